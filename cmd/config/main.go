@@ -0,0 +1,13 @@
+// Command config is a shim for "segmentation-api config", kept for existing
+// deployments and scripts.
+package main
+
+import (
+	"os"
+
+	"segmentation-api/internal/app"
+)
+
+func main() {
+	os.Exit(app.Main(append([]string{"config"}, os.Args[1:]...)))
+}
@@ -0,0 +1,119 @@
+// Command loadgen generates synthetic data files for the processor and
+// load-tests the API:
+//
+//	loadgen csv  --rows 1000000 --users 200000 --out data/bench.csv
+//	loadgen http --url http://localhost:8080 --concurrency 32 --duration 30s
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"segmentation-api/internal/loadgen"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "csv":
+		err = writeCSV(args[1:])
+	case "http":
+		err = load(args[1:])
+	case "help", "-h", "--help":
+		usage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "loadgen: unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return 2
+	}
+	switch {
+	case errors.Is(err, pflag.ErrHelp):
+		return 0
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `usage: loadgen <command> [flags]
+
+commands:
+  csv   write a synthetic data file for the processor
+  http  send concurrent GET /users/{id}/segmentations and report throughput and latency
+
+Run "loadgen <command> --help" for the flags of a command.
+`)
+}
+
+func writeCSV(args []string) error {
+	fs := pflag.NewFlagSet("loadgen csv", pflag.ContinueOnError)
+	var cfg loadgen.CSVConfig
+	fs.IntVar(&cfg.Rows, "rows", 100000, "data rows to write")
+	fs.IntVar(&cfg.Users, "users", 20000, "distinct user IDs")
+	fs.Uint64Var(&cfg.FirstUserID, "first-user-id", 1, "first user ID")
+	fs.IntVar(&cfg.Names, "names", 50, "distinct segmentation names per type")
+	fs.IntVar(&cfg.DataSize, "data-size", 32, "approximate size in bytes of the data column")
+	fs.Int64Var(&cfg.Seed, "seed", 1, "random seed; the same flags always write the same file")
+	out := fs.String("out", "-", "file to write, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		return loadgen.WriteCSV(os.Stdout, cfg)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := loadgen.WriteCSV(f, cfg); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func load(args []string) error {
+	fs := pflag.NewFlagSet("loadgen http", pflag.ContinueOnError)
+	var cfg loadgen.LoadConfig
+	fs.StringVar(&cfg.BaseURL, "url", "http://localhost:8080", "API address")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 16, "concurrent clients")
+	fs.DurationVar(&cfg.Duration, "duration", 30*time.Second, "length of the run (0 to stop on --requests only)")
+	fs.IntVar(&cfg.Requests, "requests", 0, "stop after this many requests (0 for no limit)")
+	fs.IntVar(&cfg.Users, "users", 20000, "distinct user IDs requested")
+	fs.Uint64Var(&cfg.FirstUserID, "first-user-id", 1, "first user ID")
+	fs.Int64Var(&cfg.Seed, "seed", 1, "random seed of the user IDs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := loadgen.Run(ctx, nil, cfg)
+	if err != nil {
+		return err
+	}
+	report.Write(os.Stdout)
+	return nil
+}
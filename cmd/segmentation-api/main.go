@@ -0,0 +1,28 @@
+// Command segmentation-api runs the API server, the file processor and the
+// operational subcommands.
+//
+// The general API info below feeds the OpenAPI spec generated by
+// "make swagger" into docs/.
+//
+//	@title						Segmentation API
+//	@version					1.0.0
+//	@description				API de segmentações com worker de processamento de arquivos
+//	@contact.name				Charlen Rodrigues
+//	@host						localhost:8080
+//	@BasePath					/
+//	@schemes					http https
+//	@securityDefinitions.apikey	AdminToken
+//	@in							header
+//	@name						Authorization
+//	@description				Bearer token of the /admin and /webhooks routes ("Bearer <ADMIN_TOKEN>")
+package main
+
+import (
+	"os"
+
+	"segmentation-api/internal/app"
+)
+
+func main() {
+	os.Exit(app.Main(os.Args[1:]))
+}
@@ -34,6 +34,10 @@ func (m *E2EMockRepository) FindByUserID(ctx context.Context, userID uint64) ([]
 	return []models.Segmentation{}, nil
 }
 
+func (m *E2EMockRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func (m *E2EMockRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
 	m.upserts = append(m.upserts, *s)
 
@@ -56,6 +60,18 @@ func (m *E2EMockRepository) Upsert(ctx context.Context, s *models.Segmentation)
 	return repository.UpsertInserted, nil
 }
 
+func (m *E2EMockRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *E2EMockRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *E2EMockRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
 // TestE2E_CompleteWorkflow tests full request-response cycle
 func TestE2E_CompleteWorkflow(t *testing.T) {
 	mockRepo := NewE2EMockRepository()
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTopSegments = 10
+	maxTopSegments     = 100
+)
+
+// AnalyticsHandler serves the aggregates of the internal dashboard
+type AnalyticsHandler struct {
+	analytics *service.Analytics
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(analytics *service.Analytics) *AnalyticsHandler {
+	return &AnalyticsHandler{analytics: analytics}
+}
+
+// TopSegments returns the segments with the most users of each type
+// GET /admin/analytics/top-segments
+// @Summary		Top segments per type
+// @Description	The segments (type and name) with the most users, largest first, per type. Computed from the whole table and cached for ANALYTICS_CACHE_TTL; generated_at tells when.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			type	query		string	false	"Only this type, singular or group key"
+// @Param			limit	query		integer	false	"Segments per type"	minimum(1)	maximum(100)	default(10)
+// @Success		200		{object}	service.TopSegments
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid limit"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/analytics/top-segments [get]
+func (h *AnalyticsHandler) TopSegments(c *gin.Context) {
+	limit := defaultTopSegments
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTopSegments {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxTopSegments),
+			})
+			return
+		}
+		limit = n
+	}
+
+	top, err := h.analytics.TopSegments(c.Request.Context(), c.Query("type"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, top)
+}
+
+// UserHistogram returns how the users spread over the number of
+// segmentations they have
+// GET /admin/analytics/users
+// @Summary		User distribution histogram
+// @Description	How many users have how many segmentations, of one type or of all, in buckets. Each bound in buckets closes a bucket; the last bucket is unbounded. Cached like the other aggregates.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			type	query		string	false	"Only this type, singular or group key"
+// @Param			buckets	query		string	false	"Ascending upper bounds of the buckets"	default(1,2,5,10,20,50,100)
+// @Success		200		{object}	service.UserHistogram
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid buckets"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/analytics/users [get]
+func (h *AnalyticsHandler) UserHistogram(c *gin.Context) {
+	var bounds []int64
+	if v := c.Query("buckets"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			b, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "buckets must be a comma-separated list of integers",
+				})
+				return
+			}
+			bounds = append(bounds, b)
+		}
+	}
+
+	histogram, err := h.analytics.UserHistogram(c.Request.Context(), c.Query("type"), bounds)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, histogram)
+}
+
+// Growth returns the daily size of each type from the snapshots
+// GET /admin/analytics/growth
+// @Summary		Growth over time
+// @Description	The size of each type per day, from the daily snapshots taken every ANALYTICS_SNAPSHOT_INTERVAL, with the change since the previous snapshot. Days without a snapshot are left out.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			type	query		string	false	"Only this type, singular or group key"
+// @Param			from	query		string	false	"First day, YYYY-MM-DD"
+// @Param			to		query		string	false	"Last day, YYYY-MM-DD"
+// @Success		200		{object}	service.Growth
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid from or to"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/analytics/growth [get]
+func (h *AnalyticsHandler) Growth(c *gin.Context) {
+	growth, err := h.analytics.Growth(c.Request.Context(), c.Query("type"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, growth)
+}
+
+// TakeSnapshot records the size of every type today now, instead of
+// waiting for the next scheduled snapshot
+// POST /admin/analytics/snapshots
+// @Summary		Take a snapshot
+// @Description	Records the size of every type today (UTC), replacing the earlier snapshot of the day.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	object{types=integer}
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/analytics/snapshots [post]
+func (h *AnalyticsHandler) TakeSnapshot(c *gin.Context) {
+	types, err := h.analytics.TakeSnapshot(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"types": types})
+}
+
+// Overlap returns how many users two or more segments share
+// GET /admin/analytics/overlap
+// @Summary		Segment overlap
+// @Description	How many users are in every one of the segments (intersection) and in at least one (union), e.g. cardiologists who also have Alopáticos. Each segment is type:name, the type singular or as its group key. When the largest segment has more than ANALYTICS_OVERLAP_SAMPLE_THRESHOLD users, intersection and union are estimated from a sample of the users (sampled, sample_rate); the segment sizes are exact. Cached like the other aggregates.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			segment	query		[]string	true	"Segment as type:name, 2 to 10 of them"	collectionFormat(multi)
+// @Success		200		{object}	service.Overlap
+// @Failure		400		{object}	handler.ErrorResponse	"Fewer than 2 or more than 10 segments, or a segment without type or name"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/analytics/overlap [get]
+func (h *AnalyticsHandler) Overlap(c *gin.Context) {
+	var segments []service.OverlapSegment
+	for _, v := range c.QueryArray("segment") {
+		// names may hold colons, types do not
+		segType, name, ok := strings.Cut(v, ":")
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "segment must be type:name",
+			})
+			return
+		}
+		segments = append(segments, service.OverlapSegment{Type: segType, Name: name})
+	}
+
+	overlap, err := h.analytics.Overlap(c.Request.Context(), segments)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, overlap)
+}
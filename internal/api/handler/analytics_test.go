@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockAnalytics answers fixed aggregates and records the last query
+type mockAnalytics struct {
+	segType  string
+	limit    int
+	filter   repository.SnapshotFilter
+	segments []repository.Segment
+}
+
+func (m *mockAnalytics) TopSegments(ctx context.Context, segType string, limit int) ([]repository.SegmentCount, error) {
+	m.segType, m.limit = segType, limit
+	return []repository.SegmentCount{{SegmentationType: "drug", SegmentationName: "Dipirona", Users: 3}}, nil
+}
+
+func (m *mockAnalytics) UserCounts(ctx context.Context, segType string) ([]repository.UserCount, error) {
+	m.segType = segType
+	return []repository.UserCount{{Segmentations: 1, Users: 4}, {Segmentations: 3, Users: 1}}, nil
+}
+
+func (m *mockAnalytics) TakeSnapshot(ctx context.Context, day string, takenAt int64) (int64, error) {
+	return 3, nil
+}
+
+func (m *mockAnalytics) Snapshots(ctx context.Context, filter repository.SnapshotFilter) ([]models.SegmentSnapshot, error) {
+	m.filter = filter
+	return []models.SegmentSnapshot{{Day: "2026-10-16", SegmentationType: "drug", Segmentations: 5, Users: 4, Segments: 2}}, nil
+}
+
+func (m *mockAnalytics) SegmentSizes(ctx context.Context, segments []repository.Segment) ([]int64, error) {
+	m.segments = segments
+	return []int64{10, 20}[:len(segments)], nil
+}
+
+func (m *mockAnalytics) Overlap(ctx context.Context, segments []repository.Segment, samplePermille int) (int64, int64, error) {
+	return 5, 25, nil
+}
+
+func TestAnalyticsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockAnalytics{}
+	h := NewAnalyticsHandler(service.NewAnalytics(repo, time.Minute))
+	r := gin.New()
+	r.GET("/admin/analytics/top-segments", h.TopSegments)
+	r.GET("/admin/analytics/users", h.UserHistogram)
+	r.GET("/admin/analytics/growth", h.Growth)
+	r.POST("/admin/analytics/snapshots", h.TakeSnapshot)
+	r.GET("/admin/analytics/overlap", h.Overlap)
+
+	w := doWebhookRequest(r, "GET", "/admin/analytics/top-segments?type=drugs&limit=5", "")
+	var top service.TopSegments
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &top) != nil || len(top.Types["drug"]) != 1 {
+		t.Fatalf("GET top-segments = %d %s", w.Code, w.Body.String())
+	}
+	if repo.segType != "drug" || repo.limit != 5 {
+		t.Errorf("queried type %q limit %d, want drug and 5", repo.segType, repo.limit)
+	}
+
+	w = doWebhookRequest(r, "GET", "/admin/analytics/users?buckets=1,2", "")
+	var histogram service.UserHistogram
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &histogram) != nil || histogram.Users != 5 || len(histogram.Buckets) != 3 || histogram.Buckets[2].Users != 1 {
+		t.Errorf("GET users = %d %s", w.Code, w.Body.String())
+	}
+
+	w = doWebhookRequest(r, "GET", "/admin/analytics/growth?from=2026-10-01&to=2026-10-31", "")
+	var growth service.Growth
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &growth) != nil || len(growth.Types["drug"]) != 1 || repo.filter.From != "2026-10-01" {
+		t.Errorf("GET growth = %d %s (filter %+v)", w.Code, w.Body.String(), repo.filter)
+	}
+
+	if w := doWebhookRequest(r, "POST", "/admin/analytics/snapshots", ""); w.Code != http.StatusOK || w.Body.String() != `{"types":3}` {
+		t.Errorf("POST snapshots = %d %s", w.Code, w.Body.String())
+	}
+
+	w = doWebhookRequest(r, "GET", "/admin/analytics/overlap?segment=specialty:Cardiologia&segment=drugs:Alop%C3%A1ticos:%20500mg", "")
+	var overlap service.Overlap
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &overlap) != nil || overlap.Intersection != 5 || overlap.Union != 25 {
+		t.Errorf("GET overlap = %d %s", w.Code, w.Body.String())
+	}
+	if len(repo.segments) != 2 || repo.segments[1] != (repository.Segment{Type: "drug", Name: "Alopáticos: 500mg"}) {
+		t.Errorf("queried segments %+v", repo.segments)
+	}
+
+	for _, path := range []string{
+		"/admin/analytics/overlap?segment=specialty:Cardiologia",
+		"/admin/analytics/overlap?segment=specialty:Cardiologia&segment=Alopaticos",
+		"/admin/analytics/top-segments?limit=0",
+		"/admin/analytics/top-segments?limit=101",
+		"/admin/analytics/users?buckets=a",
+		"/admin/analytics/users?buckets=5,2",
+		"/admin/analytics/growth?from=last-week",
+	} {
+		if w := doWebhookRequest(r, "GET", path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"segmentation-api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditLogHandler serves the audit log admin endpoint
+type AuditLogHandler struct {
+	store repository.AuditLogRepository
+}
+
+// NewAuditLogHandler creates a new audit log handler
+func NewAuditLogHandler(store repository.AuditLogRepository) *AuditLogHandler {
+	return &AuditLogHandler{store: store}
+}
+
+// ListAuditLog returns audit entries, most recent first, filtered by the
+// actor, action, since and until (RFC 3339) query parameters. Pages go on
+// with after, the ID of the last entry of the previous page.
+// GET /admin/audit-log
+// @Summary		List audit entries
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			actor	query		string	false	"Actor"
+// @Param			action	query		string	false	"Action"
+// @Param			since	query		string	false	"RFC 3339 timestamp"
+// @Param			until	query		string	false	"RFC 3339 timestamp"
+// @Param			after	query		integer	false	"Only entries older than this ID"
+// @Param			limit	query		integer	false	"Page size"	minimum(1)	maximum(1000)	default(100)
+// @Success		200		{object}	object{entries=[]models.AuditLog,links=handler.Links,meta=handler.Meta}
+// @Header			200		{string}	Link	"The next page, rel=next"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid since, until, after or limit"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/audit-log [get]
+func (h *AuditLogHandler) ListAuditLog(c *gin.Context) {
+	filter := repository.AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Limit:  defaultAuditLimit,
+	}
+
+	for param, dst := range map[string]*int64{"since": &filter.Since, "until": &filter.Until} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": param + " must be an RFC 3339 timestamp",
+			})
+			return
+		}
+		*dst = t.Unix()
+	}
+
+	if v := c.Query("after"); v != "" {
+		after, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "after must be a positive integer",
+			})
+			return
+		}
+		filter.AfterID = after
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxAuditLimit),
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.store.List(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	links := selfLinks(c)
+	if len(entries) == filter.Limit {
+		links.Next = pageLink(c, map[string]string{"after": strconv.FormatUint(entries[len(entries)-1].ID, 10)})
+	}
+	setLinks(c, links)
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"links":   links,
+		"meta":    Meta{Returned: len(entries)},
+	})
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockAuditLog struct {
+	filter repository.AuditFilter
+}
+
+func (m *mockAuditLog) Insert(ctx context.Context, entry *models.AuditLog) error {
+	return nil
+}
+
+func (m *mockAuditLog) List(ctx context.Context, filter repository.AuditFilter) ([]models.AuditLog, error) {
+	m.filter = filter
+	return []models.AuditLog{{ID: 1, Actor: "admin", Action: "auth_failed"}}, nil
+}
+
+func TestAuditLogHandler_ListAuditLog(t *testing.T) {
+	store := &mockAuditLog{}
+	h := NewAuditLogHandler(store)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET",
+		"/admin/audit-log?actor=admin&action=auth_failed&since=2026-01-01T00:00:00Z&after=50&limit=10", nil)
+
+	h.ListAuditLog(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	want := repository.AuditFilter{Actor: "admin", Action: "auth_failed", Since: since, AfterID: 50, Limit: 10}
+	if store.filter != want {
+		t.Errorf("filter = %+v, want %+v", store.filter, want)
+	}
+
+	var resp struct {
+		Entries []models.AuditLog `json:"entries"`
+		Links   Links             `json:"links"`
+		Meta    Meta              `json:"meta"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Entries) != 1 || resp.Entries[0].Action != "auth_failed" {
+		t.Errorf("unexpected entries: %+v", resp.Entries)
+	}
+	// a short page is the last one
+	if resp.Links.Next != "" || w.Header().Get("Link") != "" || resp.Meta.Returned != 1 {
+		t.Errorf("links = %+v, meta = %+v, Link = %q", resp.Links, resp.Meta, w.Header().Get("Link"))
+	}
+}
+
+func TestAuditLogHandler_NextPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/audit-log?actor=admin&limit=1", nil)
+
+	NewAuditLogHandler(&mockAuditLog{}).ListAuditLog(c)
+
+	next := "/admin/audit-log?actor=admin&after=1&limit=1"
+	if link := w.Header().Get("Link"); link != "<"+next+`>; rel="next"` {
+		t.Errorf("Link = %q", link)
+	}
+	var resp struct {
+		Links Links `json:"links"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Links.Next != next {
+		t.Errorf("next = %q, want %q", resp.Links.Next, next)
+	}
+}
+
+func TestAuditLogHandler_InvalidQuery(t *testing.T) {
+	for _, query := range []string{"since=yesterday", "until=2026-13-01", "after=-1", "limit=0", "limit=5000"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/admin/audit-log?"+query, nil)
+
+		NewAuditLogHandler(&mockAuditLog{}).ListAuditLog(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
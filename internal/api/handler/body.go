@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"segmentation-api/internal/api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindJSON decodes the request body into obj. With strict, members obj
+// does not declare are refused instead of ignored, so a misspelled field
+// is not silently dropped. On failure it answers 413 for a body over the
+// limit of middleware.LimitBody and 400 otherwise, and returns false.
+func bindJSON(c *gin.Context, obj any, strict bool) bool {
+	dec := json.NewDecoder(c.Request.Body)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(obj)
+	if err == nil {
+		return true
+	}
+	if middleware.AbortBodyTooLarge(c, err) {
+		return false
+	}
+
+	body := gin.H{"error": "invalid request body"}
+	// the decoder names the member as "json: unknown field \"x\""
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		body["reason"] = "unknown field " + field
+	}
+	c.JSON(http.StatusBadRequest, body)
+	return false
+}
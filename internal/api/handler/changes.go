@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"segmentation-api/internal/api/render"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// ChangesHandler serves the incremental changes feed
+type ChangesHandler struct {
+	feed *service.ChangeFeed
+}
+
+// NewChangesHandler creates a new changes handler
+func NewChangesHandler(feed *service.ChangeFeed) *ChangesHandler {
+	return &ChangesHandler{feed: feed}
+}
+
+// ListChanges returns segmentations in the order they were last written,
+// starting at since (RFC 3339 or unix seconds, inclusive) or right after
+// the cursor of a previous page; limit caps the page size and each filter
+// (field:op:value) restricts the rows
+// GET /segmentations/changes
+// @Summary		List changed segmentations
+// @Tags			segmentations
+// @Produce		json
+// @Produce		text/csv
+// @Produce		application/xml
+// @Param			since	query		string		false	"RFC 3339 timestamp or unix seconds; excludes cursor"
+// @Param			cursor	query		string		false	"next_cursor of the previous page; excludes since"
+// @Param			limit	query		integer		false	"Page size"	minimum(1)	maximum(1000)	default(100)
+// @Param			filter	query		[]string	false	"field:op:value"	collectionFormat(multi)
+// @Success		200		{object}	service.ChangesPage{links=handler.Links,meta=handler.Meta}
+// @Header			200		{string}	Link	"The next page, while has_more"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid since, cursor, limit or filter"
+// @Failure		503		{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Failure		504		{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/segmentations/changes [get]
+func (h *ChangesHandler) ListChanges(c *gin.Context) {
+	since, cursor := c.Query("since"), c.Query("cursor")
+	if since != "" && cursor != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "since and cursor are mutually exclusive",
+		})
+		return
+	}
+
+	var after repository.ChangeCursor
+	switch {
+	case cursor != "":
+		parsed, err := service.ParseChangeCursor(cursor)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		after = parsed
+	case since != "":
+		ts, err := parseTimestamp(since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "since must be an RFC 3339 timestamp or unix seconds",
+			})
+			return
+		}
+		after.UpdatedAt = ts
+	}
+
+	limit := defaultChangesLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxChangesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxChangesLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	filter, err := service.ParseFilter(c.QueryArray("filter"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	page, err := h.feed.After(c.Request.Context(), after, filter, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	links := selfLinks(c)
+	if page.HasMore {
+		links.Next = pageLink(c, map[string]string{"cursor": page.NextCursor, "since": ""})
+	}
+	setLinks(c, links)
+	render.Render(c, http.StatusOK, changesResponse{
+		ChangesPage: page,
+		Links:       links,
+		Meta:        Meta{Returned: len(page.Changes)},
+	})
+}
+
+// changesResponse is a ChangesPage with its pagination
+type changesResponse struct {
+	*service.ChangesPage
+	Links Links `json:"links"`
+	Meta  Meta  `json:"meta"`
+}
+
+// parseTimestamp parses RFC 3339 or unix seconds
+func parseTimestamp(s string) (int64, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+type mockChanges struct {
+	after  repository.ChangeCursor
+	filter repository.Filter
+	limit  int
+}
+
+func (m *mockChanges) Changes(ctx context.Context, after repository.ChangeCursor, filter repository.Filter, limit int) ([]models.Segmentation, error) {
+	m.after, m.filter, m.limit = after, filter, limit
+	return []models.Segmentation{
+		{ID: 7, UserID: 1, SegmentationType: "drug", SegmentationName: "Dipirona", Data: datatypes.JSON(`{"quantity":"200"}`), UpdatedAt: 1767225600},
+	}, nil
+}
+
+func TestChangesHandler_ListChanges(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	cursor := service.EncodeChangeCursor(repository.ChangeCursor{UpdatedAt: since, ID: 3})
+
+	tests := []struct {
+		query     string
+		wantAfter repository.ChangeCursor
+		wantLimit int
+	}{
+		{query: "", wantLimit: defaultChangesLimit + 1},
+		{query: "since=2026-01-01T00:00:00Z&limit=10", wantAfter: repository.ChangeCursor{UpdatedAt: since}, wantLimit: 11},
+		{query: "since=1767225600", wantAfter: repository.ChangeCursor{UpdatedAt: since}, wantLimit: defaultChangesLimit + 1},
+		{query: "cursor=" + cursor, wantAfter: repository.ChangeCursor{UpdatedAt: since, ID: 3}, wantLimit: defaultChangesLimit + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			store := &mockChanges{}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/segmentations/changes?"+tt.query, nil)
+
+			NewChangesHandler(service.NewChangeFeed(store)).ListChanges(c)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if store.after != tt.wantAfter || store.limit != tt.wantLimit {
+				t.Errorf("Changes(%+v, %d), want (%+v, %d)", store.after, store.limit, tt.wantAfter, tt.wantLimit)
+			}
+
+			var page service.ChangesPage
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Changes) != 1 || page.Changes[0].Name != "Dipirona" || page.HasMore {
+				t.Errorf("unexpected page: %+v", page)
+			}
+			if !bytes.Contains(w.Body.Bytes(), []byte(`"created_at":null,"updated_at":"2026-01-01T00:00:00Z"`)) {
+				t.Errorf("timestamps should be RFC3339: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestChangesHandler_InvalidQuery(t *testing.T) {
+	for _, query := range []string{"since=yesterday", "cursor=!!", "since=1&cursor=MS4y", "limit=0", "limit=5000", "filter=name_key:eq:x", "filter=user_id:like:1"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/segmentations/changes?"+query, nil)
+
+		NewChangesHandler(service.NewChangeFeed(&mockChanges{})).ListChanges(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestChangesHandler_Filter(t *testing.T) {
+	store := &mockChanges{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/segmentations/changes?filter=segmentation_type:eq:drug&filter=data.dose:prefix:500", nil)
+
+	NewChangesHandler(service.NewChangeFeed(store)).ListChanges(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(store.filter) != 2 || store.filter[0].Field != "segmentation_type" || store.filter[1].Op != repository.FilterPrefix || store.filter[1].Values[0] != "500" {
+		t.Errorf("unexpected filter: %+v", store.filter)
+	}
+}
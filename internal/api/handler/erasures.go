@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultErasureLimit = 100
+	maxErasureLimit     = 1000
+)
+
+// ErasureHandler handles the right-to-erasure endpoints
+type ErasureHandler struct {
+	erasures *service.Erasures
+}
+
+// NewErasureHandler creates a new erasure handler
+func NewErasureHandler(erasures *service.Erasures) *ErasureHandler {
+	return &ErasureHandler{erasures: erasures}
+}
+
+// EraseUser hard-deletes every segmentation of a user and its change
+// history, for LGPD and GDPR erasure requests, and answers the erasure
+// record kept as proof
+// DELETE /users/:user_id
+// @Summary		Erase a user
+// @Description	Deletes the segmentations of the user, the outbox events about them, the webhook deliveries of those events, the rows of the user rejected by the processor and the responses stored for the user's requests under Idempotency-Key in one transaction, and records who erased them, when and how many rows, chained by hash to the previous records. The writes of the user still waiting in the write queue are dropped first. Subscribers get a segmentation.deleted event per erased segmentation. A user without data is recorded too.
+// @Tags			segmentations
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			user_id	path		string					true	"User ID"
+// @Param			request	body		service.ErasureRequest	false	"Reference of the request"
+// @Success		200		{object}	models.Erasure
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid user_id or body"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/users/{user_id} [delete]
+func (h *ErasureHandler) EraseUser(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, h.erasures.ParseUserID)
+	if !ok {
+		return
+	}
+
+	var req service.ErasureRequest
+	// the body is optional
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	erasure, err := h.erasures.Erase(c.Request.Context(), userID, c.GetString(middleware.ActorKey), c.ClientIP(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, erasure)
+}
+
+// ListErasures returns the erasure records in the order of their chain,
+// oldest first, optionally those of one user
+// GET /admin/erasures
+// @Summary		List erasure records
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			user_id	query		integer	false	"Only the records of this user"
+// @Param			after	query		integer	false	"Only records after this ID"
+// @Param			limit	query		integer	false	"Page size"	minimum(1)	maximum(1000)	default(100)
+// @Success		200		{object}	object{erasures=[]models.Erasure,links=handler.Links,meta=handler.Meta}
+// @Header			200		{string}	Link	"The next page, rel=next"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid user_id, after or limit"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/erasures [get]
+func (h *ErasureHandler) ListErasures(c *gin.Context) {
+	filter := repository.ErasureFilter{Limit: defaultErasureLimit}
+
+	for param, dst := range map[string]*uint64{"user_id": &filter.UserID, "after": &filter.AfterID} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": param + " must be a positive integer",
+			})
+			return
+		}
+		*dst = n
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxErasureLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxErasureLimit),
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	erasures, err := h.erasures.List(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	links := selfLinks(c)
+	if len(erasures) == filter.Limit {
+		links.Next = pageLink(c, map[string]string{"after": strconv.FormatUint(erasures[len(erasures)-1].ID, 10)})
+	}
+	setLinks(c, links)
+	c.JSON(http.StatusOK, gin.H{
+		"erasures": erasures,
+		"links":    links,
+		"meta":     Meta{Returned: len(erasures)},
+	})
+}
+
+// VerifyErasures checks the hash chain of the erasure records, telling
+// whether any was edited or removed since it was written
+// GET /admin/erasures/verify
+// @Summary		Verify the erasure records
+// @Description	Recomputes the hash of every record and its link to the previous one. broken_at is the first record that does not match; head is the hash of the last record.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	service.ErasureChain
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/erasures/verify [get]
+func (h *ErasureHandler) VerifyErasures(c *gin.Context) {
+	chain, err := h.erasures.Verify(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, chain)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockErasures chains the records it is given, erasing nothing
+type mockErasures struct {
+	records []models.Erasure
+}
+
+func (m *mockErasures) Erase(ctx context.Context, erasure *models.Erasure) ([]models.Segmentation, error) {
+	if len(m.records) > 0 {
+		erasure.PrevHash = m.records[len(m.records)-1].Hash
+	}
+	erasure.ID = uint64(len(m.records) + 1)
+	erasure.Segmentations = 2
+	erasure.Hash = erasure.ComputeHash()
+	m.records = append(m.records, *erasure)
+	return []models.Segmentation{
+		{UserID: erasure.UserID, SegmentationType: "drug", SegmentationName: "Dipirona"},
+		{UserID: erasure.UserID, SegmentationType: "specialty", SegmentationName: "Cardiologia"},
+	}, nil
+}
+
+func (m *mockErasures) List(ctx context.Context, filter repository.ErasureFilter) ([]models.Erasure, error) {
+	var out []models.Erasure
+	for _, e := range m.records {
+		if e.ID > filter.AfterID && (filter.UserID == 0 || e.UserID == filter.UserID) && len(out) < filter.Limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func TestErasureHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockErasures{}
+	h := NewErasureHandler(service.NewErasures(repo, service.NewSegmentationService(nil)))
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(middleware.ActorKey, "admin") })
+	r.DELETE("/users/:user_id", h.EraseUser)
+	r.GET("/admin/erasures", h.ListErasures)
+	r.GET("/admin/erasures/verify", h.VerifyErasures)
+
+	for _, path := range []string{"/users/abc", "/users/0"} {
+		if w := doWebhookRequest(r, "DELETE", path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("DELETE %s = %d, want 400", path, w.Code)
+		}
+	}
+
+	w := doWebhookRequest(r, "DELETE", "/users/10", `{"reference": "DPO-1"}`)
+	var erasure models.Erasure
+	json.Unmarshal(w.Body.Bytes(), &erasure)
+	if w.Code != http.StatusOK || erasure.Actor != "admin" || erasure.Reference != "DPO-1" || erasure.Segmentations != 2 || erasure.Hash == "" {
+		t.Fatalf("unexpected erasure %d: %s", w.Code, w.Body.String())
+	}
+	if w := doWebhookRequest(r, "DELETE", "/users/11", ""); w.Code != http.StatusOK {
+		t.Errorf("an erasure without a body = %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doWebhookRequest(r, "GET", "/admin/erasures?limit=1", "")
+	if w.Code != http.StatusOK || w.Header().Get("Link") != `</admin/erasures?after=1&limit=1>; rel="next"` {
+		t.Errorf("unexpected first page %d: %s", w.Code, w.Body.String())
+	}
+	w = doWebhookRequest(r, "GET", "/admin/erasures?user_id=11", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"user_id":11`) || strings.Contains(w.Body.String(), `"user_id":10`) {
+		t.Errorf("unexpected records of user 11 %d: %s", w.Code, w.Body.String())
+	}
+	for _, query := range []string{"user_id=x", "after=-1", "limit=0"} {
+		if w := doWebhookRequest(r, "GET", "/admin/erasures?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /admin/erasures?%s = %d, want 400", query, w.Code)
+		}
+	}
+
+	w = doWebhookRequest(r, "GET", "/admin/erasures/verify", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"valid":true,"records":2`) {
+		t.Errorf("unexpected verification %d: %s", w.Code, w.Body.String())
+	}
+	repo.records[0].Reference = "edited"
+	w = doWebhookRequest(r, "GET", "/admin/erasures/verify", "")
+	if !strings.Contains(w.Body.String(), `"valid":false`) || !strings.Contains(w.Body.String(), `"broken_at":1`) {
+		t.Errorf("an edited record should break the chain: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the envelope of every error answer: the message plus
+// the fields some errors add. Handlers build it as a gin.H; the type only
+// describes it in the OpenAPI spec.
+type ErrorResponse struct {
+	Error string `json:"error" example:"invalid segmentation: empty name for type \"drug\""`
+	// Value and Reason describe a refused user_id; on a 409 Key lists the
+	// columns of the unique key and Value holds the repeated value
+	Value  string   `json:"value,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+	Key    []string `json:"key,omitempty"`
+	// Allowed lists the accepted segmentation types of an unknown type
+	Allowed []string `json:"allowed,omitempty"`
+	// Errors lists the items of a transactional bulk write that was rolled
+	// back
+	Errors []service.BulkItemError `json:"errors,omitempty"`
+	// MaxBytes is the body limit of a 413
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
+// respondError answers err with the status of its kind (see
+// apperrors.Status) and {"error": message} plus the fields the error adds,
+// such as the conflicting key or the allowed types. 503 responses carry
+// Retry-After so clients back off; errors without a kind answer 500 and are
+// recorded on the context for the logger and the error reporter.
+func respondError(c *gin.Context, err error) {
+	status := apperrors.Status(err)
+
+	body := gin.H{"error": err.Error()}
+	for k, v := range apperrors.Details(err) {
+		body[k] = v
+	}
+
+	switch status {
+	case http.StatusServiceUnavailable:
+		retry := time.Second
+		var unavailable *repository.UnavailableError
+		if errors.As(err, &unavailable) {
+			retry = unavailable.RetryAfter
+		}
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retry)))
+	case http.StatusInternalServerError:
+		c.Error(err)
+	}
+	c.JSON(status, body)
+}
+
+// retryAfterSeconds rounds d up to whole seconds, at least one
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportUserSegmentations streams all segmentations of a user as a
+// downloadable JSON or CSV document; bom=true starts a CSV with the UTF-8
+// byte order mark, for spreadsheets
+// GET /users/:user_id/segmentations/export?format=json|csv&bom=true
+// @Summary		Export user segmentations
+// @Tags			segmentations
+// @Produce		json
+// @Produce		text/csv
+// @Param			user_id	path		integer	true	"User ID"	minimum(1)
+// @Param			format	query		string	false	"Document format"	Enums(json, csv)	default(json)
+// @Param			bom		query		boolean	false	"Start a CSV with the UTF-8 byte order mark, so Excel reads accents"	default(false)
+// @Success		200		{file}		file
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid user_id, format or bom"
+// @Failure		503		{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Router			/users/{user_id}/segmentations/export [get]
+func (h *SegmentationHandler) ExportUserSegmentations(c *gin.Context) {
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+
+	format, err := service.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var opts []service.ExportOption
+	if v := c.Query("bom"); v != "" {
+		bom, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "bom must be true or false",
+			})
+			return
+		}
+		if bom {
+			opts = append(opts, service.WithBOM())
+		}
+	}
+
+	filename := fmt.Sprintf("user-%d-segmentations.%s", userID, format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	ctx := c.Request.Context()
+	if err := h.service.Export(ctx, userID, format, c.Writer, opts...); err != nil {
+		// once bytes are on the wire the status can no longer change
+		if c.Writer.Written() {
+			c.Error(err)
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		respondError(c, err)
+		return
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+func newExportContext(userID, format string) (*gin.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest("GET", "/users/"+userID+"/segmentations/export?format="+format, nil)
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = []gin.Param{{Key: "user_id", Value: userID}}
+	return c, w
+}
+
+func TestExportUserSegmentations_CSV(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{
+				{
+					UserID:           123,
+					SegmentationType: "drug",
+					SegmentationName: "Alopáticos",
+					Data:             datatypes.JSON(`{"quantity": "200"}`),
+				},
+			}, nil
+		},
+	}
+	handler := NewSegmentationHandler(service.NewSegmentationService(mockRepo))
+
+	c, w := newExportContext("123", "csv")
+	handler.ExportUserSegmentations(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv content type, got %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "user-123-segmentations.csv") {
+		t.Errorf("unexpected Content-Disposition: %s", cd)
+	}
+	if !strings.Contains(w.Body.String(), "Alopáticos") {
+		t.Errorf("body should contain the segmentation name, got %s", w.Body.String())
+	}
+}
+
+func TestExportUserSegmentations_BOM(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	c, w := newExportContext("123", "csv&bom=true")
+	handler.ExportUserSegmentations(c)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "\ufeffuser_id,") {
+		t.Fatalf("expected a CSV with BOM, got %d %q", w.Code, w.Body.String())
+	}
+
+	c, w = newExportContext("123", "csv&bom=maybe")
+	handler.ExportUserSegmentations(c)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestExportUserSegmentations_DefaultsToJSON(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	c, w := newExportContext("123", "")
+	handler.ExportUserSegmentations(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expected application/json content type, got %s", ct)
+	}
+}
+
+func TestExportUserSegmentations_InvalidFormat(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	c, w := newExportContext("123", "xml")
+	handler.ExportUserSegmentations(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestExportUserSegmentations_InvalidUserID(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	c, w := newExportContext("abc", "json")
+	handler.ExportUserSegmentations(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestExportUserSegmentations_RepositoryError(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return nil, errors.New("db down")
+		},
+	}
+	handler := NewSegmentationHandler(service.NewSegmentationService(mockRepo))
+
+	c, w := newExportContext("123", "csv")
+	handler.ExportUserSegmentations(c)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", w.Code)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != "" {
+		t.Errorf("error responses should not be attachments, got %s", cd)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler handles the table export endpoints
+type ExportHandler struct {
+	exports *service.Exports
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(exports *service.Exports) *ExportHandler {
+	return &ExportHandler{exports: exports}
+}
+
+// StartExport starts exporting the segmentations passing the filter, or
+// all of them, to gzip-compressed NDJSON files; the answer points at the
+// export to follow its progress
+// POST /admin/exports
+// @Summary		Start a table export
+// @Description	Writes the segmentations as gzip-compressed NDJSON chunks and a manifest.json, to EXPORT_DIR or the S3 bucket. One export runs at a time per instance. With pseudonymize, user IDs are replaced by HMAC pseudonyms and the EXPORT_STRIP_DATA_KEYS are dropped from data.
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			request	body		service.ExportRequest	false	"Filter of the rows to export"
+// @Success		202		{object}	service.ExportJob
+// @Header			202		{string}	Location	"/admin/exports/{id}"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid body or filter, or pseudonymized exports not configured"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		409		{object}	handler.ErrorResponse	"An export is already running"
+// @Router			/admin/exports [post]
+func (h *ExportHandler) StartExport(c *gin.Context) {
+	var req service.ExportRequest
+	// the body is optional: no body exports every segmentation
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	job, err := h.exports.Start(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Location", "/admin/exports/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetExport returns the status and progress of an export
+// GET /admin/exports/:id
+// @Summary		Get a table export
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			id	path		string	true	"Export ID"
+// @Success		200	{object}	service.ExportJob
+// @Failure		401	{object}	handler.ErrorResponse
+// @Failure		404	{object}	handler.ErrorResponse	"Unknown export"
+// @Router			/admin/exports/{id} [get]
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	job, err := h.exports.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
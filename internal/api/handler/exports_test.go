@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockExports starts exports into its runs
+type mockExports struct {
+	repository.RunRepository
+	runs    map[string]*models.Run
+	filter  repository.Filter
+	running bool
+}
+
+func (m *mockExports) Start(ctx context.Context, filter repository.Filter, source string, pseudonymize bool) (*models.Run, error) {
+	if m.running {
+		return nil, apperrors.New("an export is already running", apperrors.ErrConflict)
+	}
+	m.running, m.filter = true, filter
+	run := &models.Run{ID: "export-1", Kind: models.RunExport, Status: models.RunRunning, Source: source, Pseudonymized: pseudonymize}
+	m.runs[run.ID] = run
+	return run, nil
+}
+
+func (m *mockExports) Get(ctx context.Context, id string) (*models.Run, error) {
+	return m.runs[id], nil
+}
+
+func TestExportHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := &mockExports{runs: map[string]*models.Run{}}
+	h := NewExportHandler(service.NewExports(m, m))
+	r := gin.New()
+	r.POST("/admin/exports", h.StartExport)
+	r.GET("/admin/exports/:id", h.GetExport)
+
+	if w := doWebhookRequest(r, "POST", "/admin/exports", `{"filter": ["password:eq:x"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("an invalid filter = %d, want 400", w.Code)
+	}
+
+	w := doWebhookRequest(r, "POST", "/admin/exports", `{"filter": ["segmentation_type:eq:drug"]}`)
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") != "/admin/exports/export-1" {
+		t.Fatalf("expected 202 at /admin/exports/export-1, got %d %q: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	if len(m.filter) != 1 || m.filter[0].Values[0] != "drug" {
+		t.Errorf("unexpected filter %+v", m.filter)
+	}
+
+	if w := doWebhookRequest(r, "POST", "/admin/exports", ""); w.Code != http.StatusConflict {
+		t.Errorf("a second export = %d, want 409", w.Code)
+	}
+
+	m.runs["export-1"].Status, m.runs["export-1"].RowsRead = models.RunSucceeded, 1200
+	w = doWebhookRequest(r, "GET", "/admin/exports/export-1", "")
+	var job service.ExportJob
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if w.Code != http.StatusOK || job.Status != models.RunSucceeded || job.Rows != 1200 || len(job.Filter) != 1 {
+		t.Errorf("unexpected export %d: %s", w.Code, w.Body.String())
+	}
+	if w := doWebhookRequest(r, "GET", "/admin/exports/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("an unknown export = %d, want 404", w.Code)
+	}
+
+	m.running = false
+	w = doWebhookRequest(r, "POST", "/admin/exports", `{"pseudonymize": true}`)
+	if w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"pseudonymized":true`) {
+		t.Errorf("a pseudonymized export = %d: %s", w.Code, w.Body.String())
+	}
+}
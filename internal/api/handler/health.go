@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"segmentation-api/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler reports the state of the API dependencies
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler creates a new health details handler
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Details runs every dependency check. The response is 503 when any
+// dependency is down and 200 otherwise, degraded included.
+// GET /health/details
+// @Summary		Dependency health
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	health.Report
+// @Failure		503	{object}	health.Report	"A dependency is down"
+// @Router			/health/details [get]
+func (h *HealthHandler) Details(c *gin.Context) {
+	report := h.checker.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StateDown {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthHandler_Details(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    health.State
+		expected int
+	}{
+		{name: "ok", state: health.StateOK, expected: http.StatusOK},
+		{name: "degraded", state: health.StateDegraded, expected: http.StatusOK},
+		{name: "down", state: health.StateDown, expected: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			checker.Register("database", func(context.Context) health.Result {
+				return health.Result{State: tt.state, Details: map[string]any{"latency_ms": 1.5}}
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/health/details", nil)
+
+			NewHealthHandler(checker).Details(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+			var report health.Report
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Status != tt.state || report.Checks["database"].Details["latency_ms"] != 1.5 {
+				t.Errorf("unexpected report: %+v", report)
+			}
+		})
+	}
+}
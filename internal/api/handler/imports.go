@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultImportLimit = 100
+	maxImportLimit     = 1000
+)
+
+// ImportHandler starts imports and serves the reports of processor runs
+type ImportHandler struct {
+	errors  *service.ImportErrors
+	imports *service.Imports
+}
+
+// NewImportHandler creates a new import handler; imports may be nil when
+// the endpoints following and starting imports are not served
+func NewImportHandler(errors *service.ImportErrors, imports *service.Imports) *ImportHandler {
+	return &ImportHandler{errors: errors, imports: imports}
+}
+
+// StartImport stores a CSV, uploaded as the multipart field file or found
+// at an s3:// url, and imports it in the background; the answer carries
+// the ID of its run
+// POST /imports
+// @Summary		Start an import
+// @Description	Imports a CSV in the format of the processor, uploaded as multipart/form-data or given as the s3:// URL of an object, also accepted as a JSON body {"url": ...}. The file is stored and processed in the background as a processor run; one import runs at a time per instance.
+// @Tags			imports
+// @Accept			multipart/form-data
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			file	formData	file	false	"CSV to import"
+// @Param			url		formData	string	false	"s3://bucket/key of a CSV to import instead"
+// @Success		202		{object}	service.ImportJob
+// @Header			202		{string}	Location	"/imports/{id}"
+// @Failure		400		{object}	handler.ErrorResponse	"Neither a file nor a valid URL"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		409		{object}	handler.ErrorResponse	"An import is already running"
+// @Failure		413		{object}	handler.ErrorResponse	"The file exceeds IMPORT_MAX_BYTES"
+// @Router			/imports [post]
+func (h *ImportHandler) StartImport(c *gin.Context) {
+	var (
+		job *service.ImportJob
+		err error
+	)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		job, err = h.upload(c)
+	} else {
+		var req service.ImportRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid request body",
+			})
+			return
+		}
+		job, err = h.imports.Fetch(c.Request.Context(), req)
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Location", "/imports/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListImports returns the imports recorded in the runs table, started most
+// recently first, with the counters of the running ones as of their last
+// progress. A full page carries next_before, the before of the next page.
+// GET /imports
+// @Summary		List the imports
+// @Description	Lists the imports started with POST /imports or run by the processor, whichever instance runs them. The counters of a running import are written by the processor at each progress interval.
+// @Tags			imports
+// @Produce		json
+// @Security		AdminToken
+// @Param			status	query		string	false	"Import status"	Enums(running, succeeded, failed, cancelled)
+// @Param			before	query		string	false	"Only imports started before this import ID"
+// @Param			limit	query		integer	false	"Page size"	minimum(1)	maximum(1000)	default(100)
+// @Success		200		{object}	object{imports=[]service.ImportJob,next_before=string,links=handler.Links,meta=handler.Meta}
+// @Header			200		{string}	Link	"The next page"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid status, before or limit"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/imports [get]
+func (h *ImportHandler) ListImports(c *gin.Context) {
+	filter := service.ImportFilter{
+		Status: c.Query("status"),
+		Before: c.Query("before"),
+		Limit:  defaultImportLimit,
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxImportLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxImportLimit),
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	jobs, err := h.imports.List(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	links := selfLinks(c)
+	body := gin.H{"imports": jobs}
+	if len(jobs) == filter.Limit {
+		next := jobs[len(jobs)-1].ID
+		body["next_before"] = next
+		links.Next = pageLink(c, map[string]string{"before": next})
+	}
+	body["links"] = links
+	body["meta"] = Meta{Returned: len(jobs)}
+	setLinks(c, links)
+	c.JSON(http.StatusOK, body)
+}
+
+// GetImport returns an import with its counters
+// GET /imports/:run_id
+// @Summary		Get an import
+// @Tags			imports
+// @Produce		json
+// @Security		AdminToken
+// @Param			run_id	path		string	true	"Import run ID"
+// @Success		200		{object}	service.ImportJob
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown import"
+// @Router			/imports/{run_id} [get]
+func (h *ImportHandler) GetImport(c *gin.Context) {
+	job, err := h.imports.Get(c.Request.Context(), c.Param("run_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelImport requests the cancellation of a running import. The answer
+// is the import with cancel_requested_at set: it is recorded as cancelled
+// once the processor running it stops, at its next progress check.
+// POST /imports/:run_id/cancel
+// @Summary		Cancel an import
+// @Tags			imports
+// @Produce		json
+// @Security		AdminToken
+// @Param			run_id	path		string	true	"Import run ID"
+// @Success		202		{object}	service.ImportJob
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown import"
+// @Failure		409		{object}	handler.ErrorResponse	"The import is not running"
+// @Router			/imports/{run_id}/cancel [post]
+func (h *ImportHandler) CancelImport(c *gin.Context) {
+	job, err := h.imports.Cancel(c.Request.Context(), c.Param("run_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// upload streams the file part of a multipart body to the import, without
+// buffering it; a url field before it imports from the URL instead
+func (h *ImportHandler) upload(c *gin.Context) (*service.ImportJob, error) {
+	ctx := c.Request.Context()
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, apperrors.New("invalid multipart body", apperrors.ErrValidation)
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, service.ErrImportSourceRequired
+		}
+		if err != nil {
+			return nil, apperrors.New("invalid multipart body", apperrors.ErrValidation)
+		}
+		switch part.FormName() {
+		case "file":
+			return h.imports.Upload(ctx, part.FileName(), part)
+		case "url":
+			value, err := io.ReadAll(io.LimitReader(part, 4<<10))
+			if err != nil {
+				return nil, apperrors.New("invalid multipart body", apperrors.ErrValidation)
+			}
+			return h.imports.Fetch(ctx, service.ImportRequest{URL: strings.TrimSpace(string(value))})
+		}
+	}
+}
+
+// GetImportErrors streams the rows a processor run rejected or failed to
+// write, with their row number, raw line and error, as a downloadable JSON
+// or CSV document
+// GET /imports/:run_id/errors?format=json|csv
+// @Summary		Get the rejected rows of an import
+// @Tags			imports
+// @Produce		json
+// @Produce		text/csv
+// @Param			run_id	path		string	true	"Processor run ID"
+// @Param			format	query		string	false	"Document format"	Enums(json, csv)	default(json)
+// @Success		200		{array}		service.ImportError
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid format"
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown run"
+// @Router			/imports/{run_id}/errors [get]
+func (h *ImportHandler) GetImportErrors(c *gin.Context) {
+	runID := c.Param("run_id")
+
+	format, err := service.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("run-%s-errors.%s", runID, format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	ctx := c.Request.Context()
+	if err := h.errors.Write(ctx, runID, format, c.Writer); err != nil {
+		// once bytes are on the wire the status can no longer change
+		if c.Writer.Written() {
+			c.Error(err)
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		respondError(c, err)
+		return
+	}
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockRuns struct {
+	repository.RunRepository
+}
+
+func (mockRuns) Get(ctx context.Context, id string) (*models.Run, error) {
+	if id != "run-1" {
+		return nil, nil
+	}
+	return &models.Run{ID: id, Status: models.RunSucceeded}, nil
+}
+
+type mockDeadLetters struct {
+	repository.DeadLetterRepository
+}
+
+func (mockDeadLetters) List(ctx context.Context, runID string, afterID uint64, limit int) ([]models.DeadLetter, error) {
+	if afterID > 0 {
+		return nil, nil
+	}
+	return []models.DeadLetter{{ID: 1, RunID: runID, RowNumber: 4, RawLine: "abc,drug,Aspirina,{}", Error: "invalid user_id"}}, nil
+}
+
+func TestImportHandler_GetImportErrors(t *testing.T) {
+	h := NewImportHandler(service.NewImportErrors(mockRuns{}, mockDeadLetters{}), nil)
+
+	tests := []struct {
+		runID, query string
+		wantStatus   int
+		wantBody     string
+	}{
+		{runID: "run-1", query: "format=csv", wantStatus: http.StatusOK, wantBody: "row_number,raw_line,error\n4,\"abc,drug,Aspirina,{}\",invalid user_id\n"},
+		{runID: "run-1", wantStatus: http.StatusOK, wantBody: `"errors":[{"row_number":4,`},
+		{runID: "missing", wantStatus: http.StatusNotFound, wantBody: "run not found"},
+		{runID: "run-1", query: "format=xml", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.runID+"?"+tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/imports/"+tt.runID+"/errors?"+tt.query, nil)
+			c.Params = gin.Params{{Key: "run_id", Value: tt.runID}}
+
+			h.GetImportErrors(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(w.Header().Get("Content-Disposition"), "run-run-1-errors") {
+				t.Errorf("Content-Disposition = %q", w.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
+
+// mockImports starts imports without running them
+type mockImports struct {
+	name, content, url string
+}
+
+func (m *mockImports) Start(ctx context.Context, name string, body io.Reader) (*models.Run, error) {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	m.name, m.content = name, string(b)
+	return &models.Run{ID: "import-1", Status: models.RunRunning, Source: "upload:" + name}, nil
+}
+
+func (m *mockImports) StartS3(ctx context.Context, rawURL string) (*models.Run, error) {
+	if !strings.HasPrefix(rawURL, "s3://") {
+		return nil, apperrors.New("invalid S3 URL", apperrors.ErrValidation)
+	}
+	m.url = rawURL
+	return &models.Run{ID: "import-2", Status: models.RunRunning, Source: rawURL}, nil
+}
+
+func (m *mockImports) Cancel(runID string) bool { return false }
+
+func TestImportHandler_StartImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := &mockImports{}
+	h := NewImportHandler(nil, service.NewImports(mockRuns{}, m))
+	r := gin.New()
+	r.POST("/imports", h.StartImport)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("note", "ignored")
+	fw, _ := mw.CreateFormFile("file", "daily.csv")
+	fw.Write([]byte("user_id,segmentation_type,segmentation_name,data\n"))
+	mw.Close()
+	req := httptest.NewRequest("POST", "/imports", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var job service.ImportJob
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if w.Code != http.StatusAccepted || job.ID != "import-1" || job.Source != "upload:daily.csv" {
+		t.Fatalf("expected 202 with the run, got %d: %s", w.Code, w.Body.String())
+	}
+	if loc := w.Header().Get("Location"); loc != "/imports/import-1" {
+		t.Errorf("Location = %q", loc)
+	}
+	if m.name != "daily.csv" || !strings.HasPrefix(m.content, "user_id,") {
+		t.Errorf("unexpected upload %q: %q", m.name, m.content)
+	}
+
+	w = doWebhookRequest(r, "POST", "/imports", `{"url": "s3://drops/daily.csv"}`)
+	if w.Code != http.StatusAccepted || m.url != "s3://drops/daily.csv" {
+		t.Errorf("expected 202 for an S3 URL, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, body := range []string{`{}`, `{"url": "https://drops/daily.csv"}`, `{"url": 1}`} {
+		if w := doWebhookRequest(r, "POST", "/imports", body); w.Code != http.StatusBadRequest {
+			t.Errorf("POST /imports %s = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+// mockImportRuns knows a running and a finished import
+type mockImportRuns struct {
+	repository.RunRepository
+	cancelled bool
+}
+
+func (m *mockImportRuns) Get(ctx context.Context, id string) (*models.Run, error) {
+	switch id {
+	case "import-1":
+		run := &models.Run{ID: id, Kind: models.RunImport, Status: models.RunRunning, RowsRead: 40}
+		if m.cancelled {
+			run.CancelRequestedAt = 1760000000
+		}
+		return run, nil
+	case "import-2":
+		return &models.Run{ID: id, Kind: models.RunImport, Status: models.RunSucceeded}, nil
+	}
+	return nil, nil
+}
+
+func (m *mockImportRuns) List(ctx context.Context, filter repository.RunFilter) ([]models.Run, error) {
+	runs := []models.Run{{ID: "import-1", Kind: models.RunImport}, {ID: "import-2", Kind: models.RunImport}}
+	return runs[:min(filter.Limit, len(runs))], nil
+}
+
+func (m *mockImportRuns) RequestCancel(ctx context.Context, id string, at int64) (bool, error) {
+	m.cancelled = true
+	return true, nil
+}
+
+func TestImportHandler_ListGetCancel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewImportHandler(nil, service.NewImports(&mockImportRuns{}, nil))
+	r := gin.New()
+	r.GET("/imports", h.ListImports)
+	r.GET("/imports/:run_id", h.GetImport)
+	r.POST("/imports/:run_id/cancel", h.CancelImport)
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+		wantBody     string
+	}{
+		{"GET", "/imports", http.StatusOK, `"imports":[{"id":"import-1"`},
+		{"GET", "/imports?limit=2", http.StatusOK, `"links":{"self":"/imports?limit=2","next":"/imports?before=import-2\u0026limit=2"},"meta":{"returned":2},"next_before":"import-2"`},
+		{"GET", "/imports?limit=0", http.StatusBadRequest, "limit must be between"},
+		{"GET", "/imports?status=done", http.StatusBadRequest, "status must be"},
+		{"GET", "/imports/import-1", http.StatusOK, `"rows_read":40`},
+		{"GET", "/imports/missing", http.StatusNotFound, "run not found"},
+		{"POST", "/imports/import-2/cancel", http.StatusConflict, "not running"},
+		{"POST", "/imports/import-1/cancel", http.StatusAccepted, `"cancel_requested_at":1760000000`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("expected %d with %s, got %d: %s", tt.wantStatus, tt.wantBody, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevelHandler reads and changes the log level of the running process
+type LogLevelHandler struct {
+	level  zap.AtomicLevel
+	logger *zap.Logger
+}
+
+// NewLogLevelHandler creates a handler over level; changes are recorded in
+// logger
+func NewLogLevelHandler(level zap.AtomicLevel, logger *zap.Logger) *LogLevelHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &LogLevelHandler{level: level, logger: logger}
+}
+
+// LogLevelRequest is the payload of SetLogLevel
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// GetLogLevel returns the current log level
+// GET /admin/log-level
+// @Summary		Get the log level
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	handler.LogLevelRequest
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/log-level [get]
+func (h *LogLevelHandler) GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"level": h.level.String(),
+	})
+}
+
+// SetLogLevel changes the log level without restarting
+// PUT /admin/log-level
+// @Summary		Change the log level
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			request	body		handler.LogLevelRequest	true	"New level"
+// @Success		200		{object}	handler.LogLevelRequest
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid body or level"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/log-level [put]
+func (h *LogLevelHandler) SetLogLevel(c *gin.Context) {
+	var req LogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	level, err := zapcore.ParseLevel(strings.ToLower(strings.TrimSpace(req.Level)))
+	if err != nil || level > zapcore.ErrorLevel {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "level must be one of debug, info, warn, error",
+		})
+		return
+	}
+
+	previous := h.level.Level()
+	record := func() {
+		h.logger.Warn("log_level_changed",
+			zap.Stringer("from", previous),
+			zap.Stringer("to", level),
+			zap.String("client_ip", c.ClientIP()),
+		)
+	}
+
+	// Record the change under whichever of the two levels is more verbose
+	if level > previous {
+		record()
+		h.level.SetLevel(level)
+	} else {
+		h.level.SetLevel(level)
+		record()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"level": level.String(),
+	})
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogLevelHandler_GetLogLevel(t *testing.T) {
+	h := NewLogLevelHandler(zap.NewAtomicLevelAt(zapcore.WarnLevel), nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/log-level", nil)
+
+	h.GetLogLevel(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp LogLevelRequest
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Level != "warn" {
+		t.Errorf("level = %q, want warn", resp.Level)
+	}
+}
+
+func TestLogLevelHandler_SetLogLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+		level    zapcore.Level
+	}{
+		{name: "lower to debug", body: `{"level": "debug"}`, expected: http.StatusOK, level: zapcore.DebugLevel},
+		{name: "raise to error", body: `{"level": "ERROR"}`, expected: http.StatusOK, level: zapcore.ErrorLevel},
+		{name: "unknown level", body: `{"level": "verbose"}`, expected: http.StatusBadRequest, level: zapcore.InfoLevel},
+		{name: "fatal is not allowed", body: `{"level": "fatal"}`, expected: http.StatusBadRequest, level: zapcore.InfoLevel},
+		{name: "invalid body", body: `level=debug`, expected: http.StatusBadRequest, level: zapcore.InfoLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+			core, logs := observer.New(level)
+			h := NewLogLevelHandler(level, zap.New(core))
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			h.SetLogLevel(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if level.Level() != tt.level {
+				t.Errorf("level = %s, want %s", level.Level(), tt.level)
+			}
+
+			want := 0
+			if tt.expected == http.StatusOK {
+				want = 1
+			}
+			if changed := logs.FilterMessage("log_level_changed").Len(); changed != want {
+				t.Errorf("log_level_changed entries = %d, want %d", changed, want)
+			}
+		})
+	}
+}
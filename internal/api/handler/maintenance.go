@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+
+	"segmentation-api/internal/api/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// MaintenanceHandler reads and changes the maintenance mode of the API
+type MaintenanceHandler struct {
+	maintenance *middleware.Maintenance
+	logger      *zap.Logger
+}
+
+// NewMaintenanceHandler creates a handler over maintenance; changes are
+// recorded in logger
+func NewMaintenanceHandler(maintenance *middleware.Maintenance, logger *zap.Logger) *MaintenanceHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &MaintenanceHandler{maintenance: maintenance, logger: logger}
+}
+
+// MaintenanceRequest is the payload of SetMaintenance
+type MaintenanceRequest struct {
+	Mode    string `json:"mode"`
+	Message string `json:"message"`
+}
+
+// GetMaintenance returns the current maintenance mode
+// GET /admin/maintenance
+// @Summary		Get the maintenance mode
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	middleware.MaintenanceState
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/maintenance [get]
+func (h *MaintenanceHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, h.maintenance.State())
+}
+
+// SetMaintenance changes the maintenance mode without restarting: off,
+// read_only (writes answer 503) or write_only (reads answer 503)
+// PUT /admin/maintenance
+// @Summary		Change the maintenance mode
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			request	body		handler.MaintenanceRequest	true	"New mode"
+// @Success		200		{object}	middleware.MaintenanceState
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid body or mode"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/maintenance [put]
+func (h *MaintenanceHandler) SetMaintenance(c *gin.Context) {
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	mode, err := middleware.ParseMaintenanceMode(req.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	previous := h.maintenance.State().Mode
+	state := h.maintenance.Set(mode, req.Message)
+	h.logger.Warn("maintenance_mode_changed",
+		zap.String("from", string(previous)),
+		zap.String("to", string(mode)),
+		zap.String("message", state.Message),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, state)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenanceHandler_SetMaintenance(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+		mode     middleware.MaintenanceMode
+	}{
+		{name: "read only", body: `{"mode": "read_only", "message": "migrating"}`, expected: http.StatusOK, mode: middleware.MaintenanceReadOnly},
+		{name: "write only", body: `{"mode": "WRITE_ONLY"}`, expected: http.StatusOK, mode: middleware.MaintenanceWriteOnly},
+		{name: "unknown mode", body: `{"mode": "readonly"}`, expected: http.StatusBadRequest, mode: middleware.MaintenanceOff},
+		{name: "invalid body", body: `mode=off`, expected: http.StatusBadRequest, mode: middleware.MaintenanceOff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := middleware.NewMaintenance(middleware.MaintenanceOff, "")
+			h := NewMaintenanceHandler(m, nil)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("PUT", "/admin/maintenance", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			h.SetMaintenance(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if got := m.State().Mode; got != tt.mode {
+				t.Errorf("mode = %s, want %s", got, tt.mode)
+			}
+		})
+	}
+}
+
+func TestMaintenanceHandler_GetMaintenance(t *testing.T) {
+	h := NewMaintenanceHandler(middleware.NewMaintenance(middleware.MaintenanceReadOnly, "backfill"), nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/maintenance", nil)
+	h.GetMaintenance(c)
+
+	var state middleware.MaintenanceState
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || state.Mode != middleware.MaintenanceReadOnly || state.Message != "backfill" {
+		t.Errorf("GetMaintenance() = %d %+v", w.Code, state)
+	}
+}
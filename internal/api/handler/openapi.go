@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swaggo/swag"
+)
+
+// OpenAPI serves the spec registered by the docs package, the document the
+// Swagger UI renders, for client generators and contract tests
+// GET /openapi.json
+func OpenAPI(c *gin.Context) {
+	doc, err := swag.ReadDoc()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(doc))
+}
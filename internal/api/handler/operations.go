@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ConfigFunc returns the configuration in effect, reloads included
+type ConfigFunc func() *config.Config
+
+// AnalyzeFunc recomputes the index statistics of the database tables
+type AnalyzeFunc func(ctx context.Context) ([]repository.TableStats, error)
+
+// OperationsHandler serves the routine actions of on-call engineers that
+// would otherwise need a restart or database access
+type OperationsHandler struct {
+	svc      *service.SegmentationService
+	registry *service.TypeRegistry
+	config   ConfigFunc
+	analyze  AnalyzeFunc
+	logger   *zap.Logger
+}
+
+// NewOperationsHandler creates a handler over svc; registry, cfg and
+// analyze may be nil when the API runs without them. Actions are recorded
+// in logger.
+func NewOperationsHandler(
+	svc *service.SegmentationService,
+	registry *service.TypeRegistry,
+	cfg ConfigFunc,
+	analyze AnalyzeFunc,
+	logger *zap.Logger,
+) *OperationsHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &OperationsHandler{svc: svc, registry: registry, config: cfg, analyze: analyze, logger: logger}
+}
+
+// FlushCaches drops the cached user responses and reloads the segmentation
+// type registry, so changes made directly in the database show up at once
+// POST /admin/cache/flush
+// @Summary		Flush the caches
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	object{users=integer,types_reloaded=boolean}
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/cache/flush [post]
+func (h *OperationsHandler) FlushCaches(c *gin.Context) {
+	body := gin.H{"users": h.svc.FlushCache()}
+	if h.registry != nil {
+		if err := h.registry.Refresh(c.Request.Context()); err != nil {
+			respondError(c, err)
+			return
+		}
+		body["types_reloaded"] = true
+	}
+
+	h.logger.Warn("caches_flushed",
+		zap.Any("users", body["users"]),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	c.JSON(http.StatusOK, body)
+}
+
+// GetConfig returns the configuration in effect as YAML, in the format of
+// "config dump", with secrets masked
+// GET /admin/config
+// @Summary		Get the configuration in effect
+// @Tags			admin
+// @Produce		application/yaml
+// @Security		AdminToken
+// @Success		200	{string}	string	"config dump, secrets masked"
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/config [get]
+func (h *OperationsHandler) GetConfig(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := config.Dump(&buf, h.config()); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", buf.Bytes())
+}
+
+// RecomputeStats recomputes the index statistics MySQL plans the API's
+// queries with, usually after a large import
+// POST /admin/stats/recompute
+// @Summary		Recompute index statistics
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	object{tables=[]repository.TableStats}
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/stats/recompute [post]
+func (h *OperationsHandler) RecomputeStats(c *gin.Context) {
+	tables, err := h.analyze(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.logger.Warn("table_stats_recomputed",
+		zap.Int("tables", len(tables)),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	c.JSON(http.StatusOK, gin.H{
+		"tables": tables,
+	})
+}
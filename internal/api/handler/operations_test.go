@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOperationsHandler_FlushCaches(t *testing.T) {
+	reads := 0
+	repo := &MockRepository{findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+		reads++
+		return nil, nil
+	}}
+	svc := service.NewSegmentationService(repo, service.WithCache(service.NewUserCache(10, time.Minute)))
+	svc.GetByUserID(context.Background(), 1)
+	svc.GetByUserID(context.Background(), 2)
+
+	h := NewOperationsHandler(svc, nil, nil, nil, nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/admin/cache/flush", nil)
+
+	h.FlushCaches(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Users int `json:"users"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Users != 2 {
+		t.Errorf("users = %d, want 2", body.Users)
+	}
+	svc.GetByUserID(context.Background(), 1)
+	if reads != 3 {
+		t.Errorf("reads = %d, want the flushed user read again", reads)
+	}
+}
+
+func TestOperationsHandler_FlushCachesReloadsTypes(t *testing.T) {
+	repo := &MockTypeRepository{types: map[string]models.SegmentationType{
+		"exam": {Name: "exam", Active: true},
+	}}
+	h := NewOperationsHandler(service.NewSegmentationService(&MockRepository{}), service.NewTypeRegistry(repo, 0), nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/admin/cache/flush", nil)
+
+	h.FlushCaches(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"types_reloaded":true`) {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+}
+
+func TestOperationsHandler_GetConfig(t *testing.T) {
+	cfg := &config.Config{DB: config.DB{Host: "db", Password: "s3cret"}}
+	h := NewOperationsHandler(service.NewSegmentationService(&MockRepository{}), nil, func() *config.Config { return cfg }, nil, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/config", nil)
+
+	h.GetConfig(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "host: db") {
+		t.Errorf("config should be dumped as YAML, got %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "s3cret") {
+		t.Error("secrets should be masked")
+	}
+}
+
+func TestOperationsHandler_RecomputeStats(t *testing.T) {
+	analyze := func(ctx context.Context) ([]repository.TableStats, error) {
+		return []repository.TableStats{{Table: "segmentation.segmentations", MsgType: "status", MsgText: "OK"}}, nil
+	}
+	h := NewOperationsHandler(service.NewSegmentationService(&MockRepository{}), nil, nil, analyze, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/admin/stats/recompute", nil)
+
+	h.RecomputeStats(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"table":"segmentation.segmentations"`) {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Links navigate a paginated list: self is the request, next and prev the
+// adjacent pages when there are any. They are relative to the API root and
+// keep the query of the request, with only its position changed, so a
+// client follows next until it is missing. Feeds paged with a cursor only
+// go forward and have no prev.
+type Links struct {
+	Self string `json:"self" example:"/segmentations/search?q=dipirona&limit=20&offset=20"`
+	Next string `json:"next,omitempty" example:"/segmentations/search?limit=20&offset=40&q=dipirona"`
+	Prev string `json:"prev,omitempty" example:"/segmentations/search?limit=20&offset=0&q=dipirona"`
+}
+
+// Meta counts a page: Returned is the number of items in it and Total, set
+// by the lists that know it, the number of items of every page
+type Meta struct {
+	Total    *int64 `json:"total,omitempty"`
+	Returned int    `json:"returned"`
+}
+
+// selfLinks returns the Links of the request, without adjacent pages
+func selfLinks(c *gin.Context) Links {
+	return Links{Self: c.Request.URL.RequestURI()}
+}
+
+// pageLink is the URL of the request with the parameters of set replaced,
+// or removed when empty: the position of another page
+func pageLink(c *gin.Context, set map[string]string) string {
+	u := *c.Request.URL
+	q := u.Query()
+	for k, v := range set {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// setLinks answers the links of a page also in the Link header (RFC 8288),
+// for the formats whose body cannot carry them such as CSV
+func setLinks(c *gin.Context, links Links) {
+	var header []string
+	for _, l := range []struct{ rel, target string }{{"next", links.Next}, {"prev", links.Prev}} {
+		if l.target != "" {
+			header = append(header, "<"+l.target+`>; rel="`+l.rel+`"`)
+		}
+	}
+	if len(header) > 0 {
+		c.Header("Link", strings.Join(header, ", "))
+	}
+}
+
+// offsetLinks are the Links of a page of limit items from offset, of a list
+// of total items whose last reachable position is window
+func offsetLinks(c *gin.Context, offset, limit int, total int64, window int) Links {
+	links := selfLinks(c)
+	if next := offset + limit; int64(next) < total && next < window {
+		links.Next = pageLink(c, map[string]string{
+			"offset": strconv.Itoa(next),
+			"limit":  strconv.Itoa(min(limit, window-next)),
+		})
+	}
+	if offset > 0 {
+		links.Prev = pageLink(c, map[string]string{
+			"offset": strconv.Itoa(max(0, offset-limit)),
+			"limit":  strconv.Itoa(min(limit, offset)),
+		})
+	}
+	return links
+}
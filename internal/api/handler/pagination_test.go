@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOffsetLinks(t *testing.T) {
+	tests := []struct {
+		name                  string
+		query                 string
+		offset, limit, window int
+		total                 int64
+		wantNext, wantPrev    string
+	}{
+		{
+			name: "first page", query: "q=a&limit=20", offset: 0, limit: 20, total: 50, window: 100,
+			wantNext: "/s?limit=20&offset=20&q=a",
+		},
+		{
+			name: "middle page", query: "offset=30&limit=20", offset: 30, limit: 20, total: 100, window: 100,
+			wantNext: "/s?limit=20&offset=50", wantPrev: "/s?limit=20&offset=10",
+		},
+		{
+			name: "prev clamped at the start", query: "offset=5&limit=20", offset: 5, limit: 20, total: 25, window: 100,
+			wantPrev: "/s?limit=5&offset=0",
+		},
+		{
+			name: "next clamped to the window", query: "offset=80&limit=15", offset: 80, limit: 15, total: 1000, window: 100,
+			wantNext: "/s?limit=5&offset=95", wantPrev: "/s?limit=15&offset=65",
+		},
+		{
+			name: "window reached", query: "offset=90&limit=10", offset: 90, limit: 10, total: 1000, window: 100,
+			wantPrev: "/s?limit=10&offset=80",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/s?"+tt.query, nil)
+
+			links := offsetLinks(c, tt.offset, tt.limit, tt.total, tt.window)
+			if links.Self != "/s?"+tt.query || links.Next != tt.wantNext || links.Prev != tt.wantPrev {
+				t.Errorf("offsetLinks() = %+v, want next %q and prev %q", links, tt.wantNext, tt.wantPrev)
+			}
+		})
+	}
+}
+
+// fullChanges returns a whole page of changes, so there is a next one
+type fullChanges struct{}
+
+func (fullChanges) Changes(ctx context.Context, after repository.ChangeCursor, filter repository.Filter, limit int) ([]models.Segmentation, error) {
+	out := make([]models.Segmentation, limit)
+	for i := range out {
+		out[i] = models.Segmentation{ID: uint64(i + 1), UserID: 1, SegmentationType: "drug", SegmentationName: "Dipirona", UpdatedAt: 1767225600}
+	}
+	return out, nil
+}
+
+func TestChangesHandler_Links(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/segmentations/changes?since=1767225600&limit=2", nil)
+	c.Request.Header.Set("Accept", "text/csv")
+
+	NewChangesHandler(service.NewChangeFeed(fullChanges{})).ListChanges(c)
+
+	next := "/segmentations/changes?cursor=" + service.EncodeChangeCursor(repository.ChangeCursor{UpdatedAt: 1767225600, ID: 2}) + "&limit=2"
+	// the cursor replaces since, and the CSV body has the link in the header
+	if link := w.Header().Get("Link"); link != "<"+next+`>; rel="next"` {
+		t.Errorf("Link = %q, want the next page %s", link, next)
+	}
+}
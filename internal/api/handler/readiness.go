@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness tells load balancers whether to route traffic to the server.
+// It turns false for good once the server starts draining before a
+// shutdown, while /health keeps answering so the process is not killed.
+type Readiness struct {
+	draining atomic.Bool
+}
+
+// NewReadiness creates a ready state
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// Drain marks the server as going away
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// Draining reports whether Drain was called
+func (r *Readiness) Draining() bool {
+	return r.draining.Load()
+}
+
+// Ready answers 200 until the server drains and 503 afterwards
+// GET /ready
+// @Summary		Readiness probe
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	map[string]string
+// @Failure		503	{object}	map[string]string	"Draining before a shutdown"
+// @Router			/ready [get]
+func (r *Readiness) Ready(c *gin.Context) {
+	if r.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "draining",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+	})
+}
+
+// CloseWhenDraining asks clients to close keep-alive connections once the
+// server drains, so they reconnect to another instance instead of reusing
+// a connection that is about to be closed
+func (r *Readiness) CloseWhenDraining(c *gin.Context) {
+	if r.Draining() {
+		c.Header("Connection", "close")
+	}
+	c.Next()
+}
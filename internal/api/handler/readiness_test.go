@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewReadiness()
+	router := gin.New()
+	router.Use(r.CloseWhenDraining)
+	router.GET("/ready", r.Ready)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusOK || w.Header().Get("Connection") != "" {
+		t.Errorf("before draining: status %d, Connection %q", w.Code, w.Header().Get("Connection"))
+	}
+
+	r.Drain()
+	if w := get(); w.Code != http.StatusServiceUnavailable || w.Header().Get("Connection") != "close" {
+		t.Errorf("while draining: status %d, Connection %q", w.Code, w.Header().Get("Connection"))
+	}
+}
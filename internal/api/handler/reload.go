@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReloadFunc resolves the configuration again and applies the settings
+// that can change at runtime, returning the keys of those that changed
+type ReloadFunc func() ([]string, error)
+
+// ReloadHandler reloads the configuration without restarting, as SIGHUP
+// does
+type ReloadHandler struct {
+	reload ReloadFunc
+}
+
+// NewReloadHandler creates a handler calling reload
+func NewReloadHandler(reload ReloadFunc) *ReloadHandler {
+	return &ReloadHandler{reload: reload}
+}
+
+// Reload applies the configuration and lists the settings that changed;
+// an invalid configuration answers 422 and the running one is kept
+// POST /admin/reload
+// @Summary		Reload the configuration
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	object{changed=[]string}
+// @Failure		401	{object}	handler.ErrorResponse
+// @Failure		422	{object}	handler.ErrorResponse	"Invalid configuration, the running one is kept"
+// @Router			/admin/reload [post]
+func (h *ReloadHandler) Reload(c *gin.Context) {
+	changed, err := h.reload()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changed": changed,
+	})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"segmentation-api/internal/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReloadHandler_Reload(t *testing.T) {
+	tests := []struct {
+		name     string
+		changed  []string
+		err      error
+		expected int
+	}{
+		{name: "reloaded", changed: []string{"log.level"}, expected: http.StatusOK},
+		{name: "nothing changed", changed: []string{}, expected: http.StatusOK},
+		{name: "invalid configuration", err: apperrors.New("configuration not reloaded", apperrors.ErrUnprocessable), expected: http.StatusUnprocessableEntity},
+		{name: "unexpected error", err: errors.New("boom"), expected: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewReloadHandler(func() ([]string, error) { return tt.changed, tt.err })
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/admin/reload", nil)
+
+			h.Reload(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if tt.err != nil {
+				return
+			}
+			var body struct {
+				Changed []string `json:"changed"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Changed) != len(tt.changed) {
+				t.Errorf("changed = %v, want %v", body.Changed, tt.changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"segmentation-api/internal/api/render"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchHandler serves the search of the segmentations mirrored into the
+// search index
+type SearchHandler struct {
+	searcher *service.Searcher
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(searcher *service.Searcher) *SearchHandler {
+	return &SearchHandler{searcher: searcher}
+}
+
+// SearchSegmentations returns the segmentations whose name or data text
+// resemble q, tolerating typos, accents and case, best first; each filter
+// (field:op:value) restricts the hits, and data.<key> filters compare the
+// value of the key as text. Without q the hits come most recently written
+// first. The index follows the writes with the delay of the outbox relay.
+// GET /segmentations/search
+// @Summary		Search segmentations
+// @Tags			segmentations
+// @Produce		json
+// @Produce		text/csv
+// @Produce		application/xml
+// @Param			q		query		string		false	"Text matched against the name and the data values"
+// @Param			filter	query		[]string	false	"field:op:value"	collectionFormat(multi)
+// @Param			limit	query		integer		false	"Page size"	minimum(1)	maximum(100)	default(20)
+// @Param			offset	query		integer		false	"Hits to skip; offset + limit must not exceed 10000"	minimum(0)	default(0)
+// @Success		200		{object}	service.SearchPage{links=handler.Links,meta=handler.Meta}
+// @Header			200		{string}	Link	"The next and prev pages"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid q, filter, limit or offset"
+// @Failure		503		{object}	handler.ErrorResponse	"Search index unavailable or maintenance mode"
+// @Failure		504		{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/segmentations/search [get]
+func (h *SearchHandler) SearchSegmentations(c *gin.Context) {
+	limit := defaultSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	offset := 0
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "offset must be a non-negative integer",
+			})
+			return
+		}
+		offset = n
+	}
+
+	filter, err := service.ParseFilter(c.QueryArray("filter"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	page, err := h.searcher.Search(c.Request.Context(), c.Query("q"), filter, limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	links := offsetLinks(c, offset, limit, page.Total, service.MaxSearchWindow)
+	setLinks(c, links)
+	render.Render(c, http.StatusOK, searchResponse{
+		SearchPage: page,
+		Links:      links,
+		Meta:       Meta{Total: &page.Total, Returned: len(page.Hits)},
+	})
+}
+
+// searchResponse is a SearchPage with its pagination
+type searchResponse struct {
+	*service.SearchPage
+	Links Links `json:"links"`
+	Meta  Meta  `json:"meta"`
+}
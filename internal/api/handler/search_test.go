@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+type mockSearch struct {
+	query repository.SearchQuery
+	err   error
+}
+
+func (m *mockSearch) Search(ctx context.Context, q repository.SearchQuery) (*repository.SearchResult, error) {
+	m.query = q
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &repository.SearchResult{Total: 31, Hits: []repository.SearchHit{{
+		Segmentation: models.Segmentation{UserID: 1, SegmentationType: "drug", SegmentationName: "Dipirona",
+			Data: datatypes.JSON(`{"quantity":"200"}`), UpdatedAt: 1767225600},
+		Score: 4.2,
+	}}}, nil
+}
+
+func TestSearchHandler_SearchSegmentations(t *testing.T) {
+	store := &mockSearch{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/segmentations/search?q=dipirna&filter=data.quantity:eq:200&offset=20", nil)
+
+	NewSearchHandler(service.NewSearcher(store)).SearchSegmentations(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if q := store.query; q.Text != "dipirna" || q.Limit != defaultSearchLimit || q.Offset != 20 ||
+		len(q.Filter) != 1 || q.Filter[0].Field != "data.quantity" {
+		t.Errorf("unexpected query: %+v", q)
+	}
+	var page service.SearchPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 31 || len(page.Hits) != 1 || page.Hits[0].Name != "Dipirona" || page.Hits[0].Score != 4.2 {
+		t.Errorf("unexpected page: %s", w.Body.String())
+	}
+
+	var nav struct {
+		Links Links `json:"links"`
+		Meta  Meta  `json:"meta"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &nav); err != nil {
+		t.Fatal(err)
+	}
+	// the last page: 31 hits, from 20
+	prev := "/segmentations/search?filter=data.quantity%3Aeq%3A200&limit=20&offset=0&q=dipirna"
+	if nav.Links.Next != "" || nav.Links.Prev != prev || nav.Meta.Returned != 1 || nav.Meta.Total == nil || *nav.Meta.Total != 31 {
+		t.Errorf("unexpected pagination %+v", nav)
+	}
+	if link := w.Header().Get("Link"); link != "<"+prev+`>; rel="prev"` {
+		t.Errorf("Link = %q", link)
+	}
+}
+
+func TestSearchHandler_InvalidQuery(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=500", "offset=-1", "offset=9990&limit=20", "filter=name_key:eq:x"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/segmentations/search?"+query, nil)
+
+		NewSearchHandler(service.NewSearcher(&mockSearch{})).SearchSegmentations(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestSearchHandler_Unavailable(t *testing.T) {
+	store := &mockSearch{err: apperrors.New("search index unavailable", apperrors.ErrUnavailable)}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/segmentations/search?q=x", nil)
+
+	NewSearchHandler(service.NewSearcher(store)).SearchSegmentations(c)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+}
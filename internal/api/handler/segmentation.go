@@ -1,57 +1,516 @@
 package handler
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"segmentation-api/internal/api/render"
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/repository"
 	"segmentation-api/internal/service"
+	"segmentation-api/internal/writequeue"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SegmentationHandler handles segmentation-related HTTP requests
 type SegmentationHandler struct {
-	service *service.SegmentationService
+	service         *service.SegmentationService
+	streamThreshold int
+	queue           *writequeue.Queue
+	strictJSON      bool
+}
+
+// SegmentationHandlerOption customizes a SegmentationHandler
+type SegmentationHandlerOption func(*SegmentationHandler)
+
+// WithStreamThreshold streams GET responses of users with more than rows
+// segmentations instead of encoding them in one buffer; 0 never streams
+func WithStreamThreshold(rows int) SegmentationHandlerOption {
+	return func(h *SegmentationHandler) {
+		h.streamThreshold = rows
+	}
+}
+
+// WithWriteQueue accepts POST writes into q and answers 202 with the ID
+// to follow them at GET /writes/:id, instead of writing them to MySQL
+// before answering
+func WithWriteQueue(q *writequeue.Queue) SegmentationHandlerOption {
+	return func(h *SegmentationHandler) {
+		h.queue = q
+	}
+}
+
+// WithStrictJSON refuses write bodies with members the request does not
+// declare with 400 instead of ignoring them
+func WithStrictJSON() SegmentationHandlerOption {
+	return func(h *SegmentationHandler) {
+		h.strictJSON = true
+	}
 }
 
 // NewSegmentationHandler creates a new segmentation handler
-func NewSegmentationHandler(s *service.SegmentationService) *SegmentationHandler {
-	return &SegmentationHandler{service: s}
+func NewSegmentationHandler(s *service.SegmentationService, opts ...SegmentationHandlerOption) *SegmentationHandler {
+	h := &SegmentationHandler{service: s}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
-// GetUserSegmentations retrieves all segmentations for a user
+// GetUserSegmentations retrieves all segmentations for a user; ?fields=
+// trims each item to the listed members (name, data, data.<key>) and
+// ?include=metadata adds created_at and updated_at. A user without
+// segmentations gets an empty response, or 404 when the service has a user
+// directory that does not know it.
 // GET /users/:user_id/segmentations
+// @Summary		Get user segmentations
+// @Description	Returns the segmentations of a user grouped by type ("drugs", "specialties", ...). Accept-Language adds localized group labels. Accept text/csv answers a row per segmentation, application/xml the same document as XML.
+// @Tags			segmentations
+// @Produce		json
+// @Produce		text/csv
+// @Produce		application/xml
+// @Param			user_id			path		integer	true	"User ID"	minimum(1)
+// @Param			fields			query		string	false	"Members kept in each item: name, data, data.<key>"
+// @Param			include			query		string	false	"metadata adds created_at and updated_at"	Enums(metadata)
+// @Param			Accept-Language	header		string	false	"Locale of the group labels"
+// @Success		200				{object}	service.SegmentationResponse
+// @Failure		400				{object}	handler.ErrorResponse	"Invalid user_id, fields or include"
+// @Failure		404				{object}	handler.ErrorResponse	"User not found (only with API_USER_LOOKUP)"
+// @Failure		503				{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Failure		504				{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/users/{user_id}/segmentations [get]
 func (h *SegmentationHandler) GetUserSegmentations(c *gin.Context) {
-	userIDStr := c.Param("user_id")
-	userID, err := strconv.ParseUint(userIDStr, 10, 64)
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+
+	fields, ok := fieldsParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	// Localized group labels driven by Accept-Language
+	locale := service.MatchLocale(c.GetHeader("Accept-Language"))
+	if locale != "" {
+		c.Header("Content-Language", locale)
+	}
+	c.Header("Vary", "Accept-Language")
+
+	if h.streamThreshold <= 0 || !render.IsJSON(c) {
+		result, err := h.service.GetByUserID(ctx, userID)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		h.renderUserSegmentations(c, result, fields, locale)
+		return
+	}
+
+	// large users are written as they are read, without being cached
+	streamed := false
+	result, err := h.service.StreamByUserID(ctx, userID, h.streamThreshold, service.ResponseStream{
+		Open: func() io.Writer {
+			streamed = true
+			startJSONStream(c)
+			return c.Writer
+		},
+		Fields: fields,
+		Labels: func(groups []string) map[string]string {
+			if locale == "" {
+				return nil
+			}
+			return h.service.GroupLabels(groups, locale)
+		},
+	})
+	switch {
+	case streamed:
+		if err != nil {
+			// the status is already sent; the client sees a truncated body
+			c.Error(err)
+		}
+	case err != nil:
+		respondError(c, err)
+	default:
+		h.renderUserSegmentations(c, result, fields, locale)
+	}
+}
+
+// renderUserSegmentations answers a response built in memory, streamed
+// when it is a cached one above the threshold
+func (h *SegmentationHandler) renderUserSegmentations(c *gin.Context, result *service.SegmentationResponse, fields *service.Fields, locale string) {
+	if locale != "" {
+		groups := make([]string, 0, len(result.Segmentations))
+		for group := range result.Segmentations {
+			groups = append(groups, group)
+		}
+		result.Labels = h.service.GroupLabels(groups, locale)
+	}
+
+	if h.streamThreshold > 0 && result.Len() > h.streamThreshold && render.IsJSON(c) {
+		startJSONStream(c)
+		if err := result.WriteJSONFields(c.Writer, fields); err != nil {
+			// the status is already sent; the client sees a truncated body
+			c.Error(err)
+		}
+		return
+	}
+
+	render.Render(c, http.StatusOK, userSegmentations{result, fields})
+}
+
+// startJSONStream sends the status and headers of a streamed JSON response
+func startJSONStream(c *gin.Context) {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Writer.Header().Add("Vary", "Accept")
+	c.Status(http.StatusOK)
+}
+
+// userSegmentations renders a response trimmed to fields
+type userSegmentations struct {
+	*service.SegmentationResponse
+	fields *service.Fields
+}
+
+func (u userSegmentations) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	err := u.WriteJSONFields(&buf, u.fields)
+	return buf.Bytes(), err
+}
+
+func (u userSegmentations) Table() ([]string, [][]string, error) {
+	return u.TableFields(u.fields)
+}
+
+// GetUserSegmentationsByType retrieves the segmentations of a user of one
+// type as a flat array, for clients that only need that type; fields and
+// include work as in GetUserSegmentations
+// GET /users/:user_id/segmentations/:type
+// @Summary		Get user segmentations of one type
+// @Description	Returns the segmentations of a user of one type, given singular ("drug") or as its group key ("drugs"), as a flat array; empty when the user has none. With a type registry, types it does not know answer 404. Accept text/csv answers a row per segmentation, application/xml the same array as XML.
+// @Tags			segmentations
+// @Produce		json
+// @Produce		text/csv
+// @Produce		application/xml
+// @Param			user_id	path		integer	true	"User ID"	minimum(1)
+// @Param			type	path		string	true	"Segmentation type or group key"	example(drugs)
+// @Param			fields	query		string	false	"Members kept in each item: name, data, data.<key>"
+// @Param			include	query		string	false	"metadata adds created_at and updated_at"	Enums(metadata)
+// @Success		200		{array}		service.SegmentationItem
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid user_id, fields or include"
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown type, or user not found (only with API_USER_LOOKUP)"
+// @Failure		503		{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Failure		504		{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/users/{user_id}/segmentations/{type} [get]
+func (h *SegmentationHandler) GetUserSegmentationsByType(c *gin.Context) {
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+	fields, ok := fieldsParam(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.service.GetByType(c.Request.Context(), userID, c.Param("type"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user_id format",
-		})
+		respondError(c, err)
+		return
+	}
+	render.Render(c, http.StatusOK, typeSegmentations{items, fields})
+}
+
+// typeSegmentations renders the items of a type trimmed to fields
+type typeSegmentations struct {
+	service.SegmentationItems
+	fields *service.Fields
+}
+
+func (t typeSegmentations) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	err := t.WriteJSONFields(&buf, t.fields)
+	return buf.Bytes(), err
+}
+
+func (t typeSegmentations) Table() ([]string, [][]string, error) {
+	return t.TableFields(t.fields)
+}
+
+// fieldsParam parses the fields and include query parameters of the read
+// endpoints, answering 400 when they are invalid
+func fieldsParam(c *gin.Context) (*service.Fields, bool) {
+	fields, err := service.ParseFields(strings.Join(c.QueryArray("fields"), ","))
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	for _, include := range strings.Split(strings.Join(c.QueryArray("include"), ","), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "metadata":
+			fields = fields.IncludeMetadata()
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("invalid include %q: must be metadata", strings.TrimSpace(include)),
+			})
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// ReplaceUserSegmentations replaces the whole segmentation set of a user with
+// the grouped payload, making this endpoint the source-of-truth sync path
+// PUT /users/:user_id/segmentations
+// @Summary		Replace user segmentations
+// @Description	Makes the stored segmentations of the user match the payload exactly: missing ones are inserted, changed ones updated and the others deleted.
+// @Tags			segmentations
+// @Accept			json
+// @Produce		json
+// @Param			user_id	path		integer					true	"User ID"	minimum(1)
+// @Param			request	body		service.ReplaceRequest	true	"Complete segmentation set, grouped by type"
+// @Success		200		{object}	service.ReplaceResult
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid user_id or body"
+// @Failure		413		{object}	handler.ErrorResponse	"Body over API_MAX_BODY_BYTES"
+// @Failure		415		{object}	handler.ErrorResponse	"Body is not application/json"
+// @Failure		422		{object}	handler.ErrorResponse	"Data over the validation limits"
+// @Failure		503		{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Failure		504		{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/users/{user_id}/segmentations [put]
+func (h *SegmentationHandler) ReplaceUserSegmentations(c *gin.Context) {
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.ReplaceRequest
+	if !bindJSON(c, &req, h.strictJSON) {
 		return
 	}
 
 	ctx := c.Request.Context()
-	result, err := h.service.GetByUserID(ctx, userID)
+	result, err := h.service.Replace(ctx, userID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateUserSegmentation inserts or updates a single segmentation of a user
+// POST /users/:user_id/segmentations
+// @Summary		Upsert a user segmentation
+// @Description	Inserts or updates one segmentation of the user. With the write queue enabled the write is accepted with 202 and followed at GET /writes/{id}.
+// @Tags			segmentations
+// @Accept			json
+// @Produce		json
+// @Param			user_id			path		integer					true	"User ID"	minimum(1)
+// @Param			Idempotency-Key	header		string					false	"Replays the stored response of a repeated request"	maxlength(255)
+// @Param			request			body		service.UpsertRequest	true	"Segmentation to write"
+// @Success		200				{object}	service.UpsertResponse	"Updated or unchanged"
+// @Success		201				{object}	service.UpsertResponse	"Inserted"
+// @Success		202				{object}	service.QueuedResponse	"Queued"
+// @Header			202				{string}	Location	"/writes/{id}"
+// @Failure		400				{object}	handler.ErrorResponse	"Invalid user_id or segmentation"
+// @Failure		409				{object}	handler.ErrorResponse	"Idempotency-Key reused or in progress"
+// @Failure		413				{object}	handler.ErrorResponse	"Body over API_MAX_BODY_BYTES"
+// @Failure		415				{object}	handler.ErrorResponse	"Body is not application/json"
+// @Failure		422				{object}	handler.ErrorResponse	"Data over the validation limits"
+// @Failure		503				{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Failure		504				{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/users/{user_id}/segmentations [post]
+func (h *SegmentationHandler) CreateUserSegmentation(c *gin.Context) {
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpsertRequest
+	if !bindJSON(c, &req, h.strictJSON) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if h.queue != nil {
+		resp, err := h.service.QueueUpsert(ctx, h.queue, userID, req)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		accepted(c, resp)
+		return
+	}
+
+	resp, result, err := h.service.Upsert(ctx, userID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if result == repository.UpsertInserted {
+		status = http.StatusCreated
+	}
+	c.JSON(status, resp)
+}
+
+// BulkUpsertSegmentations writes a batch of segmentations for any users,
+// reporting per-item failures in the response. With ?transactional=true
+// (or "transactional": true) the batch is applied all or nothing, bypassing
+// the write queue.
+// POST /segmentations/bulk
+// @Summary		Bulk upsert segmentations
+// @Description	Writes up to 1000 segmentations of any users. Invalid items are reported per index; a transactional batch is rolled back as a whole instead.
+// @Tags			segmentations
+// @Accept			json
+// @Produce		json
+// @Param			transactional	query		boolean				false	"Apply the batch all or nothing"
+// @Param			Idempotency-Key	header		string				false	"Replays the stored response of a repeated request"	maxlength(255)
+// @Param			request			body		service.BulkRequest	true	"Items to write"
+// @Success		200				{object}	service.BulkResult
+// @Success		202				{object}	service.QueuedResponse	"Queued"
+// @Header			202				{string}	Location	"/writes/{id}"
+// @Failure		400				{object}	handler.ErrorResponse	"Invalid body, empty or oversized batch, or rolled back batch"
+// @Failure		409				{object}	handler.ErrorResponse	"Idempotency-Key reused or in progress"
+// @Failure		413				{object}	handler.ErrorResponse	"Body over API_MAX_BODY_BYTES"
+// @Failure		415				{object}	handler.ErrorResponse	"Body is not application/json"
+// @Failure		503				{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Failure		504				{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/segmentations/bulk [post]
+func (h *SegmentationHandler) BulkUpsertSegmentations(c *gin.Context) {
+	var req service.BulkRequest
+	if !bindJSON(c, &req, h.strictJSON) {
+		return
+	}
+	if v := c.Query("transactional"); v != "" {
+		transactional, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "transactional must be true or false",
+			})
+			return
+		}
+		req.Transactional = transactional
+	}
+
+	ctx := c.Request.Context()
+	if h.queue != nil && !req.Transactional {
+		resp, err := h.service.QueueBulk(ctx, h.queue, req)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		accepted(c, resp)
+		return
+	}
+
+	result, err := h.service.BulkUpsert(ctx, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteUserSegmentation removes one segmentation of a user, identified by
+// the type and name query parameters
+// DELETE /users/:user_id/segmentations?type=drug&name=Aspirina
+// @Summary		Delete a user segmentation
+// @Description	Removes the segmentation with the given type (singular or group key) and name, matched like writes match it.
+// @Tags			segmentations
+// @Produce		json
+// @Param			user_id	path	integer	true	"User ID"	minimum(1)
+// @Param			type	query	string	true	"Segmentation type"	maxlength(50)
+// @Param			name	query	string	true	"Segmentation name"	maxlength(100)
+// @Success		204
+// @Failure		400	{object}	handler.ErrorResponse	"Invalid user_id, type or name"
+// @Failure		404	{object}	handler.ErrorResponse	"Segmentation not found"
+// @Failure		503	{object}	handler.ErrorResponse	"Database unavailable or maintenance mode"
+// @Failure		504	{object}	handler.ErrorResponse	"Request timed out"
+// @Router			/users/{user_id}/segmentations [delete]
+func (h *SegmentationHandler) DeleteUserSegmentation(c *gin.Context) {
+	userID, ok := h.userIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, userID, c.Query("type"), c.Query("name")); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	if result == nil {
+	c.Status(http.StatusNoContent)
+}
+
+// GetWrite reports the status of a write accepted by the write queue
+// GET /writes/:id
+// @Summary		Get a queued write
+// @Tags			segmentations
+// @Produce		json
+// @Param			id	path		string	true	"Write ID"
+// @Success		200	{object}	writequeue.Status
+// @Failure		404	{object}	handler.ErrorResponse	"Unknown or expired write"
+// @Router			/writes/{id} [get]
+func (h *SegmentationHandler) GetWrite(c *gin.Context) {
+	status, ok := h.queue.Status(c.Param("id"))
+	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{
-			"error": "user not found",
+			"error": "write not found",
 		})
 		return
 	}
+	c.JSON(http.StatusOK, status)
+}
 
-	c.JSON(http.StatusOK, result)
+// accepted answers 202 with the queued write, or 200 when nothing was
+// queued because every item was invalid
+func accepted(c *gin.Context, resp *service.QueuedResponse) {
+	if resp.ID == "" {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	c.Header("Location", "/writes/"+resp.ID)
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// userIDParam parses the user_id path parameter, answering 400 with the
+// offending value and the reason when it is not a canonical ID within the
+// configured bounds
+func (h *SegmentationHandler) userIDParam(c *gin.Context) (uint64, bool) {
+	return parseUserIDParam(c, h.service.ParseUserID)
+}
+
+// parseUserIDParam is userIDParam with the parser of another handler
+func parseUserIDParam(c *gin.Context, parse func(string) (uint64, error)) (uint64, bool) {
+	value := c.Param("user_id")
+	userID, err := parse(value)
+	if err != nil {
+		body := gin.H{"error": "invalid user_id format"}
+		for k, v := range apperrors.Details(err) {
+			body[k] = v
+		}
+		c.JSON(http.StatusBadRequest, body)
+		return 0, false
+	}
+	return userID, true
 }
 
 // Health returns the health status of the API
 // GET /health
+// @Summary		Health check
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	map[string]string
+// @Router			/health [get]
 func (h *SegmentationHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status": "healthy",
@@ -3,9 +3,14 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"segmentation-api/internal/models"
 	"segmentation-api/internal/repository"
@@ -27,10 +32,65 @@ func (m *MockRepository) FindByUserID(ctx context.Context, userID uint64) ([]mod
 	return nil, nil
 }
 
+func (m *MockRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func (m *MockRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
 	return repository.UpsertInserted, nil
 }
 
+func (m *MockRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *MockRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *MockRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
+func TestGetUserSegmentations_Streamed(t *testing.T) {
+	// in type order, as the repository reads them
+	var mockData []models.Segmentation
+	for i := 0; i < 10; i++ {
+		mockData = append(mockData, models.Segmentation{
+			UserID:           123,
+			SegmentationType: []string{"drug", "specialty"}[i/5],
+			SegmentationName: fmt.Sprintf("seg-%d", i),
+			Data:             datatypes.JSON(`{"quantity": "200"}`),
+		})
+	}
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return mockData, nil
+		},
+	}
+	svc := service.NewSegmentationService(mockRepo)
+
+	get := func(h *SegmentationHandler) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/users/123/segmentations", nil)
+		c.Request.Header.Set("Accept-Language", "en")
+		c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+		h.GetUserSegmentations(c)
+		return w
+	}
+
+	buffered := get(NewSegmentationHandler(svc))
+	streamed := get(NewSegmentationHandler(svc, WithStreamThreshold(5)))
+
+	if streamed.Code != http.StatusOK || streamed.Header().Get("Content-Type") != buffered.Header().Get("Content-Type") {
+		t.Errorf("streamed: status %d, Content-Type %q", streamed.Code, streamed.Header().Get("Content-Type"))
+	}
+	if streamed.Body.String() != buffered.Body.String() {
+		t.Errorf("streamed body differs:\n%s\nwant\n%s", streamed.Body.String(), buffered.Body.String())
+	}
+}
+
 func TestGetUserSegmentations_Success(t *testing.T) {
 	// Setup mock data
 	mockData := []models.Segmentation{
@@ -175,6 +235,31 @@ func TestGetUserSegmentations_NotFound(t *testing.T) {
 	}
 }
 
+// userSet is a UserDirectory of fixed user IDs
+type userSet map[uint64]bool
+
+func (u userSet) Exists(ctx context.Context, userID uint64) (bool, error) {
+	return u[userID], nil
+}
+
+func TestGetUserSegmentations_UnknownUser(t *testing.T) {
+	svc := service.NewSegmentationService(&MockRepository{}, service.WithUserDirectory(userSet{999: true}))
+	handler := NewSegmentationHandler(svc)
+
+	for userID, want := range map[string]int{"999": http.StatusOK, "998": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/users/"+userID+"/segmentations", nil)
+		c.Params = []gin.Param{{Key: "user_id", Value: userID}}
+
+		handler.GetUserSegmentations(c)
+
+		if w.Code != want {
+			t.Errorf("user %s: status = %d, want %d: %s", userID, w.Code, want, w.Body.String())
+		}
+	}
+}
+
 func TestHealth(t *testing.T) {
 	mockRepo := &MockRepository{}
 	svc := service.NewSegmentationService(mockRepo)
@@ -222,6 +307,31 @@ func TestGetUserSegmentations_EmptyUserID(t *testing.T) {
 	}
 }
 
+func TestGetUserSegmentations_NonCanonicalUserID(t *testing.T) {
+	svc := service.NewSegmentationService(&MockRepository{}, service.WithValidationRules(service.ValidationRules{MaxUserID: 1000}))
+	handler := NewSegmentationHandler(svc)
+
+	for value, reason := range map[string]string{
+		"+12":  "must not have a sign",
+		"012":  "must not have leading zeros",
+		" 12":  "must contain only digits",
+		"1001": "must be at most 1000",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/users/x/segmentations", nil)
+		c.Params = []gin.Param{{Key: "user_id", Value: value}}
+
+		handler.GetUserSegmentations(c)
+
+		var resp map[string]string
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || resp["value"] != value || resp["reason"] != reason {
+			t.Errorf("user_id %q: status %d, body %v", value, w.Code, resp)
+		}
+	}
+}
+
 func TestGetUserSegmentations_NegativeUserID(t *testing.T) {
 	mockRepo := &MockRepository{}
 	svc := service.NewSegmentationService(mockRepo)
@@ -409,7 +519,7 @@ func TestNewSegmentationHandler_NotNil(t *testing.T) {
 func TestGetUserSegmentations_ServiceError(t *testing.T) {
 	mockRepo := &MockRepository{
 		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
-			return nil, context.DeadlineExceeded
+			return nil, errors.New("connection lost")
 		},
 	}
 
@@ -436,6 +546,33 @@ func TestGetUserSegmentations_ServiceError(t *testing.T) {
 	}
 }
 
+func TestGetUserSegmentations_DatabaseUnavailable(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return nil, &repository.UnavailableError{RetryAfter: 1500 * time.Millisecond}
+		},
+	}
+
+	handler := NewSegmentationHandler(service.NewSegmentationService(mockRepo))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/users/123/segmentations", nil)
+	c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+	handler.GetUserSegmentations(c)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if len(c.Errors) != 0 {
+		t.Errorf("an unavailable database should not be recorded as a server error: %v", c.Errors)
+	}
+}
+
 func TestGetUserSegmentations_SpecificUserID(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -581,3 +718,538 @@ func TestGetUserSegmentations_GroupingByType(t *testing.T) {
 		}
 	}
 }
+
+func TestReplaceUserSegmentations_Success(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	body := `{"segmentations": {"drugs": [{"name": "Aspirina", "data": {"dose": "500mg"}}]}}`
+	req := httptest.NewRequest("PUT", "/users/123/segmentations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+	handler.ReplaceUserSegmentations(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp service.ReplaceResult
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.UserID != 123 || resp.Inserted != 1 {
+		t.Fatalf("unexpected result: %+v", resp)
+	}
+}
+
+func TestReplaceUserSegmentations_InvalidPayload(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "malformed json", body: `{"segmentations":`},
+		{name: "empty name", body: `{"segmentations": {"drugs": [{"name": ""}]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/users/123/segmentations", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+			handler.ReplaceUserSegmentations(c)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestCreateUserSegmentation_Created(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	body := `{"segmentation_type": "drug", "segmentation_name": "Aspirina", "data": {"dose": "500mg"}}`
+	req := httptest.NewRequest("POST", "/users/123/segmentations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+	handler.CreateUserSegmentation(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp service.UpsertResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.UserID != 123 || resp.Result != "inserted" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestDeleteUserSegmentation(t *testing.T) {
+	repo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{{ID: 7, UserID: userID, SegmentationType: "drug", SegmentationName: "Aspirina"}}, nil
+		},
+	}
+	handler := NewSegmentationHandler(service.NewSegmentationService(repo))
+
+	tests := []struct {
+		query      string
+		wantStatus int
+	}{
+		{query: "type=drugs&name=Aspirina", wantStatus: http.StatusNoContent},
+		{query: "type=drug&name=Dipirona", wantStatus: http.StatusNotFound},
+		{query: "type=drug", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("DELETE", "/users/123/segmentations?"+tt.query, nil)
+			c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+			handler.DeleteUserSegmentation(c)
+			c.Writer.WriteHeaderNow()
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateUserSegmentation_InvalidBody(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	req := httptest.NewRequest("POST", "/users/123/segmentations", strings.NewReader(`{"segmentation_type": ""}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+	handler.CreateUserSegmentation(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestCreateUserSegmentation_DataTooLarge(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{},
+		service.WithValidationRules(service.ValidationRules{MaxDataBytes: 32})))
+
+	body := `{"segmentation_type": "drug", "segmentation_name": "Aspirina", "data": {"notes": "` + strings.Repeat("x", 64) + `"}}`
+	req := httptest.NewRequest("POST", "/users/123/segmentations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+	handler.CreateUserSegmentation(c)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateUserSegmentation_StrictJSON(t *testing.T) {
+	body := `{"segmentation_type": "drug", "segmentaton_name": "Aspirina"}`
+
+	for _, strict := range []bool{false, true} {
+		var opts []SegmentationHandlerOption
+		if strict {
+			opts = append(opts, WithStrictJSON())
+		}
+		handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}), opts...)
+
+		req := httptest.NewRequest("POST", "/users/123/segmentations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+		handler.CreateUserSegmentation(c)
+
+		// lenient binding drops the misspelled member and the empty name
+		// fails validation; strict binding names the member
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("strict=%v: expected status 400, got %d", strict, w.Code)
+		}
+		if named := strings.Contains(w.Body.String(), `unknown field \"segmentaton_name\"`); named != strict {
+			t.Errorf("strict=%v: unexpected body %s", strict, w.Body.String())
+		}
+	}
+}
+
+func TestBulkUpsertSegmentations(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	body := `{"items": [
+		{"user_id": 1, "segmentation_type": "drug", "segmentation_name": "Aspirina"},
+		{"user_id": 2, "segmentation_type": "specialty", "segmentation_name": ""}
+	]}`
+	req := httptest.NewRequest("POST", "/segmentations/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	handler.BulkUpsertSegmentations(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp service.BulkResult
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Inserted != 1 || resp.Failed != 1 {
+		t.Fatalf("unexpected result: %+v", resp)
+	}
+}
+
+func TestBulkUpsertSegmentations_Transactional(t *testing.T) {
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}))
+
+	body := `{"items": [
+		{"user_id": 1, "segmentation_type": "drug", "segmentation_name": "Aspirina"},
+		{"user_id": 2, "segmentation_type": "specialty", "segmentation_name": ""}
+	]}`
+	req := httptest.NewRequest("POST", "/segmentations/bulk?transactional=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	handler.BulkUpsertSegmentations(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Errors []service.BulkItemError `json:"errors"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Errors) != 1 || resp.Errors[0].Index != 1 {
+		t.Fatalf("unexpected item errors: %s", w.Body.String())
+	}
+}
+
+func TestGetUserSegmentations_LocalizedLabels(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{
+				{ID: 1, UserID: 123, SegmentationType: "drug", SegmentationName: "Aspirina", Data: datatypes.JSON(`{}`)},
+			}, nil
+		},
+	}
+	types := &MockTypeRepository{types: map[string]models.SegmentationType{
+		"drug": {Name: "drug", DisplayName: "Medicamentos", Active: true, Labels: datatypes.JSON(`{"en": "Drugs"}`)},
+	}}
+	reg := service.NewTypeRegistry(types, 0)
+	if err := reg.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	handler := NewSegmentationHandler(service.NewSegmentationService(mockRepo, service.WithTypeRegistry(reg)))
+
+	tests := []struct {
+		name         string
+		acceptLang   string
+		wantLabel    string
+		wantLanguage string
+	}{
+		{"english", "en-US,en;q=0.9", "Drugs", "en"},
+		{"portuguese falls back to display name", "pt-BR", "Medicamentos", "pt-BR"},
+		{"no header", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users/123/segmentations", nil)
+			if tt.acceptLang != "" {
+				req.Header.Set("Accept-Language", tt.acceptLang)
+			}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+			handler.GetUserSegmentations(c)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLanguage)
+			}
+
+			var resp service.SegmentationResponse
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if got := resp.Labels["drugs"]; got != tt.wantLabel {
+				t.Errorf("labels[drugs] = %q, want %q", got, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestGetUserSegmentations_Fields(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{
+				{UserID: 123, SegmentationType: "drug", SegmentationName: "Dipirona", Data: datatypes.JSON(`{"quantity": "200", "dose": 1}`), CreatedAt: 1767225600, UpdatedAt: 1767229200},
+				{UserID: 123, SegmentationType: "drug", SegmentationName: "Ibuprofeno", Data: datatypes.JSON(`{"dose": 2}`)},
+			}, nil
+		},
+	}
+	svc := service.NewSegmentationService(mockRepo)
+
+	tests := []struct {
+		name       string
+		query      string
+		opts       []SegmentationHandlerOption
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "name", query: "fields=name", wantStatus: http.StatusOK,
+			wantBody: `{"user_id":123,"segmentations":{"drugs":[{"name":"Dipirona"},{"name":"Ibuprofeno"}]}}`,
+		},
+		{
+			name: "data key", query: "fields=data.quantity", wantStatus: http.StatusOK,
+			wantBody: `{"user_id":123,"segmentations":{"drugs":[{"data":{"quantity":"200"}},{"data":{}}]}}`,
+		},
+		{
+			name: "repeated parameter", query: "fields=name&fields=data.dose", wantStatus: http.StatusOK,
+			wantBody: `{"user_id":123,"segmentations":{"drugs":[{"name":"Dipirona","data":{"dose":1}},{"name":"Ibuprofeno","data":{"dose":2}}]}}`,
+		},
+		{
+			name: "streamed", query: "fields=name", opts: []SegmentationHandlerOption{WithStreamThreshold(1)}, wantStatus: http.StatusOK,
+			wantBody: `{"user_id":123,"segmentations":{"drugs":[{"name":"Dipirona"},{"name":"Ibuprofeno"}]}}`,
+		},
+		{name: "unknown field", query: "fields=user_id", wantStatus: http.StatusBadRequest},
+		{
+			name: "metadata", query: "include=metadata", wantStatus: http.StatusOK,
+			wantBody: `{"user_id":123,"segmentations":{"drugs":[` +
+				`{"name":"Dipirona","data":{"quantity":"200","dose":1},"created_at":"2026-01-01T00:00:00Z","updated_at":"2026-01-01T01:00:00Z"},` +
+				`{"name":"Ibuprofeno","data":{"dose":2},"created_at":null,"updated_at":null}]}}`,
+		},
+		{
+			name: "metadata with fields", query: "fields=name&include=metadata", wantStatus: http.StatusOK,
+			wantBody: `{"user_id":123,"segmentations":{"drugs":[` +
+				`{"name":"Dipirona","created_at":"2026-01-01T00:00:00Z","updated_at":"2026-01-01T01:00:00Z"},` +
+				`{"name":"Ibuprofeno","created_at":null,"updated_at":null}]}}`,
+		},
+		{name: "unknown include", query: "include=audit", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/users/123/segmentations?"+tt.query, nil)
+			c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+			NewSegmentationHandler(svc, tt.opts...).GetUserSegmentations(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusOK && w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestGetUserSegmentations_Formats(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{
+				{UserID: 123, SegmentationType: "drug", SegmentationName: "Dipirona, 500mg", Data: datatypes.JSON(`{"quantity": "200"}`), UpdatedAt: 1767229200},
+				{UserID: 123, SegmentationType: "specialty", SegmentationName: "Cardiologia", Data: datatypes.JSON(`{"1st": true}`)},
+			}, nil
+		},
+	}
+	svc := service.NewSegmentationService(mockRepo)
+
+	tests := []struct {
+		name, accept, query string
+		opts                []SegmentationHandlerOption
+		wantType            string
+		wantBody            string
+	}{
+		{
+			name: "csv", accept: "text/csv", wantType: "text/csv; charset=utf-8",
+			wantBody: "user_id,group,name,data\n" +
+				"123,drugs,\"Dipirona, 500mg\",\"{\"\"quantity\"\":\"\"200\"\"}\"\n" +
+				"123,specialties,Cardiologia,\"{\"\"1st\"\":true}\"\n",
+		},
+		{
+			name: "csv with fields", accept: "text/csv", query: "fields=name&include=metadata", wantType: "text/csv; charset=utf-8",
+			wantBody: "user_id,group,name,created_at,updated_at\n" +
+				"123,drugs,\"Dipirona, 500mg\",,2026-01-01T01:00:00Z\n" +
+				"123,specialties,Cardiologia,,\n",
+		},
+		{
+			name: "xml", accept: "application/xml", wantType: "application/xml; charset=utf-8",
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<response><user_id>123</user_id><segmentations>` +
+				`<drugs><item><name>Dipirona, 500mg</name><data><quantity>200</quantity></data></item></drugs>` +
+				`<specialties><item><name>Cardiologia</name><data><entry key="1st">true</entry></data></item></specialties>` +
+				`</segmentations></response>`,
+		},
+		{
+			name: "xml is not streamed", accept: "application/xml", query: "fields=name", opts: []SegmentationHandlerOption{WithStreamThreshold(1)},
+			wantType: "application/xml; charset=utf-8",
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<response><user_id>123</user_id><segmentations>` +
+				`<drugs><item><name>Dipirona, 500mg</name></item></drugs>` +
+				`<specialties><item><name>Cardiologia</name></item></specialties>` +
+				`</segmentations></response>`,
+		},
+		{
+			name: "unknown type falls back to json", accept: "text/html", query: "fields=name", wantType: "application/json; charset=utf-8",
+			wantBody: `{"user_id":123,"segmentations":{"drugs":[{"name":"Dipirona, 500mg"}],"specialties":[{"name":"Cardiologia"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/users/123/segmentations?"+tt.query, nil)
+			c.Request.Header.Set("Accept", tt.accept)
+			c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+			NewSegmentationHandler(svc, tt.opts...).GetUserSegmentations(c)
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.wantType {
+				t.Fatalf("got %d %q, want 200 %q: %s", w.Code, w.Header().Get("Content-Type"), tt.wantType, w.Body.String())
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+			if vary := w.Header().Values("Vary"); !slices.Contains(vary, "Accept") {
+				t.Errorf("Vary = %v, want Accept", vary)
+			}
+		})
+	}
+}
+
+func TestRespondError_Conflict(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	respondError(c, fmt.Errorf("upsert: %w", &repository.ConflictError{
+		Key:   []string{"user_id", "segmentation_type", "segmentation_name"},
+		Value: "123-drug-Aspirina",
+		Err:   errors.New("Error 1062 (23000): Duplicate entry '123-drug-Aspirina' for key 'segmentations.uniq_user_seg'"),
+	}))
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "uniq_user_seg") || strings.Contains(w.Body.String(), "1062") {
+		t.Errorf("response leaks the MySQL error: %s", w.Body.String())
+	}
+	var resp struct {
+		Key   []string `json:"key"`
+		Value string   `json:"value"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Key) != 3 || resp.Value != "123-drug-Aspirina" {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestGetUserSegmentationsByType(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{
+				{UserID: 123, SegmentationType: "drug", SegmentationName: "Dipirona, 500mg", Data: datatypes.JSON(`{"quantity": "200"}`)},
+				{UserID: 123, SegmentationType: "specialty", SegmentationName: "Cardiologia", Data: datatypes.JSON(`{}`)},
+			}, nil
+		},
+	}
+	svc := service.NewSegmentationService(mockRepo)
+
+	tests := []struct {
+		name, segType, accept, query string
+		wantType                     string
+		wantBody                     string
+	}{
+		{
+			name: "group key", segType: "drugs", wantType: "application/json; charset=utf-8",
+			wantBody: `[{"name":"Dipirona, 500mg","data":{"quantity":"200"}}]`,
+		},
+		{
+			name: "singular type with fields", segType: "Drug", query: "fields=name", wantType: "application/json; charset=utf-8",
+			wantBody: `[{"name":"Dipirona, 500mg"}]`,
+		},
+		{
+			name: "no segmentations of the type", segType: "patients", wantType: "application/json; charset=utf-8",
+			wantBody: `[]`,
+		},
+		{
+			name: "csv", segType: "drugs", accept: "text/csv", wantType: "text/csv; charset=utf-8",
+			wantBody: "name,data\n\"Dipirona, 500mg\",\"{\"\"quantity\"\":\"\"200\"\"}\"\n",
+		},
+		{
+			name: "xml", segType: "specialties", accept: "application/xml", wantType: "application/xml; charset=utf-8",
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<response><item><name>Cardiologia</name><data></data></item></response>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/users/123/segmentations/"+tt.segType+"?"+tt.query, nil)
+			c.Request.Header.Set("Accept", tt.accept)
+			c.Params = []gin.Param{{Key: "user_id", Value: "123"}, {Key: "type", Value: tt.segType}}
+			NewSegmentationHandler(svc).GetUserSegmentationsByType(c)
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.wantType {
+				t.Fatalf("got %d %q, want 200 %q: %s", w.Code, w.Header().Get("Content-Type"), tt.wantType, w.Body.String())
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("invalid include", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/users/123/segmentations/drugs?include=audit", nil)
+		c.Params = []gin.Param{{Key: "user_id", Value: "123"}, {Key: "type", Value: "drugs"}}
+		NewSegmentationHandler(svc).GetUserSegmentationsByType(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("got %d, want 400", w.Code)
+		}
+	})
+}
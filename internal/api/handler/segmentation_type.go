@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TypeHandler handles the segmentation type registry admin endpoints
+type TypeHandler struct {
+	registry *service.TypeRegistry
+}
+
+// NewTypeHandler creates a new type registry handler
+func NewTypeHandler(r *service.TypeRegistry) *TypeHandler {
+	return &TypeHandler{registry: r}
+}
+
+// ListTypes returns every registered segmentation type with its drift
+// GET /admin/segmentation-types
+// @Summary		List segmentation types
+// @Description	Each type comes with its drift: for a type declared with PUT, whether it still matches the declaration and which fields changed since.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	object{types=[]service.TypeState}
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/admin/segmentation-types [get]
+func (h *TypeHandler) ListTypes(c *gin.Context) {
+	types, err := h.registry.States(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"types": types,
+	})
+}
+
+// GetType returns a single registered type with its drift
+// GET /admin/segmentation-types/:name
+// @Summary		Get a segmentation type
+// @Description	drift.in_sync is false when a type declared with PUT was changed since, by PATCH or in the database; drift.fields lists what changed and drift.applied is the declaration.
+// @Tags			admin
+// @Produce		json
+// @Security		AdminToken
+// @Param			name	path		string	true	"Type name"
+// @Success		200		{object}	service.TypeState
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown type"
+// @Router			/admin/segmentation-types/{name} [get]
+func (h *TypeHandler) GetType(c *gin.Context) {
+	t, err := h.registry.State(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+// RegisterType adds a type to the registry
+// POST /admin/segmentation-types
+// @Summary		Register a segmentation type
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			request	body		service.TypeRequest	true	"Type to register"
+// @Success		201		{object}	models.SegmentationType
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid body, name or data schema"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		409		{object}	handler.ErrorResponse	"Type already registered"
+// @Router			/admin/segmentation-types [post]
+func (h *TypeHandler) RegisterType(c *gin.Context) {
+	var req service.TypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	t, err := h.registry.Register(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, t)
+}
+
+// UpdateType partially updates a registered type
+// PATCH /admin/segmentation-types/:name
+// @Summary		Update a segmentation type
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			name	path		string				true	"Type name"
+// @Param			request	body		service.TypePatch	true	"Fields to change"
+// @Success		200		{object}	models.SegmentationType
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid body or data schema"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown type"
+// @Router			/admin/segmentation-types/{name} [patch]
+func (h *TypeHandler) UpdateType(c *gin.Context) {
+	var patch service.TypePatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	t, err := h.registry.Update(c.Request.Context(), c.Param("name"), patch)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+// PutType declares the whole state of a type, creating it if needed
+// PUT /admin/segmentation-types/:name
+// @Summary		Declare a segmentation type
+// @Description	Idempotent: the type is made to match the body, omitted fields taking their defaults, and the body is kept as its declaration. Applying the same body again changes nothing and answers result "unchanged". Unknown fields are refused.
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			name	path		string				true	"Type name"
+// @Param			request	body		service.TypeSpec	true	"Declared state"
+// @Success		200		{object}	service.AppliedType	"Updated or unchanged"
+// @Success		201		{object}	service.AppliedType	"Created"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid body, name or data schema"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/admin/segmentation-types/{name} [put]
+func (h *TypeHandler) PutType(c *gin.Context) {
+	var spec service.TypeSpec
+	if !bindJSON(c, &spec, true) {
+		return
+	}
+
+	t, err := h.registry.Apply(c.Request.Context(), c.Param("name"), spec)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if t.Result == service.ApplyCreated {
+		status = http.StatusCreated
+	}
+	c.JSON(status, t)
+}
+
+// PutTypeSchema sets the data schema of a registered type
+// PUT /admin/segmentation-types/:name/schema
+// @Summary		Declare the data schema of a segmentation type
+// @Description	Idempotent: the body is the JSON Schema itself, null removes it. The schema of a declared type is updated in its declaration too.
+// @Tags			admin
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			name	path		string	true	"Type name"
+// @Param			schema	body		object	true	"Data schema"
+// @Success		200		{object}	service.AppliedType
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid data schema"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown type"
+// @Router			/admin/segmentation-types/{name}/schema [put]
+func (h *TypeHandler) PutTypeSchema(c *gin.Context) {
+	var schema json.RawMessage
+	if !bindJSON(c, &schema, false) {
+		return
+	}
+
+	t, err := h.registry.ApplySchema(c.Request.Context(), c.Param("name"), schema)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MockTypeRepository struct {
+	types map[string]models.SegmentationType
+}
+
+func (m *MockTypeRepository) List(ctx context.Context) ([]models.SegmentationType, error) {
+	var out []models.SegmentationType
+	for _, t := range m.types {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (m *MockTypeRepository) FindByName(ctx context.Context, name string) (*models.SegmentationType, error) {
+	t, ok := m.types[name]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (m *MockTypeRepository) Create(ctx context.Context, t *models.SegmentationType) error {
+	m.types[t.Name] = *t
+	return nil
+}
+
+func (m *MockTypeRepository) Update(ctx context.Context, t *models.SegmentationType) error {
+	m.types[t.Name] = *t
+	return nil
+}
+
+func newTypeTestHandler() *TypeHandler {
+	repo := &MockTypeRepository{types: map[string]models.SegmentationType{
+		"drug": {Name: "drug", DisplayName: "Medicamentos", Active: true},
+	}}
+	return NewTypeHandler(service.NewTypeRegistry(repo, 0))
+}
+
+func TestCreateUserSegmentation_StrictUnknownType(t *testing.T) {
+	repo := &MockTypeRepository{types: map[string]models.SegmentationType{
+		"drug":      {Name: "drug", Active: true},
+		"specialty": {Name: "specialty", Active: true},
+		"legacy":    {Name: "legacy", Active: false},
+	}}
+	registry := service.NewTypeRegistry(repo, 0)
+	if err := registry.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{},
+		service.WithValidationRules(service.ValidationRules{Mode: service.ValidationStrict}),
+		service.WithTypeRegistry(registry),
+	))
+
+	body := `{"segmentation_type": "patient", "segmentation_name": "Adulto"}`
+	req := httptest.NewRequest("POST", "/users/123/segmentations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+
+	handler.CreateUserSegmentation(c)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Allowed []string `json:"allowed"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if strings.Join(resp.Allowed, ",") != "drug,specialty" {
+		t.Errorf("allowed = %v, want [drug specialty]", resp.Allowed)
+	}
+}
+
+func TestTypeHandler_ListTypes(t *testing.T) {
+	h := newTypeTestHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/segmentation-types", nil)
+
+	h.ListTypes(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Types []models.SegmentationType `json:"types"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Types) != 1 || resp.Types[0].DisplayName != "Medicamentos" {
+		t.Fatalf("unexpected types: %+v", resp.Types)
+	}
+}
+
+func TestTypeHandler_GetType(t *testing.T) {
+	h := newTypeTestHandler()
+
+	tests := []struct {
+		name     string
+		param    string
+		expected int
+	}{
+		{name: "found", param: "drug", expected: http.StatusOK},
+		{name: "not found", param: "unknown", expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/admin/segmentation-types/"+tt.param, nil)
+			c.Params = []gin.Param{{Key: "name", Value: tt.param}}
+
+			h.GetType(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestTypeHandler_RegisterType(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{name: "created", body: `{"name": "patient", "display_name": "Pacientes"}`, expected: http.StatusCreated},
+		{name: "duplicate", body: `{"name": "drug"}`, expected: http.StatusConflict},
+		{name: "invalid", body: `{"name": ""}`, expected: http.StatusBadRequest},
+		{name: "malformed", body: `{`, expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTypeTestHandler()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/admin/segmentation-types", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			h.RegisterType(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTypeHandler_UpdateType(t *testing.T) {
+	h := newTypeTestHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PATCH", "/admin/segmentation-types/drug", strings.NewReader(`{"active": false}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = []gin.Param{{Key: "name", Value: "drug"}}
+
+	h.UpdateType(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp models.SegmentationType
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Active {
+		t.Error("type should be inactive after update")
+	}
+}
+
+func TestTypeHandler_PutType(t *testing.T) {
+	h := newTypeTestHandler()
+
+	tests := []struct {
+		name     string
+		param    string
+		body     string
+		expected int
+		result   service.ApplyResult
+	}{
+		{name: "created", param: "patient", body: `{"display_name": "Pacientes"}`, expected: http.StatusCreated, result: service.ApplyCreated},
+		{name: "unchanged", param: "patient", body: `{"display_name": "Pacientes", "active": true}`, expected: http.StatusOK, result: service.ApplyUnchanged},
+		{name: "existing type declared", param: "drug", body: `{"display_name": "Medicamentos"}`, expected: http.StatusOK, result: service.ApplyUpdated},
+		{name: "unknown field", param: "drug", body: `{"display_nmae": "Medicamentos"}`, expected: http.StatusBadRequest},
+		{name: "invalid schema", param: "drug", body: `{"data_schema": {"type": "array"}}`, expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("PUT", "/admin/segmentation-types/"+tt.param, strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = []gin.Param{{Key: "name", Value: tt.param}}
+
+			h.PutType(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			var resp service.AppliedType
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Result != tt.result {
+				t.Errorf("result = %q, want %q", resp.Result, tt.result)
+			}
+		})
+	}
+}
+
+func TestTypeHandler_GetTypeDrift(t *testing.T) {
+	h := newTypeTestHandler()
+	ctx := context.Background()
+	if _, err := h.registry.Apply(ctx, "drug", service.TypeSpec{DisplayName: "Medicamentos"}); err != nil {
+		t.Fatal(err)
+	}
+	renamed := "Remédios"
+	if _, err := h.registry.Update(ctx, "drug", service.TypePatch{DisplayName: &renamed}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/segmentation-types/drug", nil)
+	c.Params = []gin.Param{{Key: "name", Value: "drug"}}
+
+	h.GetType(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp service.TypeState
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.DisplayName != "Remédios" || resp.Drift.InSync || strings.Join(resp.Drift.Fields, ",") != "display_name" {
+		t.Errorf("unexpected drift: %s", w.Body.String())
+	}
+}
+
+func TestTypeHandler_PutTypeSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    string
+		body     string
+		expected int
+	}{
+		{name: "set", param: "drug", body: `{"properties": {"dose": {"type": "number"}}}`, expected: http.StatusOK},
+		{name: "removed", param: "drug", body: `null`, expected: http.StatusOK},
+		{name: "unknown type", param: "unknown", body: `{}`, expected: http.StatusNotFound},
+		{name: "invalid", param: "drug", body: `{"type": "array"}`, expected: http.StatusBadRequest},
+		{name: "malformed", param: "drug", body: `{`, expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTypeTestHandler()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("PUT", "/admin/segmentation-types/"+tt.param+"/schema", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = []gin.Param{{Key: "name", Value: tt.param}}
+
+			h.PutTypeSchema(c)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
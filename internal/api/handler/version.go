@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"segmentation-api/internal/buildinfo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SchemaVersionFunc returns the applied schema migration version and the
+// number of migrations of the binary still pending
+type SchemaVersionFunc func(ctx context.Context) (version int64, pending int, err error)
+
+// VersionHandler reports what exactly is running
+type VersionHandler struct {
+	info   buildinfo.Info
+	schema SchemaVersionFunc
+}
+
+// NewVersionHandler creates a new version handler; schema may be nil when
+// the database is not available
+func NewVersionHandler(info buildinfo.Info, schema SchemaVersionFunc) *VersionHandler {
+	return &VersionHandler{info: info, schema: schema}
+}
+
+// schemaInfo is the schema part of the version response
+type schemaInfo struct {
+	Version int64  `json:"version"`
+	Pending int    `json:"pending"`
+	Error   string `json:"error,omitempty"`
+}
+
+// versionResponse is the body of GET /version
+type versionResponse struct {
+	buildinfo.Info
+	Schema *schemaInfo `json:"schema,omitempty"`
+}
+
+// Version returns the build info and the schema version. A failure to read
+// the schema is reported in the body and still answers 200: the build info
+// is what operators ask for.
+// GET /version
+// @Summary		Build and schema version
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	handler.versionResponse
+// @Router			/version [get]
+func (h *VersionHandler) Version(c *gin.Context) {
+	resp := versionResponse{Info: h.info}
+	if h.schema != nil {
+		version, pending, err := h.schema(c.Request.Context())
+		resp.Schema = &schemaInfo{Version: version, Pending: pending}
+		if err != nil {
+			resp.Schema.Error = err.Error()
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
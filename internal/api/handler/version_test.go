@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"segmentation-api/internal/buildinfo"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	info := buildinfo.Info{Version: "1.4.0", Commit: "abc123", BuildDate: "2026-10-16T12:00:00Z", GoVersion: "go1.25.0"}
+
+	tests := []struct {
+		name   string
+		schema SchemaVersionFunc
+		want   string
+	}{
+		{
+			name: "no schema",
+			want: `{"version":"1.4.0","commit":"abc123","build_date":"2026-10-16T12:00:00Z","go_version":"go1.25.0"}`,
+		},
+		{
+			name: "schema",
+			schema: func(ctx context.Context) (int64, int, error) {
+				return 20261016000100, 1, nil
+			},
+			want: `{"version":"1.4.0","commit":"abc123","build_date":"2026-10-16T12:00:00Z","go_version":"go1.25.0",` +
+				`"schema":{"version":20261016000100,"pending":1}}`,
+		},
+		{
+			name: "schema error",
+			schema: func(ctx context.Context) (int64, int, error) {
+				return 0, 0, errors.New("connection refused")
+			},
+			want: `{"version":"1.4.0","commit":"abc123","build_date":"2026-10-16T12:00:00Z","go_version":"go1.25.0",` +
+				`"schema":{"version":0,"pending":0,"error":"connection refused"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/version", NewVersionHandler(info, tt.schema).Version)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/version", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			var got, want any
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			json.Unmarshal([]byte(tt.want), &want)
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("body = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
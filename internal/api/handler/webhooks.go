@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = 1000
+)
+
+// WebhookHandler handles the webhook subscription endpoints
+type WebhookHandler struct {
+	webhooks *service.Webhooks
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhooks *service.Webhooks) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks}
+}
+
+// ListWebhooks returns every webhook
+// GET /webhooks
+// @Summary		List webhooks
+// @Tags			webhooks
+// @Produce		json
+// @Security		AdminToken
+// @Success		200	{object}	object{webhooks=[]service.Webhook}
+// @Failure		401	{object}	handler.ErrorResponse
+// @Router			/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	hooks, err := h.webhooks.List(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": hooks,
+	})
+}
+
+// GetWebhook returns a single webhook
+// GET /webhooks/:id
+// @Summary		Get a webhook
+// @Tags			webhooks
+// @Produce		json
+// @Security		AdminToken
+// @Param			id	path		integer	true	"Webhook ID"
+// @Success		200	{object}	service.Webhook
+// @Failure		400	{object}	handler.ErrorResponse	"Invalid ID"
+// @Failure		401	{object}	handler.ErrorResponse
+// @Failure		404	{object}	handler.ErrorResponse	"Unknown webhook"
+// @Router			/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+
+	w, err := h.webhooks.Get(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+// CreateWebhook registers a webhook; the answer carries its secret, which
+// is not shown again
+// POST /webhooks
+// @Summary		Register a webhook
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			request	body		service.WebhookRequest	true	"Webhook to register"
+// @Success		201		{object}	service.Webhook
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid body, URL, secret or event type"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Router			/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req service.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	w, err := h.webhooks.Create(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Location", "/webhooks/"+strconv.FormatUint(w.ID, 10))
+	c.JSON(http.StatusCreated, w)
+}
+
+// UpdateWebhook partially updates a webhook; an empty secret generates a
+// new one, shown in the answer
+// PATCH /webhooks/:id
+// @Summary		Update a webhook
+// @Tags			webhooks
+// @Accept			json
+// @Produce		json
+// @Security		AdminToken
+// @Param			id		path		integer					true	"Webhook ID"
+// @Param			request	body		service.WebhookPatch	true	"Fields to change"
+// @Success		200		{object}	service.Webhook
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid ID, body, URL, secret or event type"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown webhook"
+// @Router			/webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	var patch service.WebhookPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	w, err := h.webhooks.Update(c.Request.Context(), id, patch)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+// DeleteWebhook removes a webhook and its deliveries
+// DELETE /webhooks/:id
+// @Summary		Delete a webhook
+// @Tags			webhooks
+// @Security		AdminToken
+// @Param			id	path	integer	true	"Webhook ID"
+// @Success		204
+// @Failure		400	{object}	handler.ErrorResponse	"Invalid ID"
+// @Failure		401	{object}	handler.ErrorResponse
+// @Failure		404	{object}	handler.ErrorResponse	"Unknown webhook"
+// @Router			/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.webhooks.Delete(c.Request.Context(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns the deliveries of a webhook, most recent first,
+// with the status, attempts and last error of each. A full page carries
+// next_before, the before of the next page.
+// GET /webhooks/:id/deliveries
+// @Summary		List the deliveries of a webhook
+// @Tags			webhooks
+// @Produce		json
+// @Security		AdminToken
+// @Param			id		path		integer	true	"Webhook ID"
+// @Param			status	query		string	false	"Delivery status"	Enums(pending, delivered, failed)
+// @Param			before	query		integer	false	"Only deliveries with a smaller ID"
+// @Param			limit	query		integer	false	"Page size"	minimum(1)	maximum(1000)	default(100)
+// @Success		200		{object}	object{deliveries=[]models.WebhookDelivery,next_before=integer,links=handler.Links,meta=handler.Meta}
+// @Header			200		{string}	Link	"The next page"
+// @Failure		400		{object}	handler.ErrorResponse	"Invalid ID, status, before or limit"
+// @Failure		401		{object}	handler.ErrorResponse
+// @Failure		404		{object}	handler.ErrorResponse	"Unknown webhook"
+// @Router			/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	filter := repository.DeliveryFilter{
+		WebhookID: id,
+		Status:    c.Query("status"),
+		Limit:     defaultDeliveryLimit,
+	}
+	if v := c.Query("before"); v != "" {
+		before, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || before == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "before must be a positive delivery ID",
+			})
+			return
+		}
+		filter.BeforeID = before
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxDeliveryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxDeliveryLimit),
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	deliveries, err := h.webhooks.Deliveries(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	links := selfLinks(c)
+	body := gin.H{"deliveries": deliveries}
+	if len(deliveries) == filter.Limit {
+		next := deliveries[len(deliveries)-1].ID
+		body["next_before"] = next
+		links.Next = pageLink(c, map[string]string{"before": strconv.FormatUint(next, 10)})
+	}
+	body["links"] = links
+	body["meta"] = Meta{Returned: len(deliveries)}
+	setLinks(c, links)
+	c.JSON(http.StatusOK, body)
+}
+
+// RetryDelivery schedules a failed delivery again right away, with a fresh
+// set of attempts
+// POST /webhooks/:id/deliveries/:delivery_id/retry
+// @Summary		Retry a failed delivery
+// @Tags			webhooks
+// @Produce		json
+// @Security		AdminToken
+// @Param			id			path		integer	true	"Webhook ID"
+// @Param			delivery_id	path		integer	true	"Delivery ID"
+// @Success		200			{object}	models.WebhookDelivery
+// @Failure		400			{object}	handler.ErrorResponse	"Invalid ID"
+// @Failure		401			{object}	handler.ErrorResponse
+// @Failure		404			{object}	handler.ErrorResponse	"Unknown delivery"
+// @Failure		409			{object}	handler.ErrorResponse	"The delivery has not failed"
+// @Router			/webhooks/{id}/deliveries/{delivery_id}/retry [post]
+func (h *WebhookHandler) RetryDelivery(c *gin.Context) {
+	id, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := idParam(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	d, err := h.webhooks.Retry(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
+
+// idParam parses a positive numeric path parameter, answering 400 when it
+// is not one
+func idParam(c *gin.Context, name string) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid " + name,
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockWebhooks keeps webhooks and deliveries in memory
+type mockWebhooks struct {
+	hooks      map[uint64]models.Webhook
+	deliveries []models.WebhookDelivery
+	filter     repository.DeliveryFilter
+}
+
+func (m *mockWebhooks) List(ctx context.Context) ([]models.Webhook, error) {
+	var out []models.Webhook
+	for _, w := range m.hooks {
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+func (m *mockWebhooks) Find(ctx context.Context, id uint64) (*models.Webhook, error) {
+	w, ok := m.hooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return &w, nil
+}
+
+func (m *mockWebhooks) Create(ctx context.Context, w *models.Webhook) error {
+	w.ID = uint64(len(m.hooks) + 1)
+	m.hooks[w.ID] = *w
+	return nil
+}
+
+func (m *mockWebhooks) Update(ctx context.Context, w *models.Webhook) error {
+	m.hooks[w.ID] = *w
+	return nil
+}
+
+func (m *mockWebhooks) Delete(ctx context.Context, id uint64) (bool, error) {
+	_, ok := m.hooks[id]
+	delete(m.hooks, id)
+	return ok, nil
+}
+
+func (m *mockWebhooks) Enqueue(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	return nil
+}
+
+func (m *mockWebhooks) Due(ctx context.Context, now int64, limit int) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *mockWebhooks) Deliveries(ctx context.Context, filter repository.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	m.filter = filter
+	return m.deliveries, nil
+}
+
+func (m *mockWebhooks) FindDelivery(ctx context.Context, webhookID, id uint64) (*models.WebhookDelivery, error) {
+	for _, d := range m.deliveries {
+		if d.ID == id && d.WebhookID == webhookID {
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockWebhooks) SaveDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == d.ID {
+			m.deliveries[i] = *d
+		}
+	}
+	return nil
+}
+
+func (m *mockWebhooks) DeleteFinished(ctx context.Context, before int64) (int64, error) {
+	return 0, nil
+}
+
+func newWebhookTestRouter(repo *mockWebhooks) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewWebhookHandler(service.NewWebhooks(repo))
+	r := gin.New()
+	r.GET("/webhooks", h.ListWebhooks)
+	r.POST("/webhooks", h.CreateWebhook)
+	r.GET("/webhooks/:id", h.GetWebhook)
+	r.PATCH("/webhooks/:id", h.UpdateWebhook)
+	r.DELETE("/webhooks/:id", h.DeleteWebhook)
+	r.GET("/webhooks/:id/deliveries", h.ListDeliveries)
+	r.POST("/webhooks/:id/deliveries/:delivery_id/retry", h.RetryDelivery)
+	return r
+}
+
+func doWebhookRequest(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebhookHandler_CRUD(t *testing.T) {
+	repo := &mockWebhooks{hooks: map[uint64]models.Webhook{}}
+	r := newWebhookTestRouter(repo)
+
+	w := doWebhookRequest(r, "POST", "/webhooks", `{"url": "https://crm.example.com/hooks", "event_types": ["segmentation.deleted"]}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/webhooks/1" {
+		t.Fatalf("expected 201 at /webhooks/1, got %d %q: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	var created service.Webhook
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Secret == "" || created.Secret != repo.hooks[1].Secret || len(created.EventTypes) != 1 || !created.Active {
+		t.Errorf("unexpected webhook %+v", created)
+	}
+
+	w = doWebhookRequest(r, "GET", "/webhooks/1", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("GET should not show the secret, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doWebhookRequest(r, "PATCH", "/webhooks/1", `{"active": false, "event_types": []}`)
+	var updated service.Webhook
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.Active || len(updated.EventTypes) != 2 || updated.Secret != "" {
+		t.Errorf("unexpected update %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := doWebhookRequest(r, "POST", "/webhooks", `{"url": "ftp://crm.example.com"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("an ftp URL should be refused, got %d", w.Code)
+	}
+	if w := doWebhookRequest(r, "GET", "/webhooks/abc", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("a non-numeric ID should be refused, got %d", w.Code)
+	}
+
+	if w := doWebhookRequest(r, "DELETE", "/webhooks/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", w.Code)
+	}
+	if w := doWebhookRequest(r, "DELETE", "/webhooks/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", w.Code)
+	}
+}
+
+func TestWebhookHandler_Deliveries(t *testing.T) {
+	repo := &mockWebhooks{
+		hooks: map[uint64]models.Webhook{1: {ID: 1, URL: "https://crm.example.com/hooks", Active: true}},
+		deliveries: []models.WebhookDelivery{
+			{ID: 8, WebhookID: 1, Status: models.DeliveryFailed, Attempts: 10, LastStatusCode: 500, LastError: "webhook answered 500"},
+			{ID: 7, WebhookID: 1, Status: models.DeliveryDelivered, Attempts: 1},
+		},
+	}
+	r := newWebhookTestRouter(repo)
+
+	w := doWebhookRequest(r, "GET", "/webhooks/1/deliveries?status=failed&before=9&limit=2", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	want := repository.DeliveryFilter{WebhookID: 1, Status: "failed", BeforeID: 9, Limit: 2}
+	if repo.filter != want {
+		t.Errorf("filter = %+v, want %+v", repo.filter, want)
+	}
+	var resp struct {
+		Deliveries []models.WebhookDelivery `json:"deliveries"`
+		NextBefore uint64                   `json:"next_before"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Deliveries) != 2 || resp.Deliveries[0].LastError != "webhook answered 500" || resp.NextBefore != 7 {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	for _, path := range []string{"/webhooks/1/deliveries?status=lost", "/webhooks/1/deliveries?limit=0", "/webhooks/1/deliveries?before=x"} {
+		if w := doWebhookRequest(r, "GET", path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, w.Code)
+		}
+	}
+	if w := doWebhookRequest(r, "GET", "/webhooks/2/deliveries", ""); w.Code != http.StatusNotFound {
+		t.Errorf("deliveries of an unknown webhook = %d, want 404", w.Code)
+	}
+
+	w = doWebhookRequest(r, "POST", "/webhooks/1/deliveries/8/retry", "")
+	if w.Code != http.StatusOK || repo.deliveries[0].Status != models.DeliveryPending || repo.deliveries[0].Attempts != 0 {
+		t.Errorf("retry = %d, delivery %+v", w.Code, repo.deliveries[0])
+	}
+	if w := doWebhookRequest(r, "POST", "/webhooks/1/deliveries/7/retry", ""); w.Code != http.StatusConflict {
+		t.Errorf("retrying a delivered delivery = %d, want 409", w.Code)
+	}
+	if w := doWebhookRequest(r, "POST", "/webhooks/1/deliveries/9/retry", ""); w.Code != http.StatusNotFound {
+		t.Errorf("retrying an unknown delivery = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/service"
+	"segmentation-api/internal/writequeue"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateUserSegmentation_Queued(t *testing.T) {
+	q, err := writequeue.Open(t.TempDir(), writequeue.WithMaxPending(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	h := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}), WithWriteQueue(q))
+
+	post := func() *httptest.ResponseRecorder {
+		body := `{"segmentation_type": "drug", "segmentation_name": "Aspirina", "data": {"dose": "500mg"}}`
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/users/123/segmentations", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = []gin.Param{{Key: "user_id", Value: "123"}}
+		h.CreateUserSegmentation(c)
+		return w
+	}
+
+	w := post()
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp service.QueuedResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ID == "" || resp.State != writequeue.StateQueued || resp.Items != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if loc := w.Header().Get("Location"); loc != "/writes/"+resp.ID {
+		t.Errorf("Location = %q", loc)
+	}
+
+	// the queue holds one pending write at most
+	if w := post(); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("full queue: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	for id, want := range map[string]int{resp.ID: http.StatusOK, "unknown": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/writes/"+id, nil)
+		c.Params = []gin.Param{{Key: "id", Value: id}}
+		h.GetWrite(c)
+		if w.Code != want {
+			t.Errorf("GET /writes/%s: status %d, want %d", id, w.Code, want)
+		}
+	}
+}
+
+func TestBulkUpsertSegmentations_Queued(t *testing.T) {
+	q, err := writequeue.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	h := NewSegmentationHandler(service.NewSegmentationService(&MockRepository{}), WithWriteQueue(q))
+
+	body := `{"items": [
+		{"user_id": 1, "segmentation_type": "drug", "segmentation_name": "Aspirina"},
+		{"user_id": 2, "segmentation_type": "specialty", "segmentation_name": ""}
+	]}`
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/segmentations/bulk", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.BulkUpsertSegmentations(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp service.QueuedResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Items != 1 || resp.Rejected != 1 || q.Pending() != 1 {
+		t.Fatalf("unexpected response: %+v (pending %d)", resp, q.Pending())
+	}
+}
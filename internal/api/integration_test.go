@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -27,6 +28,10 @@ func (m *IntegrationMockRepository) FindByUserID(ctx context.Context, userID uin
 	return nil, nil
 }
 
+func (m *IntegrationMockRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func (m *IntegrationMockRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
 	if m.upsertFunc != nil {
 		return m.upsertFunc(ctx, s)
@@ -34,6 +39,18 @@ func (m *IntegrationMockRepository) Upsert(ctx context.Context, s *models.Segmen
 	return repository.UpsertInserted, nil
 }
 
+func (m *IntegrationMockRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *IntegrationMockRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *IntegrationMockRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
 // TestIntegration_HealthEndpoint tests health check through full stack
 func TestIntegration_HealthEndpoint(t *testing.T) {
 	mockRepo := &IntegrationMockRepository{}
@@ -209,7 +226,7 @@ func TestIntegration_DataTransformationFlow(t *testing.T) {
 func TestIntegration_ErrorPropagation(t *testing.T) {
 	mockRepo := &IntegrationMockRepository{
 		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
-			return nil, context.DeadlineExceeded
+			return nil, errors.New("connection lost")
 		},
 	}
 
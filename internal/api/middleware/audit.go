@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ActorKey is the context key holding the authenticated caller; it is
+	// set by the auth middlewares and read by Audit
+	ActorKey = "actor"
+
+	// AuditAuthFailed is the action recorded for refused credentials
+	AuditAuthFailed = "auth_failed"
+
+	anonymousActor = "anonymous"
+)
+
+// Audit writes security-relevant requests to the audit log once they have
+// been handled: failed authentications (401 and 403) and every request
+// that is not a read. action names the event; when empty it is built from
+// the method and route, e.g. "PATCH /admin/segmentation-types/:name".
+// Place it before the auth middleware so refused requests are seen too.
+func Audit(store repository.AuditLogRepository, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		event := action
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			event = AuditAuthFailed
+		case c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead:
+			return
+		case event == "":
+			event = c.Request.Method + " " + c.FullPath()
+		}
+
+		actor := c.GetString(ActorKey)
+		if actor == "" {
+			actor = anonymousActor
+		}
+
+		// the entry is kept even if the client has already gone away
+		err := store.Insert(context.WithoutCancel(c.Request.Context()), &models.AuditLog{
+			Actor:     actor,
+			Action:    event,
+			Target:    c.Request.URL.Path,
+			Status:    status,
+			ClientIP:  c.ClientIP(),
+			CreatedAt: models.Timestamp(time.Now().Unix()),
+		})
+		if err != nil {
+			c.Error(err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type memoryAuditLog struct {
+	mu      sync.Mutex
+	entries []models.AuditLog
+}
+
+func (m *memoryAuditLog) Insert(ctx context.Context, entry *models.AuditLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *memoryAuditLog) List(ctx context.Context, filter repository.AuditFilter) ([]models.AuditLog, error) {
+	return m.entries, nil
+}
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		action string
+		want   *models.AuditLog
+	}{
+		{
+			name:   "auth failure",
+			method: "GET",
+			token:  "wrong",
+			want:   &models.AuditLog{Actor: "anonymous", Action: AuditAuthFailed, Status: http.StatusUnauthorized},
+		},
+		{
+			name:   "admin read is not recorded",
+			method: "GET",
+			token:  "s3cret",
+		},
+		{
+			name:   "admin action",
+			method: "PATCH",
+			token:  "s3cret",
+			want:   &models.AuditLog{Actor: "admin", Action: "PATCH /admin/segmentation-types/:name", Status: http.StatusOK},
+		},
+		{
+			name:   "named action",
+			method: "PATCH",
+			token:  "s3cret",
+			action: "types.update",
+			want:   &models.AuditLog{Actor: "admin", Action: "types.update", Status: http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryAuditLog{}
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			admin := r.Group("/admin", Audit(store, tt.action), AdminAuth("s3cret"))
+			admin.Handle(tt.method, "/segmentation-types/:name", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, "/admin/segmentation-types/drug", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.want == nil {
+				if len(store.entries) != 0 {
+					t.Fatalf("expected no audit entries, got %+v", store.entries)
+				}
+				return
+			}
+			if len(store.entries) != 1 {
+				t.Fatalf("expected 1 audit entry, got %d", len(store.entries))
+			}
+			got := store.entries[0]
+			if got.Actor != tt.want.Actor || got.Action != tt.want.Action || got.Status != tt.want.Status {
+				t.Errorf("entry = %+v, want actor %q action %q status %d", got, tt.want.Actor, tt.want.Action, tt.want.Status)
+			}
+			if got.Target != "/admin/segmentation-types/drug" || got.CreatedAt == 0 {
+				t.Errorf("unexpected target or timestamp: %+v", got)
+			}
+		})
+	}
+}
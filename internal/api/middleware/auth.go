@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth protects admin routes with a static bearer token. When token is
+// empty every request is refused, so the admin API is disabled unless
+// explicitly configured. Authenticated requests carry the "admin" actor.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "admin API disabled",
+			})
+			return
+		}
+
+		auth := c.GetHeader("Authorization")
+		given, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid admin credentials",
+			})
+			return
+		}
+
+		c.Set(ActorKey, "admin")
+		c.Next()
+	}
+}
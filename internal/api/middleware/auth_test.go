@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAdminRouter(token string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin/ping", AdminAuth(token), func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return r
+}
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		header   string
+		expected int
+	}{
+		{name: "valid token", token: "s3cret", header: "Bearer s3cret", expected: http.StatusOK},
+		{name: "wrong token", token: "s3cret", header: "Bearer nope", expected: http.StatusUnauthorized},
+		{name: "missing header", token: "s3cret", header: "", expected: http.StatusUnauthorized},
+		{name: "wrong scheme", token: "s3cret", header: "Basic s3cret", expected: http.StatusUnauthorized},
+		{name: "admin disabled", token: "", header: "Bearer ", expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/ping", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			newAdminRouter(tt.token).ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
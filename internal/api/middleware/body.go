@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireJSON refuses requests with a body whose Content-Type is not
+// application/json with 415. Requests without a body, such as a DELETE or
+// a POST that takes no input, pass through.
+func RequireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength == 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "request body must be application/json",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitBody refuses request bodies larger than maxBytes with 413: at once
+// when Content-Length says so, otherwise when a reader of the body goes
+// past the limit (see AbortBodyTooLarge). 0 disables the limit.
+func LimitBody(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, bodyTooLarge(maxBytes))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// AbortBodyTooLarge answers 413 and returns true when err comes from
+// reading a body past the limit of LimitBody
+func AbortBodyTooLarge(c *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, bodyTooLarge(tooLarge.Limit))
+	return true
+}
+
+func bodyTooLarge(maxBytes int64) gin.H {
+	return gin.H{
+		"error":     "request body too large",
+		"max_bytes": maxBytes,
+	}
+}
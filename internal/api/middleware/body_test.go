@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/data", RequireJSON(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		contentType string
+		body        string
+		want        int
+	}{
+		{contentType: "application/json", body: `{}`, want: http.StatusOK},
+		{contentType: "application/json; charset=utf-8", body: `{}`, want: http.StatusOK},
+		{contentType: "text/plain", body: `{}`, want: http.StatusUnsupportedMediaType},
+		{contentType: "application/x-www-form-urlencoded", body: `a=1`, want: http.StatusUnsupportedMediaType},
+		{contentType: "", body: `{}`, want: http.StatusUnsupportedMediaType},
+		{contentType: "", body: "", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/data", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%q with %q = %d, want %d", tt.contentType, tt.body, w.Code, tt.want)
+		}
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/data", LimitBody(8), func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			if !AbortBodyTooLarge(c, err) {
+				c.Status(http.StatusBadRequest)
+			}
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	send := func(body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/data", body))
+		return w
+	}
+
+	if w := send(strings.NewReader("12345678")); w.Code != http.StatusOK {
+		t.Errorf("body at the limit = %d, want 200", w.Code)
+	}
+	if w := send(strings.NewReader("123456789")); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Content-Length over the limit = %d, want 413", w.Code)
+	}
+	// no Content-Length: the limit is hit while reading
+	if w := send(io.MultiReader(strings.NewReader("123456789"))); w.Code != http.StatusRequestEntityTooLarge ||
+		!strings.Contains(w.Body.String(), `"max_bytes":8`) {
+		t.Errorf("streamed body over the limit = %d %s, want 413", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyHeader is the request header carrying the client supplied key
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader marks responses served from the key store
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotency replays the stored response of a previous request carrying the
+// same Idempotency-Key header instead of executing the handler again.
+// Requests without the header pass through untouched. Server errors (5xx)
+// are not stored so clients can retry them.
+func Idempotency(store repository.IdempotencyRepository, ttl time.Duration) gin.HandlerFunc {
+	var inflight sync.Map
+
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if AbortBodyTooLarge(c, err) {
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "could not read request body",
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fp := fingerprint(c.Request.Method, c.Request.URL.Path, body)
+		ctx := c.Request.Context()
+
+		stored, err := store.Find(ctx, key, time.Now().Unix())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if stored != nil {
+			if stored.Fingerprint != fp {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+					"error": "Idempotency-Key was already used for a different request",
+				})
+				return
+			}
+			c.Header(IdempotencyReplayedHeader, "true")
+			c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		if _, busy := inflight.LoadOrStore(key, struct{}{}); busy {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "a request with this Idempotency-Key is already in progress",
+			})
+			return
+		}
+		defer inflight.Delete(key)
+
+		rec := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+
+		c.Next()
+
+		status := rec.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		// the user is recorded so that erasing the user's data also removes
+		// the stored responses, which carry it
+		userID, _ := strconv.ParseUint(c.Param("user_id"), 10, 64)
+
+		now := time.Now()
+		err = store.Save(ctx, &models.IdempotencyKey{
+			Key:         key,
+			Fingerprint: fp,
+			StatusCode:  status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+			CreatedAt:   now.Unix(),
+			ExpiresAt:   now.Add(ttl).Unix(),
+			UserID:      userID,
+		})
+		if err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// fingerprint identifies a request by method, path and body so a key reused
+// for a different payload can be detected
+func fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyRecorder tees everything written to the client into a buffer
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
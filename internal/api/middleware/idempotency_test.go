@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]models.IdempotencyKey
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{keys: make(map[string]models.IdempotencyKey)}
+}
+
+func (m *memoryIdempotencyStore) Find(ctx context.Context, key string, now int64) (*models.IdempotencyKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[key]
+	if !ok || k.ExpiresAt <= now {
+		return nil, nil
+	}
+	return &k, nil
+}
+
+func (m *memoryIdempotencyStore) Save(ctx context.Context, k *models.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[k.Key] = *k
+	return nil
+}
+
+func (m *memoryIdempotencyStore) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	return 0, nil
+}
+
+func newIdempotentRouter(store *memoryIdempotencyStore, status int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/items", Idempotency(store, time.Hour), func(c *gin.Context) {
+		*calls++
+		c.JSON(status, gin.H{"call": *calls})
+	})
+	return r
+}
+
+func doPost(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusCreated, &calls)
+
+	first := doPost(r, "abc", `{"a":1}`)
+	second := doPost(r, "abc", `{"a":1}`)
+
+	if calls != 1 {
+		t.Fatalf("handler should run once, ran %d times", calls)
+	}
+	if second.Code != http.StatusCreated {
+		t.Errorf("replayed status = %d, want 201", second.Code)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("replayed body = %s, want %s", second.Body.String(), first.Body.String())
+	}
+	if second.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Error("replayed response should be marked")
+	}
+}
+
+func TestIdempotency_WithoutHeaderPassesThrough(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusOK, &calls)
+
+	doPost(r, "", `{}`)
+	doPost(r, "", `{}`)
+
+	if calls != 2 {
+		t.Fatalf("handler should run for every request without a key, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_RejectsKeyReuseWithDifferentBody(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusOK, &calls)
+
+	doPost(r, "abc", `{"a":1}`)
+	w := doPost(r, "abc", `{"a":2}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("handler should not run for a mismatched replay")
+	}
+}
+
+func TestIdempotency_ServerErrorsAreNotStored(t *testing.T) {
+	calls := 0
+	store := newMemoryIdempotencyStore()
+	r := newIdempotentRouter(store, http.StatusInternalServerError, &calls)
+
+	doPost(r, "abc", `{}`)
+	doPost(r, "abc", `{}`)
+
+	if calls != 2 {
+		t.Fatalf("5xx responses should allow retries, handler ran %d times", calls)
+	}
+	if len(store.keys) != 0 {
+		t.Error("5xx responses should not be stored")
+	}
+}
+
+func TestIdempotency_ExpiredKeyRunsAgain(t *testing.T) {
+	calls := 0
+	store := newMemoryIdempotencyStore()
+	store.keys["abc"] = models.IdempotencyKey{
+		Key:         "abc",
+		Fingerprint: fingerprint("POST", "/items", []byte(`{}`)),
+		StatusCode:  http.StatusOK,
+		ExpiresAt:   time.Now().Add(-time.Minute).Unix(),
+	}
+	r := newIdempotentRouter(store, http.StatusOK, &calls)
+
+	doPost(r, "abc", `{}`)
+
+	if calls != 1 {
+		t.Fatalf("expired keys should not be replayed")
+	}
+}
+
+func TestIdempotency_RecordsUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newMemoryIdempotencyStore()
+	r := gin.New()
+	r.POST("/users/:user_id/items", Idempotency(store, time.Hour), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	req := httptest.NewRequest("POST", "/users/42/items", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyHeader, "abc")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := store.keys["abc"].UserID; got != 42 {
+		t.Errorf("stored user_id = %d, want 42", got)
+	}
+
+	calls := 0
+	doPost(newIdempotentRouter(store, http.StatusOK, &calls), "def", `{}`)
+	if got := store.keys["def"].UserID; got != 0 {
+		t.Errorf("a route without :user_id stored user_id %d", got)
+	}
+}
+
+func TestIdempotency_KeyTooLong(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(newMemoryIdempotencyStore(), http.StatusOK, &calls)
+
+	w := doPost(r, strings.Repeat("k", 256), `{}`)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := fingerprint("POST", "/a", []byte("x"))
+	if a != fingerprint("POST", "/a", []byte("x")) {
+		t.Error("fingerprint should be deterministic")
+	}
+	if a == fingerprint("POST", "/b", []byte("x")) {
+		t.Error("fingerprint should depend on the path")
+	}
+	if a == fingerprint("PUT", "/a", []byte("x")) {
+		t.Error("fingerprint should depend on the method")
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestLogger writes one structured entry per request. Server errors are
+// logged at error level, client errors at warn and everything else at info;
+// errors attached by handlers with c.Error are included.
+func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypeAny); len(errs) > 0 {
+			fields = append(fields, zap.Strings("errors", errs.Errors()))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			logger.Error("http_request", fields...)
+		case status >= http.StatusBadRequest:
+			logger.Warn("http_request", fields...)
+		default:
+			logger.Info("http_request", fields...)
+		}
+	}
+}
+
+// Recovery turns panics into 500 responses and logs them with a stack trace
+func Recovery(logger *zap.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		logger.Error("panic_recovered",
+			zap.Any("panic", err),
+			zap.String("path", c.Request.URL.Path),
+			zap.Stack("stack"),
+		)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLogger_Levels(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		level  zapcore.Level
+	}{
+		{name: "success", status: http.StatusOK, level: zapcore.InfoLevel},
+		{name: "client error", status: http.StatusBadRequest, level: zapcore.WarnLevel},
+		{name: "server error", status: http.StatusInternalServerError, err: errors.New("db down"), level: zapcore.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(RequestLogger(zap.New(core)))
+			r.GET("/users/:user_id", func(c *gin.Context) {
+				if tt.err != nil {
+					c.Error(tt.err)
+				}
+				c.Status(tt.status)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 log entry, got %d", len(entries))
+			}
+			entry := entries[0]
+			if entry.Level != tt.level {
+				t.Errorf("level = %s, want %s", entry.Level, tt.level)
+			}
+
+			fields := entry.ContextMap()
+			if fields["route"] != "/users/:user_id" || fields["path"] != "/users/42" {
+				t.Errorf("unexpected route/path fields: %v", fields)
+			}
+			if fields["status"] != int64(tt.status) {
+				t.Errorf("status field = %v, want %d", fields["status"], tt.status)
+			}
+			if _, ok := fields["errors"]; ok != (tt.err != nil) {
+				t.Errorf("errors field present = %v, want %v", ok, tt.err != nil)
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Recovery(zap.New(core)))
+	r.GET("/boom", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", w.Code)
+	}
+	if logs.FilterMessage("panic_recovered").Len() != 1 {
+		t.Error("expected panic_recovered to be logged")
+	}
+}
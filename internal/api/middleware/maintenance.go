@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMode selects which requests are refused during maintenance
+type MaintenanceMode string
+
+const (
+	MaintenanceOff MaintenanceMode = "off"
+	// MaintenanceReadOnly refuses writes while reads continue, e.g. during
+	// a schema migration or a large backfill
+	MaintenanceReadOnly MaintenanceMode = "read_only"
+	// MaintenanceWriteOnly refuses reads while writes continue
+	MaintenanceWriteOnly MaintenanceMode = "write_only"
+)
+
+// DefaultMaintenanceMessage is returned when no message is set
+const DefaultMaintenanceMessage = "the service is under maintenance, try again later"
+
+// ParseMaintenanceMode parses off, read_only or write_only
+func ParseMaintenanceMode(s string) (MaintenanceMode, error) {
+	switch mode := MaintenanceMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case MaintenanceOff, MaintenanceReadOnly, MaintenanceWriteOnly:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid maintenance mode %q: must be off, read_only or write_only", s)
+	}
+}
+
+// MaintenanceState is the current maintenance mode and the message sent
+// with refused requests; Since is zero while the mode is off
+type MaintenanceState struct {
+	Mode    MaintenanceMode `json:"mode"`
+	Message string          `json:"message"`
+	Since   time.Time       `json:"since,omitzero"`
+}
+
+// Maintenance holds the maintenance state of the API. It is read on every
+// request and changed at runtime through the admin API.
+type Maintenance struct {
+	mu    sync.RWMutex
+	state MaintenanceState
+}
+
+// NewMaintenance creates the state with the mode set at startup
+func NewMaintenance(mode MaintenanceMode, message string) *Maintenance {
+	m := &Maintenance{}
+	m.Set(mode, message)
+	return m
+}
+
+// State returns the current state
+func (m *Maintenance) State() MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// Set changes the mode; an empty message uses DefaultMaintenanceMessage
+func (m *Maintenance) Set(mode MaintenanceMode, message string) MaintenanceState {
+	if message == "" {
+		message = DefaultMaintenanceMessage
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	since := m.state.Since
+	switch {
+	case mode == MaintenanceOff:
+		since = time.Time{}
+	case mode != m.state.Mode:
+		since = time.Now().UTC()
+	}
+	m.state = MaintenanceState{Mode: mode, Message: message, Since: since}
+	return m.state
+}
+
+// refuses reports whether the current mode refuses writes, or reads when
+// write is false
+func (m *Maintenance) refuses(write bool) (MaintenanceState, bool) {
+	state := m.State()
+	if write {
+		return state, state.Mode == MaintenanceReadOnly
+	}
+	return state, state.Mode == MaintenanceWriteOnly
+}
+
+// MaintenanceGuard answers 503 with the maintenance message while m
+// refuses the requests of the route, writes when write is set and reads
+// otherwise
+func MaintenanceGuard(m *Maintenance, write bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if state, refused := m.refuses(write); refused {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "service under maintenance",
+				"message": state.Message,
+				"mode":    state.Mode,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenanceGuard(t *testing.T) {
+	tests := []struct {
+		mode  MaintenanceMode
+		read  int
+		write int
+	}{
+		{mode: MaintenanceOff, read: http.StatusOK, write: http.StatusOK},
+		{mode: MaintenanceReadOnly, read: http.StatusOK, write: http.StatusServiceUnavailable},
+		{mode: MaintenanceWriteOnly, read: http.StatusServiceUnavailable, write: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			m := NewMaintenance(tt.mode, "")
+			r := gin.New()
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			r.GET("/data", MaintenanceGuard(m, false), ok)
+			r.POST("/data", MaintenanceGuard(m, true), ok)
+
+			for method, want := range map[string]int{"GET": tt.read, "POST": tt.write} {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(method, "/data", nil))
+				if w.Code != want {
+					t.Errorf("%s = %d, want %d", method, w.Code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMaintenance_Set(t *testing.T) {
+	m := NewMaintenance(MaintenanceOff, "")
+	if s := m.State(); s.Message != DefaultMaintenanceMessage || !s.Since.IsZero() {
+		t.Errorf("initial state = %+v", s)
+	}
+
+	s := m.Set(MaintenanceReadOnly, "migrating")
+	if s.Mode != MaintenanceReadOnly || s.Message != "migrating" || s.Since.IsZero() {
+		t.Fatalf("state = %+v", s)
+	}
+	if again := m.Set(MaintenanceReadOnly, "still migrating"); !again.Since.Equal(s.Since) {
+		t.Error("changing only the message should keep Since")
+	}
+	if off := m.Set(MaintenanceOff, ""); !off.Since.IsZero() {
+		t.Errorf("Since = %s, want zero once off", off.Since)
+	}
+}
+
+func TestParseMaintenanceMode(t *testing.T) {
+	if mode, err := ParseMaintenanceMode(" Read_Only "); err != nil || mode != MaintenanceReadOnly {
+		t.Errorf("ParseMaintenanceMode() = %q, %v", mode, err)
+	}
+	if _, err := ParseMaintenanceMode("readonly"); err == nil {
+		t.Error("ParseMaintenanceMode() should reject unknown modes")
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"segmentation-api/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the latency of every request by route template (not the
+// raw path, which would create a series per user) and status class. A
+// panicking handler is counted as a 500 before the panic reaches the
+// recovery middleware.
+func Metrics(m *metrics.HTTPMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := m.Start()
+		defer func() {
+			if p := recover(); p != nil {
+				done(c.Request.Method, c.FullPath(), http.StatusInternalServerError)
+				panic(p)
+			}
+			done(c.Request.Method, c.FullPath(), c.Writer.Status())
+		}()
+		c.Next()
+	}
+}
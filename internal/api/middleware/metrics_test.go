@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_LabelsByRouteAndStatusClass(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery(), Metrics(metrics.NewHTTPMetrics(reg)))
+	r.GET("/users/:user_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	for _, path := range []string{"/users/1", "/users/2", "/panic", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	expected := `
+# HELP segmentation_http_requests_in_flight HTTP requests currently being served.
+# TYPE segmentation_http_requests_in_flight gauge
+segmentation_http_requests_in_flight 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "segmentation_http_requests_in_flight"); err != nil {
+		t.Error(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	for _, f := range families {
+		if f.GetName() != "segmentation_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			var route, status string
+			for _, l := range m.GetLabel() {
+				switch l.GetName() {
+				case "route":
+					route = l.GetValue()
+				case "status":
+					status = l.GetValue()
+				}
+			}
+			counts[route+" "+status] = m.GetHistogram().GetSampleCount()
+		}
+	}
+
+	want := map[string]uint64{
+		"/users/:user_id 2xx": 2,
+		"/panic 5xx":          1,
+		"unmatched 4xx":       1,
+	}
+	for series, n := range want {
+		if counts[series] != n {
+			t.Errorf("%s: count = %d, want %d (all: %v)", series, counts[series], n, counts)
+		}
+	}
+}
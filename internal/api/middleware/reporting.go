@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"segmentation-api/internal/reporting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportErrors sends panics and the errors attached with c.Error to server
+// error responses to reporter, tagged with the request context. Panics are
+// re-raised so the recovery middleware still answers the request.
+func ReportErrors(reporter reporting.Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if p := recover(); p != nil {
+				reporter.Report(c.Request.Context(), reporting.PanicError(p), requestTags(c))
+				panic(p)
+			}
+		}()
+
+		c.Next()
+
+		if c.Writer.Status() < http.StatusInternalServerError {
+			return
+		}
+		for _, e := range c.Errors {
+			reporter.Report(c.Request.Context(), e.Err, requestTags(c))
+		}
+	}
+}
+
+func requestTags(c *gin.Context) map[string]string {
+	return map[string]string{
+		"method":    c.Request.Method,
+		"route":     c.FullPath(),
+		"path":      c.Request.URL.Path,
+		"client_ip": c.ClientIP(),
+	}
+}
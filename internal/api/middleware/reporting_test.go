@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type report struct {
+	err  error
+	tags map[string]string
+}
+
+type fakeReporter struct {
+	mu      sync.Mutex
+	reports []report
+}
+
+func (f *fakeReporter) Report(_ context.Context, err error, tags map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = append(f.reports, report{err: err, tags: tags})
+}
+
+func (f *fakeReporter) Flush(time.Duration) {}
+
+func TestReportErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dbErr := errors.New("db down")
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		status  int
+		want    string
+	}{
+		{
+			name:    "success is not reported",
+			handler: func(c *gin.Context) { c.Status(http.StatusOK) },
+			status:  http.StatusOK,
+		},
+		{
+			name: "client error is not reported",
+			handler: func(c *gin.Context) {
+				c.Error(errors.New("bad input"))
+				c.Status(http.StatusBadRequest)
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "server error is reported",
+			handler: func(c *gin.Context) {
+				c.Error(dbErr)
+				c.Status(http.StatusInternalServerError)
+			},
+			status: http.StatusInternalServerError,
+			want:   "db down",
+		},
+		{
+			name:    "panic is reported and recovered",
+			handler: func(c *gin.Context) { panic("boom") },
+			status:  http.StatusInternalServerError,
+			want:    "panic: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := &fakeReporter{}
+			r := gin.New()
+			r.Use(gin.Recovery(), ReportErrors(reporter))
+			r.GET("/users/:user_id", tt.handler)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.want == "" {
+				if len(reporter.reports) != 0 {
+					t.Fatalf("expected no reports, got %v", reporter.reports)
+				}
+				return
+			}
+			if len(reporter.reports) != 1 {
+				t.Fatalf("expected 1 report, got %d", len(reporter.reports))
+			}
+			got := reporter.reports[0]
+			if got.err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", got.err, tt.want)
+			}
+			if got.tags["route"] != "/users/:user_id" || got.tags["path"] != "/users/42" || got.tags["method"] != "GET" {
+				t.Errorf("unexpected tags: %v", got.tags)
+			}
+		})
+	}
+}
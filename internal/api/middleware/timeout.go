@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout gives each request a deadline of d, so a slow query can't hold
+// its goroutine and database connection indefinitely. Handlers see it in
+// the request context: a query cut by it fails with
+// context.DeadlineExceeded, answered 504 by the handler, and a handler that
+// returns past the deadline without a response gets a 504 here. 0 disables
+// the deadline.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+				"error": "request timed out",
+			})
+		}
+	}
+}
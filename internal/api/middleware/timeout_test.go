@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/fast", Timeout(50*time.Millisecond), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	// honours the deadline but returns without a response
+	r.GET("/slow", Timeout(10*time.Millisecond), func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+	// answers the deadline itself, as respondError does
+	r.GET("/answered", Timeout(10*time.Millisecond), func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "context deadline exceeded"})
+	})
+	r.GET("/off", Timeout(0), func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); ok {
+			t.Error("Timeout(0) should not set a deadline")
+		}
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		path string
+		want int
+		body string
+	}{
+		{path: "/fast", want: http.StatusOK},
+		{path: "/slow", want: http.StatusGatewayTimeout, body: `{"error":"request timed out"}`},
+		{path: "/answered", want: http.StatusGatewayTimeout, body: `{"error":"context deadline exceeded"}`},
+		{path: "/off", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.want || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s = %d %s, want %d %s", tt.path, w.Code, w.Body.String(), tt.want, tt.body)
+		}
+	}
+}
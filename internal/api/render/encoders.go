@@ -0,0 +1,168 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Table is a value the CSV encoder can render: a header and one row per
+// item, each the size of the header
+type Table interface {
+	Table() (header []string, rows [][]string, err error)
+}
+
+// JSON renders values as json.Marshal does
+type JSON struct{}
+
+func (JSON) MediaType() string   { return gin.MIMEJSON }
+func (JSON) ContentType() string { return "application/json; charset=utf-8" }
+
+func (JSON) Encode(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// CSV renders the values that implement Table, header first
+type CSV struct{}
+
+func (CSV) MediaType() string   { return "text/csv" }
+func (CSV) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (CSV) Encode(w io.Writer, v any) error {
+	t, ok := v.(Table)
+	if !ok {
+		return ErrUnsupported
+	}
+	header, rows, err := t.Table()
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// XML renders the JSON of a value as XML under a response element: an
+// object member is an element of the same name, or an entry element with
+// a key attribute when the name is not a valid XML name, an array repeats
+// an item element per value and null is an empty element
+type XML struct{}
+
+const (
+	xmlRoot  = "response"
+	xmlItem  = "item"
+	xmlEntry = "entry"
+)
+
+func (XML) MediaType() string   { return gin.MIMEXML }
+func (XML) ContentType() string { return "application/xml; charset=utf-8" }
+
+func (XML) Encode(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := writeElement(dec, enc, xml.StartElement{Name: xml.Name{Local: xmlRoot}}); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// writeElement writes the next JSON value of dec as the element start
+func writeElement(dec *json.Decoder, enc *xml.Encoder, start xml.StartElement) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		for dec.More() {
+			child := xml.StartElement{Name: xml.Name{Local: xmlItem}}
+			if t == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = memberElement(key.(string))
+			}
+			if err := writeElement(dec, enc, child); err != nil {
+				return err
+			}
+		}
+		// the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	case string:
+		err = enc.EncodeToken(xml.CharData(t))
+	case json.Number:
+		err = enc.EncodeToken(xml.CharData(t.String()))
+	case bool:
+		err = enc.EncodeToken(xml.CharData(strconv.FormatBool(t)))
+	case nil:
+	default:
+		err = fmt.Errorf("render: unexpected JSON token %v", tok)
+	}
+	if err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// memberElement is the element of the object member key
+func memberElement(key string) xml.StartElement {
+	if validName(key) {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: xmlEntry},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+	}
+}
+
+// validName reports whether s can name an element: a letter or underscore
+// followed by letters, digits, underscores, hyphens and dots, not starting
+// with xml, which is reserved
+func validName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', unicode.IsLetter(r):
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+// Package render writes the bodies of the read endpoints in the media type
+// the client asks for with Accept: JSON, the default, CSV or XML, for
+// partner integrations that only read one of them. The encoders are
+// pluggable: Register adds one for another media type.
+//
+// Every encoder renders the document the JSON encoder would write, so the
+// formats carry the same data. XML is converted from the JSON: objects
+// become elements named after their members and arrays repeat an item
+// element. CSV needs rows, so it only renders the values that implement
+// Table; the others are answered in JSON.
+package render
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrUnsupported is returned by an encoder for a value it cannot render;
+// the response falls back to JSON
+var ErrUnsupported = errors.New("render: value not supported by the encoder")
+
+// Encoder writes a response body in one media type
+type Encoder interface {
+	// MediaType is matched against Accept, such as text/csv
+	MediaType() string
+	// ContentType is the Content-Type of the body, with its charset
+	ContentType() string
+	// Encode writes v, or returns ErrUnsupported when it cannot render it
+	Encode(w io.Writer, v any) error
+}
+
+var (
+	mu sync.RWMutex
+	// encoders in the order they are offered; JSON answers the requests
+	// that accept none of them
+	encoders = []Encoder{JSON{}, CSV{}, XML{}}
+)
+
+// Register offers enc for its media type, replacing the encoder registered
+// for it
+func Register(enc Encoder) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, e := range encoders {
+		if e.MediaType() == enc.MediaType() {
+			encoders[i] = enc
+			return
+		}
+	}
+	encoders = append(encoders, enc)
+}
+
+// Negotiate returns the encoder of the media type the request accepts
+// first, JSON when Accept is missing or names none of the registered ones
+func Negotiate(c *gin.Context) Encoder {
+	mu.RLock()
+	defer mu.RUnlock()
+	offered := make([]string, len(encoders))
+	for i, e := range encoders {
+		offered[i] = e.MediaType()
+	}
+	if c.GetHeader("Accept") != "" {
+		if format := c.NegotiateFormat(offered...); format != "" {
+			for _, e := range encoders {
+				if e.MediaType() == format {
+					return e
+				}
+			}
+		}
+	}
+	return encoders[0]
+}
+
+// Render answers v with status in the negotiated media type. The body is
+// encoded before the status is sent, so an encoder that fails answers 500
+// instead of a truncated body.
+func Render(c *gin.Context, status int, v any) {
+	c.Writer.Header().Add("Vary", "Accept")
+	enc := Negotiate(c)
+
+	var buf bytes.Buffer
+	err := enc.Encode(&buf, v)
+	if errors.Is(err, ErrUnsupported) {
+		buf.Reset()
+		enc = JSON{}
+		err = enc.Encode(&buf, v)
+	}
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to encode the response"})
+		return
+	}
+	c.Data(status, enc.ContentType(), buf.Bytes())
+}
+
+// IsJSON reports whether the request is answered in JSON, for the
+// handlers that stream their JSON instead of rendering it
+func IsJSON(c *gin.Context) bool {
+	return Negotiate(c).MediaType() == gin.MIMEJSON
+}
@@ -0,0 +1,116 @@
+package render
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rows is a value the CSV encoder can render
+type rows struct {
+	Items []string `json:"items"`
+}
+
+func (r rows) Table() ([]string, [][]string, error) {
+	out := make([][]string, 0, len(r.Items))
+	for _, item := range r.Items {
+		out = append(out, []string{item})
+	}
+	return []string{"item"}, out, nil
+}
+
+func render(accept string, v any) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	if accept != "" {
+		c.Request.Header.Set("Accept", accept)
+	}
+	Render(c, http.StatusOK, v)
+	return w
+}
+
+func TestRender_Negotiation(t *testing.T) {
+	v := rows{Items: []string{"a,b", "c"}}
+	tests := []struct {
+		accept, wantType, wantBody string
+	}{
+		{"", "application/json; charset=utf-8", `{"items":["a,b","c"]}`},
+		{"*/*", "application/json; charset=utf-8", `{"items":["a,b","c"]}`},
+		{"text/html", "application/json; charset=utf-8", `{"items":["a,b","c"]}`},
+		{"text/csv", "text/csv; charset=utf-8", "item\n\"a,b\"\nc\n"},
+		{"text/html, text/csv;q=0.9", "text/csv; charset=utf-8", "item\n\"a,b\"\nc\n"},
+		{"application/xml", "application/xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+			`<response><items><item>a,b</item><item>c</item></items></response>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := render(tt.accept, v)
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.wantType {
+				t.Fatalf("got %d %q, want 200 %q", w.Code, w.Header().Get("Content-Type"), tt.wantType)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if w.Header().Get("Vary") != "Accept" {
+				t.Errorf("Vary = %q", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestRender_CSVNeedsTable(t *testing.T) {
+	w := render("text/csv", map[string]int{"total": 1})
+	if w.Header().Get("Content-Type") != "application/json; charset=utf-8" || w.Body.String() != `{"total":1}` {
+		t.Errorf("a value without rows should be answered in JSON, got %q: %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestXML_Encode(t *testing.T) {
+	var b strings.Builder
+	v := map[string]any{
+		"name":  `Dipirona <500mg> & "co"`,
+		"data":  map[string]any{"dose": 1.5, "1st": true, "xmlns": "x", "dose mg": nil},
+		"empty": []any{},
+		"tags":  []any{[]any{"a"}, map[string]any{"b": false}},
+	}
+	if err := (XML{}).Encode(&b, v); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response>` +
+		`<data><entry key="1st">true</entry><dose>1.5</dose><entry key="dose mg"></entry><entry key="xmlns">x</entry></data>` +
+		`<empty></empty>` +
+		`<name>Dipirona &lt;500mg&gt; &amp; &#34;co&#34;</name>` +
+		`<tags><item><item>a</item></item><item><b>false</b></item></tags>` +
+		`</response>`
+	if b.String() != want {
+		t.Errorf("Encode() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+// plain renders every value as text/plain
+type plain struct{}
+
+func (plain) MediaType() string   { return "text/plain" }
+func (plain) ContentType() string { return "text/plain; charset=utf-8" }
+func (plain) Encode(w io.Writer, v any) error {
+	_, err := io.WriteString(w, "plain")
+	return err
+}
+
+func TestRegister(t *testing.T) {
+	saved := append([]Encoder(nil), encoders...)
+	defer func() { encoders = saved }()
+
+	Register(plain{})
+	if w := render("text/plain", rows{}); w.Body.String() != "plain" {
+		t.Errorf("the registered encoder should answer text/plain, got %s", w.Body.String())
+	}
+	if w := render("", rows{}); w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("JSON should stay the default, got %q", w.Header().Get("Content-Type"))
+	}
+}
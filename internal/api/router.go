@@ -1,30 +1,529 @@
 package api
 
 import (
+	"time"
+
 	"segmentation-api/internal/api/handler"
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/buildinfo"
+	"segmentation-api/internal/health"
+	"segmentation-api/internal/metrics"
+	"segmentation-api/internal/reporting"
+	"segmentation-api/internal/repository"
 	"segmentation-api/internal/service"
+	"segmentation-api/internal/writequeue"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.uber.org/zap"
 )
 
+// Option customizes the router built by SetupRouter
+type Option func(*routerConfig)
+
+type routerConfig struct {
+	idempotencyStore repository.IdempotencyRepository
+	idempotencyTTL   time.Duration
+	adminToken       string
+	typeRegistry     *service.TypeRegistry
+	logger           *zap.Logger
+	logLevel         *zap.AtomicLevel
+	metrics          *prometheus.Registry
+	reporter         reporting.Reporter
+	auditLog         repository.AuditLogRepository
+	health           *health.Checker
+	maintenance      *middleware.Maintenance
+	readiness        *handler.Readiness
+	noSwagger        bool
+	schemaVersion    handler.SchemaVersionFunc
+	streamThreshold  int
+	maxBodyBytes     int64
+	requestTimeout   time.Duration
+	strictJSON       bool
+	changes          repository.ChangeRepository
+	search           repository.SearchRepository
+	importErrors     *service.ImportErrors
+	imports          *service.Imports
+	startImports     bool
+	writeQueue       *writequeue.Queue
+	reload           handler.ReloadFunc
+	config           handler.ConfigFunc
+	analyze          handler.AnalyzeFunc
+	webhooks         repository.WebhookRepository
+	exports          *service.Exports
+	erasures         repository.ErasureRepository
+	analytics        *service.Analytics
+}
+
+// WithIdempotency enables Idempotency-Key handling on write endpoints,
+// keeping stored responses for ttl
+func WithIdempotency(store repository.IdempotencyRepository, ttl time.Duration) Option {
+	return func(cfg *routerConfig) {
+		cfg.idempotencyStore = store
+		cfg.idempotencyTTL = ttl
+	}
+}
+
+// WithAdminToken sets the bearer token required by the /admin routes
+func WithAdminToken(token string) Option {
+	return func(cfg *routerConfig) {
+		cfg.adminToken = token
+	}
+}
+
+// WithTypeRegistry exposes the segmentation type registry admin API
+func WithTypeRegistry(registry *service.TypeRegistry) Option {
+	return func(cfg *routerConfig) {
+		cfg.typeRegistry = registry
+	}
+}
+
+// WithLogger replaces gin's default request logging and panic recovery with
+// structured entries written to logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(cfg *routerConfig) {
+		cfg.logger = logger
+	}
+}
+
+// WithLogLevel exposes the admin endpoints that read and change level at
+// runtime
+func WithLogLevel(level zap.AtomicLevel) Option {
+	return func(cfg *routerConfig) {
+		cfg.logLevel = &level
+	}
+}
+
+// WithMetrics measures every request with collectors registered in reg and
+// serves the Prometheus metrics of reg at /metrics
+func WithMetrics(reg *prometheus.Registry) Option {
+	return func(cfg *routerConfig) {
+		cfg.metrics = reg
+	}
+}
+
+// WithErrorReporter sends panics and server errors to reporter
+func WithErrorReporter(reporter reporting.Reporter) Option {
+	return func(cfg *routerConfig) {
+		cfg.reporter = reporter
+	}
+}
+
+// WithAuditLog records auth failures, admin actions and segmentation
+// replacements in store and exposes them at GET /admin/audit-log
+func WithAuditLog(store repository.AuditLogRepository) Option {
+	return func(cfg *routerConfig) {
+		cfg.auditLog = store
+	}
+}
+
+// WithHealthChecks serves the report of checker at /health/details
+func WithHealthChecks(checker *health.Checker) Option {
+	return func(cfg *routerConfig) {
+		cfg.health = checker
+	}
+}
+
+// WithMaintenance refuses reads or writes with 503 according to the mode
+// of maintenance, changed at runtime through /admin/maintenance
+func WithMaintenance(maintenance *middleware.Maintenance) Option {
+	return func(cfg *routerConfig) {
+		cfg.maintenance = maintenance
+	}
+}
+
+// WithReadiness serves readiness at /ready, for load balancers to stop
+// routing traffic before a shutdown
+func WithReadiness(readiness *handler.Readiness) Option {
+	return func(cfg *routerConfig) {
+		cfg.readiness = readiness
+	}
+}
+
+// WithSchemaVersion adds the schema migration version to GET /version
+func WithSchemaVersion(schema handler.SchemaVersionFunc) Option {
+	return func(cfg *routerConfig) {
+		cfg.schemaVersion = schema
+	}
+}
+
+// WithStreamThreshold streams the segmentations of users with more than
+// rows of them instead of buffering the whole response; 0 never streams
+func WithStreamThreshold(rows int) Option {
+	return func(cfg *routerConfig) {
+		cfg.streamThreshold = rows
+	}
+}
+
+// WithRequestTimeout gives each segmentation read and write a deadline of
+// d, answered 504 when exceeded; exports and import errors, which stream,
+// are left to the server write timeout. 0 sets no deadline.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(cfg *routerConfig) {
+		cfg.requestTimeout = d
+	}
+}
+
+// WithMaxBodyBytes refuses bodies larger than n bytes on the segmentation
+// write endpoints with 413; 0 allows any size
+func WithMaxBodyBytes(n int64) Option {
+	return func(cfg *routerConfig) {
+		cfg.maxBodyBytes = n
+	}
+}
+
+// WithStrictJSON refuses segmentation write bodies with unknown members
+// with 400 instead of ignoring them
+func WithStrictJSON(strict bool) Option {
+	return func(cfg *routerConfig) {
+		cfg.strictJSON = strict
+	}
+}
+
+// WithChanges serves the segmentations written after a watermark, read
+// from store, at GET /segmentations/changes
+func WithChanges(store repository.ChangeRepository) Option {
+	return func(cfg *routerConfig) {
+		cfg.changes = store
+	}
+}
+
+// WithSearch serves the search of the segmentations mirrored into index
+// at GET /segmentations/search
+func WithSearch(index repository.SearchRepository) Option {
+	return func(cfg *routerConfig) {
+		cfg.search = index
+	}
+}
+
+// WithImportErrors serves the dead-letter entries of processor runs, read
+// from runs and deadLetters, at GET /imports/:run_id/errors
+func WithImportErrors(runs repository.RunRepository, deadLetters repository.DeadLetterRepository) Option {
+	return func(cfg *routerConfig) {
+		cfg.importErrors = service.NewImportErrors(runs, deadLetters)
+	}
+}
+
+// WithImports serves GET /imports and GET /imports/:run_id, which read the
+// imports and their progress from runs, POST /imports/:run_id/cancel and,
+// with a starter, POST /imports, which stores a CSV and imports it in the
+// background. A nil starter leaves POST /imports out.
+func WithImports(runs repository.RunRepository, starter service.ImportStarter) Option {
+	return func(cfg *routerConfig) {
+		cfg.imports = service.NewImports(runs, starter)
+		cfg.startImports = starter != nil
+	}
+}
+
+// WithWriteQueue accepts single and bulk POST writes into q, flushed to
+// MySQL in the background, and serves their status at GET /writes/:id
+func WithWriteQueue(q *writequeue.Queue) Option {
+	return func(cfg *routerConfig) {
+		cfg.writeQueue = q
+	}
+}
+
+// WithReload serves POST /admin/reload, which calls reload to apply the
+// configuration without restarting
+func WithReload(reload handler.ReloadFunc) Option {
+	return func(cfg *routerConfig) {
+		cfg.reload = reload
+	}
+}
+
+// WithEffectiveConfig serves the configuration returned by cfg, secrets
+// masked, at GET /admin/config
+func WithEffectiveConfig(cfg handler.ConfigFunc) Option {
+	return func(c *routerConfig) {
+		c.config = cfg
+	}
+}
+
+// WithStatsRecompute serves POST /admin/stats/recompute, which calls
+// analyze to recompute the table statistics of the database
+func WithStatsRecompute(analyze handler.AnalyzeFunc) Option {
+	return func(cfg *routerConfig) {
+		cfg.analyze = analyze
+	}
+}
+
+// WithWebhooks serves the webhook subscription endpoints under /webhooks,
+// behind the admin token, over store
+func WithWebhooks(store repository.WebhookRepository) Option {
+	return func(cfg *routerConfig) {
+		cfg.webhooks = store
+	}
+}
+
+// WithExports serves POST /admin/exports, which starts a table export
+// with starter, and GET /admin/exports/:id, which reads its progress from
+// runs. A nil starter leaves the routes out.
+func WithExports(runs repository.RunRepository, starter service.ExportStarter) Option {
+	return func(cfg *routerConfig) {
+		if starter != nil {
+			cfg.exports = service.NewExports(runs, starter)
+		}
+	}
+}
+
+// WithErasures serves DELETE /users/:user_id, which erases all the data of
+// a user through repo, and the erasure records under /admin/erasures
+func WithErasures(repo repository.ErasureRepository) Option {
+	return func(cfg *routerConfig) {
+		cfg.erasures = repo
+	}
+}
+
+// WithAnalytics serves the aggregates of analytics under
+// /admin/analytics
+func WithAnalytics(analytics *service.Analytics) Option {
+	return func(cfg *routerConfig) {
+		cfg.analytics = analytics
+	}
+}
+
+// WithSwagger serves the Swagger UI at /swagger and the spec at
+// /openapi.json when enabled, the default
+func WithSwagger(enabled bool) Option {
+	return func(cfg *routerConfig) {
+		cfg.noSwagger = !enabled
+	}
+}
+
 // SetupRouter configures all API routes
-func SetupRouter(svc *service.SegmentationService) *gin.Engine {
-	router := gin.Default()
+func SetupRouter(svc *service.SegmentationService, opts ...Option) *gin.Engine {
+	cfg := &routerConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var router *gin.Engine
+	if cfg.logger != nil {
+		router = gin.New()
+		router.Use(middleware.Recovery(cfg.logger), middleware.RequestLogger(cfg.logger))
+	} else {
+		router = gin.Default()
+	}
+	if cfg.metrics != nil {
+		router.Use(middleware.Metrics(metrics.NewHTTPMetrics(cfg.metrics)))
+	}
+	if cfg.reporter != nil {
+		router.Use(middleware.ReportErrors(cfg.reporter))
+	}
+	if cfg.readiness != nil {
+		router.Use(cfg.readiness.CloseWhenDraining)
+	}
 
 	// Initialize handler
-	h := handler.NewSegmentationHandler(svc)
+	handlerOpts := []handler.SegmentationHandlerOption{handler.WithStreamThreshold(cfg.streamThreshold)}
+	if cfg.writeQueue != nil {
+		handlerOpts = append(handlerOpts, handler.WithWriteQueue(cfg.writeQueue))
+	}
+	if cfg.strictJSON {
+		handlerOpts = append(handlerOpts, handler.WithStrictJSON())
+	}
+	h := handler.NewSegmentationHandler(svc, handlerOpts...)
+
+	// Middleware chains applied to read and write endpoints; maintenance
+	// comes first so refused writes are not stored as idempotent responses,
+	// and the body checks come before idempotency reads the body. Streamed
+	// responses use stream instead of read, without the request deadline.
+	var readMiddleware, writeMiddleware []gin.HandlerFunc
+	if cfg.maintenance != nil {
+		readMiddleware = append(readMiddleware, middleware.MaintenanceGuard(cfg.maintenance, false))
+		writeMiddleware = append(writeMiddleware, middleware.MaintenanceGuard(cfg.maintenance, true))
+	}
+	streamMiddleware := append([]gin.HandlerFunc{}, readMiddleware...)
+	if cfg.requestTimeout > 0 {
+		timeout := middleware.Timeout(cfg.requestTimeout)
+		readMiddleware = append(readMiddleware, timeout)
+		writeMiddleware = append(writeMiddleware, timeout)
+	}
+	bodyMiddleware := []gin.HandlerFunc{middleware.RequireJSON(), middleware.LimitBody(cfg.maxBodyBytes)}
+	writeMiddleware = append(writeMiddleware, bodyMiddleware...)
+	if cfg.idempotencyStore != nil {
+		writeMiddleware = append(writeMiddleware, middleware.Idempotency(cfg.idempotencyStore, cfg.idempotencyTTL))
+	}
+	write := func(h gin.HandlerFunc) []gin.HandlerFunc {
+		chain := append([]gin.HandlerFunc{}, writeMiddleware...)
+		return append(chain, h)
+	}
+	read := func(h gin.HandlerFunc) []gin.HandlerFunc {
+		chain := append([]gin.HandlerFunc{}, readMiddleware...)
+		return append(chain, h)
+	}
+	stream := func(h gin.HandlerFunc) []gin.HandlerFunc {
+		chain := append([]gin.HandlerFunc{}, streamMiddleware...)
+		return append(chain, h)
+	}
 
 	// Health check endpoint
 	router.GET("/health", h.Health)
+	if cfg.health != nil {
+		router.GET("/health/details", handler.NewHealthHandler(cfg.health).Details)
+	}
+	if cfg.readiness != nil {
+		router.GET("/ready", cfg.readiness.Ready)
+	}
+	router.GET("/version", handler.NewVersionHandler(buildinfo.Get(), cfg.schemaVersion).Version)
+
+	// Prometheus metrics
+	if cfg.metrics != nil {
+		router.GET("/metrics", gin.WrapH(metrics.Handler(cfg.metrics)))
+	}
+
+	// Audited requests: the audit middleware runs before auth so refused
+	// attempts are recorded
+	adminMiddleware := []gin.HandlerFunc{middleware.AdminAuth(cfg.adminToken)}
+	replace := append(append([]gin.HandlerFunc{}, bodyMiddleware...), h.ReplaceUserSegmentations)
+	if cfg.requestTimeout > 0 {
+		replace = append([]gin.HandlerFunc{middleware.Timeout(cfg.requestTimeout)}, replace...)
+	}
+	remove := write(h.DeleteUserSegmentation)
+	if cfg.auditLog != nil {
+		adminMiddleware = append([]gin.HandlerFunc{middleware.Audit(cfg.auditLog, "")}, adminMiddleware...)
+		replace = append([]gin.HandlerFunc{middleware.Audit(cfg.auditLog, "segmentations.replace")}, replace...)
+		remove = append([]gin.HandlerFunc{middleware.Audit(cfg.auditLog, "segmentations.delete")}, remove...)
+	}
+	if cfg.maintenance != nil {
+		replace = append([]gin.HandlerFunc{middleware.MaintenanceGuard(cfg.maintenance, true)}, replace...)
+	}
 
 	// Segmentation endpoints
-	router.GET("/users/:user_id/segmentations", h.GetUserSegmentations)
+	router.GET("/users/:user_id/segmentations", read(h.GetUserSegmentations)...)
+	router.PUT("/users/:user_id/segmentations", replace...)
+	router.POST("/users/:user_id/segmentations", write(h.CreateUserSegmentation)...)
+	router.DELETE("/users/:user_id/segmentations", remove...)
+	router.POST("/segmentations/bulk", write(h.BulkUpsertSegmentations)...)
+	router.GET("/users/:user_id/segmentations/export", stream(h.ExportUserSegmentations)...)
+	router.GET("/users/:user_id/segmentations/:type", read(h.GetUserSegmentationsByType)...)
+	if cfg.writeQueue != nil {
+		router.GET("/writes/:id", h.GetWrite)
+	}
+	if cfg.changes != nil {
+		ch := handler.NewChangesHandler(service.NewChangeFeed(cfg.changes))
+		router.GET("/segmentations/changes", read(ch.ListChanges)...)
+	}
+	if cfg.search != nil {
+		sh := handler.NewSearchHandler(service.NewSearcher(cfg.search))
+		router.GET("/segmentations/search", read(sh.SearchSegmentations)...)
+	}
+	ih := handler.NewImportHandler(cfg.importErrors, cfg.imports)
+	if cfg.importErrors != nil {
+		router.GET("/imports/:run_id/errors", stream(ih.GetImportErrors)...)
+	}
+	if cfg.imports != nil {
+		// the admin token since an import writes any user
+		list := append(append(append([]gin.HandlerFunc{}, readMiddleware...), adminMiddleware...), ih.ListImports)
+		get := append(append(append([]gin.HandlerFunc{}, readMiddleware...), adminMiddleware...), ih.GetImport)
+		router.GET("/imports", list...)
+		router.GET("/imports/:run_id", get...)
+
+		// the file streams to disk: no body limit or request deadline
+		start := append(append([]gin.HandlerFunc{}, adminMiddleware...), ih.StartImport)
+		cancel := append(append([]gin.HandlerFunc{}, adminMiddleware...), ih.CancelImport)
+		if cfg.maintenance != nil {
+			guard := middleware.MaintenanceGuard(cfg.maintenance, true)
+			start = append([]gin.HandlerFunc{guard}, start...)
+			cancel = append([]gin.HandlerFunc{guard}, cancel...)
+		}
+		router.POST("/imports/:run_id/cancel", cancel...)
+		if cfg.startImports {
+			router.POST("/imports", start...)
+		}
+	}
+
+	var erasures *handler.ErasureHandler
+	if cfg.erasures != nil {
+		// the admin token since the erasure cannot be undone
+		var erasureOpts []service.ErasuresOption
+		if cfg.writeQueue != nil {
+			erasureOpts = append(erasureOpts, service.WithQueuePurge(cfg.writeQueue))
+		}
+		erasures = handler.NewErasureHandler(service.NewErasures(cfg.erasures, svc, erasureOpts...))
+		erase := append(append([]gin.HandlerFunc{}, adminMiddleware...), erasures.EraseUser)
+		if cfg.maintenance != nil {
+			erase = append([]gin.HandlerFunc{middleware.MaintenanceGuard(cfg.maintenance, true)}, erase...)
+		}
+		router.DELETE("/users/:user_id", erase...)
+	}
+
+	// Admin endpoints
+	admin := router.Group("/admin", adminMiddleware...)
+	if cfg.typeRegistry != nil {
+		th := handler.NewTypeHandler(cfg.typeRegistry)
+		admin.GET("/segmentation-types", th.ListTypes)
+		admin.GET("/segmentation-types/:name", th.GetType)
+		admin.POST("/segmentation-types", th.RegisterType)
+		admin.PATCH("/segmentation-types/:name", th.UpdateType)
+		admin.PUT("/segmentation-types/:name", th.PutType)
+		admin.PUT("/segmentation-types/:name/schema", th.PutTypeSchema)
+	}
+	if cfg.auditLog != nil {
+		ah := handler.NewAuditLogHandler(cfg.auditLog)
+		admin.GET("/audit-log", ah.ListAuditLog)
+	}
+	if cfg.logLevel != nil {
+		lh := handler.NewLogLevelHandler(*cfg.logLevel, cfg.logger)
+		admin.GET("/log-level", lh.GetLogLevel)
+		admin.PUT("/log-level", lh.SetLogLevel)
+	}
+	if cfg.maintenance != nil {
+		mh := handler.NewMaintenanceHandler(cfg.maintenance, cfg.logger)
+		admin.GET("/maintenance", mh.GetMaintenance)
+		admin.PUT("/maintenance", mh.SetMaintenance)
+	}
+	if cfg.reload != nil {
+		admin.POST("/reload", handler.NewReloadHandler(cfg.reload).Reload)
+	}
+	oh := handler.NewOperationsHandler(svc, cfg.typeRegistry, cfg.config, cfg.analyze, cfg.logger)
+	admin.POST("/cache/flush", oh.FlushCaches)
+	if cfg.config != nil {
+		admin.GET("/config", oh.GetConfig)
+	}
+	if cfg.analyze != nil {
+		admin.POST("/stats/recompute", oh.RecomputeStats)
+	}
+	if cfg.exports != nil {
+		eh := handler.NewExportHandler(cfg.exports)
+		admin.POST("/exports", eh.StartExport)
+		admin.GET("/exports/:id", eh.GetExport)
+	}
+	if erasures != nil {
+		admin.GET("/erasures", erasures.ListErasures)
+		admin.GET("/erasures/verify", erasures.VerifyErasures)
+	}
+	if cfg.analytics != nil {
+		anh := handler.NewAnalyticsHandler(cfg.analytics)
+		admin.GET("/analytics/top-segments", anh.TopSegments)
+		admin.GET("/analytics/users", anh.UserHistogram)
+		admin.GET("/analytics/growth", anh.Growth)
+		admin.POST("/analytics/snapshots", anh.TakeSnapshot)
+		admin.GET("/analytics/overlap", anh.Overlap)
+	}
+
+	// Webhook subscriptions, managed with the admin token
+	if cfg.webhooks != nil {
+		wh := handler.NewWebhookHandler(service.NewWebhooks(cfg.webhooks))
+		webhooks := router.Group("/webhooks", adminMiddleware...)
+		webhooks.GET("", wh.ListWebhooks)
+		webhooks.POST("", wh.CreateWebhook)
+		webhooks.GET("/:id", wh.GetWebhook)
+		webhooks.PATCH("/:id", wh.UpdateWebhook)
+		webhooks.DELETE("/:id", wh.DeleteWebhook)
+		webhooks.GET("/:id/deliveries", wh.ListDeliveries)
+		webhooks.POST("/:id/deliveries/:delivery_id/retry", wh.RetryDelivery)
+	}
 
 	// Swagger documentation
-	// Available at http://localhost:8080/swagger/index.html
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Available at http://localhost:8080/swagger/index.html, with the spec
+	// itself at /openapi.json
+	if !cfg.noSwagger {
+		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+		router.GET("/openapi.json", handler.OpenAPI)
+	}
 
 	return router
 }
@@ -2,13 +2,23 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	_ "segmentation-api/docs"
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/metrics"
 	"segmentation-api/internal/models"
 	"segmentation-api/internal/repository"
 	"segmentation-api/internal/service"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // MockRepository for testing
@@ -23,10 +33,26 @@ func (m *MockRepository) FindByUserID(ctx context.Context, userID uint64) ([]mod
 	return nil, nil
 }
 
+func (m *MockRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func (m *MockRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
 	return repository.UpsertInserted, nil
 }
 
+func (m *MockRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *MockRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *MockRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
 func TestSetupRouter_RoutesDefined(t *testing.T) {
 	mockRepo := &MockRepository{}
 	svc := service.NewSegmentationService(mockRepo)
@@ -96,6 +122,85 @@ func TestSetupRouter_SwaggerEndpoint(t *testing.T) {
 	}
 }
 
+func TestSetupRouter_SwaggerDisabled(t *testing.T) {
+	mockRepo := &MockRepository{}
+	svc := service.NewSegmentationService(mockRepo)
+	router := SetupRouter(svc, WithSwagger(false))
+
+	req := httptest.NewRequest("GET", "/swagger/index.html", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected swagger endpoint to return 404 when disabled, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/openapi.json", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected openapi.json to return 404 when disabled, got %d", w.Code)
+	}
+}
+
+func TestSetupRouter_OpenAPISpec(t *testing.T) {
+	mockRepo := &MockRepository{}
+	svc := service.NewSegmentationService(mockRepo)
+	router := SetupRouter(svc)
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected openapi.json to return 200, got %d", w.Code)
+	}
+	var spec struct {
+		Paths       map[string]map[string]json.RawMessage `json:"paths"`
+		Definitions map[string]json.RawMessage            `json:"definitions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("openapi.json is not JSON: %v", err)
+	}
+
+	for path, method := range map[string]string{
+		"/users/{user_id}/segmentations":          "put",
+		"/segmentations/bulk":                     "post",
+		"/segmentations/changes":                  "get",
+		"/segmentations/search":                   "get",
+		"/admin/segmentation-types/{name}":        "patch",
+		"/admin/segmentation-types/{name}/schema": "put",
+		"/admin/stats/recompute":                  "post",
+	} {
+		if _, ok := spec.Paths[path][method]; !ok {
+			t.Errorf("spec does not document %s %s", strings.ToUpper(method), path)
+		}
+	}
+	if _, ok := spec.Definitions["handler.ErrorResponse"]; !ok {
+		t.Error("spec does not define the error envelope")
+	}
+}
+
+func TestSetupRouter_VersionEndpoint(t *testing.T) {
+	mockRepo := &MockRepository{}
+	svc := service.NewSegmentationService(mockRepo)
+	router := SetupRouter(svc, WithSchemaVersion(func(ctx context.Context) (int64, int, error) {
+		return 20261016000100, 0, nil
+	}))
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected version endpoint to return 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"version":"dev"`) || !strings.Contains(w.Body.String(), `"version":20261016000100`) {
+		t.Errorf("unexpected version body: %s", w.Body.String())
+	}
+}
+
 func TestSetupRouter_HealthMultipleCalls(t *testing.T) {
 	mockRepo := &MockRepository{}
 	svc := service.NewSegmentationService(mockRepo)
@@ -227,7 +332,7 @@ func TestSetupRouter_PathNotFound(t *testing.T) {
 		"/users",
 		"/users/123",
 		"/segmentations",
-		"/users/123/segmentations/456",
+		"/users/123/segmentations/drugs/456",
 	}
 
 	for _, path := range paths {
@@ -240,3 +345,429 @@ func TestSetupRouter_PathNotFound(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupRouter_LogLevelEndpoint(t *testing.T) {
+	level := zap.NewAtomicLevel()
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithLogLevel(level),
+	)
+
+	req := httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "debug"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if level.Level() != zapcore.DebugLevel {
+		t.Errorf("level = %s, want debug", level.Level())
+	}
+
+	// Without the admin token the level cannot be changed
+	req = httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "error"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+}
+
+func TestSetupRouter_Maintenance(t *testing.T) {
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithMaintenance(middleware.NewMaintenance(middleware.MaintenanceOff, "")),
+	)
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	write := `{"segmentation_type": "drug", "segmentation_name": "Aspirina"}`
+
+	if code := do("PUT", "/admin/maintenance", `{"mode": "read_only"}`); code != http.StatusOK {
+		t.Fatalf("PUT /admin/maintenance = %d, want 200", code)
+	}
+	if code := do("GET", "/users/1/segmentations", ""); code != http.StatusOK {
+		t.Errorf("read in read_only mode = %d, want 200", code)
+	}
+	if code := do("POST", "/users/1/segmentations", write); code != http.StatusServiceUnavailable {
+		t.Errorf("write in read_only mode = %d, want 503", code)
+	}
+	if code := do("PUT", "/users/1/segmentations", `{"segmentations": []}`); code != http.StatusServiceUnavailable {
+		t.Errorf("replace in read_only mode = %d, want 503", code)
+	}
+
+	do("PUT", "/admin/maintenance", `{"mode": "write_only"}`)
+	if code := do("GET", "/users/1/segmentations", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("read in write_only mode = %d, want 503", code)
+	}
+
+	do("PUT", "/admin/maintenance", `{"mode": "off"}`)
+	if code := do("GET", "/users/1/segmentations", ""); code != http.StatusOK {
+		t.Errorf("read with maintenance off = %d, want 200", code)
+	}
+}
+
+func TestSetupRouter_RequestBodies(t *testing.T) {
+	router := SetupRouter(service.NewSegmentationService(&MockRepository{}), WithMaxBodyBytes(256), WithStrictJSON(true))
+	do := func(method, path, contentType, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	write := `{"segmentation_type": "drug", "segmentation_name": "Aspirina"}`
+
+	if code := do("POST", "/users/1/segmentations", "application/json", write); code != http.StatusCreated {
+		t.Errorf("JSON write = %d, want 201", code)
+	}
+	if code := do("POST", "/users/1/segmentations", "text/csv", write); code != http.StatusUnsupportedMediaType {
+		t.Errorf("CSV write = %d, want 415", code)
+	}
+	if code := do("PUT", "/users/1/segmentations", "text/plain", `{"segmentations": []}`); code != http.StatusUnsupportedMediaType {
+		t.Errorf("text replace = %d, want 415", code)
+	}
+	if code := do("POST", "/segmentations/bulk", "application/json", `{"items": [`+strings.Repeat(write+",", 8)+write+`]}`); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized bulk = %d, want 413", code)
+	}
+	if code := do("POST", "/users/1/segmentations", "application/json", `{"segmentation_type": "drug", "segmentation_name": "A", "extra": 1}`); code != http.StatusBadRequest {
+		t.Errorf("unknown member in strict mode = %d, want 400", code)
+	}
+}
+
+func TestSetupRouter_RequestTimeout(t *testing.T) {
+	// a query that only ends when the request deadline cancels it
+	repo := &MockRepository{findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	router := SetupRouter(service.NewSegmentationService(repo), WithRequestTimeout(20*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/1/segmentations", nil))
+
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("slow read = %d %s, want 504 with an error", w.Code, w.Body.String())
+	}
+}
+
+func TestSetupRouter_MetricsEndpoint(t *testing.T) {
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithMetrics(metrics.NewRegistry()),
+	)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "go_goroutines") {
+		t.Error("expected Prometheus metrics in the response")
+	}
+	if !strings.Contains(w.Body.String(), `segmentation_http_request_duration_seconds_count{method="GET",route="/health",status="2xx"} 1`) {
+		t.Error("expected the /health request in the HTTP latency histogram")
+	}
+}
+
+type memoryAuditLog struct {
+	entries []models.AuditLog
+}
+
+func (m *memoryAuditLog) Insert(ctx context.Context, entry *models.AuditLog) error {
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *memoryAuditLog) List(ctx context.Context, filter repository.AuditFilter) ([]models.AuditLog, error) {
+	return m.entries, nil
+}
+
+func TestSetupRouter_AuditLog(t *testing.T) {
+	store := &memoryAuditLog{}
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithAuditLog(store),
+	)
+
+	// Refused admin request
+	req := httptest.NewRequest("GET", "/admin/audit-log", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+
+	// Segmentation replacement
+	req = httptest.NewRequest("PUT", "/users/42/segmentations", strings.NewReader(`{"segmentations": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if len(store.entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", store.entries)
+	}
+	if store.entries[0].Action != "auth_failed" || store.entries[1].Action != "segmentations.replace" {
+		t.Errorf("unexpected actions: %+v", store.entries)
+	}
+	if store.entries[1].Target != "/users/42/segmentations" {
+		t.Errorf("target = %q", store.entries[1].Target)
+	}
+
+	// Segmentation removal
+	req = httptest.NewRequest("DELETE", "/users/42/segmentations?type=drug&name=Aspirina", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if len(store.entries) != 3 || store.entries[2].Action != "segmentations.delete" {
+		t.Fatalf("expected a segmentations.delete entry, got %+v", store.entries)
+	}
+	if store.entries[2].Target != "/users/42/segmentations" {
+		t.Errorf("target = %q", store.entries[2].Target)
+	}
+
+	// Entries are readable with the admin token
+	req = httptest.NewRequest("GET", "/admin/audit-log", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "segmentations.replace") {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// listOnlyWebhooks answers List with no webhooks; other methods are not used
+type listOnlyWebhooks struct {
+	repository.WebhookRepository
+}
+
+func (listOnlyWebhooks) List(ctx context.Context) ([]models.Webhook, error) {
+	return nil, nil
+}
+
+func TestSetupRouter_Webhooks(t *testing.T) {
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithWebhooks(listOnlyWebhooks{}),
+	)
+
+	req := httptest.NewRequest("GET", "/webhooks", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("/webhooks without the admin token = %d, want 401", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"webhooks":[]}` {
+		t.Errorf("GET /webhooks = %d %s", w.Code, w.Body.String())
+	}
+}
+
+// noRuns knows no run; other methods are not used
+type noRuns struct {
+	repository.RunRepository
+}
+
+func (noRuns) Get(ctx context.Context, id string) (*models.Run, error) {
+	return nil, nil
+}
+
+func TestSetupRouter_Exports(t *testing.T) {
+	var noStarter service.ExportStarter
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithExports(noRuns{}, noStarter),
+	)
+	req := httptest.NewRequest("GET", "/admin/exports/x", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "export not found") {
+		t.Fatalf("without a starter the route should not exist, got %d %s", w.Code, w.Body.String())
+	}
+
+	router = SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithExports(noRuns{}, exportStarterFunc(nil)),
+	)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "export not found") {
+		t.Errorf("GET /admin/exports/x = %d %s", w.Code, w.Body.String())
+	}
+}
+
+// countingErasures records the erasures it is asked for
+type countingErasures struct {
+	repository.ErasureRepository
+	erased []models.Erasure
+}
+
+func (m *countingErasures) Erase(ctx context.Context, erasure *models.Erasure) ([]models.Segmentation, error) {
+	m.erased = append(m.erased, *erasure)
+	return nil, nil
+}
+
+func TestSetupRouter_EraseUser(t *testing.T) {
+	erasures := &countingErasures{}
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithErasures(erasures),
+	)
+
+	req := httptest.NewRequest("DELETE", "/users/10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || len(erasures.erased) != 0 {
+		t.Fatalf("an erasure without the admin token = %d, want 401", w.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || len(erasures.erased) != 1 || erasures.erased[0].UserID != 10 || erasures.erased[0].Actor != "admin" {
+		t.Errorf("DELETE /users/10 = %d %s, erased %+v", w.Code, w.Body.String(), erasures.erased)
+	}
+}
+
+type exportStarterFunc func(ctx context.Context, filter repository.Filter, source string, pseudonymize bool) (*models.Run, error)
+
+func (f exportStarterFunc) Start(ctx context.Context, filter repository.Filter, source string, pseudonymize bool) (*models.Run, error) {
+	return f(ctx, filter, source, pseudonymize)
+}
+
+// emptySearch finds nothing
+type emptySearch struct{}
+
+func (emptySearch) Search(ctx context.Context, q repository.SearchQuery) (*repository.SearchResult, error) {
+	return &repository.SearchResult{Hits: []repository.SearchHit{}}, nil
+}
+
+func TestSetupRouter_Search(t *testing.T) {
+	req := httptest.NewRequest("GET", "/segmentations/search?q=aspirina", nil)
+	w := httptest.NewRecorder()
+	SetupRouter(service.NewSegmentationService(&MockRepository{})).ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("without an index the route should not exist, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	SetupRouter(service.NewSegmentationService(&MockRepository{}), WithSearch(emptySearch{})).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"total":0,"hits":[],"links":{"self":"/segmentations/search?q=aspirina"},"meta":{"total":0,"returned":0}}` {
+		t.Errorf("GET /segmentations/search = %d %s", w.Code, w.Body.String())
+	}
+}
+
+// uploadStarter records the file of an import without running it
+type uploadStarter struct {
+	content string
+}
+
+func (s *uploadStarter) Start(ctx context.Context, name string, body io.Reader) (*models.Run, error) {
+	b, _ := io.ReadAll(body)
+	s.content = string(b)
+	return &models.Run{ID: "import-1", Status: models.RunRunning}, nil
+}
+
+func (s *uploadStarter) StartS3(ctx context.Context, rawURL string) (*models.Run, error) {
+	return &models.Run{ID: "import-2", Status: models.RunRunning}, nil
+}
+
+func (s *uploadStarter) Cancel(runID string) bool { return false }
+
+func TestSetupRouter_Imports(t *testing.T) {
+	starter := &uploadStarter{}
+	maintenance := middleware.NewMaintenance(middleware.MaintenanceOff, "")
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithMaintenance(maintenance),
+		WithMaxBodyBytes(8),
+		WithImportErrors(noRuns{}, nil),
+		WithImports(noRuns{}, starter),
+	)
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/imports", strings.NewReader("--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.csv\"\r\n\r\nuser_id,segmentation_type\r\n--b--\r\n"))
+		req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("POST /imports without the admin token = %d, want 401", w.Code)
+	}
+	// uploads are not held to the body limit of the JSON writes
+	if w := post("s3cret"); w.Code != http.StatusAccepted || starter.content != "user_id,segmentation_type" {
+		t.Errorf("POST /imports = %d %s, stored %q", w.Code, w.Body.String(), starter.content)
+	}
+	// the routes reading the imports share the path of the errors
+	for _, path := range []string{"/imports/import-1", "/imports/import-1/errors"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "run not found") {
+			t.Errorf("GET %s = %d %s, want the run not found", path, w.Code, w.Body.String())
+		}
+	}
+	maintenance.Set(middleware.MaintenanceReadOnly, "")
+	if w := post("s3cret"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /imports in read-only maintenance = %d, want 503", w.Code)
+	}
+}
+
+func TestSetupRouter_ImportsWithoutStarter(t *testing.T) {
+	router := SetupRouter(
+		service.NewSegmentationService(&MockRepository{}),
+		WithAdminToken("s3cret"),
+		WithImports(noRuns{}, nil),
+	)
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/imports/import-1", http.StatusNotFound},
+		{"POST", "/imports/import-1/cancel", http.StatusNotFound},
+		{"POST", "/imports", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized && tt.path != "/imports" {
+			t.Errorf("%s %s without the admin token = %d, want 401", tt.method, tt.path, w.Code)
+		}
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/service"
+)
+
+// analyticsSnapshotLock is the leader lock of the analytics snapshots
+const analyticsSnapshotLock = "segmentation_analytics_snapshot"
+
+// runSnapshots records the size of each type with analytics right away
+// and then every interval, until ctx is done. A snapshot replaces the
+// earlier one of the day, so the last of each day is kept.
+func runSnapshots(ctx context.Context, analytics *service.Analytics, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		types, err := analytics.TakeSnapshot(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Error("analytics_snapshot_error", zap.Error(err))
+		case err == nil:
+			logger.Info("analytics_snapshot", zap.Int64("types", types))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package app implements the segmentation-api commands. They share the
+// wiring of configuration, logging and database, and are run by the
+// cmd/segmentation-api binary as subcommands; cmd/api, cmd/processor and
+// cmd/config are shims kept for existing deployments.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	"segmentation-api/internal/config"
+	lgr "segmentation-api/internal/logger"
+	"segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/secrets"
+)
+
+// Name is the name of the binary, used in usage and flag help
+const Name = "segmentation-api"
+
+// command is a subcommand; run returns config.ErrHelp after printing the help
+type command struct {
+	name    string
+	summary string
+	run     func(name string, args []string) error
+}
+
+var commands = []command{
+	{name: "serve", summary: "run the HTTP API", run: serve},
+	{name: "import", summary: "import the CSV data file (processor), or restore a backup", run: importData},
+	{name: "export", summary: "back up the segmentations to compressed NDJSON", run: exportData},
+	{name: "reindex", summary: "load every segmentation into the search index", run: reindexData},
+	{name: "migrate", summary: "manage the schema migrations (up, down, status, create)", run: migrate},
+	{name: "seed", summary: "fill the database with fake users for development and QA", run: seedData},
+	{name: "doctor", summary: "check configuration, database and filesystem before a deployment", run: runDoctor},
+	{name: "config", summary: "print the resolved configuration (config dump)", run: configCmd},
+}
+
+// exitError makes Main exit with code instead of 1 after printing err
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [flags]\n\ncommands:\n", Name)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"%s <command> --help\" for the flags of a command.\n", Name)
+}
+
+// Main runs the subcommand named by args[0] and returns the exit code
+func Main(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
+	}
+	switch args[0] {
+	case "help", "-h", "--help":
+		usage(os.Stdout)
+		return 0
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(Name+" "+c.name, args[1:])
+		if err == nil || errors.Is(err, config.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			return exit.code
+		}
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", Name, args[0])
+	usage(os.Stderr)
+	return 2
+}
+
+// loadConfig resolves the configuration of a command and checks it;
+// requireDB is false for commands that do not connect to the database
+func loadConfig(name string, args []string, requireDB bool) (*config.Config, error) {
+	return parseConfig(config.Flags(name), args, requireDB)
+}
+
+// parseConfig is loadConfig for commands with flags of their own, added to
+// fs (created by config.Flags) before the call
+func parseConfig(fs *pflag.FlagSet, args []string, requireDB bool) (*config.Config, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadFlags(fs)
+	if err == nil {
+		err = cfg.Validate(requireDB)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config_error: %w", err)
+	}
+	return cfg, nil
+}
+
+// openDatabase connects to MySQL logging through logger, with slow queries
+// in their own file (fields are added to each of its lines). The returned
+// closer releases the slow query log.
+func openDatabase(
+	ctx context.Context,
+	cfg *config.Config,
+	logger *zap.Logger,
+	level gormLogger.LogLevel,
+	fields ...zap.Field,
+) (*gorm.DB, io.Closer, error) {
+	slowLog, slowThreshold, slowFile, err := lgr.NewSlowQueryLogger(cfg.Log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("slow query log: %w", err)
+	}
+	gormLog := lgr.NewGorm(logger, slowLog.With(fields...), slowThreshold, level)
+
+	db, err := connect(ctx, cfg, gormLog, logger)
+	if err != nil {
+		slowFile.Close()
+		return nil, nil, err
+	}
+	return db, slowFile, nil
+}
+
+// connect opens the connection pool. With vault.db_path the credentials
+// come from Vault and are renewed until ctx is done.
+func connect(ctx context.Context, cfg *config.Config, gormLog gormLogger.Interface, logger *zap.Logger) (*gorm.DB, error) {
+	var connOpts []mysql.ConnOption
+	if cfg.Vault.Enabled() {
+		vault := secrets.NewVault(cfg.Vault)
+		if err := vault.Fetch(ctx); err != nil {
+			return nil, fmt.Errorf("vault credentials: %w", err)
+		}
+		go vault.Run(ctx, func(err error) {
+			logger.Error("vault_renewal_error", zap.Error(err))
+		})
+		connOpts = append(connOpts, mysql.WithCredentials(vault.Credentials))
+	}
+	return mysql.NewMySQL(cfg.DB, gormLog, connOpts...)
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain_Dispatch(t *testing.T) {
+	t.Setenv("DB_HOST", "")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no command", args: nil, want: 2},
+		{name: "unknown command", args: []string{"deploy"}, want: 2},
+		{name: "help", args: []string{"help"}, want: 0},
+		{name: "command help", args: []string{"serve", "--help"}, want: 0},
+		{name: "unknown flag", args: []string{"import", "--nope"}, want: 1},
+		{name: "invalid import mode", args: []string{"import", "--mode=forever"}, want: 1},
+		{name: "missing database config", args: []string{"migrate"}, want: 1},
+		{name: "unknown migrate command", args: []string{"migrate", "sideways"}, want: 1},
+		{name: "migrate create without name", args: []string{"migrate", "create"}, want: 1},
+		{name: "migrate create", args: []string{"migrate", "create", "add_index", t.TempDir()}, want: 0},
+		{name: "migrate partition dry run", args: []string{"migrate", "partition", "16", "--dry-run", "--log.dir", t.TempDir()}, want: 0},
+		{name: "migrate partition without database config", args: []string{"migrate", "partition", "16"}, want: 1},
+		{name: "migrate rekey-names without database config", args: []string{"migrate", "rekey-names", "--dry-run"}, want: 1},
+		{name: "export help", args: []string{"export", "--help"}, want: 0},
+		{name: "restore without database config", args: []string{"import", "--restore"}, want: 1},
+		{name: "doctor without database config", args: []string{"doctor", "--log.dir", t.TempDir()}, want: 1},
+		{name: "config without dump", args: []string{"config"}, want: 1},
+		{name: "config dump", args: []string{"config", "dump"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Main(tt.args); got != tt.want {
+				t.Errorf("Main(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMain_ExitCode(t *testing.T) {
+	saved := commands
+	t.Cleanup(func() { commands = saved })
+	commands = append(commands, command{name: "partial", run: func(string, []string) error {
+		return &exitError{code: exitRejected, err: errors.New("rows rejected")}
+	}})
+
+	if got := Main([]string{"partial"}); got != exitRejected {
+		t.Errorf("Main() = %d, want %d", got, exitRejected)
+	}
+}
+
+func TestWriteBackupFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.ndjson.gz")
+
+	_, err := writeBackupFile(path, func(w io.Writer) (int, error) {
+		w.Write([]byte("partial"))
+		return 1, errors.New("connection lost")
+	})
+	if err == nil {
+		t.Fatal("writeBackupFile() should return the write error")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("a failed export should leave no file, got %v", entries)
+	}
+
+	n, err := writeBackupFile(path, func(w io.Writer) (int, error) {
+		_, err := w.Write([]byte("complete"))
+		return 2, err
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("writeBackupFile() = %d, %v", n, err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "complete" {
+		t.Errorf("backup content = %q", got)
+	}
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/archive"
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/export"
+	"segmentation-api/internal/repository/mysql"
+)
+
+// newRunArchiver archives the runs recorded in db to the bucket of cfg
+func newRunArchiver(cfg config.Archive, creds config.AWS, db *gorm.DB, logger *zap.Logger) (*archive.Archiver, error) {
+	sink, err := export.NewS3Sink(export.S3Config{
+		Bucket:      cfg.S3.Bucket,
+		Prefix:      cfg.S3.Prefix,
+		Region:      cfg.S3.Region,
+		Endpoint:    cfg.S3.Endpoint,
+		Tags:        cfg.Tags,
+		Credentials: awsCredentials(creds),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return archive.NewArchiver(sink, mysql.NewRunRepository(db), mysql.NewDeadLetterRepository(db), archive.WithLogger(logger)), nil
+}
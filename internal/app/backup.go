@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"go.uber.org/zap"
+	gormLogger "gorm.io/gorm/logger"
+
+	"segmentation-api/internal/backup"
+	"segmentation-api/internal/config"
+	lgr "segmentation-api/internal/logger"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/repository/mysql"
+)
+
+// exportData implements "export --all": it backs up every segmentation to
+// gzip-compressed NDJSON, in --file or on stdout
+func exportData(name string, args []string) error {
+	fs := config.Flags(name)
+	all := fs.Bool("all", false, "export every segmentation (required)")
+	file := fs.String("file", "-", "backup file to write (- for stdout)")
+	cfg, err := parseConfig(fs, args, true)
+	if err != nil {
+		return err
+	}
+	if !*all {
+		return fmt.Errorf("usage: %s --all [--file backup.ndjson.gz] [flags]", name)
+	}
+
+	return withBackupDB(cfg, func(ctx context.Context, repo repository.BackupRepository, logger *zap.Logger) error {
+		n, err := writeBackupFile(*file, func(w io.Writer) (int, error) {
+			return backup.Export(ctx, repo, w)
+		})
+		if err != nil {
+			logger.Error("export_error", zap.Int("written", n), zap.Error(err))
+			return err
+		}
+		logger.Info("export_finished", zap.Int("segmentations", n), zap.String("file", *file))
+		fmt.Fprintf(os.Stderr, "exported %d segmentations\n", n)
+		return nil
+	})
+}
+
+// restoreBackup implements "import --restore": it writes the records of a
+// backup made by "export --all", read from file or stdin
+func restoreBackup(cfg *config.Config, file string) error {
+	return withBackupDB(cfg, func(ctx context.Context, repo repository.BackupRepository, logger *zap.Logger) error {
+		in := io.ReadCloser(os.Stdin)
+		if file != "-" {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			in = f
+		}
+		defer in.Close()
+
+		n, err := backup.Restore(ctx, repo, in)
+		if err != nil {
+			logger.Error("restore_error", zap.Int("restored", n), zap.Error(err))
+			return err
+		}
+		logger.Info("restore_finished", zap.Int("segmentations", n), zap.String("file", file))
+		fmt.Fprintf(os.Stderr, "restored %d segmentations\n", n)
+		return nil
+	})
+}
+
+// withBackupDB sets up logging and the database for export and restore;
+// the context given to fn is cancelled on SIGINT or SIGTERM
+func withBackupDB(
+	cfg *config.Config,
+	fn func(ctx context.Context, repo repository.BackupRepository, logger *zap.Logger) error,
+) error {
+	logger, logFile, err := lgr.New(cfg.Log)
+	if err != nil {
+		return fmt.Errorf("logger_init_error: %w", err)
+	}
+	defer logFile.Close()
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, slowFile, err := openDatabase(ctx, cfg, logger, gormLogger.Warn)
+	if err != nil {
+		logger.Error("db_init_error", zap.Error(err))
+		return err
+	}
+	defer slowFile.Close()
+
+	if err := migrateOnStart(ctx, cfg, db, logger); err != nil {
+		logger.Error("migration_error", zap.Error(err))
+		return err
+	}
+	return fn(ctx, mysql.NewBackupRepository(db), logger)
+}
+
+// writeBackupFile runs write on path, or on stdout for "-". A file is
+// written next to path and renamed only when write succeeds, so a failed
+// or interrupted export never leaves a truncated backup behind.
+func writeBackupFile(path string, write func(w io.Writer) (int, error)) (int, error) {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := write(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/chat"
+	"segmentation-api/internal/config"
+)
+
+// newChatNotifier posts the runs to the webhook of cfg
+func newChatNotifier(cfg config.Chat, logger *zap.Logger) (*chat.Notifier, error) {
+	return chat.NewNotifier(chat.Config{
+		Kind:       cfg.Kind,
+		WebhookURL: cfg.WebhookURL,
+		Mention:    cfg.Mention,
+		MentionID:  cfg.MentionID,
+		ErrorRate:  cfg.ErrorRate,
+	}, chat.WithTimeout(cfg.Timeout), chat.WithLogger(logger))
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"segmentation-api/internal/config"
+)
+
+// configCmd implements "config dump": it prints the resolved configuration
+// (defaults, config file, environment and flags) as YAML with secrets
+// masked, then checks it
+func configCmd(name string, args []string) error {
+	if len(args) == 0 || args[0] != "dump" {
+		return fmt.Errorf("usage: %s dump [flags]", name)
+	}
+
+	cfg, err := config.Load(name+" dump", args[1:])
+	if errors.Is(err, config.ErrHelp) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("config_error: %w", err)
+	}
+
+	if err := config.Dump(os.Stdout, cfg); err != nil {
+		return fmt.Errorf("config_error: %w", err)
+	}
+
+	// a conexão com o banco não é obrigatória só para inspecionar a config
+	if err := cfg.Validate(false); err != nil {
+		return fmt.Errorf("config_invalid: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package app
+
+import (
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/crm"
+)
+
+// newCRMConnector creates the connector of the CRM in cfg, reading the
+// current segmentations of each user from segs
+func newCRMConnector(cfg config.CRM, segs crm.Segmentations, logger *zap.Logger) (*crm.Connector, error) {
+	return crm.NewConnector(crm.Config{
+		URL:        cfg.URL,
+		Format:     cfg.Format,
+		Token:      cfg.Token,
+		IDProperty: cfg.IDProperty,
+		Fields:     cfg.Fields,
+	}, segs,
+		crm.WithTimeout(cfg.Timeout),
+		crm.WithBatchSize(cfg.BatchSize),
+		crm.WithRateLimit(cfg.RateLimit),
+		crm.WithMaxRetries(cfg.MaxRetries),
+		crm.WithLogger(logger),
+	)
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	gormLogger "gorm.io/gorm/logger"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/doctor"
+	lgr "segmentation-api/internal/logger"
+	"segmentation-api/internal/repository/mysql"
+)
+
+// doctorCheckTimeout bounds each check; the first connection still retries
+// for db.connect_timeout
+const doctorCheckTimeout = 10 * time.Second
+
+// errChecksFailed makes doctor exit 1 after printing its report
+var errChecksFailed = errors.New("doctor: some checks failed")
+
+// runDoctor checks the configuration, the database and the filesystem
+// with the same settings as the other commands, and prints a pass/fail
+// report; it exits 1 when any check fails
+func runDoctor(name string, args []string) error {
+	cfg, err := config.Load(name, args)
+	if err != nil {
+		return err
+	}
+	configErr := cfg.Validate(true)
+
+	checks := []doctor.Check{doctor.Config(configErr)}
+
+	dirs, err := lgr.FileDirs(cfg.Log)
+	if err != nil {
+		checks = append(checks, doctor.Skipped("log_dir", err.Error()))
+	}
+	for _, dir := range dirs {
+		checks = append(checks, doctor.WritableDir("log_dir", dir))
+	}
+	if s := cfg.Processor.SFTP; s.Enabled() {
+		checks = append(checks,
+			doctor.ReadableFile("sftp_key_file", s.KeyFile, "needed by the SFTP source (processor.sftp.key_file)"),
+			doctor.ReadableFile("sftp_known_hosts", s.KnownHosts, "needed by the SFTP source (processor.sftp.known_hosts)"))
+	} else {
+		checks = append(checks, doctor.ReadableFile("data_file", cfg.Processor.DataFile,
+			"only needed by import (processor.data_file / DATAFILEPATH)"))
+	}
+
+	ctx := context.Background()
+	if cfg.DB.Host == "" || cfg.DB.Name == "" {
+		checks = append(checks,
+			doctor.Skipped("database", "db.host and db.name are not set"),
+			doctor.Skipped("privileges", "no database"),
+			doctor.Skipped("migrations", "no database"),
+		)
+	} else if db, err := connect(ctx, cfg, gormLogger.Discard, zap.NewNop()); err != nil {
+		checks = append(checks,
+			doctor.Database(func(context.Context) error { return err }),
+			doctor.Skipped("privileges", "no database connection"),
+			doctor.Skipped("migrations", "no database connection"),
+		)
+	} else {
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+		checks = append(checks,
+			doctor.Database(sqlDB.PingContext),
+			doctor.Privileges(
+				func(ctx context.Context, required ...string) ([]string, error) {
+					return mysql.MissingPrivileges(ctx, db, required...)
+				},
+				mysql.DataPrivileges, mysql.MigrationPrivileges, cfg.DB.MigrateOnStart,
+			),
+			doctor.Migrations(pendingMigrations(db), cfg.DB.MigrateOnStart),
+		)
+	}
+
+	if doctor.Print(os.Stdout, doctor.Run(ctx, doctorCheckTimeout, checks)) {
+		return errChecksFailed
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/grpcapi"
+	"segmentation-api/internal/service"
+)
+
+// grpcServer is the running gRPC API of serve
+type grpcServer struct {
+	api *grpcapi.Server
+	srv *grpc.Server
+}
+
+// startGRPC serves the gRPC API on cfg.Port in the background
+func startGRPC(cfg config.GRPC, svc *service.SegmentationService, maintenance *middleware.Maintenance, logger *zap.Logger) (*grpcServer, error) {
+	lis, err := net.Listen("tcp", ":"+cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+	api := grpcapi.NewServer(svc,
+		grpcapi.WithBatchSize(cfg.BatchSize),
+		grpcapi.WithMaxRecvBytes(cfg.MaxMessageBytes),
+		grpcapi.WithMaintenance(maintenance),
+		grpcapi.WithLogger(logger),
+	)
+	srv := api.GRPCServer()
+	go func() {
+		logger.Info("Starting gRPC server", zap.String("port", cfg.Port))
+		if err := srv.Serve(lis); err != nil {
+			logger.Error("grpc_server_error", zap.Error(err))
+		}
+	}()
+	return &grpcServer{api: api, srv: srv}, nil
+}
+
+// drain reports the services as not serving through the health service,
+// during the drain of the HTTP server
+func (g *grpcServer) drain() {
+	g.api.Drain()
+}
+
+// stop waits for the streams in flight until ctx is done, then closes
+// the ones left
+func (g *grpcServer) stop(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		g.srv.GracefulStop()
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		g.srv.Stop()
+		<-stopped
+	}
+}
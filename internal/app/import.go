@@ -0,0 +1,602 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	gormLogger "gorm.io/gorm/logger"
+
+	"segmentation-api/internal/archive"
+	"segmentation-api/internal/chat"
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/health"
+	lgr "segmentation-api/internal/logger"
+	"segmentation-api/internal/metrics"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/processor"
+	"segmentation-api/internal/reporting"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/runreport"
+	"segmentation-api/internal/service"
+	"segmentation-api/internal/tracing"
+)
+
+// Códigos de saída do import no modo oneshot, para pipelines; 0 é um run
+// limpo e 1 uma falha, como nos outros comandos
+const (
+	// exitRejected: o run terminou, mas rejeitou linhas (ver dead_letters)
+	exitRejected = 3
+	// exitCancelled: o run foi interrompido por SIGINT ou SIGTERM
+	exitCancelled = 4
+)
+
+// importData roda o processor sobre o arquivo de dados (processor.data_file)
+// ou, com --restore, restaura um backup feito por "export --all". Com
+// --mode=daemon (processor.mode) o processor fica residente e reimporta o
+// arquivo quando ele muda ou a cada processor.schedule. Com
+// processor.sftp.host os arquivos vêm do servidor SFTP em vez do arquivo
+// de dados: o oneshot importa os que houver e o daemon consulta o servidor
+// a cada processor.sftp.poll_interval.
+func importData(name string, args []string) error {
+	// ─────────────────────────────────────────────
+	// Configuração: defaults, arquivo, ambiente e flags
+	// ─────────────────────────────────────────────
+	fs, restore, file := importFlags(name)
+	cfg, err := parseConfig(fs, args, true)
+	if err != nil {
+		return err
+	}
+	if *restore {
+		return restoreBackup(cfg, *file)
+	}
+	daemon := cfg.Processor.Mode == "daemon"
+	fromSFTP := cfg.Processor.SFTP.Enabled()
+	// origem dos imports, que também dá nome ao lock de líder
+	source := cfg.Processor.DataFile
+	if fromSFTP {
+		source = sftpSource(cfg.Processor.SFTP).Name()
+	}
+
+	fmt.Println("SEGMENTATION PROCESSOR")
+
+	// ─────────────────────────────────────────────
+	// Logs (log.dir, log.sinks, log.format)
+	// ─────────────────────────────────────────────
+	logLevel, err := lgr.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return fmt.Errorf("logger_init_error: %w", err)
+	}
+	logger, logFile, err := lgr.NewWithLevel(cfg.Log, logLevel)
+	if err != nil {
+		return fmt.Errorf("logger_init_error: %w", err)
+	}
+	defer logFile.Close()
+	defer logger.Sync()
+
+	// no oneshot todas as linhas carregam o mesmo run_id (também gravado em
+	// runs e dead_letters); no daemon e no SFTP cada import tem o seu
+	runID := processor.NewRunID()
+	var runFields []zap.Field
+	if !daemon && !fromSFTP {
+		runFields = append(runFields, zap.String("run_id", runID))
+		logger = logger.With(runFields...)
+	}
+
+	// ─────────────────────────────────────────────
+	// Error reporting (desligado sem DSN)
+	// ─────────────────────────────────────────────
+	reporter, err := reporting.New(cfg.Sentry)
+	if err != nil {
+		logger.Fatal("error_reporting_init_error", zap.Error(err))
+	}
+	defer reporter.Flush(2 * time.Second)
+
+	// ─────────────────────────────────────────────
+	// Context + signals
+	// ─────────────────────────────────────────────
+	ctx, stop := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+	)
+	defer stop()
+
+	// ─────────────────────────────────────────────
+	// Tracing (OTEL_EXPORTER_OTLP_ENDPOINT; desligado sem endpoint)
+	// ─────────────────────────────────────────────
+	shutdownTracing, err := tracing.Setup(ctx, "segmentation-processor")
+	if err != nil {
+		logger.Fatal("tracing_init_error", zap.Error(err))
+	}
+	defer func() {
+		// spans pendentes são enviados mesmo após SIGTERM
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("tracing_shutdown_error", zap.Error(err))
+		}
+	}()
+
+	// ─────────────────────────────────────────────
+	// Database
+	// ─────────────────────────────────────────────
+	// GORM loga só warnings (🔥 SEM INSERT OK); queries lentas vão para um
+	// arquivo próprio com o run_id, e credenciais do Vault (vault.db_path)
+	// são renovadas durante o run
+	db, slowFile, err := openDatabase(ctx, cfg, logger, gormLogger.Warn, runFields...)
+	if err != nil {
+		logger.Fatal("db_init_error", zap.Error(err))
+	}
+	defer slowFile.Close()
+
+	// migrations pendentes, a não ser com db.migrate_on_start=false
+	if err := migrateOnStart(ctx, cfg, db, logger); err != nil {
+		logger.Fatal("migration_error", zap.Error(err))
+	}
+
+	// ─────────────────────────────────────────────
+	// Probes (processor.health_addr; desligadas sem endereço)
+	// ─────────────────────────────────────────────
+	// /healthz falha quando o processor fica processor.stall_timeout sem
+	// avançar, para o Kubernetes reiniciar o pod; /readyz também exige o
+	// banco. O daemon ocioso e a réplica à espera do líder seguem batendo.
+	heartbeat := health.NewHeartbeat()
+	if addr := cfg.Processor.HealthAddr; addr != "" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			logger.Fatal("db_handle_error", zap.Error(err))
+		}
+		progress := health.Progress(heartbeat, cfg.Processor.StallTimeout)
+
+		live := health.NewChecker(2 * time.Second)
+		live.Register("progress", progress)
+		ready := health.NewChecker(2 * time.Second)
+		ready.Register("database", health.Database(sqlDB, 100*time.Millisecond))
+		ready.Register("progress", progress)
+
+		stopProbes := serveProbes(addr, live, ready, logger)
+		defer stopProbes()
+	}
+
+	// ─────────────────────────────────────────────
+	// Eleição de líder (processor.leader_election)
+	// ─────────────────────────────────────────────
+	// com várias réplicas só a que obtém o lock do arquivo importa. No
+	// oneshot as outras saem sem erro; no daemon ficam de reserva até o
+	// líder cair. Se o lock se perde, o run em andamento é cancelado e o
+	// processo termina.
+	if cfg.Processor.LeaderElection {
+		lock := mysql.NewLeaderLock(db, mysql.LeaderLockName(source))
+		lead, err := acquireLeader(ctx, lock, cfg.Processor.LeaderWait, daemon, heartbeat.Beat, logger)
+		if errors.Is(err, mysql.ErrNotLeader) || ctx.Err() != nil {
+			logger.Info("leader_not_acquired", zap.String("file", source))
+			fmt.Println("another instance is importing", source)
+			return nil
+		}
+		if err != nil {
+			logger.Fatal("leader_election_error", zap.Error(err))
+		}
+		defer lead.Release()
+		logger.Info("leader_acquired", zap.String("file", source))
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-lead.Lost():
+				logger.Error("leader_lost", zap.Error(lead.Err()))
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// ─────────────────────────────────────────────
+	// Service wiring
+	// ─────────────────────────────────────────────
+	rules, err := service.ValidationRulesFromConfig(cfg.Validation)
+	if err != nil {
+		logger.Fatal("validation_config_error", zap.Error(err))
+	}
+
+	// processor.log_mode=aggregate desliga o log por registro em runs grandes
+	logConfig, err := processor.LogConfigFromConfig(cfg.Processor)
+	if err != nil {
+		logger.Fatal("log_config_error", zap.Error(err))
+	}
+
+	// quedas do banco pausam o run em vez de falhar as linhas
+	outage, err := processor.OutagePolicyFromConfig(cfg.Processor)
+	if err != nil {
+		logger.Fatal("outage_config_error", zap.Error(err))
+	}
+
+	// um run usa um snapshot do registro de tipos; o daemon o atualiza
+	refresh := time.Duration(0)
+	if daemon {
+		refresh = time.Minute
+	}
+	typeRegistry := service.NewTypeRegistry(mysql.NewSegmentationTypeRepository(db), refresh)
+	if err := typeRegistry.Refresh(ctx); err != nil {
+		logger.Fatal("type_registry_error", zap.Error(err))
+	}
+	if daemon {
+		go typeRegistry.Run(ctx, func(err error) {
+			logger.Error("type_registry_refresh_error", zap.Error(err))
+		})
+	}
+
+	// carga inicial: INSERT IGNORE sem ON DUPLICATE KEY UPDATE, só sobre a
+	// tabela vazia, já que as linhas existentes não seriam atualizadas. Com o
+	// arquivo particionado as outras instâncias já podem ter gravado, então
+	// a tabela só é conferida sem partições.
+	repoOpts := []mysql.Option{mysql.WithLogger(logger)}
+	if cfg.DB.CompressionThreshold > 0 {
+		repoOpts = append(repoOpts, mysql.WithCompression(cfg.DB.CompressionThreshold))
+	}
+	if cfg.Processor.InitialLoad {
+		if cfg.Processor.Partitions <= 1 {
+			empty, err := mysql.SegmentationsEmpty(ctx, db)
+			if err != nil {
+				logger.Fatal("initial_load_check_error", zap.Error(err))
+			}
+			if !empty {
+				logger.Fatal("initial_load_table_not_empty",
+					zap.String("hint", "processor.initial_load only applies to an empty segmentations table"))
+			}
+		}
+		repoOpts = append(repoOpts, mysql.WithInsertIgnore())
+		logger.Info("initial_load_enabled")
+	}
+	if cfg.Outbox.Enabled {
+		// os eventos são publicados pelo relay da API
+		repoOpts = append(repoOpts, mysql.WithOutbox())
+	}
+
+	repo := mysql.NewSegmentationRepository(db, repoOpts...)
+	svcOpts := []service.Option{
+		service.WithValidationRules(rules),
+		service.WithTypeRegistry(typeRegistry),
+		service.WithLogger(logger),
+	}
+	if cfg.Notify.Enabled() {
+		// as gravações também são notificadas no Redis, o que invalida o
+		// cache das réplicas da API; o que está na fila sai no fim do run
+		notifier, stopNotifier := startNotifier(cfg.Notify, logger)
+		defer stopNotifier()
+		svcOpts = append(svcOpts, service.WithNotifier(notifier))
+	}
+	svc := service.NewSegmentationService(repo, svcOpts...)
+
+	// relatório de cada run por email (processor.report.smtp_addr), para
+	// quem não acompanha os dashboards
+	var mailer *runreport.Mailer
+	if cfg.Processor.Report.Enabled() {
+		mailer, err = newRunMailer(cfg.Processor.Report, db, logger)
+		if err != nil {
+			logger.Fatal("run_report_config_error", zap.Error(err))
+		}
+	}
+	// cópia do arquivo, das linhas rejeitadas e do resumo de cada run bem
+	// sucedido no S3 (processor.archive.s3.bucket), para auditoria
+	var archiver *archive.Archiver
+	if cfg.Processor.Archive.Enabled() {
+		archiver, err = newRunArchiver(cfg.Processor.Archive, cfg.AWS, db, logger)
+		if err != nil {
+			logger.Fatal("archive_config_error", zap.Error(err))
+		}
+	}
+	// início e fim de cada run no Slack ou Teams (processor.chat.webhook_url),
+	// com menção quando o run falha ou rejeita linhas demais
+	var chatNotifier *chat.Notifier
+	if cfg.Processor.Chat.Enabled() {
+		chatNotifier, err = newChatNotifier(cfg.Processor.Chat, logger)
+		if err != nil {
+			logger.Fatal("chat_config_error", zap.Error(err))
+		}
+	}
+
+	// ─────────────────────────────────────────────
+	// Processor
+	// ─────────────────────────────────────────────
+	// importFile importa file, gravado em runs como source, e devolve os
+	// contadores do run, ou nil se não sobrou partição do job para este
+	// processo. O resultado também vai
+	// para o Pushgateway (pushgateway.url), já que no oneshot o processo
+	// termina antes de qualquer scrape, para o email do run e para o chat;
+	// o arquivo de um run bem sucedido é arquivado no S3.
+	// o paralelismo de cada run vem de tuning, trocado pelo reload do daemon
+	var tuning atomic.Pointer[config.Processor]
+	tuning.Store(&cfg.Processor)
+	importFile := func(ctx context.Context, runID, file, source string, logger *zap.Logger) (*processor.RunStats, error) {
+		tune := tuning.Load()
+		runMetrics := prometheus.NewRegistry()
+		runs := metrics.InstrumentRunRepository(
+			mysql.NewRunRepository(db),
+			metrics.NewRunMetrics(runMetrics),
+		)
+
+		logger.Info("processor_started")
+		if chatNotifier != nil && cfg.Processor.Chat.NotifyStart {
+			if cerr := chatNotifier.Started(ctx, runID, source); cerr != nil {
+				logger.Error("chat_post_error", zap.Error(cerr))
+			}
+		}
+		runOpts := []processor.Option{
+			processor.WithFile(file),
+			processor.WithSource(source),
+			processor.WithRunID(runID),
+			processor.WithRunStore(runs),
+			processor.WithDeadLetters(mysql.NewDeadLetterRepository(db)),
+			processor.WithErrorReporter(reporter),
+			processor.WithLogConfig(logConfig),
+			processor.WithHeartbeat(heartbeat.Beat),
+			processor.WithPartition(cfg.Processor.JobID, cfg.Processor.Partition, cfg.Processor.Partitions),
+			processor.WithBatching(tune.BatchSize, tune.FlushInterval),
+			processor.WithReadAhead(tune.ReadAhead),
+			processor.WithWorkers(tune.Workers),
+			processor.WithDrainTimeout(cfg.Processor.DrainTimeout),
+			processor.WithOutagePause(outage, func(ctx context.Context) error {
+				return mysql.Ping(ctx, db)
+			}),
+		}
+		if cfg.Processor.Transactional {
+			// tudo ou nada: uma linha rejeitada desfaz o arquivo inteiro
+			runOpts = append(runOpts, processor.WithTransaction())
+		}
+		stats, err := processor.Run(ctx, svc, logger, runOpts...)
+		if errors.Is(err, repository.ErrNoPartition) {
+			logger.Info("no_partition_left", zap.String("job_id", cfg.Processor.JobID))
+			fmt.Println("every partition of job", cfg.Processor.JobID, "is claimed")
+			return nil, nil
+		}
+
+		// arquivo particionado: cada partição tem seu grupo no Pushgateway
+		jobID := runID
+		if cfg.Processor.Partitions > 1 {
+			jobID = cfg.Processor.JobID
+		}
+		run := jobProgress(context.WithoutCancel(ctx), runs, jobID, runID, cfg.Processor.Partitions, logger)
+
+		if pusher := metrics.NewPusher(cfg.Pushgateway.URL, cfg.Pushgateway.Job, runMetrics); pusher != nil {
+			if run != nil && cfg.Processor.Partitions > 1 {
+				pusher.Grouping("job_id", jobID).Grouping("partition", strconv.Itoa(run.Partition))
+			}
+			pushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if perr := pusher.PushContext(pushCtx); perr != nil {
+				logger.Error("pushgateway_error", zap.Error(perr))
+			}
+			cancel()
+		}
+
+		// o arquivo é lido de novo para o arquivamento, antes de o SFTP
+		// apagar a cópia local; uma falha só é registrada, os dados já estão
+		// no banco
+		if archiver != nil && run != nil {
+			if _, aerr := archiver.Archive(context.WithoutCancel(ctx), run, file); aerr != nil {
+				logger.Error("archive_error", zap.Error(aerr))
+			}
+		}
+
+		// o email sai também de um run cancelado; uma falha no envio só é
+		// registrada
+		if mailer != nil {
+			mailCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.Processor.Report.Timeout)
+			if merr := mailer.Send(mailCtx, runID); merr != nil {
+				logger.Error("run_report_error", zap.Error(merr))
+			}
+			cancel()
+		}
+		if chatNotifier != nil && run != nil {
+			if cerr := chatNotifier.Finished(context.WithoutCancel(ctx), run); cerr != nil {
+				logger.Error("chat_post_error", zap.Error(cerr))
+			}
+		}
+		return &stats, err
+	}
+
+	if daemon {
+		// SIGHUP recarrega log.level na hora e o paralelismo a partir do
+		// próximo import; o run em andamento segue com o que começou
+		reload := newReloader(cfg, func() (*config.Config, error) {
+			fs, _, _ := importFlags(name)
+			return parseConfig(fs, args, true)
+		}, logger, append([]reloadable{logLevelReloadable(logLevel)}, processorReloadables(&tuning)...)...)
+		stopReload := reload.watch(ctx)
+		defer stopReload()
+	}
+
+	// SFTP: cada arquivo novo é baixado e importado no seu próprio run; a
+	// origem gravada em runs é o arquivo remoto, o que evita reimportá-lo
+	if fromSFTP {
+		poller := newSFTPPoller(cfg.Processor.SFTP, db, logger)
+		importRemote := func(ctx context.Context, file, source string) error {
+			runID := processor.NewRunID()
+			_, err := importFile(ctx, runID, file, source, logger.With(zap.String("run_id", runID), zap.String("source", source)))
+			return err
+		}
+		if daemon {
+			logger.Info("processor_sftp_started", zap.String("source", source),
+				zap.Duration("poll_interval", cfg.Processor.SFTP.PollInterval))
+			poller.Run(ctx, cfg.Processor.SFTP.PollInterval, importRemote, heartbeat.Beat)
+			logger.Info("processor_daemon_stopped")
+			return nil
+		}
+		imported, err := poller.Poll(ctx, importRemote)
+		switch {
+		case ctx.Err() != nil:
+			logger.Warn("processor_cancelled", zap.Int("imported", imported))
+			return &exitError{code: exitCancelled, err: errors.New("import: cancelled before the end of the SFTP poll")}
+		case err != nil:
+			reporter.Flush(2 * time.Second)
+			logger.Error("sftp_poll_error", zap.Int("imported", imported), zap.Error(err))
+			return fmt.Errorf("import: %w", err)
+		}
+		logger.Info("processor_finished_successfully", zap.Int("imported", imported))
+		return nil
+	}
+
+	if daemon {
+		logger.Info("processor_daemon_started",
+			zap.Duration("watch_interval", cfg.Processor.WatchInterval),
+			zap.Duration("schedule", cfg.Processor.Schedule),
+		)
+		processor.Daemon(ctx, processor.DaemonConfig{
+			File:          cfg.Processor.DataFile,
+			WatchInterval: cfg.Processor.WatchInterval,
+			Schedule:      cfg.Processor.Schedule,
+			Heartbeat:     heartbeat.Beat,
+		}, func(ctx context.Context) error {
+			runID := processor.NewRunID()
+			_, err := importFile(ctx, runID, cfg.Processor.DataFile, cfg.Processor.DataFile, logger.With(zap.String("run_id", runID)))
+			return err
+		}, logger)
+		logger.Info("processor_daemon_stopped")
+		return nil
+	}
+
+	stats, err := importFile(ctx, runID, cfg.Processor.DataFile, cfg.Processor.DataFile, logger)
+	if err != nil {
+		// Fatal sai sem rodar os defers; o erro já foi reportado pelo Run
+		reporter.Flush(2 * time.Second)
+		logger.Fatal("processor_error", zap.Error(err))
+	}
+
+	switch {
+	case stats == nil:
+		// sem partição livre
+		return nil
+	case ctx.Err() != nil:
+		logger.Warn("processor_cancelled")
+		return &exitError{code: exitCancelled, err: errors.New("import: cancelled before the end of the file")}
+	case stats.Invalid > 0 || stats.Failed > 0:
+		logger.Warn("processor_finished_with_rejections", zap.Uint64("invalid", stats.Invalid), zap.Uint64("failed", stats.Failed))
+		return &exitError{code: exitRejected, err: fmt.Errorf(
+			"import: %d invalid and %d failed rows, see the dead_letters of run %s", stats.Invalid, stats.Failed, stats.RunID)}
+	}
+
+	logger.Info("processor_finished_successfully")
+	return nil
+}
+
+// importFlags são as flags do import: as da configuração, --restore e
+// --file
+func importFlags(name string) (fs *pflag.FlagSet, restore *bool, file *string) {
+	fs = config.Flags(name)
+	restore = fs.Bool("restore", false, `restore a backup made by "export --all" instead of importing the CSV`)
+	file = fs.String("file", "-", "backup file read by --restore (- for stdin)")
+	// --mode é um atalho de --processor.mode
+	fs.SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "mode" {
+			name = "processor.mode"
+		}
+		return pflag.NormalizedName(name)
+	})
+	return fs, restore, file
+}
+
+// processorReloadables são as configurações do daemon aplicadas pelo
+// reload, guardadas em tuning para os próximos runs
+func processorReloadables(tuning *atomic.Pointer[config.Processor]) []reloadable {
+	setting := func(key string, value func(p *config.Processor) any, set func(dst, src *config.Processor)) reloadable {
+		return reloadable{
+			key:   key,
+			value: func(c *config.Config) any { return value(&c.Processor) },
+			apply: func(c *config.Config) {
+				// os reloads são serializados pelo reloader
+				next := *tuning.Load()
+				set(&next, &c.Processor)
+				tuning.Store(&next)
+			},
+			set: func(dst, src *config.Config) { set(&dst.Processor, &src.Processor) },
+		}
+	}
+	return []reloadable{
+		setting("processor.workers",
+			func(p *config.Processor) any { return p.Workers },
+			func(dst, src *config.Processor) { dst.Workers = src.Workers }),
+		setting("processor.batch_size",
+			func(p *config.Processor) any { return p.BatchSize },
+			func(dst, src *config.Processor) { dst.BatchSize = src.BatchSize }),
+		setting("processor.flush_interval",
+			func(p *config.Processor) any { return p.FlushInterval },
+			func(dst, src *config.Processor) { dst.FlushInterval = src.FlushInterval }),
+		setting("processor.read_ahead",
+			func(p *config.Processor) any { return p.ReadAhead },
+			func(dst, src *config.Processor) { dst.ReadAhead = src.ReadAhead }),
+	}
+}
+
+// acquireLeader obtém o lock de líder. No oneshot espera até wait; no
+// daemon a réplica fica de reserva, tentando de novo até obter o lock ou
+// ctx terminar, e bate o heartbeat a cada tentativa.
+func acquireLeader(
+	ctx context.Context,
+	lock *mysql.LeaderLock,
+	wait time.Duration,
+	daemon bool,
+	beat func(),
+	logger *zap.Logger,
+) (*mysql.Leadership, error) {
+	if !daemon {
+		return lock.Acquire(ctx, wait)
+	}
+	if wait <= 0 {
+		wait = 30 * time.Second
+	}
+	for {
+		beat()
+		lead, err := lock.Acquire(ctx, wait)
+		if !errors.Is(err, mysql.ErrNotLeader) {
+			return lead, err
+		}
+		logger.Debug("leader_standby")
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+// jobProgress busca os runs do job e devolve o deste run, ou nil se ele
+// não foi achado. Com o arquivo particionado registra as partições que
+// ainda faltam; a última a terminar registra job_finished.
+func jobProgress(ctx context.Context, runs repository.RunRepository, jobID, runID string, partitions int, logger *zap.Logger) *models.Run {
+	jobRuns, err := runs.JobRuns(ctx, jobID)
+	if err != nil {
+		logger.Error("job_runs_error", zap.Error(err))
+		return nil
+	}
+
+	var run *models.Run
+	for i := range jobRuns {
+		if jobRuns[i].ID == runID {
+			run = &jobRuns[i]
+		}
+	}
+	if partitions <= 1 {
+		return run
+	}
+
+	partition := -1
+	if run != nil {
+		partition = run.Partition
+	}
+	if pending := processor.PendingPartitions(jobRuns, partitions); len(pending) > 0 {
+		logger.Info("job_progress", zap.Int("partition", partition), zap.Ints("pending_partitions", pending))
+	} else {
+		logger.Info("job_finished", zap.String("job_id", jobID), zap.Int("partitions", partitions))
+	}
+	return run
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"os"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/imports"
+	"segmentation-api/internal/processor"
+	"segmentation-api/internal/reporting"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/service"
+)
+
+// newImporter creates the importer of POST /imports. Its files are
+// imported by the processor in the API process, through svc and with the
+// processor settings of the import command; S3 URLs are accepted when the
+// AWS credentials are set.
+func newImporter(cfg *config.Config, db *gorm.DB, svc *service.SegmentationService, runs repository.RunRepository, reporter reporting.Reporter, logger *zap.Logger) (*imports.Importer, error) {
+	if err := os.MkdirAll(cfg.Import.Dir, 0o750); err != nil {
+		return nil, err
+	}
+	logConfig, err := processor.LogConfigFromConfig(cfg.Processor)
+	if err != nil {
+		return nil, err
+	}
+	outage, err := processor.OutagePolicyFromConfig(cfg.Processor)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters := mysql.NewDeadLetterRepository(db)
+
+	run := func(ctx context.Context, runID, file, source string) error {
+		opts := []processor.Option{
+			processor.WithFile(file),
+			processor.WithSource(source),
+			processor.WithRunID(runID),
+			processor.WithCreatedRun(),
+			processor.WithRunStore(runs),
+			processor.WithDeadLetters(deadLetters),
+			processor.WithErrorReporter(reporter),
+			processor.WithLogConfig(logConfig),
+			processor.WithBatching(cfg.Processor.BatchSize, cfg.Processor.FlushInterval),
+			processor.WithReadAhead(cfg.Processor.ReadAhead),
+			processor.WithWorkers(cfg.Processor.Workers),
+			processor.WithDrainTimeout(cfg.Processor.DrainTimeout),
+			processor.WithOutagePause(outage, func(ctx context.Context) error {
+				return mysql.Ping(ctx, db)
+			}),
+		}
+		if cfg.Processor.Transactional {
+			opts = append(opts, processor.WithTransaction())
+		}
+		_, err := processor.Run(ctx, svc, logger.With(zap.String("run_id", runID)), opts...)
+		return err
+	}
+
+	opts := []imports.Option{
+		imports.WithMaxBytes(cfg.Import.MaxBytes),
+		imports.WithLogger(logger),
+	}
+	if cfg.AWS.AccessKeyID != "" {
+		source, err := imports.NewS3Source(imports.S3Config{
+			Region:      cfg.Import.S3Region,
+			Endpoint:    cfg.Import.S3Endpoint,
+			Credentials: awsCredentials(cfg.AWS),
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, imports.WithS3(source))
+	}
+	return imports.NewImporter(cfg.Import.Dir, runs, run, opts...), nil
+}
@@ -0,0 +1,25 @@
+package app
+
+import (
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/kafka"
+)
+
+// newKafkaPublisher creates the publisher of the outbox events to the
+// Kafka topic in cfg
+func newKafkaPublisher(cfg config.Kafka, logger *zap.Logger) (*kafka.Publisher, error) {
+	return kafka.NewPublisher(kafka.Config{
+		URL:               cfg.URL,
+		Topic:             cfg.Topic,
+		Format:            cfg.Format,
+		SchemaRegistryURL: cfg.SchemaRegistryURL,
+		Compatibility:     cfg.Compatibility,
+		Username:          cfg.Username,
+		Password:          cfg.Password,
+	},
+		kafka.WithTimeout(cfg.Timeout),
+		kafka.WithLogger(logger),
+	)
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	mysqlRepo "segmentation-api/internal/repository/mysql"
+)
+
+// leaderLockWait is how long a standby instance waits on a leader lock per
+// attempt
+const leaderLockWait = 30 * time.Second
+
+// runAsLeader runs run on the single instance holding the MySQL lock
+// lockName, until ctx is done. The others stand by and take over when the
+// holder stops or loses its database connection, which cancels the
+// context of run. Its events are logged as <event>_started, _stopped and
+// so on.
+func runAsLeader(
+	ctx context.Context,
+	db *gorm.DB,
+	lockName, event string,
+	logger *zap.Logger,
+	run func(ctx context.Context),
+) {
+	lock := mysqlRepo.NewLeaderLock(db, lockName)
+
+	for ctx.Err() == nil {
+		lead, err := lock.Acquire(ctx, leaderLockWait)
+		if errors.Is(err, mysqlRepo.ErrNotLeader) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			logger.Warn(event+"_lock_error", zap.Error(err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(leaderLockWait):
+			}
+			continue
+		}
+
+		logger.Info(event + "_started")
+		leadCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-lead.Lost():
+				logger.Error(event+"_lock_lost", zap.Error(lead.Err()))
+				cancel()
+			case <-leadCtx.Done():
+			}
+		}()
+		run(leadCtx)
+		cancel()
+		if err := lead.Release(); err != nil {
+			logger.Warn(event+"_release_error", zap.Error(err))
+		}
+		logger.Info(event + "_stopped")
+	}
+}
@@ -0,0 +1,313 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	"segmentation-api/internal/config"
+	lgr "segmentation-api/internal/logger"
+	"segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/service"
+)
+
+const migrateUsage = `usage: %[1]s up [flags]          apply the pending migrations
+       %[1]s down [n] [flags]    revert the last n applied migrations (default 1)
+       %[1]s status [flags]      list the migrations and when they were applied
+       %[1]s create <name> [dir] write empty up/down files (default dir %[2]s)
+       %[1]s partition [n]       partition segmentations by hash(user_id) into n
+                                 partitions (0 removes it); without n, show the count
+       %[1]s rekey-names [flags] recompute the name keys of stored rows after a
+                                 change of validation.name_fold_case/_strip_accents
+
+"%[1]s" alone is "%[1]s up".`
+
+// migrate manages the versioned schema migrations
+func migrate(name string, args []string) error {
+	sub := "up"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		sub, args = args[0], args[1:]
+	}
+
+	if sub == "create" {
+		return createMigration(name, args)
+	}
+	if sub != "up" && sub != "down" && sub != "status" && sub != "partition" && sub != "rekey-names" {
+		return fmt.Errorf(migrateUsage, name, mysql.MigrationsDir)
+	}
+
+	fs := config.Flags(name + " " + sub)
+	var dryRun bool
+	switch sub {
+	case "partition":
+		fs.BoolVar(&dryRun, "dry-run", false, "print the DDL instead of running it")
+	case "rekey-names":
+		fs.BoolVar(&dryRun, "dry-run", false, "count the rows whose key would change without updating them")
+	}
+	// partition --dry-run only prints the DDL, without the database
+	cfg, err := parseConfig(fs, args, sub != "partition")
+	if err != nil {
+		return err
+	}
+	if sub == "partition" && !dryRun {
+		if err := cfg.Validate(true); err != nil {
+			return fmt.Errorf("config_error: %w", err)
+		}
+	}
+
+	steps := 1
+	partitions := -1 // partition without n prints the current count
+	switch {
+	case sub == "down" && fs.NArg() == 1:
+		steps, err = strconv.Atoi(fs.Arg(0))
+		if err != nil || steps < 1 {
+			return fmt.Errorf("%s down: n must be a positive number, got %q", name, fs.Arg(0))
+		}
+	case sub == "partition" && fs.NArg() == 1:
+		partitions, err = strconv.Atoi(fs.Arg(0))
+		if err != nil || partitions < 0 {
+			return fmt.Errorf("%s partition: n must be 0 or a positive number, got %q", name, fs.Arg(0))
+		}
+	case fs.NArg() > 0:
+		return fmt.Errorf(migrateUsage, name, mysql.MigrationsDir)
+	}
+
+	if dryRun && partitions >= 0 {
+		stmts, err := mysql.PartitionStatements(partitions)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range stmts {
+			fmt.Println(stmt + ";")
+		}
+		return nil
+	}
+
+	logger, logFile, err := lgr.New(cfg.Log)
+	if err != nil {
+		return fmt.Errorf("logger_init_error: %w", err)
+	}
+	defer logFile.Close()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	db, slowFile, err := openDatabase(ctx, cfg, logger, gormLogger.Warn)
+	if err != nil {
+		logger.Error("db_init_error", zap.Error(err))
+		return err
+	}
+	defer slowFile.Close()
+
+	migrator, err := mysql.NewMigrator(db, mysql.Migrations, migratorOptions(cfg)...)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			logger.Info("migration_applied", zap.Int64("version", m.Version), zap.String("name", m.Name))
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			logger.Error("migration_error", zap.Error(err))
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			logger.Info("migration_reverted", zap.Int64("version", m.Version), zap.String("name", m.Name))
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			logger.Error("migration_error", zap.Error(err))
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+	case "status":
+		migrations, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrations(os.Stdout, migrations)
+	case "partition":
+		return partitionSegmentations(ctx, db, partitions, logger)
+	case "rekey-names":
+		return rekeyNames(ctx, cfg, db, dryRun, logger)
+	}
+	return nil
+}
+
+// rekeyNames recomputes the name_key of every segmentation with the
+// configured name policy. Rows whose new key is taken by another row are
+// listed and left alone.
+func rekeyNames(ctx context.Context, cfg *config.Config, db *gorm.DB, dryRun bool, logger *zap.Logger) error {
+	rules, err := service.ValidationRulesFromConfig(cfg.Validation)
+	if err != nil {
+		return err
+	}
+	names := rules.Names
+	key := func(name string) string {
+		return names.Key(names.Normalize(name))
+	}
+
+	start := time.Now()
+	result, err := mysql.RekeyNames(ctx, db, key, dryRun)
+	if err != nil {
+		logger.Error("rekey_error", zap.Int("scanned", result.Scanned), zap.Error(err))
+		return err
+	}
+	for _, c := range result.Conflicts {
+		logger.Warn("rekey_conflict",
+			zap.Uint64("id", c.ID),
+			zap.Uint64("user_id", c.UserID),
+			zap.String("seg_type", c.SegmentationType),
+			zap.String("seg_name", c.SegmentationName),
+			zap.String("key", c.Key),
+		)
+		fmt.Printf("conflict: id %d (user %d, %s %q) keeps its key, %q is taken\n",
+			c.ID, c.UserID, c.SegmentationType, c.SegmentationName, c.Key)
+	}
+	logger.Info("rekey_finished",
+		zap.Bool("dry_run", dryRun),
+		zap.Int("scanned", result.Scanned),
+		zap.Int("rekeyed", result.Rekeyed),
+		zap.Int("conflicts", len(result.Conflicts)),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	if dryRun {
+		fmt.Printf("%d of %d segmentations would get a new name key\n", result.Rekeyed, result.Scanned)
+		return nil
+	}
+	fmt.Printf("rekeyed %d of %d segmentations, %d conflicts\n", result.Rekeyed, result.Scanned, len(result.Conflicts))
+	if len(result.Conflicts) > 0 {
+		return fmt.Errorf("%d segmentations keep their old name key", len(result.Conflicts))
+	}
+	return nil
+}
+
+// partitionSegmentations changes the number of hash partitions of the
+// segmentations table, or prints it when partitions is negative
+func partitionSegmentations(ctx context.Context, db *gorm.DB, partitions int, logger *zap.Logger) error {
+	if partitions < 0 {
+		current, err := mysql.SegmentationPartitions(ctx, db)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			fmt.Println("segmentations is not partitioned")
+		} else {
+			fmt.Printf("segmentations has %d hash(user_id) partitions\n", current)
+		}
+		return nil
+	}
+
+	// the whole table is rebuilt, which takes a long time on large tables
+	logger.Info("partitioning_started", zap.Int("partitions", partitions))
+	start := time.Now()
+	previous, err := mysql.PartitionSegmentations(ctx, db, partitions)
+	if err != nil {
+		logger.Error("partitioning_error", zap.Error(err))
+		return err
+	}
+	if previous == partitions {
+		fmt.Printf("segmentations already has %d partitions\n", partitions)
+		return nil
+	}
+	logger.Info("partitioning_finished",
+		zap.Int("previous", previous),
+		zap.Int("partitions", partitions),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	fmt.Printf("segmentations partitions: %d -> %d\n", previous, partitions)
+	return nil
+}
+
+// createMigration writes the files of a new migration; it needs neither
+// the configuration nor the database
+func createMigration(name string, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf(migrateUsage, name, mysql.MigrationsDir)
+	}
+	dir := mysql.MigrationsDir
+	if len(args) == 2 {
+		dir = args[1]
+	}
+
+	up, down, err := mysql.CreateMigration(dir, args[0], time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Println("created", up)
+	fmt.Println("created", down)
+	return nil
+}
+
+// printMigrations writes the version, name and application time of each
+// migration as a table
+func printMigrations(w io.Writer, migrations []mysql.Migration) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, m := range migrations {
+		applied := "pending"
+		if m.Applied() {
+			applied = time.Unix(m.AppliedAt, 0).UTC().Format(time.RFC3339)
+		}
+		if m.Up == "" {
+			applied += " (files missing)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+	tw.Flush()
+}
+
+// migrateOnStart applies the pending migrations when db.migrate_on_start
+// is set (the default)
+func migrateOnStart(ctx context.Context, cfg *config.Config, db *gorm.DB, logger *zap.Logger) error {
+	if !cfg.DB.MigrateOnStart {
+		return nil
+	}
+	applied, err := mysql.RunMigrations(ctx, db, migratorOptions(cfg)...)
+	for _, m := range applied {
+		logger.Info("migration_applied", zap.Int64("version", m.Version), zap.String("name", m.Name))
+	}
+	return err
+}
+
+// migratorOptions applies db.online_ddl
+func migratorOptions(cfg *config.Config) []mysql.MigratorOption {
+	if cfg.DB.OnlineDDL {
+		return []mysql.MigratorOption{mysql.WithOnlineDDL()}
+	}
+	return nil
+}
+
+// pendingMigrations lists the names of the pending migrations, for the
+// schema health check
+func pendingMigrations(db *gorm.DB) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		pending, err := mysql.PendingMigrations(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(pending))
+		for i, m := range pending {
+			names[i] = fmt.Sprintf("%d_%s", m.Version, m.Name)
+		}
+		return names, nil
+	}
+}
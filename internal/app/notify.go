@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/pubsub"
+	"segmentation-api/internal/service"
+)
+
+// startNotifier publishes the changes given to the returned notifier to
+// Redis until stop is called; stop gives the changes still queued a last
+// attempt and returns once they are sent or dropped
+func startNotifier(cfg config.Notify, logger *zap.Logger) (notifier *pubsub.Notifier, stop func()) {
+	notifier = pubsub.NewNotifier(notifyConfig(cfg), cfg.QueueSize, pubsub.WithLogger(logger))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		notifier.Run(ctx)
+	}()
+	return notifier, func() {
+		cancel()
+		<-done
+	}
+}
+
+// invalidateOnNotify drops from cache the users written by other replicas
+// and by the processor, as they are notified, until ctx is done. After a
+// lost subscription the whole cache is flushed, since notifications
+// published meanwhile are gone.
+func invalidateOnNotify(ctx context.Context, cfg config.Notify, cache *service.UserCache, logger *zap.Logger) {
+	sub := pubsub.NewSubscriber(notifyConfig(cfg), "user:*", pubsub.WithLogger(logger))
+	sub.Run(ctx, func(m pubsub.Message) {
+		cache.Invalidate(m.UserID)
+	}, func() {
+		logger.Info("cache_flushed_after_resubscribe", zap.Int("users", cache.Flush()))
+	})
+}
+
+func notifyConfig(cfg config.Notify) pubsub.Config {
+	return pubsub.Config{
+		Addr:     cfg.RedisAddr,
+		Username: cfg.RedisUsername,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+		TLS:      cfg.RedisTLS,
+		Prefix:   cfg.ChannelPrefix,
+	}
+}
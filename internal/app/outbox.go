@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/outbox"
+	mysqlRepo "segmentation-api/internal/repository/mysql"
+)
+
+// outboxRelayLock is the leader lock of the outbox relay
+const outboxRelayLock = "segmentation_outbox_relay"
+
+// runOutboxRelay publishes the outbox from the instance holding the relay
+// lock, so a single replica relays the events, in order. The others stand
+// by and take over when the holder stops or loses its database connection.
+// Each of alongside runs next to the relay while the instance holds the
+// lock. It returns when ctx is done.
+func runOutboxRelay(
+	ctx context.Context,
+	db *gorm.DB,
+	cfg config.Outbox,
+	publisher outbox.Publisher,
+	logger *zap.Logger,
+	alongside ...func(ctx context.Context),
+) {
+	relay := outbox.NewRelay(mysqlRepo.NewOutboxRepository(db), publisher,
+		outbox.WithBatchSize(cfg.BatchSize),
+		outbox.WithInterval(cfg.PollInterval),
+		outbox.WithRetention(cfg.Retention),
+		outbox.WithLogger(logger),
+	)
+	runAsLeader(ctx, db, outboxRelayLock, "outbox_relay", logger, func(ctx context.Context) {
+		var wg sync.WaitGroup
+		for _, run := range alongside {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run(ctx)
+			}()
+		}
+		relay.Run(ctx)
+		wg.Wait()
+	})
+}
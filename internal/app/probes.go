@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/health"
+)
+
+// serveProbes serves /healthz and /readyz on addr until the returned stop
+// is called; a listen error is logged and the probes then fail, which is
+// what Kubernetes should see
+func serveProbes(addr string, live, ready *health.Checker, logger *zap.Logger) (stop func()) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           health.ProbeHandler(live, ready),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.Info("probe_server_started", zap.String("addr", addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("probe_server_error", zap.Error(err))
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("probe_server_shutdown_error", zap.Error(err))
+		}
+	}
+}
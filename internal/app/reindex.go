@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/repository/elasticsearch"
+)
+
+// reindexBatch is how many segmentations each bulk request of "reindex"
+// carries
+const reindexBatch = 1000
+
+// reindexData implements "reindex": it loads every segmentation into the
+// search index, to fill a new index or repair one that missed events. It
+// can run while the API serves writes: the documents are versioned, so a
+// row read before a newer write never replaces it.
+func reindexData(name string, args []string) error {
+	fs := config.Flags(name)
+	cfg, err := parseConfig(fs, args, true)
+	if err != nil {
+		return err
+	}
+	if !cfg.Search.Enabled() {
+		return fmt.Errorf("search.url (SEARCH_URL) is required by %s", name)
+	}
+
+	return withBackupDB(cfg, func(ctx context.Context, repo repository.BackupRepository, logger *zap.Logger) error {
+		index, err := newSearchIndex(cfg.Search, logger)
+		if err != nil {
+			return err
+		}
+		n := 0
+		err = repo.Scan(ctx, nil, reindexBatch, func(batch []models.Segmentation) error {
+			if err := index.IndexSegmentations(ctx, batch); err != nil {
+				return err
+			}
+			n += len(batch)
+			return nil
+		})
+		if err != nil {
+			logger.Error("reindex_error", zap.Int("indexed", n), zap.Error(err))
+			return err
+		}
+		logger.Info("reindex_finished", zap.Int("segmentations", n), zap.String("index", cfg.Search.Index))
+		fmt.Fprintf(os.Stderr, "indexed %d segmentations\n", n)
+		return nil
+	})
+}
+
+// newSearchIndex opens the search index of cfg
+func newSearchIndex(cfg config.Search, logger *zap.Logger) (*elasticsearch.Index, error) {
+	return elasticsearch.New(elasticsearch.Config{
+		URL:      cfg.URL,
+		Index:    cfg.Index,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		APIKey:   cfg.APIKey,
+	}, &http.Client{Timeout: cfg.Timeout}, elasticsearch.WithLogger(logger))
+}
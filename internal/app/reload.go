@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/config"
+	lgr "segmentation-api/internal/logger"
+)
+
+// errInvalidReload is returned by Reload when the new configuration does
+// not load or validate; the running one is kept
+var errInvalidReload = apperrors.New("configuration not reloaded", apperrors.ErrUnprocessable)
+
+// reloadable is a setting that can change while the process runs; apply
+// is called with the new configuration when value changes, and set copies
+// the setting from src into dst, the configuration reported by Current
+type reloadable struct {
+	key   string
+	value func(*config.Config) any
+	apply func(*config.Config)
+	set   func(dst, src *config.Config)
+}
+
+// reloader resolves the configuration again (config file, environment and
+// the flags of the command line) on SIGHUP or through POST /admin/reload
+// and applies the reloadable settings that changed. Other settings keep
+// their value until the process restarts.
+type reloader struct {
+	mu       sync.Mutex
+	load     func() (*config.Config, error)
+	current  *config.Config
+	settings []reloadable
+	logger   *zap.Logger
+}
+
+func newReloader(current *config.Config, load func() (*config.Config, error), logger *zap.Logger, settings ...reloadable) *reloader {
+	return &reloader{load: load, current: current, settings: settings, logger: logger}
+}
+
+// Reload loads the configuration and applies the settings whose value
+// changed, returning their keys. Settings changed at runtime through the
+// admin API, such as the log level, are only overwritten when their value
+// in the configuration changed too.
+func (r *reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		r.logger.Error("config_reload_error", zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", errInvalidReload, err)
+	}
+
+	// the other settings keep running with their startup value, so only
+	// the applied ones replace theirs
+	applied := *r.current
+	changed := []string{}
+	for _, s := range r.settings {
+		from, to := s.value(r.current), s.value(next)
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		s.apply(next)
+		s.set(&applied, next)
+		changed = append(changed, s.key)
+		r.logger.Warn("config_setting_reloaded",
+			zap.String("key", s.key),
+			zap.Any("from", from),
+			zap.Any("to", to),
+		)
+	}
+	r.current = &applied
+	r.logger.Info("config_reloaded", zap.Strings("changed", changed))
+	return changed, nil
+}
+
+// Current returns the configuration the process runs with: the one it
+// started with, with the settings applied by the reloads since. Keys that
+// are not reloadable keep their startup value even when the file changed.
+func (r *reloader) Current() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// watch calls Reload on every SIGHUP until ctx is done. SIGHUP stays
+// captured until stop is called, so one arriving during the graceful
+// shutdown is ignored instead of killing the process with its default
+// action; callers defer stop to the end of the shutdown.
+func (r *reloader) watch(ctx context.Context) (stop func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				r.Reload()
+			}
+		}
+	}()
+	return func() { signal.Stop(hup) }
+}
+
+// logLevelReloadable applies log.level to level
+func logLevelReloadable(level zap.AtomicLevel) reloadable {
+	return reloadable{
+		key:   "log.level",
+		value: func(c *config.Config) any { return c.Log.Level },
+		apply: func(c *config.Config) {
+			// checked by Validate
+			next, _ := lgr.ParseLevel(c.Log.Level)
+			level.SetLevel(next.Level())
+		},
+		set: func(dst, src *config.Config) { dst.Log.Level = src.Log.Level },
+	}
+}
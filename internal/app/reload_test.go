@@ -0,0 +1,91 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/config"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_NAME", "segmentation")
+	t.Setenv("DB_USER", "app")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	args := []string{"--config", path}
+
+	write("log:\n  level: info\nprocessor:\n  workers: 2\n")
+	cfg, err := loadConfig("import", args, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	var tuning atomic.Pointer[config.Processor]
+	tuning.Store(&cfg.Processor)
+	r := newReloader(cfg, func() (*config.Config, error) {
+		return loadConfig("import", args, true)
+	}, zap.NewNop(), append([]reloadable{logLevelReloadable(level)}, processorReloadables(&tuning)...)...)
+
+	write("log:\n  level: debug\nprocessor:\n  workers: 8\n  batch_size: 100\n  stall_timeout: 1m\n")
+	changed, err := r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(changed)
+	if want := []string{"log.level", "processor.batch_size", "processor.workers"}; !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if level.Level() != zapcore.DebugLevel {
+		t.Errorf("level = %s, want debug", level.Level())
+	}
+	if p := tuning.Load(); p.Workers != 8 || p.BatchSize != 100 || p.FlushInterval != cfg.Processor.FlushInterval {
+		t.Errorf("tuning = %+v", p)
+	}
+
+	// the effective configuration has the applied settings; a key that is
+	// not reloadable keeps the value the process runs with
+	current := r.Current()
+	if current.Log.Level != "debug" || current.Processor.Workers != 8 || current.Processor.BatchSize != 100 {
+		t.Errorf("Current() did not apply the reloaded settings: %+v", current.Processor)
+	}
+	if current.Processor.StallTimeout != 5*time.Minute || cfg.Processor.StallTimeout != 5*time.Minute {
+		t.Errorf("stall_timeout = %s, want the startup 5m", current.Processor.StallTimeout)
+	}
+	if cfg.Processor.Workers != 2 {
+		t.Error("the startup configuration should not be modified")
+	}
+
+	// a level changed through the admin API survives a reload that does
+	// not change log.level
+	level.SetLevel(zapcore.WarnLevel)
+	if changed, err := r.Reload(); err != nil || len(changed) != 0 {
+		t.Errorf("Reload() = %v, %v, want nothing changed", changed, err)
+	}
+	if level.Level() != zapcore.WarnLevel {
+		t.Errorf("level = %s, want warn", level.Level())
+	}
+
+	write("log:\n  level: loud\nprocessor:\n  workers: 1\n")
+	_, err = r.Reload()
+	if !errors.Is(err, errInvalidReload) || apperrors.Status(err) != http.StatusUnprocessableEntity {
+		t.Fatalf("Reload() error = %v, want errInvalidReload", err)
+	}
+	if tuning.Load().Workers != 8 {
+		t.Error("an invalid configuration should not be applied")
+	}
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package app
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/config"
+)
+
+func TestReloader_WatchKeepsSIGHUPUntilStop(t *testing.T) {
+	loads := make(chan struct{}, 4)
+	r := newReloader(&config.Config{}, func() (*config.Config, error) {
+		loads <- struct{}{}
+		return &config.Config{}, nil
+	}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := r.watch(ctx)
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-loads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP did not reload")
+	}
+
+	// during the shutdown a SIGHUP is still captured: it neither reloads
+	// nor terminates the test binary
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-loads:
+		t.Error("a SIGHUP after ctx is done should not reload")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
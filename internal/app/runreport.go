@@ -0,0 +1,27 @@
+package app
+
+import (
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/runreport"
+)
+
+// newRunMailer emails the reports of the runs recorded in db
+func newRunMailer(cfg config.Report, db *gorm.DB, logger *zap.Logger) (*runreport.Mailer, error) {
+	return runreport.NewMailer(runreport.Config{
+		SMTP: runreport.SMTP{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			TLS:      cfg.SMTPTLS,
+		},
+		From:           cfg.From,
+		To:             cfg.To,
+		ErrorsURL:      cfg.ErrorsURL,
+		AttachMaxBytes: cfg.AttachMaxBytes,
+		OnlyProblems:   cfg.OnlyProblems,
+	}, mysql.NewRunRepository(db), mysql.NewDeadLetterRepository(db), runreport.WithLogger(logger))
+}
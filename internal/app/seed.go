@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+	gormLogger "gorm.io/gorm/logger"
+
+	lgr "segmentation-api/internal/logger"
+	"segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/seed"
+	"segmentation-api/internal/service"
+)
+
+// seedData fills the database with fake users and segmentations
+// (seed.users, seed.max_per_user) for development and QA environments
+func seedData(name string, args []string) error {
+	cfg, err := loadConfig(name, args, true)
+	if err != nil {
+		return err
+	}
+
+	logger, logFile, err := lgr.New(cfg.Log)
+	if err != nil {
+		return fmt.Errorf("logger_init_error: %w", err)
+	}
+	defer logFile.Close()
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, slowFile, err := openDatabase(ctx, cfg, logger, gormLogger.Warn)
+	if err != nil {
+		logger.Error("db_init_error", zap.Error(err))
+		return err
+	}
+	defer slowFile.Close()
+
+	if err := migrateOnStart(ctx, cfg, db, logger); err != nil {
+		logger.Error("migration_error", zap.Error(err))
+		return err
+	}
+
+	rules, err := service.ValidationRulesFromConfig(cfg.Validation)
+	if err != nil {
+		return err
+	}
+	typeRegistry := service.NewTypeRegistry(mysql.NewSegmentationTypeRepository(db), 0)
+	if err := typeRegistry.Refresh(ctx); err != nil {
+		logger.Error("type_registry_error", zap.Error(err))
+		return err
+	}
+	svc := service.NewSegmentationService(
+		mysql.NewSegmentationRepository(db, mysql.WithLogger(logger)),
+		service.WithValidationRules(rules),
+		service.WithTypeRegistry(typeRegistry),
+		service.WithLogger(logger),
+	)
+
+	logger.Info("seed_started",
+		zap.Int("users", cfg.Seed.Users),
+		zap.Int("first_user_id", cfg.Seed.FirstUserID),
+		zap.Int("random_seed", cfg.Seed.RandomSeed),
+	)
+	result, err := seed.Run(ctx, svc, cfg.Seed, logger)
+	if err != nil {
+		logger.Error("seed_error", zap.Error(err))
+		return err
+	}
+	logger.Info("seed_finished",
+		zap.Int("users", result.Users),
+		zap.Int("inserted", result.Inserted),
+		zap.Int("updated", result.Updated),
+		zap.Int("failed", result.Failed),
+	)
+	fmt.Printf("seeded %d users: %d inserted, %d updated, %d failed\n",
+		result.Users, result.Inserted, result.Updated, result.Failed)
+	return nil
+}
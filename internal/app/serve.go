@@ -0,0 +1,553 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"segmentation-api/internal/api"
+	"segmentation-api/internal/api/handler"
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/buildinfo"
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/export"
+	"segmentation-api/internal/health"
+	lgr "segmentation-api/internal/logger"
+	"segmentation-api/internal/metrics"
+	"segmentation-api/internal/outbox"
+	"segmentation-api/internal/reporting"
+	"segmentation-api/internal/repository"
+	mysqlRepo "segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/service"
+	"segmentation-api/internal/sigv4"
+	"segmentation-api/internal/users"
+	"segmentation-api/internal/webhook"
+	"segmentation-api/internal/writequeue"
+
+	_ "segmentation-api/docs" // Swagger documentation
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// serve runs the HTTP API
+func serve(name string, args []string) error {
+	// Configuration: defaults, config file, environment and flags
+	cfg, err := loadConfig(name, args, true)
+	if err != nil {
+		return err
+	}
+
+	// Initialize logger
+	logLevel, err := lgr.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+	log_, file, err := lgr.NewWithLevel(cfg.Log, logLevel)
+	if err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+	defer file.Close()
+	defer log_.Sync()
+
+	// Error reporting; disabled without a DSN
+	reporter, err := reporting.New(cfg.Sentry)
+	if err != nil {
+		log_.Fatal("Failed to initialize error reporting", zap.Error(err))
+	}
+	defer reporter.Flush(2 * time.Second)
+
+	// Database connection; credentials from Vault are renewed in the background
+	db, slowFile, err := openDatabase(context.Background(), cfg, log_, gormLogger.Error)
+	if err != nil {
+		log_.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	defer slowFile.Close()
+
+	// Circuit breaker: fail fast with 503 while the database is down
+	if err := db.Use(mysqlRepo.NewBreaker(cfg.DB, log_)); err != nil {
+		log_.Fatal("Failed to register the database circuit breaker", zap.Error(err))
+	}
+
+	// Apply pending migrations unless db.migrate_on_start is off
+	if err := migrateOnStart(context.Background(), cfg, db, log_); err != nil {
+		log_.Fatal("Failed to run migrations", zap.Error(err))
+	}
+
+	// Validation rules
+	rules, err := service.ValidationRulesFromConfig(cfg.Validation)
+	if err != nil {
+		log_.Fatal("Invalid validation config", zap.Error(err))
+	}
+
+	// Segmentation type registry, refreshed in the background
+	typeRegistry := service.NewTypeRegistry(mysqlRepo.NewSegmentationTypeRepository(db), time.Minute)
+	if err := typeRegistry.Refresh(context.Background()); err != nil {
+		log_.Fatal("Failed to load segmentation types", zap.Error(err))
+	}
+	go typeRegistry.Run(context.Background(), func(err error) {
+		log_.Error("type_registry_refresh_error", zap.Error(err))
+	})
+
+	// Initialize repository and service
+	metricsRegistry := metrics.NewRegistry()
+	repoOpts := []mysqlRepo.Option{mysqlRepo.WithLogger(log_)}
+	if cfg.API.RawReads {
+		repoOpts = append(repoOpts, mysqlRepo.WithRawReads())
+	}
+	if cfg.DB.CompressionThreshold > 0 {
+		repoOpts = append(repoOpts, mysqlRepo.WithCompression(cfg.DB.CompressionThreshold))
+	}
+	if cfg.Outbox.Enabled {
+		repoOpts = append(repoOpts, mysqlRepo.WithOutbox())
+	}
+	repo := metrics.InstrumentRepository(
+		mysqlRepo.NewSegmentationRepository(db, repoOpts...),
+		metrics.NewRepositoryMetrics(metricsRegistry),
+	)
+	svcOpts := []service.Option{
+		service.WithValidationRules(rules),
+		service.WithTypeRegistry(typeRegistry),
+		service.WithLogger(log_),
+	}
+	var cache *service.UserCache
+	if cfg.API.CacheSize > 0 {
+		// Hot users are served from memory; writes of this instance
+		// invalidate them, other writes show up after api.cache_ttl or,
+		// with change notifications, as soon as they are notified
+		cache = service.NewUserCache(cfg.API.CacheSize, cfg.API.CacheTTL)
+		svcOpts = append(svcOpts, service.WithCache(cache))
+	}
+	// Change notifications: every write is published to Redis, per user
+	// and per type, and the other writers' changes invalidate the cache
+	if cfg.Notify.Enabled() {
+		notifier, stopNotifier := startNotifier(cfg.Notify, log_)
+		defer stopNotifier()
+		svcOpts = append(svcOpts, service.WithNotifier(notifier))
+
+		if cache != nil {
+			listenCtx, stopListening := context.WithCancel(context.Background())
+			listened := make(chan struct{})
+			go func() {
+				defer close(listened)
+				invalidateOnNotify(listenCtx, cfg.Notify, cache, log_)
+			}()
+			defer func() {
+				stopListening()
+				<-listened
+			}()
+		}
+	}
+	switch cfg.API.UserLookup {
+	case "table":
+		svcOpts = append(svcOpts, service.WithUserDirectory(mysqlRepo.NewUserDirectory(db)))
+	case "http":
+		svcOpts = append(svcOpts, service.WithUserDirectory(users.NewHTTPDirectory(cfg.API.UserLookupURL, cfg.API.UserLookupTimeout)))
+	}
+	svc := service.NewSegmentationService(repo, svcOpts...)
+
+	// Outbox relay: one instance at a time publishes the change events
+	// recorded with each write, to the log or, with webhooks enabled, as
+	// deliveries to the registered webhooks, sent by the same instance.
+	// With the CRM sync enabled the users they touch are updated in the
+	// CRM before that, and with search enabled the events are mirrored into
+	// the search index first: repeating either is harmless when a later
+	// publisher fails. With Kafka enabled the events are produced to its
+	// topic last, so a failure there repeats the others rather than the
+	// records consumers already read.
+	var webhookRepo repository.WebhookRepository
+	var searchIndex repository.SearchRepository
+	if cfg.Outbox.Enabled {
+		publisher := outbox.LogPublisher(log_)
+		var alongside []func(context.Context)
+		if cfg.Webhooks.Enabled {
+			webhookRepo = mysqlRepo.NewWebhookRepository(db)
+			publisher = webhook.NewFanout(webhookRepo)
+			dispatcher := webhook.NewDispatcher(webhookRepo,
+				webhook.WithTimeout(cfg.Webhooks.Timeout),
+				webhook.WithMaxAttempts(cfg.Webhooks.MaxAttempts),
+				webhook.WithInterval(cfg.Outbox.PollInterval),
+				webhook.WithRetention(cfg.Webhooks.Retention),
+				webhook.WithLogger(log_),
+			)
+			alongside = append(alongside, dispatcher.Run)
+		}
+		if cfg.CRM.Enabled() {
+			connector, err := newCRMConnector(cfg.CRM, repo, log_)
+			if err != nil {
+				log_.Fatal("Invalid CRM connector", zap.Error(err))
+			}
+			publisher = outbox.Publishers(connector, publisher)
+		}
+		if cfg.Search.Enabled() {
+			index, err := newSearchIndex(cfg.Search, log_)
+			if err != nil {
+				log_.Fatal("Invalid search index", zap.Error(err))
+			}
+			searchIndex = index
+			publisher = outbox.Publishers(index, publisher)
+		}
+		if cfg.Kafka.Enabled() {
+			producer, err := newKafkaPublisher(cfg.Kafka, log_)
+			if err != nil {
+				log_.Fatal("Invalid Kafka publisher", zap.Error(err))
+			}
+			publisher = outbox.Publishers(publisher, producer)
+		}
+
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		relayed := make(chan struct{})
+		go func() {
+			defer close(relayed)
+			runOutboxRelay(relayCtx, db, cfg.Outbox, publisher, log_, alongside...)
+		}()
+		defer func() {
+			stopRelay()
+			<-relayed
+		}()
+	}
+
+	// Warehouse sync: one instance at a time loads the segmentations
+	// changed since the last sync into BigQuery or Redshift
+	if cfg.Warehouse.Kind != "" {
+		syncer, err := newWarehouseSyncer(db, cfg, log_)
+		if err != nil {
+			log_.Fatal("Invalid warehouse sync", zap.Error(err))
+		}
+		syncCtx, stopSync := context.WithCancel(context.Background())
+		synced := make(chan struct{})
+		go func() {
+			defer close(synced)
+			runAsLeader(syncCtx, db, warehouseSyncLock, "warehouse_sync", log_, syncer.Run)
+		}()
+		defer func() {
+			stopSync()
+			<-synced
+		}()
+	}
+
+	// Analytics of /admin/analytics: the aggregates are cached on each
+	// instance, and one instance at a time records the daily size of each
+	// type for the growth over time
+	analytics := service.NewAnalytics(mysqlRepo.NewAnalyticsRepository(db), cfg.Analytics.CacheTTL,
+		service.WithOverlapSampling(cfg.Analytics.OverlapSampleThreshold))
+	if cfg.Analytics.SnapshotInterval > 0 {
+		snapshotCtx, stopSnapshots := context.WithCancel(context.Background())
+		snapshotted := make(chan struct{})
+		go func() {
+			defer close(snapshotted)
+			runAsLeader(snapshotCtx, db, analyticsSnapshotLock, "analytics_snapshots", log_, func(ctx context.Context) {
+				runSnapshots(ctx, analytics, cfg.Analytics.SnapshotInterval, log_)
+			})
+		}()
+		defer func() {
+			stopSnapshots()
+			<-snapshotted
+		}()
+	}
+
+	// Write-behind queue: POST writes are kept on disk and flushed to MySQL
+	// in the background; what is left at shutdown is flushed on the next
+	// start
+	var writeQueue *writequeue.Queue
+	if dir := cfg.API.WriteQueue.Dir; dir != "" {
+		writeQueue, err = writequeue.Open(dir,
+			writequeue.WithMaxPending(cfg.API.WriteQueue.MaxPending),
+			writequeue.WithDedup(cfg.API.WriteQueue.DedupTTL),
+			writequeue.WithLogger(log_),
+		)
+		if err != nil {
+			log_.Fatal("Failed to open the write queue", zap.Error(err))
+		}
+		metrics.RegisterWriteQueue(metricsRegistry, writeQueue.Pending)
+
+		flushCtx, stopFlush := context.WithCancel(context.Background())
+		flushed := make(chan struct{})
+		go func() {
+			defer close(flushed)
+			writeQueue.Run(flushCtx, svc.FlushQueued)
+		}()
+		defer func() {
+			stopFlush()
+			<-flushed
+			pending := writeQueue.Pending()
+			if err := writeQueue.Close(); err != nil {
+				log_.Error("write_queue_close_error", zap.Error(err))
+			}
+			log_.Info("write_queue_closed", zap.Int("pending", pending))
+		}()
+	}
+
+	// Idempotency keys for write endpoints
+	idempotencyRepo := mysqlRepo.NewIdempotencyRepository(db)
+
+	// Purge expired idempotency keys periodically
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := idempotencyRepo.DeleteExpired(context.Background(), time.Now().Unix())
+			if err != nil {
+				log_.Error("idempotency_cleanup_error", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				log_.Info("idempotency_cleanup", zap.Int64("deleted", n))
+			}
+		}
+	}()
+
+	// Dependency checks served at /health/details
+	sqlDB, err := db.DB()
+	if err != nil {
+		log_.Fatal("Failed to get database handle", zap.Error(err))
+	}
+	runRepo := mysqlRepo.NewRunRepository(db)
+	checker := health.NewChecker(2 * time.Second)
+	checker.Register("database", health.Database(sqlDB, 100*time.Millisecond))
+	checker.Register("schema", health.Schema(pendingMigrations(db)))
+	if file.Path() != "" {
+		checker.Register("log_dir", health.Disk(filepath.Dir(file.Path())))
+	}
+	if path := cfg.Processor.DataFile; path != "" {
+		checker.Register("data_dir", health.Disk(filepath.Dir(path)))
+	}
+	checker.Register("processor", health.ProcessorRun(runRepo.Latest, 24*time.Hour))
+
+	// Table exports of POST /admin/exports run in the background on the
+	// instance that got the request; a shutdown cancels them
+	var exporter service.ExportStarter
+	if cfg.Export.Enabled() {
+		sink, err := exportSink(cfg.Export, cfg.AWS)
+		if err != nil {
+			log_.Fatal("Invalid export destination", zap.Error(err))
+		}
+		opts := []export.Option{
+			export.WithChunkRows(cfg.Export.ChunkRows),
+			export.WithLogger(log_),
+		}
+		if cfg.Export.PseudonymKey != "" {
+			opts = append(opts, export.WithPseudonymizer(export.NewPseudonymizer(cfg.Export.PseudonymKey, cfg.Export.StripDataKeys)))
+		}
+		e := export.NewExporter(mysqlRepo.NewBackupRepository(db), runRepo, sink, opts...)
+		defer e.Close()
+		exporter = e
+	}
+
+	// Imports of POST /imports: the file is stored under import.dir and
+	// imported by the processor in the background on the instance that got
+	// the request; a shutdown cancels the import
+	var importer service.ImportStarter
+	if cfg.Import.Enabled() {
+		i, err := newImporter(cfg, db, svc, runRepo, reporter, log_)
+		if err != nil {
+			log_.Fatal("Invalid import settings", zap.Error(err))
+		}
+		defer i.Close()
+		importer = i
+	}
+
+	// Maintenance mode: starts as api.maintenance_mode, changed at runtime
+	// through /admin/maintenance
+	maintenanceMode, err := middleware.ParseMaintenanceMode(cfg.API.MaintenanceMode)
+	if err != nil {
+		log_.Fatal("Invalid maintenance mode", zap.Error(err))
+	}
+	maintenance := middleware.NewMaintenance(maintenanceMode, cfg.API.MaintenanceMessage)
+	if maintenanceMode != middleware.MaintenanceOff {
+		log_.Warn("Starting in maintenance mode", zap.String("mode", string(maintenanceMode)))
+	}
+
+	// gRPC API: client-streaming ingest of upstream services, next to the
+	// HTTP API and under the same maintenance mode
+	var grpcSrv *grpcServer
+	if cfg.API.GRPC.Enabled() {
+		grpcSrv, err = startGRPC(cfg.API.GRPC, svc, maintenance, log_)
+		if err != nil {
+			log_.Fatal("Failed to start the gRPC server", zap.Error(err))
+		}
+	}
+
+	// Settings reloaded on SIGHUP and POST /admin/reload
+	reload := newReloader(cfg, func() (*config.Config, error) {
+		return loadConfig(name, args, true)
+	}, log_, apiReloadables(logLevel, cache)...)
+
+	// Readiness at /ready, failed during the drain before a shutdown
+	readiness := handler.NewReadiness()
+
+	// Setup router; gin mode and Swagger follow the APP_ENV profile
+	gin.SetMode(cfg.API.GinMode)
+	router := api.SetupRouter(
+		svc,
+		api.WithIdempotency(idempotencyRepo, cfg.API.IdempotencyTTL),
+		api.WithAdminToken(cfg.API.AdminToken),
+		api.WithTypeRegistry(typeRegistry),
+		api.WithLogger(log_),
+		api.WithLogLevel(logLevel),
+		api.WithMetrics(metricsRegistry),
+		api.WithErrorReporter(reporter),
+		api.WithAuditLog(mysqlRepo.NewAuditLogRepository(db)),
+		api.WithChanges(mysqlRepo.NewChangeRepository(db)),
+		api.WithSearch(searchIndex),
+		api.WithImportErrors(runRepo, mysqlRepo.NewDeadLetterRepository(db)),
+		api.WithImports(runRepo, importer),
+		api.WithHealthChecks(checker),
+		api.WithMaintenance(maintenance),
+		api.WithReadiness(readiness),
+		api.WithSwagger(cfg.API.Swagger),
+		api.WithStreamThreshold(cfg.API.StreamThreshold),
+		api.WithMaxBodyBytes(cfg.API.MaxBodyBytes),
+		api.WithRequestTimeout(cfg.API.RequestTimeout),
+		api.WithStrictJSON(rules.Mode == service.ValidationStrict),
+		api.WithWriteQueue(writeQueue),
+		api.WithReload(reload.Reload),
+		api.WithEffectiveConfig(reload.Current),
+		api.WithWebhooks(webhookRepo),
+		api.WithExports(runRepo, exporter),
+		api.WithErasures(mysqlRepo.NewErasureRepository(db, repoOpts...)),
+		api.WithAnalytics(analytics),
+		api.WithStatsRecompute(func(ctx context.Context) ([]repository.TableStats, error) {
+			return mysqlRepo.AnalyzeTables(ctx, db)
+		}),
+		api.WithSchemaVersion(func(ctx context.Context) (int64, int, error) {
+			return mysqlRepo.SchemaVersion(ctx, db)
+		}),
+	)
+
+	port := cfg.API.Port
+	srv := newHTTPServer(":"+port, router, cfg.API.Server)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopReload := reload.watch(ctx)
+	defer stopReload()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		build := buildinfo.Get()
+		log_.Info("Starting API server", zap.String("port", port), zap.String("env", cfg.Env),
+			zap.String("version", build.Version), zap.String("commit", build.Commit))
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log_.Fatal("Failed to start server", zap.Error(err))
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Drain: fail /ready so load balancers de-register the instance while
+	// it still serves, then stop accepting connections and let in-flight
+	// requests finish. A second signal skips the grace period.
+	readiness.Drain()
+	if grpcSrv != nil {
+		grpcSrv.drain()
+	}
+	log_.Info("Draining API server", zap.Duration("grace", cfg.API.DrainGrace))
+	drainCtx, stopDrain := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-time.After(cfg.API.DrainGrace):
+	case <-drainCtx.Done():
+		log_.Warn("Drain interrupted by a second signal")
+	}
+	stopDrain()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.API.ShutdownTimeout)
+	defer cancel()
+	if grpcSrv != nil {
+		// in-flight streams share the shutdown timeout with HTTP requests
+		var grpcStopped sync.WaitGroup
+		grpcStopped.Go(func() { grpcSrv.stop(shutdownCtx) })
+		defer grpcStopped.Wait()
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log_.Error("API server shutdown did not complete", zap.Error(err))
+		return err
+	}
+	log_.Info("API server stopped")
+	if writeQueue != nil {
+		drainWriteQueue(shutdownCtx, writeQueue)
+	}
+	return nil
+}
+
+// apiReloadables are the settings of serve applied by a reload: the log
+// level and, with the response cache on, api.cache_ttl
+func apiReloadables(level zap.AtomicLevel, cache *service.UserCache) []reloadable {
+	settings := []reloadable{logLevelReloadable(level)}
+	if cache != nil {
+		settings = append(settings, reloadable{
+			key:   "api.cache_ttl",
+			value: func(c *config.Config) any { return c.API.CacheTTL },
+			apply: func(c *config.Config) { cache.SetTTL(c.API.CacheTTL) },
+			set:   func(dst, src *config.Config) { dst.API.CacheTTL = src.API.CacheTTL },
+		})
+	}
+	return settings
+}
+
+// exportSink returns the destination of the exports: the S3 bucket when
+// export.s3.bucket is set, export.dir otherwise
+func exportSink(cfg config.Export, creds config.AWS) (export.Sink, error) {
+	if cfg.S3.Bucket == "" {
+		return export.NewDirSink(cfg.Dir), nil
+	}
+	return export.NewS3Sink(export.S3Config{
+		Bucket:      cfg.S3.Bucket,
+		Prefix:      cfg.S3.Prefix,
+		Region:      cfg.S3.Region,
+		Endpoint:    cfg.S3.Endpoint,
+		Credentials: awsCredentials(creds),
+	}, nil)
+}
+
+func awsCredentials(cfg config.AWS) sigv4.Credentials {
+	return sigv4.Credentials{
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+	}
+}
+
+// drainWriteQueue gives the flusher until ctx is done to empty the write
+// queue, so a clean shutdown leaves nothing behind on the instance's disk
+func drainWriteQueue(ctx context.Context, q *writequeue.Queue) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for q.Pending() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newHTTPServer builds the API's server from the api.server settings
+func newHTTPServer(addr string, handler http.Handler, cfg config.Server) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if cfg.H2C {
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = &protocols
+	}
+	srv.SetKeepAlivesEnabled(cfg.KeepAlive)
+	return srv
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	cfg := config.Server{
+		ReadTimeout:       5 * time.Second,
+		ReadHeaderTimeout: time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       time.Minute,
+		MaxHeaderBytes:    8 << 10,
+		KeepAlive:         true,
+	}
+	srv := newHTTPServer(":8080", http.NotFoundHandler(), cfg)
+
+	if srv.Addr != ":8080" || srv.ReadTimeout != cfg.ReadTimeout || srv.ReadHeaderTimeout != cfg.ReadHeaderTimeout ||
+		srv.WriteTimeout != cfg.WriteTimeout || srv.IdleTimeout != cfg.IdleTimeout || srv.MaxHeaderBytes != cfg.MaxHeaderBytes {
+		t.Errorf("server does not match the config: %+v", srv)
+	}
+	if srv.Protocols != nil {
+		t.Errorf("protocols = %v without h2c, want the net/http default", srv.Protocols)
+	}
+}
+
+func TestNewHTTPServer_H2C(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	srv := newHTTPServer("", handler, config.Server{MaxHeaderBytes: 1 << 20, KeepAlive: true, H2C: true})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	defer srv.Shutdown(context.Background())
+
+	// a client that only speaks HTTP/2 over cleartext
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}, Timeout: 5 * time.Second}
+
+	resp, err := client.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("h2c request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("proto = %s, want HTTP/2", resp.Proto)
+	}
+
+	// HTTP/1.1 clients are still served
+	resp, err = http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 1 {
+		t.Errorf("proto = %s, want HTTP/1.1", resp.Proto)
+	}
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/sftp"
+)
+
+// sftpSource is the SFTP source of the processor in cfg
+func sftpSource(cfg config.SFTP) sftp.Source {
+	return sftp.Source{
+		Config: sftp.Config{
+			Host:          cfg.Host,
+			Port:          cfg.Port,
+			User:          cfg.User,
+			KeyFile:       cfg.KeyFile,
+			KeyPassphrase: cfg.KeyPassphrase,
+			KnownHosts:    cfg.KnownHosts,
+		},
+		Pattern:     cfg.Pattern,
+		ArchiveDir:  cfg.ArchiveDir,
+		FailedDir:   cfg.FailedDir,
+		MinAge:      cfg.MinAge,
+		MaxAttempts: cfg.MaxAttempts,
+		DownloadDir: cfg.DownloadDir,
+	}
+}
+
+// newSFTPPoller polls the SFTP source of cfg; the imports of each file are
+// found in the runs table of db
+func newSFTPPoller(cfg config.SFTP, db *gorm.DB, logger *zap.Logger) *sftp.Poller {
+	return sftp.NewPoller(sftpSource(cfg), mysql.NewRunRepository(db), sftp.WithLogger(logger))
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/export"
+	mysqlRepo "segmentation-api/internal/repository/mysql"
+	"segmentation-api/internal/warehouse"
+)
+
+// warehouseSyncLock is the leader lock of the warehouse sync
+const warehouseSyncLock = "segmentation_warehouse_sync"
+
+// newWarehouseSyncer builds the sync of the changed segmentations to the
+// warehouse of cfg.Warehouse.Kind
+func newWarehouseSyncer(db *gorm.DB, cfg *config.Config, logger *zap.Logger) (*warehouse.Syncer, error) {
+	var loader warehouse.Loader
+	switch wh := cfg.Warehouse; wh.Kind {
+	case "bigquery":
+		creds, err := os.ReadFile(wh.BigQuery.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read BigQuery credentials: %w", err)
+		}
+		if loader, err = warehouse.NewBigQuery(warehouse.BigQueryConfig{
+			Project:     wh.BigQuery.Project,
+			Dataset:     wh.BigQuery.Dataset,
+			Table:       wh.BigQuery.Table,
+			Location:    wh.BigQuery.Location,
+			Credentials: creds,
+		}, nil); err != nil {
+			return nil, err
+		}
+	case "redshift":
+		rs := wh.Redshift
+		stage, err := export.NewS3Sink(export.S3Config{
+			Bucket:      rs.StagingBucket,
+			Prefix:      rs.StagingPrefix,
+			Region:      rs.Region,
+			Credentials: awsCredentials(cfg.AWS),
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if loader, err = warehouse.NewRedshift(warehouse.RedshiftConfig{
+			Region:      rs.Region,
+			ClusterID:   rs.ClusterID,
+			Workgroup:   rs.Workgroup,
+			Database:    rs.Database,
+			DBUser:      rs.DBUser,
+			SecretARN:   rs.SecretARN,
+			Table:       rs.Table,
+			IAMRole:     rs.IAMRole,
+			Credentials: awsCredentials(cfg.AWS),
+		}, stage, nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown warehouse %q", wh.Kind)
+	}
+
+	return warehouse.NewSyncer("warehouse:"+cfg.Warehouse.Kind,
+		mysqlRepo.NewChangeRepository(db),
+		mysqlRepo.NewWatermarkRepository(db),
+		loader,
+		warehouse.WithErasures(mysqlRepo.NewErasureRepository(db)),
+		warehouse.WithBatchRows(cfg.Warehouse.BatchRows),
+		warehouse.WithInterval(cfg.Warehouse.Interval),
+		warehouse.WithLag(cfg.Warehouse.Lag),
+		warehouse.WithLogger(logger),
+	), nil
+}
@@ -0,0 +1,93 @@
+// Package apperrors is the catalog of error kinds shared by the
+// repository, service and API layers. Each package keeps its own errors,
+// created with New on top of one or more kinds, and the API turns a kind
+// into an HTTP status in one place (Status) instead of every handler
+// knowing the errors of every package.
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Error kinds, matched with errors.Is
+var (
+	// ErrNotFound is an entity that does not exist (404)
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is a write that collides with the current state (409)
+	ErrConflict = errors.New("conflict")
+	// ErrValidation is invalid input (400)
+	ErrValidation = errors.New("validation failed")
+	// ErrTooLarge is input over a size limit (413)
+	ErrTooLarge = errors.New("too large")
+	// ErrUnprocessable is well-formed input the current configuration
+	// does not accept (422)
+	ErrUnprocessable = errors.New("unprocessable")
+	// ErrUnavailable is a dependency that is temporarily down; the request
+	// can be retried later (503)
+	ErrUnavailable = errors.New("unavailable")
+	// ErrTimeout is a request that ran past its deadline (504); a
+	// context.DeadlineExceeded anywhere in the chain counts as one
+	ErrTimeout = errors.New("timeout")
+)
+
+// New returns an error with message msg that errors.Is matches against
+// each of kinds
+func New(msg string, kinds ...error) error {
+	return &kindError{msg: msg, kinds: kinds}
+}
+
+type kindError struct {
+	msg   string
+	kinds []error
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() []error {
+	return e.kinds
+}
+
+// Status returns the HTTP status of the kind of err, 500 when it has none.
+// The most specific kind wins: an oversized or unprocessable input is also
+// a validation error, an unavailable dependency answers 503 whatever else
+// the error says and a deadline that ran out answers 504.
+func Status(err error) int {
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnprocessable):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Detailer is implemented by errors that add fields to the error response,
+// such as the conflicting key or the accepted values
+type Detailer interface {
+	Details() map[string]any
+}
+
+// Details returns the fields of the first error in the chain of err that
+// implements Detailer, or nil
+func Details(err error) map[string]any {
+	var d Detailer
+	if errors.As(err, &d) {
+		return d.Details()
+	}
+	return nil
+}
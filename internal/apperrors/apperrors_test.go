@@ -0,0 +1,58 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type detailed struct{ error }
+
+func (d detailed) Details() map[string]any {
+	return map[string]any{"field": "name"}
+}
+
+func TestStatus(t *testing.T) {
+	invalid := New("invalid segmentation", ErrValidation)
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "not found", err: New("user not found", ErrNotFound), want: http.StatusNotFound},
+		{name: "conflict", err: New("conflicting write", ErrConflict), want: http.StatusConflict},
+		{name: "validation", err: fmt.Errorf("%w: name is required", invalid), want: http.StatusBadRequest},
+		{name: "too large", err: fmt.Errorf("%w: %w", invalid, New("data too large", ErrTooLarge)), want: http.StatusRequestEntityTooLarge},
+		{name: "unprocessable", err: New("unknown type", ErrValidation, ErrUnprocessable), want: http.StatusUnprocessableEntity},
+		{name: "unavailable", err: fmt.Errorf("find: %w", New("database unavailable", ErrUnavailable)), want: http.StatusServiceUnavailable},
+		{name: "timeout", err: New("request timed out", ErrTimeout), want: http.StatusGatewayTimeout},
+		{name: "deadline", err: fmt.Errorf("find: %w", context.DeadlineExceeded), want: http.StatusGatewayTimeout},
+		{name: "untyped", err: errors.New("boom"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Status(tt.err); got != tt.want {
+				t.Errorf("Status() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_KeepsMessage(t *testing.T) {
+	err := New("segmentation type not found", ErrNotFound)
+	if err.Error() != "segmentation type not found" || !errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDetails(t *testing.T) {
+	err := fmt.Errorf("write: %w", detailed{errors.New("invalid")})
+	if got := Details(err); got["field"] != "name" {
+		t.Errorf("Details() = %v", got)
+	}
+	if got := Details(errors.New("plain")); got != nil {
+		t.Errorf("Details() = %v, want nil", got)
+	}
+}
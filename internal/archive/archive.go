@@ -0,0 +1,216 @@
+// Package archive keeps an auditable copy of what each processor run
+// ingested: once a run succeeds, its input file, the rows it rejected and
+// a summary of the run are stored under a folder of the run,
+//
+//	2026/10/17/<run_id>/daily.csv          the file, as read
+//	2026/10/17/<run_id>/dead_letters.csv   the rejected rows, if any
+//	2026/10/17/<run_id>/run.json           counters, checksum of the file
+//
+// usually in an S3 bucket whose lifecycle rules act on the tags of the
+// objects. run.json is written last: a folder without it is incomplete.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/export"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+)
+
+// Names of the files of a run, next to the input file
+const (
+	DeadLettersName = "dead_letters.csv"
+	SummaryName     = "run.json"
+)
+
+// Summary is run.json: the counters of the run as recorded in the runs
+// table, and the size and SHA-256 of the file, which tell whether a file
+// is the one that was ingested
+type Summary struct {
+	RunID       string    `json:"run_id"`
+	JobID       string    `json:"job_id"`
+	Partition   int       `json:"partition"`
+	Partitions  int       `json:"partitions"`
+	Source      string    `json:"source"`
+	Host        string    `json:"host,omitempty"`
+	Status      string    `json:"status"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	RowsRead    uint64    `json:"rows_read"`
+	Inserted    uint64    `json:"inserted"`
+	Updated     uint64    `json:"updated"`
+	Duplicates  uint64    `json:"duplicates"`
+	Invalid     uint64    `json:"invalid"`
+	Failed      uint64    `json:"failed"`
+	Warnings    uint64    `json:"warnings"`
+	File        File      `json:"file"`
+	DeadLetters string    `json:"dead_letters,omitempty"`
+}
+
+// File describes the archived input file
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Archiver stores the runs in a sink
+type Archiver struct {
+	sink     export.Sink
+	errors   *service.ImportErrors
+	logger   *zap.Logger
+	hostname string
+}
+
+// Option customizes an Archiver
+type Option func(*Archiver)
+
+// WithLogger sets the logger of the archived runs
+func WithLogger(logger *zap.Logger) Option {
+	return func(a *Archiver) {
+		a.logger = logger
+	}
+}
+
+// NewArchiver stores the runs in sink, with the rejected rows read from
+// runs and deadLetters
+func NewArchiver(sink export.Sink, runs repository.RunRepository, deadLetters repository.DeadLetterRepository, opts ...Option) *Archiver {
+	hostname, _ := os.Hostname()
+	a := &Archiver{
+		sink:     sink,
+		errors:   service.NewImportErrors(runs, deadLetters),
+		logger:   zap.NewNop(),
+		hostname: hostname,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Folder is where the files of run are stored, relative to the sink
+func Folder(run *models.Run) string {
+	return time.Unix(run.StartedAt, 0).UTC().Format("2006/01/02") + "/" + run.ID
+}
+
+// Archive stores file, the local copy of what run read, with the rejected
+// rows and the summary of the run, and returns the location of the
+// folder. Only succeeded runs are archived; the others return "".
+func (a *Archiver) Archive(ctx context.Context, run *models.Run, file string) (string, error) {
+	if run.Status != models.RunSucceeded {
+		return "", nil
+	}
+	folder := Folder(run)
+	summary := a.summary(run)
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	summary.File = File{Name: archiveName(run.Source, file), Size: info.Size(), SHA256: hex.EncodeToString(h.Sum(nil))}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := a.sink.Put(ctx, folder+"/"+summary.File.Name, f, info.Size()); err != nil {
+		return "", err
+	}
+
+	if run.Invalid > 0 || run.Failed > 0 {
+		if err := a.putDeadLetters(ctx, run.ID, folder+"/"+DeadLettersName); err != nil {
+			return "", err
+		}
+		summary.DeadLetters = DeadLettersName
+	}
+
+	body, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := a.sink.Put(ctx, folder+"/"+SummaryName, bytes.NewReader(body), int64(len(body))); err != nil {
+		return "", err
+	}
+	location := a.sink.Location(folder + "/")
+	a.logger.Info("run_archived", zap.String("location", location), zap.Int64("bytes", info.Size()),
+		zap.String("sha256", summary.File.SHA256))
+	return location, nil
+}
+
+// putDeadLetters stores the rejected rows of the run as CSV, spooled to a
+// temporary file since a run may reject millions of them
+func (a *Archiver) putDeadLetters(ctx context.Context, runID, name string) error {
+	tmp, err := os.CreateTemp("", "dead-letters-*.csv")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := a.errors.Write(ctx, runID, service.ExportFormatCSV, tmp); err != nil {
+		return fmt.Errorf("archive: dead letters: %w", err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return a.sink.Put(ctx, name, tmp, size)
+}
+
+func (a *Archiver) summary(run *models.Run) Summary {
+	return Summary{
+		RunID:      run.ID,
+		JobID:      run.JobID,
+		Partition:  run.Partition,
+		Partitions: run.Partitions,
+		Source:     run.Source,
+		Host:       a.hostname,
+		Status:     run.Status,
+		StartedAt:  time.Unix(run.StartedAt, 0).UTC(),
+		FinishedAt: time.Unix(run.FinishedAt, 0).UTC(),
+		RowsRead:   run.RowsRead,
+		Inserted:   run.Inserted,
+		Updated:    run.Updated,
+		Duplicates: run.Duplicates,
+		Invalid:    run.Invalid,
+		Failed:     run.Failed,
+		Warnings:   run.Warnings,
+	}
+}
+
+// archiveName is the name of the archived file: that of the source, which
+// for a remote file is not the name of its local copy
+func archiveName(source, file string) string {
+	if source == "" {
+		source = file
+	}
+	source, _, _ = strings.Cut(source, "?")
+	name := path.Base(strings.ReplaceAll(source, "\\", "/"))
+	if name == "." || name == "/" || name == DeadLettersName || name == SummaryName {
+		name = "input_" + name
+	}
+	return name
+}
@@ -0,0 +1,117 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/export"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// memoryRuns knows the runs in byID; the other methods are unused
+type memoryRuns struct {
+	repository.RunRepository
+	byID map[string]*models.Run
+}
+
+func (m memoryRuns) Get(ctx context.Context, id string) (*models.Run, error) {
+	return m.byID[id], nil
+}
+
+type memoryDeadLetters struct {
+	repository.DeadLetterRepository
+	entries []models.DeadLetter
+}
+
+func (m memoryDeadLetters) List(ctx context.Context, runID string, afterID uint64, limit int) ([]models.DeadLetter, error) {
+	var out []models.DeadLetter
+	for _, e := range m.entries {
+		if e.RunID == runID && e.ID > afterID && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func TestArchiver_Archive(t *testing.T) {
+	started := time.Date(2026, 10, 17, 23, 59, 0, 0, time.UTC)
+	run := &models.Run{
+		ID: "run-1", JobID: "run-1", Partitions: 1, Status: models.RunSucceeded,
+		Source:   "sftp://sftp.vendor.example:22/inbox/daily.csv?mtime=1760000000",
+		RowsRead: 3, Inserted: 2, Invalid: 1,
+		StartedAt: started.Unix(), FinishedAt: started.Add(2 * time.Minute).Unix(),
+	}
+	input := filepath.Join(t.TempDir(), "sftp-123-daily.csv")
+	if err := os.WriteFile(input, []byte("user_id,segmentation_type,segmentation_name,data\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	a := NewArchiver(export.NewDirSink(dir),
+		memoryRuns{byID: map[string]*models.Run{"run-1": run}},
+		memoryDeadLetters{entries: []models.DeadLetter{{ID: 1, RunID: "run-1", RowNumber: 2, RawLine: "x,drug,A,{}", Error: "invalid user_id"}}},
+	)
+
+	location, err := a.Archive(context.Background(), run, input)
+	if err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+	folder := filepath.Join(dir, "2026", "10", "17", "run-1")
+	if location != folder+string(filepath.Separator) && location != folder {
+		t.Errorf("location = %q, want %q", location, folder)
+	}
+
+	if b, _ := os.ReadFile(filepath.Join(folder, "daily.csv")); string(b) != "user_id,segmentation_type,segmentation_name,data\n" {
+		t.Errorf("archived file = %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(folder, DeadLettersName)); string(b) != "row_number,raw_line,error\n2,\"x,drug,A,{}\",invalid user_id\n" {
+		t.Errorf("dead letters = %q", b)
+	}
+	raw, err := os.ReadFile(filepath.Join(folder, SummaryName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary Summary
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		t.Fatal(err)
+	}
+	wantFile := File{Name: "daily.csv", Size: 49, SHA256: "65223616eb2d9f25182888de89114f7eefe394166bcf8307fdfb63a6dd34c5c8"}
+	if summary.File != wantFile {
+		t.Errorf("file = %+v, want %+v", summary.File, wantFile)
+	}
+	if summary.RunID != "run-1" || summary.Invalid != 1 || summary.DeadLetters != DeadLettersName || !summary.FinishedAt.Equal(started.Add(2*time.Minute)) {
+		t.Errorf("unexpected summary %s", raw)
+	}
+}
+
+func TestArchiver_SkipsUnsuccessfulRuns(t *testing.T) {
+	dir := t.TempDir()
+	a := NewArchiver(export.NewDirSink(dir), memoryRuns{}, memoryDeadLetters{})
+	for _, status := range []string{models.RunFailed, models.RunCancelled} {
+		location, err := a.Archive(context.Background(), &models.Run{ID: "run-2", Status: status}, "/does/not/exist.csv")
+		if location != "" || err != nil {
+			t.Errorf("a %s run should not be archived, got %q, %v", status, location, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("nothing should be written, got %v", entries)
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct{ source, file, want string }{
+		{"/data/segmentations.csv", "/data/segmentations.csv", "segmentations.csv"},
+		{"sftp://host:22/inbox/daily.csv?mtime=1", "/tmp/sftp-1-daily.csv", "daily.csv"},
+		{"", "/tmp/x.csv", "x.csv"},
+		{"/inbox/run.json", "/inbox/run.json", "input_run.json"},
+	}
+	for _, tt := range tests {
+		if got := archiveName(tt.source, tt.file); got != tt.want {
+			t.Errorf("archiveName(%q, %q) = %q, want %q", tt.source, tt.file, got, tt.want)
+		}
+	}
+}
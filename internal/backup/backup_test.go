@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// memoryRepository keeps the rows in insertion order
+type memoryRepository struct {
+	rows     []models.Segmentation
+	restores int
+}
+
+func (m *memoryRepository) Scan(ctx context.Context, filter repository.Filter, batchSize int, fn func([]models.Segmentation) error) error {
+	for start := 0; start < len(m.rows); start += batchSize {
+		end := min(start+batchSize, len(m.rows))
+		if err := fn(m.rows[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepository) Restore(ctx context.Context, items []models.Segmentation) error {
+	m.restores++
+	m.rows = append(m.rows, items...)
+	return nil
+}
+
+func TestExportRestore(t *testing.T) {
+	src := &memoryRepository{}
+	for i := 1; i <= 2500; i++ {
+		src.rows = append(src.rows, models.Segmentation{
+			ID:               uint64(i),
+			UserID:           uint64(i%100 + 1),
+			SegmentationType: "drug",
+			SegmentationName: fmt.Sprintf("Drug %d <%d>", i, i),
+			Data:             []byte(fmt.Sprintf(`{"quantity":"%d"}`, i)),
+			CreatedAt:        int64(1700000000 + i),
+			UpdatedAt:        int64(1800000000 + i),
+		})
+	}
+	src.rows[0].Data = nil
+	src.rows[1].NameKey = "drug 2 <2>"
+
+	var buf bytes.Buffer
+	n, err := Export(context.Background(), src, &buf)
+	if err != nil || n != 2500 {
+		t.Fatalf("Export() = %d, %v", n, err)
+	}
+
+	dst := &memoryRepository{}
+	n, err = Restore(context.Background(), dst, &buf)
+	if err != nil || n != 2500 {
+		t.Fatalf("Restore() = %d, %v", n, err)
+	}
+	if dst.restores != 3 {
+		t.Errorf("restores = %d, want 3 batches", dst.restores)
+	}
+
+	for i := range src.rows {
+		want := src.rows[i]
+		want.ID = 0 // ids are not kept
+		if !reflect.DeepEqual(dst.rows[i], want) {
+			t.Fatalf("row %d = %+v, want %+v", i, dst.rows[i], want)
+		}
+	}
+}
+
+func gzipped(t *testing.T, s string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return &buf
+}
+
+func TestRestore_Errors(t *testing.T) {
+	if _, err := Restore(context.Background(), &memoryRepository{}, strings.NewReader("not gzip")); err == nil {
+		t.Error("Restore() should reject a stream that is not gzip")
+	}
+
+	backup := `{"user_id":1,"segmentation_type":"drug","segmentation_name":"A","data":{}}
+
+{"user_id":0,"segmentation_type":"drug","segmentation_name":"B"}
+`
+	dst := &memoryRepository{}
+	_, err := Restore(context.Background(), dst, gzipped(t, backup))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Restore() error = %v, want it to point at line 3", err)
+	}
+	if len(dst.rows) != 0 {
+		t.Errorf("the partial batch should not be written, got %d rows", len(dst.rows))
+	}
+}
@@ -0,0 +1,51 @@
+// Package buildinfo describes the running binary. The release fields are
+// set at build time with -ldflags, for example:
+//
+//	go build -ldflags "-X segmentation-api/internal/buildinfo.Version=1.4.0 \
+//	  -X segmentation-api/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X segmentation-api/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X; see the package documentation
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info identifies the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build info. Commit and date not set with -ldflags come
+// from the VCS stamp of the Go toolchain when the binary was built from a
+// git checkout, and are "unknown" otherwise.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: Date, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
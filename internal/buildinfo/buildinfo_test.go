@@ -0,0 +1,24 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGet_LDFlags(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, Date = v, c, d }(Version, Commit, Date)
+	Version, Commit, Date = "1.4.0", "abc123", "2026-10-16T12:00:00Z"
+
+	info := Get()
+	want := Info{Version: "1.4.0", Commit: "abc123", BuildDate: "2026-10-16T12:00:00Z", GoVersion: runtime.Version()}
+	if info != want {
+		t.Errorf("Get() = %+v, want %+v", info, want)
+	}
+}
+
+func TestGet_Defaults(t *testing.T) {
+	info := Get()
+	if info.Version != "dev" || info.Commit == "" || info.BuildDate == "" || info.GoVersion != runtime.Version() {
+		t.Errorf("Get() = %+v, want version dev and no empty field", info)
+	}
+}
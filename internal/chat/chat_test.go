@@ -0,0 +1,136 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+)
+
+// newWebhook records the bodies posted to it and answers status
+func newWebhook(t *testing.T, status int) (*httptest.Server, *[]map[string]any) {
+	var posts []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("invalid JSON posted: %s", raw)
+		}
+		posts = append(posts, body)
+		w.WriteHeader(status)
+		w.Write([]byte("invalid_payload"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &posts
+}
+
+func finishedRun(status string, read, invalid uint64) *models.Run {
+	return &models.Run{
+		ID: "run-1", Source: "/data/daily.csv", Status: status, Partitions: 1,
+		RowsRead: read, Inserted: read - invalid, Invalid: invalid,
+		StartedAt: 1760000000, FinishedAt: 1760000090, Error: map[bool]string{true: "db down"}[status == models.RunFailed],
+	}
+}
+
+func TestNotifier_Slack(t *testing.T) {
+	tests := []struct {
+		name      string
+		run       *models.Run
+		wantText  string
+		wantColor string
+	}{
+		{name: "clean", run: finishedRun(models.RunSucceeded, 100, 0), wantText: "*Import finished: daily.csv*", wantColor: "good"},
+		{name: "below threshold", run: finishedRun(models.RunSucceeded, 100, 5), wantText: "*Import finished with rejected rows: daily.csv*", wantColor: "warning"},
+		{name: "above threshold", run: finishedRun(models.RunSucceeded, 100, 20),
+			wantText: "<!subteam^S012> *Import finished with rejected rows: daily.csv*\nThe error rate is above 10.00%.", wantColor: "danger"},
+		{name: "failed", run: finishedRun(models.RunFailed, 0, 0), wantText: "<!subteam^S012> *Import failed: daily.csv*", wantColor: "danger"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, posts := newWebhook(t, http.StatusOK)
+			n, err := NewNotifier(Config{Kind: KindSlack, WebhookURL: srv.URL, Mention: "<!subteam^S012>", ErrorRate: 0.1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := n.Finished(context.Background(), tt.run); err != nil {
+				t.Fatalf("Finished() error = %v", err)
+			}
+			body := (*posts)[0]
+			if body["text"] != tt.wantText {
+				t.Errorf("text = %q, want %q", body["text"], tt.wantText)
+			}
+			att := body["attachments"].([]any)[0].(map[string]any)
+			if att["color"] != tt.wantColor {
+				t.Errorf("color = %v, want %v", att["color"], tt.wantColor)
+			}
+			raw, _ := json.Marshal(att["fields"])
+			if !strings.Contains(string(raw), `"title":"Duration","value":"1m30s"`) {
+				t.Errorf("fields should hold the duration: %s", raw)
+			}
+		})
+	}
+}
+
+func TestNotifier_Teams(t *testing.T) {
+	srv, posts := newWebhook(t, http.StatusAccepted)
+	n, err := NewNotifier(Config{Kind: KindTeams, WebhookURL: srv.URL, Mention: "Data On-call", MentionID: "oncall@example.com", ErrorRate: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Started(context.Background(), "run-1", "/data/daily.csv"); err != nil {
+		t.Fatalf("Started() error = %v", err)
+	}
+	if err := n.Finished(context.Background(), finishedRun(models.RunSucceeded, 100, 50)); err != nil {
+		t.Fatalf("Finished() error = %v", err)
+	}
+
+	card := func(i int) map[string]any {
+		return (*posts)[i]["attachments"].([]any)[0].(map[string]any)["content"].(map[string]any)
+	}
+	if title := card(0)["body"].([]any)[0].(map[string]any)["text"]; title != "Import started: daily.csv" {
+		t.Errorf("start title = %v", title)
+	}
+	if _, ok := card(0)["msteams"]; ok {
+		t.Error("the start should not mention anyone")
+	}
+	raw, _ := json.Marshal(card(1))
+	for _, want := range []string{
+		`"text":"\u003cat\u003eData On-call\u003c/at\u003e The error rate is above 10.00%."`,
+		`"mentioned":{"id":"oncall@example.com","name":"Data On-call"}`,
+		`"title":"Rejected","value":"50 invalid, 0 failed (50.00%)"`,
+		`"color":"Attention"`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("card should contain %s:\n%s", want, raw)
+		}
+	}
+}
+
+func TestNotifier_Refused(t *testing.T) {
+	srv, _ := newWebhook(t, http.StatusBadRequest)
+	n, err := NewNotifier(Config{Kind: KindSlack, WebhookURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Started(context.Background(), "run-1", "/data/daily.csv")
+	if err == nil || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Errorf("Started() error = %v, want the answer of the webhook", err)
+	}
+}
+
+func TestNewNotifier_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{Kind: KindSlack},
+		{Kind: "discord", WebhookURL: "https://hooks.example"},
+		{Kind: KindTeams, WebhookURL: "https://hooks.example", Mention: "Data On-call"},
+	} {
+		if _, err := NewNotifier(cfg); err == nil {
+			t.Errorf("NewNotifier(%+v) should fail", cfg)
+		}
+	}
+}
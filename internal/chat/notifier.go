@@ -0,0 +1,194 @@
+// Package chat posts the start and the outcome of each processor run to a
+// Slack or Microsoft Teams channel through an incoming webhook, with the
+// key counters of the run. A run that fails, or whose share of rejected
+// rows exceeds a threshold, mentions someone so it is not missed in a busy
+// channel.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+)
+
+// Kinds of webhook
+const (
+	KindSlack = "slack"
+	KindTeams = "teams"
+)
+
+// maxErrorBytes is how much of a refused post's body is kept as its error
+const maxErrorBytes = 512
+
+// Config is the webhook of the channel. Mention is who a failing run
+// escalates to: on Slack it is inserted as is, as in <!here>, <@U024BE7LH>
+// or <!subteam^S012AB3CD>; on Teams it is the display name of MentionID,
+// the user principal name or Entra ID of that user. ErrorRate is the
+// share of rejected rows, from 0 to 1, above which a run mentions them; 0
+// only mentions failed runs.
+type Config struct {
+	Kind       string
+	WebhookURL string
+	Mention    string
+	MentionID  string
+	ErrorRate  float64
+}
+
+// Notifier posts the runs to the channel
+type Notifier struct {
+	cfg      Config
+	client   *http.Client
+	logger   *zap.Logger
+	hostname string
+}
+
+// Option customizes a Notifier
+type Option func(*Notifier)
+
+// WithTimeout bounds each post (default 10s)
+func WithTimeout(d time.Duration) Option {
+	return func(n *Notifier) {
+		if d > 0 {
+			n.client.Timeout = d
+		}
+	}
+}
+
+// WithLogger sets the logger of the posts
+func WithLogger(logger *zap.Logger) Option {
+	return func(n *Notifier) {
+		n.logger = logger
+	}
+}
+
+// NewNotifier creates a notifier posting to the webhook of cfg
+func NewNotifier(cfg Config, opts ...Option) (*Notifier, error) {
+	if cfg.WebhookURL == "" {
+		return nil, errors.New("chat: webhook URL is required")
+	}
+	if cfg.Kind != KindSlack && cfg.Kind != KindTeams {
+		return nil, fmt.Errorf("chat: unknown kind %q", cfg.Kind)
+	}
+	if cfg.Kind == KindTeams && cfg.Mention != "" && cfg.MentionID == "" {
+		return nil, errors.New("chat: a Teams mention needs the ID of the user")
+	}
+	hostname, _ := os.Hostname()
+	n := &Notifier{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   zap.NewNop(),
+		hostname: hostname,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n, nil
+}
+
+// Started posts that a run of source started
+func (n *Notifier) Started(ctx context.Context, runID, source string) error {
+	return n.post(ctx, message{
+		Title: fmt.Sprintf("Import started: %s", path.Base(source)),
+		Facts: []fact{
+			{"Run", runID},
+			{"Source", source},
+			{"Host", n.hostname},
+		},
+	})
+}
+
+// Finished posts the outcome of a recorded run, mentioning Mention when it
+// failed or rejected too many rows
+func (n *Notifier) Finished(ctx context.Context, run *models.Run) error {
+	rejected := run.Invalid + run.Failed
+	rate := 0.0
+	if run.RowsRead > 0 {
+		rate = float64(rejected) / float64(run.RowsRead)
+	}
+	escalate := run.Status == models.RunFailed || (n.cfg.ErrorRate > 0 && rate > n.cfg.ErrorRate)
+
+	m := message{
+		Level:   levelGood,
+		Mention: escalate && n.cfg.Mention != "",
+		Facts: []fact{
+			{"Run", run.ID},
+			{"Source", run.Source},
+			{"Host", n.hostname},
+		},
+	}
+	if run.Partitions > 1 {
+		m.Facts = append(m.Facts, fact{"Partition", fmt.Sprintf("%d of %d (job %s)", run.Partition+1, run.Partitions, run.JobID)})
+	}
+	if run.FinishedAt > 0 {
+		m.Facts = append(m.Facts, fact{"Duration", (time.Duration(run.FinishedAt-run.StartedAt) * time.Second).String()})
+	}
+	m.Facts = append(m.Facts,
+		fact{"Read", fmt.Sprint(run.RowsRead)},
+		fact{"Inserted / updated", fmt.Sprintf("%d / %d", run.Inserted, run.Updated)},
+		fact{"Rejected", fmt.Sprintf("%d invalid, %d failed (%.2f%%)", run.Invalid, run.Failed, 100*rate)},
+	)
+
+	name := path.Base(run.Source)
+	switch {
+	case run.Status == models.RunFailed:
+		m.Level = levelDanger
+		m.Title = "Import failed: " + name
+		m.Facts = append(m.Facts, fact{"Error", run.Error})
+	case run.Status == models.RunCancelled:
+		m.Level = levelWarning
+		m.Title = "Import cancelled: " + name
+	case rejected > 0:
+		m.Level = levelWarning
+		if escalate {
+			m.Level = levelDanger
+		}
+		m.Title = "Import finished with rejected rows: " + name
+	default:
+		m.Title = "Import finished: " + name
+	}
+	if escalate && n.cfg.ErrorRate > 0 && rate > n.cfg.ErrorRate {
+		m.Text = fmt.Sprintf("The error rate is above %.2f%%.", 100*n.cfg.ErrorRate)
+	}
+	return n.post(ctx, m)
+}
+
+// post sends a message to the webhook
+func (n *Notifier) post(ctx context.Context, m message) error {
+	var payload any
+	if n.cfg.Kind == KindTeams {
+		payload = teamsPayload(m, n.cfg)
+	} else {
+		payload = slackPayload(m, n.cfg)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("chat: post: %w", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("chat: webhook answered %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	n.logger.Debug("chat_message_posted", zap.String("title", m.Title), zap.Bool("mention", m.Mention))
+	return nil
+}
@@ -0,0 +1,92 @@
+package chat
+
+import "strings"
+
+// level is the severity of a message, shown as its color
+type level int
+
+const (
+	levelGood level = iota
+	levelWarning
+	levelDanger
+)
+
+// message is what is posted, before it is shaped for Slack or Teams
+type message struct {
+	Title   string
+	Text    string
+	Facts   []fact
+	Level   level
+	Mention bool
+}
+
+type fact struct {
+	Name, Value string
+}
+
+// slackPayload is the body of a Slack incoming webhook: the title, and the
+// mention that notifies, in text; the counters as the fields of a colored
+// attachment
+func slackPayload(m message, cfg Config) map[string]any {
+	text := "*" + slackEscape(m.Title) + "*"
+	if m.Mention {
+		text = cfg.Mention + " " + text
+	}
+	if m.Text != "" {
+		text += "\n" + slackEscape(m.Text)
+	}
+	fields := make([]map[string]any, len(m.Facts))
+	for i, f := range m.Facts {
+		fields[i] = map[string]any{"title": f.Name, "value": slackEscape(f.Value), "short": len(f.Value) <= 40}
+	}
+	color := map[level]string{levelGood: "good", levelWarning: "warning", levelDanger: "danger"}[m.Level]
+	return map[string]any{
+		"text":        text,
+		"attachments": []map[string]any{{"color": color, "fields": fields}},
+	}
+}
+
+// slackEscape escapes the characters Slack reads as markup
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// teamsPayload is the body of a Teams incoming webhook: an Adaptive Card
+// with the title, the counters as a fact set and the mention as an entity
+func teamsPayload(m message, cfg Config) map[string]any {
+	color := map[level]string{levelGood: "Good", levelWarning: "Warning", levelDanger: "Attention"}[m.Level]
+	body := []map[string]any{
+		{"type": "TextBlock", "text": m.Title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+	}
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+	}
+	text := m.Text
+	if m.Mention {
+		at := "<at>" + cfg.Mention + "</at>"
+		text = strings.TrimSpace(at + " " + text)
+		card["msteams"] = map[string]any{"entities": []map[string]any{{
+			"type":      "mention",
+			"text":      at,
+			"mentioned": map[string]any{"id": cfg.MentionID, "name": cfg.Mention},
+		}}}
+	}
+	if text != "" {
+		body = append(body, map[string]any{"type": "TextBlock", "text": text, "wrap": true})
+	}
+	facts := make([]map[string]any, len(m.Facts))
+	for i, f := range m.Facts {
+		facts[i] = map[string]any{"title": f.Name, "value": f.Value}
+	}
+	body = append(body, map[string]any{"type": "FactSet", "facts": facts})
+	card["body"] = body
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
@@ -0,0 +1,1295 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config is the configuration of the API and the processor. Values are
+// resolved from, in increasing priority: defaults, the config file, the
+// environment and command-line flags. The OpenTelemetry exporter keeps
+// reading its standard OTEL_* variables itself.
+type Config struct {
+	// Env is the environment (dev, staging or prod) whose profile sets
+	// the defaults; see profiles
+	Env         string      `mapstructure:"env" yaml:"env"`
+	API         API         `mapstructure:"api" yaml:"api"`
+	DB          DB          `mapstructure:"db" yaml:"db"`
+	Log         Log         `mapstructure:"log" yaml:"log"`
+	Processor   Processor   `mapstructure:"processor" yaml:"processor"`
+	Validation  Validation  `mapstructure:"validation" yaml:"validation"`
+	Sentry      Sentry      `mapstructure:"sentry" yaml:"sentry"`
+	Pushgateway Pushgateway `mapstructure:"pushgateway" yaml:"pushgateway"`
+	Vault       Vault       `mapstructure:"vault" yaml:"vault"`
+	Seed        Seed        `mapstructure:"seed" yaml:"seed"`
+	Outbox      Outbox      `mapstructure:"outbox" yaml:"outbox"`
+	Webhooks    Webhooks    `mapstructure:"webhooks" yaml:"webhooks"`
+	Import      Import      `mapstructure:"import" yaml:"import"`
+	Export      Export      `mapstructure:"export" yaml:"export"`
+	Warehouse   Warehouse   `mapstructure:"warehouse" yaml:"warehouse"`
+	Search      Search      `mapstructure:"search" yaml:"search"`
+	Kafka       Kafka       `mapstructure:"kafka" yaml:"kafka"`
+	CRM         CRM         `mapstructure:"crm" yaml:"crm"`
+	Notify      Notify      `mapstructure:"notify" yaml:"notify"`
+	Analytics   Analytics   `mapstructure:"analytics" yaml:"analytics"`
+	AWS         AWS         `mapstructure:"aws" yaml:"aws"`
+}
+
+// API configures the HTTP server
+type API struct {
+	Port           string        `mapstructure:"port" yaml:"port"`
+	AdminToken     string        `mapstructure:"admin_token" yaml:"admin_token"`
+	IdempotencyTTL time.Duration `mapstructure:"idempotency_ttl" yaml:"idempotency_ttl"`
+	// MaintenanceMode is the mode at startup: off, read_only or write_only
+	MaintenanceMode    string `mapstructure:"maintenance_mode" yaml:"maintenance_mode"`
+	MaintenanceMessage string `mapstructure:"maintenance_message" yaml:"maintenance_message"`
+	// DrainGrace is how long /ready answers 503 before the server stops
+	// accepting connections, for load balancers to de-register it
+	DrainGrace      time.Duration `mapstructure:"drain_grace" yaml:"drain_grace"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" yaml:"shutdown_timeout"`
+	GinMode         string        `mapstructure:"gin_mode" yaml:"gin_mode"`
+	Swagger         bool          `mapstructure:"swagger" yaml:"swagger"`
+	// CacheSize is the number of users whose segmentations are cached in
+	// memory (0 disables the cache), each for CacheTTL
+	CacheSize int           `mapstructure:"cache_size" yaml:"cache_size"`
+	CacheTTL  time.Duration `mapstructure:"cache_ttl" yaml:"cache_ttl"`
+	// StreamThreshold is the number of segmentations above which a GET
+	// response is streamed instead of buffered (0 never streams)
+	StreamThreshold int `mapstructure:"stream_threshold" yaml:"stream_threshold"`
+	// MaxBodyBytes is the largest body accepted by the segmentation write
+	// endpoints (0 for no limit)
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes" yaml:"max_body_bytes"`
+	// RequestTimeout is the deadline of each segmentation read or write;
+	// exports and other streamed responses are bounded by the server write
+	// timeout instead (0 for no deadline)
+	RequestTimeout time.Duration `mapstructure:"request_timeout" yaml:"request_timeout"`
+	// RawReads serves GET /users/:user_id/segmentations through a
+	// database/sql prepared statement instead of GORM
+	RawReads bool `mapstructure:"raw_reads" yaml:"raw_reads"`
+	// UserLookup tells unknown users (404) from users without
+	// segmentations (200 with none): off, table (the users table) or http
+	// (a GET on UserLookupURL, {user_id} replaced, bounded by
+	// UserLookupTimeout)
+	UserLookup        string        `mapstructure:"user_lookup" yaml:"user_lookup"`
+	UserLookupURL     string        `mapstructure:"user_lookup_url" yaml:"user_lookup_url"`
+	UserLookupTimeout time.Duration `mapstructure:"user_lookup_timeout" yaml:"user_lookup_timeout"`
+	// Server tunes the http.Server of the API
+	Server Server `mapstructure:"server" yaml:"server"`
+	// WriteQueue accepts POST writes on disk and flushes them to MySQL in
+	// the background
+	WriteQueue WriteQueue `mapstructure:"write_queue" yaml:"write_queue"`
+	// GRPC serves the gRPC API next to the HTTP one
+	GRPC GRPC `mapstructure:"grpc" yaml:"grpc"`
+}
+
+// GRPC configures the gRPC API (proto/segmentation/v1)
+type GRPC struct {
+	// Port is where the gRPC server listens; empty disables it
+	Port string `mapstructure:"port" yaml:"port"`
+	// BatchSize is how many records of an ingest stream are written per
+	// statement
+	BatchSize int `mapstructure:"batch_size" yaml:"batch_size"`
+	// MaxMessageBytes is the largest message accepted
+	MaxMessageBytes int `mapstructure:"max_message_bytes" yaml:"max_message_bytes"`
+}
+
+// Enabled reports whether the gRPC server is started
+func (g GRPC) Enabled() bool {
+	return g.Port != ""
+}
+
+// WriteQueue configures the write-behind queue of the API
+type WriteQueue struct {
+	// Dir holds the queue files; empty disables the queue and writes are
+	// applied before the response
+	Dir string `mapstructure:"dir" yaml:"dir"`
+	// MaxPending is how many accepted writes may wait to be flushed before
+	// new ones are refused with 503 (0 for no limit)
+	MaxPending int `mapstructure:"max_pending" yaml:"max_pending"`
+	// DedupTTL is how long a flushed item is remembered, so a repeat of it
+	// with the same data is not written again (0 disables deduplication)
+	DedupTTL time.Duration `mapstructure:"dedup_ttl" yaml:"dedup_ttl"`
+}
+
+// Server tunes the API's http.Server. A zero timeout means no timeout, as
+// in net/http.
+type Server struct {
+	ReadTimeout       time.Duration `mapstructure:"read_timeout" yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout" yaml:"read_header_timeout"`
+	// WriteTimeout bounds a whole response, streamed ones and exports
+	// included
+	WriteTimeout   time.Duration `mapstructure:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout    time.Duration `mapstructure:"idle_timeout" yaml:"idle_timeout"`
+	MaxHeaderBytes int           `mapstructure:"max_header_bytes" yaml:"max_header_bytes"`
+	KeepAlive      bool          `mapstructure:"keep_alive" yaml:"keep_alive"`
+	// H2C serves HTTP/2 without TLS, for proxies and clients that speak
+	// it to the backend (prior knowledge)
+	H2C bool `mapstructure:"h2c" yaml:"h2c"`
+}
+
+// DB configures the MySQL connection
+type DB struct {
+	Host     string `mapstructure:"host" yaml:"host"`
+	Port     string `mapstructure:"port" yaml:"port"`
+	Name     string `mapstructure:"name" yaml:"name"`
+	User     string `mapstructure:"user" yaml:"user"`
+	Password string `mapstructure:"password" yaml:"password"`
+	// PasswordFile is read into Password at load, for Docker and
+	// Kubernetes secrets mounted as files
+	PasswordFile string `mapstructure:"password_file" yaml:"password_file"`
+	// ConnectTimeout bounds the retries of the first connection
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout" yaml:"connect_timeout"`
+	// The circuit breaker opens after BreakerThreshold consecutive
+	// connection failures and pings the database every BreakerCooldown,
+	// doubling up to BreakerMaxCooldown, until it answers
+	BreakerThreshold   int           `mapstructure:"breaker_threshold" yaml:"breaker_threshold"`
+	BreakerCooldown    time.Duration `mapstructure:"breaker_cooldown" yaml:"breaker_cooldown"`
+	BreakerMaxCooldown time.Duration `mapstructure:"breaker_max_cooldown" yaml:"breaker_max_cooldown"`
+	// MigrateOnStart applies pending migrations when serve and import
+	// start; pipelines that run "migrate up" explicitly turn it off
+	MigrateOnStart bool `mapstructure:"migrate_on_start" yaml:"migrate_on_start"`
+	// OnlineDDL runs the ALTER TABLE of migrations with ALGORITHM=INPLACE,
+	// LOCK=NONE where they allow it
+	OnlineDDL bool `mapstructure:"online_ddl" yaml:"online_ddl"`
+	// CompressionThreshold is the size in bytes from which segmentation
+	// data is stored compressed (0 stores everything as JSON)
+	CompressionThreshold int `mapstructure:"compression_threshold" yaml:"compression_threshold"`
+	// Connection pool
+	MaxOpenConns    int           `mapstructure:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+}
+
+// Log configures the application and slow query loggers
+type Log struct {
+	Dir    string `mapstructure:"dir" yaml:"dir"`
+	Format string `mapstructure:"format" yaml:"format"`
+	Level  string `mapstructure:"level" yaml:"level"`
+	// Sinks lists the outputs; empty means the file, plus stdout when
+	// PrintLog is set
+	Sinks              []string      `mapstructure:"sinks" yaml:"sinks"`
+	PrintLog           bool          `mapstructure:"print" yaml:"print"`
+	SyslogAddr         string        `mapstructure:"syslog_addr" yaml:"syslog_addr"`
+	SyslogTag          string        `mapstructure:"syslog_tag" yaml:"syslog_tag"`
+	FluentdAddr        string        `mapstructure:"fluentd_addr" yaml:"fluentd_addr"`
+	SlowQueryLog       string        `mapstructure:"slow_query_log" yaml:"slow_query_log"`
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold" yaml:"slow_query_threshold"`
+}
+
+// Processor configures the CSV import
+type Processor struct {
+	DataFile         string        `mapstructure:"data_file" yaml:"data_file"`
+	Mode             string        `mapstructure:"mode" yaml:"mode"`
+	WatchInterval    time.Duration `mapstructure:"watch_interval" yaml:"watch_interval"`
+	Schedule         time.Duration `mapstructure:"schedule" yaml:"schedule"`
+	LogMode          string        `mapstructure:"log_mode" yaml:"log_mode"`
+	LogSample        float64       `mapstructure:"log_sample" yaml:"log_sample"`
+	ProgressInterval time.Duration `mapstructure:"progress_interval" yaml:"progress_interval"`
+	HealthAddr       string        `mapstructure:"health_addr" yaml:"health_addr"`
+	StallTimeout     time.Duration `mapstructure:"stall_timeout" yaml:"stall_timeout"`
+	LeaderElection   bool          `mapstructure:"leader_election" yaml:"leader_election"`
+	LeaderWait       time.Duration `mapstructure:"leader_wait" yaml:"leader_wait"`
+	JobID            string        `mapstructure:"job_id" yaml:"job_id"`
+	Partitions       int           `mapstructure:"partitions" yaml:"partitions"`
+	Partition        int           `mapstructure:"partition" yaml:"partition"`
+	// BatchSize rows are written by each worker in one statement, flushed
+	// when full or FlushInterval after the first pending row
+	BatchSize     int           `mapstructure:"batch_size" yaml:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval" yaml:"flush_interval"`
+	// ReadAhead goroutines validate rows while the producer keeps reading
+	// the file; 0 validates inline
+	ReadAhead int `mapstructure:"read_ahead" yaml:"read_ahead"`
+	// Workers write the rows; 0 starts one per CPU
+	Workers int `mapstructure:"workers" yaml:"workers"`
+	// InitialLoad writes with INSERT IGNORE into an empty table, keeping
+	// the first row of each key instead of updating it
+	InitialLoad bool `mapstructure:"initial_load" yaml:"initial_load"`
+	// Transactional writes the whole file in one transaction, rolled back
+	// by the first rejected row
+	Transactional bool `mapstructure:"transactional" yaml:"transactional"`
+	// DrainTimeout bounds how long a cancelled run waits for its workers
+	// before abandoning them; 0 waits indefinitely
+	DrainTimeout time.Duration `mapstructure:"drain_timeout" yaml:"drain_timeout"`
+	// OutageThreshold is the fraction of the last OutageWindow writes that,
+	// failing with connection errors, pauses the run until the database
+	// answers a ping again (0 disables the pause); pings back off up to
+	// OutageMaxBackoff and a pause longer than OutageMaxPause fails the run
+	// (0 waits indefinitely)
+	OutageThreshold  float64       `mapstructure:"outage_threshold" yaml:"outage_threshold"`
+	OutageWindow     int           `mapstructure:"outage_window" yaml:"outage_window"`
+	OutageMaxBackoff time.Duration `mapstructure:"outage_max_backoff" yaml:"outage_max_backoff"`
+	OutageMaxPause   time.Duration `mapstructure:"outage_max_pause" yaml:"outage_max_pause"`
+	// SFTP replaces the data file by the files dropped on an SFTP server
+	SFTP SFTP `mapstructure:"sftp" yaml:"sftp"`
+	// Report emails a summary of each run
+	Report Report `mapstructure:"report" yaml:"report"`
+	// Chat posts the start and outcome of each run to Slack or Teams
+	Chat Chat `mapstructure:"chat" yaml:"chat"`
+	// Archive keeps a copy of what each succeeded run ingested
+	Archive Archive `mapstructure:"archive" yaml:"archive"`
+}
+
+// SFTP configures the SFTP source of the processor. The files of Host
+// matching Pattern (as in /inbox/*.csv) and older than MinAge are
+// downloaded, imported and moved to ArchiveDir, every PollInterval in the
+// daemon and once in oneshot. The client authenticates as User with the
+// private key in KeyFile and only trusts the host keys in KnownHosts. A
+// file whose import fails MaxAttempts times is moved to FailedDir, or
+// left in place when it is empty. The source is off when Host is empty.
+type SFTP struct {
+	Host          string        `mapstructure:"host" yaml:"host"`
+	Port          int           `mapstructure:"port" yaml:"port"`
+	User          string        `mapstructure:"user" yaml:"user"`
+	KeyFile       string        `mapstructure:"key_file" yaml:"key_file"`
+	KeyPassphrase string        `mapstructure:"key_passphrase" yaml:"key_passphrase"`
+	KnownHosts    string        `mapstructure:"known_hosts" yaml:"known_hosts"`
+	Pattern       string        `mapstructure:"pattern" yaml:"pattern"`
+	ArchiveDir    string        `mapstructure:"archive_dir" yaml:"archive_dir"`
+	FailedDir     string        `mapstructure:"failed_dir" yaml:"failed_dir"`
+	MinAge        time.Duration `mapstructure:"min_age" yaml:"min_age"`
+	PollInterval  time.Duration `mapstructure:"poll_interval" yaml:"poll_interval"`
+	MaxAttempts   int           `mapstructure:"max_attempts" yaml:"max_attempts"`
+	DownloadDir   string        `mapstructure:"download_dir" yaml:"download_dir"`
+}
+
+// Enabled reports whether the processor imports from SFTP
+func (s SFTP) Enabled() bool {
+	return s.Host != ""
+}
+
+// Report configures the email sent after each processor run, through the
+// SMTP server at SMTPAddr, from From to the addresses in To. It holds the
+// counters, duration and most common errors of the run, links to the
+// dead-letter report at ErrorsURL ({run_id} is replaced by the ID of the
+// run) and attaches its CSV when it fits in AttachMaxBytes. SMTPTLS is
+// starttls, tls (implicit, usually port 465) or none. With OnlyProblems
+// clean runs send nothing. Reports are off when SMTPAddr is empty.
+type Report struct {
+	SMTPAddr       string        `mapstructure:"smtp_addr" yaml:"smtp_addr"`
+	SMTPUsername   string        `mapstructure:"smtp_username" yaml:"smtp_username"`
+	SMTPPassword   string        `mapstructure:"smtp_password" yaml:"smtp_password"`
+	SMTPTLS        string        `mapstructure:"smtp_tls" yaml:"smtp_tls"`
+	From           string        `mapstructure:"from" yaml:"from"`
+	To             []string      `mapstructure:"to" yaml:"to"`
+	ErrorsURL      string        `mapstructure:"errors_url" yaml:"errors_url"`
+	AttachMaxBytes int           `mapstructure:"attach_max_bytes" yaml:"attach_max_bytes"`
+	OnlyProblems   bool          `mapstructure:"only_problems" yaml:"only_problems"`
+	Timeout        time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// Enabled reports whether run reports are emailed
+func (r Report) Enabled() bool {
+	return r.SMTPAddr != ""
+}
+
+// Chat configures the messages posted to the Slack or Teams (Kind)
+// incoming webhook at WebhookURL when a run starts (unless NotifyStart is
+// off) and when it ends, with its counters. A run that fails or whose
+// share of rejected rows exceeds ErrorRate (0 to 1; 0 only counts
+// failures) mentions Mention: on Slack a mention as in <!here> or
+// <@U024BE7LH>, on Teams the display name of the user MentionID. Each
+// post may take Timeout. Messages are off when WebhookURL is empty.
+type Chat struct {
+	Kind        string        `mapstructure:"kind" yaml:"kind"`
+	WebhookURL  string        `mapstructure:"webhook_url" yaml:"webhook_url"`
+	Mention     string        `mapstructure:"mention" yaml:"mention"`
+	MentionID   string        `mapstructure:"mention_id" yaml:"mention_id"`
+	ErrorRate   float64       `mapstructure:"error_rate" yaml:"error_rate"`
+	NotifyStart bool          `mapstructure:"notify_start" yaml:"notify_start"`
+	Timeout     time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// Enabled reports whether runs are posted to a chat
+func (c Chat) Enabled() bool {
+	return c.WebhookURL != ""
+}
+
+// Archive configures the copy of the input file, rejected rows and
+// summary of each succeeded run uploaded to S3, under
+// <prefix>/<yyyy>/<mm>/<dd>/<run_id>/ with the AWS credentials. Tags
+// (in the environment "key:value,key2:value2") are set on every object,
+// for the lifecycle rules of the bucket. Archiving is off when the bucket
+// is empty.
+type Archive struct {
+	S3   S3                `mapstructure:"s3" yaml:"s3"`
+	Tags map[string]string `mapstructure:"tags" yaml:"tags"`
+}
+
+// Enabled reports whether the runs are archived
+func (a Archive) Enabled() bool {
+	return a.S3.Bucket != ""
+}
+
+// Validation configures the write validation rules. In the environment
+// the maps use the compact forms "old:new,old2:new2" and
+// "type:key1|key2,type2:key3".
+type Validation struct {
+	Mode            string              `mapstructure:"mode" yaml:"mode"`
+	DeprecatedTypes map[string]string   `mapstructure:"deprecated_types" yaml:"deprecated_types"`
+	DataKeys        map[string][]string `mapstructure:"data_keys" yaml:"data_keys"`
+	// MaxDataBytes rejects writes whose data is larger; 0 disables the limit
+	MaxDataBytes int `mapstructure:"max_data_bytes" yaml:"max_data_bytes"`
+	// MaxDataDepth, MaxDataKeys and MaxDataStringBytes reject data nested
+	// deeper, with more keys in total or with a longer key or string; 0
+	// disables each limit
+	MaxDataDepth       int `mapstructure:"max_data_depth" yaml:"max_data_depth"`
+	MaxDataKeys        int `mapstructure:"max_data_keys" yaml:"max_data_keys"`
+	MaxDataStringBytes int `mapstructure:"max_data_string_bytes" yaml:"max_data_string_bytes"`
+	// NameFoldCase and NameStripAccents make names differing only in case
+	// or accents the same segmentation
+	NameFoldCase     bool `mapstructure:"name_fold_case" yaml:"name_fold_case"`
+	NameStripAccents bool `mapstructure:"name_strip_accents" yaml:"name_strip_accents"`
+	// MaxUserID rejects larger user IDs in the API and the processor; 0
+	// allows the whole uint64 range
+	MaxUserID uint64 `mapstructure:"max_user_id" yaml:"max_user_id"`
+}
+
+// Sentry configures error reporting; an empty DSN disables it
+type Sentry struct {
+	DSN         string `mapstructure:"dsn" yaml:"dsn"`
+	Environment string `mapstructure:"environment" yaml:"environment"`
+	Release     string `mapstructure:"release" yaml:"release"`
+}
+
+// Pushgateway configures the push of processor run metrics; an empty URL
+// disables it
+type Pushgateway struct {
+	URL string `mapstructure:"url" yaml:"url"`
+	Job string `mapstructure:"job" yaml:"job"`
+}
+
+// Vault configures fetching the database credentials from HashiCorp Vault
+// instead of db.user and db.password; an empty DBPath disables it.
+// DBPath is read with a GET, e.g. database/creds/segmentation (database
+// secrets engine) or secret/data/segmentation (KV v2), and must return
+// username and password.
+type Vault struct {
+	Addr string `mapstructure:"addr" yaml:"addr"`
+	// Token authenticates to Vault; TokenFile is read into it at load
+	Token     string `mapstructure:"token" yaml:"token"`
+	TokenFile string `mapstructure:"token_file" yaml:"token_file"`
+	DBPath    string `mapstructure:"db_path" yaml:"db_path"`
+}
+
+// Enabled reports whether the database credentials come from Vault
+func (v Vault) Enabled() bool {
+	return v.DBPath != ""
+}
+
+// Seed configures the fake data written by the seed command. Users get
+// consecutive IDs from FirstUserID and 1 to MaxPerUser segmentations each;
+// the same RandomSeed always produces the same data.
+type Seed struct {
+	Users       int `mapstructure:"users" yaml:"users"`
+	MaxPerUser  int `mapstructure:"max_per_user" yaml:"max_per_user"`
+	FirstUserID int `mapstructure:"first_user_id" yaml:"first_user_id"`
+	RandomSeed  int `mapstructure:"random_seed" yaml:"random_seed"`
+}
+
+// Outbox configures the transactional outbox: with Enabled, every write
+// also records an event in segmentation_outbox, and the API instance
+// holding the relay lock publishes the pending events every PollInterval,
+// BatchSize at a time. Published events are deleted after Retention; 0
+// keeps them.
+type Outbox struct {
+	Enabled      bool          `mapstructure:"enabled" yaml:"enabled"`
+	BatchSize    int           `mapstructure:"batch_size" yaml:"batch_size"`
+	PollInterval time.Duration `mapstructure:"poll_interval" yaml:"poll_interval"`
+	Retention    time.Duration `mapstructure:"retention" yaml:"retention"`
+}
+
+// Webhooks configures the delivery of the outbox events to the webhooks
+// registered at /webhooks; it needs the outbox. Each delivery attempt may
+// take Timeout, and a delivery fails after MaxAttempts attempts. Finished
+// deliveries are deleted after Retention; 0 keeps them.
+type Webhooks struct {
+	Enabled     bool          `mapstructure:"enabled" yaml:"enabled"`
+	Timeout     time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	MaxAttempts int           `mapstructure:"max_attempts" yaml:"max_attempts"`
+	Retention   time.Duration `mapstructure:"retention" yaml:"retention"`
+}
+
+// Import configures the imports started with POST /imports: the files,
+// uploaded or downloaded from an s3:// URL with the AWS credentials, are
+// kept under Dir until their run finishes. Imports are off when Dir is
+// empty; S3 URLs are refused without credentials.
+type Import struct {
+	Dir        string `mapstructure:"dir" yaml:"dir"`
+	MaxBytes   int64  `mapstructure:"max_bytes" yaml:"max_bytes"`
+	S3Region   string `mapstructure:"s3_region" yaml:"s3_region"`
+	S3Endpoint string `mapstructure:"s3_endpoint" yaml:"s3_endpoint"`
+}
+
+// Enabled reports whether imports have a directory for their files
+func (i Import) Enabled() bool {
+	return i.Dir != ""
+}
+
+// Export configures the table exports started with POST /admin/exports:
+// gzip-compressed NDJSON files of ChunkRows rows each, written under Dir
+// or uploaded to the S3 bucket. Exports are off when neither is set.
+//
+// PseudonymKey allows the pseudonymized exports, which replace the user IDs
+// by their HMAC under the key and drop the StripDataKeys members of data.
+type Export struct {
+	Dir           string   `mapstructure:"dir" yaml:"dir"`
+	ChunkRows     int      `mapstructure:"chunk_rows" yaml:"chunk_rows"`
+	S3            S3       `mapstructure:"s3" yaml:"s3"`
+	PseudonymKey  string   `mapstructure:"pseudonym_key" yaml:"pseudonym_key"`
+	StripDataKeys []string `mapstructure:"strip_data_keys" yaml:"strip_data_keys"`
+}
+
+// Enabled reports whether exports have a destination
+func (e Export) Enabled() bool {
+	return e.Dir != "" || e.S3.Bucket != ""
+}
+
+// S3 is a bucket on AWS or, with Endpoint, on an S3-compatible service
+// such as MinIO, written with the AWS credentials
+type S3 struct {
+	Bucket   string `mapstructure:"bucket" yaml:"bucket"`
+	Prefix   string `mapstructure:"prefix" yaml:"prefix"`
+	Region   string `mapstructure:"region" yaml:"region"`
+	Endpoint string `mapstructure:"endpoint" yaml:"endpoint"`
+}
+
+// Warehouse configures the sync of the changed segmentations to a data
+// warehouse: every Interval, the API instance holding the sync lock loads
+// the rows updated since the last sync, BatchRows per bulk load, into
+// BigQuery or Redshift (Kind; empty disables the sync). Rows updated in
+// the last Lag wait for the next sync, so writes still being committed
+// are not skipped.
+type Warehouse struct {
+	Kind      string        `mapstructure:"kind" yaml:"kind"`
+	Interval  time.Duration `mapstructure:"interval" yaml:"interval"`
+	BatchRows int           `mapstructure:"batch_rows" yaml:"batch_rows"`
+	Lag       time.Duration `mapstructure:"lag" yaml:"lag"`
+	BigQuery  BigQuery      `mapstructure:"bigquery" yaml:"bigquery"`
+	Redshift  Redshift      `mapstructure:"redshift" yaml:"redshift"`
+}
+
+// BigQuery is the table the rows are appended to with load jobs,
+// authenticated with the key file of a service account
+type BigQuery struct {
+	Project         string `mapstructure:"project" yaml:"project"`
+	Dataset         string `mapstructure:"dataset" yaml:"dataset"`
+	Table           string `mapstructure:"table" yaml:"table"`
+	Location        string `mapstructure:"location" yaml:"location"`
+	CredentialsFile string `mapstructure:"credentials_file" yaml:"credentials_file"`
+}
+
+// Redshift is the table the rows are appended to with COPY, run through
+// the Redshift Data API on a provisioned cluster (ClusterID) or a
+// serverless workgroup (Workgroup). The rows are staged in StagingBucket,
+// which IAMRole must be able to read.
+type Redshift struct {
+	Region        string `mapstructure:"region" yaml:"region"`
+	ClusterID     string `mapstructure:"cluster_id" yaml:"cluster_id"`
+	Workgroup     string `mapstructure:"workgroup" yaml:"workgroup"`
+	Database      string `mapstructure:"database" yaml:"database"`
+	DBUser        string `mapstructure:"db_user" yaml:"db_user"`
+	SecretARN     string `mapstructure:"secret_arn" yaml:"secret_arn"`
+	Table         string `mapstructure:"table" yaml:"table"`
+	IAMRole       string `mapstructure:"iam_role" yaml:"iam_role"`
+	StagingBucket string `mapstructure:"staging_bucket" yaml:"staging_bucket"`
+	StagingPrefix string `mapstructure:"staging_prefix" yaml:"staging_prefix"`
+}
+
+// Search configures the Elasticsearch or OpenSearch index behind GET
+// /segmentations/search. The outbox relay mirrors every write into Index,
+// so search needs the outbox; "reindex" fills the index from the table.
+// Requests are authenticated with APIKey or, without it, with Username and
+// Password, and each may take Timeout. Search is off when URL is empty.
+type Search struct {
+	URL      string        `mapstructure:"url" yaml:"url"`
+	Index    string        `mapstructure:"index" yaml:"index"`
+	Username string        `mapstructure:"username" yaml:"username"`
+	Password string        `mapstructure:"password" yaml:"password"`
+	APIKey   string        `mapstructure:"api_key" yaml:"api_key"`
+	Timeout  time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// Enabled reports whether search has an index
+func (s Search) Enabled() bool {
+	return s.URL != ""
+}
+
+// Kafka configures the publication of the outbox events to Topic through
+// the Confluent REST Proxy at URL, so it needs the outbox. Values are JSON
+// or, in the avro Format, Avro records whose schemas are registered in the
+// registry at SchemaRegistryURL under a subject per event type, set to the
+// Compatibility level first (empty leaves the registry's). Username and
+// Password authenticate to the proxy and the registry, and each request
+// may take Timeout. Publication is off when URL is empty.
+type Kafka struct {
+	URL               string        `mapstructure:"url" yaml:"url"`
+	Topic             string        `mapstructure:"topic" yaml:"topic"`
+	Format            string        `mapstructure:"format" yaml:"format"`
+	SchemaRegistryURL string        `mapstructure:"schema_registry_url" yaml:"schema_registry_url"`
+	Compatibility     string        `mapstructure:"compatibility" yaml:"compatibility"`
+	Username          string        `mapstructure:"username" yaml:"username"`
+	Password          string        `mapstructure:"password" yaml:"password"`
+	Timeout           time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// Enabled reports whether the events are published to Kafka
+func (k Kafka) Enabled() bool {
+	return k.URL != ""
+}
+
+// CRM configures the connector keeping the contacts of a CRM in step with
+// the segmentations. The outbox relay turns the changes into contact
+// updates, so the connector needs the outbox: Fields maps each synced
+// segmentation type to the contact attribute holding its names, in the
+// environment in the compact form "type:attribute,type2:attribute2".
+// Updates are POSTed to URL in batches of BatchSize contacts, in the
+// generic or hubspot Format, at most RateLimit requests per second (0 does
+// not limit them) and retried up to MaxRetries times. The sync is off when
+// URL is empty.
+type CRM struct {
+	URL        string            `mapstructure:"url" yaml:"url"`
+	Format     string            `mapstructure:"format" yaml:"format"`
+	Token      string            `mapstructure:"token" yaml:"token"`
+	IDProperty string            `mapstructure:"id_property" yaml:"id_property"`
+	Fields     map[string]string `mapstructure:"fields" yaml:"fields"`
+	BatchSize  int               `mapstructure:"batch_size" yaml:"batch_size"`
+	RateLimit  float64           `mapstructure:"rate_limit" yaml:"rate_limit"`
+	MaxRetries int               `mapstructure:"max_retries" yaml:"max_retries"`
+	Timeout    time.Duration     `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// Enabled reports whether the CRM sync has an endpoint
+func (c CRM) Enabled() bool {
+	return c.URL != ""
+}
+
+// Notify configures the change notifications published to Redis after
+// every write of the API and the processor, on a channel per user and one
+// per type whose names start with ChannelPrefix. Up to QueueSize changes
+// wait to be published; more are dropped. The API replicas also listen to
+// them to invalidate their response caches. Notifications are off when
+// RedisAddr is empty.
+type Notify struct {
+	RedisAddr     string `mapstructure:"redis_addr" yaml:"redis_addr"`
+	RedisUsername string `mapstructure:"redis_username" yaml:"redis_username"`
+	RedisPassword string `mapstructure:"redis_password" yaml:"redis_password"`
+	RedisDB       int    `mapstructure:"redis_db" yaml:"redis_db"`
+	RedisTLS      bool   `mapstructure:"redis_tls" yaml:"redis_tls"`
+	ChannelPrefix string `mapstructure:"channel_prefix" yaml:"channel_prefix"`
+	QueueSize     int    `mapstructure:"queue_size" yaml:"queue_size"`
+}
+
+// Enabled reports whether changes are published
+func (n Notify) Enabled() bool {
+	return n.RedisAddr != ""
+}
+
+// Analytics configures the aggregates of /admin/analytics. Each is
+// computed at most once per CacheTTL on every API instance. Every
+// SnapshotInterval, the instance holding the snapshot lock records the
+// size of each type for the growth over time (0 disables the snapshots).
+// Overlaps of segments larger than OverlapSampleThreshold users are
+// estimated from a sample (0 never samples).
+type Analytics struct {
+	CacheTTL               time.Duration `mapstructure:"cache_ttl" yaml:"cache_ttl"`
+	SnapshotInterval       time.Duration `mapstructure:"snapshot_interval" yaml:"snapshot_interval"`
+	OverlapSampleThreshold int64         `mapstructure:"overlap_sample_threshold" yaml:"overlap_sample_threshold"`
+}
+
+// AWS holds the static credentials of the S3 exports and the Redshift
+// sync
+type AWS struct {
+	AccessKeyID     string `mapstructure:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string `mapstructure:"session_token" yaml:"session_token"`
+}
+
+// setting describes one configuration key: its default, the environment
+// variable it is read from and the help of its flag
+type setting struct {
+	key   string
+	env   string
+	def   any
+	usage string
+}
+
+var settings = []setting{
+	{"env", "APP_ENV", "dev", "dev, staging or prod; selects the defaults of the profile"},
+
+	{"api.port", "API_PORT", "8080", "HTTP port"},
+	{"api.admin_token", "ADMIN_TOKEN", "", "bearer token of the /admin routes (empty disables them)"},
+	{"api.idempotency_ttl", "IDEMPOTENCY_TTL", 24 * time.Hour, "how long Idempotency-Key responses are kept"},
+	{"api.maintenance_mode", "MAINTENANCE_MODE", "off", "off, read_only (writes answer 503) or write_only (reads answer 503)"},
+	{"api.maintenance_message", "MAINTENANCE_MESSAGE", "", "message of the requests refused during maintenance"},
+	{"api.drain_grace", "API_DRAIN_GRACE", time.Duration(0), "on SIGTERM, how long /ready fails before the server stops accepting connections"},
+	{"api.shutdown_timeout", "API_SHUTDOWN_TIMEOUT", 30 * time.Second, "how long in-flight requests get to finish on shutdown"},
+	{"api.gin_mode", "GIN_MODE", "debug", "gin mode: debug, release or test"},
+	{"api.swagger", "API_SWAGGER", true, "serve the Swagger UI at /swagger and the spec at /openapi.json"},
+	{"api.cache_size", "API_CACHE_SIZE", 0, "users whose segmentations are cached in memory (0 disables the cache)"},
+	{"api.cache_ttl", "API_CACHE_TTL", 30 * time.Second, "how long a cached user is served before reading MySQL again"},
+	{"api.raw_reads", "API_RAW_READS", false, "read segmentations with a database/sql prepared statement instead of GORM"},
+	{"api.user_lookup", "API_USER_LOOKUP", "off", "how a user without segmentations is told from an unknown one: off, table or http"},
+	{"api.user_lookup_url", "API_USER_LOOKUP_URL", "", "http user lookup: URL answering 2xx or 404, with {user_id} replaced"},
+	{"api.user_lookup_timeout", "API_USER_LOOKUP_TIMEOUT", 2 * time.Second, "http user lookup: how long a request may take"},
+	{"api.stream_threshold", "API_STREAM_THRESHOLD", 5000, "segmentations above which a GET response is streamed (0 never streams)"},
+	{"api.request_timeout", "API_REQUEST_TIMEOUT", 5 * time.Second, "deadline of each segmentation read or write, answered 504 when exceeded (0 for no deadline)"},
+	{"api.max_body_bytes", "API_MAX_BODY_BYTES", int64(8 << 20), "largest request body accepted by the segmentation write endpoints, in bytes (0 for no limit)"},
+	{"api.server.read_timeout", "API_READ_TIMEOUT", 30 * time.Second, "how long reading a whole request may take (0 for no limit)"},
+	{"api.server.read_header_timeout", "API_READ_HEADER_TIMEOUT", 10 * time.Second, "how long reading the request headers may take"},
+	{"api.server.write_timeout", "API_WRITE_TIMEOUT", 60 * time.Second, "how long writing a response may take, streams and exports included (0 for no limit)"},
+	{"api.server.idle_timeout", "API_IDLE_TIMEOUT", 120 * time.Second, "how long an idle keep-alive connection is kept open"},
+	{"api.server.max_header_bytes", "API_MAX_HEADER_BYTES", 1 << 20, "largest request header accepted, in bytes"},
+	{"api.server.keep_alive", "API_KEEP_ALIVE", true, "reuse connections between requests (HTTP/1.1 keep-alive)"},
+	{"api.server.h2c", "API_H2C", false, "also serve HTTP/2 without TLS (h2c, prior knowledge)"},
+	{"api.write_queue.dir", "API_WRITE_QUEUE_DIR", "", "directory of the write-behind queue; POST writes answer 202 and are flushed in the background (empty disables)"},
+	{"api.write_queue.max_pending", "API_WRITE_QUEUE_MAX_PENDING", 10000, "queued writes above which new ones are refused with 503 (0 for no limit)"},
+	{"api.write_queue.dedup_ttl", "API_WRITE_QUEUE_DEDUP_TTL", time.Duration(0), "skip queued items repeating, with the same data, an item flushed this recently (0 disables)"},
+	{"api.grpc.port", "GRPC_PORT", "", "port of the gRPC API, with the StreamUpsert ingest (empty disables)"},
+	{"api.grpc.batch_size", "GRPC_BATCH_SIZE", 500, "records of a StreamUpsert stream written per statement"},
+	{"api.grpc.max_message_bytes", "GRPC_MAX_MESSAGE_BYTES", 4 << 20, "largest gRPC message accepted, in bytes"},
+
+	{"db.host", "DB_HOST", "", "MySQL host"},
+	{"db.port", "DB_PORT", "3306", "MySQL port"},
+	{"db.name", "DB_NAME", "", "MySQL database"},
+	{"db.user", "DB_USER", "", "MySQL user"},
+	{"db.password", "DB_PASSWORD", "", "MySQL password"},
+	{"db.password_file", "DB_PASSWORD_FILE", "", "file holding the MySQL password (Docker/Kubernetes secret)"},
+	{"db.connect_timeout", "DB_CONNECT_TIMEOUT", 30 * time.Second, "how long to retry the first connection (0 tries once)"},
+	{"db.breaker_threshold", "DB_BREAKER_THRESHOLD", 5, "consecutive connection failures that open the circuit breaker (0 disables)"},
+	{"db.breaker_cooldown", "DB_BREAKER_COOLDOWN", time.Second, "wait before the first reconnection attempt"},
+	{"db.breaker_max_cooldown", "DB_BREAKER_MAX_COOLDOWN", 30 * time.Second, "maximum wait between reconnection attempts"},
+	{"db.migrate_on_start", "DB_MIGRATE_ON_START", true, "apply pending migrations when the API and the processor start"},
+	{"db.compression_threshold", "DB_COMPRESSION_THRESHOLD", 0, "store segmentation data of this many bytes or more compressed (0 disables)"},
+	{"db.online_ddl", "DB_ONLINE_DDL", false, "run migration ALTER TABLEs online (ALGORITHM=INPLACE, LOCK=NONE), failing instead of locking the table"},
+	{"db.max_open_conns", "DB_MAX_OPEN_CONNS", 32, "maximum open connections of the pool (0 uses 32)"},
+	{"db.max_idle_conns", "DB_MAX_IDLE_CONNS", 32, "maximum idle connections kept by the pool"},
+	{"db.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", 30 * time.Second, "how long a connection is reused"},
+
+	{"log.dir", "LOG_DIR", "./logs", "directory of the log files"},
+	{"log.format", "LOG_FORMAT", "text", "text (alias console) or json"},
+	{"log.level", "LOG_LEVEL", "info", "debug, info, warn or error"},
+	{"log.sinks", "LOG_SINKS", []string{}, "comma-separated file, stdout, stderr, syslog, fluentd"},
+	{"log.print", "PRINTLOG", false, "also log to stdout when log.sinks is empty"},
+	{"log.syslog_addr", "SYSLOG_ADDR", "", "syslog server (udp://host:514); local daemon when empty"},
+	{"log.syslog_tag", "SYSLOG_TAG", "segmentation", "syslog tag"},
+	{"log.fluentd_addr", "FLUENTD_ADDR", "", "fluentd in_tcp address (host:port)"},
+	{"log.slow_query_log", "SLOW_QUERY_LOG", "", "slow query file (default <log.dir>/slow-queries.log) or stdout"},
+	{"log.slow_query_threshold", "SLOW_QUERY_THRESHOLD", time.Second, "minimum duration of a slow query (0 disables)"},
+
+	{"processor.data_file", "DATAFILEPATH", "", "CSV file imported by the processor"},
+	{"processor.mode", "PROCESSOR_MODE", "oneshot", "oneshot exits after the file; daemon stays resident and re-imports it"},
+	{"processor.watch_interval", "PROCESSOR_WATCH_INTERVAL", 10 * time.Second, "daemon: interval between checks of the data file"},
+	{"processor.schedule", "PROCESSOR_SCHEDULE", time.Duration(0), "daemon: re-import the file at this interval even if unchanged (0 disables)"},
+	{"processor.log_mode", "PROCESSOR_LOG_MODE", "rows", "rows or aggregate"},
+	{"processor.log_sample", "PROCESSOR_LOG_SAMPLE", 0.0, "fraction of successes logged in aggregate mode"},
+	{"processor.progress_interval", "PROCESSOR_PROGRESS_INTERVAL", 2 * time.Second, "interval between progress lines"},
+	{"processor.health_addr", "PROCESSOR_HEALTH_ADDR", "", "address of the /healthz and /readyz probes (empty disables)"},
+	{"processor.stall_timeout", "PROCESSOR_STALL_TIMEOUT", 5 * time.Minute, "time without progress after which the processor is stuck"},
+	{"processor.leader_election", "PROCESSOR_LEADER_ELECTION", false, "only the replica holding the lock of the data file imports it"},
+	{"processor.leader_wait", "PROCESSOR_LEADER_WAIT", time.Duration(0), "how long a replica waits for the lock before exiting (0 exits at once)"},
+	{"processor.job_id", "PROCESSOR_JOB_ID", "", "import job shared by the instances of a partitioned file"},
+	{"processor.partitions", "PROCESSOR_PARTITIONS", 1, "instances the file is split across by user_id"},
+	{"processor.partition", "PROCESSOR_PARTITION", -1, "partition imported by this instance (-1 claims a free one)"},
+	{"processor.batch_size", "PROCESSOR_BATCH_SIZE", 500, "rows each worker writes in one statement (1 writes row by row)"},
+	{"processor.flush_interval", "PROCESSOR_FLUSH_INTERVAL", 200 * time.Millisecond, "how long a partial batch waits before it is written"},
+	{"processor.read_ahead", "PROCESSOR_READ_AHEAD", 0, "goroutines validating rows ahead of the workers (0 validates inline)"},
+	{"processor.workers", "PROCESSOR_WORKERS", 0, "goroutines writing rows (0 uses one per CPU)"},
+	{"processor.initial_load", "PROCESSOR_INITIAL_LOAD", false, "first load into an empty table: INSERT IGNORE instead of upserts"},
+	{"processor.transactional", "PROCESSOR_TRANSACTIONAL", false, "write the whole file in one transaction, rolled back by any rejected row"},
+	{"processor.drain_timeout", "PROCESSOR_DRAIN_TIMEOUT", 30 * time.Second, "how long a cancelled run waits for in-flight writes (0 waits indefinitely)"},
+	{"processor.outage_threshold", "PROCESSOR_OUTAGE_THRESHOLD", 0.5, "fraction of the recent writes failing with connection errors that pauses the run until the database is back (0 disables)"},
+	{"processor.outage_window", "PROCESSOR_OUTAGE_WINDOW", 100, "recent writes considered by processor.outage_threshold"},
+	{"processor.outage_max_backoff", "PROCESSOR_OUTAGE_MAX_BACKOFF", 30 * time.Second, "longest interval between the database pings of a paused run"},
+	{"processor.outage_max_pause", "PROCESSOR_OUTAGE_MAX_PAUSE", 15 * time.Minute, "a run paused longer than this fails (0 waits indefinitely)"},
+	{"processor.sftp.host", "SFTP_HOST", "", "SFTP server the processor imports the files of, instead of the data file (empty disables)"},
+	{"processor.sftp.port", "SFTP_PORT", 22, "SSH port of the SFTP server"},
+	{"processor.sftp.user", "SFTP_USER", "", "SSH user"},
+	{"processor.sftp.key_file", "SFTP_KEY_FILE", "", "private key the user authenticates with"},
+	{"processor.sftp.key_passphrase", "SFTP_KEY_PASSPHRASE", "", "passphrase of an encrypted private key"},
+	{"processor.sftp.known_hosts", "SFTP_KNOWN_HOSTS", "", "known_hosts file holding the host keys of the server"},
+	{"processor.sftp.pattern", "SFTP_PATTERN", "", "remote files imported, as in /inbox/*.csv (wildcards only in the file name)"},
+	{"processor.sftp.archive_dir", "SFTP_ARCHIVE_DIR", "", "remote directory the imported files are moved to"},
+	{"processor.sftp.failed_dir", "SFTP_FAILED_DIR", "", "remote directory of the files that failed every attempt (empty leaves them in place)"},
+	{"processor.sftp.min_age", "SFTP_MIN_AGE", time.Minute, "files modified more recently may still be uploading and wait for the next poll"},
+	{"processor.sftp.poll_interval", "SFTP_POLL_INTERVAL", time.Minute, "daemon: interval between listings of the server"},
+	{"processor.sftp.max_attempts", "SFTP_MAX_ATTEMPTS", 3, "failed imports of a file before it is given up"},
+	{"processor.report.smtp_addr", "REPORT_SMTP_ADDR", "", "host:port of the SMTP server the run reports are emailed through (empty disables)"},
+	{"processor.report.smtp_username", "REPORT_SMTP_USERNAME", "", "SMTP user, authenticated with PLAIN"},
+	{"processor.report.smtp_password", "REPORT_SMTP_PASSWORD", "", "SMTP password"},
+	{"processor.report.smtp_tls", "REPORT_SMTP_TLS", "starttls", "starttls, tls (implicit) or none"},
+	{"processor.report.from", "REPORT_FROM", "", "sender of the run reports"},
+	{"processor.report.to", "REPORT_TO", []string{}, "comma-separated recipients of the run reports"},
+	{"processor.report.errors_url", "REPORT_ERRORS_URL", "", "link to the dead-letter report of the run, with {run_id} replaced by its ID"},
+	{"processor.report.attach_max_bytes", "REPORT_ATTACH_MAX_BYTES", 5 << 20, "largest dead-letter CSV attached to a report (0 attaches none)"},
+	{"processor.report.only_problems", "REPORT_ONLY_PROBLEMS", false, "skip the reports of runs that succeeded without rejected rows"},
+	{"processor.report.timeout", "REPORT_TIMEOUT", 30 * time.Second, "how long sending a report may take"},
+	{"processor.chat.kind", "CHAT_KIND", "slack", "slack or teams: the kind of incoming webhook the runs are posted to"},
+	{"processor.chat.webhook_url", "CHAT_WEBHOOK_URL", "", "incoming webhook the start and outcome of each run are posted to (empty disables)"},
+	{"processor.chat.mention", "CHAT_MENTION", "", "who failing runs mention: <!here>, <@U...> or <!subteam^S...> on Slack, a display name on Teams"},
+	{"processor.chat.mention_id", "CHAT_MENTION_ID", "", "Teams: user principal name or ID of the mentioned user"},
+	{"processor.chat.error_rate", "CHAT_ERROR_RATE", 0.05, "share of rejected rows (0 to 1) above which a run mentions chat.mention; 0 only mentions failed runs"},
+	{"processor.chat.notify_start", "CHAT_NOTIFY_START", true, "also post when a run starts"},
+	{"processor.chat.timeout", "CHAT_TIMEOUT", 10 * time.Second, "how long a post to the webhook may take"},
+	{"processor.archive.s3.bucket", "ARCHIVE_S3_BUCKET", "", "S3 bucket the input file, rejected rows and summary of each succeeded run are archived to (empty disables)"},
+	{"processor.archive.s3.prefix", "ARCHIVE_S3_PREFIX", "archive", "prefix of the keys of the archived runs in the bucket"},
+	{"processor.archive.s3.region", "ARCHIVE_S3_REGION", "us-east-1", "region of the bucket"},
+	{"processor.archive.s3.endpoint", "ARCHIVE_S3_ENDPOINT", "", "URL of an S3-compatible service such as MinIO (empty uses AWS)"},
+	{"processor.archive.tags", "ARCHIVE_TAGS", "", "tags of the archived objects, e.g. for retention rules (key:value,...)"},
+	{"processor.sftp.download_dir", "SFTP_DOWNLOAD_DIR", "", "local directory of the files being imported (empty uses the system temporary directory)"},
+
+	{"validation.mode", "VALIDATION_MODE", "lenient", "lenient or strict"},
+	{"validation.deprecated_types", "DEPRECATED_TYPES", "", "legacy type names (old:new,...)"},
+	{"validation.data_keys", "DATA_KEYS", "", "known data keys per type (type:key1|key2,...)"},
+	{"validation.max_data_bytes", "MAX_DATA_BYTES", 65536, "largest data document accepted, in bytes (0 disables)"},
+	{"validation.max_data_depth", "MAX_DATA_DEPTH", 16, "deepest nesting of objects and arrays accepted in data (0 disables)"},
+	{"validation.max_data_keys", "MAX_DATA_KEYS", 1000, "most object keys accepted in a data document, at any depth (0 disables)"},
+	{"validation.max_data_string_bytes", "MAX_DATA_STRING_BYTES", 8192, "longest key or string accepted in data, in bytes (0 disables)"},
+	{"validation.name_fold_case", "NAME_FOLD_CASE", false, "names differing only in case are the same segmentation"},
+	{"validation.name_strip_accents", "NAME_STRIP_ACCENTS", false, "names differing only in accents are the same segmentation"},
+	{"validation.max_user_id", "MAX_USER_ID", uint64(1<<53 - 1), "largest user_id accepted by the API and the processor (0 allows any uint64)"},
+
+	{"sentry.dsn", "SENTRY_DSN", "", "Sentry DSN (empty disables error reporting)"},
+	{"sentry.environment", "SENTRY_ENVIRONMENT", "", "Sentry environment"},
+	{"sentry.release", "SENTRY_RELEASE", "", "Sentry release"},
+
+	{"pushgateway.url", "PUSHGATEWAY_URL", "", "Pushgateway of the processor run metrics (empty disables)"},
+	{"pushgateway.job", "PUSHGATEWAY_JOB", "segmentation_processor", "Pushgateway job"},
+
+	{"vault.addr", "VAULT_ADDR", "", "Vault server address"},
+	{"vault.token", "VAULT_TOKEN", "", "Vault token"},
+	{"vault.token_file", "VAULT_TOKEN_FILE", "", "file holding the Vault token"},
+	{"vault.db_path", "VAULT_DB_PATH", "", "Vault path of the MySQL credentials (empty disables Vault)"},
+
+	{"seed.users", "SEED_USERS", 1000, "fake users written by the seed command"},
+	{"seed.max_per_user", "SEED_MAX_PER_USER", 5, "maximum segmentations per seeded user"},
+	{"seed.first_user_id", "SEED_FIRST_USER_ID", 1, "user ID of the first seeded user"},
+	{"seed.random_seed", "SEED_RANDOM_SEED", 1, "seed of the fake data generator"},
+
+	{"outbox.enabled", "OUTBOX_ENABLED", false, "record every write as an event in segmentation_outbox and relay the events"},
+	{"outbox.batch_size", "OUTBOX_BATCH_SIZE", 100, "events published by the relay at a time"},
+	{"outbox.poll_interval", "OUTBOX_POLL_INTERVAL", time.Second, "how often the relay looks for pending events"},
+	{"outbox.retention", "OUTBOX_RETENTION", 7 * 24 * time.Hour, "how long published events are kept (0 keeps them)"},
+
+	{"webhooks.enabled", "WEBHOOKS_ENABLED", false, "serve /webhooks and deliver the outbox events to the registered webhooks (needs outbox.enabled)"},
+	{"webhooks.timeout", "WEBHOOKS_TIMEOUT", 10 * time.Second, "how long a delivery attempt may take"},
+	{"webhooks.max_attempts", "WEBHOOKS_MAX_ATTEMPTS", 10, "attempts after which a delivery is given up as failed"},
+	{"webhooks.retention", "WEBHOOKS_RETENTION", 7 * 24 * time.Hour, "how long finished deliveries are kept (0 keeps them)"},
+
+	{"import.dir", "IMPORT_DIR", "", "directory the files of POST /imports are kept in until imported (empty disables POST /imports)"},
+	{"import.max_bytes", "IMPORT_MAX_BYTES", int64(1 << 30), "size limit of an uploaded or downloaded file"},
+	{"import.s3_region", "IMPORT_S3_REGION", "us-east-1", "region of the buckets of the s3:// URLs"},
+	{"import.s3_endpoint", "IMPORT_S3_ENDPOINT", "", "URL of an S3-compatible service such as MinIO (empty uses AWS)"},
+
+	{"export.dir", "EXPORT_DIR", "", "directory the exports of POST /admin/exports are written to (empty disables, unless export.s3.bucket is set)"},
+	{"export.chunk_rows", "EXPORT_CHUNK_ROWS", 100000, "segmentations per gzip-compressed NDJSON file of an export"},
+	{"export.s3.bucket", "EXPORT_S3_BUCKET", "", "S3 bucket the exports are uploaded to, instead of export.dir"},
+	{"export.s3.prefix", "EXPORT_S3_PREFIX", "exports", "prefix of the keys of the exports in the bucket"},
+	{"export.s3.region", "EXPORT_S3_REGION", "us-east-1", "region of the bucket"},
+	{"export.s3.endpoint", "EXPORT_S3_ENDPOINT", "", "URL of an S3-compatible service such as MinIO (empty uses AWS)"},
+	{"export.pseudonym_key", "EXPORT_PSEUDONYM_KEY", "", "secret key of the user pseudonyms of the pseudonymized exports, at least 32 characters (empty disables them)"},
+	{"export.strip_data_keys", "EXPORT_STRIP_DATA_KEYS", []string{}, "comma-separated data keys dropped from the pseudonymized exports"},
+
+	{"warehouse.kind", "WAREHOUSE_KIND", "", "warehouse the changed segmentations are synced to: bigquery or redshift (empty disables)"},
+	{"warehouse.interval", "WAREHOUSE_INTERVAL", 15 * time.Minute, "how often the changes are synced"},
+	{"warehouse.batch_rows", "WAREHOUSE_BATCH_ROWS", 50000, "segmentations per bulk load"},
+	{"warehouse.lag", "WAREHOUSE_LAG", time.Minute, "how long a change waits before it is synced, so slow transactions are not skipped"},
+	{"warehouse.bigquery.project", "WAREHOUSE_BIGQUERY_PROJECT", "", "Google Cloud project of the BigQuery dataset"},
+	{"warehouse.bigquery.dataset", "WAREHOUSE_BIGQUERY_DATASET", "", "BigQuery dataset of the table"},
+	{"warehouse.bigquery.table", "WAREHOUSE_BIGQUERY_TABLE", "segmentations", "BigQuery table the changes are appended to"},
+	{"warehouse.bigquery.location", "WAREHOUSE_BIGQUERY_LOCATION", "", "location of the dataset, when not US or EU"},
+	{"warehouse.bigquery.credentials_file", "GOOGLE_APPLICATION_CREDENTIALS", "", "key file of the service account that runs the load jobs"},
+	{"warehouse.redshift.region", "WAREHOUSE_REDSHIFT_REGION", "us-east-1", "region of the Redshift cluster and of the staging bucket"},
+	{"warehouse.redshift.cluster_id", "WAREHOUSE_REDSHIFT_CLUSTER_ID", "", "provisioned Redshift cluster"},
+	{"warehouse.redshift.workgroup", "WAREHOUSE_REDSHIFT_WORKGROUP", "", "Redshift Serverless workgroup, instead of a cluster"},
+	{"warehouse.redshift.database", "WAREHOUSE_REDSHIFT_DATABASE", "", "Redshift database of the table"},
+	{"warehouse.redshift.db_user", "WAREHOUSE_REDSHIFT_DB_USER", "", "database user of a cluster, authenticated with temporary credentials"},
+	{"warehouse.redshift.secret_arn", "WAREHOUSE_REDSHIFT_SECRET_ARN", "", "Secrets Manager secret holding the database credentials"},
+	{"warehouse.redshift.table", "WAREHOUSE_REDSHIFT_TABLE", "segmentations", "Redshift table the changes are copied to, optionally schema-qualified"},
+	{"warehouse.redshift.iam_role", "WAREHOUSE_REDSHIFT_IAM_ROLE", "", "ARN of the IAM role COPY reads the staging bucket with"},
+	{"warehouse.redshift.staging_bucket", "WAREHOUSE_REDSHIFT_STAGING_BUCKET", "", "S3 bucket the changes are staged in before COPY"},
+	{"warehouse.redshift.staging_prefix", "WAREHOUSE_REDSHIFT_STAGING_PREFIX", "warehouse", "prefix of the staged files in the bucket"},
+
+	{"search.url", "SEARCH_URL", "", "URL of the Elasticsearch or OpenSearch cluster the segmentations are mirrored to (empty disables search; needs outbox.enabled)"},
+	{"search.index", "SEARCH_INDEX", "segmentations", "index of the segmentations, created with its mapping when missing"},
+	{"search.username", "SEARCH_USERNAME", "", "user of basic authentication"},
+	{"search.password", "SEARCH_PASSWORD", "", "password of basic authentication"},
+	{"search.api_key", "SEARCH_API_KEY", "", "encoded API key, instead of basic authentication"},
+	{"search.timeout", "SEARCH_TIMEOUT", 5 * time.Second, "how long a request to the cluster may take"},
+
+	{"kafka.url", "KAFKA_REST_URL", "", "URL of the Confluent REST Proxy the outbox events are published through (empty disables Kafka; needs outbox.enabled)"},
+	{"kafka.topic", "KAFKA_TOPIC", "segmentation-events", "topic of the events, keyed by user"},
+	{"kafka.format", "KAFKA_FORMAT", "json", "format of the values: json or avro"},
+	{"kafka.schema_registry_url", "KAFKA_SCHEMA_REGISTRY_URL", "", "URL of the schema registry of the avro format"},
+	{"kafka.compatibility", "KAFKA_SCHEMA_COMPATIBILITY", "BACKWARD", "compatibility level set on the subjects of the avro schemas (empty leaves the registry's)"},
+	{"kafka.username", "KAFKA_USERNAME", "", "user of basic authentication to the proxy and the registry"},
+	{"kafka.password", "KAFKA_PASSWORD", "", "password of basic authentication to the proxy and the registry"},
+	{"kafka.timeout", "KAFKA_TIMEOUT", 10 * time.Second, "how long a request to the proxy or the registry may take"},
+
+	{"crm.url", "CRM_URL", "", "batch endpoint the contact updates are POSTed to (empty disables the CRM sync; needs outbox.enabled)"},
+	{"crm.format", "CRM_FORMAT", "generic", "body of the requests: generic or hubspot"},
+	{"crm.token", "CRM_TOKEN", "", "bearer token of the requests"},
+	{"crm.id_property", "CRM_ID_PROPERTY", "", "contact property holding the user ID, for the hubspot format"},
+	{"crm.fields", "CRM_FIELDS", "", "contact attribute of each synced segmentation type (type:attribute,...)"},
+	{"crm.batch_size", "CRM_BATCH_SIZE", 100, "contacts updated per request"},
+	{"crm.rate_limit", "CRM_RATE_LIMIT", 5.0, "requests per second at most (0 does not limit them)"},
+	{"crm.max_retries", "CRM_MAX_RETRIES", 5, "retries of a throttled or failed request before the outbox batch is published again later"},
+	{"crm.timeout", "CRM_TIMEOUT", 10 * time.Second, "how long a request to the CRM may take"},
+
+	{"notify.redis_addr", "NOTIFY_REDIS_ADDR", "", "host:port of the Redis server the change notifications are published to (empty disables)"},
+	{"notify.redis_username", "NOTIFY_REDIS_USERNAME", "", "Redis ACL user"},
+	{"notify.redis_password", "NOTIFY_REDIS_PASSWORD", "", "Redis password"},
+	{"notify.redis_db", "NOTIFY_REDIS_DB", 0, "Redis database"},
+	{"notify.redis_tls", "NOTIFY_REDIS_TLS", false, "connect to Redis over TLS"},
+	{"notify.channel_prefix", "NOTIFY_CHANNEL_PREFIX", "segmentations", "prefix of the channels, as in <prefix>:user:<id> and <prefix>:type:<type>"},
+	{"notify.queue_size", "NOTIFY_QUEUE_SIZE", 10000, "changes waiting to be published before new ones are dropped"},
+
+	{"analytics.cache_ttl", "ANALYTICS_CACHE_TTL", 5 * time.Minute, "how long an aggregate of /admin/analytics is served before it is computed again"},
+	{"analytics.snapshot_interval", "ANALYTICS_SNAPSHOT_INTERVAL", time.Hour, "how often the size of each type is recorded for the growth over time (0 disables)"},
+	{"analytics.overlap_sample_threshold", "ANALYTICS_OVERLAP_SAMPLE_THRESHOLD", int64(1000000), "segment size in users above which overlaps are estimated from a sample (0 never samples)"},
+
+	{"aws.access_key_id", "AWS_ACCESS_KEY_ID", "", "AWS access key ID of the S3 exports and archive and the Redshift sync"},
+	{"aws.secret_access_key", "AWS_SECRET_ACCESS_KEY", "", "AWS secret access key"},
+	{"aws.session_token", "AWS_SESSION_TOKEN", "", "AWS session token of temporary credentials"},
+}
+
+// ErrHelp is returned by Load when --help was requested; the usage has
+// already been printed
+var ErrHelp = pflag.ErrHelp
+
+// ConfigFileEnv names the config file when --config is not given
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Flags returns a flag set with a flag per configuration key (e.g.
+// --log.level) plus --config for the YAML or TOML file
+func Flags(name string) *pflag.FlagSet {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.String("config", "", "YAML or TOML config file (also "+ConfigFileEnv+")")
+	for _, s := range settings {
+		switch def := s.def.(type) {
+		case string:
+			fs.String(s.key, def, s.usage+" ("+s.env+")")
+		case bool:
+			fs.Bool(s.key, def, s.usage+" ("+s.env+")")
+		case int:
+			fs.Int(s.key, def, s.usage+" ("+s.env+")")
+		case uint64:
+			fs.Uint64(s.key, def, s.usage+" ("+s.env+")")
+		case float64:
+			fs.Float64(s.key, def, s.usage+" ("+s.env+")")
+		case time.Duration:
+			fs.Duration(s.key, def, s.usage+" ("+s.env+")")
+		case []string:
+			fs.StringSlice(s.key, def, s.usage+" ("+s.env+")")
+		}
+	}
+	return fs
+}
+
+// Load parses args with the flags of Flags and resolves the configuration.
+// The result is not validated; see Validate.
+func Load(name string, args []string) (*Config, error) {
+	fs := Flags(name)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return LoadFlags(fs)
+}
+
+// LoadFlags resolves the configuration from an already parsed flag set
+// created by Flags
+func LoadFlags(fs *pflag.FlagSet) (*Config, error) {
+	v := viper.New()
+	for _, s := range settings {
+		v.SetDefault(s.key, s.def)
+		if err := v.BindEnv(s.key, s.env); err != nil {
+			return nil, err
+		}
+		if f := fs.Lookup(s.key); f != nil {
+			if err := v.BindPFlag(s.key, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	path, _ := fs.GetString("config")
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	applyProfile(v)
+
+	var cfg Config
+	err := v.Unmarshal(&cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		compactMapHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+	if err != nil {
+		return nil, err
+	}
+	cfg.normalize()
+	if err := cfg.readSecretFiles(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// readSecretFiles replaces the secrets given as files by their content
+func (c *Config) readSecretFiles() error {
+	for _, f := range []struct {
+		key   string
+		path  string
+		value *string
+	}{
+		{"db.password", c.DB.PasswordFile, &c.DB.Password},
+		{"vault.token", c.Vault.TokenFile, &c.Vault.Token},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if *f.value != "" {
+			return fmt.Errorf("%s and %s_file are mutually exclusive", f.key, f.key)
+		}
+		secret, err := readSecretFile(f.path)
+		if err != nil {
+			return fmt.Errorf("%s_file: %w", f.key, err)
+		}
+		*f.value = secret
+	}
+	return nil
+}
+
+// readSecretFile reads a secret, dropping the trailing newline most
+// editors and "echo" add
+func readSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+func (c *Config) normalize() {
+	var sinks []string
+	for _, s := range c.Log.Sinks {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			sinks = append(sinks, s)
+		}
+	}
+	c.Log.Sinks = sinks
+	var to []string
+	for _, addr := range c.Processor.Report.To {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	c.Processor.Report.To = to
+	var strip []string
+	for _, k := range c.Export.StripDataKeys {
+		if k = strings.TrimSpace(k); k != "" {
+			strip = append(strip, k)
+		}
+	}
+	c.Export.StripDataKeys = strip
+	c.Processor.Report.SMTPTLS = strings.ToLower(strings.TrimSpace(c.Processor.Report.SMTPTLS))
+	c.Processor.Chat.Kind = strings.ToLower(strings.TrimSpace(c.Processor.Chat.Kind))
+	c.Log.Format = strings.ToLower(strings.TrimSpace(c.Log.Format))
+	c.Log.Level = strings.ToLower(strings.TrimSpace(c.Log.Level))
+	c.Processor.LogMode = strings.ToLower(strings.TrimSpace(c.Processor.LogMode))
+	c.Processor.Mode = strings.ToLower(strings.TrimSpace(c.Processor.Mode))
+	c.API.MaintenanceMode = strings.ToLower(strings.TrimSpace(c.API.MaintenanceMode))
+	c.API.GinMode = strings.ToLower(strings.TrimSpace(c.API.GinMode))
+	c.API.UserLookup = strings.ToLower(strings.TrimSpace(c.API.UserLookup))
+	c.Env = strings.ToLower(strings.TrimSpace(c.Env))
+	c.Validation.Mode = strings.ToLower(strings.TrimSpace(c.Validation.Mode))
+}
+
+// compactMapHook decodes the compact environment form of the validation
+// maps; maps coming from a config file pass through untouched
+func compactMapHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	raw := data.(string)
+
+	switch to {
+	case reflect.TypeOf(map[string]string{}):
+		return parsePairs(raw, func(v string) any { return v })
+	case reflect.TypeOf(map[string][]string{}):
+		return parsePairs(raw, func(v string) any { return strings.Split(v, "|") })
+	}
+	return data, nil
+}
+
+func parsePairs(raw string, value func(string) any) (map[string]any, error) {
+	out := make(map[string]any)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, ":")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("malformed entry %q", pair)
+		}
+		out[k] = value(v)
+	}
+	return out, nil
+}
+
+// Validate checks the values that can be verified before anything is
+// started. requireDB is false for commands that never connect.
+func (c *Config) Validate(requireDB bool) error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if requireDB {
+		check(c.DB.Host != "", "db.host (DB_HOST) is required")
+		check(c.DB.Name != "", "db.name (DB_NAME) is required")
+		check(c.DB.User != "" || c.Vault.Enabled(), "db.user (DB_USER) is required")
+	}
+	if c.Vault.Enabled() {
+		check(c.Vault.Addr != "", "vault.addr (VAULT_ADDR) is required by vault.db_path")
+		check(c.Vault.Token != "", "vault.token (VAULT_TOKEN or VAULT_TOKEN_FILE) is required by vault.db_path")
+	}
+
+	check(c.DB.ConnectTimeout >= 0, "db.connect_timeout must not be negative")
+	check(c.DB.MaxOpenConns >= 0, "db.max_open_conns must not be negative")
+	check(c.DB.MaxIdleConns >= 0 && (c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns),
+		"db.max_idle_conns must be between 0 and db.max_open_conns")
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime must not be negative")
+	check(c.DB.CompressionThreshold >= 0, "db.compression_threshold must not be negative")
+	check(c.DB.BreakerThreshold >= 0, "db.breaker_threshold must not be negative")
+	if c.DB.BreakerThreshold > 0 {
+		check(c.DB.BreakerCooldown > 0 && c.DB.BreakerMaxCooldown >= c.DB.BreakerCooldown,
+			"db.breaker_cooldown must be positive and at most db.breaker_max_cooldown")
+	}
+
+	check(c.Seed.Users > 0, "seed.users must be positive")
+	check(c.Seed.MaxPerUser > 0, "seed.max_per_user must be positive")
+	check(c.Seed.FirstUserID > 0, "seed.first_user_id must be positive")
+
+	check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+	check(c.Outbox.PollInterval > 0, "outbox.poll_interval must be positive")
+	check(c.Outbox.Retention >= 0, "outbox.retention must not be negative")
+	check(!c.Webhooks.Enabled || c.Outbox.Enabled, "webhooks.enabled requires outbox.enabled")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive")
+	check(c.Webhooks.Retention >= 0, "webhooks.retention must not be negative")
+	if c.Import.Enabled() {
+		check(c.Import.MaxBytes > 0, "import.max_bytes must be positive")
+		check(c.Import.S3Region != "", "import.s3_region must not be empty")
+	}
+	check(c.Export.ChunkRows > 0, "export.chunk_rows must be positive")
+	check(c.Export.PseudonymKey == "" || len(c.Export.PseudonymKey) >= 32,
+		"export.pseudonym_key must have at least 32 characters")
+	check(len(c.Export.StripDataKeys) == 0 || c.Export.PseudonymKey != "",
+		"export.pseudonym_key (EXPORT_PSEUDONYM_KEY) is required by export.strip_data_keys")
+	if c.Export.S3.Bucket != "" {
+		check(c.Export.Dir == "", "export.dir and export.s3.bucket are mutually exclusive")
+		check(c.Export.S3.Region != "", "export.s3.region (EXPORT_S3_REGION) is required by export.s3.bucket")
+		check(c.AWS.AccessKeyID != "" && c.AWS.SecretAccessKey != "",
+			"AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required by export.s3.bucket")
+	}
+	check(oneOf(c.Warehouse.Kind, "", "bigquery", "redshift"),
+		"invalid warehouse.kind %q: must be bigquery or redshift", c.Warehouse.Kind)
+	if c.Warehouse.Kind != "" {
+		check(c.Warehouse.Interval > 0, "warehouse.interval must be positive")
+		check(c.Warehouse.BatchRows > 0, "warehouse.batch_rows must be positive")
+		check(c.Warehouse.Lag >= 0, "warehouse.lag must not be negative")
+	}
+	if bq := c.Warehouse.BigQuery; c.Warehouse.Kind == "bigquery" {
+		check(bq.Project != "" && bq.Dataset != "" && bq.Table != "",
+			"warehouse.bigquery.project, dataset and table are required by warehouse.kind bigquery")
+		check(bq.CredentialsFile != "", "GOOGLE_APPLICATION_CREDENTIALS is required by warehouse.kind bigquery")
+	}
+	if rs := c.Warehouse.Redshift; c.Warehouse.Kind == "redshift" {
+		check((rs.ClusterID == "") != (rs.Workgroup == ""),
+			"exactly one of warehouse.redshift.cluster_id and workgroup is required by warehouse.kind redshift")
+		check(rs.Region != "" && rs.Database != "" && rs.Table != "",
+			"warehouse.redshift.region, database and table are required by warehouse.kind redshift")
+		check(rs.IAMRole != "" && rs.StagingBucket != "",
+			"warehouse.redshift.iam_role and staging_bucket are required by warehouse.kind redshift")
+		check(c.AWS.AccessKeyID != "" && c.AWS.SecretAccessKey != "",
+			"AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required by warehouse.kind redshift")
+	}
+	if c.Search.Enabled() {
+		check(c.Outbox.Enabled, "search.url requires outbox.enabled")
+		check(c.Search.Index != "", "search.index must not be empty")
+		check(c.Search.Timeout > 0, "search.timeout must be positive")
+	}
+	if c.Kafka.Enabled() {
+		check(c.Outbox.Enabled, "kafka.url requires outbox.enabled")
+		check(c.Kafka.Topic != "", "kafka.topic must not be empty")
+		check(oneOf(c.Kafka.Format, "json", "avro"), "invalid kafka.format %q: must be json or avro", c.Kafka.Format)
+		check(c.Kafka.Format != "avro" || c.Kafka.SchemaRegistryURL != "", "kafka.schema_registry_url is required by kafka.format avro")
+		check(c.Kafka.Compatibility == "" || oneOf(c.Kafka.Compatibility, "BACKWARD", "BACKWARD_TRANSITIVE", "FORWARD", "FORWARD_TRANSITIVE", "FULL", "FULL_TRANSITIVE", "NONE"),
+			"invalid kafka.compatibility %q", c.Kafka.Compatibility)
+		check(c.Kafka.Timeout > 0, "kafka.timeout must be positive")
+	}
+	if c.CRM.Enabled() {
+		check(c.Outbox.Enabled, "crm.url requires outbox.enabled")
+		check(oneOf(c.CRM.Format, "generic", "hubspot"), "invalid crm.format %q: must be generic or hubspot", c.CRM.Format)
+		check(len(c.CRM.Fields) > 0, "crm.fields must map at least one segmentation type")
+		check(c.CRM.BatchSize > 0, "crm.batch_size must be positive")
+		check(c.CRM.RateLimit >= 0, "crm.rate_limit must not be negative")
+		check(c.CRM.MaxRetries >= 0, "crm.max_retries must not be negative")
+		check(c.CRM.Timeout > 0, "crm.timeout must be positive")
+	}
+	if c.Notify.Enabled() {
+		check(c.Notify.RedisDB >= 0, "notify.redis_db must not be negative")
+		check(c.Notify.ChannelPrefix != "", "notify.channel_prefix must not be empty")
+		check(c.Notify.QueueSize > 0, "notify.queue_size must be positive")
+	}
+	check(c.Analytics.CacheTTL > 0, "analytics.cache_ttl must be positive")
+	check(c.Analytics.SnapshotInterval >= 0, "analytics.snapshot_interval must not be negative")
+	check(c.Analytics.OverlapSampleThreshold >= 0, "analytics.overlap_sample_threshold must not be negative")
+
+	check(oneOf(c.Env, environments...), "invalid env %q: must be dev, staging or prod", c.Env)
+	check(c.API.Port != "", "api.port must not be empty")
+	check(oneOf(c.API.GinMode, "debug", "release", "test"), "invalid api.gin_mode %q", c.API.GinMode)
+	check(c.API.IdempotencyTTL > 0, "api.idempotency_ttl must be positive")
+	check(c.API.DrainGrace >= 0, "api.drain_grace must not be negative")
+	check(c.API.ShutdownTimeout > 0, "api.shutdown_timeout must be positive")
+	check(c.API.CacheSize >= 0, "api.cache_size must not be negative")
+	check(c.API.StreamThreshold >= 0, "api.stream_threshold must not be negative")
+	check(c.API.MaxBodyBytes >= 0, "api.max_body_bytes must not be negative")
+	check(c.API.RequestTimeout >= 0, "api.request_timeout must not be negative")
+	check(c.API.Server.ReadTimeout >= 0 && c.API.Server.ReadHeaderTimeout >= 0 &&
+		c.API.Server.WriteTimeout >= 0 && c.API.Server.IdleTimeout >= 0, "api.server timeouts must not be negative")
+	check(c.API.Server.MaxHeaderBytes > 0, "api.server.max_header_bytes must be positive")
+	check(c.API.WriteQueue.MaxPending >= 0, "api.write_queue.max_pending must not be negative")
+	check(c.API.WriteQueue.DedupTTL >= 0, "api.write_queue.dedup_ttl must not be negative")
+	if c.API.GRPC.Enabled() {
+		check(c.API.GRPC.Port != c.API.Port, "api.grpc.port must differ from api.port")
+		check(c.API.GRPC.BatchSize > 0, "api.grpc.batch_size must be positive")
+		check(c.API.GRPC.MaxMessageBytes > 0, "api.grpc.max_message_bytes must be positive")
+	}
+	check(c.API.CacheSize == 0 || c.API.CacheTTL > 0, "api.cache_ttl must be positive when api.cache_size is set")
+	check(oneOf(c.API.UserLookup, "off", "table", "http"), "invalid api.user_lookup %q: must be off, table or http", c.API.UserLookup)
+	if c.API.UserLookup == "http" {
+		check(strings.Contains(c.API.UserLookupURL, "{user_id}"), "api.user_lookup_url (API_USER_LOOKUP_URL) must contain {user_id} with api.user_lookup http")
+		check(c.API.UserLookupTimeout > 0, "api.user_lookup_timeout must be positive")
+	}
+	check(oneOf(c.API.MaintenanceMode, "off", "read_only", "write_only"), "invalid api.maintenance_mode %q", c.API.MaintenanceMode)
+
+	check(oneOf(c.Log.Format, "text", "console", "json"), "invalid log.format %q", c.Log.Format)
+	check(oneOf(c.Log.Level, "debug", "info", "warn", "error"), "invalid log.level %q", c.Log.Level)
+	for _, s := range c.Log.Sinks {
+		check(oneOf(s, "file", "stdout", "stderr", "syslog", "fluentd"), "invalid log.sinks entry %q", s)
+		if s == "fluentd" {
+			check(c.Log.FluentdAddr != "", "log.fluentd_addr (FLUENTD_ADDR) is required by the fluentd sink")
+		}
+	}
+	check(c.Log.SlowQueryThreshold >= 0, "log.slow_query_threshold must not be negative")
+
+	check(oneOf(c.Processor.Mode, "oneshot", "daemon"), "invalid processor.mode %q", c.Processor.Mode)
+	check(c.Processor.WatchInterval > 0, "processor.watch_interval must be positive")
+	check(c.Processor.Schedule >= 0, "processor.schedule must not be negative")
+	check(oneOf(c.Processor.LogMode, "rows", "aggregate"), "invalid processor.log_mode %q", c.Processor.LogMode)
+	check(c.Processor.LogSample >= 0 && c.Processor.LogSample <= 1, "processor.log_sample must be between 0 and 1")
+	check(c.Processor.ProgressInterval > 0, "processor.progress_interval must be positive")
+	check(c.Processor.BatchSize > 0, "processor.batch_size must be positive")
+	check(c.Processor.FlushInterval > 0, "processor.flush_interval must be positive")
+	check(c.Processor.ReadAhead >= 0, "processor.read_ahead must not be negative")
+	check(c.Processor.Workers >= 0, "processor.workers must not be negative")
+	check(c.Processor.DrainTimeout >= 0, "processor.drain_timeout must not be negative")
+	check(c.Processor.OutageThreshold >= 0 && c.Processor.OutageThreshold <= 1, "processor.outage_threshold must be between 0 and 1")
+	if c.Processor.OutageThreshold > 0 {
+		check(c.Processor.OutageWindow > 0, "processor.outage_window must be positive")
+		check(c.Processor.OutageMaxBackoff >= time.Second, "processor.outage_max_backoff must be at least 1s")
+	}
+	check(c.Processor.OutageMaxPause >= 0, "processor.outage_max_pause must not be negative")
+	check(!c.Processor.InitialLoad || c.Processor.Mode != "daemon", "processor.initial_load cannot be combined with processor.mode daemon")
+	check(c.Processor.StallTimeout > c.Processor.ProgressInterval, "processor.stall_timeout must be longer than processor.progress_interval")
+	check(c.Processor.LeaderWait >= 0, "processor.leader_wait must not be negative")
+	check(c.Processor.Partitions >= 1, "processor.partitions must be at least 1")
+	check(c.Processor.Partition >= -1 && c.Processor.Partition < c.Processor.Partitions,
+		"processor.partition must be -1 or lower than processor.partitions")
+	if c.Processor.Partitions > 1 {
+		check(c.Processor.JobID != "" && len(c.Processor.JobID) <= 36, "processor.job_id (up to 36 characters) is required with processor.partitions")
+		check(!c.Processor.LeaderElection, "processor.leader_election cannot be combined with processor.partitions")
+		check(c.Processor.Mode != "daemon", "processor.mode daemon cannot be combined with processor.partitions")
+		check(!c.Processor.Transactional, "processor.transactional cannot be combined with processor.partitions")
+	}
+	if s := c.Processor.SFTP; s.Enabled() {
+		check(s.Port > 0 && s.Port < 65536, "processor.sftp.port must be between 1 and 65535")
+		check(s.User != "" && s.KeyFile != "", "processor.sftp.user and processor.sftp.key_file are required with processor.sftp.host")
+		check(s.KnownHosts != "", "processor.sftp.known_hosts is required with processor.sftp.host")
+		dir, pattern := path.Split(s.Pattern)
+		_, err := path.Match(pattern, "")
+		check(path.IsAbs(s.Pattern) && pattern != "" && err == nil && !strings.ContainsAny(dir, "*?["),
+			"processor.sftp.pattern must be an absolute path with wildcards only in the file name, got %q", s.Pattern)
+		check(path.IsAbs(s.ArchiveDir) && path.Clean(s.ArchiveDir) != path.Clean(dir),
+			"processor.sftp.archive_dir must be an absolute path other than the directory of processor.sftp.pattern")
+		check(s.FailedDir == "" || path.IsAbs(s.FailedDir), "processor.sftp.failed_dir must be an absolute path")
+		check(s.MinAge >= 0, "processor.sftp.min_age must not be negative")
+		check(s.PollInterval > 0, "processor.sftp.poll_interval must be positive")
+		check(s.MaxAttempts > 0, "processor.sftp.max_attempts must be positive")
+		check(c.Processor.Partitions == 1, "processor.sftp.host cannot be combined with processor.partitions")
+	}
+	if r := c.Processor.Report; r.Enabled() {
+		_, _, err := net.SplitHostPort(r.SMTPAddr)
+		check(err == nil, "processor.report.smtp_addr must be host:port, got %q", r.SMTPAddr)
+		check(oneOf(r.SMTPTLS, "starttls", "tls", "none"), "invalid processor.report.smtp_tls %q: must be starttls, tls or none", r.SMTPTLS)
+		_, err = mail.ParseAddress(r.From)
+		check(err == nil, "processor.report.from must be an email address, got %q", r.From)
+		check(len(r.To) > 0, "processor.report.to is required with processor.report.smtp_addr")
+		for _, to := range r.To {
+			_, err := mail.ParseAddress(to)
+			check(err == nil, "invalid recipient %q in processor.report.to", to)
+		}
+		check(r.AttachMaxBytes >= 0, "processor.report.attach_max_bytes must not be negative")
+		check(r.Timeout > 0, "processor.report.timeout must be positive")
+	}
+	if a := c.Processor.Archive; a.Enabled() {
+		check(a.S3.Region != "", "processor.archive.s3.region (ARCHIVE_S3_REGION) is required by processor.archive.s3.bucket")
+		check(c.AWS.AccessKeyID != "" && c.AWS.SecretAccessKey != "",
+			"AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required by processor.archive.s3.bucket")
+		check(len(a.Tags) <= 10, "processor.archive.tags can hold at most 10 tags, the S3 limit")
+	}
+	if ch := c.Processor.Chat; ch.Enabled() {
+		check(oneOf(ch.Kind, "slack", "teams"), "invalid processor.chat.kind %q: must be slack or teams", ch.Kind)
+		check(ch.Kind != "teams" || ch.Mention == "" || ch.MentionID != "",
+			"processor.chat.mention_id is required by a Teams processor.chat.mention")
+		check(ch.ErrorRate >= 0 && ch.ErrorRate <= 1, "processor.chat.error_rate must be between 0 and 1")
+		check(ch.Timeout > 0, "processor.chat.timeout must be positive")
+	}
+
+	check(oneOf(c.Validation.Mode, "lenient", "strict"), "invalid validation.mode %q", c.Validation.Mode)
+	check(c.Validation.MaxDataBytes >= 0, "validation.max_data_bytes must not be negative")
+	check(c.Validation.MaxDataDepth >= 0 && c.Validation.MaxDataKeys >= 0 && c.Validation.MaxDataStringBytes >= 0,
+		"validation data limits must not be negative")
+
+	return errors.Join(errs...)
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
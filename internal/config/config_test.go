@@ -0,0 +1,568 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.API.Port != "8080" || cfg.API.IdempotencyTTL != 24*time.Hour {
+		t.Errorf("unexpected API defaults: %+v", cfg.API)
+	}
+	if s := cfg.API.Server; s.ReadTimeout != 30*time.Second || s.ReadHeaderTimeout != 10*time.Second ||
+		s.WriteTimeout != time.Minute || s.IdleTimeout != 2*time.Minute || s.MaxHeaderBytes != 1<<20 || !s.KeepAlive || s.H2C {
+		t.Errorf("unexpected API server defaults: %+v", s)
+	}
+	if cfg.DB.Port != "3306" {
+		t.Errorf("db.port = %q, want 3306", cfg.DB.Port)
+	}
+	if cfg.DB.ConnectTimeout != 30*time.Second || cfg.DB.BreakerThreshold != 5 ||
+		cfg.DB.BreakerCooldown != time.Second || cfg.DB.BreakerMaxCooldown != 30*time.Second ||
+		!cfg.DB.MigrateOnStart || cfg.DB.OnlineDDL || cfg.DB.CompressionThreshold != 0 {
+		t.Errorf("unexpected db reconnection defaults: %+v", cfg.DB)
+	}
+	if cfg.Env != "dev" || cfg.API.GinMode != "debug" || !cfg.API.Swagger ||
+		cfg.API.CacheSize != 0 || cfg.API.CacheTTL != 30*time.Second || cfg.API.StreamThreshold != 5000 || cfg.API.RawReads ||
+		cfg.API.UserLookup != "off" || cfg.API.UserLookupURL != "" || cfg.API.UserLookupTimeout != 2*time.Second ||
+		cfg.API.WriteQueue.Dir != "" || cfg.API.WriteQueue.MaxPending != 10000 || cfg.API.WriteQueue.DedupTTL != 0 ||
+		cfg.API.GRPC.Enabled() || cfg.API.GRPC.BatchSize != 500 || cfg.API.GRPC.MaxMessageBytes != 4<<20 ||
+		cfg.DB.MaxOpenConns != 32 || cfg.DB.MaxIdleConns != 32 || cfg.DB.ConnMaxLifetime != 30*time.Second {
+		t.Errorf("unexpected dev profile defaults: env=%q %+v %+v", cfg.Env, cfg.API, cfg.DB)
+	}
+	if cfg.Log.Dir != "./logs" || cfg.Log.Format != "text" || cfg.Log.Level != "info" || len(cfg.Log.Sinks) != 0 {
+		t.Errorf("unexpected log defaults: %+v", cfg.Log)
+	}
+	if cfg.Log.SlowQueryThreshold != time.Second {
+		t.Errorf("log.slow_query_threshold = %s, want 1s", cfg.Log.SlowQueryThreshold)
+	}
+	if cfg.Processor.LogMode != "rows" || cfg.Processor.ProgressInterval != 2*time.Second ||
+		cfg.Processor.HealthAddr != "" || cfg.Processor.StallTimeout != 5*time.Minute ||
+		cfg.Processor.LeaderElection || cfg.Processor.LeaderWait != 0 ||
+		cfg.Processor.Partitions != 1 || cfg.Processor.Partition != -1 ||
+		cfg.Processor.Mode != "oneshot" || cfg.Processor.WatchInterval != 10*time.Second || cfg.Processor.Schedule != 0 ||
+		cfg.Processor.BatchSize != 500 || cfg.Processor.FlushInterval != 200*time.Millisecond ||
+		cfg.Processor.ReadAhead != 0 || cfg.Processor.InitialLoad || cfg.Processor.DrainTimeout != 30*time.Second {
+		t.Errorf("unexpected processor defaults: %+v", cfg.Processor)
+	}
+	if cfg.Validation.Mode != "lenient" || cfg.Validation.MaxDataBytes != 65536 ||
+		cfg.Validation.MaxDataDepth != 16 || cfg.Validation.MaxDataKeys != 1000 || cfg.Validation.MaxDataStringBytes != 8192 ||
+		cfg.Validation.MaxUserID != 1<<53-1 || cfg.Pushgateway.Job != "segmentation_processor" {
+		t.Errorf("unexpected defaults: %+v %+v", cfg.Validation, cfg.Pushgateway)
+	}
+	if o := cfg.Outbox; o.Enabled || o.BatchSize != 100 || o.PollInterval != time.Second || o.Retention != 7*24*time.Hour {
+		t.Errorf("unexpected outbox defaults: %+v", o)
+	}
+	if w := cfg.Webhooks; w.Enabled || w.Timeout != 10*time.Second || w.MaxAttempts != 10 || w.Retention != 7*24*time.Hour {
+		t.Errorf("unexpected webhooks defaults: %+v", w)
+	}
+	if i := cfg.Import; i.Enabled() || i.MaxBytes != 1<<30 || i.S3Region != "us-east-1" || i.S3Endpoint != "" {
+		t.Errorf("unexpected import defaults: %+v", i)
+	}
+	if e := cfg.Export; e.Enabled() || e.ChunkRows != 100000 || e.S3.Prefix != "exports" || e.S3.Region != "us-east-1" {
+		t.Errorf("unexpected export defaults: %+v", e)
+	}
+	if w := cfg.Warehouse; w.Kind != "" || w.Interval != 15*time.Minute || w.BatchRows != 50000 || w.Lag != time.Minute ||
+		w.BigQuery.Table != "segmentations" || w.Redshift.Table != "segmentations" || w.Redshift.StagingPrefix != "warehouse" {
+		t.Errorf("unexpected warehouse defaults: %+v", w)
+	}
+	if s := cfg.Search; s.Enabled() || s.Index != "segmentations" || s.Timeout != 5*time.Second {
+		t.Errorf("unexpected search defaults: %+v", s)
+	}
+	if k := cfg.Kafka; k.Enabled() || k.Topic != "segmentation-events" || k.Format != "json" || k.Compatibility != "BACKWARD" || k.Timeout != 10*time.Second {
+		t.Errorf("unexpected kafka defaults: %+v", k)
+	}
+	if n := cfg.Notify; n.Enabled() || n.ChannelPrefix != "segmentations" || n.QueueSize != 10000 || n.RedisDB != 0 {
+		t.Errorf("unexpected notify defaults: %+v", n)
+	}
+	if a := cfg.Analytics; a.CacheTTL != 5*time.Minute || a.SnapshotInterval != time.Hour || a.OverlapSampleThreshold != 1000000 {
+		t.Errorf("unexpected analytics defaults: %+v", a)
+	}
+	if s := cfg.Processor.SFTP; s.Enabled() || s.Port != 22 || s.MinAge != time.Minute || s.PollInterval != time.Minute || s.MaxAttempts != 3 {
+		t.Errorf("unexpected sftp defaults: %+v", s)
+	}
+	if r := cfg.Processor.Report; r.Enabled() || r.SMTPTLS != "starttls" || len(r.To) != 0 || r.AttachMaxBytes != 5<<20 || r.Timeout != 30*time.Second {
+		t.Errorf("unexpected report defaults: %+v", r)
+	}
+	if ch := cfg.Processor.Chat; ch.Enabled() || ch.Kind != "slack" || ch.ErrorRate != 0.05 || !ch.NotifyStart || ch.Timeout != 10*time.Second {
+		t.Errorf("unexpected chat defaults: %+v", ch)
+	}
+	if a := cfg.Processor.Archive; a.Enabled() || a.S3.Prefix != "archive" || a.S3.Region != "us-east-1" || len(a.Tags) != 0 {
+		t.Errorf("unexpected archive defaults: %+v", a)
+	}
+	if c := cfg.CRM; c.Enabled() || c.Format != "generic" || c.BatchSize != 100 || c.RateLimit != 5 || c.MaxRetries != 5 || c.Timeout != 10*time.Second {
+		t.Errorf("unexpected crm defaults: %+v", c)
+	}
+}
+
+func TestLoad_Env(t *testing.T) {
+	t.Setenv("API_PORT", "9090")
+	t.Setenv("DB_HOST", "mysql")
+	t.Setenv("IDEMPOTENCY_TTL", "1h")
+	t.Setenv("LOG_SINKS", " Stdout,syslog ")
+	t.Setenv("PRINTLOG", "true")
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("PROCESSOR_LOG_SAMPLE", "0.01")
+	t.Setenv("DEPRECATED_TYPES", "medication:drug, especialidade:specialty")
+	t.Setenv("DATA_KEYS", "drug:quantity|dose")
+	t.Setenv("MAX_USER_ID", "4294967295")
+	t.Setenv("CRM_FIELDS", "drug:segment_drugs,specialty:medical_specialty")
+	t.Setenv("REPORT_TO", "data-ops@example.com, vendor@example.com")
+
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.API.Port != "9090" || cfg.DB.Host != "mysql" || cfg.API.IdempotencyTTL != time.Hour {
+		t.Errorf("unexpected values: %+v %+v", cfg.API, cfg.DB)
+	}
+	if !reflect.DeepEqual(cfg.Log.Sinks, []string{"stdout", "syslog"}) || !cfg.Log.PrintLog || cfg.Log.Format != "json" {
+		t.Errorf("unexpected log config: %+v", cfg.Log)
+	}
+	if cfg.Processor.LogSample != 0.01 {
+		t.Errorf("processor.log_sample = %v, want 0.01", cfg.Processor.LogSample)
+	}
+	wantTypes := map[string]string{"medication": "drug", "especialidade": "specialty"}
+	if !reflect.DeepEqual(cfg.Validation.DeprecatedTypes, wantTypes) {
+		t.Errorf("deprecated types = %v, want %v", cfg.Validation.DeprecatedTypes, wantTypes)
+	}
+	if cfg.Validation.MaxUserID != 1<<32-1 {
+		t.Errorf("validation.max_user_id = %d, want %d", cfg.Validation.MaxUserID, uint64(1<<32-1))
+	}
+	wantKeys := map[string][]string{"drug": {"quantity", "dose"}}
+	if !reflect.DeepEqual(cfg.Validation.DataKeys, wantKeys) {
+		t.Errorf("data keys = %v, want %v", cfg.Validation.DataKeys, wantKeys)
+	}
+	wantFields := map[string]string{"drug": "segment_drugs", "specialty": "medical_specialty"}
+	if !reflect.DeepEqual(cfg.CRM.Fields, wantFields) {
+		t.Errorf("crm fields = %v, want %v", cfg.CRM.Fields, wantFields)
+	}
+	if to := cfg.Processor.Report.To; !reflect.DeepEqual(to, []string{"data-ops@example.com", "vendor@example.com"}) {
+		t.Errorf("report recipients = %q", to)
+	}
+}
+
+func TestLoad_MalformedMap(t *testing.T) {
+	t.Setenv("DEPRECATED_TYPES", "missing-colon")
+	if _, err := Load("test", nil); err == nil {
+		t.Error("malformed map should fail")
+	}
+}
+
+func TestLoad_YAMLFile(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+api:
+  port: "7070"
+log:
+  sinks: [stdout]
+  slow_query_threshold: 250ms
+validation:
+  mode: strict
+  deprecated_types:
+    medication: drug
+  data_keys:
+    drug: [quantity, dose]
+`)
+
+	cfg, err := Load("test", []string{"--config", path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.API.Port != "7070" || cfg.Log.SlowQueryThreshold != 250*time.Millisecond {
+		t.Errorf("unexpected values: %+v %+v", cfg.API, cfg.Log)
+	}
+	if !reflect.DeepEqual(cfg.Log.Sinks, []string{"stdout"}) {
+		t.Errorf("log.sinks = %v, want [stdout]", cfg.Log.Sinks)
+	}
+	if cfg.Validation.Mode != "strict" || cfg.Validation.DeprecatedTypes["medication"] != "drug" || len(cfg.Validation.DataKeys["drug"]) != 2 {
+		t.Errorf("unexpected validation config: %+v", cfg.Validation)
+	}
+	if cfg.DB.Port != "3306" {
+		t.Errorf("keys missing from the file should keep their default, db.port = %q", cfg.DB.Port)
+	}
+}
+
+func TestLoad_TOMLFileFromEnv(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[db]
+host = "mysql"
+name = "segmentation"
+`)
+	t.Setenv(ConfigFileEnv, path)
+
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DB.Host != "mysql" || cfg.DB.Name != "segmentation" {
+		t.Errorf("unexpected db config: %+v", cfg.DB)
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	if _, err := Load("test", []string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("a missing config file should fail")
+	}
+}
+
+func TestLoad_Priority(t *testing.T) {
+	path := writeFile(t, "config.yaml", "api:\n  port: \"7070\"\nlog:\n  level: warn\n  format: json\n")
+	t.Setenv("API_PORT", "9090")
+	t.Setenv("LOG_LEVEL", "error")
+
+	cfg, err := Load("test", []string{"--config", path, "--log.level", "debug"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Log.Format != "json" {
+		t.Errorf("file should override defaults, log.format = %q", cfg.Log.Format)
+	}
+	if cfg.API.Port != "9090" {
+		t.Errorf("env should override the file, api.port = %q", cfg.API.Port)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Errorf("flags should override env, log.level = %q", cfg.Log.Level)
+	}
+}
+
+func TestLoad_Profiles(t *testing.T) {
+	t.Setenv("APP_ENV", "Prod")
+	t.Setenv("API_SWAGGER", "true")
+
+	cfg, err := Load("test", []string{"--db.max_open_conns", "100"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Env != "prod" || cfg.API.GinMode != "release" || cfg.Log.Format != "json" ||
+		cfg.Processor.LogMode != "aggregate" || cfg.API.DrainGrace != 15*time.Second || cfg.DB.MaxIdleConns != 32 {
+		t.Errorf("unexpected prod profile: %+v %+v %+v", cfg.API, cfg.Log, cfg.DB)
+	}
+	if !cfg.API.Swagger || cfg.DB.MaxOpenConns != 100 {
+		t.Errorf("env and flags should override the profile, swagger=%v max_open_conns=%d", cfg.API.Swagger, cfg.DB.MaxOpenConns)
+	}
+
+	path := writeFile(t, "config.yaml", "env: staging\nlog:\n  format: text\n")
+	t.Setenv("APP_ENV", "")
+	cfg, err = Load("test", []string{"--config", path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Env != "staging" || cfg.API.GinMode != "release" || !cfg.API.Swagger {
+		t.Errorf("env from the config file should select the staging profile: env=%q %+v", cfg.Env, cfg.API)
+	}
+	if cfg.Log.Format != "text" {
+		t.Errorf("the file should override the profile, log.format = %q", cfg.Log.Format)
+	}
+}
+
+func TestLoad_UnknownFlag(t *testing.T) {
+	if _, err := Load("test", []string{"--nope"}); err == nil {
+		t.Error("an unknown flag should fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg, err := Load("test", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.DB = DB{Host: "mysql", Port: "3306", Name: "segmentation", User: "root"}
+		return cfg
+	}
+
+	if err := valid().Validate(true); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	noDB := valid()
+	noDB.DB = DB{}
+	if err := noDB.Validate(false); err != nil {
+		t.Errorf("Validate(false) should not require the database, got %v", err)
+	}
+	if err := noDB.Validate(true); err == nil || !strings.Contains(err.Error(), "db.host") {
+		t.Errorf("Validate(true) error = %v, want a db.host error", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{name: "user lookup", mutate: func(c *Config) { c.API.UserLookup = "ldap" }, want: "api.user_lookup"},
+		{name: "user lookup url", mutate: func(c *Config) { c.API.UserLookup, c.API.UserLookupURL = "http", "http://users/users" }, want: "api.user_lookup_url"},
+		{name: "format", mutate: func(c *Config) { c.Log.Format = "xml" }, want: "log.format"},
+		{name: "level", mutate: func(c *Config) { c.Log.Level = "loud" }, want: "log.level"},
+		{name: "sink", mutate: func(c *Config) { c.Log.Sinks = []string{"kafka"} }, want: "log.sinks"},
+		{name: "fluentd", mutate: func(c *Config) { c.Log.Sinks = []string{"fluentd"} }, want: "log.fluentd_addr"},
+		{name: "mode", mutate: func(c *Config) { c.Processor.Mode = "forever" }, want: "processor.mode"},
+		{name: "watch interval", mutate: func(c *Config) { c.Processor.WatchInterval = 0 }, want: "processor.watch_interval"},
+		{name: "log mode", mutate: func(c *Config) { c.Processor.LogMode = "silent" }, want: "processor.log_mode"},
+		{name: "sample", mutate: func(c *Config) { c.Processor.LogSample = 2 }, want: "processor.log_sample"},
+		{name: "stall timeout", mutate: func(c *Config) { c.Processor.StallTimeout = time.Second }, want: "processor.stall_timeout"},
+		{name: "leader wait", mutate: func(c *Config) { c.Processor.LeaderWait = -time.Second }, want: "processor.leader_wait"},
+		{name: "batch size", mutate: func(c *Config) { c.Processor.BatchSize = 0 }, want: "processor.batch_size"},
+		{name: "flush interval", mutate: func(c *Config) { c.Processor.FlushInterval = 0 }, want: "processor.flush_interval"},
+		{name: "read ahead", mutate: func(c *Config) { c.Processor.ReadAhead = -1 }, want: "processor.read_ahead"},
+		{name: "drain timeout", mutate: func(c *Config) { c.Processor.DrainTimeout = -time.Second }, want: "processor.drain_timeout"},
+		{name: "outage threshold", mutate: func(c *Config) { c.Processor.OutageThreshold = 2 }, want: "processor.outage_threshold"},
+		{name: "outage max backoff", mutate: func(c *Config) { c.Processor.OutageMaxBackoff = time.Millisecond }, want: "processor.outage_max_backoff"},
+		{name: "initial load daemon", mutate: func(c *Config) { c.Processor.InitialLoad, c.Processor.Mode = true, "daemon" }, want: "processor.initial_load"},
+		{name: "partitions", mutate: func(c *Config) { c.Processor.Partitions = 0 }, want: "processor.partitions"},
+		{name: "partition", mutate: func(c *Config) { c.Processor.Partition = 1 }, want: "processor.partition must"},
+		{name: "job id", mutate: func(c *Config) { c.Processor.Partitions = 2 }, want: "processor.job_id"},
+		{name: "partitioned leader", mutate: func(c *Config) {
+			c.Processor.Partitions, c.Processor.JobID, c.Processor.LeaderElection = 2, "nightly", true
+		}, want: "processor.leader_election"},
+		{name: "partitioned daemon", mutate: func(c *Config) {
+			c.Processor.Partitions, c.Processor.JobID, c.Processor.Mode = 2, "nightly", "daemon"
+		}, want: "processor.mode"},
+		{name: "validation", mutate: func(c *Config) { c.Validation.Mode = "paranoid" }, want: "validation.mode"},
+		{name: "max data bytes", mutate: func(c *Config) { c.Validation.MaxDataBytes = -1 }, want: "validation.max_data_bytes"},
+		{name: "max data depth", mutate: func(c *Config) { c.Validation.MaxDataDepth = -1 }, want: "validation data limits"},
+		{name: "drain grace", mutate: func(c *Config) { c.API.DrainGrace = -time.Second }, want: "api.drain_grace"},
+		{name: "shutdown timeout", mutate: func(c *Config) { c.API.ShutdownTimeout = 0 }, want: "api.shutdown_timeout"},
+		{name: "server timeout", mutate: func(c *Config) { c.API.Server.WriteTimeout = -time.Second }, want: "api.server timeouts"},
+		{name: "max header bytes", mutate: func(c *Config) { c.API.Server.MaxHeaderBytes = 0 }, want: "api.server.max_header_bytes"},
+		{name: "env", mutate: func(c *Config) { c.Env = "qa" }, want: "invalid env"},
+		{name: "gin mode", mutate: func(c *Config) { c.API.GinMode = "verbose" }, want: "api.gin_mode"},
+		{name: "max idle conns", mutate: func(c *Config) { c.DB.MaxOpenConns, c.DB.MaxIdleConns = 8, 16 }, want: "db.max_idle_conns"},
+		{name: "request timeout", mutate: func(c *Config) { c.API.RequestTimeout = -time.Second }, want: "api.request_timeout"},
+		{name: "max body bytes", mutate: func(c *Config) { c.API.MaxBodyBytes = -1 }, want: "api.max_body_bytes"},
+		{name: "cache size", mutate: func(c *Config) { c.API.CacheSize = -1 }, want: "api.cache_size"},
+		{name: "write queue max pending", mutate: func(c *Config) { c.API.WriteQueue.MaxPending = -1 }, want: "api.write_queue.max_pending"},
+		{name: "write queue dedup ttl", mutate: func(c *Config) { c.API.WriteQueue.DedupTTL = -time.Second }, want: "api.write_queue.dedup_ttl"},
+		{name: "grpc port", mutate: func(c *Config) { c.API.GRPC.Port = c.API.Port }, want: "api.grpc.port"},
+		{name: "grpc batch size", mutate: func(c *Config) { c.API.GRPC.Port, c.API.GRPC.BatchSize = "9000", 0 }, want: "api.grpc.batch_size"},
+		{name: "stream threshold", mutate: func(c *Config) { c.API.StreamThreshold = -1 }, want: "api.stream_threshold"},
+		{name: "cache ttl", mutate: func(c *Config) { c.API.CacheSize, c.API.CacheTTL = 100, 0 }, want: "api.cache_ttl"},
+		{name: "maintenance", mutate: func(c *Config) { c.API.MaintenanceMode = "readonly" }, want: "api.maintenance_mode"},
+		{name: "ttl", mutate: func(c *Config) { c.API.IdempotencyTTL = 0 }, want: "api.idempotency_ttl"},
+		{name: "connect timeout", mutate: func(c *Config) { c.DB.ConnectTimeout = -time.Second }, want: "db.connect_timeout"},
+		{name: "compression threshold", mutate: func(c *Config) { c.DB.CompressionThreshold = -1 }, want: "db.compression_threshold"},
+		{name: "seed users", mutate: func(c *Config) { c.Seed.Users = 0 }, want: "seed.users"},
+		{name: "seed user id", mutate: func(c *Config) { c.Seed.FirstUserID = 0 }, want: "seed.first_user_id"},
+		{name: "outbox batch size", mutate: func(c *Config) { c.Outbox.BatchSize = 0 }, want: "outbox.batch_size"},
+		{name: "outbox poll interval", mutate: func(c *Config) { c.Outbox.PollInterval = 0 }, want: "outbox.poll_interval"},
+		{name: "outbox retention", mutate: func(c *Config) { c.Outbox.Retention = -time.Hour }, want: "outbox.retention"},
+		{name: "webhooks without outbox", mutate: func(c *Config) { c.Webhooks.Enabled = true }, want: "requires outbox.enabled"},
+		{name: "webhooks timeout", mutate: func(c *Config) { c.Webhooks.Timeout = 0 }, want: "webhooks.timeout"},
+		{name: "webhooks max attempts", mutate: func(c *Config) { c.Webhooks.MaxAttempts = 0 }, want: "webhooks.max_attempts"},
+		{name: "import max bytes", mutate: func(c *Config) { c.Import.Dir, c.Import.MaxBytes = "/data/imports", 0 }, want: "import.max_bytes"},
+		{name: "export chunk rows", mutate: func(c *Config) { c.Export.ChunkRows = 0 }, want: "export.chunk_rows"},
+		{name: "export two destinations", mutate: func(c *Config) {
+			c.Export.Dir, c.Export.S3.Bucket = "/data/exports", "b"
+			c.AWS = AWS{AccessKeyID: "a", SecretAccessKey: "s"}
+		}, want: "mutually exclusive"},
+		{name: "export s3 credentials", mutate: func(c *Config) { c.Export.S3.Bucket = "warehouse" }, want: "AWS_ACCESS_KEY_ID"},
+		{name: "export short pseudonym key", mutate: func(c *Config) { c.Export.PseudonymKey = "short" }, want: "export.pseudonym_key"},
+		{name: "export strip keys without pseudonyms", mutate: func(c *Config) { c.Export.StripDataKeys = []string{"crm"} }, want: "EXPORT_PSEUDONYM_KEY"},
+		{name: "warehouse kind", mutate: func(c *Config) { c.Warehouse.Kind = "snowflake" }, want: "warehouse.kind"},
+		{name: "warehouse batch rows", mutate: func(c *Config) {
+			c.Warehouse.Kind, c.Warehouse.BatchRows = "bigquery", 0
+		}, want: "warehouse.batch_rows"},
+		{name: "warehouse bigquery", mutate: func(c *Config) { c.Warehouse.Kind = "bigquery" }, want: "GOOGLE_APPLICATION_CREDENTIALS"},
+		{name: "warehouse redshift target", mutate: func(c *Config) {
+			c.Warehouse.Kind = "redshift"
+			c.Warehouse.Redshift.ClusterID, c.Warehouse.Redshift.Workgroup = "analytics", "analytics"
+		}, want: "cluster_id and workgroup"},
+		{name: "warehouse redshift staging", mutate: func(c *Config) { c.Warehouse.Kind = "redshift" }, want: "staging_bucket"},
+		{name: "search without outbox", mutate: func(c *Config) { c.Search.URL = "http://es:9200" }, want: "requires outbox.enabled"},
+		{name: "search timeout", mutate: func(c *Config) {
+			c.Search.URL, c.Outbox.Enabled, c.Search.Timeout = "http://es:9200", true, 0
+		}, want: "search.timeout"},
+		{name: "kafka without outbox", mutate: func(c *Config) { c.Kafka.URL = "http://rest-proxy:8082" }, want: "kafka.url requires outbox.enabled"},
+		{name: "kafka avro without registry", mutate: func(c *Config) {
+			c.Kafka.URL, c.Outbox.Enabled, c.Kafka.Format = "http://rest-proxy:8082", true, "avro"
+		}, want: "kafka.schema_registry_url"},
+		{name: "kafka compatibility", mutate: func(c *Config) {
+			c.Kafka.URL, c.Outbox.Enabled, c.Kafka.Compatibility = "http://rest-proxy:8082", true, "backward"
+		}, want: "kafka.compatibility"},
+		{name: "crm without outbox", mutate: func(c *Config) {
+			c.CRM.URL, c.CRM.Fields = "https://crm.example/contacts", map[string]string{"drug": "segment_drugs"}
+		}, want: "crm.url requires outbox.enabled"},
+		{name: "crm format", mutate: func(c *Config) {
+			c.CRM.URL, c.Outbox.Enabled, c.CRM.Format = "https://crm.example/contacts", true, "salesforce"
+			c.CRM.Fields = map[string]string{"drug": "segment_drugs"}
+		}, want: "crm.format"},
+		{name: "crm fields", mutate: func(c *Config) {
+			c.CRM.URL, c.Outbox.Enabled = "https://crm.example/contacts", true
+		}, want: "crm.fields"},
+		{name: "report recipients", mutate: func(c *Config) {
+			c.Processor.Report.SMTPAddr, c.Processor.Report.From = "smtp:587", "reports@example.com"
+		}, want: "processor.report.to"},
+		{name: "report sender", mutate: func(c *Config) {
+			c.Processor.Report.SMTPAddr, c.Processor.Report.To = "smtp:587", []string{"ops@example.com"}
+		}, want: "processor.report.from"},
+		{name: "report smtp addr", mutate: func(c *Config) {
+			c.Processor.Report.SMTPAddr, c.Processor.Report.From = "smtp", "reports@example.com"
+			c.Processor.Report.To = []string{"ops@example.com"}
+		}, want: "processor.report.smtp_addr"},
+		{name: "archive credentials", mutate: func(c *Config) { c.Processor.Archive.S3.Bucket = "ingest-archive" }, want: "required by processor.archive.s3.bucket"},
+		{name: "chat kind", mutate: func(c *Config) {
+			c.Processor.Chat.WebhookURL, c.Processor.Chat.Kind = "https://hooks.slack.com/services/T/B/x", "discord"
+		}, want: "processor.chat.kind"},
+		{name: "chat teams mention", mutate: func(c *Config) {
+			c.Processor.Chat.WebhookURL, c.Processor.Chat.Kind = "https://example.webhook.office.com/x", "teams"
+			c.Processor.Chat.Mention = "Data On-call"
+		}, want: "processor.chat.mention_id"},
+		{name: "chat error rate", mutate: func(c *Config) {
+			c.Processor.Chat.WebhookURL, c.Processor.Chat.ErrorRate = "https://hooks.slack.com/services/T/B/x", 5
+		}, want: "processor.chat.error_rate"},
+		{name: "notify queue size", mutate: func(c *Config) {
+			c.Notify.RedisAddr, c.Notify.QueueSize = "redis:6379", 0
+		}, want: "notify.queue_size"},
+		{name: "notify channel prefix", mutate: func(c *Config) {
+			c.Notify.RedisAddr, c.Notify.ChannelPrefix = "redis:6379", ""
+		}, want: "notify.channel_prefix"},
+		{name: "analytics cache ttl", mutate: func(c *Config) { c.Analytics.CacheTTL = 0 }, want: "analytics.cache_ttl"},
+		{name: "analytics snapshot interval", mutate: func(c *Config) { c.Analytics.SnapshotInterval = -time.Hour }, want: "analytics.snapshot_interval"},
+		{name: "analytics overlap sample threshold", mutate: func(c *Config) { c.Analytics.OverlapSampleThreshold = -1 }, want: "analytics.overlap_sample_threshold"},
+		{name: "sftp key", mutate: func(c *Config) {
+			c.Processor.SFTP = SFTP{Host: "sftp.vendor.example", Port: 22, PollInterval: time.Minute, MaxAttempts: 3}
+		}, want: "processor.sftp.key_file"},
+		{name: "sftp pattern", mutate: func(c *Config) {
+			c.Processor.SFTP = SFTP{Host: "sftp.vendor.example", Port: 22, User: "u", KeyFile: "k", KnownHosts: "h",
+				Pattern: "/inbox/*/data.csv", ArchiveDir: "/archive", PollInterval: time.Minute, MaxAttempts: 3}
+		}, want: "processor.sftp.pattern"},
+		{name: "sftp archive in inbox", mutate: func(c *Config) {
+			c.Processor.SFTP = SFTP{Host: "sftp.vendor.example", Port: 22, User: "u", KeyFile: "k", KnownHosts: "h",
+				Pattern: "/inbox/*.csv", ArchiveDir: "/inbox/", PollInterval: time.Minute, MaxAttempts: 3}
+		}, want: "processor.sftp.archive_dir"},
+		{name: "threshold", mutate: func(c *Config) { c.DB.BreakerThreshold = -1 }, want: "db.breaker_threshold"},
+		{name: "cooldown", mutate: func(c *Config) {
+			c.DB.BreakerThreshold, c.DB.BreakerCooldown, c.DB.BreakerMaxCooldown = 5, time.Minute, time.Second
+		}, want: "db.breaker_cooldown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.mutate(cfg)
+			if err := cfg.Validate(true); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want it to mention %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDump_RedactsSecrets(t *testing.T) {
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DB.Password = "s3cret"
+	cfg.API.AdminToken = "t0ken"
+	cfg.Sentry.DSN = "https://key@sentry.example/1"
+	cfg.Vault.Token = "hvs.vault"
+	cfg.AWS.SecretAccessKey = "aws-s3cret-key"
+	cfg.Search.APIKey = "es-api-key"
+	cfg.Kafka.Password = "kafka-pa55"
+	cfg.Notify.RedisPassword = "redis-pa55"
+	cfg.Processor.SFTP.KeyPassphrase = "key-pa55phrase"
+	cfg.CRM.Token = "crm-t0ken"
+	cfg.Processor.Report.SMTPPassword = "smtp-pa55"
+	cfg.Processor.Chat.WebhookURL = "https://hooks.slack.com/services/T0/B0/webhook-s3cret"
+	cfg.Export.PseudonymKey = "pseudonym-key-0123456789abcdef0123"
+
+	var buf bytes.Buffer
+	if err := Dump(&buf, cfg); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, secret := range []string{"s3cret", "t0ken", "key@sentry", "hvs.vault", "aws-s3cret-key", "es-api-key", "kafka-pa55", "redis-pa55", "key-pa55phrase", "crm-t0ken", "smtp-pa55", "webhook-s3cret", "pseudonym-key"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("dump should not contain %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "password: "+redacted) {
+		t.Errorf("dump should mask the password:\n%s", out)
+	}
+	if cfg.DB.Password != "s3cret" {
+		t.Error("Dump() should not modify the configuration")
+	}
+
+	// the dump is a valid config file
+	path := writeFile(t, "dump.yaml", out)
+	reloaded, err := Load("test", []string{"--config", path})
+	if err != nil {
+		t.Fatalf("reloading the dump: %v", err)
+	}
+	if reloaded.API.IdempotencyTTL != cfg.API.IdempotencyTTL || reloaded.Log.Dir != cfg.Log.Dir {
+		t.Errorf("reloaded config differs: %+v", reloaded)
+	}
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "s3cret\n"))
+	t.Setenv("VAULT_TOKEN_FILE", writeFile(t, "vault_token", "hvs.token"))
+
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DB.Password != "s3cret" {
+		t.Errorf("db.password = %q, want the file content without the newline", cfg.DB.Password)
+	}
+	if cfg.Vault.Token != "hvs.token" {
+		t.Errorf("vault.token = %q", cfg.Vault.Token)
+	}
+}
+
+func TestLoad_SecretFileErrors(t *testing.T) {
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := Load("test", nil); err == nil {
+		t.Error("a missing secret file should fail")
+	}
+
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "s3cret"))
+	t.Setenv("DB_PASSWORD", "other")
+	if _, err := Load("test", nil); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("Load() error = %v, want mutually exclusive", err)
+	}
+}
+
+func TestValidate_Vault(t *testing.T) {
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DB = DB{Host: "mysql", Port: "3306", Name: "segmentation"}
+	cfg.Vault = Vault{DBPath: "database/creds/app"}
+
+	err = cfg.Validate(true)
+	if err == nil || !strings.Contains(err.Error(), "vault.addr") || !strings.Contains(err.Error(), "vault.token") {
+		t.Errorf("Validate() error = %v, want vault.addr and vault.token errors", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "db.user") {
+		t.Errorf("db.user should not be required with Vault: %v", err)
+	}
+
+	cfg.Vault.Addr, cfg.Vault.Token = "http://vault:8200", "hvs.token"
+	if err := cfg.Validate(true); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
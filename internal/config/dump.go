@@ -0,0 +1,46 @@
+package config
+
+import (
+	"io"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const redacted = "<redacted>"
+
+// Redacted returns a copy of c with secrets masked
+func (c Config) Redacted() Config {
+	mask := func(s *string) {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	mask(&c.DB.Password)
+	mask(&c.API.AdminToken)
+	mask(&c.Sentry.DSN)
+	mask(&c.Vault.Token)
+	mask(&c.Search.Password)
+	mask(&c.Search.APIKey)
+	mask(&c.Kafka.Password)
+	mask(&c.CRM.Token)
+	mask(&c.Processor.Report.SMTPPassword)
+	// the path of an incoming webhook URL is its credential
+	mask(&c.Processor.Chat.WebhookURL)
+	mask(&c.Notify.RedisPassword)
+	mask(&c.Processor.SFTP.KeyPassphrase)
+	mask(&c.AWS.SecretAccessKey)
+	mask(&c.AWS.SessionToken)
+	mask(&c.Export.PseudonymKey)
+	return c
+}
+
+// Dump writes the configuration as YAML with secrets masked; the output
+// can be used as a config file once the secrets are filled in
+func Dump(w io.Writer, c *Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
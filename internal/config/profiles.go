@@ -0,0 +1,41 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Environments accepted by env (APP_ENV)
+var environments = []string{"dev", "staging", "prod"}
+
+// profiles change the defaults of some settings per environment; the
+// config file, the environment and flags still override them. dev keeps
+// the defaults of settings.
+var profiles = map[string]map[string]any{
+	"dev": {},
+	"staging": {
+		"api.gin_mode": "release",
+		"log.format":   "json",
+	},
+	"prod": {
+		"api.gin_mode":       "release",
+		"api.swagger":        false,
+		"api.drain_grace":    15 * time.Second,
+		"log.format":         "json",
+		"processor.log_mode": "aggregate",
+		"db.max_open_conns":  64,
+		"db.max_idle_conns":  32,
+	},
+}
+
+// applyProfile sets the defaults of the profile selected by env, once the
+// config file is read so that env can also come from it. An unknown
+// environment changes nothing and is reported by Validate.
+func applyProfile(v *viper.Viper) {
+	env := strings.ToLower(strings.TrimSpace(v.GetString("env")))
+	for key, value := range profiles[env] {
+		v.SetDefault(key, value)
+	}
+}
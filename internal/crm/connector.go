@@ -0,0 +1,331 @@
+// Package crm keeps the contacts of a CRM or marketing platform in step
+// with the segmentations of their users. It is an outbox publisher: each
+// batch of change events is turned into contact updates, one per user,
+// that set an attribute per mapped segmentation type to the names the
+// user currently has of that type:
+//
+//	drug:segment_drugs   →   segment_drugs = ["Aspirina", "Dipirona"]
+//
+// The values are read from the table when the batch is published rather
+// than taken from the events, so a repeated or reordered event sends the
+// same update and a delete empties the attribute. Updates are sent in
+// batches, at a bounded rate, and retried on throttling and server
+// errors; contacts the CRM refuses are logged and skipped so they do not
+// hold up the outbox.
+package crm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+)
+
+// Formats of the batch requests
+const (
+	// FormatGeneric POSTs {"contacts": [{"id": "123", "attributes":
+	// {"segment_drugs": ["Aspirina"]}}]}
+	FormatGeneric = "generic"
+	// FormatHubSpot POSTs the body of HubSpot's contacts batch upsert,
+	// {"inputs": [{"idProperty": ..., "id": "123", "properties":
+	// {"segment_drugs": "Aspirina;Dipirona"}}]}, multiple values joined
+	// with ";" as its multiple checkbox properties expect
+	FormatHubSpot = "hubspot"
+)
+
+const (
+	// DefaultBatchSize is how many contacts go in one request
+	DefaultBatchSize = 100
+	// retryBase is the wait after the first failed request, doubled after
+	// each one up to retryMax, unless the CRM sends Retry-After
+	retryBase = time.Second
+	retryMax  = time.Minute
+	// maxErrorBytes is how much of a refusal's body is kept as its error
+	maxErrorBytes = 512
+	userAgent     = "segmentation-api-crm"
+)
+
+// Segmentations reads the segmentations of a user
+type Segmentations interface {
+	FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error)
+}
+
+// Config locates the batch endpoint of the CRM. Fields maps segmentation
+// types to contact attributes; events of other types are ignored.
+// IDProperty is the HubSpot property holding the user ID.
+type Config struct {
+	URL        string
+	Format     string
+	Token      string
+	IDProperty string
+	Fields     map[string]string
+}
+
+// Connector is the outbox.Publisher of the CRM
+type Connector struct {
+	cfg        Config
+	segs       Segmentations
+	client     *http.Client
+	batchSize  int
+	maxRetries int
+	limiter    *limiter
+	logger     *zap.Logger
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// Option customizes a Connector
+type Option func(*Connector)
+
+// WithTimeout bounds each request (default 10s)
+func WithTimeout(d time.Duration) Option {
+	return func(c *Connector) {
+		if d > 0 {
+			c.client.Timeout = d
+		}
+	}
+}
+
+// WithBatchSize sets how many contacts go in one request
+// (DefaultBatchSize by default)
+func WithBatchSize(n int) Option {
+	return func(c *Connector) {
+		if n > 0 {
+			c.batchSize = n
+		}
+	}
+}
+
+// WithRateLimit spaces the requests so at most perSecond are sent each
+// second; 0 (the default) does not limit them
+func WithRateLimit(perSecond float64) Option {
+	return func(c *Connector) {
+		c.limiter = newLimiter(perSecond)
+	}
+}
+
+// WithMaxRetries sets how many times a throttled or failed request is
+// retried before the batch fails (default 5); the outbox publishes a
+// failed batch again later
+func WithMaxRetries(n int) Option {
+	return func(c *Connector) {
+		c.maxRetries = max(0, n)
+	}
+}
+
+// WithLogger sets the logger of the refused contacts and the retries
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *Connector) {
+		c.logger = logger
+	}
+}
+
+// NewConnector creates a connector updating the CRM of cfg from segs
+func NewConnector(cfg Config, segs Segmentations, opts ...Option) (*Connector, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("crm: URL is required")
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatGeneric
+	}
+	if cfg.Format != FormatGeneric && cfg.Format != FormatHubSpot {
+		return nil, fmt.Errorf("crm: unknown format %q", cfg.Format)
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, errors.New("crm: no field mapping")
+	}
+	c := &Connector{
+		cfg:        cfg,
+		segs:       segs,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		batchSize:  DefaultBatchSize,
+		maxRetries: 5,
+		limiter:    newLimiter(0),
+		logger:     zap.NewNop(),
+		sleep:      sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// update is the update of a CRM contact: the attributes of the mapped
+// types touched by the events, each set to the sorted names the user has
+// of that type (empty when none is left)
+type update struct {
+	UserID     uint64
+	Attributes map[string][]string
+}
+
+// Publish updates the contacts of the users in events
+func (c *Connector) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	contacts, err := c.updates(ctx, events)
+	if err != nil {
+		return err
+	}
+	for batch := range slices.Chunk(contacts, c.batchSize) {
+		if err := c.send(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updates builds the contact updates of events, in the order their users
+// first appear
+func (c *Connector) updates(ctx context.Context, events []models.OutboxEvent) ([]update, error) {
+	var users []uint64
+	touched := map[uint64]map[string]bool{} // user → mapped types
+	for _, e := range events {
+		if _, ok := c.cfg.Fields[e.SegmentationType]; !ok {
+			continue
+		}
+		if touched[e.UserID] == nil {
+			touched[e.UserID] = map[string]bool{}
+			users = append(users, e.UserID)
+		}
+		touched[e.UserID][e.SegmentationType] = true
+	}
+
+	contacts := make([]update, 0, len(users))
+	for _, userID := range users {
+		segs, err := c.segs.FindByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		attrs := map[string][]string{}
+		for segType := range touched[userID] {
+			attrs[c.cfg.Fields[segType]] = []string{}
+		}
+		for _, s := range segs {
+			if touched[userID][s.SegmentationType] {
+				field := c.cfg.Fields[s.SegmentationType]
+				attrs[field] = append(attrs[field], s.SegmentationName)
+			}
+		}
+		for _, names := range attrs {
+			slices.Sort(names)
+		}
+		contacts = append(contacts, update{UserID: userID, Attributes: attrs})
+	}
+	return contacts, nil
+}
+
+// body encodes a batch in the format of the CRM
+func (c *Connector) body(batch []update) ([]byte, error) {
+	if c.cfg.Format == FormatHubSpot {
+		type input struct {
+			IDProperty string            `json:"idProperty,omitempty"`
+			ID         string            `json:"id"`
+			Properties map[string]string `json:"properties"`
+		}
+		inputs := make([]input, len(batch))
+		for i, contact := range batch {
+			props := make(map[string]string, len(contact.Attributes))
+			for field, names := range contact.Attributes {
+				props[field] = strings.Join(names, ";")
+			}
+			inputs[i] = input{IDProperty: c.cfg.IDProperty, ID: strconv.FormatUint(contact.UserID, 10), Properties: props}
+		}
+		return json.Marshal(map[string]any{"inputs": inputs})
+	}
+
+	type contact struct {
+		ID         string              `json:"id"`
+		Attributes map[string][]string `json:"attributes"`
+	}
+	contacts := make([]contact, len(batch))
+	for i, ct := range batch {
+		contacts[i] = contact{ID: strconv.FormatUint(ct.UserID, 10), Attributes: ct.Attributes}
+	}
+	return json.Marshal(map[string]any{"contacts": contacts})
+}
+
+// send POSTs a batch, retrying throttled and failed requests. A batch the
+// CRM refuses (a 4xx other than 408 and 429) is logged and skipped.
+func (c *Connector) send(ctx context.Context, batch []update) error {
+	body, err := c.body(batch)
+	if err != nil {
+		return err
+	}
+	wait := retryBase
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+		status, retryAfter, err := c.post(ctx, body)
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests:
+			users := make([]uint64, len(batch))
+			for i, contact := range batch {
+				users[i] = contact.UserID
+			}
+			c.logger.Error("crm_contacts_refused", zap.Int("status", status), zap.Uint64s("user_ids", users), zap.Error(err))
+			return nil
+		case attempt >= c.maxRetries:
+			return fmt.Errorf("crm: %d contacts not sent after %d attempts: %w", len(batch), attempt+1, err)
+		}
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		c.logger.Warn("crm_request_retry", zap.Int("attempt", attempt+1), zap.Duration("wait", wait), zap.Error(err))
+		if err := c.sleep(ctx, wait); err != nil {
+			return err
+		}
+		wait = min(2*wait, retryMax)
+	}
+}
+
+// post sends one request and returns its status and Retry-After; a
+// response other than 2xx is an error
+func (c *Connector) post(ctx context.Context, body []byte) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, 0, nil
+	}
+	var retryAfter time.Duration
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		retryAfter = min(time.Duration(secs)*time.Second, retryMax)
+	}
+	return resp.StatusCode, retryAfter, fmt.Errorf("crm answered %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
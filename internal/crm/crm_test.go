@@ -0,0 +1,211 @@
+package crm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+)
+
+// memorySegmentations holds the segmentations of each user
+type memorySegmentations map[uint64][]models.Segmentation
+
+func (m memorySegmentations) FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+	return m[userID], nil
+}
+
+// fakeCRM records the requests and answers them with the statuses in
+// replies, then 200
+type fakeCRM struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	replies  []int
+	requests []recorded
+}
+
+type recorded struct {
+	auth string
+	body map[string]any
+}
+
+func newFakeCRM(t *testing.T, replies ...int) *fakeCRM {
+	f := &fakeCRM{replies: replies}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		json.Unmarshal(raw, &body)
+		f.mu.Lock()
+		f.requests = append(f.requests, recorded{auth: r.Header.Get("Authorization"), body: body})
+		status := http.StatusOK
+		if len(f.replies) > 0 {
+			status, f.replies = f.replies[0], f.replies[1:]
+		}
+		f.mu.Unlock()
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "3")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"nope"}`))
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+var testSegmentations = memorySegmentations{
+	1: {
+		{UserID: 1, SegmentationType: "drug", SegmentationName: "Dipirona"},
+		{UserID: 1, SegmentationType: "drug", SegmentationName: "Aspirina"},
+		{UserID: 1, SegmentationType: "specialty", SegmentationName: "Cardiologia"},
+		{UserID: 1, SegmentationType: "region", SegmentationName: "Sul"},
+	},
+	// user 2 has no drug left after the delete
+	2: {{UserID: 2, SegmentationType: "specialty", SegmentationName: "Pediatria"}},
+}
+
+var testEvents = []models.OutboxEvent{
+	{EventType: models.OutboxUpserted, UserID: 1, SegmentationType: "drug", SegmentationName: "Aspirina"},
+	{EventType: models.OutboxUpserted, UserID: 1, SegmentationType: "specialty", SegmentationName: "Cardiologia"},
+	{EventType: models.OutboxUpserted, UserID: 1, SegmentationType: "region", SegmentationName: "Sul"},
+	{EventType: models.OutboxDeleted, UserID: 2, SegmentationType: "drug", SegmentationName: "Aspirina"},
+	{EventType: models.OutboxUpserted, UserID: 3, SegmentationType: "region", SegmentationName: "Norte"},
+}
+
+var testFields = map[string]string{"drug": "segment_drugs", "specialty": "medical_specialty"}
+
+// noSleep records the waits instead of sleeping
+func noSleep(waits *[]time.Duration) Option {
+	return func(c *Connector) {
+		c.sleep = func(ctx context.Context, d time.Duration) error {
+			*waits = append(*waits, d)
+			return ctx.Err()
+		}
+	}
+}
+
+func TestConnector_Generic(t *testing.T) {
+	f := newFakeCRM(t)
+	c, err := NewConnector(Config{URL: f.srv.URL, Token: "t0ken", Fields: testFields}, testSegmentations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(context.Background(), testEvents); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if len(f.requests) != 1 {
+		t.Fatalf("requests = %d, want 1 batch", len(f.requests))
+	}
+	if f.requests[0].auth != "Bearer t0ken" {
+		t.Errorf("Authorization = %q", f.requests[0].auth)
+	}
+	// only the mapped types touched by the events, from the table; user 3
+	// has no mapped change
+	want := map[string]any{"contacts": []any{
+		map[string]any{"id": "1", "attributes": map[string]any{
+			"segment_drugs":     []any{"Aspirina", "Dipirona"},
+			"medical_specialty": []any{"Cardiologia"},
+		}},
+		map[string]any{"id": "2", "attributes": map[string]any{"segment_drugs": []any{}}},
+	}}
+	if got := f.requests[0].body; !reflect.DeepEqual(got, want) {
+		t.Errorf("body = %v, want %v", got, want)
+	}
+}
+
+func TestConnector_HubSpotBatches(t *testing.T) {
+	f := newFakeCRM(t)
+	c, err := NewConnector(Config{URL: f.srv.URL, Format: FormatHubSpot, IDProperty: "external_id", Fields: testFields},
+		testSegmentations, WithBatchSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(context.Background(), testEvents); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if len(f.requests) != 2 {
+		t.Fatalf("requests = %d, want one per contact", len(f.requests))
+	}
+	want := map[string]any{"inputs": []any{map[string]any{
+		"idProperty": "external_id",
+		"id":         "1",
+		"properties": map[string]any{"segment_drugs": "Aspirina;Dipirona", "medical_specialty": "Cardiologia"},
+	}}}
+	if got := f.requests[0].body; !reflect.DeepEqual(got, want) {
+		t.Errorf("body = %v, want %v", got, want)
+	}
+	if got := f.requests[1].body["inputs"].([]any)[0].(map[string]any)["properties"]; !reflect.DeepEqual(got, map[string]any{"segment_drugs": ""}) {
+		t.Errorf("an emptied attribute should be cleared, got %v", got)
+	}
+}
+
+func TestConnector_Retries(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   []int
+		wantErr   bool
+		wantCalls int
+		wantWaits []time.Duration
+	}{
+		{name: "throttled", replies: []int{429, 503}, wantCalls: 3, wantWaits: []time.Duration{3 * time.Second, 6 * time.Second}},
+		{name: "exhausted", replies: []int{500, 500, 500}, wantErr: true, wantCalls: 3, wantWaits: []time.Duration{time.Second, 2 * time.Second}},
+		{name: "refused is skipped", replies: []int{400}, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeCRM(t, tt.replies...)
+			var waits []time.Duration
+			c, err := NewConnector(Config{URL: f.srv.URL, Fields: testFields}, testSegmentations,
+				WithMaxRetries(2), noSleep(&waits))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.Publish(context.Background(), testEvents)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Publish() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(f.requests) != tt.wantCalls || !reflect.DeepEqual(waits, tt.wantWaits) {
+				t.Errorf("requests = %d, waits = %v; want %d and %v", len(f.requests), waits, tt.wantCalls, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func TestNewConnector_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{Fields: testFields},
+		{URL: "http://crm", Format: "salesforce", Fields: testFields},
+		{URL: "http://crm"},
+	} {
+		if _, err := NewConnector(cfg, testSegmentations); err == nil {
+			t.Errorf("NewConnector(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(100) // 10ms apart
+	start := time.Now()
+	for range 4 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("4 requests took %v, want at least 30ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newLimiter(0).wait(ctx); err == nil {
+		t.Error("wait() should fail with a cancelled context")
+	}
+}
@@ -0,0 +1,45 @@
+package crm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter spaces events at least interval apart; a zero interval lets
+// every event through
+type limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // earliest time of the next event
+	now  func() time.Time
+}
+
+func newLimiter(perSecond float64) *limiter {
+	l := &limiter{now: time.Now}
+	if perSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	return l
+}
+
+// wait blocks until the next event may happen, or ctx is done
+func (l *limiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := l.now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		return sleep(ctx, d)
+	}
+	return ctx.Err()
+}
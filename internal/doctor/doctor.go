@@ -0,0 +1,179 @@
+// Package doctor runs the self-checks of the doctor command: a pass/fail
+// report of the configuration, the database and the filesystem, meant to
+// catch misconfiguration before a deployment rather than at startup.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	Pass Status = "PASS"
+	// Warn flags something to look at that does not prevent a deployment
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+	// Skip means the check could not run because an earlier one failed
+	Skip Status = "SKIP"
+)
+
+// Result is the outcome of one check with a message for the operator
+type Result struct {
+	Name    string
+	Status  Status
+	Message string
+}
+
+// Check returns the status and message of one verification
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (Status, string)
+}
+
+// Run runs the checks in order, each bounded by timeout
+func Run(ctx context.Context, timeout time.Duration, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		status, msg := c.Run(checkCtx)
+		cancel()
+		results = append(results, Result{Name: c.Name, Status: status, Message: msg})
+	}
+	return results
+}
+
+// Print writes the report as a table and reports whether any check failed
+func Print(w io.Writer, results []Result) (failed bool) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	counts := map[Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		lines := strings.Split(r.Message, "\n")
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Status, r.Name, lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(tw, "\t\t%s\n", line)
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		counts[Pass], counts[Warn], counts[Fail], counts[Skip])
+	return counts[Fail] > 0
+}
+
+// Skipped is a check that did not run, with the reason
+func Skipped(name, reason string) Check {
+	return Check{Name: name, Run: func(context.Context) (Status, string) { return Skip, reason }}
+}
+
+// Config reports the error of config.Validate, one problem per line
+func Config(err error) Check {
+	return Check{Name: "config", Run: func(context.Context) (Status, string) {
+		if err != nil {
+			return Fail, err.Error()
+		}
+		return Pass, "required settings are present and valid"
+	}}
+}
+
+// Database pings the database
+func Database(ping func(ctx context.Context) error) Check {
+	return Check{Name: "database", Run: func(ctx context.Context) (Status, string) {
+		start := time.Now()
+		if err := ping(ctx); err != nil {
+			return Fail, err.Error()
+		}
+		return Pass, fmt.Sprintf("reachable (%s)", time.Since(start).Round(time.Millisecond))
+	}}
+}
+
+// Privileges reports the privileges the database user lacks. The
+// migration privileges are only required when migrateOnStart is set;
+// otherwise their absence is a warning, since "migrate up" may run as
+// another user.
+func Privileges(missing func(ctx context.Context, required ...string) ([]string, error), data, migration []string, migrateOnStart bool) Check {
+	return Check{Name: "privileges", Run: func(ctx context.Context) (Status, string) {
+		lackData, err := missing(ctx, data...)
+		if err != nil {
+			return Fail, err.Error()
+		}
+		if len(lackData) > 0 {
+			return Fail, "missing " + strings.Join(lackData, ", ")
+		}
+		lackDDL, err := missing(ctx, migration...)
+		if err != nil {
+			return Fail, err.Error()
+		}
+		switch {
+		case len(lackDDL) == 0:
+			return Pass, "the user can read, write and migrate"
+		case migrateOnStart:
+			return Fail, "missing " + strings.Join(lackDDL, ", ") + " needed by db.migrate_on_start"
+		default:
+			return Warn, "missing " + strings.Join(lackDDL, ", ") + ": run migrations as another user"
+		}
+	}}
+}
+
+// Migrations reports the pending migrations: a failure when nothing will
+// apply them, a warning when serve and import will at startup
+func Migrations(pending func(ctx context.Context) ([]string, error), migrateOnStart bool) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) (Status, string) {
+		names, err := pending(ctx)
+		if err != nil {
+			return Fail, err.Error()
+		}
+		switch {
+		case len(names) == 0:
+			return Pass, "schema is up to date"
+		case migrateOnStart:
+			return Warn, fmt.Sprintf("%d pending, applied at startup: %s", len(names), strings.Join(names, ", "))
+		default:
+			return Fail, fmt.Sprintf("%d pending, run \"migrate up\": %s", len(names), strings.Join(names, ", "))
+		}
+	}}
+}
+
+// WritableDir creates dir if needed and writes a file in it
+func WritableDir(name, dir string) Check {
+	return Check{Name: name, Run: func(context.Context) (Status, string) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return Fail, err.Error()
+		}
+		f, err := os.CreateTemp(dir, ".doctor-*")
+		if err != nil {
+			return Fail, err.Error()
+		}
+		f.Close()
+		os.Remove(f.Name())
+		return Pass, dir + " is writable"
+	}}
+}
+
+// ReadableFile opens path and reads from it. An empty path is a warning:
+// the file is only needed by some commands (what tells which).
+func ReadableFile(name, path, what string) Check {
+	return Check{Name: name, Run: func(context.Context) (Status, string) {
+		if path == "" {
+			return Warn, "not set; " + what
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return Fail, err.Error()
+		}
+		defer f.Close()
+		if _, err := f.Read(make([]byte, 1)); err != nil && err != io.EOF {
+			return Fail, err.Error()
+		}
+		abs, _ := filepath.Abs(path)
+		return Pass, abs + " is readable"
+	}}
+}
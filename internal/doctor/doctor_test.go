@@ -0,0 +1,127 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func status(t *testing.T, c Check) (Status, string) {
+	t.Helper()
+	return c.Run(context.Background())
+}
+
+func TestMigrations(t *testing.T) {
+	pending := func(names ...string) func(context.Context) ([]string, error) {
+		return func(context.Context) ([]string, error) { return names, nil }
+	}
+
+	if s, _ := status(t, Migrations(pending(), false)); s != Pass {
+		t.Errorf("up to date: %s, want PASS", s)
+	}
+	if s, _ := status(t, Migrations(pending("1_a"), true)); s != Warn {
+		t.Errorf("pending with migrate on start: %s, want WARN", s)
+	}
+	if s, msg := status(t, Migrations(pending("1_a"), false)); s != Fail || !strings.Contains(msg, "migrate up") {
+		t.Errorf("pending without migrate on start: %s %q, want FAIL", s, msg)
+	}
+	failing := func(context.Context) ([]string, error) { return nil, errors.New("boom") }
+	if s, _ := status(t, Migrations(failing, true)); s != Fail {
+		t.Errorf("lookup error: %s, want FAIL", s)
+	}
+}
+
+func TestPrivileges(t *testing.T) {
+	lacking := func(lack ...string) func(context.Context, ...string) ([]string, error) {
+		return func(_ context.Context, required ...string) ([]string, error) {
+			var missing []string
+			for _, r := range required {
+				for _, l := range lack {
+					if r == l {
+						missing = append(missing, r)
+					}
+				}
+			}
+			return missing, nil
+		}
+	}
+	data, ddl := []string{"SELECT", "INSERT"}, []string{"CREATE"}
+
+	tests := []struct {
+		name           string
+		lack           []string
+		migrateOnStart bool
+		want           Status
+	}{
+		{name: "all", want: Pass},
+		{name: "no insert", lack: []string{"INSERT"}, want: Fail},
+		{name: "no ddl, migrations elsewhere", lack: []string{"CREATE"}, want: Warn},
+		{name: "no ddl, migrate on start", lack: []string{"CREATE"}, migrateOnStart: true, want: Fail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if s, msg := status(t, Privileges(lacking(tt.lack...), data, ddl, tt.migrateOnStart)); s != tt.want {
+				t.Errorf("Privileges() = %s %q, want %s", s, msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	if s, msg := status(t, WritableDir("log_dir", filepath.Join(dir, "logs"))); s != Pass {
+		t.Errorf("WritableDir() = %s %q", s, msg)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "logs")); len(entries) != 0 {
+		t.Errorf("WritableDir() should not leave files behind: %v", entries)
+	}
+
+	file := filepath.Join(dir, "data.csv")
+	os.WriteFile(file, []byte("1,drug,A,{}\n"), 0644)
+	if s, _ := status(t, ReadableFile("data_file", file, "")); s != Pass {
+		t.Errorf("ReadableFile(existing) = %s", s)
+	}
+	if s, _ := status(t, ReadableFile("data_file", filepath.Join(dir, "missing.csv"), "")); s != Fail {
+		t.Errorf("ReadableFile(missing) = %s", s)
+	}
+	if s, _ := status(t, ReadableFile("data_file", dir, "")); s != Fail {
+		t.Errorf("ReadableFile(directory) = %s", s)
+	}
+	if s, _ := status(t, ReadableFile("data_file", "", "only needed by import")); s != Warn {
+		t.Errorf("ReadableFile(unset) = %s", s)
+	}
+}
+
+func TestRunAndPrint(t *testing.T) {
+	slow := Check{Name: "slow", Run: func(ctx context.Context) (Status, string) {
+		<-ctx.Done()
+		return Fail, ctx.Err().Error()
+	}}
+	results := Run(context.Background(), 10*time.Millisecond, []Check{
+		Config(nil),
+		Config(errors.New("db.host (DB_HOST) is required\ndb.name (DB_NAME) is required")),
+		Skipped("database", "no config"),
+		slow,
+	})
+
+	var out bytes.Buffer
+	if !Print(&out, results) {
+		t.Error("Print() should report the failures")
+	}
+	report := out.String()
+	for _, want := range []string{"PASS  config", "FAIL  config", "db.name (DB_NAME)", "SKIP  database", "FAIL  slow", "1 passed, 0 warnings, 2 failed, 1 skipped"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report should contain %q:\n%s", want, report)
+		}
+	}
+
+	out.Reset()
+	if Print(&out, Run(context.Background(), time.Second, []Check{Config(nil)})) {
+		t.Error("Print() should not report a failure when every check passed")
+	}
+}
@@ -0,0 +1,390 @@
+// Package export runs the table exports started with POST /admin/exports:
+// the segmentations passing a filter, or all of them, are written as
+// gzip-compressed NDJSON files of at most a chunk of rows each, to a local
+// directory or to S3, for the data warehouse. Each line is a backup.Record,
+// so a chunk can also be loaded back with "import --restore".
+//
+// A pseudonymized export, made to share a dataset with analytics vendors,
+// writes PseudonymRecords instead: user IDs replaced by pseudonyms and the
+// sensitive data keys dropped. It cannot be restored.
+//
+// An export is a run of kind export in the runs table. RowsRead counts the
+// rows exported so far and is updated after each chunk. The files of a run
+// go under <run_id>/: part-00000.ndjson.gz, part-00001.ndjson.gz, ... and
+// last manifest.json, which lists them. Loaders should wait for the
+// manifest.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/backup"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultChunkRows is the number of rows of a file
+	DefaultChunkRows = 100_000
+	// ManifestName is the file written once every chunk is stored
+	ManifestName = "manifest.json"
+
+	// finishTimeout bounds recording the outcome of a cancelled export
+	finishTimeout = 10 * time.Second
+)
+
+var (
+	// ErrRunning is returned by Start while an export of this instance is
+	// still running
+	ErrRunning = apperrors.New("an export is already running", apperrors.ErrConflict)
+	// ErrNoPseudonymKey is returned by Start for a pseudonymized export
+	// when the exporter has no Pseudonymizer
+	ErrNoPseudonymKey = apperrors.New("pseudonymized exports are not configured", apperrors.ErrValidation)
+)
+
+// Manifest describes the files of a finished export
+type Manifest struct {
+	RunID      string  `json:"run_id"`
+	Filter     string  `json:"filter,omitempty"`
+	Rows       uint64  `json:"rows"`
+	Chunks     []Chunk `json:"chunks"`
+	StartedAt  int64   `json:"started_at"`
+	FinishedAt int64   `json:"finished_at"`
+
+	// Pseudonymized exports list the data keys dropped from the rows
+	Pseudonymized bool     `json:"pseudonymized,omitempty"`
+	StrippedKeys  []string `json:"stripped_keys,omitempty"`
+}
+
+// Chunk is one file of an export
+type Chunk struct {
+	Name  string `json:"name"`
+	Rows  uint64 `json:"rows"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Exporter runs exports in the background, one at a time
+type Exporter struct {
+	repo      repository.BackupRepository
+	runs      repository.RunRepository
+	sink      Sink
+	chunkRows int
+	pseudonym *Pseudonymizer
+	logger    *zap.Logger
+
+	// ctx is the parent of the exports, cancelled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running bool
+}
+
+// Option customizes an Exporter
+type Option func(*Exporter)
+
+// WithChunkRows sets the number of rows of a file; n < 1 keeps the default
+func WithChunkRows(n int) Option {
+	return func(e *Exporter) {
+		if n > 0 {
+			e.chunkRows = n
+		}
+	}
+}
+
+// WithPseudonymizer allows pseudonymized exports, written with p
+func WithPseudonymizer(p *Pseudonymizer) Option {
+	return func(e *Exporter) {
+		e.pseudonym = p
+	}
+}
+
+// WithLogger logs the progress and outcome of the exports
+func WithLogger(logger *zap.Logger) Option {
+	return func(e *Exporter) {
+		e.logger = logger
+	}
+}
+
+// NewExporter exports the rows of repo to sink, recording each export in
+// runs
+func NewExporter(repo repository.BackupRepository, runs repository.RunRepository, sink Sink, opts ...Option) *Exporter {
+	e := &Exporter{
+		repo:      repo,
+		runs:      runs,
+		sink:      sink,
+		chunkRows: DefaultChunkRows,
+		logger:    zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	return e
+}
+
+// Start records an export of the rows passing filter and runs it in the
+// background; source describes the filter in the runs table. With
+// pseudonymize the rows are written as PseudonymRecords. It returns the
+// run as recorded, or ErrRunning while another export is running.
+func (e *Exporter) Start(ctx context.Context, filter repository.Filter, source string, pseudonymize bool) (*models.Run, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if pseudonymize && e.pseudonym == nil {
+		return nil, ErrNoPseudonymKey
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return nil, ErrRunning
+	}
+	if e.ctx.Err() != nil {
+		return nil, errors.New("exporter closed")
+	}
+
+	id := uuid.NewString()
+	run := &models.Run{
+		ID:          id,
+		Kind:        models.RunExport,
+		JobID:       id,
+		Partitions:  1,
+		Source:      source,
+		Destination: e.sink.Location(id + "/"),
+		Status:      models.RunRunning,
+		StartedAt:   time.Now().Unix(),
+
+		Pseudonymized: pseudonymize,
+	}
+	if err := e.runs.Create(ctx, run); err != nil {
+		return nil, err
+	}
+
+	e.running = true
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer func() {
+			e.mu.Lock()
+			e.running = false
+			e.mu.Unlock()
+		}()
+		e.run(e.ctx, *run, filter)
+	}()
+	return run, nil
+}
+
+// Close cancels the running export, recorded as cancelled, and waits for
+// it to stop
+func (e *Exporter) Close() {
+	e.cancel()
+	e.wg.Wait()
+}
+
+// run exports and records the outcome in the runs table
+func (e *Exporter) run(ctx context.Context, run models.Run, filter repository.Filter) {
+	logger := e.logger.With(zap.String("run_id", run.ID))
+	logger.Info("export_started", zap.String("filter", run.Source), zap.String("destination", run.Destination), zap.Bool("pseudonymized", run.Pseudonymized))
+
+	err := e.export(ctx, &run, filter, logger)
+
+	run.FinishedAt = time.Now().Unix()
+	switch {
+	case err == nil:
+		run.Status = models.RunSucceeded
+		logger.Info("export_finished", zap.Uint64("rows", run.RowsRead), zap.Int64("seconds", run.FinishedAt-run.StartedAt))
+	case ctx.Err() != nil:
+		run.Status = models.RunCancelled
+		run.Error = context.Cause(ctx).Error()
+		logger.Warn("export_cancelled", zap.Uint64("rows", run.RowsRead))
+	default:
+		run.Status = models.RunFailed
+		run.Error = err.Error()
+		logger.Error("export_failed", zap.Uint64("rows", run.RowsRead), zap.Error(err))
+	}
+
+	// a cancelled export is still recorded
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+	if err := e.runs.Update(updateCtx, &run); err != nil {
+		logger.Error("export_run_update_error", zap.Error(err))
+	}
+}
+
+// export writes the chunks and then the manifest, updating run.RowsRead
+// after each chunk
+func (e *Exporter) export(ctx context.Context, run *models.Run, filter repository.Filter, logger *zap.Logger) error {
+	manifest := Manifest{RunID: run.ID, Filter: run.Source, StartedAt: run.StartedAt}
+	var pseudonym *Pseudonymizer
+	if run.Pseudonymized {
+		pseudonym = e.pseudonym
+		manifest.Pseudonymized = true
+		manifest.StrippedKeys = pseudonym.StripKeys()
+	}
+
+	var chunk *chunkFile
+	defer func() {
+		chunk.discard()
+	}()
+	flush := func() error {
+		if chunk == nil {
+			return nil
+		}
+		name := fmt.Sprintf("part-%05d.ndjson.gz", len(manifest.Chunks))
+		stored, err := chunk.store(ctx, e.sink, path.Join(run.ID, name))
+		if err != nil {
+			return err
+		}
+		stored.Name = name
+		manifest.Chunks = append(manifest.Chunks, stored)
+		manifest.Rows += stored.Rows
+		chunk = nil
+
+		run.RowsRead = manifest.Rows
+		if err := e.runs.Update(ctx, run); err != nil {
+			// the progress is only informative; the outcome is recorded at
+			// the end
+			logger.Warn("export_progress_update_error", zap.Error(err))
+		}
+		logger.Info("export_chunk_written", zap.String("chunk", name), zap.Uint64("rows", manifest.Rows))
+		return nil
+	}
+
+	err := e.repo.Scan(ctx, filter, backup.BatchSize, func(batch []models.Segmentation) error {
+		for _, s := range batch {
+			if chunk == nil {
+				var err error
+				if chunk, err = newChunkFile(pseudonym); err != nil {
+					return err
+				}
+			}
+			if err := chunk.write(s); err != nil {
+				return err
+			}
+			if chunk.rows == uint64(e.chunkRows) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest.FinishedAt = time.Now().Unix()
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return putBytes(ctx, e.sink, path.Join(run.ID, ManifestName), b)
+}
+
+// chunkFile is a chunk being written to a temporary file, compressed; with
+// a pseudonym its lines are PseudonymRecords
+type chunkFile struct {
+	f         *os.File
+	zw        *gzip.Writer
+	bw        *bufio.Writer
+	enc       *json.Encoder
+	pseudonym *Pseudonymizer
+	rows      uint64
+}
+
+func newChunkFile(pseudonym *Pseudonymizer) (*chunkFile, error) {
+	f, err := os.CreateTemp("", "segmentation-export-*.ndjson.gz")
+	if err != nil {
+		return nil, err
+	}
+	zw := gzip.NewWriter(f)
+	bw := bufio.NewWriter(zw)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	return &chunkFile{f: f, zw: zw, bw: bw, enc: enc, pseudonym: pseudonym}, nil
+}
+
+func (c *chunkFile) write(s models.Segmentation) error {
+	if c.pseudonym != nil {
+		rec, err := c.pseudonym.Record(s)
+		if err != nil {
+			return err
+		}
+		return c.encode(rec)
+	}
+	return c.encode(backup.Record{
+		UserID:           s.UserID,
+		SegmentationType: s.SegmentationType,
+		SegmentationName: s.SegmentationName,
+		NameKey:          s.NameKey,
+		Data:             json.RawMessage(s.Data),
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	})
+}
+
+func (c *chunkFile) encode(rec any) error {
+	if err := c.enc.Encode(rec); err != nil {
+		return err
+	}
+	c.rows++
+	return nil
+}
+
+// store completes the gzip stream, stores the file as name in sink and
+// removes it
+func (c *chunkFile) store(ctx context.Context, sink Sink, name string) (Chunk, error) {
+	defer c.discard()
+	if err := c.bw.Flush(); err != nil {
+		return Chunk{}, err
+	}
+	if err := c.zw.Close(); err != nil {
+		return Chunk{}, err
+	}
+	size, err := c.f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Chunk{}, err
+	}
+	if _, err := c.f.Seek(0, io.SeekStart); err != nil {
+		return Chunk{}, err
+	}
+	if err := sink.Put(ctx, name, c.f, size); err != nil {
+		return Chunk{}, err
+	}
+	return Chunk{Rows: c.rows, Bytes: size}, nil
+}
+
+// discard removes the temporary file; it accepts a nil or already
+// discarded chunk
+func (c *chunkFile) discard() {
+	if c == nil || c.f == nil {
+		return
+	}
+	c.f.Close()
+	os.Remove(c.f.Name())
+	c.f = nil
+}
+
+func putBytes(ctx context.Context, sink Sink, name string, b []byte) error {
+	return sink.Put(ctx, name, bytes.NewReader(b), int64(len(b)))
+}
@@ -0,0 +1,192 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"segmentation-api/internal/backup"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// memoryRepository scans rows in order; the filter keeps the rows of the
+// types it names with eq
+type memoryRepository struct {
+	repository.BackupRepository
+	rows []models.Segmentation
+	// block, when set, holds Scan until the context is cancelled
+	block bool
+}
+
+func (m *memoryRepository) Scan(ctx context.Context, filter repository.Filter, batchSize int, fn func([]models.Segmentation) error) error {
+	if m.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	var rows []models.Segmentation
+	for _, r := range m.rows {
+		if len(filter) == 0 || r.SegmentationType == filter[0].Values[0] {
+			rows = append(rows, r)
+		}
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		if err := fn(rows[start:min(start+batchSize, len(rows))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memoryRuns keeps every update of the runs
+type memoryRuns struct {
+	repository.RunRepository
+	mu      sync.Mutex
+	byID    map[string]models.Run
+	updates int
+}
+
+func (m *memoryRuns) Create(ctx context.Context, run *models.Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.byID == nil {
+		m.byID = map[string]models.Run{}
+	}
+	m.byID[run.ID] = *run
+	return nil
+}
+
+func (m *memoryRuns) Update(ctx context.Context, run *models.Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byID[run.ID] = *run
+	m.updates++
+	return nil
+}
+
+func (m *memoryRuns) get(id string) models.Run {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.byID[id]
+}
+
+func TestExporter_ChunksAndManifest(t *testing.T) {
+	repo := &memoryRepository{}
+	for i := 1; i <= 2500; i++ {
+		repo.rows = append(repo.rows, models.Segmentation{
+			UserID:           uint64(i),
+			SegmentationType: "drug",
+			SegmentationName: fmt.Sprintf("Ácido %d", i),
+			Data:             []byte(`{"dose":"5mg"}`),
+		})
+	}
+	repo.rows = append(repo.rows, models.Segmentation{UserID: 1, SegmentationType: "specialty", SegmentationName: "Cardiologia"})
+	runs := &memoryRuns{}
+	dir := t.TempDir()
+	e := NewExporter(repo, runs, NewDirSink(dir), WithChunkRows(1000))
+
+	filter := repository.Filter{{Field: "segmentation_type", Op: repository.FilterEq, Values: []string{"drug"}}}
+	run, err := e.Start(context.Background(), filter, `["segmentation_type:eq:drug"]`, false)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if run.Kind != models.RunExport || run.Status != models.RunRunning || run.Destination != filepath.Join(dir, run.ID) {
+		t.Errorf("unexpected run %+v", run)
+	}
+	e.wg.Wait()
+
+	got := runs.get(run.ID)
+	if got.Status != models.RunSucceeded || got.RowsRead != 2500 || got.FinishedAt == 0 {
+		t.Fatalf("unexpected finished run %+v", got)
+	}
+	// one progress update per chunk, then the outcome
+	if runs.updates != 4 {
+		t.Errorf("runs updated %d times, want 4", runs.updates)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, run.ID, ManifestName))
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatalf("invalid manifest %s: %v", raw, err)
+	}
+	if manifest.Rows != 2500 || len(manifest.Chunks) != 3 || manifest.Chunks[2].Name != "part-00002.ndjson.gz" || manifest.Chunks[2].Rows != 500 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	// the chunks are backups
+	restored := 0
+	for _, c := range manifest.Chunks {
+		f, err := os.ReadFile(filepath.Join(dir, run.ID, c.Name))
+		if err != nil || int64(len(f)) != c.Bytes {
+			t.Fatalf("chunk %s: %d bytes, %v", c.Name, len(f), err)
+		}
+		n, err := backup.Restore(context.Background(), &restoreCounter{}, bytes.NewReader(f))
+		if err != nil {
+			t.Fatalf("restore %s: %v", c.Name, err)
+		}
+		restored += n
+	}
+	if restored != 2500 {
+		t.Errorf("restored %d rows, want 2500", restored)
+	}
+}
+
+type restoreCounter struct {
+	repository.BackupRepository
+}
+
+func (restoreCounter) Restore(ctx context.Context, items []models.Segmentation) error { return nil }
+
+func TestExporter_Empty(t *testing.T) {
+	runs := &memoryRuns{}
+	dir := t.TempDir()
+	e := NewExporter(&memoryRepository{}, runs, NewDirSink(dir))
+
+	run, err := e.Start(context.Background(), nil, "", false)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	e.wg.Wait()
+
+	if got := runs.get(run.ID); got.Status != models.RunSucceeded || got.RowsRead != 0 {
+		t.Errorf("unexpected run %+v", got)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, run.ID))
+	if len(entries) != 1 || entries[0].Name() != ManifestName {
+		t.Errorf("an empty export should only write the manifest, got %v", entries)
+	}
+}
+
+func TestExporter_OneAtATimeAndClose(t *testing.T) {
+	runs := &memoryRuns{}
+	e := NewExporter(&memoryRepository{block: true}, runs, NewDirSink(t.TempDir()))
+
+	run, err := e.Start(context.Background(), nil, "", false)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := e.Start(context.Background(), nil, "", false); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Start() error = %v, want ErrRunning", err)
+	}
+	bad := repository.Filter{{Field: "password", Op: repository.FilterEq, Values: []string{"x"}}}
+	if _, err := e.Start(context.Background(), bad, "", false); err == nil {
+		t.Error("an invalid filter should be refused")
+	}
+
+	e.Close()
+	if got := runs.get(run.ID); got.Status != models.RunCancelled || got.FinishedAt == 0 {
+		t.Errorf("a closed export should be recorded as cancelled, got %+v", got)
+	}
+	if _, err := e.Start(context.Background(), nil, "", false); err == nil {
+		t.Error("Start() after Close() should fail")
+	}
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	"segmentation-api/internal/models"
+)
+
+// PseudonymRecord is a line of a pseudonymized export: the user ID is
+// replaced by its pseudonym and the sensitive data keys are dropped, so
+// the file identifies no physician and cannot be restored
+type PseudonymRecord struct {
+	User             string          `json:"user"`
+	SegmentationType string          `json:"segmentation_type"`
+	SegmentationName string          `json:"segmentation_name"`
+	Data             json.RawMessage `json:"data"`
+	CreatedAt        int64           `json:"created_at"`
+	UpdatedAt        int64           `json:"updated_at"`
+}
+
+// Pseudonymizer turns segmentations into PseudonymRecords. The pseudonym
+// of a user is the hex HMAC-SHA256 of its decimal ID under a secret key:
+// the same in every export made with the key, so datasets can be joined,
+// but not reversible nor guessable by enumerating IDs without it.
+type Pseudonymizer struct {
+	key       []byte
+	stripKeys map[string]bool
+	keys      []string
+}
+
+// NewPseudonymizer pseudonymizes with key, dropping the top-level members
+// of data named in stripKeys
+func NewPseudonymizer(key string, stripKeys []string) *Pseudonymizer {
+	p := &Pseudonymizer{
+		key:       []byte(key),
+		stripKeys: make(map[string]bool, len(stripKeys)),
+	}
+	for _, k := range stripKeys {
+		if !p.stripKeys[k] {
+			p.stripKeys[k] = true
+			p.keys = append(p.keys, k)
+		}
+	}
+	return p
+}
+
+// StripKeys returns the data keys dropped from the records
+func (p *Pseudonymizer) StripKeys() []string {
+	return p.keys
+}
+
+// Pseudonym returns the pseudonym of a user
+func (p *Pseudonymizer) Pseudonym(userID uint64) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(strconv.AppendUint(nil, userID, 10))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Record returns the pseudonymized line of s
+func (p *Pseudonymizer) Record(s models.Segmentation) (PseudonymRecord, error) {
+	data, err := p.strip([]byte(s.Data))
+	if err != nil {
+		return PseudonymRecord{}, err
+	}
+	return PseudonymRecord{
+		User:             p.Pseudonym(s.UserID),
+		SegmentationType: s.SegmentationType,
+		SegmentationName: s.SegmentationName,
+		Data:             data,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}, nil
+}
+
+// strip drops the sensitive keys of data; data that is not an object, or
+// holds none of them, is kept as is
+func (p *Pseudonymizer) strip(data []byte) (json.RawMessage, error) {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(p.stripKeys) == 0 || len(trimmed) == 0 || trimmed[0] != '{' {
+		return json.RawMessage(data), nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	stripped := false
+	for k := range members {
+		if p.stripKeys[k] {
+			delete(members, k)
+			stripped = true
+		}
+	}
+	if !stripped {
+		return json.RawMessage(data), nil
+	}
+	return json.Marshal(members)
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+)
+
+const testPseudonymKey = "0123456789abcdef0123456789abcdef"
+
+func TestPseudonymizer_Record(t *testing.T) {
+	p := NewPseudonymizer(testPseudonymKey, []string{"crm", "email", "crm"})
+
+	// HMAC-SHA256 of "42" under the key
+	if got, want := p.Pseudonym(42), "3b12d0412db185c98ff58825ed4c81cfbc7bdabcf33ac48bdaaae5f39fc65445"; got != want {
+		t.Errorf("Pseudonym(42) = %q, want %q", got, want)
+	}
+	if NewPseudonymizer(strings.Repeat("x", 32), nil).Pseudonym(42) == p.Pseudonym(42) {
+		t.Error("another key should give another pseudonym")
+	}
+
+	rec, err := p.Record(models.Segmentation{
+		UserID:           42,
+		SegmentationType: "drug",
+		SegmentationName: "Dipirona",
+		Data:             []byte(`{"dose": "500mg", "crm": "123456-SP", "email": "a@b.c"}`),
+		CreatedAt:        1,
+		UpdatedAt:        2,
+	})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if rec.User != p.Pseudonym(42) || rec.SegmentationName != "Dipirona" || string(rec.Data) != `{"dose":"500mg"}` || rec.UpdatedAt != 2 {
+		t.Errorf("unexpected record %+v (data %s)", rec, rec.Data)
+	}
+	if keys := p.StripKeys(); len(keys) != 2 {
+		t.Errorf("StripKeys() = %v, want crm and email once", keys)
+	}
+
+	// data without the keys, or that is not an object, is kept as written
+	for _, data := range []string{`{"dose": 1}`, `["crm"]`, ``} {
+		rec, err := p.Record(models.Segmentation{UserID: 1, Data: []byte(data)})
+		if err != nil || string(rec.Data) != data {
+			t.Errorf("Record(%q) data = %s, %v", data, rec.Data, err)
+		}
+	}
+}
+
+func TestExporter_Pseudonymized(t *testing.T) {
+	repo := &memoryRepository{rows: []models.Segmentation{
+		{UserID: 7, SegmentationType: "drug", SegmentationName: "Dipirona", Data: []byte(`{"dose":"5mg","crm":"123"}`)},
+		{UserID: 8, SegmentationType: "drug", SegmentationName: "Losartana", Data: []byte(`{}`)},
+	}}
+	runs := &memoryRuns{}
+	dir := t.TempDir()
+
+	plain := NewExporter(repo, runs, NewDirSink(dir))
+	if _, err := plain.Start(context.Background(), nil, "", true); !errors.Is(err, ErrNoPseudonymKey) {
+		t.Errorf("Start() without a pseudonymizer error = %v, want ErrNoPseudonymKey", err)
+	}
+
+	p := NewPseudonymizer(testPseudonymKey, []string{"crm"})
+	e := NewExporter(repo, runs, NewDirSink(dir), WithPseudonymizer(p))
+	run, err := e.Start(context.Background(), nil, "", true)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	e.wg.Wait()
+	if got := runs.get(run.ID); got.Status != models.RunSucceeded || !got.Pseudonymized {
+		t.Fatalf("unexpected finished run %+v", got)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, run.ID, ManifestName))
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil || !manifest.Pseudonymized || len(manifest.StrippedKeys) != 1 {
+		t.Fatalf("unexpected manifest %s: %v", raw, err)
+	}
+
+	f, err := os.ReadFile(filepath.Join(dir, run.ID, manifest.Chunks[0].Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(zr)
+	var lines []map[string]any
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if _, ok := lines[0]["user_id"]; ok || lines[0]["user"] != p.Pseudonym(7) {
+		t.Errorf("the user ID should be replaced by its pseudonym: %v", lines[0])
+	}
+	if data := lines[0]["data"].(map[string]any); data["crm"] != nil || data["dose"] != "5mg" {
+		t.Errorf("crm should be stripped from the data: %v", data)
+	}
+}
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"segmentation-api/internal/sigv4"
+)
+
+// s3Attempts is how many times an upload is tried before the export fails
+const s3Attempts = 3
+
+// S3Config locates a bucket and holds static credentials to write to it
+type S3Config struct {
+	Bucket string
+	// Prefix is prepended to every key, e.g. "exports"
+	Prefix string
+	Region string
+	// Endpoint is the URL of an S3-compatible service such as MinIO,
+	// addressed path-style; empty uses AWS, addressed virtual-host style
+	Endpoint string
+	// Tags are set on every object, e.g. for the lifecycle rules of the
+	// bucket to expire them
+	Tags map[string]string
+	sigv4.Credentials
+}
+
+// S3Sink uploads the files to S3 with a signed PUT each. It only uses the
+// standard library: requests are signed with AWS Signature Version 4.
+type S3Sink struct {
+	cfg  S3Config
+	base *url.URL
+	// tagging is the X-Amz-Tagging header of the Tags
+	tagging string
+	http    *http.Client
+	signer  sigv4.Signer
+	// retryWait is the wait before the second attempt, doubled after
+	retryWait time.Duration
+}
+
+// NewS3Sink uploads to the bucket of cfg through hc, or
+// http.DefaultClient when nil
+func NewS3Sink(cfg S3Config, hc *http.Client) (*S3Sink, error) {
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("s3: bucket and region are required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3: access key ID and secret access key are required")
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	raw := "https://" + cfg.Bucket + ".s3." + cfg.Region + ".amazonaws.com"
+	if cfg.Endpoint != "" {
+		raw = strings.TrimRight(cfg.Endpoint, "/") + "/" + cfg.Bucket
+	}
+	base, err := url.Parse(raw)
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	tags := url.Values{}
+	for k, v := range cfg.Tags {
+		tags.Set(k, v)
+	}
+	return &S3Sink{
+		cfg:       cfg,
+		base:      base,
+		tagging:   tags.Encode(),
+		http:      hc,
+		signer:    sigv4.Signer{Credentials: cfg.Credentials, Region: cfg.Region, Service: "s3"},
+		retryWait: time.Second,
+	}, nil
+}
+
+func (s *S3Sink) key(name string) string {
+	if s.cfg.Prefix == "" {
+		return name
+	}
+	return s.cfg.Prefix + "/" + name
+}
+
+func (s *S3Sink) Location(name string) string {
+	return "s3://" + s.cfg.Bucket + "/" + s.key(name)
+}
+
+// Put uploads body, retrying connection errors, 429 and 5xx answers
+func (s *S3Sink) Put(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return err
+	}
+	payloadHash := hex.EncodeToString(h.Sum(nil))
+
+	var err error
+	wait := s.retryWait
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = s.put(ctx, name, body, size, payloadHash)
+		if err == nil || !retry || attempt == s3Attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// put sends one PUT; retry tells whether a failure may be transient
+func (s *S3Sink) put(ctx context.Context, name string, body io.ReadSeeker, size int64, payloadHash string) (retry bool, err error) {
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	target := *s.base
+	target.Path = s.base.Path + "/" + s.key(name)
+	target.RawPath = sigv4.URIEncode(target.Path, false)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), io.NopCloser(body))
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType(name))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.tagging != "" {
+		req.Header.Set("X-Amz-Tagging", s.tagging)
+	}
+	s.signer.Sign(req, payloadHash)
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("s3: put %s: %w", s.key(name), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("s3: put %s: %s: %s", s.key(name), resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func contentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".json"):
+		return "application/json"
+	case strings.HasSuffix(name, ".gz"):
+		return "application/gzip"
+	case strings.HasSuffix(name, ".csv"):
+		return "text/csv"
+	}
+	return "application/octet-stream"
+}
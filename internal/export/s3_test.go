@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/sigv4"
+)
+
+func TestS3Sink_Put(t *testing.T) {
+	var calls atomic.Int32
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method != http.MethodPut || r.URL.Path != "/warehouse/exports/run-1/part-00000.ndjson.gz" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20261017/sa-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature=") {
+			t.Errorf("unexpected Authorization %q", auth)
+		}
+		if r.Header.Get("X-Amz-Date") != "20261017T120000Z" || r.Header.Get("X-Amz-Security-Token") != "token" ||
+			r.Header.Get("Content-Type") != "application/gzip" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	sink, err := NewS3Sink(S3Config{
+		Bucket:   "warehouse",
+		Prefix:   "/exports/",
+		Region:   "sa-east-1",
+		Endpoint: srv.URL,
+		Credentials: sigv4.Credentials{
+			AccessKeyID:     "AKID",
+			SecretAccessKey: "secret",
+			SessionToken:    "token",
+		},
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewS3Sink() error = %v", err)
+	}
+	sink.signer.Now = func() time.Time { return time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC) }
+	sink.retryWait = time.Millisecond
+
+	if err := sink.Put(context.Background(), "run-1/part-00000.ndjson.gz", bytes.NewReader([]byte("chunk")), 5); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if calls.Load() != 2 || string(body) != "chunk" {
+		t.Errorf("a 503 should be retried with the whole body, got %d calls and %q", calls.Load(), body)
+	}
+	if got := sink.Location("run-1/"); got != "s3://warehouse/exports/run-1/" {
+		t.Errorf("Location() = %q", got)
+	}
+}
+
+func TestS3Sink_Tags(t *testing.T) {
+	var tagging, signed, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tagging, signed, contentType = r.Header.Get("X-Amz-Tagging"), r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	sink, err := NewS3Sink(S3Config{
+		Bucket:      "archive",
+		Region:      "sa-east-1",
+		Endpoint:    srv.URL,
+		Tags:        map[string]string{"retention": "7y", "dataset": "segmentations"},
+		Credentials: sigv4.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Put(context.Background(), "run-1/daily.csv", strings.NewReader("a,b"), 3); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if tagging != "dataset=segmentations&retention=7y" || !strings.Contains(signed, "x-amz-tagging") {
+		t.Errorf("X-Amz-Tagging = %q, should be signed: %q", tagging, signed)
+	}
+	if contentType != "text/csv" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+}
+
+func TestNewS3Sink_Invalid(t *testing.T) {
+	creds := sigv4.Credentials{AccessKeyID: "a", SecretAccessKey: "b"}
+	for _, cfg := range []S3Config{
+		{Region: "us-east-1", Credentials: creds},
+		{Bucket: "b", Region: "us-east-1"},
+		{Bucket: "b", Region: "us-east-1", Credentials: creds, Endpoint: "minio:9000"},
+	} {
+		if _, err := NewS3Sink(cfg, nil); err == nil {
+			t.Errorf("NewS3Sink(%+v) should fail", cfg)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package export
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Sink stores the files of an export
+type Sink interface {
+	// Put stores the size bytes of body as name, a slash-separated path
+	// relative to the sink. It may read body more than once, seeking back
+	// to the start.
+	Put(ctx context.Context, name string, body io.ReadSeeker, size int64) error
+	// Location tells where name is stored, e.g. /data/exports/<name> or
+	// s3://bucket/prefix/<name>
+	Location(name string) string
+}
+
+// DirSink writes the files under a local directory, e.g. a volume shared
+// with the warehouse loader
+type DirSink struct {
+	dir string
+}
+
+// NewDirSink writes the files under dir, created when missing
+func NewDirSink(dir string) *DirSink {
+	return &DirSink{dir: dir}
+}
+
+// Put writes name next to its final path and renames it when complete, so
+// readers never see a partial file
+func (s *DirSink) Put(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *DirSink) Location(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
@@ -0,0 +1,50 @@
+package grpcapi
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"segmentation-api/internal/service"
+	pb "segmentation-api/pkg/segmentationpb"
+)
+
+// GetUserSegmentations returns the segmentations of a user, read as
+// GET /users/:user_id/segmentations reads them (through the cache)
+func (s *Server) GetUserSegmentations(ctx context.Context, req *pb.GetUserSegmentationsRequest) (*pb.UserSegmentations, error) {
+	userID, err := s.svc.ParseUserID(strconv.FormatUint(req.GetUserId(), 10))
+	if err != nil {
+		return nil, status.Error(code(err), err.Error())
+	}
+	resp, err := s.svc.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, status.Error(code(err), err.Error())
+	}
+	return userSegmentations(resp), nil
+}
+
+// userSegmentations is the wire form of a read
+func userSegmentations(resp *service.SegmentationResponse) *pb.UserSegmentations {
+	out := &pb.UserSegmentations{UserId: resp.UserID}
+	for _, group := range slices.Sorted(maps.Keys(resp.Segmentations)) {
+		for _, item := range resp.Segmentations[group] {
+			seg := &pb.Segmentation{
+				Group:            group,
+				SegmentationName: item.Name,
+				Data:             string(item.Data),
+			}
+			if item.CreatedAt != 0 {
+				seg.CreatedAt = timestamppb.New(item.CreatedAt.Time())
+			}
+			if item.UpdatedAt != 0 {
+				seg.UpdatedAt = timestamppb.New(item.UpdatedAt.Time())
+			}
+			out.Segmentations = append(out.Segmentations, seg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,286 @@
+// Package grpcapi serves the gRPC API of segmentation-api, defined in
+// proto/segmentation/v1: the client-streaming ingest of upstream services,
+// which write segmentations as they produce them instead of uploading CSV
+// files for the processor, and the reads of a user's segmentations. gRPC
+// flow control is the backpressure of the ingest: a stream is not read
+// while its batch is written.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+	pb "segmentation-api/pkg/segmentationpb"
+)
+
+// maxReportedErrors is how many failed records a summary lists; the
+// others are only counted
+const maxReportedErrors = 100
+
+// Server implements the services of the gRPC API
+type Server struct {
+	pb.UnimplementedIngestServiceServer
+	pb.UnimplementedSegmentationServiceServer
+
+	svc            *service.SegmentationService
+	batchSize      int
+	maxRecvBytes   int
+	maintenance    *middleware.Maintenance
+	logger         *zap.Logger
+	healthRegistry *health.Server
+}
+
+// Option customizes a Server
+type Option func(*Server)
+
+// WithBatchSize sets how many valid records are written per statement
+// (default 500)
+func WithBatchSize(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.batchSize = n
+		}
+	}
+}
+
+// WithMaxRecvBytes sets the largest message accepted (default 4 MiB)
+func WithMaxRecvBytes(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxRecvBytes = n
+		}
+	}
+}
+
+// WithMaintenance refuses the writes with UNAVAILABLE while the API is in
+// read_only maintenance, as the HTTP write endpoints are
+func WithMaintenance(m *middleware.Maintenance) Option {
+	return func(s *Server) {
+		s.maintenance = m
+	}
+}
+
+// WithLogger sets the logger of the streams
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewServer serves the writes and reads of svc
+func NewServer(svc *service.SegmentationService, opts ...Option) *Server {
+	s := &Server{
+		svc:            svc,
+		batchSize:      500,
+		maxRecvBytes:   4 << 20,
+		logger:         zap.NewNop(),
+		healthRegistry: health.NewServer(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// GRPCServer returns a grpc.Server with the services registered, and the
+// standard health service reporting them as serving
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.MaxRecvMsgSize(s.maxRecvBytes)}, opts...)
+	srv := grpc.NewServer(opts...)
+	pb.RegisterIngestServiceServer(srv, s)
+	pb.RegisterSegmentationServiceServer(srv, s)
+	healthpb.RegisterHealthServer(srv, s.healthRegistry)
+	for _, name := range []string{pb.IngestService_ServiceDesc.ServiceName, pb.SegmentationService_ServiceDesc.ServiceName} {
+		s.healthRegistry.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	return srv
+}
+
+// Drain reports the services as not serving, for clients and load
+// balancers to move to other instances before the server stops
+func (s *Server) Drain() {
+	s.healthRegistry.Shutdown()
+}
+
+// StreamUpsert validates the records as they arrive and writes the valid
+// ones in batches. A batch MySQL refuses is written again record by record,
+// so only the refused records fail; a database that is unavailable aborts
+// the stream.
+func (s *Server) StreamUpsert(stream pb.IngestService_StreamUpsertServer) error {
+	if s.maintenance != nil {
+		if state := s.maintenance.State(); state.Mode == middleware.MaintenanceReadOnly {
+			return status.Error(codes.Unavailable, state.Message)
+		}
+	}
+	ctx := stream.Context()
+	begin := time.Now()
+	w := &streamWriter{svc: s.svc, summary: &pb.StreamUpsertSummary{}}
+
+	for {
+		rec, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.add(ctx, rec, s.batchSize); err != nil {
+			return s.abort(w.summary, err)
+		}
+	}
+	if err := w.flush(ctx); err != nil {
+		return s.abort(w.summary, err)
+	}
+
+	sum := w.summary
+	s.logger.Info("grpc_stream_upsert",
+		zap.Uint64("received", sum.Received),
+		zap.Uint64("inserted", sum.Inserted),
+		zap.Uint64("updated", sum.Updated),
+		zap.Uint64("unchanged", sum.Unchanged),
+		zap.Uint64("failed", sum.Failed),
+		zap.Duration("duration", time.Since(begin)),
+	)
+	return stream.SendAndClose(sum)
+}
+
+// abort ends a stream with the status of err
+func (s *Server) abort(sum *pb.StreamUpsertSummary, err error) error {
+	s.logger.Warn("grpc_stream_upsert_aborted",
+		zap.Uint64("received", sum.Received),
+		zap.Uint64("written", sum.Inserted+sum.Updated+sum.Unchanged),
+		zap.Error(err),
+	)
+	return status.Error(code(err), err.Error())
+}
+
+// streamWriter batches the records of a stream
+type streamWriter struct {
+	svc     *service.SegmentationService
+	summary *pb.StreamUpsertSummary
+	batch   []models.Segmentation
+	indexes []uint64
+}
+
+// add validates a record and queues it, writing the batch once it holds
+// size records
+func (w *streamWriter) add(ctx context.Context, rec *pb.UpsertRecord, size int) error {
+	index := w.summary.Received
+	w.summary.Received++
+
+	req := service.UpsertRequest{
+		SegmentationType: rec.GetSegmentationType(),
+		SegmentationName: rec.GetSegmentationName(),
+		Data:             []byte(rec.GetData()),
+	}
+	if rec.GetCreatedAt() != nil {
+		req.CreatedAt = service.Timestamp(rec.GetCreatedAt().GetSeconds())
+	}
+	seg, warnings, err := w.svc.Prepare(ctx, rec.GetUserId(), req)
+	if err != nil {
+		w.fail(index, err)
+		return nil
+	}
+	w.summary.Warnings += uint64(len(warnings))
+	w.batch = append(w.batch, *seg)
+	w.indexes = append(w.indexes, index)
+	if len(w.batch) >= size {
+		return w.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the queued records
+func (w *streamWriter) flush(ctx context.Context) error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	defer func() {
+		w.batch, w.indexes = w.batch[:0], w.indexes[:0]
+	}()
+
+	result, err := w.svc.CreateBatch(ctx, w.batch)
+	if err == nil {
+		w.summary.Inserted += uint64(result.Inserted)
+		w.summary.Updated += uint64(result.Updated)
+		w.summary.Unchanged += uint64(result.Ignored)
+		return nil
+	}
+	if retryLater(ctx, err) {
+		return err
+	}
+
+	for i := range w.batch {
+		res, err := w.svc.Create(ctx, &w.batch[i])
+		if err != nil {
+			if retryLater(ctx, err) {
+				return err
+			}
+			w.fail(w.indexes[i], err)
+			continue
+		}
+		switch res {
+		case repository.UpsertInserted:
+			w.summary.Inserted++
+		case repository.UpsertUpdated:
+			w.summary.Updated++
+		default:
+			w.summary.Unchanged++
+		}
+	}
+	return nil
+}
+
+func (w *streamWriter) fail(index uint64, err error) {
+	w.summary.Failed++
+	if len(w.summary.Errors) < maxReportedErrors {
+		w.summary.Errors = append(w.summary.Errors, &pb.RecordError{Index: index, Error: err.Error()})
+	}
+}
+
+// retryLater reports whether a write failed for lack of a database rather
+// than because of its contents
+func retryLater(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, repository.ErrUnavailable)
+}
+
+// code is the gRPC code of the kind of err, the counterpart of its HTTP
+// status (apperrors.Status)
+func code(err error) codes.Code {
+	if errors.Is(err, context.Canceled) {
+		return codes.Canceled
+	}
+	switch apperrors.Status(err) {
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,217 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"segmentation-api/internal/api/middleware"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+	pb "segmentation-api/pkg/segmentationpb"
+)
+
+// memorySegmentations records the writes; bulkErr fails every BulkUpsert
+// and refuse fails the Upsert of the names it holds
+type memorySegmentations struct {
+	repository.SegmentationRepository
+	bulkErr error
+	refuse  map[string]error
+	batches [][]models.Segmentation
+	written []models.Segmentation
+}
+
+func (m *memorySegmentations) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+	if err := m.refuse[s.SegmentationName]; err != nil {
+		return repository.UpsertNoOp, err
+	}
+	m.written = append(m.written, *s)
+	return repository.UpsertInserted, nil
+}
+
+func (m *memorySegmentations) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	if m.bulkErr != nil {
+		return repository.BulkUpsertResult{}, m.bulkErr
+	}
+	m.batches = append(m.batches, append([]models.Segmentation(nil), items...))
+	m.written = append(m.written, items...)
+	return repository.BulkUpsertResult{Inserted: len(items)}, nil
+}
+
+func (m *memorySegmentations) FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+	var out []models.Segmentation
+	for _, s := range m.written {
+		if s.UserID == userID {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// dial serves s over an in-memory connection
+func dial(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := s.GRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func streamUpsert(t *testing.T, conn *grpc.ClientConn, records ...*pb.UpsertRecord) (*pb.StreamUpsertSummary, error) {
+	t.Helper()
+	stream, err := pb.NewIngestServiceClient(conn).StreamUpsert(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := stream.Send(r); err != nil {
+			break // the server ended the stream; CloseAndRecv has its status
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func record(userID uint64, name string) *pb.UpsertRecord {
+	return &pb.UpsertRecord{UserId: userID, SegmentationType: "drug", SegmentationName: name, Data: `{"dose":"500mg"}`}
+}
+
+func TestStreamUpsert_Batches(t *testing.T) {
+	repo := &memorySegmentations{}
+	conn := dial(t, NewServer(service.NewSegmentationService(repo), WithBatchSize(2)))
+
+	migrated := record(3, "Dipirona")
+	migrated.CreatedAt = timestamppb.New(timestamppb.Now().AsTime().AddDate(-1, 0, 0))
+	sum, err := streamUpsert(t, conn,
+		record(1, "Aspirina"), record(1, "Paracetamol"), record(2, " "), migrated, record(4, "Ibuprofeno"), record(5, "Omeprazol"))
+	if err != nil {
+		t.Fatalf("StreamUpsert() error = %v", err)
+	}
+
+	if sum.Received != 6 || sum.Inserted != 5 || sum.Failed != 1 {
+		t.Errorf("unexpected summary %v", sum)
+	}
+	if len(sum.Errors) != 1 || sum.Errors[0].Index != 2 || !strings.Contains(sum.Errors[0].Error, "empty name") {
+		t.Errorf("errors = %v, want the record at index 2", sum.Errors)
+	}
+	if len(repo.batches) != 3 || len(repo.batches[0]) != 2 || len(repo.batches[2]) != 1 {
+		t.Errorf("want batches of 2, 2 and the remaining 1, got %d batches", len(repo.batches))
+	}
+	if repo.batches[1][0].CreatedAt != migrated.CreatedAt.GetSeconds() {
+		t.Errorf("created_at = %d, want %d", repo.batches[1][0].CreatedAt, migrated.CreatedAt.GetSeconds())
+	}
+}
+
+func TestStreamUpsert_RefusedBatchIsWrittenRecordByRecord(t *testing.T) {
+	repo := &memorySegmentations{
+		bulkErr: errors.New("deadlock found"),
+		refuse:  map[string]error{"Paracetamol": errors.New("data too long")},
+	}
+	conn := dial(t, NewServer(service.NewSegmentationService(repo)))
+
+	sum, err := streamUpsert(t, conn, record(1, "Aspirina"), record(1, "Paracetamol"), record(2, "Ibuprofeno"))
+	if err != nil {
+		t.Fatalf("StreamUpsert() error = %v", err)
+	}
+	if sum.Inserted != 2 || sum.Failed != 1 || sum.Errors[0].Index != 1 {
+		t.Errorf("unexpected summary %v", sum)
+	}
+}
+
+func TestStreamUpsert_Aborted(t *testing.T) {
+	tests := []struct {
+		name string
+		repo *memorySegmentations
+		opts []Option
+		want codes.Code
+	}{
+		{
+			name: "database unavailable",
+			repo: &memorySegmentations{bulkErr: &repository.UnavailableError{Err: errors.New("connection refused")}},
+			want: codes.Unavailable,
+		},
+		{
+			name: "read only maintenance",
+			repo: &memorySegmentations{},
+			opts: []Option{WithMaintenance(middleware.NewMaintenance(middleware.MaintenanceReadOnly, ""))},
+			want: codes.Unavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dial(t, NewServer(service.NewSegmentationService(tt.repo), tt.opts...))
+			_, err := streamUpsert(t, conn, record(1, "Aspirina"))
+			if status.Code(err) != tt.want {
+				t.Errorf("StreamUpsert() error = %v, want %s", err, tt.want)
+			}
+			if len(tt.repo.written) != 0 {
+				t.Errorf("nothing should be written, got %v", tt.repo.written)
+			}
+		})
+	}
+}
+
+func TestGetUserSegmentations(t *testing.T) {
+	repo := &memorySegmentations{written: []models.Segmentation{
+		{UserID: 1, SegmentationType: "Drug", SegmentationName: "Aspirina", Data: []byte(`{"dose":"500mg"}`), CreatedAt: 1760000000, UpdatedAt: 1760000100},
+		{UserID: 1, SegmentationType: "allergy", SegmentationName: "Lactose"},
+		{UserID: 2, SegmentationType: "drug", SegmentationName: "Dipirona"},
+	}}
+	svc := service.NewSegmentationService(repo, service.WithValidationRules(service.ValidationRules{MaxUserID: 1000}))
+	client := pb.NewSegmentationServiceClient(dial(t, NewServer(svc)))
+
+	resp, err := client.GetUserSegmentations(context.Background(), &pb.GetUserSegmentationsRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("GetUserSegmentations() error = %v", err)
+	}
+	if resp.UserId != 1 || len(resp.Segmentations) != 2 {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	allergy, drug := resp.Segmentations[0], resp.Segmentations[1]
+	if allergy.Group != "allergys" || allergy.Data != "" || allergy.CreatedAt != nil {
+		t.Errorf("segmentations should be sorted by group, got %v first", allergy)
+	}
+	if drug.Group != "drugs" || drug.Data != `{"dose":"500mg"}` || drug.UpdatedAt.GetSeconds() != 1760000100 {
+		t.Errorf("unexpected segmentation %v", drug)
+	}
+
+	_, err = client.GetUserSegmentations(context.Background(), &pb.GetUserSegmentationsRequest{UserId: 1001})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("user_id above the maximum: error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestServer_Health(t *testing.T) {
+	s := NewServer(service.NewSegmentationService(&memorySegmentations{}))
+	client := healthpb.NewHealthClient(dial(t, s))
+	req := &healthpb.HealthCheckRequest{Service: pb.IngestService_ServiceDesc.ServiceName}
+
+	resp, err := client.Check(context.Background(), req)
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Check() = %v, %v, want SERVING", resp, err)
+	}
+	s.Drain()
+	if resp, err = client.Check(context.Background(), req); err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Check() after Drain = %v, %v, want NOT_SERVING", resp, err)
+	}
+}
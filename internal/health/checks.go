@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"segmentation-api/internal/models"
+)
+
+// Database pings db and reports the round trip; pings slower than slow are
+// degraded
+func Database(db *sql.DB, slow time.Duration) Check {
+	return func(ctx context.Context) Result {
+		start := time.Now()
+		err := db.PingContext(ctx)
+		latency := time.Since(start)
+
+		details := map[string]any{
+			"latency_ms":       float64(latency.Microseconds()) / 1000,
+			"open_connections": db.Stats().OpenConnections,
+		}
+		switch {
+		case err != nil:
+			return Result{State: StateDown, Details: details, Error: err.Error()}
+		case latency > slow:
+			return Result{State: StateDegraded, Details: details}
+		default:
+			return Result{State: StateOK, Details: details}
+		}
+	}
+}
+
+// Schema reports the versioned migrations: pending lists the migrations
+// the code expects that were not applied yet, and any of them marks the
+// schema down since the code may use columns or tables that do not exist
+func Schema(pending func(ctx context.Context) ([]string, error)) Check {
+	return func(ctx context.Context) Result {
+		migrations, err := pending(ctx)
+		if err != nil {
+			return Result{State: StateDown, Error: err.Error()}
+		}
+		if len(migrations) > 0 {
+			return Result{State: StateDown, Details: map[string]any{"pending_migrations": migrations}}
+		}
+		return Result{State: StateOK}
+	}
+}
+
+// Disk thresholds as a fraction of the filesystem still available
+const (
+	DiskDegradedBelow = 0.10
+	DiskDownBelow     = 0.02
+)
+
+// Disk reports the space left on the filesystem holding path
+func Disk(path string) Check {
+	return func(ctx context.Context) Result {
+		free, total, err := diskUsage(path)
+		if err != nil {
+			return Result{State: StateDown, Details: map[string]any{"path": path}, Error: err.Error()}
+		}
+
+		if total == 0 {
+			return Result{State: StateDown, Details: map[string]any{"path": path}, Error: "filesystem reports no size"}
+		}
+
+		ratio := float64(free) / float64(total)
+		details := map[string]any{
+			"path":        path,
+			"free_bytes":  free,
+			"total_bytes": total,
+			"free_ratio":  ratio,
+		}
+		switch {
+		case ratio < DiskDownBelow:
+			return Result{State: StateDown, Details: details}
+		case ratio < DiskDegradedBelow:
+			return Result{State: StateDegraded, Details: details}
+		default:
+			return Result{State: StateOK, Details: details}
+		}
+	}
+}
+
+// ProcessorRun reports the last processor run. Failed or cancelled runs are
+// degraded, as is a run still marked running after stale (the processor was
+// probably killed before recording its end). No run at all is ok.
+func ProcessorRun(latest func(ctx context.Context) (*models.Run, error), stale time.Duration) Check {
+	return func(ctx context.Context) Result {
+		run, err := latest(ctx)
+		if err != nil {
+			return Result{State: StateDown, Error: err.Error()}
+		}
+		if run == nil {
+			return Result{State: StateOK, Details: map[string]any{"last_run": nil}}
+		}
+
+		details := map[string]any{
+			"run_id":     run.ID,
+			"status":     run.Status,
+			"started_at": time.Unix(run.StartedAt, 0).UTC(),
+			"failed":     run.Failed,
+			"invalid":    run.Invalid,
+		}
+		if run.FinishedAt > 0 {
+			details["finished_at"] = time.Unix(run.FinishedAt, 0).UTC()
+		}
+
+		switch run.Status {
+		case models.RunFailed:
+			return Result{State: StateDegraded, Details: details, Error: run.Error}
+		case models.RunCancelled:
+			return Result{State: StateDegraded, Details: details}
+		case models.RunRunning:
+			if age := time.Since(time.Unix(run.StartedAt, 0)); age > stale {
+				return Result{State: StateDegraded, Details: details, Error: fmt.Sprintf("running for %s", age.Round(time.Second))}
+			}
+		}
+		return Result{State: StateOK, Details: details}
+	}
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// State is the condition of a dependency
+type State string
+
+const (
+	StateOK       State = "ok"
+	StateDegraded State = "degraded"
+	StateDown     State = "down"
+)
+
+// worse reports whether s is a worse condition than other
+func (s State) worse(other State) bool {
+	rank := map[State]int{StateOK: 0, StateDegraded: 1, StateDown: 2}
+	return rank[s] > rank[other]
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	State   State          `json:"state"`
+	Details map[string]any `json:"details,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// Check inspects one dependency
+type Check func(ctx context.Context) Result
+
+// Report is the outcome of every registered check; Status is the worst
+// state among them
+type Report struct {
+	Status State             `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs a named set of checks
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  map[string]Check
+}
+
+// NewChecker creates a checker that gives each check up to timeout;
+// a check still running after it is reported as down
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: make(map[string]Check)}
+}
+
+// Register adds a check under name, replacing any previous one
+func (c *Checker) Register(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Run executes every check concurrently
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StateOK, Checks: make(map[string]Result, len(c.names))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.State.worse(report.Status) {
+				report.Status = result.State
+			}
+		}(name, c.checks[name])
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	done := make(chan Result, 1)
+	go func() { done <- check(ctx) }()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return Result{State: StateDown, Error: "check timed out"}
+	}
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+)
+
+func fixed(state State) Check {
+	return func(context.Context) Result { return Result{State: state} }
+}
+
+func TestChecker_StatusIsWorstState(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []State
+		want   State
+	}{
+		{name: "no checks", want: StateOK},
+		{name: "all ok", states: []State{StateOK, StateOK}, want: StateOK},
+		{name: "degraded", states: []State{StateOK, StateDegraded}, want: StateDegraded},
+		{name: "down wins", states: []State{StateDown, StateDegraded, StateOK}, want: StateDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(time.Second)
+			for i, s := range tt.states {
+				c.Register(string(rune('a'+i)), fixed(s))
+			}
+
+			report := c.Run(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("status = %s, want %s", report.Status, tt.want)
+			}
+			if len(report.Checks) != len(tt.states) {
+				t.Errorf("got %d results, want %d", len(report.Checks), len(tt.states))
+			}
+		})
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	c := NewChecker(10 * time.Millisecond)
+	c.Register("slow", func(ctx context.Context) Result {
+		time.Sleep(time.Second)
+		return Result{State: StateOK}
+	})
+
+	report := c.Run(context.Background())
+	if got := report.Checks["slow"]; got.State != StateDown || got.Error == "" {
+		t.Errorf("slow check = %+v, want down with an error", got)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	ok := Schema(func(context.Context) ([]string, error) { return nil, nil })(context.Background())
+	if ok.State != StateOK {
+		t.Errorf("no pending migrations: state = %s, want ok", ok.State)
+	}
+
+	pending := Schema(func(context.Context) ([]string, error) {
+		return []string{"20261101000000_add_runs_source"}, nil
+	})(context.Background())
+	if pending.State != StateDown || pending.Details["pending_migrations"] == nil {
+		t.Errorf("pending migrations: %+v", pending)
+	}
+
+	failed := Schema(func(context.Context) ([]string, error) { return nil, errors.New("db down") })(context.Background())
+	if failed.State != StateDown || failed.Error != "db down" {
+		t.Errorf("lookup error: %+v", failed)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	got := Disk(t.TempDir())(context.Background())
+	if got.State == StateDown && got.Error != "" {
+		t.Fatalf("temp dir should be readable: %+v", got)
+	}
+	if got.Details["total_bytes"] == nil || got.Details["free_bytes"] == nil {
+		t.Errorf("expected space details, got %+v", got.Details)
+	}
+
+	missing := Disk("/does/not/exist")(context.Background())
+	if missing.State != StateDown || missing.Error == "" {
+		t.Errorf("missing path: %+v", missing)
+	}
+}
+
+func TestProcessorRun(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		run  *models.Run
+		err  error
+		want State
+	}{
+		{name: "no run yet", want: StateOK},
+		{name: "lookup error", err: errors.New("db down"), want: StateDown},
+		{name: "succeeded", run: &models.Run{ID: "r1", Status: models.RunSucceeded, StartedAt: now.Unix(), FinishedAt: now.Unix()}, want: StateOK},
+		{name: "failed", run: &models.Run{ID: "r1", Status: models.RunFailed, Error: "boom", StartedAt: now.Unix()}, want: StateDegraded},
+		{name: "cancelled", run: &models.Run{ID: "r1", Status: models.RunCancelled, StartedAt: now.Unix()}, want: StateDegraded},
+		{name: "running", run: &models.Run{ID: "r1", Status: models.RunRunning, StartedAt: now.Unix()}, want: StateOK},
+		{name: "stale", run: &models.Run{ID: "r1", Status: models.RunRunning, StartedAt: now.Add(-2 * time.Hour).Unix()}, want: StateDegraded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := ProcessorRun(func(context.Context) (*models.Run, error) { return tt.run, tt.err }, time.Hour)
+			if got := check(context.Background()); got.State != tt.want {
+				t.Errorf("state = %s, want %s (%+v)", got.State, tt.want, got)
+			}
+		})
+	}
+}
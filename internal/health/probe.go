@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat records the last time a long-running worker made progress
+type Heartbeat struct {
+	last atomic.Int64
+}
+
+// NewHeartbeat creates a heartbeat that last beat now
+func NewHeartbeat() *Heartbeat {
+	hb := &Heartbeat{}
+	hb.Beat()
+	return hb
+}
+
+// Beat records progress
+func (hb *Heartbeat) Beat() {
+	hb.last.Store(time.Now().UnixNano())
+}
+
+// Last is the time of the last beat
+func (hb *Heartbeat) Last() time.Time {
+	return time.Unix(0, hb.last.Load())
+}
+
+// Progress reports a worker as down when its heartbeat is older than
+// stall: the worker is wedged and restarting it is the way out
+func Progress(hb *Heartbeat, stall time.Duration) Check {
+	return func(ctx context.Context) Result {
+		age := time.Since(hb.Last())
+		details := map[string]any{
+			"last_progress": hb.Last().UTC(),
+			"stall_after":   stall.String(),
+		}
+		if age > stall {
+			return Result{State: StateDown, Details: details, Error: fmt.Sprintf("no progress for %s", age.Round(time.Second))}
+		}
+		return Result{State: StateOK, Details: details}
+	}
+}
+
+// ProbeHandler serves Kubernetes probes for processes without the API
+// router: /healthz runs the liveness checks and /readyz the readiness
+// checks. Each answers 503 when a check is down and 200 otherwise.
+func ProbeHandler(live, ready *Checker) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", probe(live))
+	mux.Handle("GET /readyz", probe(ready))
+	return mux
+}
+
+func probe(checker *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Run(r.Context())
+
+		status := http.StatusOK
+		if report.Status == StateDown {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	hb := NewHeartbeat()
+	if got := Progress(hb, time.Minute)(context.Background()); got.State != StateOK {
+		t.Errorf("fresh heartbeat = %+v, want ok", got)
+	}
+
+	hb.last.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	if got := Progress(hb, time.Minute)(context.Background()); got.State != StateDown || got.Error == "" {
+		t.Errorf("stale heartbeat = %+v, want down with an error", got)
+	}
+
+	hb.Beat()
+	if got := Progress(hb, time.Minute)(context.Background()); got.State != StateOK {
+		t.Errorf("heartbeat after Beat = %+v, want ok", got)
+	}
+}
+
+func TestProbeHandler(t *testing.T) {
+	live := NewChecker(time.Second)
+	live.Register("progress", fixed(StateOK))
+	ready := NewChecker(time.Second)
+	ready.Register("database", fixed(StateDown))
+	handler := ProbeHandler(live, ready)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: http.MethodGet, path: "/healthz", want: http.StatusOK},
+		{method: http.MethodGet, path: "/readyz", want: http.StatusServiceUnavailable},
+		{method: http.MethodPost, path: "/healthz", want: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/metrics", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Package imports runs the imports started with POST /imports, so that a
+// data file no longer needs the processor binary run by hand: a CSV
+// uploaded with the request, or downloaded from S3, is stored under a
+// directory and imported by the processor in the background.
+//
+// An import is a run of kind import in the runs table, created before the
+// request is answered so its ID can be followed at once; the processor
+// updates it as for the imports of the import command. The stored file is
+// removed once the run finishes.
+package imports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxBytes is the size limit of a file
+	DefaultMaxBytes = 1 << 30
+	// UploadSource prefixes the file name of an upload in the runs table
+	UploadSource = "upload:"
+	// maxSource is the size of runs.source
+	maxSource = 500
+
+	// finishTimeout bounds recording the outcome of an import whose file
+	// could not be fetched
+	finishTimeout = 10 * time.Second
+)
+
+var (
+	// ErrRunning is returned by Start while an import of this instance is
+	// still running
+	ErrRunning = apperrors.New("an import is already running", apperrors.ErrConflict)
+	// ErrNoS3 is returned for an S3 URL when downloads are not configured
+	ErrNoS3 = apperrors.New("imports from S3 are not configured", apperrors.ErrValidation)
+	// ErrCancelled stops an import cancelled through the API
+	ErrCancelled = errors.New("cancellation requested")
+)
+
+// TooLargeError is a file over the size limit
+type TooLargeError struct {
+	MaxBytes int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("the file exceeds %d bytes", e.MaxBytes)
+}
+
+func (e *TooLargeError) Unwrap() error {
+	return apperrors.ErrTooLarge
+}
+
+// Details adds the limit to the error response
+func (e *TooLargeError) Details() map[string]any {
+	return map[string]any{"max_bytes": e.MaxBytes}
+}
+
+// RunFunc imports file as the run runID, recorded with source; the run is
+// already in the runs table
+type RunFunc func(ctx context.Context, runID, file, source string) error
+
+// Importer stores the files and imports them in the background, one at a
+// time
+type Importer struct {
+	dir      string
+	runs     repository.RunRepository
+	run      RunFunc
+	s3       *S3Source
+	maxBytes int64
+	logger   *zap.Logger
+
+	// ctx is the parent of the imports, cancelled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running bool
+	// current is the running import and cancelRun cancels it
+	current   string
+	cancelRun context.CancelCauseFunc
+}
+
+// Option customizes an Importer
+type Option func(*Importer)
+
+// WithMaxBytes sets the size limit of a file; n < 1 keeps the default
+func WithMaxBytes(n int64) Option {
+	return func(i *Importer) {
+		if n > 0 {
+			i.maxBytes = n
+		}
+	}
+}
+
+// WithS3 accepts s3:// URLs, downloaded from source
+func WithS3(source *S3Source) Option {
+	return func(i *Importer) {
+		i.s3 = source
+	}
+}
+
+// WithLogger logs the imports and the failed downloads
+func WithLogger(logger *zap.Logger) Option {
+	return func(i *Importer) {
+		i.logger = logger
+	}
+}
+
+// NewImporter stores the files under dir and imports them with run,
+// recording each import in runs
+func NewImporter(dir string, runs repository.RunRepository, run RunFunc, opts ...Option) *Importer {
+	i := &Importer{
+		dir:      dir,
+		runs:     runs,
+		run:      run,
+		maxBytes: DefaultMaxBytes,
+		logger:   zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.ctx, i.cancel = context.WithCancel(context.Background())
+	return i
+}
+
+// Start stores body, the file the client named name, records its import
+// and runs it in the background. It returns the run as recorded, or
+// ErrRunning while another import is running.
+func (i *Importer) Start(ctx context.Context, name string, body io.Reader) (*models.Run, error) {
+	if err := i.reserve(); err != nil {
+		return nil, err
+	}
+	run := i.newRun(uploadSource(name))
+	file, err := i.store(run.ID, body)
+	if err == nil {
+		err = i.runs.Create(ctx, run)
+	}
+	if err != nil {
+		os.Remove(file)
+		i.release()
+		return nil, err
+	}
+	i.logger.Info("import_received", zap.String("run_id", run.ID), zap.String("source", run.Source))
+	i.spawn(*run, file, nil)
+	return run, nil
+}
+
+// StartS3 records the import of the object at rawURL, s3://bucket/key,
+// and runs it in the background once downloaded. It returns the run as
+// recorded, or ErrRunning while another import is running.
+func (i *Importer) StartS3(ctx context.Context, rawURL string) (*models.Run, error) {
+	if i.s3 == nil {
+		return nil, ErrNoS3
+	}
+	bucket, key, err := ParseS3URL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.reserve(); err != nil {
+		return nil, err
+	}
+	run := i.newRun(rawURL)
+	if err := i.runs.Create(ctx, run); err != nil {
+		i.release()
+		return nil, err
+	}
+	file := i.path(run.ID)
+	i.spawn(*run, file, func(ctx context.Context) error {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := i.s3.Download(ctx, bucket, key, f, i.maxBytes); err != nil {
+			return err
+		}
+		return f.Close()
+	})
+	return run, nil
+}
+
+// Close cancels the running import, recorded as cancelled, and waits for
+// it to stop
+func (i *Importer) Close() {
+	i.cancel()
+	i.wg.Wait()
+}
+
+// Cancel cancels the import runID when it runs in this instance, recorded
+// as cancelled by the processor. It reports whether it was running here.
+func (i *Importer) Cancel(runID string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.current != runID || i.cancelRun == nil {
+		return false
+	}
+	i.cancelRun(ErrCancelled)
+	return true
+}
+
+// reserve takes the slot of the running import
+func (i *Importer) reserve() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.running {
+		return ErrRunning
+	}
+	if i.ctx.Err() != nil {
+		return errors.New("importer closed")
+	}
+	i.running = true
+	return nil
+}
+
+func (i *Importer) release() {
+	i.mu.Lock()
+	i.running = false
+	i.current, i.cancelRun = "", nil
+	i.mu.Unlock()
+}
+
+func (i *Importer) newRun(source string) *models.Run {
+	id := uuid.NewString()
+	return &models.Run{
+		ID:         id,
+		Kind:       models.RunImport,
+		JobID:      id,
+		Partitions: 1,
+		Source:     source,
+		Status:     models.RunRunning,
+		StartedAt:  time.Now().Unix(),
+	}
+}
+
+func (i *Importer) path(runID string) string {
+	return filepath.Join(i.dir, runID+".csv")
+}
+
+// store writes body to the file of the run, up to the size limit
+func (i *Importer) store(runID string, body io.Reader) (string, error) {
+	file := i.path(runID)
+	f, err := os.Create(file)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(body, i.maxBytes+1))
+	if err != nil {
+		return file, err
+	}
+	if n > i.maxBytes {
+		return file, &TooLargeError{MaxBytes: i.maxBytes}
+	}
+	return file, f.Close()
+}
+
+// spawn fetches the file of run, when fetch is set, and imports it in the
+// background, then removes it and frees the slot
+func (i *Importer) spawn(run models.Run, file string, fetch func(context.Context) error) {
+	ctx, cancel := context.WithCancelCause(i.ctx)
+	i.mu.Lock()
+	i.current, i.cancelRun = run.ID, cancel
+	i.mu.Unlock()
+
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		defer cancel(nil)
+		defer i.release()
+		defer os.Remove(file)
+
+		logger := i.logger.With(zap.String("run_id", run.ID))
+		if fetch != nil {
+			if err := fetch(ctx); err != nil {
+				i.fail(ctx, &run, err, logger)
+				return
+			}
+			logger.Info("import_downloaded", zap.String("source", run.Source))
+		}
+		// the processor records the outcome in the run
+		if err := i.run(ctx, run.ID, file, run.Source); err != nil {
+			logger.Warn("import_run_error", zap.Error(err))
+		}
+	}()
+}
+
+// fail records a run whose file could not be fetched
+func (i *Importer) fail(ctx context.Context, run *models.Run, err error, logger *zap.Logger) {
+	run.Status = models.RunFailed
+	run.Error = err.Error()
+	if ctx.Err() != nil {
+		run.Status = models.RunCancelled
+		if cause := context.Cause(ctx); errors.Is(cause, ErrCancelled) {
+			run.Error = cause.Error()
+		}
+	}
+	run.FinishedAt = time.Now().Unix()
+	logger.Error("import_download_failed", zap.String("source", run.Source), zap.Error(err))
+
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+	if err := i.runs.Update(updateCtx, run); err != nil {
+		logger.Error("import_run_update_error", zap.Error(err))
+	}
+}
+
+// uploadSource is the source of an upload in the runs table, its file name
+// without the directories some browsers send, cut to the size of the
+// column
+func uploadSource(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		name = ""
+	}
+	source := UploadSource + name
+	if len(source) > maxSource {
+		source = strings.ToValidUTF8(source[:maxSource], "")
+	}
+	return source
+}
+
+// ParseS3URL splits an s3://bucket/key URL
+func ParseS3URL(rawURL string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(rawURL, "s3://")
+	if ok {
+		bucket, key, ok = strings.Cut(rest, "/")
+	}
+	if !ok || bucket == "" || key == "" || strings.HasSuffix(key, "/") || len(rawURL) > maxSource {
+		return "", "", apperrors.New(fmt.Sprintf("invalid S3 URL %q: want s3://bucket/key", rawURL), apperrors.ErrValidation)
+	}
+	return bucket, key, nil
+}
@@ -0,0 +1,263 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/sigv4"
+)
+
+// memoryRuns keeps the last version of each run
+type memoryRuns struct {
+	repository.RunRepository
+	mu   sync.Mutex
+	byID map[string]models.Run
+}
+
+func (m *memoryRuns) Create(ctx context.Context, run *models.Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.byID == nil {
+		m.byID = map[string]models.Run{}
+	}
+	m.byID[run.ID] = *run
+	return nil
+}
+
+func (m *memoryRuns) Update(ctx context.Context, run *models.Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byID[run.ID] = *run
+	return nil
+}
+
+func (m *memoryRuns) get(id string) models.Run {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.byID[id]
+}
+
+// recorder is a RunFunc that keeps what it was given to import
+type recorder struct {
+	runID, source, content string
+	block                  bool
+	done                   chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{done: make(chan struct{})}
+}
+
+func (r *recorder) run(ctx context.Context, runID, file, source string) error {
+	defer close(r.done)
+	b, _ := os.ReadFile(file)
+	r.runID, r.source, r.content = runID, source, string(b)
+	if r.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestImporter_Upload(t *testing.T) {
+	dir := t.TempDir()
+	runs := &memoryRuns{}
+	rec := newRecorder()
+	i := NewImporter(dir, runs, rec.run)
+	defer i.Close()
+
+	run, err := i.Start(context.Background(), `C:\drops\daily.csv`, strings.NewReader("user_id,segmentation_type\n"))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := runs.get(run.ID); got.Kind != models.RunImport || got.Status != models.RunRunning || got.Source != "upload:daily.csv" {
+		t.Errorf("unexpected recorded run %+v", got)
+	}
+
+	<-rec.done
+	if rec.runID != run.ID || rec.source != run.Source || rec.content != "user_id,segmentation_type\n" {
+		t.Errorf("unexpected import of %q as %s: %q", rec.source, rec.runID, rec.content)
+	}
+	i.Close()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("the file should be removed after its run, found %v", entries)
+	}
+}
+
+func TestImporter_TooLarge(t *testing.T) {
+	dir := t.TempDir()
+	runs := &memoryRuns{}
+	i := NewImporter(dir, runs, newRecorder().run, WithMaxBytes(4))
+	defer i.Close()
+
+	_, err := i.Start(context.Background(), "big.csv", strings.NewReader("12345"))
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || !errors.Is(err, apperrors.ErrTooLarge) || tooLarge.MaxBytes != 4 {
+		t.Fatalf("Start() error = %v, want a TooLargeError", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 || len(runs.byID) != 0 {
+		t.Errorf("nothing should be kept of a refused file: %v, %v", entries, runs.byID)
+	}
+	// the slot is free again
+	if _, err := i.Start(context.Background(), "small.csv", strings.NewReader("1234")); err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
+
+func TestImporter_OneAtATimeAndClose(t *testing.T) {
+	rec := newRecorder()
+	rec.block = true
+	i := NewImporter(t.TempDir(), &memoryRuns{}, rec.run)
+
+	if _, err := i.Start(context.Background(), "a.csv", strings.NewReader("a")); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := i.Start(context.Background(), "b.csv", strings.NewReader("b")); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Start() error = %v, want ErrRunning", err)
+	}
+
+	i.Close()
+	select {
+	case <-rec.done:
+	default:
+		t.Error("Close() should wait for the running import")
+	}
+	if _, err := i.Start(context.Background(), "c.csv", strings.NewReader("c")); err == nil {
+		t.Error("Start() after Close() should fail")
+	}
+}
+
+func TestImporter_Cancel(t *testing.T) {
+	rec := newRecorder()
+	rec.block = true
+	i := NewImporter(t.TempDir(), &memoryRuns{}, rec.run)
+	defer i.Close()
+
+	run, err := i.Start(context.Background(), "a.csv", strings.NewReader("a"))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if i.Cancel("other-run") {
+		t.Error("Cancel() of a run of another instance should report false")
+	}
+	if !i.Cancel(run.ID) {
+		t.Fatal("Cancel() of the running import should report true")
+	}
+	select {
+	case <-rec.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the import was not cancelled")
+	}
+	// once stopped the run is no longer cancellable here
+	deadline := time.Now().Add(5 * time.Second)
+	for i.Cancel(run.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("the cancelled run still holds the slot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestImporter_S3(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("unsigned request %v", r.Header)
+		}
+		switch r.URL.Path {
+		case "/drops/partner/daily.csv":
+			w.Write([]byte("user_id\n1\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+		}
+	}))
+	defer srv.Close()
+	source, err := NewS3Source(S3Config{
+		Region:      "sa-east-1",
+		Endpoint:    srv.URL + "/",
+		Credentials: sigv4.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewS3Source() error = %v", err)
+	}
+
+	runs := &memoryRuns{}
+	rec := newRecorder()
+	i := NewImporter(t.TempDir(), runs, rec.run, WithS3(source))
+	defer i.Close()
+
+	run, err := i.StartS3(context.Background(), "s3://drops/partner/daily.csv")
+	if err != nil {
+		t.Fatalf("StartS3() error = %v", err)
+	}
+	<-rec.done
+	if rec.runID != run.ID || rec.source != "s3://drops/partner/daily.csv" || rec.content != "user_id\n1\n" {
+		t.Errorf("unexpected import of %q as %s: %q", rec.source, rec.runID, rec.content)
+	}
+	i.Close()
+
+	// a failed download is recorded by the importer, as the processor
+	// never runs
+	i = NewImporter(t.TempDir(), runs, newRecorder().run, WithS3(source))
+	defer i.Close()
+	run, err = i.StartS3(context.Background(), "s3://drops/partner/missing.csv")
+	if err != nil {
+		t.Fatalf("StartS3() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runs.get(run.ID).Status == models.RunRunning && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := runs.get(run.ID); got.Status != models.RunFailed || !strings.Contains(got.Error, "NoSuchKey") || got.FinishedAt == 0 {
+		t.Errorf("unexpected failed run %+v", got)
+	}
+}
+
+func TestImporter_S3NotConfigured(t *testing.T) {
+	i := NewImporter(t.TempDir(), &memoryRuns{}, newRecorder().run)
+	defer i.Close()
+	if _, err := i.StartS3(context.Background(), "s3://drops/daily.csv"); !errors.Is(err, ErrNoS3) {
+		t.Errorf("StartS3() error = %v, want ErrNoS3", err)
+	}
+}
+
+func TestParseS3URL(t *testing.T) {
+	bucket, key, err := ParseS3URL("s3://drops/partner/daily.csv")
+	if err != nil || bucket != "drops" || key != "partner/daily.csv" {
+		t.Errorf("ParseS3URL() = %q, %q, %v", bucket, key, err)
+	}
+	for _, raw := range []string{"https://drops/daily.csv", "s3://drops", "s3://drops/", "s3:///daily.csv", "s3://drops/partner/"} {
+		if _, _, err := ParseS3URL(raw); !errors.Is(err, apperrors.ErrValidation) {
+			t.Errorf("ParseS3URL(%q) error = %v, want a validation error", raw, err)
+		}
+	}
+}
+
+func TestS3Source_TooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+	source, _ := NewS3Source(S3Config{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: sigv4.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	}, srv.Client())
+	source.signer.Now = func() time.Time { return time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC) }
+
+	var b strings.Builder
+	var tooLarge *TooLargeError
+	if err := source.Download(context.Background(), "drops", "daily.csv", &b, 5); !errors.As(err, &tooLarge) {
+		t.Errorf("Download() error = %v, want a TooLargeError", err)
+	}
+}
@@ -0,0 +1,101 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"segmentation-api/internal/sigv4"
+)
+
+// S3Config locates the buckets of the s3:// URLs and holds static
+// credentials to read them
+type S3Config struct {
+	Region string
+	// Endpoint is the URL of an S3-compatible service such as MinIO,
+	// addressed path-style; empty uses AWS, addressed virtual-host style
+	Endpoint string
+	sigv4.Credentials
+}
+
+// S3Source downloads objects with a signed GET. Like the S3 sink of the
+// exports it only uses the standard library.
+type S3Source struct {
+	cfg    S3Config
+	http   *http.Client
+	signer sigv4.Signer
+}
+
+// NewS3Source downloads from the buckets of cfg through hc, or
+// http.DefaultClient when nil
+func NewS3Source(cfg S3Config, hc *http.Client) (*S3Source, error) {
+	if cfg.Region == "" {
+		return nil, errors.New("s3: region is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3: access key ID and secret access key are required")
+	}
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+		}
+		cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &S3Source{
+		cfg:    cfg,
+		http:   hc,
+		signer: sigv4.Signer{Credentials: cfg.Credentials, Region: cfg.Region, Service: "s3"},
+	}, nil
+}
+
+// Download writes the object key of bucket to w; an object over maxBytes
+// fails with a TooLargeError
+func (s *S3Source) Download(ctx context.Context, bucket, key string, w io.Writer, maxBytes int64) error {
+	raw := "https://" + bucket + ".s3." + s.cfg.Region + ".amazonaws.com"
+	if s.cfg.Endpoint != "" {
+		raw = s.cfg.Endpoint + "/" + bucket
+	}
+	target, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("s3: invalid bucket %q", bucket)
+	}
+	target.Path += "/" + key
+	target.RawPath = sigv4.URIEncode(target.Path, false)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	payloadHash := sigv4.PayloadHash(nil)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	s.signer.Sign(req, payloadHash)
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3: get %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("s3: get %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if resp.ContentLength > maxBytes {
+		return &TooLargeError{MaxBytes: maxBytes}
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return fmt.Errorf("s3: get %s: %w", key, err)
+	}
+	if n > maxBytes {
+		return &TooLargeError{MaxBytes: maxBytes}
+	}
+	return nil
+}
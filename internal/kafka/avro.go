@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"bytes"
+	"embed"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"segmentation-api/internal/models"
+)
+
+//go:embed schemas/*.avsc
+var schemaFiles embed.FS
+
+// eventSchemas names the schema file of each event type
+var eventSchemas = map[string]string{
+	models.OutboxUpserted: "schemas/segmentation_upserted.avsc",
+	models.OutboxDeleted:  "schemas/segmentation_deleted.avsc",
+}
+
+// schema is a parsed Avro record schema. Only what the event schemas use
+// is supported: fields of type long or string, optionally in a union with
+// null, and logical types over them.
+type schema struct {
+	// Name is the full name of the record, namespace included
+	Name string
+	// Text is the schema as registered, without spacing
+	Text   string
+	Fields []field
+}
+
+type field struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// loadSchemas parses the schema of every event type
+func loadSchemas() (map[string]*schema, error) {
+	out := make(map[string]*schema, len(eventSchemas))
+	for eventType, file := range eventSchemas {
+		raw, err := schemaFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		s, err := parseSchema(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		out[eventType] = s
+	}
+	return out, nil
+}
+
+func parseSchema(raw []byte) (*schema, error) {
+	var doc struct {
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		Fields    []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Type != "record" || doc.Name == "" {
+		return nil, fmt.Errorf("not a named record schema")
+	}
+	var text bytes.Buffer
+	if err := json.Compact(&text, raw); err != nil {
+		return nil, err
+	}
+
+	s := &schema{Name: doc.Name, Text: text.String()}
+	if doc.Namespace != "" {
+		s.Name = doc.Namespace + "." + doc.Name
+	}
+	for _, f := range doc.Fields {
+		typ, nullable, err := fieldType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		s.Fields = append(s.Fields, field{Name: f.Name, Type: typ, Nullable: nullable})
+	}
+	return s, nil
+}
+
+// fieldType returns the primitive type of a field and whether it is a
+// union with null
+func fieldType(raw json.RawMessage) (string, bool, error) {
+	var union []json.RawMessage
+	if json.Unmarshal(raw, &union) == nil {
+		if len(union) != 2 || string(union[0]) != `"null"` {
+			return "", false, fmt.Errorf("only unions of null and one type are supported")
+		}
+		typ, _, err := fieldType(union[1])
+		return typ, true, err
+	}
+
+	var typ string
+	if json.Unmarshal(raw, &typ) != nil {
+		// a logical type is written as its underlying type
+		var annotated struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &annotated); err != nil {
+			return "", false, err
+		}
+		typ = annotated.Type
+	}
+	if typ != "long" && typ != "string" {
+		return "", false, fmt.Errorf("unsupported type %q", typ)
+	}
+	return typ, false, nil
+}
+
+// encode writes values, by field name, in the Avro binary encoding of the
+// record: longs as int64, strings as string, and nil for the null of a
+// union
+func (s *schema) encode(values map[string]any) ([]byte, error) {
+	var buf []byte
+	for _, f := range s.Fields {
+		v := values[f.Name]
+		if f.Nullable {
+			if v == nil {
+				buf = appendLong(buf, 0)
+				continue
+			}
+			buf = appendLong(buf, 1)
+		}
+		switch f.Type {
+		case "long":
+			n, ok := v.(int64)
+			if !ok {
+				return nil, fmt.Errorf("field %s: want int64, got %T", f.Name, v)
+			}
+			buf = appendLong(buf, n)
+		case "string":
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("field %s: want string, got %T", f.Name, v)
+			}
+			buf = appendLong(buf, int64(len(str)))
+			buf = append(buf, str...)
+		}
+	}
+	return buf, nil
+}
+
+// appendLong appends n zig-zag encoded as a variable-length integer, as
+// Avro writes ints, longs and the lengths and union branches
+func appendLong(buf []byte, n int64) []byte {
+	return binary.AppendUvarint(buf, uint64(n<<1)^uint64(n>>63))
+}
+
+// wireFormat frames an encoded value as the Confluent serializers do: a
+// zero magic byte and the big-endian ID of the schema in the registry
+func wireFormat(schemaID int, value []byte) []byte {
+	out := make([]byte, 5, 5+len(value))
+	binary.BigEndian.PutUint32(out[1:], uint32(schemaID))
+	return append(out, value...)
+}
@@ -0,0 +1,246 @@
+// Package kafka publishes the change events of the outbox to a Kafka topic
+// through a Confluent REST Proxy. Each event is a record keyed by its user,
+// so the events of a user stay in order on one partition. Values are the
+// JSON of the webhook deliveries or, in the avro format, the records of
+// the schemas in schemas/ in the Confluent wire format, for the standard
+// Avro deserializers of the data platform. Each event type has its own
+// subject in the schema registry, "<topic>-<record name>" as the
+// TopicRecordNameStrategy names them; the schema is checked against the
+// subject and registered on the first publication of its type.
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/webhook"
+)
+
+// Formats of the record values
+const (
+	FormatJSON = "json"
+	FormatAvro = "avro"
+)
+
+const (
+	// maxErrorBytes is how much of a refusal's body is kept as its error
+	maxErrorBytes = 512
+	userAgent     = "segmentation-api-kafka"
+)
+
+// Config locates the REST Proxy and, for the avro format, the schema
+// registry. Compatibility is the level set on the subjects before their
+// schema is registered; empty leaves the level of the registry. Username
+// and Password authenticate to both, when set.
+type Config struct {
+	URL               string
+	Topic             string
+	Format            string
+	SchemaRegistryURL string
+	Compatibility     string
+	Username          string
+	Password          string
+}
+
+// Publisher is the outbox.Publisher of the Kafka topic
+type Publisher struct {
+	cfg      Config
+	client   *http.Client
+	schemas  map[string]*schema
+	registry *registry
+	logger   *zap.Logger
+}
+
+// Option customizes a Publisher
+type Option func(*Publisher)
+
+// WithTimeout bounds each request (default 10s)
+func WithTimeout(d time.Duration) Option {
+	return func(p *Publisher) {
+		if d > 0 {
+			p.client.Timeout = d
+		}
+	}
+}
+
+// WithLogger sets the logger of the schema registrations
+func WithLogger(logger *zap.Logger) Option {
+	return func(p *Publisher) {
+		p.logger = logger
+	}
+}
+
+// NewPublisher creates a publisher to the topic of cfg
+func NewPublisher(cfg Config, opts ...Option) (*Publisher, error) {
+	if cfg.URL == "" || cfg.Topic == "" {
+		return nil, errors.New("kafka: URL and topic are required")
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatJSON
+	}
+	p := &Publisher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: zap.NewNop(),
+	}
+	switch cfg.Format {
+	case FormatJSON:
+	case FormatAvro:
+		if cfg.SchemaRegistryURL == "" {
+			return nil, errors.New("kafka: the avro format needs a schema registry")
+		}
+		schemas, err := loadSchemas()
+		if err != nil {
+			return nil, fmt.Errorf("kafka: %w", err)
+		}
+		p.schemas = schemas
+	default:
+		return nil, fmt.Errorf("kafka: unknown format %q", cfg.Format)
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.schemas != nil {
+		p.registry = &registry{
+			url:           cfg.SchemaRegistryURL,
+			username:      cfg.Username,
+			password:      cfg.Password,
+			compatibility: cfg.Compatibility,
+			client:        p.client,
+			ids:           map[string]int{},
+		}
+	}
+	return p, nil
+}
+
+// Subject is the registry subject of the schema of an event type
+func (p *Publisher) Subject(eventType string) string {
+	if s, ok := p.schemas[eventType]; ok {
+		return p.cfg.Topic + "-" + s.Name
+	}
+	return ""
+}
+
+// record is a record of a produce request of the REST Proxy
+type record struct {
+	Key   any `json:"key"`
+	Value any `json:"value"`
+}
+
+// Publish produces a record per event, in one request
+func (p *Publisher) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	records := make([]record, len(events))
+	for i, e := range events {
+		key := strconv.FormatUint(e.UserID, 10)
+		if p.cfg.Format == FormatJSON {
+			records[i] = record{Key: key, Value: webhook.PayloadOf(e)}
+			continue
+		}
+		value, err := p.avroValue(ctx, e)
+		if err != nil {
+			return err
+		}
+		// the binary embedded format takes base64, as []byte encodes
+		records[i] = record{Key: []byte(key), Value: value}
+	}
+	return p.produce(ctx, records)
+}
+
+// avroValue is the event in the Confluent wire format
+func (p *Publisher) avroValue(ctx context.Context, e models.OutboxEvent) ([]byte, error) {
+	s, ok := p.schemas[e.EventType]
+	if !ok {
+		return nil, fmt.Errorf("kafka: no schema for event type %q", e.EventType)
+	}
+	subject := p.Subject(e.EventType)
+	id, err := p.registry.id(ctx, subject, s)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: %w", err)
+	}
+	p.logger.Debug("kafka_schema", zap.String("subject", subject), zap.Int("schema_id", id))
+
+	values := map[string]any{
+		"event_id":          int64(e.ID),
+		"user_id":           int64(e.UserID),
+		"segmentation_type": e.SegmentationType,
+		"segmentation_name": e.SegmentationName,
+		"occurred_at":       time.Unix(e.CreatedAt, 0).UnixMilli(),
+	}
+	if len(e.Data) > 0 {
+		values["data"] = string(e.Data)
+	}
+	if e.RunID != "" {
+		values["run_id"] = e.RunID
+	}
+	value, err := s.encode(values)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: event %d: %w", e.ID, err)
+	}
+	return wireFormat(id, value), nil
+}
+
+// produce POSTs records to the topic; a record the proxy could not write
+// fails the batch, which the outbox publishes again
+func (p *Publisher) produce(ctx context.Context, records []record) error {
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL+"/topics/"+url.PathEscape(p.cfg.Topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	contentType := "application/vnd.kafka.json.v2+json"
+	if p.cfg.Format == FormatAvro {
+		contentType = "application/vnd.kafka.binary.v2+json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	req.Header.Set("User-Agent", userAgent)
+	if p.cfg.Username != "" {
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka: REST proxy %w", newStatusError(resp))
+	}
+
+	var result struct {
+		Offsets []struct {
+			Partition int     `json:"partition"`
+			ErrorCode *int    `json:"error_code"`
+			Error     *string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("kafka: decode produce response: %w", err)
+	}
+	for i, o := range result.Offsets {
+		if o.ErrorCode != nil {
+			var msg string
+			if o.Error != nil {
+				msg = *o.Error
+			}
+			return fmt.Errorf("kafka: record %d of %d not written (error %d): %s", i+1, len(records), *o.ErrorCode, msg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/models"
+)
+
+// confluent serves the schema registry and the REST Proxy; incompatible
+// fails the compatibility checks and recordError the produced records
+type confluent struct {
+	mu            sync.Mutex
+	incompatible  bool
+	recordError   bool
+	compatibility map[string]string // subject → level
+	registered    []string          // subjects, in order
+	produced      []*http.Request
+	records       []record
+}
+
+func (c *confluent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	path := r.URL.EscapedPath()
+
+	switch {
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/config/"):
+		var req map[string]string
+		json.Unmarshal(body, &req)
+		c.compatibility[strings.TrimPrefix(path, "/config/")] = req["compatibility"]
+		w.Write(body)
+	case strings.HasPrefix(path, "/compatibility/"):
+		if len(c.registered) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error_code": 40401, "message": "Subject not found"}`)
+			return
+		}
+		if c.incompatible {
+			io.WriteString(w, `{"is_compatible": false, "messages": ["READER_FIELD_MISSING_DEFAULT_VALUE: data"]}`)
+			return
+		}
+		io.WriteString(w, `{"is_compatible": true}`)
+	case strings.HasPrefix(path, "/subjects/"):
+		var req map[string]string
+		if json.Unmarshal(body, &req) != nil || !json.Valid([]byte(req["schema"])) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		c.registered = append(c.registered, strings.TrimSuffix(strings.TrimPrefix(path, "/subjects/"), "/versions"))
+		json.NewEncoder(w).Encode(map[string]int{"id": 40 + len(c.registered)})
+	case strings.HasPrefix(path, "/topics/"):
+		var req struct {
+			Records []record `json:"records"`
+		}
+		json.Unmarshal(body, &req)
+		c.produced = append(c.produced, r)
+		c.records = append(c.records, req.Records...)
+		if c.recordError {
+			io.WriteString(w, `{"offsets": [{"partition": 0, "offset": 10}, {"partition": null, "offset": null, "error_code": 40403, "error": "Topic not authorized"}]}`)
+			return
+		}
+		io.WriteString(w, `{"offsets": [{"partition": 0, "offset": 10}, {"partition": 0, "offset": 11}]}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newConfluent(t *testing.T) (*confluent, *httptest.Server) {
+	c := &confluent{compatibility: map[string]string{}}
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+var events = []models.OutboxEvent{
+	{ID: 10, EventType: models.OutboxUpserted, UserID: 7, SegmentationType: "drug", SegmentationName: "Aspirina", Data: datatypes.JSON(`{"dose":"500mg"}`), CreatedAt: 1_700_000_000, RunID: "run-1"},
+	{ID: 11, EventType: models.OutboxDeleted, UserID: 7, SegmentationType: "drug", SegmentationName: "Dipirona", CreatedAt: 1_700_000_001},
+}
+
+func TestPublisher_Avro(t *testing.T) {
+	c, srv := newConfluent(t)
+	p, err := NewPublisher(Config{URL: srv.URL, Topic: "segmentation-events", Format: FormatAvro, SchemaRegistryURL: srv.URL, Compatibility: "BACKWARD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.Publish(ctx, events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	// the schemas are registered once
+	if err := p.Publish(ctx, events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	upserted := "segmentation-events-segmentation.v1.SegmentationUpserted"
+	if len(c.registered) != 2 || c.registered[0] != upserted || c.compatibility[upserted] != "BACKWARD" {
+		t.Errorf("registered %q with %v, want a subject per event type", c.registered, c.compatibility)
+	}
+	if got := c.produced[0].Header.Get("Content-Type"); got != "application/vnd.kafka.binary.v2+json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if len(c.records) != 4 || c.records[0].Key != "Nw==" {
+		t.Fatalf("unexpected records %+v", c.records)
+	}
+
+	value := decodeBase64(t, c.records[0].Value.(string))
+	if value[0] != 0 || binary.BigEndian.Uint32(value[1:5]) != 41 {
+		t.Fatalf("the value should start with the magic byte and the schema ID, got % x", value[:5])
+	}
+	var want []byte
+	want = appendLong(want, 10)
+	want = appendLong(want, 7)
+	want = append(appendLong(want, 4), "drug"...)
+	want = append(appendLong(want, 8), "Aspirina"...)
+	want = appendLong(want, 1) // the string branch of the union
+	want = append(appendLong(want, 16), `{"dose":"500mg"}`...)
+	want = appendLong(want, 1_700_000_000_000)
+	want = appendLong(want, 1)
+	want = append(appendLong(want, 5), "run-1"...)
+	if !bytes.Equal(value[5:], want) {
+		t.Errorf("value = % x, want % x", value[5:], want)
+	}
+	deleted := decodeBase64(t, c.records[1].Value.(string))
+	if binary.BigEndian.Uint32(deleted[1:5]) != 42 {
+		t.Errorf("the delete should carry the ID of its own schema, got % x", deleted[:5])
+	}
+	if deleted[len(deleted)-1] != 0 {
+		t.Errorf("an event without run should end with the null branch, got % x", deleted)
+	}
+}
+
+func TestPublisher_Incompatible(t *testing.T) {
+	c, srv := newConfluent(t)
+	c.registered = []string{"an earlier version"}
+	c.incompatible = true
+	p, _ := NewPublisher(Config{URL: srv.URL, Topic: "segmentation-events", Format: FormatAvro, SchemaRegistryURL: srv.URL})
+
+	err := p.Publish(context.Background(), events)
+	if !errors.Is(err, ErrIncompatible) || !strings.Contains(err.Error(), "READER_FIELD_MISSING_DEFAULT_VALUE") {
+		t.Errorf("Publish() error = %v, want ErrIncompatible with the reason", err)
+	}
+	if len(c.produced) != 0 || len(c.compatibility) != 0 {
+		t.Error("nothing should be produced, nor the compatibility level changed when none is configured")
+	}
+}
+
+func TestPublisher_JSON(t *testing.T) {
+	c, srv := newConfluent(t)
+	p, _ := NewPublisher(Config{URL: srv.URL, Topic: "segmentation-events"})
+	if err := p.Publish(context.Background(), events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := c.produced[0].Header.Get("Content-Type"); got != "application/vnd.kafka.json.v2+json" || len(c.registered) != 0 {
+		t.Errorf("Content-Type = %q, registered %q", got, c.registered)
+	}
+	value := c.records[0].Value.(map[string]any)
+	if c.records[0].Key != "7" || value["event_id"] != 10.0 || value["event_type"] != models.OutboxUpserted || value["run_id"] != "run-1" {
+		t.Errorf("unexpected record %+v", c.records[0])
+	}
+}
+
+func TestPublisher_RecordError(t *testing.T) {
+	c, srv := newConfluent(t)
+	c.recordError = true
+	p, _ := NewPublisher(Config{URL: srv.URL, Topic: "segmentation-events"})
+	if err := p.Publish(context.Background(), events); err == nil || !strings.Contains(err.Error(), "record 2 of 2") {
+		t.Errorf("Publish() error = %v, want the refused record", err)
+	}
+}
+
+func TestNewPublisher_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{Topic: "segmentation-events"},
+		{URL: "http://proxy:8082", Topic: "segmentation-events", Format: "protobuf"},
+		{URL: "http://proxy:8082", Topic: "segmentation-events", Format: FormatAvro},
+	} {
+		if _, err := NewPublisher(cfg); err == nil {
+			t.Errorf("NewPublisher(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	schemas, err := loadSchemas()
+	if err != nil {
+		t.Fatalf("loadSchemas() error = %v", err)
+	}
+	for _, eventType := range models.OutboxEventTypes {
+		if schemas[eventType] == nil {
+			t.Errorf("no schema for %s", eventType)
+		}
+	}
+	if _, err := parseSchema([]byte(`{"type": "record", "name": "X", "fields": [{"name": "a", "type": "double"}]}`)); err == nil {
+		t.Error("unsupported types should be refused")
+	}
+	if _, err := schemas[models.OutboxDeleted].encode(map[string]any{"event_id": "10"}); err == nil {
+		t.Error("a value of the wrong type should be refused")
+	}
+}
+
+func TestAppendLong(t *testing.T) {
+	// the examples of the Avro specification
+	for n, want := range map[int64][]byte{0: {0x00}, -1: {0x01}, 1: {0x02}, -2: {0x03}, 2: {0x04}, -64: {0x7f}, 64: {0x80, 0x01}} {
+		if got := appendLong(nil, n); !bytes.Equal(got, want) {
+			t.Errorf("appendLong(%d) = % x, want % x", n, got, want)
+		}
+	}
+}
+
+func decodeBase64(t *testing.T, s string) []byte {
+	t.Helper()
+	var b []byte
+	if err := json.Unmarshal([]byte(`"`+s+`"`), &b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// ErrIncompatible is returned when the registry refuses a schema as
+// incompatible with the versions of its subject
+var ErrIncompatible = errors.New("kafka: schema is incompatible with the registered versions")
+
+// registry is a client of the Confluent Schema Registry API, which caches
+// the ID of each registered schema
+type registry struct {
+	url           string
+	username      string
+	password      string
+	compatibility string
+	client        *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int // subject → ID of our schema
+}
+
+// id returns the ID of s under subject, registering it the first time.
+// Before that the compatibility level of the subject is set, and s is
+// checked against the latest version so that an incompatible change is
+// reported with the reasons of the registry.
+func (r *registry) id(ctx context.Context, subject string, s *schema) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.ids[subject]; ok {
+		return id, nil
+	}
+
+	path := "/subjects/" + url.PathEscape(subject)
+	if r.compatibility != "" {
+		body := map[string]string{"compatibility": r.compatibility}
+		if err := r.do(ctx, http.MethodPut, "/config/"+url.PathEscape(subject), body, nil); err != nil {
+			return 0, fmt.Errorf("set compatibility of %s: %w", subject, err)
+		}
+	}
+
+	var check struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages"`
+	}
+	err := r.do(ctx, http.MethodPost, "/compatibility"+path+"/versions/latest?verbose=true", map[string]string{"schema": s.Text}, &check)
+	var status *statusError
+	switch {
+	case errors.As(err, &status) && status.Code == http.StatusNotFound:
+		// the first version of the subject
+	case err != nil:
+		return 0, fmt.Errorf("check compatibility of %s: %w", subject, err)
+	case !check.IsCompatible:
+		return 0, fmt.Errorf("%w: %s: %s", ErrIncompatible, subject, strings.Join(check.Messages, "; "))
+	}
+
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, path+"/versions", map[string]string{"schema": s.Text}, &registered); err != nil {
+		return 0, fmt.Errorf("register %s: %w", subject, err)
+	}
+	r.ids[subject] = registered.ID
+	return registered.ID, nil
+}
+
+func (r *registry) do(ctx context.Context, method, path string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", registryContentType)
+	req.Header.Set("Accept", registryContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newStatusError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// statusError is an answer other than 2xx of the registry or the proxy
+type statusError struct {
+	Code    int
+	Message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("answered %d: %s", e.Code, e.Message)
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	var doc struct {
+		Message string `json:"message"`
+	}
+	msg := string(bytes.TrimSpace(body))
+	if json.Unmarshal(body, &doc) == nil && doc.Message != "" {
+		msg = doc.Message
+	}
+	return &statusError{Code: resp.StatusCode, Message: msg}
+}
@@ -0,0 +1,95 @@
+// Package loadgen generates synthetic data files for the processor and
+// drives the API with concurrent reads, so performance changes can be
+// measured. It backs the cmd/loadgen tool and the make bench targets.
+package loadgen
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strconv"
+)
+
+// segmentationTypes are the types spread over the generated rows
+var segmentationTypes = []string{"drug", "specialty", "patient"}
+
+// CSVConfig sizes a generated data file
+type CSVConfig struct {
+	// Rows is the number of data rows, without the header
+	Rows int
+	// Users is the number of distinct user IDs the rows are spread over,
+	// starting at FirstUserID
+	Users       int
+	FirstUserID uint64
+	// Names is the number of distinct segmentation names of each type
+	Names int
+	// DataSize is the approximate size in bytes of the data column
+	DataSize int
+	// Seed makes the file reproducible: the same config always yields the
+	// same rows
+	Seed int64
+}
+
+func (c CSVConfig) validate() error {
+	switch {
+	case c.Rows < 0:
+		return errors.New("rows must not be negative")
+	case c.Users <= 0:
+		return errors.New("users must be positive")
+	case c.Names <= 0:
+		return errors.New("names must be positive")
+	case c.DataSize < 0:
+		return errors.New("data size must not be negative")
+	}
+	return nil
+}
+
+// WriteCSV writes a data file in the processor's format (user_id,
+// segmentation_type, segmentation_name, data). Users and names are drawn
+// uniformly, so with fewer users × types × names than rows some rows
+// update earlier ones, as in a real file with repeated keys.
+func WriteCSV(w io.Writer, cfg CSVConfig) error {
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("loadgen: %w", err)
+	}
+
+	seed := uint64(cfg.Seed)
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	buf := bufio.NewWriter(w)
+	out := csv.NewWriter(buf)
+
+	if err := out.Write([]string{"user_id", "segmentation_type", "segmentation_name", "data"}); err != nil {
+		return err
+	}
+	row := make([]string, 4)
+	for i := 0; i < cfg.Rows; i++ {
+		row[0] = strconv.FormatUint(cfg.FirstUserID+uint64(rnd.IntN(cfg.Users)), 10)
+		row[1] = segmentationTypes[rnd.IntN(len(segmentationTypes))]
+		row[2] = "Segment " + strconv.Itoa(1+rnd.IntN(cfg.Names))
+		row[3] = data(rnd, cfg.DataSize)
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// data builds a JSON object of about size bytes
+func data(rnd *rand.Rand, size int) string {
+	obj := `{"count":` + strconv.Itoa(rnd.IntN(1000))
+	if pad := size - len(obj) - len(`,"pad":""}`); pad > 0 {
+		b := make([]byte, pad)
+		for i := range b {
+			b[i] = 'a' + byte(rnd.IntN(26))
+		}
+		obj += `,"pad":"` + string(b) + `"`
+	}
+	return obj + "}"
+}
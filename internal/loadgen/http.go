@@ -0,0 +1,193 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadConfig drives the API with concurrent GET /users/{id}/segmentations
+type LoadConfig struct {
+	// BaseURL is the API address, e.g. http://localhost:8080
+	BaseURL string
+	// Concurrency is the number of clients sending requests one after the
+	// other
+	Concurrency int
+	// Duration stops the run; Requests stops it after that many requests
+	// when set, whichever comes first
+	Duration time.Duration
+	Requests int
+	// Users is the number of user IDs requested, from FirstUserID on, so
+	// the run hits the rows imported from a file of the same cardinality
+	Users       int
+	FirstUserID uint64
+	Seed        int64
+}
+
+func (c LoadConfig) validate() error {
+	switch {
+	case c.BaseURL == "":
+		return errors.New("base URL is required")
+	case c.Concurrency <= 0:
+		return errors.New("concurrency must be positive")
+	case c.Duration <= 0 && c.Requests <= 0:
+		return errors.New("a duration or a number of requests is required")
+	case c.Users <= 0:
+		return errors.New("users must be positive")
+	}
+	return nil
+}
+
+// Report summarizes a load run. Latencies cover every answered request,
+// whatever its status; transport failures are counted in Errors only.
+type Report struct {
+	Requests int
+	Errors   int
+	Statuses map[int]int
+	Elapsed  time.Duration
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+// Throughput is the number of requests per second
+func (r *Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// Write prints the report in a human readable form
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "requests:   %d in %s (%.1f req/s)\n", r.Requests, r.Elapsed.Round(time.Millisecond), r.Throughput())
+	fmt.Fprintf(w, "errors:     %d\n", r.Errors)
+	codes := make([]int, 0, len(r.Statuses))
+	for code := range r.Statuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	statuses := make([]string, 0, len(codes))
+	for _, code := range codes {
+		statuses = append(statuses, fmt.Sprintf("%d=%d", code, r.Statuses[code]))
+	}
+	fmt.Fprintf(w, "statuses:   %s\n", strings.Join(statuses, " "))
+	fmt.Fprintf(w, "latency:    p50=%s p90=%s p99=%s max=%s\n", r.P50, r.P90, r.P99, r.Max)
+}
+
+// Run sends requests until cfg.Duration elapses, cfg.Requests were sent
+// or ctx ends, and reports throughput and latency. client may be nil to
+// use a client with keep-alive sized for cfg.Concurrency.
+func Run(ctx context.Context, client *http.Client, cfg LoadConfig) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("loadgen: %w", err)
+	}
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = cfg.Concurrency
+		client = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	}
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+	base := strings.TrimRight(cfg.BaseURL, "/")
+
+	var (
+		mu        sync.Mutex
+		report    = &Report{Statuses: map[int]int{}}
+		latencies []time.Duration
+		sent      int
+		wg        sync.WaitGroup
+	)
+	// next reserves the next request; false when the budget is spent
+	next := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if cfg.Requests > 0 && sent >= cfg.Requests {
+			return false
+		}
+		sent++
+		return true
+	}
+
+	start := time.Now()
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			seed := uint64(cfg.Seed) + uint64(worker)
+			rnd := rand.New(rand.NewPCG(seed, seed))
+			for ctx.Err() == nil && next() {
+				userID := cfg.FirstUserID + uint64(rnd.IntN(cfg.Users))
+				url := base + "/users/" + strconv.FormatUint(userID, 10) + "/segmentations"
+				status, latency, err := get(ctx, client, url)
+				if err != nil && ctx.Err() != nil {
+					return // cut short by the end of the run, not a failure
+				}
+
+				mu.Lock()
+				report.Requests++
+				if err != nil {
+					report.Errors++
+				} else {
+					report.Statuses[status]++
+					latencies = append(latencies, latency)
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+
+	slices.Sort(latencies)
+	report.P50 = percentile(latencies, 50)
+	report.P90 = percentile(latencies, 90)
+	report.P99 = percentile(latencies, 99)
+	if n := len(latencies); n > 0 {
+		report.Max = latencies[n-1]
+	}
+	return report, nil
+}
+
+// get sends one request and reads the whole body, which is part of the
+// latency the API's clients see
+func get(ctx context.Context, client *http.Client, url string) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	begin := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0, 0, err
+	}
+	return resp.StatusCode, time.Since(begin), nil
+}
+
+// percentile of sorted latencies, nearest rank
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	if i < 1 {
+		i = 1
+	}
+	return sorted[i-1]
+}
@@ -0,0 +1,123 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteCSV(t *testing.T) {
+	cfg := CSVConfig{Rows: 500, Users: 10, FirstUserID: 100, Names: 3, DataSize: 64, Seed: 7}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, cfg); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("generated file is not valid CSV: %v", err)
+	}
+	if len(rows) != cfg.Rows+1 || strings.Join(rows[0], ",") != "user_id,segmentation_type,segmentation_name,data" {
+		t.Fatalf("got %d rows with header %v", len(rows), rows[0])
+	}
+
+	users := map[string]bool{}
+	for _, row := range rows[1:] {
+		id, err := strconv.ParseUint(row[0], 10, 64)
+		if err != nil || id < 100 || id >= 110 {
+			t.Fatalf("user_id %q outside [100, 110)", row[0])
+		}
+		users[row[0]] = true
+		if !json.Valid([]byte(row[3])) {
+			t.Fatalf("data %q is not valid JSON", row[3])
+		}
+		if n := len(row[3]); n < 60 || n > 68 {
+			t.Errorf("data has %d bytes, want about 64", n)
+		}
+	}
+	if len(users) != cfg.Users {
+		t.Errorf("distinct users = %d, want %d", len(users), cfg.Users)
+	}
+
+	var again bytes.Buffer
+	if err := WriteCSV(&again, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("the same config wrote different files")
+	}
+}
+
+func TestWriteCSV_InvalidConfig(t *testing.T) {
+	if err := WriteCSV(&bytes.Buffer{}, CSVConfig{Rows: 1, Users: 0, Names: 1}); err == nil {
+		t.Error("expected an error for zero users")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !strings.HasPrefix(r.URL.Path, "/users/") || !strings.HasSuffix(r.URL.Path, "/segmentations") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/users/1/segmentations" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"segmentations":{}}`))
+	}))
+	defer srv.Close()
+
+	report, err := Run(context.Background(), srv.Client(), LoadConfig{
+		BaseURL:     srv.URL + "/",
+		Concurrency: 4,
+		Duration:    time.Minute,
+		Requests:    200,
+		Users:       5,
+		FirstUserID: 1,
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if report.Requests != 200 || hits.Load() != 200 || report.Errors != 0 {
+		t.Fatalf("requests = %d, hits = %d, errors = %d, want 200, 200 and 0", report.Requests, hits.Load(), report.Errors)
+	}
+	if report.Statuses[http.StatusOK]+report.Statuses[http.StatusNotFound] != 200 || report.Statuses[http.StatusNotFound] == 0 {
+		t.Errorf("unexpected statuses: %v", report.Statuses)
+	}
+	if report.P50 <= 0 || report.P50 > report.P99 || report.P99 > report.Max || report.Throughput() <= 0 {
+		t.Errorf("inconsistent latencies: %+v", report)
+	}
+
+	var out bytes.Buffer
+	report.Write(&out)
+	if !strings.Contains(out.String(), "requests:   200") || !strings.Contains(out.String(), "404=") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+	for p, want := range map[int]time.Duration{50: 50, 90: 90, 99: 99, 100: 100} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%d) = %d, want %d", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of nothing = %d, want 0", got)
+	}
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Rotation of the slow query file
+const (
+	slowQueryMaxSizeMB  = 100
+	slowQueryMaxBackups = 5
+	slowQueryMaxAgeDays = 30
+)
+
+// NewSlowQueryLogger builds the slow query channel: JSON entries written to
+// a size-rotated file, separate from the application log so it can be
+// analyzed on its own. cfg.SlowQueryLog sets the file (default
+// <cfg.Dir>/slow-queries.log, or stdout for no file) and
+// cfg.SlowQueryThreshold the minimum duration (0 disables slow query
+// logging). The returned closer releases the file.
+func NewSlowQueryLogger(cfg config.Log) (*zap.Logger, time.Duration, io.Closer, error) {
+	threshold := cfg.SlowQueryThreshold
+	if threshold < 0 {
+		return nil, 0, nil, fmt.Errorf("invalid slow query threshold %s", threshold)
+	}
+
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.TimeKey = "time"
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoder := zapcore.NewJSONEncoder(encCfg)
+
+	path := cfg.SlowQueryLog
+	if path == "stdout" {
+		core := zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), zapcore.InfoLevel)
+		return zap.New(core), threshold, nopCloser{}, nil
+	}
+	if path == "" {
+		path = filepath.Join(cfg.Dir, "slow-queries.log")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, 0, nil, err
+	}
+
+	file := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    slowQueryMaxSizeMB,
+		MaxBackups: slowQueryMaxBackups,
+		MaxAge:     slowQueryMaxAgeDays,
+	}
+	core := zapcore.NewCore(encoder, zapcore.AddSync(file), zapcore.InfoLevel)
+
+	return zap.New(core), threshold, file, nil
+}
+
+// GormLogger implements GORM's logger. Query errors go to the application
+// logger; queries slower than the threshold go to the slow query logger
+// with the SQL, duration and affected rows as fields.
+type GormLogger struct {
+	app       *zap.Logger
+	slow      *zap.Logger
+	threshold time.Duration
+	level     gormlogger.LogLevel
+}
+
+// NewGorm creates a GORM logger. A nil slow logger or a zero threshold
+// disables slow query logging.
+func NewGorm(app, slow *zap.Logger, threshold time.Duration, level gormlogger.LogLevel) *GormLogger {
+	if slow == nil {
+		slow = zap.NewNop()
+	}
+	return &GormLogger{
+		app:       app.Named("gorm").WithOptions(zap.WithCaller(false)),
+		slow:      slow,
+		threshold: threshold,
+		level:     level,
+	}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	c := *l
+	c.level = level
+	return &c
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.app.Sugar().Infof(msg, args...)
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.app.Sugar().Warnf(msg, args...)
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.app.Sugar().Errorf(msg, args...)
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	slow := l.threshold > 0 && elapsed > l.threshold
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error
+
+	if !slow && !failed && l.level < gormlogger.Info {
+		return
+	}
+
+	sql, rows := fc()
+	fields := []zap.Field{
+		zap.String("sql", sql),
+		zap.Duration("duration", elapsed),
+		zap.Int64("rows", rows),
+	}
+
+	if slow {
+		l.slow.Warn("slow_query", append(fields, zap.Duration("threshold", l.threshold))...)
+	}
+	switch {
+	case failed:
+		l.app.Error("query_error", append(fields, zap.Error(err))...)
+	case l.level >= gormlogger.Info:
+		l.app.Debug("query", fields...)
+	}
+}
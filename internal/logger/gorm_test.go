@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGormLogger_Trace(t *testing.T) {
+	query := func() (string, int64) { return "SELECT * FROM segmentations", 3 }
+
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		err       error
+		level     gormlogger.LogLevel
+		wantSlow  int
+		wantError int
+	}{
+		{name: "fast query", elapsed: time.Millisecond, level: gormlogger.Warn},
+		{name: "slow query", elapsed: 2 * time.Second, level: gormlogger.Warn, wantSlow: 1},
+		{name: "slow query at error level", elapsed: 2 * time.Second, level: gormlogger.Error, wantSlow: 1},
+		{name: "failed query", elapsed: time.Millisecond, err: errors.New("deadlock"), level: gormlogger.Error, wantError: 1},
+		{name: "record not found is ignored", elapsed: time.Millisecond, err: gorm.ErrRecordNotFound, level: gormlogger.Error},
+		{name: "silent", elapsed: 2 * time.Second, err: errors.New("deadlock"), level: gormlogger.Silent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appCore, appLogs := observer.New(zapcore.DebugLevel)
+			slowCore, slowLogs := observer.New(zapcore.DebugLevel)
+			l := NewGorm(zap.New(appCore), zap.New(slowCore), time.Second, tt.level)
+
+			l.Trace(context.Background(), time.Now().Add(-tt.elapsed), query, tt.err)
+
+			if got := slowLogs.FilterMessage("slow_query").Len(); got != tt.wantSlow {
+				t.Errorf("slow_query entries = %d, want %d", got, tt.wantSlow)
+			}
+			if got := appLogs.FilterMessage("query_error").Len(); got != tt.wantError {
+				t.Errorf("query_error entries = %d, want %d", got, tt.wantError)
+			}
+			if appLogs.FilterMessage("slow_query").Len() != 0 {
+				t.Error("slow queries must not reach the application log")
+			}
+
+			if tt.wantSlow > 0 {
+				fields := slowLogs.All()[0].ContextMap()
+				if fields["sql"] != "SELECT * FROM segmentations" || fields["rows"] != int64(3) {
+					t.Errorf("unexpected slow query fields: %v", fields)
+				}
+			}
+		})
+	}
+}
+
+func TestNewSlowQueryLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db", "slow.log")
+	logger, threshold, closer, err := NewSlowQueryLogger(config.Log{
+		SlowQueryLog:       path,
+		SlowQueryThreshold: 250 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewSlowQueryLogger() error = %v", err)
+	}
+	defer closer.Close()
+
+	if threshold != 250*time.Millisecond {
+		t.Errorf("threshold = %s, want 250ms", threshold)
+	}
+
+	logger.Warn("slow_query", zap.String("sql", "SELECT 1"))
+	logger.Sync()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("slow query file should exist: %v", err)
+	}
+	if len(content) == 0 {
+		t.Error("slow query file should contain the entry")
+	}
+}
+
+func TestNewSlowQueryLogger_InvalidThreshold(t *testing.T) {
+	_, _, _, err := NewSlowQueryLogger(config.Log{
+		SlowQueryLog:       filepath.Join(t.TempDir(), "slow.log"),
+		SlowQueryThreshold: -time.Second,
+	})
+	if err == nil {
+		t.Error("NewSlowQueryLogger() should reject an invalid threshold")
+	}
+}
+
+func TestNewSlowQueryLogger_Stdout(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs")
+
+	_, _, closer, err := NewSlowQueryLogger(config.Log{Dir: logDir, SlowQueryLog: "stdout"})
+	if err != nil {
+		t.Fatalf("NewSlowQueryLogger() error = %v", err)
+	}
+	if err := closer.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(logDir); !os.IsNotExist(err) {
+		t.Errorf("no file should be created, stat error = %v", err)
+	}
+}
@@ -1,38 +1,86 @@
 package logger
 
 import (
-	"io"
-	"log"
-	"os"
-	"path/filepath"
-	"time"
+	"fmt"
+	"strings"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-func New() (*log.Logger, *os.File, error) {
-	logDir := os.Getenv("LOG_DIR")
-	logOut := os.Getenv("PRINTLOG")
-	if logDir == "" {
-		logDir = "./logs"
+// New builds the application logger at cfg.Level. See NewWithLevel.
+func New(cfg config.Log) (*zap.Logger, *Output, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
 	}
+	return NewWithLevel(cfg, level)
+}
 
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, nil, err
+// ParseLevel parses debug, info, warn or error (empty means info) into a
+// level that can be changed while the process runs
+func ParseLevel(raw string) (zap.AtomicLevel, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return zap.NewAtomicLevelAt(zapcore.InfoLevel), nil
+	}
+	level, err := zap.ParseAtomicLevel(strings.ToLower(raw))
+	if err != nil {
+		return level, fmt.Errorf("invalid log level %q", raw)
 	}
+	return level, nil
+}
 
-	filename := time.Now().Format("2006-01-02T15-04-05") + "-processor.log"
-	path := filepath.Join(logDir, filename)
+// NewWithLevel builds the application logger. Entries enabled by level are
+// written to every sink of cfg.Sinks (see sinkNames); by default a
+// timestamped file in cfg.Dir, plus stdout when cfg.PrintLog is set.
+// cfg.Format selects the encoding: text (alias console, the default) for
+// humans or json for one object per line (time, level, caller, msg and the
+// entry fields) that log collectors can ingest without parsing.
+// The returned Output must be closed on exit.
+func NewWithLevel(cfg config.Log, level zap.AtomicLevel) (*zap.Logger, *Output, error) {
+	if _, err := newEncoder(cfg.Format); err != nil {
+		return nil, nil, err
+	}
 
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	names, err := sinkNames(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
-	var multi io.Writer
-	multi = file
-	if logOut == "true" {
-		multi = io.MultiWriter(os.Stdout, file)
+
+	out := &Output{}
+	cores := make([]zapcore.Core, 0, len(names))
+	for _, name := range names {
+		s, err := sinkOpeners[name](cfg, level)
+		if err != nil {
+			out.Close()
+			return nil, nil, fmt.Errorf("log sink %s: %w", name, err)
+		}
+		out.add(s)
+		cores = append(cores, s.core)
 	}
 
-	logger := log.New(multi, "", log.LstdFlags|log.Lmicroseconds)
+	logger := zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	return logger, out, nil
+}
+
+func newEncoder(format string) (zapcore.Encoder, error) {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.TimeKey = "time"
+	cfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	return newEncoderWith(format, cfg)
+}
 
-	return logger, file, nil
+func newEncoderWith(format string, cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text", "console":
+		cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		return zapcore.NewConsoleEncoder(cfg), nil
+	case "json":
+		return zapcore.NewJSONEncoder(cfg), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
 }
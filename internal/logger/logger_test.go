@@ -1,9 +1,19 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestLoggerPackageExists(t *testing.T) {
@@ -12,9 +22,8 @@ func TestLoggerPackageExists(t *testing.T) {
 
 func TestNew_CreatesLogDirectory(t *testing.T) {
 	tmpDir := t.TempDir()
-	t.Setenv("LOG_DIR", tmpDir)
 
-	logger, file, err := New()
+	logger, file, err := New(config.Log{Dir: tmpDir})
 	if err != nil {
 		t.Fatalf("New() should not return error: %v", err)
 	}
@@ -24,7 +33,7 @@ func TestNew_CreatesLogDirectory(t *testing.T) {
 		t.Error("New() should return non-nil logger")
 	}
 	if file == nil {
-		t.Error("New() should return non-nil file")
+		t.Error("New() should return non-nil output")
 	}
 
 	if _, err := os.Stat(tmpDir); os.IsNotExist(err) {
@@ -34,9 +43,8 @@ func TestNew_CreatesLogDirectory(t *testing.T) {
 
 func TestNew_CreatesLogFile(t *testing.T) {
 	tmpDir := t.TempDir()
-	t.Setenv("LOG_DIR", tmpDir)
 
-	logger, file, err := New()
+	logger, file, err := New(config.Log{Dir: tmpDir})
 	if err != nil {
 		t.Fatalf("New() should not return error: %v", err)
 	}
@@ -46,7 +54,7 @@ func TestNew_CreatesLogFile(t *testing.T) {
 		t.Fatal("logger should not be nil")
 	}
 
-	fileInfo, err := os.Stat(file.Name())
+	fileInfo, err := os.Stat(file.Path())
 	if err != nil {
 		t.Fatalf("Log file should exist: %v", err)
 	}
@@ -59,9 +67,8 @@ func TestNew_CreatesLogFile(t *testing.T) {
 func TestNew_WithCustomLogDir(t *testing.T) {
 	tmpDir := t.TempDir()
 	customDir := filepath.Join(tmpDir, "custom", "logs")
-	t.Setenv("LOG_DIR", customDir)
 
-	logger, file, err := New()
+	logger, file, err := New(config.Log{Dir: customDir})
 	if err != nil {
 		t.Fatalf("New() should not return error: %v", err)
 	}
@@ -78,19 +85,18 @@ func TestNew_WithCustomLogDir(t *testing.T) {
 
 func TestNew_FileCanBeWritten(t *testing.T) {
 	tmpDir := t.TempDir()
-	t.Setenv("LOG_DIR", tmpDir)
 
-	_, file, err := New()
+	logger, file, err := New(config.Log{Dir: tmpDir})
 	if err != nil {
 		t.Fatalf("New() should not return error: %v", err)
 	}
 	defer file.Close()
 
 	// Test writing to file through logger
-	content := "test message"
-	file.WriteString(content + "\n")
+	logger.Info("test message")
+	logger.Sync()
 
-	fileContent, err := os.ReadFile(file.Name())
+	fileContent, err := os.ReadFile(file.Path())
 	if err != nil {
 		t.Fatalf("should be able to read log file: %v", err)
 	}
@@ -101,16 +107,13 @@ func TestNew_FileCanBeWritten(t *testing.T) {
 }
 
 func TestNew_FilenameDateFormat(t *testing.T) {
-	tmpDir := t.TempDir()
-	t.Setenv("LOG_DIR", tmpDir)
-
-	_, file, err := New()
+	_, file, err := New(config.Log{Dir: t.TempDir()})
 	if err != nil {
 		t.Fatalf("New() should not return error: %v", err)
 	}
 	defer file.Close()
 
-	filename := filepath.Base(file.Name())
+	filename := filepath.Base(file.Path())
 
 	ext := filepath.Ext(filename)
 	if ext != ".log" {
@@ -121,3 +124,104 @@ func TestNew_FilenameDateFormat(t *testing.T) {
 		t.Error("Filename should have sufficient length")
 	}
 }
+
+func TestNew_JSONFormat(t *testing.T) {
+	logger, file, err := New(config.Log{Dir: t.TempDir(), Format: "json"})
+	if err != nil {
+		t.Fatalf("New() should not return error: %v", err)
+	}
+	defer file.Close()
+
+	logger.Info("json_entry", zap.Int("count", 3))
+	logger.Debug("hidden_entry")
+	logger.Sync()
+
+	content, err := os.ReadFile(file.Path())
+	if err != nil {
+		t.Fatalf("should be able to read log file: %v", err)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(content), &entry); err != nil {
+		t.Fatalf("log line should be a single JSON object: %v (%s)", err, content)
+	}
+	if entry["msg"] != "json_entry" || entry["level"] != "info" || entry["count"] != float64(3) {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if _, err := time.Parse("2006-01-02T15:04:05.000Z0700", fmt.Sprint(entry["time"])); err != nil {
+		t.Errorf("time should be an ISO 8601 timestamp: %v", entry["time"])
+	}
+}
+
+func TestNew_TextFormat(t *testing.T) {
+	logger, file, err := New(config.Log{Dir: t.TempDir(), Format: "text"})
+	if err != nil {
+		t.Fatalf("New() should not return error: %v", err)
+	}
+	defer file.Close()
+
+	logger.Info("text_entry", zap.Int("count", 3))
+	logger.Sync()
+
+	content, err := os.ReadFile(file.Path())
+	if err != nil {
+		t.Fatalf("should be able to read log file: %v", err)
+	}
+	line := string(content)
+	if json.Valid(bytes.TrimSpace(content)) || !strings.Contains(line, "\tINFO\t") || !strings.Contains(line, "text_entry") {
+		t.Errorf("expected a console line, got %q", line)
+	}
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	if _, _, err := New(config.Log{Dir: t.TempDir(), Format: "xml"}); err == nil {
+		t.Error("New() should reject an unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    zapcore.Level
+		wantErr bool
+	}{
+		{value: "", want: zapcore.InfoLevel},
+		{value: "debug", want: zapcore.DebugLevel},
+		{value: "WARN", want: zapcore.WarnLevel},
+		{value: "loud", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			level, err := ParseLevel(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && level.Level() != tt.want {
+				t.Errorf("ParseLevel() = %s, want %s", level.Level(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWithLevel_ChangesAtRuntime(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, file, err := NewWithLevel(config.Log{Dir: t.TempDir(), Format: "json"}, level)
+	if err != nil {
+		t.Fatalf("NewWithLevel() should not return error: %v", err)
+	}
+	defer file.Close()
+
+	logger.Debug("before_change")
+	level.SetLevel(zapcore.DebugLevel)
+	logger.Debug("after_change")
+	logger.Sync()
+
+	content, _ := os.ReadFile(file.Path())
+	if bytes.Contains(content, []byte("before_change")) {
+		t.Error("debug entry should be dropped at info level")
+	}
+	if !bytes.Contains(content, []byte("after_change")) {
+		t.Error("debug entry should be written after lowering the level")
+	}
+}
@@ -0,0 +1,90 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"log/syslog"
+	"strings"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultSyslogTag = "segmentation"
+
+// openSyslogSink writes to the local syslog daemon, or to cfg.SyslogAddr
+// (udp://host:514 or tcp://host:514) when set, tagged with cfg.SyslogTag.
+// Each entry is sent with the syslog severity of its level.
+func openSyslogSink(cfg config.Log, level zapcore.LevelEnabler) (*sink, error) {
+	encoder, err := syslogEncoder(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	var network, raddr string
+	if addr := strings.TrimSpace(cfg.SyslogAddr); addr != "" {
+		network, raddr, _ = strings.Cut(addr, "://")
+		if raddr == "" {
+			network, raddr = "udp", addr
+		}
+	}
+	tag := cfg.SyslogTag
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{core: &syslogCore{LevelEnabler: level, enc: encoder, w: w}, closer: w}, nil
+}
+
+// syslogCore is a zapcore.Core that maps zap levels to syslog severities
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	w   *syslog.Writer
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &syslogCore{LevelEnabler: c.LevelEnabler, enc: enc, w: c.w}
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(buf.String(), "\n")
+	buf.Free()
+
+	switch {
+	case ent.Level >= zapcore.DPanicLevel:
+		return c.w.Crit(msg)
+	case ent.Level == zapcore.ErrorLevel:
+		return c.w.Err(msg)
+	case ent.Level == zapcore.WarnLevel:
+		return c.w.Warning(msg)
+	case ent.Level == zapcore.InfoLevel:
+		return c.w.Info(msg)
+	default:
+		return c.w.Debug(msg)
+	}
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9
+
+package logger
+
+import (
+	"errors"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func openSyslogSink(config.Log, zapcore.LevelEnabler) (*sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Output is the set of sinks a logger writes to
+type Output struct {
+	closers []io.Closer
+	path    string
+}
+
+func (o *Output) add(s *sink) {
+	if s.closer != nil {
+		o.closers = append(o.closers, s.closer)
+	}
+	if s.path != "" {
+		o.path = s.path
+	}
+}
+
+// Path returns the log file, or "" when the file sink is not in use
+func (o *Output) Path() string {
+	return o.path
+}
+
+// Close releases every sink
+func (o *Output) Close() error {
+	var errs []error
+	for _, c := range o.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// sink is an opened log destination
+type sink struct {
+	core   zapcore.Core
+	closer io.Closer
+	path   string // only for the file sink
+}
+
+type sinkOpener func(cfg config.Log, level zapcore.LevelEnabler) (*sink, error)
+
+// sinkOpeners maps the names accepted by LOG_SINKS to their constructors
+var sinkOpeners = map[string]sinkOpener{
+	"file":    openFileSink,
+	"stdout":  openStreamSink(os.Stdout),
+	"stderr":  openStreamSink(os.Stderr),
+	"syslog":  openSyslogSink,
+	"fluentd": openFluentdSink,
+}
+
+// sinkNames validates cfg.Sinks, a list of file, stdout, stderr, syslog
+// and fluentd. Without sinks the logger keeps the historical behavior: the
+// file, plus stdout when cfg.PrintLog is set. Deployments without a
+// writable filesystem use the stdout sink only.
+func sinkNames(cfg config.Log) ([]string, error) {
+	if len(cfg.Sinks) == 0 {
+		names := []string{"file"}
+		if cfg.PrintLog {
+			names = append(names, "stdout")
+		}
+		return names, nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range cfg.Sinks {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := sinkOpeners[name]; !ok {
+			return nil, fmt.Errorf("invalid log sink %q", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no valid log sink in %q", cfg.Sinks)
+	}
+	return names, nil
+}
+
+// FileDirs returns the directories the loggers of cfg write files to: the
+// log directory with the file sink, and the one of the slow query log
+// unless it goes to stdout
+func FileDirs(cfg config.Log) ([]string, error) {
+	names, err := sinkNames(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, name := range names {
+		if name == "file" {
+			dirs = append(dirs, cfg.Dir)
+		}
+	}
+	switch cfg.SlowQueryLog {
+	case "stdout":
+	case "":
+		if len(dirs) == 0 || dirs[0] != cfg.Dir {
+			dirs = append(dirs, cfg.Dir)
+		}
+	default:
+		if dir := filepath.Dir(cfg.SlowQueryLog); len(dirs) == 0 || dirs[0] != dir {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// openFileSink creates a timestamped file in cfg.Dir
+func openFileSink(cfg config.Log, level zapcore.LevelEnabler) (*sink, error) {
+	encoder, err := newEncoder(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	logDir := cfg.Dir
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, err
+	}
+
+	filename := time.Now().Format("2006-01-02T15-04-05") + "-processor.log"
+	path := filepath.Join(logDir, filename)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{
+		core:   zapcore.NewCore(encoder, zapcore.AddSync(file), level),
+		closer: file,
+		path:   path,
+	}, nil
+}
+
+// openStreamSink writes to a standard stream, which is never closed
+func openStreamSink(f *os.File) sinkOpener {
+	return func(cfg config.Log, level zapcore.LevelEnabler) (*sink, error) {
+		encoder, err := newEncoder(cfg.Format)
+		if err != nil {
+			return nil, err
+		}
+		return &sink{core: zapcore.NewCore(encoder, zapcore.Lock(f), level)}, nil
+	}
+}
+
+const fluentdWriteTimeout = 5 * time.Second
+
+// openFluentdSink sends JSON lines over TCP to cfg.FluentdAddr (host:port),
+// the format read by fluentd's in_tcp input with a json parser. JSON is
+// used whatever cfg.Format says, since the collector parses the entries.
+func openFluentdSink(cfg config.Log, level zapcore.LevelEnabler) (*sink, error) {
+	addr := strings.TrimSpace(cfg.FluentdAddr)
+	if addr == "" {
+		return nil, errors.New("fluentd address is required")
+	}
+
+	encoder, err := newEncoder("json")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &tcpWriter{addr: addr, timeout: fluentdWriteTimeout}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return &sink{core: zapcore.NewCore(encoder, w, level), closer: w}, nil
+}
+
+// tcpWriter writes to a TCP peer, reconnecting once when a write fails so
+// a restarted collector does not silence the logger for good
+type tcpWriter struct {
+	addr    string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *tcpWriter) connect() error {
+	conn, err := net.DialTimeout("tcp", w.addr, w.timeout)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+func (w *tcpWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+		if n, err := w.conn.Write(p); err == nil {
+			return n, nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	return w.conn.Write(p)
+}
+
+func (w *tcpWriter) Sync() error {
+	return nil
+}
+
+func (w *tcpWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// syslogEncoder is the LOG_FORMAT encoder without the timestamp, which
+// syslog adds itself
+func syslogEncoder(format string) (zapcore.Encoder, error) {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.TimeKey = ""
+	return newEncoderWith(format, cfg)
+}
+
+// nopCloser is the closer of sinks that own nothing to release
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
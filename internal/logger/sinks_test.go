@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+)
+
+func TestSinkNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		sinks    []string
+		printLog bool
+		want     []string
+		wantErr  bool
+	}{
+		{name: "default", want: []string{"file"}},
+		{name: "default with print", printLog: true, want: []string{"file", "stdout"}},
+		{name: "stdout only", sinks: []string{"stdout"}, printLog: true, want: []string{"stdout"}},
+		{name: "list", sinks: []string{" File", "syslog ", "fluentd", "file"}, want: []string{"file", "syslog", "fluentd"}},
+		{name: "unknown", sinks: []string{"file", "kafka"}, wantErr: true},
+		{name: "empty list", sinks: []string{"", " "}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sinkNames(config.Log{Sinks: tt.sinks, PrintLog: tt.printLog})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sinkNames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sinkNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileDirs(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Log
+		want []string
+	}{
+		{name: "default", cfg: config.Log{Dir: "logs"}, want: []string{"logs"}},
+		{name: "stdout only", cfg: config.Log{Dir: "logs", Sinks: []string{"stdout"}, SlowQueryLog: "stdout"}},
+		{name: "slow log in dir", cfg: config.Log{Dir: "logs", Sinks: []string{"stdout"}}, want: []string{"logs"}},
+		{name: "slow log elsewhere", cfg: config.Log{Dir: "logs", SlowQueryLog: "/var/log/slow.log"}, want: []string{"logs", "/var/log"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FileDirs(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FileDirs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_StdoutOnlyWritesNoFile(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs")
+
+	logger, out, err := New(config.Log{Dir: logDir, Sinks: []string{"stdout"}})
+	if err != nil {
+		t.Fatalf("New() should not return error: %v", err)
+	}
+	defer out.Close()
+	logger.Info("stdout_entry")
+
+	if out.Path() != "" {
+		t.Errorf("Path() = %q, want no file", out.Path())
+	}
+	if _, err := os.Stat(logDir); !os.IsNotExist(err) {
+		t.Errorf("log directory should not be created, stat error = %v", err)
+	}
+}
+
+func TestNew_FluentdSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		if scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	logger, out, err := New(config.Log{Format: "text", Sinks: []string{"fluentd"}, FluentdAddr: ln.Addr().String()})
+	if err != nil {
+		t.Fatalf("New() should not return error: %v", err)
+	}
+	defer out.Close()
+
+	logger.Info("fluentd_entry")
+
+	select {
+	case line := <-lines:
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("fluentd entries should be JSON whatever the format says: %v (%s)", err, line)
+		}
+		if entry["msg"] != "fluentd_entry" {
+			t.Errorf("unexpected entry: %v", entry)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no entry received by the collector")
+	}
+}
+
+func TestNew_FluentdSinkRequiresAddress(t *testing.T) {
+	if _, _, err := New(config.Log{Sinks: []string{"fluentd"}}); err == nil {
+		t.Error("New() should fail without a fluentd address")
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UnmatchedRoute labels requests that did not match any route, so scans
+// of random paths do not create new series
+const UnmatchedRoute = "unmatched"
+
+// HTTPMetrics holds the collectors of the HTTP server
+type HTTPMetrics struct {
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// NewHTTPMetrics creates the HTTP collectors and registers them with reg
+func NewHTTPMetrics(reg prometheus.Registerer) *HTTPMetrics {
+	m := &HTTPMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route template and status class.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+	}
+	reg.MustRegister(m.duration, m.inFlight)
+	return m
+}
+
+// Start marks a request as in flight; the returned function records its
+// outcome and must be called once the response is written
+func (m *HTTPMetrics) Start() func(method, route string, status int) {
+	begin := time.Now()
+	m.inFlight.Inc()
+	return func(method, route string, status int) {
+		m.inFlight.Dec()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		m.duration.WithLabelValues(method, route, StatusClass(status)).Observe(time.Since(begin).Seconds())
+	}
+}
+
+// StatusClass returns the class of an HTTP status code, e.g. "2xx"
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
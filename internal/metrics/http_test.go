@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStatusClass(t *testing.T) {
+	tests := map[int]string{200: "2xx", 204: "2xx", 304: "3xx", 404: "4xx", 503: "5xx", 0: "unknown", 600: "unknown"}
+	for status, want := range tests {
+		if got := StatusClass(status); got != want {
+			t.Errorf("StatusClass(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestHTTPMetrics_Start(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	done := m.Start()
+	if got := testutil.ToFloat64(m.inFlight); got != 1 {
+		t.Errorf("in flight = %v, want 1", got)
+	}
+
+	done("GET", "/users/:user_id/segmentations", 200)
+	m.Start()("GET", "", 404)
+
+	if got := testutil.ToFloat64(m.inFlight); got != 0 {
+		t.Errorf("in flight = %v, want 0", got)
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 2 {
+		t.Errorf("series = %d, want 2", got)
+	}
+	if got := testutil.CollectAndCount(m.duration.WithLabelValues("GET", UnmatchedRoute, "4xx").(prometheus.Histogram)); got != 1 {
+		t.Errorf("unmatched routes should share one series, got %d", got)
+	}
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	NewRepositoryMetrics(reg).results.WithLabelValues("inserted").Inc()
+
+	w := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, name := range []string{"segmentation_repository_upsert_results_total", "go_goroutines"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("metrics output should contain %s", name)
+		}
+	}
+}
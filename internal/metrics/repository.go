@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RepositoryMetrics holds the collectors of the segmentation repository
+type RepositoryMetrics struct {
+	duration *prometheus.HistogramVec
+	results  *prometheus.CounterVec
+}
+
+// NewRepositoryMetrics creates the repository collectors and registers them
+// with reg
+func NewRepositoryMetrics(reg prometheus.Registerer) *RepositoryMetrics {
+	m := &RepositoryMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "repository",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of segmentation repository operations.",
+			// 0.5ms to ~4s, so a missing index shows up as a bucket shift
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"operation", "status"}),
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "repository",
+			Name:      "upsert_results_total",
+			Help:      "Upserts by result (inserted, updated, noop).",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(m.duration, m.results)
+	return m
+}
+
+// observe records the latency of an operation started at begin
+func (m *RepositoryMetrics) observe(operation string, begin time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.duration.WithLabelValues(operation, status).Observe(time.Since(begin).Seconds())
+}
+
+// InstrumentRepository wraps next so every operation is measured by m
+func InstrumentRepository(next repository.SegmentationRepository, m *RepositoryMetrics) repository.SegmentationRepository {
+	return &instrumentedRepository{next: next, metrics: m}
+}
+
+type instrumentedRepository struct {
+	next    repository.SegmentationRepository
+	metrics *RepositoryMetrics
+}
+
+func (r *instrumentedRepository) FindByUserID(
+	ctx context.Context,
+	userID uint64,
+) ([]models.Segmentation, error) {
+
+	begin := time.Now()
+	segs, err := r.next.FindByUserID(ctx, userID)
+	r.metrics.observe("find_by_user_id", begin, err)
+	return segs, err
+}
+
+// EachByUserID is measured until the last row is handed to fn, so the
+// latency includes the time fn takes
+func (r *instrumentedRepository) EachByUserID(
+	ctx context.Context,
+	userID uint64,
+	fn func(models.Segmentation) error,
+) error {
+
+	begin := time.Now()
+	err := r.next.EachByUserID(ctx, userID, fn)
+	r.metrics.observe("each_by_user_id", begin, err)
+	return err
+}
+
+func (r *instrumentedRepository) Upsert(
+	ctx context.Context,
+	s *models.Segmentation,
+) (repository.UpsertResult, error) {
+
+	begin := time.Now()
+	result, err := r.next.Upsert(ctx, s)
+	r.metrics.observe("upsert", begin, err)
+	if err == nil {
+		r.metrics.results.WithLabelValues(resultLabel(result)).Inc()
+	}
+	return result, err
+}
+
+func (r *instrumentedRepository) BulkUpsert(
+	ctx context.Context,
+	items []models.Segmentation,
+) (repository.BulkUpsertResult, error) {
+
+	begin := time.Now()
+	result, err := r.next.BulkUpsert(ctx, items)
+	r.metrics.observe("bulk_upsert", begin, err)
+	if err == nil {
+		r.metrics.results.WithLabelValues("inserted").Add(float64(result.Inserted))
+		r.metrics.results.WithLabelValues("updated").Add(float64(result.Updated))
+	}
+	return result, err
+}
+
+func (r *instrumentedRepository) DeleteByIDs(
+	ctx context.Context,
+	ids []uint64,
+) (int64, error) {
+
+	begin := time.Now()
+	n, err := r.next.DeleteByIDs(ctx, ids)
+	r.metrics.observe("delete_by_ids", begin, err)
+	return n, err
+}
+
+func (r *instrumentedRepository) Transaction(
+	ctx context.Context,
+	fn func(tx repository.SegmentationRepository) error,
+) error {
+
+	begin := time.Now()
+	err := r.next.Transaction(ctx, func(tx repository.SegmentationRepository) error {
+		return fn(&instrumentedRepository{next: tx, metrics: r.metrics})
+	})
+	r.metrics.observe("transaction", begin, err)
+	return err
+}
+
+func resultLabel(r repository.UpsertResult) string {
+	switch r {
+	case repository.UpsertInserted:
+		return "inserted"
+	case repository.UpsertUpdated:
+		return "updated"
+	default:
+		return "noop"
+	}
+}
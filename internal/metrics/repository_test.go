@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type stubRepository struct {
+	result repository.UpsertResult
+	err    error
+}
+
+func (s *stubRepository) FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+	return nil, s.err
+}
+
+func (s *stubRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, s.FindByUserID, userID, fn)
+}
+
+func (s *stubRepository) Upsert(ctx context.Context, seg *models.Segmentation) (repository.UpsertResult, error) {
+	return s.result, s.err
+}
+
+func (s *stubRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), s.err
+}
+
+func (s *stubRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, s.Upsert, items)
+}
+
+func (s *stubRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(s)
+}
+
+func TestInstrumentRepository_UpsertResults(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewRepositoryMetrics(reg)
+	stub := &stubRepository{result: repository.UpsertInserted}
+	repo := InstrumentRepository(stub, m)
+
+	ctx := context.Background()
+	repo.Upsert(ctx, &models.Segmentation{})
+	repo.Upsert(ctx, &models.Segmentation{})
+	stub.result = repository.UpsertUpdated
+	repo.Upsert(ctx, &models.Segmentation{})
+	stub.err = errors.New("db down")
+	repo.Upsert(ctx, &models.Segmentation{})
+
+	if got := testutil.ToFloat64(m.results.WithLabelValues("inserted")); got != 2 {
+		t.Errorf("inserted = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.results.WithLabelValues("updated")); got != 1 {
+		t.Errorf("updated = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.duration, "segmentation_repository_operation_duration_seconds"); got != 2 {
+		t.Errorf("duration series = %d, want 2 (upsert ok and error)", got)
+	}
+}
+
+func TestInstrumentRepository_TransactionIsInstrumented(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewRepositoryMetrics(reg)
+	repo := InstrumentRepository(&stubRepository{}, m)
+
+	err := repo.Transaction(context.Background(), func(tx repository.SegmentationRepository) error {
+		if _, ok := tx.(*instrumentedRepository); !ok {
+			t.Error("transaction repository should be instrumented")
+		}
+		_, err := tx.DeleteByIDs(context.Background(), []uint64{1, 2})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	operations := map[string]bool{}
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "operation" {
+					operations[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	if !operations["transaction"] || !operations["delete_by_ids"] {
+		t.Errorf("operations = %v, want transaction and delete_by_ids", operations)
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// DefaultPushJob is the Pushgateway job of processor runs when no job is
+// given
+const DefaultPushJob = "segmentation_processor"
+
+// RunMetrics holds the outcome of the last processor run. They are gauges
+// because the process exits after one run: the values are pushed to a
+// Pushgateway, which keeps them until the next run replaces them.
+type RunMetrics struct {
+	rows      *prometheus.GaugeVec
+	duration  prometheus.Gauge
+	success   prometheus.Gauge
+	timestamp prometheus.Gauge
+}
+
+// NewRunMetrics creates the run collectors and registers them with reg
+func NewRunMetrics(reg prometheus.Registerer) *RunMetrics {
+	m := &RunMetrics{
+		rows: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "processor",
+			Name:      "last_run_rows",
+			Help:      "Rows of the last run by outcome (read, inserted, updated, duplicates, failed, invalid, warnings).",
+		}, []string{"result"}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "processor",
+			Name:      "last_run_duration_seconds",
+			Help:      "Duration of the last run.",
+		}),
+		success: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "processor",
+			Name:      "last_run_success",
+			Help:      "1 if the last run succeeded, 0 if it failed or was cancelled.",
+		}),
+		timestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "processor",
+			Name:      "last_run_finished_timestamp_seconds",
+			Help:      "Unix time the last run finished.",
+		}),
+	}
+	reg.MustRegister(m.rows, m.duration, m.success, m.timestamp)
+	return m
+}
+
+// Record sets the gauges from a finished run
+func (m *RunMetrics) Record(run *models.Run) {
+	for result, n := range map[string]uint64{
+		"read":       run.RowsRead,
+		"inserted":   run.Inserted,
+		"updated":    run.Updated,
+		"duplicates": run.Duplicates,
+		"failed":     run.Failed,
+		"invalid":    run.Invalid,
+		"warnings":   run.Warnings,
+	} {
+		m.rows.WithLabelValues(result).Set(float64(n))
+	}
+
+	m.duration.Set(float64(run.FinishedAt - run.StartedAt))
+	m.timestamp.Set(float64(run.FinishedAt))
+	if run.Status == models.RunSucceeded {
+		m.success.Set(1)
+	} else {
+		m.success.Set(0)
+	}
+}
+
+// InstrumentRunRepository wraps next so the final state of every run is
+// recorded in m
+func InstrumentRunRepository(next repository.RunRepository, m *RunMetrics) repository.RunRepository {
+	return &instrumentedRunRepository{RunRepository: next, metrics: m}
+}
+
+type instrumentedRunRepository struct {
+	repository.RunRepository
+	metrics *RunMetrics
+}
+
+func (r *instrumentedRunRepository) Update(ctx context.Context, run *models.Run) error {
+	if run.FinishedAt > 0 {
+		r.metrics.Record(run)
+	}
+	return r.RunRepository.Update(ctx, run)
+}
+
+// NewPusher returns a pusher of the metrics in g to the Pushgateway at url
+// under job, or nil when url is empty
+func NewPusher(url, job string, g prometheus.Gatherer) *push.Pusher {
+	if url == "" {
+		return nil
+	}
+	if job == "" {
+		job = DefaultPushJob
+	}
+	return push.New(url, job).Gatherer(g)
+}
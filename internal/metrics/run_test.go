@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type stubRunRepository struct {
+	updated *models.Run
+}
+
+func (s *stubRunRepository) Create(ctx context.Context, run *models.Run) error { return nil }
+
+func (s *stubRunRepository) Update(ctx context.Context, run *models.Run) error {
+	s.updated = run
+	return nil
+}
+
+func (s *stubRunRepository) ClaimPartition(ctx context.Context, run *models.Run) error { return nil }
+
+func (s *stubRunRepository) Get(ctx context.Context, id string) (*models.Run, error) { return nil, nil }
+
+func (s *stubRunRepository) Latest(ctx context.Context) (*models.Run, error) { return s.updated, nil }
+
+func (s *stubRunRepository) JobRuns(ctx context.Context, jobID string) ([]models.Run, error) {
+	return nil, nil
+}
+
+func (s *stubRunRepository) SourceRuns(ctx context.Context, source string) ([]models.Run, error) {
+	return nil, nil
+}
+
+func (s *stubRunRepository) UpdateProgress(ctx context.Context, run *models.Run) error { return nil }
+
+func (s *stubRunRepository) RequestCancel(ctx context.Context, id string, at int64) (bool, error) {
+	return false, nil
+}
+
+func (s *stubRunRepository) CancelRequested(ctx context.Context, id string) (bool, error) {
+	return false, nil
+}
+
+func (s *stubRunRepository) List(ctx context.Context, filter repository.RunFilter) ([]models.Run, error) {
+	return nil, nil
+}
+
+func TestInstrumentRunRepository_RecordsFinishedRun(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewRunMetrics(reg)
+	stub := &stubRunRepository{}
+	runs := InstrumentRunRepository(stub, m)
+
+	run := &models.Run{
+		ID:         "run-42",
+		Status:     models.RunSucceeded,
+		RowsRead:   10,
+		Inserted:   7,
+		Failed:     1,
+		Invalid:    2,
+		StartedAt:  1000,
+		FinishedAt: 1030,
+	}
+	if err := runs.Update(context.Background(), run); err != nil {
+		t.Fatal(err)
+	}
+	if stub.updated != run {
+		t.Fatal("Update should reach the wrapped repository")
+	}
+
+	if got := testutil.ToFloat64(m.rows.WithLabelValues("inserted")); got != 7 {
+		t.Errorf("inserted = %v, want 7", got)
+	}
+	if got := testutil.ToFloat64(m.duration); got != 30 {
+		t.Errorf("duration = %v, want 30", got)
+	}
+	if got := testutil.ToFloat64(m.success); got != 1 {
+		t.Errorf("success = %v, want 1", got)
+	}
+
+	run.Status = models.RunFailed
+	runs.Update(context.Background(), run)
+	if got := testutil.ToFloat64(m.success); got != 0 {
+		t.Errorf("success after a failed run = %v, want 0", got)
+	}
+}
+
+func TestNewPusher(t *testing.T) {
+	if p := NewPusher("", "", prometheus.NewRegistry()); p != nil {
+		t.Fatal("no pusher should be created without a URL")
+	}
+
+	var path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	NewRunMetrics(reg).Record(&models.Run{Status: models.RunSucceeded, StartedAt: 1, FinishedAt: 2})
+
+	if err := NewPusher(srv.URL, "", reg).PushContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if path != "PUT /metrics/job/"+DefaultPushJob {
+		t.Errorf("request = %q", path)
+	}
+	if !strings.Contains(body, "segmentation_processor_last_run_success") {
+		t.Errorf("pushed body should contain the run metrics")
+	}
+}
@@ -0,0 +1,16 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RegisterWriteQueue exports the number of writes waiting in the API's
+// write-behind queue, read from pending at every scrape
+func RegisterWriteQueue(reg prometheus.Registerer, pending func() int) {
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "write_queue",
+		Name:      "pending",
+		Help:      "Writes accepted by the write-behind queue and not yet flushed to MySQL.",
+	}, func() float64 {
+		return float64(pending())
+	}))
+}
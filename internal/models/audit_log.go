@@ -0,0 +1,14 @@
+package models
+
+// AuditLog records a security-relevant event: a failed authentication, an
+// admin action or a destructive write. Status is the HTTP status returned
+// to the caller, so refused attempts are kept too.
+type AuditLog struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Actor     string    `gorm:"size:100;not null;index" json:"actor"`
+	Action    string    `gorm:"size:100;not null;index" json:"action"`
+	Target    string    `gorm:"size:255" json:"target"`
+	Status    int       `json:"status"`
+	ClientIP  string    `gorm:"size:45" json:"client_ip"`
+	CreatedAt Timestamp `gorm:"not null;index" json:"created_at" swaggertype:"string" format:"date-time"`
+}
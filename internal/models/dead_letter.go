@@ -0,0 +1,15 @@
+package models
+
+// DeadLetter is an input row the processor rejected or failed to write,
+// kept with the reason so data providers can fix and resend it
+type DeadLetter struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	RunID     string `gorm:"size:36;not null;index"`
+	RowNumber int    `gorm:"column:csv_row;not null"` // row_number is reserved in MySQL 8
+	// UserID is read from the first column when it holds a number, so an
+	// erasure finds the rows of the user; 0 otherwise
+	UserID    uint64 `gorm:"not null;default:0;index"`
+	RawLine   string `gorm:"type:text"`
+	Error     string `gorm:"type:text"`
+	CreatedAt int64
+}
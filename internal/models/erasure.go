@@ -0,0 +1,57 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Erasure records that the data of a user was erased on request (LGPD,
+// GDPR): who asked, when and how many rows of each kind were deleted.
+// The records form a hash chain: Hash covers the fields of the record and
+// the Hash of the previous one, its PrevHash, so a record edited or
+// removed afterwards breaks the chain from there on.
+type Erasure struct {
+	ID       uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID   uint64 `gorm:"not null;index" json:"user_id"`
+	Actor    string `gorm:"size:100;not null" json:"actor"`
+	ClientIP string `gorm:"size:45" json:"client_ip"`
+	// Reference identifies the request of the data subject, such as a
+	// ticket number
+	Reference       string    `gorm:"size:255" json:"reference,omitempty"`
+	Segmentations   int64     `gorm:"not null" json:"segmentations"`
+	Events          int64     `gorm:"not null" json:"events"`
+	Deliveries      int64     `gorm:"not null" json:"deliveries"`
+	DeadLetters     int64     `gorm:"not null;default:0" json:"dead_letters"`
+	IdempotencyKeys int64     `gorm:"not null;default:0" json:"idempotency_keys"`
+	CreatedAt       Timestamp `gorm:"not null" json:"created_at" swaggertype:"string" format:"date-time"`
+	PrevHash        string    `gorm:"size:64;not null" json:"prev_hash"`
+	Hash            string    `gorm:"size:64;not null;uniqueIndex" json:"hash"`
+}
+
+// ComputeHash returns the hex SHA-256 of the record chained to PrevHash;
+// the ID is left out, as it is only known once the record is stored
+func (e Erasure) ComputeHash() string {
+	// a struct keeps the fields in a fixed order; the counts added later
+	// are omitted when zero, so the records stored before them still
+	// verify; the time is hashed as unix seconds, whatever its JSON
+	b, _ := json.Marshal(struct {
+		PrevHash        string `json:"prev_hash"`
+		UserID          uint64 `json:"user_id"`
+		Actor           string `json:"actor"`
+		ClientIP        string `json:"client_ip"`
+		Reference       string `json:"reference"`
+		Segmentations   int64  `json:"segmentations"`
+		Events          int64  `json:"events"`
+		Deliveries      int64  `json:"deliveries"`
+		CreatedAt       int64  `json:"created_at"`
+		DeadLetters     int64  `json:"dead_letters,omitempty"`
+		IdempotencyKeys int64  `json:"idempotency_keys,omitempty"`
+	}{
+		e.PrevHash, e.UserID, e.Actor, e.ClientIP, e.Reference,
+		e.Segmentations, e.Events, e.Deliveries, int64(e.CreatedAt),
+		e.DeadLetters, e.IdempotencyKeys,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestErasureComputeHash(t *testing.T) {
+	e := Erasure{
+		UserID: 10, Actor: "admin", ClientIP: "10.0.0.1", Reference: "DPO-1",
+		Segmentations: 3, Events: 2, Deliveries: 1, CreatedAt: 1700000000, PrevHash: "abc",
+	}
+
+	// records stored before the dead letter and idempotency key counts
+	// were hashed without them and must still verify
+	legacy, _ := json.Marshal(struct {
+		PrevHash      string `json:"prev_hash"`
+		UserID        uint64 `json:"user_id"`
+		Actor         string `json:"actor"`
+		ClientIP      string `json:"client_ip"`
+		Reference     string `json:"reference"`
+		Segmentations int64  `json:"segmentations"`
+		Events        int64  `json:"events"`
+		Deliveries    int64  `json:"deliveries"`
+		CreatedAt     int64  `json:"created_at"`
+	}{"abc", 10, "admin", "10.0.0.1", "DPO-1", 3, 2, 1, 1700000000})
+	sum := sha256.Sum256(legacy)
+	if got := e.ComputeHash(); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("ComputeHash() = %s, want the hash of the legacy layout", got)
+	}
+
+	for _, edit := range []func(*Erasure){
+		func(e *Erasure) { e.DeadLetters = 1 },
+		func(e *Erasure) { e.IdempotencyKeys = 1 },
+	} {
+		changed := e
+		edit(&changed)
+		if changed.ComputeHash() == e.ComputeHash() {
+			t.Errorf("the hash should cover %+v", changed)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+// IdempotencyKey stores the response produced for a write request so that
+// retries carrying the same Idempotency-Key header are replayed instead of
+// being applied twice
+type IdempotencyKey struct {
+	Key         string `gorm:"column:idempotency_key;primaryKey;size:255"`
+	Fingerprint string `gorm:"size:64;not null"`
+	StatusCode  int    `gorm:"not null"`
+	ContentType string `gorm:"size:100"`
+	Body        []byte `gorm:"type:mediumblob"`
+	CreatedAt   int64
+	ExpiresAt   int64 `gorm:"not null;index"`
+	// UserID is the :user_id of the request, so an erasure removes the
+	// stored responses carrying the user's data; 0 for other routes
+	UserID uint64 `gorm:"not null;default:0;index"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "gorm.io/datatypes"
+
+// Outbox event types
+const (
+	OutboxUpserted = "segmentation.upserted"
+	OutboxDeleted  = "segmentation.deleted"
+)
+
+// OutboxEventTypes lists every outbox event type
+var OutboxEventTypes = []string{OutboxUpserted, OutboxDeleted}
+
+// OutboxEvent is a change to a segmentation, written in the same
+// transaction as the change itself and published later by the outbox
+// relay. Data is the written data of an upsert and empty on a delete;
+// PublishedAt is nil until the relay publishes the event. NameKey is the
+// name part of the unique key of the segmentation, for consumers mirroring
+// the rows; it is not part of the published payload. RunID is the
+// processor run that made the change, empty for the other writes.
+type OutboxEvent struct {
+	ID               uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventType        string         `gorm:"size:32;not null" json:"event_type"`
+	UserID           uint64         `gorm:"not null" json:"user_id"`
+	SegmentationType string         `gorm:"size:50;not null" json:"segmentation_type"`
+	SegmentationName string         `gorm:"size:100;not null" json:"segmentation_name"`
+	NameKey          string         `gorm:"size:100;not null;default:''" json:"-"`
+	Data             datatypes.JSON `gorm:"type:json" json:"data,omitempty"`
+	RunID            string         `gorm:"size:36;not null;default:''" json:"run_id,omitempty"`
+	CreatedAt        int64          `gorm:"not null" json:"created_at"`
+	PublishedAt      *int64         `json:"published_at,omitempty"`
+}
+
+// TableName is segmentation_outbox, the table CDC connectors can also
+// tail directly
+func (OutboxEvent) TableName() string {
+	return "segmentation_outbox"
+}
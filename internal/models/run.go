@@ -0,0 +1,51 @@
+package models
+
+// Run kinds: processor imports and the table exports of POST /admin/exports
+const (
+	RunImport = "import"
+	RunExport = "export"
+)
+
+// Run status values
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunCancelled = "cancelled"
+)
+
+// Run records one execution of the processor. Its ID is included in every
+// log line and dead-letter entry of the run so they can be joined later.
+//
+// A file can be split across instances by user ID: the runs of each
+// partition share a JobID; an unpartitioned run is its own job.
+//
+// Exports are runs of kind RunExport: Source is the filter of the export,
+// Destination where its chunks are written and RowsRead the rows exported
+// so far. Pseudonymized exports replace the user IDs by pseudonyms.
+type Run struct {
+	ID          string `gorm:"primaryKey;size:36"`
+	Kind        string `gorm:"size:20;not null;default:import"`
+	JobID       string `gorm:"size:36;not null;index"`
+	Partition   int    `gorm:"column:partition_index;not null"`
+	Partitions  int    `gorm:"not null;default:1"`
+	Source      string `gorm:"size:500;index:idx_runs_source,length:255"`
+	Destination string `gorm:"size:500"`
+	Status      string `gorm:"size:20;not null;index"`
+	RowsRead    uint64
+	Enqueued    uint64
+	Inserted    uint64
+	Updated     uint64
+	Duplicates  uint64
+	Failed      uint64
+	Invalid     uint64
+	Warnings    uint64
+	Error       string `gorm:"type:text"`
+	StartedAt   int64  `gorm:"not null;index"`
+	FinishedAt  int64
+	// CancelRequestedAt is when the cancellation of a running import was
+	// requested through the API, 0 if it was not; the processor running it
+	// stops at its next progress check
+	CancelRequestedAt int64 `gorm:"not null;default:0"`
+	Pseudonymized     bool  `gorm:"not null;default:false"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// SegmentSnapshot is the size of a segmentation type on a day (UTC), taken
+// by the analytics snapshots so the growth of each type can be charted. A
+// later snapshot of the same day replaces the earlier one.
+type SegmentSnapshot struct {
+	Day              string `gorm:"primaryKey;type:date" json:"day" example:"2026-10-17"`
+	SegmentationType string `gorm:"primaryKey;size:50" json:"segmentation_type" example:"drug"`
+	// Segmentations counts the rows of the type
+	Segmentations int64 `gorm:"not null" json:"segmentations"`
+	// Users counts the users with at least one segmentation of the type
+	Users int64 `gorm:"not null" json:"users"`
+	// Segments counts the distinct names of the type
+	Segments int64 `gorm:"not null" json:"segments"`
+	TakenAt  int64 `gorm:"not null" json:"taken_at"`
+}
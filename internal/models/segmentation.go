@@ -5,9 +5,21 @@ import "gorm.io/datatypes"
 type Segmentation struct {
 	ID               uint64         `gorm:"primaryKey;autoIncrement"`
 	UserID           uint64         `gorm:"not null;uniqueIndex:uniq_user_seg"`
-	SegmentationType string         `gorm:"size:50;not null;uniqueIndex:uniq_user_seg"`
-	SegmentationName string         `gorm:"size:100;not null;uniqueIndex:uniq_user_seg"`
+	SegmentationType string         `gorm:"size:50;not null;uniqueIndex:uniq_user_seg;index:idx_segmentations_type_name"`
+	SegmentationName string         `gorm:"size:100;not null;index:idx_segmentations_type_name"`
+	NameKey          string         `gorm:"size:100;not null;uniqueIndex:uniq_user_seg"` // SegmentationName normalized by the name policy; empty falls back to SegmentationName
 	Data             datatypes.JSON `gorm:"type:json"`
+	DataCompressed   []byte         `gorm:"type:mediumblob"` // Data compressed on write (Data is then NULL); reads restore Data
 	CreatedAt        int64
-	UpdatedAt        int64
+	UpdatedAt        int64 `gorm:"index:idx_segmentations_updated_at"`
+}
+
+// UniqueName is the name part of the unique key: NameKey, or the name itself
+// when no key was derived (rows from before the name policy, restores of old
+// backups)
+func (s *Segmentation) UniqueName() string {
+	if s.NameKey != "" {
+		return s.NameKey
+	}
+	return s.SegmentationName
 }
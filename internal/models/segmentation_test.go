@@ -68,3 +68,14 @@ func TestSegmentationStructTags(t *testing.T) {
 		t.Errorf("expected SegmentationName to be empty, got %s", seg.SegmentationName)
 	}
 }
+
+func TestSegmentationUniqueName(t *testing.T) {
+	seg := Segmentation{SegmentationName: "Cardiologia"}
+	if seg.UniqueName() != "Cardiologia" {
+		t.Errorf("without a key UniqueName() = %q, want the name", seg.UniqueName())
+	}
+	seg.NameKey = "cardiologia"
+	if seg.UniqueName() != "cardiologia" {
+		t.Errorf("UniqueName() = %q, want the key", seg.UniqueName())
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/datatypes"
+
+// SegmentationType is an entry of the type registry. DataSchema holds a
+// JSON Schema subset (properties, required, additionalProperties) that
+// segmentation data of this type must follow. Labels maps a locale tag
+// (e.g. "pt-BR", "en") to the localized display label of the type.
+// AppliedSpec is the last declaration applied through PUT, against which
+// the drift of the type is reported; it is nil for types never declared.
+type SegmentationType struct {
+	ID          uint64         `gorm:"primaryKey;autoIncrement" json:"-"`
+	Name        string         `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	DisplayName string         `gorm:"size:100;not null" json:"display_name"`
+	Description string         `gorm:"type:text" json:"description"`
+	DataSchema  datatypes.JSON `gorm:"type:json" json:"data_schema,omitempty" swaggertype:"object"`
+	Labels      datatypes.JSON `gorm:"type:json" json:"labels,omitempty" swaggertype:"object,string"`
+	Active      bool           `gorm:"not null;default:true" json:"active"`
+	AppliedSpec datatypes.JSON `gorm:"type:json" json:"-"`
+	CreatedAt   Timestamp      `json:"created_at" swaggertype:"string" format:"date-time"`
+	UpdatedAt   Timestamp      `json:"updated_at" swaggertype:"string" format:"date-time"`
+}
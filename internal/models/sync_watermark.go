@@ -0,0 +1,14 @@
+package models
+
+// SyncWatermark is how far an incremental sync of the segmentations went:
+// the (updated_at, id) of the last row it delivered, so the next sync
+// resumes right after it. Name identifies the sync, e.g.
+// "warehouse:bigquery".
+type SyncWatermark struct {
+	Name          string `gorm:"primaryKey;size:64"`
+	LastUpdatedAt int64  `gorm:"not null"`
+	LastID        uint64 `gorm:"not null"`
+	// SyncedRows counts every row the sync delivered so far
+	SyncedRows int64 `gorm:"not null"`
+	SyncedAt   int64 `gorm:"not null"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is a time stored as unix seconds, like the created_at and
+// updated_at columns. In JSON it is an RFC3339 string in UTC; decoding also
+// accepts other offsets, converted to UTC, and plain unix seconds. Zero is
+// null.
+type Timestamp int64
+
+// Time returns t in UTC
+func (t Timestamp) Time() time.Time {
+	return time.Unix(int64(t), 0).UTC()
+}
+
+// String formats t as RFC3339, or "" when zero
+func (t Timestamp) String() string {
+	if t == 0 {
+		return ""
+	}
+	return t.Time().Format(time.RFC3339)
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t == 0 {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*t = 0
+		return nil
+	}
+	if len(b) > 0 && b[0] != '"' {
+		secs, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return fmt.Errorf("timestamp must be an RFC3339 string, got %s", b)
+		}
+		*t = Timestamp(secs)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("timestamp must be RFC3339 (e.g. 2026-01-02T15:04:05Z), got %q", s)
+	}
+	*t = Timestamp(parsed.Unix())
+	return nil
+}
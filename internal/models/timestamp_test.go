@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTimestamp_JSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		Set   Timestamp `json:"set"`
+		Unset Timestamp `json:"unset"`
+	}{Set: 1767225600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"set":"2026-01-01T00:00:00Z","unset":null}` {
+		t.Errorf("Marshal() = %s", b)
+	}
+
+	tests := []struct {
+		in      string
+		want    Timestamp
+		wantErr bool
+	}{
+		{in: `"2026-01-01T00:00:00Z"`, want: 1767225600},
+		{in: `"2025-12-31T21:00:00-03:00"`, want: 1767225600},
+		{in: `1767225600`, want: 1767225600},
+		{in: `null`, want: 0},
+		{in: `"2026-01-01"`, wantErr: true},
+		{in: `"yesterday"`, wantErr: true},
+		{in: `1.5`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var got Timestamp
+			err := json.Unmarshal([]byte(tt.in), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Unmarshal(%s) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Unmarshal(%s) = %v, %v; want %v", tt.in, int64(got), err, int64(tt.want))
+			}
+		})
+	}
+}
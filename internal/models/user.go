@@ -0,0 +1,9 @@
+package models
+
+// User is a known user of the platform. The table is filled by whatever
+// syncs the user base; the API only reads it to tell unknown users from
+// users without segmentations.
+type User struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt int64
+}
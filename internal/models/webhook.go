@@ -0,0 +1,75 @@
+package models
+
+import (
+	"slices"
+	"strings"
+
+	"gorm.io/datatypes"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook body formats
+const (
+	WebhookFormatJSON     = "json"
+	WebhookFormatProtobuf = "protobuf"
+)
+
+// WebhookFormats lists the body formats a webhook can choose
+var WebhookFormats = []string{WebhookFormatJSON, WebhookFormatProtobuf}
+
+// Webhook is a subscription to the change events of the outbox. Events
+// are POSTed to URL signed with Secret; EventTypes is a comma-separated
+// list of the event types sent, empty for all of them. Format is the
+// encoding of the body: JSON, or the segmentation.v1.SegmentationEvent
+// protobuf message.
+type Webhook struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement"`
+	URL        string `gorm:"size:2048;not null"`
+	Secret     string `gorm:"size:128;not null"`
+	EventTypes string `gorm:"size:255;not null"`
+	Format     string `gorm:"size:16;not null;default:json"`
+	Active     bool   `gorm:"not null;default:true"`
+	CreatedAt  int64
+	UpdatedAt  int64
+}
+
+// Subscribed returns the event types sent to the webhook
+func (w Webhook) Subscribed() []string {
+	if w.EventTypes == "" {
+		return slices.Clone(OutboxEventTypes)
+	}
+	return strings.Split(w.EventTypes, ",")
+}
+
+// BodyFormat returns the format of the deliveries; rows written before the
+// format existed are JSON
+func (w Webhook) BodyFormat() string {
+	if w.Format == "" {
+		return WebhookFormatJSON
+	}
+	return w.Format
+}
+
+// WebhookDelivery is an outbox event to be delivered to a webhook, with
+// the outcome of its attempts. A pending delivery is attempted again at
+// NextAttemptAt; it is failed once it runs out of attempts.
+type WebhookDelivery struct {
+	ID             uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
+	WebhookID      uint64         `gorm:"not null" json:"webhook_id"`
+	EventID        uint64         `gorm:"not null" json:"event_id"`
+	EventType      string         `gorm:"size:32;not null" json:"event_type"`
+	Payload        datatypes.JSON `gorm:"type:json" json:"-"`
+	Status         string         `gorm:"size:16;not null" json:"status" enums:"pending,delivered,failed"`
+	Attempts       int            `gorm:"not null" json:"attempts"`
+	LastStatusCode int            `json:"last_status_code,omitempty"`
+	LastError      string         `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt  int64          `gorm:"not null" json:"next_attempt_at,omitempty"`
+	CreatedAt      int64          `gorm:"not null" json:"created_at"`
+	DeliveredAt    *int64         `json:"delivered_at,omitempty"`
+}
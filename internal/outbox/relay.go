@@ -0,0 +1,209 @@
+// Package outbox relays the change events the segmentation repository
+// records in segmentation_outbox, in the same transaction as each write
+// (see mysql.WithOutbox). The relay reads the pending events in write
+// order, hands them to a Publisher and only then marks them published, so
+// a change is never lost: an event whose publication or marking failed is
+// published again on the next pass. Delivery is therefore at least once
+// and consumers must tolerate repeated events.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+const (
+	// purgeInterval is how often published events older than the retention
+	// are deleted
+	purgeInterval = time.Hour
+
+	maxBackoff = 30 * time.Second
+)
+
+// Publisher delivers a batch of events, in order. An error fails the whole
+// batch, which is published again later.
+type Publisher interface {
+	Publish(ctx context.Context, events []models.OutboxEvent) error
+}
+
+// PublisherFunc adapts a function to Publisher
+type PublisherFunc func(ctx context.Context, events []models.OutboxEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	return f(ctx, events)
+}
+
+// LogPublisher publishes each event as an info log entry, for when the
+// events are only consumed by tailing segmentation_outbox with a CDC
+// connector
+func LogPublisher(logger *zap.Logger) Publisher {
+	return PublisherFunc(func(ctx context.Context, events []models.OutboxEvent) error {
+		for _, e := range events {
+			logger.Info("outbox_event",
+				zap.Uint64("id", e.ID),
+				zap.String("event_type", e.EventType),
+				zap.Uint64("user_id", e.UserID),
+				zap.String("seg_type", e.SegmentationType),
+				zap.String("seg_name", e.SegmentationName),
+			)
+		}
+		return nil
+	})
+}
+
+// Publishers publishes each batch to every one of ps, in turn. The first
+// error fails the batch, so the publishers before it get it again on the
+// retry and must tolerate repeated events.
+func Publishers(ps ...Publisher) Publisher {
+	return PublisherFunc(func(ctx context.Context, events []models.OutboxEvent) error {
+		for _, p := range ps {
+			if err := p.Publish(ctx, events); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Relay publishes the pending events of the outbox
+type Relay struct {
+	repo      repository.OutboxRepository
+	publisher Publisher
+	batchSize int
+	interval  time.Duration
+	retention time.Duration
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+// Option customizes a Relay
+type Option func(*Relay)
+
+// WithBatchSize sets how many events are published at a time (default 100)
+func WithBatchSize(n int) Option {
+	return func(r *Relay) {
+		if n > 0 {
+			r.batchSize = n
+		}
+	}
+}
+
+// WithInterval sets how long the relay waits for new events once the
+// outbox is empty (default 1s)
+func WithInterval(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.interval = d
+		}
+	}
+}
+
+// WithRetention deletes published events older than d; 0 (the default)
+// keeps them
+func WithRetention(d time.Duration) Option {
+	return func(r *Relay) {
+		r.retention = max(0, d)
+	}
+}
+
+// WithLogger sets the logger of the relay; by default nothing is logged
+func WithLogger(logger *zap.Logger) Option {
+	return func(r *Relay) {
+		r.logger = logger
+	}
+}
+
+// NewRelay creates a relay of the events of repo to publisher
+func NewRelay(repo repository.OutboxRepository, publisher Publisher, opts ...Option) *Relay {
+	r := &Relay{
+		repo:      repo,
+		publisher: publisher,
+		batchSize: 100,
+		interval:  time.Second,
+		logger:    zap.NewNop(),
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run publishes the pending events until ctx is done. A full batch is
+// followed right away by the next one; failures are retried with
+// exponential backoff.
+func (r *Relay) Run(ctx context.Context) {
+	backoff := r.interval
+	var lastPurge time.Time
+	for ctx.Err() == nil {
+		wait := r.interval
+		n, err := r.RelayOnce(ctx)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			r.logger.Warn("outbox_relay_failed", zap.Duration("retry_in", backoff), zap.Error(err))
+			wait = backoff
+			backoff = min(2*backoff, maxBackoff)
+		case n == r.batchSize:
+			backoff = r.interval
+			wait = 0
+		default:
+			backoff = r.interval
+		}
+
+		if r.retention > 0 && r.now().Sub(lastPurge) >= purgeInterval {
+			lastPurge = r.now()
+			r.purge(ctx)
+		}
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events and marks them
+// published, returning how many there were
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.repo.Pending(ctx, r.batchSize)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	if err := r.publisher.Publish(ctx, events); err != nil {
+		return 0, err
+	}
+
+	ids := make([]uint64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	if err := r.repo.MarkPublished(ctx, ids, r.now().Unix()); err != nil {
+		// published but not marked: the batch goes out again next time
+		return 0, err
+	}
+	r.logger.Debug("outbox_relayed", zap.Int("events", len(events)), zap.Uint64("last_id", ids[len(ids)-1]))
+	return len(events), nil
+}
+
+// purge deletes the events published more than the retention ago
+func (r *Relay) purge(ctx context.Context) {
+	n, err := r.repo.DeletePublished(ctx, r.now().Add(-r.retention).Unix())
+	if err != nil {
+		r.logger.Error("outbox_purge_error", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		r.logger.Info("outbox_purged", zap.Int64("deleted", n))
+	}
+}
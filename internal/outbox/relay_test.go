@@ -0,0 +1,181 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+)
+
+// memoryOutbox is an OutboxRepository over a slice
+type memoryOutbox struct {
+	mu       sync.Mutex
+	events   []models.OutboxEvent
+	markErr  error
+	purgedAt []int64
+}
+
+func (m *memoryOutbox) add(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for range n {
+		id := uint64(len(m.events) + 1)
+		m.events = append(m.events, models.OutboxEvent{ID: id, EventType: models.OutboxUpserted, UserID: id})
+	}
+}
+
+func (m *memoryOutbox) Pending(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.OutboxEvent
+	for _, e := range m.events {
+		if e.PublishedAt == nil && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryOutbox) MarkPublished(ctx context.Context, ids []uint64, at int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.markErr != nil {
+		return m.markErr
+	}
+	for _, id := range ids {
+		m.events[id-1].PublishedAt = &at
+	}
+	return nil
+}
+
+func (m *memoryOutbox) DeletePublished(ctx context.Context, before int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purgedAt = append(m.purgedAt, before)
+	return 0, nil
+}
+
+func (m *memoryOutbox) pending() int {
+	events, _ := m.Pending(context.Background(), len(m.events)+1)
+	return len(events)
+}
+
+// recorder is a Publisher keeping the IDs it published
+type recorder struct {
+	mu   sync.Mutex
+	ids  []uint64
+	fail int // batches to refuse before accepting
+}
+
+func (p *recorder) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail > 0 {
+		p.fail--
+		return errors.New("broker unavailable")
+	}
+	for _, e := range events {
+		p.ids = append(p.ids, e.ID)
+	}
+	return nil
+}
+
+func TestRelayOnce(t *testing.T) {
+	repo := &memoryOutbox{}
+	repo.add(5)
+	pub := &recorder{}
+	r := NewRelay(repo, pub, WithBatchSize(3))
+	ctx := context.Background()
+
+	if n, err := r.RelayOnce(ctx); n != 3 || err != nil {
+		t.Fatalf("RelayOnce() = %d, %v, want 3", n, err)
+	}
+	if n, err := r.RelayOnce(ctx); n != 2 || err != nil {
+		t.Fatalf("RelayOnce() = %d, %v, want 2", n, err)
+	}
+	if n, err := r.RelayOnce(ctx); n != 0 || err != nil {
+		t.Fatalf("RelayOnce() on an empty outbox = %d, %v", n, err)
+	}
+	if len(pub.ids) != 5 || pub.ids[0] != 1 || pub.ids[4] != 5 {
+		t.Errorf("published %v, want 1..5 in order", pub.ids)
+	}
+}
+
+func TestRelayOnce_FailuresKeepEventsPending(t *testing.T) {
+	repo := &memoryOutbox{}
+	repo.add(2)
+	pub := &recorder{fail: 1}
+	r := NewRelay(repo, pub)
+	ctx := context.Background()
+
+	if _, err := r.RelayOnce(ctx); err == nil || repo.pending() != 2 {
+		t.Fatalf("a failed publication should keep the events pending, err = %v", err)
+	}
+
+	repo.markErr = errors.New("connection lost")
+	if _, err := r.RelayOnce(ctx); err == nil || repo.pending() != 2 {
+		t.Fatalf("an unmarked batch should stay pending, err = %v", err)
+	}
+
+	repo.markErr = nil
+	if n, err := r.RelayOnce(ctx); n != 2 || err != nil {
+		t.Fatalf("RelayOnce() = %d, %v", n, err)
+	}
+	// the unmarked batch was delivered twice: at least once
+	if len(pub.ids) != 4 || repo.pending() != 0 {
+		t.Errorf("published %v, %d pending", pub.ids, repo.pending())
+	}
+}
+
+func TestRun(t *testing.T) {
+	repo := &memoryOutbox{}
+	repo.add(7)
+	pub := &recorder{fail: 1}
+	now := time.Unix(1_000_000, 0)
+	r := NewRelay(repo, pub, WithBatchSize(3), WithInterval(time.Millisecond), WithRetention(time.Hour))
+	r.now = func() time.Time { return now }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for repo.pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if repo.pending() != 0 {
+		t.Fatalf("%d events left pending", repo.pending())
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.purgedAt) != 1 || repo.purgedAt[0] != now.Add(-time.Hour).Unix() {
+		t.Errorf("purges = %v, want one before %d", repo.purgedAt, now.Add(-time.Hour).Unix())
+	}
+}
+
+func TestPublishers(t *testing.T) {
+	repo := &memoryOutbox{}
+	repo.add(2)
+	first, second := &recorder{}, &recorder{fail: 1}
+	r := NewRelay(repo, Publishers(first, second))
+	ctx := context.Background()
+
+	if _, err := r.RelayOnce(ctx); err == nil {
+		t.Fatal("a failing publisher should fail the batch")
+	}
+	if n, err := r.RelayOnce(ctx); n != 2 || err != nil {
+		t.Fatalf("RelayOnce() = %d, %v, want 2", n, err)
+	}
+	if len(first.ids) != 4 || len(second.ids) != 2 || repo.pending() != 0 {
+		t.Errorf("first got %v, second got %v, %d pending", first.ids, second.ids, repo.pending())
+	}
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultBatchSize e DefaultFlushInterval são os padrões de
+	// processor.batch_size e processor.flush_interval
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = 200 * time.Millisecond
+)
+
+// batching define os lotes gravados por cada worker
+type batching struct {
+	size     int
+	interval time.Duration
+}
+
+// WithBatching faz cada worker acumular até size registros e gravá-los com
+// um único upsert, quando o lote enche ou interval depois do primeiro
+// registro pendente. Um lote recusado é regravado linha a linha, para que
+// só as linhas com falha vão ao dead-letter. Com size 1 (o padrão) cada
+// registro é gravado sozinho.
+func WithBatching(size int, interval time.Duration) Option {
+	return func(cfg *runConfig) {
+		if interval <= 0 {
+			interval = DefaultFlushInterval
+		}
+		cfg.batching = batching{size: size, interval: interval}
+	}
+}
+
+// collect lê ch até ele fechar e entrega os registros a flush em lotes.
+// flush não pode guardar o slice recebido, que é reaproveitado. Com ctx
+// cancelado os registros pendentes são descartados, como os que ainda
+// estão no channel.
+func collect(ctx context.Context, ch <-chan *record, cfg batching, flush func([]*record)) {
+	pending := make([]*record, 0, cfg.size)
+	timer := time.NewTimer(cfg.interval)
+	timer.Stop()
+	defer timer.Stop()
+
+	send := func() {
+		timer.Stop()
+		if len(pending) > 0 {
+			flush(pending)
+			pending = pending[:0]
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r, ok := <-ch:
+			if !ok {
+				send()
+				return
+			}
+			if len(pending) == 0 {
+				timer.Reset(cfg.interval)
+			}
+			pending = append(pending, r)
+			if len(pending) >= cfg.size {
+				send()
+			}
+		case <-timer.C:
+			send()
+		}
+	}
+}
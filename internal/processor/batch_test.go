@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCollect(t *testing.T) {
+	ch := make(chan *record)
+	var batches [][]int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		collect(context.Background(), ch, batching{size: 3, interval: 20 * time.Millisecond}, func(pending []*record) {
+			var rows []int
+			for _, r := range pending {
+				rows = append(rows, r.row)
+			}
+			batches = append(batches, rows)
+		})
+	}()
+
+	for row := 1; row <= 4; row++ {
+		ch <- &record{row: row}
+	}
+	time.Sleep(60 * time.Millisecond) // row 4 is flushed by the interval
+	ch <- &record{row: 5}
+	close(ch) // row 5 is flushed when the channel closes
+	<-done
+
+	want := [][]int{{1, 2, 3}, {4}, {5}}
+	if fmt.Sprint(batches) != fmt.Sprint(want) {
+		t.Errorf("batches = %v, want %v", batches, want)
+	}
+}
+
+// bulkRepository refuses every batch holding user failUser, whose row
+// also fails on its own
+type bulkRepository struct {
+	MockProcessorRepository
+	failUser uint64
+
+	mu    sync.Mutex
+	bulks int
+}
+
+func (m *bulkRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	m.mu.Lock()
+	m.bulks++
+	m.mu.Unlock()
+	for _, s := range items {
+		if s.UserID == m.failUser {
+			return repository.BulkUpsertResult{}, errors.New("data too long")
+		}
+	}
+	return repository.BulkUpsertResult{Inserted: len(items)}, nil
+}
+
+func TestRun_Batching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n"
+	for i := 1; i <= 1000; i++ {
+		csv += fmt.Sprintf("%d,drug,Aspirina,{}\n", i)
+	}
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &bulkRepository{failUser: 7}
+	repo.upsertFunc = func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+		if s.UserID == repo.failUser {
+			return repository.UpsertNoOp, errors.New("data too long")
+		}
+		return repository.UpsertInserted, nil
+	}
+	runs := &memoryRunStore{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(repo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunStore(runs),
+		WithBatching(100, time.Second),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := runs.updated; got.Inserted != 999 || got.Failed != 1 {
+		t.Errorf("inserted = %d, failed = %d, want 999 and 1", got.Inserted, got.Failed)
+	}
+	// rows are split among the workers, but far fewer statements than rows
+	if repo.bulks == 0 || repo.bulks > 100 {
+		t.Errorf("bulk upserts = %d, want between 1 and 100", repo.bulks)
+	}
+}
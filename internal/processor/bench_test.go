@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"segmentation-api/internal/loadgen"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// BenchmarkRun imports a synthetic file into a repository that accepts
+// everything, measuring the processor without the database
+func BenchmarkRun(b *testing.B) {
+	const rows = 20000
+	path := filepath.Join(b.TempDir(), "bench.csv")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := loadgen.WriteCSV(f, loadgen.CSVConfig{Rows: rows, Users: rows / 5, Names: 50, DataSize: 64, Seed: 1}); err != nil {
+		b.Fatal(err)
+	}
+	f.Close()
+
+	cases := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "rows"},
+		{name: "batched", opts: []Option{WithBatching(DefaultBatchSize, DefaultFlushInterval)}},
+		{name: "read_ahead", opts: []Option{WithBatching(DefaultBatchSize, DefaultFlushInterval), WithReadAhead(4)}},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			svc := service.NewSegmentationService(&MockProcessorRepository{})
+			opts := append([]Option{WithFile(path)}, c.opts...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Run(context.Background(), svc, zap.NewNop(), opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
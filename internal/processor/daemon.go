@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DaemonConfig define quando o processor residente importa o arquivo
+type DaemonConfig struct {
+	// File é o CSV observado
+	File string
+	// WatchInterval é o intervalo entre as verificações do arquivo
+	WatchInterval time.Duration
+	// Schedule reimporta o arquivo mesmo sem mudança (0 desliga)
+	Schedule time.Duration
+	// Heartbeat é chamado a cada verificação, para que o daemon ocioso não
+	// pareça travado às probes
+	Heartbeat func()
+}
+
+// fileStamp identifica uma versão do arquivo
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// Daemon mantém o processor residente até ctx terminar: chama run quando o
+// arquivo aparece ou muda (tamanho ou mtime) e a cada Schedule. Um arquivo
+// só é importado depois de ficar igual por duas verificações seguidas, para
+// não pegar uma cópia pela metade. Os erros de run são registrados e o
+// daemon segue; Daemon só retorna quando ctx termina.
+func Daemon(ctx context.Context, cfg DaemonConfig, run func(ctx context.Context) error, logger *zap.Logger) {
+	ticker := time.NewTicker(cfg.WatchInterval)
+	defer ticker.Stop()
+
+	var (
+		seen      fileStamp // versão da verificação anterior
+		imported  fileStamp // versão do último import
+		lastRun   time.Time
+		lastError string
+	)
+	for {
+		if cfg.Heartbeat != nil {
+			cfg.Heartbeat()
+		}
+
+		stamp, err := statFile(cfg.File)
+		switch {
+		case err != nil:
+			// registra só quando o erro muda, não a cada verificação
+			if err.Error() != lastError {
+				logger.Warn("data_file_unavailable", zap.String("file", cfg.File), zap.Error(err))
+				lastError = err.Error()
+			}
+		case stamp != seen:
+			// mudou desde a última verificação: espera assentar
+			lastError = ""
+		case stamp != imported || cfg.Schedule > 0 && time.Since(lastRun) >= cfg.Schedule:
+			lastError = ""
+			reason := "file_changed"
+			if stamp == imported {
+				reason = "schedule"
+			}
+			logger.Info("daemon_import_started", zap.String("reason", reason))
+			if err := run(ctx); err != nil {
+				logger.Error("daemon_import_error", zap.Error(err))
+			}
+			imported, lastRun = stamp, time.Now()
+		}
+		seen = stamp
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
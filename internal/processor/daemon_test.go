@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDaemon_ImportsWhenFileSettles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+
+	var runs, beats atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Daemon(ctx, DaemonConfig{
+			File:          path,
+			WatchInterval: 5 * time.Millisecond,
+			Heartbeat:     func() { beats.Add(1) },
+		}, func(context.Context) error {
+			runs.Add(1)
+			return nil
+		}, zaptest.NewLogger(t))
+	}()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// no file yet: the daemon waits without importing
+	waitFor("heartbeats", func() bool { return beats.Load() > 3 })
+	if runs.Load() != 0 {
+		t.Fatalf("imported %d times without a file", runs.Load())
+	}
+
+	if err := os.WriteFile(path, []byte("user_id\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the first import", func() bool { return runs.Load() == 1 })
+
+	// unchanged file: no new import
+	b := beats.Load()
+	waitFor("more heartbeats", func() bool { return beats.Load() > b+3 })
+	if runs.Load() != 1 {
+		t.Fatalf("imported %d times, want 1 for an unchanged file", runs.Load())
+	}
+
+	if err := os.WriteFile(path, []byte("user_id\n1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the import of the new file", func() bool { return runs.Load() == 2 })
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Daemon did not return after the context was cancelled")
+	}
+}
+
+func TestDaemon_Schedule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("user_id\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs atomic.Int32
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	Daemon(ctx, DaemonConfig{File: path, WatchInterval: 2 * time.Millisecond, Schedule: 20 * time.Millisecond},
+		func(context.Context) error {
+			runs.Add(1)
+			return nil
+		}, zaptest.NewLogger(t))
+
+	if n := runs.Load(); n < 2 {
+		t.Errorf("imported %d times, want the schedule to re-import an unchanged file", n)
+	}
+}
@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"context"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+const (
+	deadLetterBatchSize     = 200
+	deadLetterFlushInterval = time.Second
+
+	// maxRawLine mantém raw_line dentro de uma coluna TEXT (64 KiB): uma
+	// linha rejeitada por um data gigante é gravada truncada
+	maxRawLine = 60000
+)
+
+// deadLetterWriter grava as linhas rejeitadas em lotes, fora do hot path.
+// Um writer nil descarta tudo, então o processor funciona sem o repositório.
+type deadLetterWriter struct {
+	store  repository.DeadLetterRepository
+	runID  string
+	logger *zap.Logger
+	ch     chan models.DeadLetter
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newDeadLetterWriter(
+	ctx context.Context,
+	store repository.DeadLetterRepository,
+	runID string,
+	logger *zap.Logger,
+) *deadLetterWriter {
+
+	if store == nil {
+		return nil
+	}
+
+	w := &deadLetterWriter{
+		store:  store,
+		runID:  runID,
+		logger: logger,
+		ch:     make(chan models.DeadLetter, deadLetterBatchSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	// entradas de um run cancelado também precisam ser gravadas
+	go w.loop(context.WithoutCancel(ctx))
+	return w
+}
+
+func (w *deadLetterWriter) add(row int, fields []string, reason error) {
+	if w == nil {
+		return
+	}
+	entry := models.DeadLetter{
+		RunID:     w.runID,
+		RowNumber: row,
+		UserID:    rawUserID(fields),
+		RawLine:   rawLine(fields),
+		Error:     reason.Error(),
+		CreatedAt: time.Now().Unix(),
+	}
+	// um worker abandonado pelo drain timeout não pode travar aqui depois
+	// que o writer foi fechado
+	select {
+	case w.ch <- entry:
+	case <-w.stop:
+		w.logger.Warn("dead_letter_dropped", zap.Int("row", row))
+	}
+}
+
+// close grava o que restou no buffer e espera o término; depois que ctx é
+// cancelado espera no máximo timeout e devolve false se a gravação travou
+func (w *deadLetterWriter) close(ctx context.Context, timeout time.Duration) bool {
+	if w == nil {
+		return true
+	}
+	close(w.stop)
+	return drain(ctx, timeout, func() { <-w.done })
+}
+
+func (w *deadLetterWriter) loop(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(deadLetterFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.DeadLetter, 0, deadLetterBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.store.Insert(ctx, batch); err != nil {
+			w.logger.Error("dead_letter_insert_error", zap.Int("entries", len(batch)), zap.Error(err))
+		}
+		batch = make([]models.DeadLetter, 0, deadLetterBatchSize)
+	}
+
+	for {
+		select {
+		case entry := <-w.ch:
+			batch = append(batch, entry)
+			if len(batch) == deadLetterBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-w.stop:
+			// ch nunca é fechado: add pode correr com close
+			for {
+				select {
+				case entry := <-w.ch:
+					batch = append(batch, entry)
+					if len(batch) == deadLetterBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// rawUserID lê o user_id da primeira coluna para a eliminação dos dados do
+// usuário; mais tolerante que ParseUserID, já que a linha pode ter sido
+// rejeitada justamente pelo user_id (" 12" ou "012" ainda são do usuário 12)
+func rawUserID(fields []string) uint64 {
+	if len(fields) == 0 {
+		return 0
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(fields[0]), "+"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// rawLine reconstrói a linha CSV original a partir dos campos lidos
+func rawLine(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+	cw.Write(fields)
+	cw.Flush()
+	line := strings.TrimRight(b.String(), "\n")
+	if len(line) > maxRawLine {
+		line = strings.ToValidUTF8(line[:maxRawLine], "")
+	}
+	return line
+}
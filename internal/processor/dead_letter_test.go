@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"segmentation-api/internal/models"
+
+	"go.uber.org/zap/zaptest"
+)
+
+type memoryDeadLetterStore struct {
+	mu      sync.Mutex
+	entries []models.DeadLetter
+	batches int
+}
+
+func (m *memoryDeadLetterStore) Insert(ctx context.Context, entries []models.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entries...)
+	m.batches++
+	return nil
+}
+
+func (m *memoryDeadLetterStore) List(ctx context.Context, runID string, afterID uint64, limit int) ([]models.DeadLetter, error) {
+	return nil, nil
+}
+
+func TestDeadLetterWriter_FlushesOnClose(t *testing.T) {
+	store := &memoryDeadLetterStore{}
+	w := newDeadLetterWriter(context.Background(), store, "run-1", zaptest.NewLogger(t))
+
+	total := deadLetterBatchSize + 3
+	for i := 0; i < total; i++ {
+		w.add(i+2, []string{"1", "drug", "Aspirina, 500mg", "{}"}, errors.New("boom"))
+	}
+	if !w.close(context.Background(), 0) {
+		t.Fatal("close did not finish")
+	}
+
+	if len(store.entries) != total {
+		t.Fatalf("entries = %d, want %d", len(store.entries), total)
+	}
+	if store.batches < 2 {
+		t.Errorf("batches = %d, want at least 2", store.batches)
+	}
+
+	first := store.entries[0]
+	if first.RunID != "run-1" || first.RowNumber != 2 || first.UserID != 1 || first.Error != "boom" {
+		t.Errorf("unexpected entry: %+v", first)
+	}
+	if first.RawLine != `1,drug,"Aspirina, 500mg",{}` {
+		t.Errorf("raw line = %q", first.RawLine)
+	}
+}
+
+func TestRawLine_Truncated(t *testing.T) {
+	line := rawLine([]string{"1", "drug", "Aspirina", strings.Repeat("ç", maxRawLine)})
+	if len(line) > maxRawLine || !utf8.ValidString(line) || !strings.HasPrefix(line, "1,drug,Aspirina,") {
+		t.Errorf("raw line of %d bytes, valid UTF-8 %v", len(line), utf8.ValidString(line))
+	}
+}
+
+func TestRawUserID(t *testing.T) {
+	for v, want := range map[string]uint64{"12": 12, " 012": 12, "+12": 12, "abc": 0, "-1": 0, "": 0} {
+		if got := rawUserID([]string{v, "drug"}); got != want {
+			t.Errorf("rawUserID(%q) = %d, want %d", v, got, want)
+		}
+	}
+	if rawUserID(nil) != 0 {
+		t.Error("a row without columns has no user")
+	}
+}
+
+func TestDeadLetterWriter_NilIsNoOp(t *testing.T) {
+	w := newDeadLetterWriter(context.Background(), nil, "run-1", zaptest.NewLogger(t))
+	if w != nil {
+		t.Fatal("writer without store should be nil")
+	}
+
+	// must not panic
+	w.add(1, nil, errors.New("ignored"))
+	w.close(context.Background(), 0)
+}
+
+// blockingDeadLetterStore trava em Insert até release, ignorando ctx
+type blockingDeadLetterStore struct {
+	release chan struct{}
+}
+
+func (b *blockingDeadLetterStore) Insert(ctx context.Context, entries []models.DeadLetter) error {
+	<-b.release
+	return nil
+}
+
+func (b *blockingDeadLetterStore) List(ctx context.Context, runID string, afterID uint64, limit int) ([]models.DeadLetter, error) {
+	return nil, nil
+}
+
+func TestDeadLetterWriter_CloseBoundedAfterCancel(t *testing.T) {
+	store := &blockingDeadLetterStore{release: make(chan struct{})}
+	defer close(store.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newDeadLetterWriter(ctx, store, "run-1", zaptest.NewLogger(t))
+	for i := 0; i < deadLetterBatchSize; i++ {
+		w.add(i+2, []string{"1"}, errors.New("boom"))
+	}
+	cancel()
+
+	if w.close(ctx, 10*time.Millisecond) {
+		t.Fatal("close should give up on a stuck insert")
+	}
+	// depois do close add descarta em vez de travar com o buffer cheio
+	for i := 0; i < 2*deadLetterBatchSize; i++ {
+		w.add(i+2, []string{"1"}, errors.New("late"))
+	}
+}
@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultDrainTimeout é quanto um run cancelado espera workers, parsers e
+// o dead-letter terminarem antes de abandoná-los
+const DefaultDrainTimeout = 30 * time.Second
+
+// ErrDrainTimeout encerra um run cancelado cujas goroutines não pararam
+// dentro do drain timeout; o que elas ainda escreviam fica para trás
+var ErrDrainTimeout = errors.New("processor did not drain before the deadline")
+
+// WithDrainTimeout limita a espera pelas goroutines do run depois que ctx
+// é cancelado, para que o cancelamento sempre encerre o run mesmo com uma
+// escrita travada. Com 0 a espera não tem limite. Sem cancelamento o fim
+// do arquivo espera as escritas pendentes o quanto for preciso.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(cfg *runConfig) {
+		cfg.drainTimeout = d
+	}
+}
+
+// drain chama wait e espera que ele volte. Enquanto ctx está ativo espera
+// sem limite; depois do cancelamento espera no máximo timeout e devolve
+// false, deixando wait rodando sozinho.
+func drain(ctx context.Context, timeout time.Duration, wait func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait()
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+	}
+	if timeout <= 0 {
+		<-done
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrain_WaitsWhileContextIsAlive(t *testing.T) {
+	release := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	if !drain(context.Background(), time.Millisecond, func() { <-release }) {
+		t.Fatal("drain gave up without a cancellation")
+	}
+}
+
+func TestDrain_TimeoutAfterCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if drain(ctx, 10*time.Millisecond, func() { <-release }) {
+		t.Fatal("drain should give up on a stuck wait")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("drain took %v", elapsed)
+	}
+}
+
+func TestDrain_ZeroTimeoutWaits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	release := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	if !drain(ctx, 0, func() { <-release }) {
+		t.Fatal("drain with no timeout should wait")
+	}
+}
@@ -2,14 +2,14 @@ package processor
 
 import (
 	"context"
-	"log"
-	"os"
 	"testing"
 
 	"segmentation-api/internal/models"
 	"segmentation-api/internal/repository"
 	"segmentation-api/internal/service"
 
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
 	"gorm.io/datatypes"
 )
 
@@ -27,6 +27,10 @@ func (m *ServiceIntegrationMock) FindByUserID(ctx context.Context, userID uint64
 	return []models.Segmentation{}, nil
 }
 
+func (m *ServiceIntegrationMock) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func (m *ServiceIntegrationMock) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
 	m.createCalls = append(m.createCalls, struct {
 		userID   uint64
@@ -40,6 +44,18 @@ func (m *ServiceIntegrationMock) Upsert(ctx context.Context, s *models.Segmentat
 	return m.result, nil
 }
 
+func (m *ServiceIntegrationMock) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *ServiceIntegrationMock) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *ServiceIntegrationMock) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
 // TestIntegration_ProcessorCallsService verifies processor -> service integration
 func TestIntegration_ProcessorCallsService(t *testing.T) {
 	mockRepo := &ServiceIntegrationMock{
@@ -47,11 +63,11 @@ func TestIntegration_ProcessorCallsService(t *testing.T) {
 	}
 
 	svc := service.NewSegmentationService(mockRepo)
-	_ = log.New(os.Stderr, "[TEST] ", 0)
+	_ = zaptest.NewLogger(t)
 
 	// Test that Run doesn't panic with cancelled context
 	ctx := context.Background()
-	err := Run(ctx, svc, log.New(os.Stderr, "[TEST] ", 0))
+	_, err := Run(ctx, svc, zaptest.NewLogger(t))
 
 	// Error is expected if data.csv doesn't exist, but should not panic
 	t.Logf("Run completed with result: %v", err)
@@ -64,7 +80,7 @@ func TestIntegration_ProcessorServiceChain(t *testing.T) {
 	}
 
 	svc := service.NewSegmentationService(mockRepo)
-	_ = log.New(os.Stderr, "[TEST] ", 0)
+	_ = zaptest.NewLogger(t)
 
 	ctx := context.Background()
 
@@ -152,7 +168,7 @@ func TestIntegration_ProcessorServiceErrorHandling(t *testing.T) {
 	}
 
 	svc := service.NewSegmentationService(errorRepo)
-	logger := log.New(os.Stderr, "[TEST] ", 0)
+	logger := zaptest.NewLogger(t)
 
 	// Verify service can be created with context
 	if svc == nil {
@@ -160,7 +176,7 @@ func TestIntegration_ProcessorServiceErrorHandling(t *testing.T) {
 	}
 
 	// Verify logger works
-	logger.Println("test log message")
+	logger.Info("test log message")
 	t.Log("Service and logger initialized successfully")
 }
 
@@ -171,7 +187,7 @@ func TestIntegration_LoggerWithProcessor(t *testing.T) {
 	}
 
 	svc := service.NewSegmentationService(mockRepo)
-	logger := log.New(os.Stderr, "[PROCESSOR] ", log.LstdFlags)
+	logger := zaptest.NewLogger(t)
 
 	ctx := context.Background()
 
@@ -183,14 +199,14 @@ func TestIntegration_LoggerWithProcessor(t *testing.T) {
 		Data:             datatypes.JSON(`{}`),
 	}
 
-	logger.Printf("Processing segmentation: %s", seg.SegmentationName)
+	logger.Info("processing segmentation", zap.String("name", seg.SegmentationName))
 	result, _ := svc.Create(ctx, seg)
 
 	if result != repository.UpsertInserted {
 		t.Fatal("create should succeed")
 	}
 
-	logger.Printf("Successfully processed segmentation for user %d", seg.UserID)
+	logger.Info("processed segmentation", zap.Uint64("user_id", seg.UserID))
 	t.Log("Logger output test passed")
 }
 
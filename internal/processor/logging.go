@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogMode controla o log dos registros gravados com sucesso
+type LogMode string
+
+const (
+	// LogRows registra cada registro gravado em debug
+	LogRows LogMode = "rows"
+	// LogAggregate registra só o progresso periódico e os erros; os
+	// sucessos podem ser amostrados em info
+	LogAggregate LogMode = "aggregate"
+)
+
+const defaultProgressInterval = 2 * time.Second
+
+// LogConfig define o volume de log de um run
+type LogConfig struct {
+	Mode LogMode
+	// SuccessSample é a fração dos sucessos registrada no modo aggregate
+	// (0 desliga, 1 registra todos)
+	SuccessSample float64
+	// ProgressInterval é o intervalo entre as linhas de progresso
+	ProgressInterval time.Duration
+}
+
+// DefaultLogConfig registra cada registro em debug e o progresso a cada 2s
+func DefaultLogConfig() LogConfig {
+	return LogConfig{Mode: LogRows, ProgressInterval: defaultProgressInterval}
+}
+
+// LogConfigFromConfig converte a configuração do processor: log_mode
+// (rows ou aggregate), log_sample (0 a 1) e progress_interval
+func LogConfigFromConfig(cfg config.Processor) (LogConfig, error) {
+	logs := DefaultLogConfig()
+
+	switch mode := LogMode(strings.ToLower(strings.TrimSpace(cfg.LogMode))); mode {
+	case "":
+	case LogRows, LogAggregate:
+		logs.Mode = mode
+	default:
+		return logs, fmt.Errorf("invalid processor log mode %q", mode)
+	}
+
+	if cfg.LogSample < 0 || cfg.LogSample > 1 {
+		return logs, fmt.Errorf("invalid processor log sample %v: must be between 0 and 1", cfg.LogSample)
+	}
+	logs.SuccessSample = cfg.LogSample
+
+	if cfg.ProgressInterval < 0 {
+		return logs, fmt.Errorf("invalid processor progress interval %s", cfg.ProgressInterval)
+	}
+	if cfg.ProgressInterval > 0 {
+		logs.ProgressInterval = cfg.ProgressInterval
+	}
+
+	return logs, nil
+}
+
+// WithLogConfig define o modo de log do run; sem ProgressInterval usa 2s
+func WithLogConfig(cfg LogConfig) Option {
+	return func(rc *runConfig) {
+		if cfg.ProgressInterval <= 0 {
+			cfg.ProgressInterval = defaultProgressInterval
+		}
+		rc.logs = cfg
+	}
+}
+
+// successLog decide quais sucessos viram uma linha de log. A amostragem é
+// determinística (um a cada N) para não sortear no hot path.
+type successLog struct {
+	mode  LogMode
+	every uint64
+	count uint64
+}
+
+func newSuccessLog(cfg LogConfig) *successLog {
+	s := &successLog{mode: cfg.Mode}
+	if cfg.SuccessSample > 0 {
+		s.every = uint64(math.Round(1 / cfg.SuccessSample))
+	}
+	return s
+}
+
+// check devolve a entrada a ser escrita, ou nil quando o sucesso não é
+// registrado
+func (s *successLog) check(logger *zap.Logger, event string) *zapcore.CheckedEntry {
+	if s.mode != LogAggregate {
+		return logger.Check(zap.DebugLevel, event)
+	}
+	if s.every == 0 || atomic.AddUint64(&s.count, 1)%s.every != 0 {
+		return nil
+	}
+	return logger.Check(zap.InfoLevel, event)
+}
@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogConfigFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Processor
+		want    LogConfig
+		wantErr bool
+	}{
+		{name: "defaults", want: DefaultLogConfig()},
+		{
+			name: "aggregate with sampling",
+			cfg:  config.Processor{LogMode: "Aggregate", LogSample: 0.01, ProgressInterval: 10 * time.Second},
+			want: LogConfig{Mode: LogAggregate, SuccessSample: 0.01, ProgressInterval: 10 * time.Second},
+		},
+		{name: "invalid mode", cfg: config.Processor{LogMode: "silent"}, wantErr: true},
+		{name: "sample out of range", cfg: config.Processor{LogSample: 2}, wantErr: true},
+		{name: "invalid interval", cfg: config.Processor{ProgressInterval: -time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LogConfigFromConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LogConfigFromConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("LogConfigFromConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuccessLog(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   LogConfig
+		want  int
+		level zapcore.Level
+	}{
+		{name: "rows logs every success in debug", cfg: LogConfig{Mode: LogRows}, want: 100, level: zapcore.DebugLevel},
+		{name: "aggregate without sampling", cfg: LogConfig{Mode: LogAggregate}, want: 0},
+		{name: "aggregate sampled", cfg: LogConfig{Mode: LogAggregate, SuccessSample: 0.1}, want: 10, level: zapcore.InfoLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			logger := zap.New(core)
+			successes := newSuccessLog(tt.cfg)
+
+			for i := 0; i < 100; i++ {
+				if ce := successes.check(logger, "upsert_inserted"); ce != nil {
+					ce.Write()
+				}
+			}
+
+			entries := logs.All()
+			if len(entries) != tt.want {
+				t.Fatalf("logged %d successes, want %d", len(entries), tt.want)
+			}
+			for _, e := range entries {
+				if e.Level != tt.level {
+					t.Errorf("level = %s, want %s", e.Level, tt.level)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// ErrDatabaseOutage encerra um run que ficou pausado mais que
+// OutagePolicy.MaxPause esperando o banco voltar
+var ErrDatabaseOutage = errors.New("database outage")
+
+// outageMinBackoff é o primeiro intervalo entre os pings de um run
+// pausado, ou MaxBackoff se for menor
+const outageMinBackoff = time.Second
+
+// OutagePolicy decide quando um run pausa por falta de banco: quando ao
+// menos Threshold das últimas Window escritas falharam por conexão. Pausado,
+// o run para de ler o CSV, pinga o banco com backoff exponencial (de 1s até
+// MaxBackoff) e retoma sozinho quando ele responde, regravando as linhas
+// que falharam em vez de contá-las como failed.
+type OutagePolicy struct {
+	// Threshold é a fração (0 a 1) de falhas de conexão que pausa o run;
+	// 0 desliga a pausa
+	Threshold float64
+	// Window é quantas escritas recentes são consideradas
+	Window int
+	// MaxBackoff limita o intervalo entre os pings
+	MaxBackoff time.Duration
+	// MaxPause encerra o run com ErrDatabaseOutage se o banco não voltar;
+	// 0 espera indefinidamente
+	MaxPause time.Duration
+}
+
+// OutagePolicyFromConfig converte processor.outage_threshold,
+// outage_window, outage_max_backoff e outage_max_pause
+func OutagePolicyFromConfig(cfg config.Processor) (OutagePolicy, error) {
+	policy := OutagePolicy{
+		Threshold:  cfg.OutageThreshold,
+		Window:     cfg.OutageWindow,
+		MaxBackoff: cfg.OutageMaxBackoff,
+		MaxPause:   cfg.OutageMaxPause,
+	}
+	switch {
+	case policy.Threshold < 0 || policy.Threshold > 1:
+		return policy, fmt.Errorf("invalid processor outage threshold %v: must be between 0 and 1", policy.Threshold)
+	case policy.Threshold > 0 && policy.Window < 1:
+		return policy, fmt.Errorf("invalid processor outage window %d: must be positive", policy.Window)
+	case policy.Threshold > 0 && policy.MaxBackoff < outageMinBackoff:
+		return policy, fmt.Errorf("invalid processor outage max backoff %s: must be at least %s", policy.MaxBackoff, outageMinBackoff)
+	case policy.MaxPause < 0:
+		return policy, fmt.Errorf("invalid processor outage max pause %s: must not be negative", policy.MaxPause)
+	}
+	return policy, nil
+}
+
+type outageConfig struct {
+	policy OutagePolicy
+	ping   func(ctx context.Context) error
+}
+
+// WithOutagePause pausa o run durante quedas do banco segundo policy;
+// ping confere se o banco voltou. Não vale para o run transacional, que
+// perde a transação junto com a conexão.
+func WithOutagePause(policy OutagePolicy, ping func(ctx context.Context) error) Option {
+	return func(cfg *runConfig) {
+		if policy.Threshold <= 0 || ping == nil {
+			cfg.outage = nil
+			return
+		}
+		cfg.outage = &outageConfig{policy: policy, ping: ping}
+	}
+}
+
+// outageGate acompanha o resultado das escritas e pausa o run quando as
+// falhas de conexão passam do limite. Os métodos aceitam um gate nil (pausa
+// desligada) e então não fazem nada.
+type outageGate struct {
+	outageConfig
+	logger *zap.Logger
+	// abort cancela o run quando a pausa passa de MaxPause
+	abort context.CancelCauseFunc
+
+	// paused é lido sem o lock por producer e workers
+	paused atomic.Bool
+
+	mu       sync.Mutex
+	window   []bool // anel: true é uma falha de conexão
+	next     int
+	failures int
+	resumed  chan struct{} // fechado quando a pausa termina
+}
+
+func newOutageGate(cfg *outageConfig, abort context.CancelCauseFunc, logger *zap.Logger) *outageGate {
+	if cfg == nil {
+		return nil
+	}
+	return &outageGate{
+		outageConfig: *cfg,
+		logger:       logger,
+		abort:        abort,
+		window:       make([]bool, cfg.policy.Window),
+	}
+}
+
+// ok registra n escritas que não falharam por conexão
+func (g *outageGate) ok(n int) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.observe(false, n)
+	g.mu.Unlock()
+}
+
+// retry registra n escritas que falharam com err e informa se elas devem
+// ser regravadas: só falhas de conexão com o run pausado, depois que o
+// banco voltou. Com o run cancelado ou a pausa longa demais, false.
+func (g *outageGate) retry(ctx context.Context, err error, n int) bool {
+	if g == nil {
+		return false
+	}
+	unavailable := errors.Is(err, repository.ErrUnavailable)
+	g.mu.Lock()
+	g.observe(unavailable, n)
+	if unavailable && !g.paused.Load() && g.tripped() {
+		g.pause(ctx)
+	}
+	paused := g.paused.Load()
+	g.mu.Unlock()
+
+	if !unavailable || !paused {
+		return false
+	}
+	return g.wait(ctx) == nil
+}
+
+// wait bloqueia enquanto o run está pausado; o erro é o de ctx quando o
+// run é cancelado antes do banco voltar
+func (g *outageGate) wait(ctx context.Context) error {
+	if g == nil || !g.paused.Load() {
+		return ctx.Err()
+	}
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-resumed:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pausing informa se o run está pausado, para o heartbeat não dar o
+// processor como travado durante a queda
+func (g *outageGate) pausing() bool {
+	return g != nil && g.paused.Load()
+}
+
+// observe registra n resultados no anel; chamado com o lock
+func (g *outageGate) observe(failed bool, n int) {
+	for range min(n, len(g.window)) {
+		if g.window[g.next] {
+			g.failures--
+		}
+		g.window[g.next] = failed
+		if failed {
+			g.failures++
+		}
+		g.next = (g.next + 1) % len(g.window)
+	}
+}
+
+// tripped informa se as falhas do anel passam do limite; o anel não
+// precisa estar cheio, então um run que começa durante a queda pausa na
+// Threshold*Window-ésima falha. Chamado com o lock.
+func (g *outageGate) tripped() bool {
+	return float64(g.failures) >= g.policy.Threshold*float64(len(g.window))
+}
+
+// pause suspende o run e começa a pingar o banco; chamado com o lock
+func (g *outageGate) pause(ctx context.Context) {
+	g.logger.Warn("database_outage_paused",
+		zap.Int("failures", g.failures),
+		zap.Int("window", len(g.window)),
+	)
+	g.resumed = make(chan struct{})
+	g.paused.Store(true)
+	go g.recover(ctx, g.resumed)
+}
+
+// recover pinga o banco com backoff exponencial até ele responder, o run
+// ser cancelado ou a pausa passar de MaxPause
+func (g *outageGate) recover(ctx context.Context, resumed chan struct{}) {
+	start := time.Now()
+	backoff := min(outageMinBackoff, g.policy.MaxBackoff)
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	defer func() {
+		g.mu.Lock()
+		// o anel recomeça: as falhas da queda não contam para a próxima
+		clear(g.window)
+		g.next, g.failures = 0, 0
+		g.paused.Store(false)
+		close(resumed)
+		g.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		err := g.ping(ctx)
+		if err == nil {
+			g.logger.Info("database_outage_resumed", zap.Duration("paused", time.Since(start)))
+			return
+		}
+		if limit := g.policy.MaxPause; limit > 0 && time.Since(start) >= limit {
+			g.logger.Error("database_outage_aborted", zap.Duration("paused", time.Since(start)), zap.Error(err))
+			g.abort(fmt.Errorf("%w: database unreachable for %s: %w", ErrDatabaseOutage, limit, err))
+			return
+		}
+		g.logger.Debug("database_ping_failed", zap.Duration("backoff", backoff), zap.Error(err))
+
+		backoff = min(2*backoff, g.policy.MaxBackoff)
+		timer.Reset(backoff)
+	}
+}
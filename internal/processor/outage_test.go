@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// outageCSV gera n linhas válidas
+func outageCSV(t *testing.T, n int) string {
+	var rows strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&rows, "%d,drug,Aspirina,{}\n", i)
+	}
+	return writeCSV(t, rows.String())
+}
+
+func TestRun_PausesDuringOutage(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	repo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			if down.Load() {
+				return repository.UpsertNoOp, &repository.UnavailableError{}
+			}
+			return repository.UpsertInserted, nil
+		},
+	}
+	// o banco volta no terceiro ping
+	var pings atomic.Int32
+	ping := func(ctx context.Context) error {
+		if pings.Add(1) < 3 {
+			return errors.New("connection refused")
+		}
+		down.Store(false)
+		return nil
+	}
+
+	stats, err := Run(
+		context.Background(),
+		service.NewSegmentationService(repo),
+		zaptest.NewLogger(t),
+		WithFile(outageCSV(t, 50)),
+		WithWorkers(1),
+		WithOutagePause(OutagePolicy{Threshold: 0.5, Window: 10, MaxBackoff: 5 * time.Millisecond}, ping),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// as falhas anteriores à pausa contam; a que a disparou é regravada
+	if stats.Failed != 4 || stats.Inserted != 46 {
+		t.Errorf("failed = %d, inserted = %d; want 4 and 46", stats.Failed, stats.Inserted)
+	}
+	if pings.Load() != 3 {
+		t.Errorf("pings = %d, want 3", pings.Load())
+	}
+}
+
+func TestRun_OutageLongerThanMaxPause(t *testing.T) {
+	repo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			return repository.UpsertNoOp, &repository.UnavailableError{}
+		},
+	}
+	runs := &memoryRunStore{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(repo),
+		zaptest.NewLogger(t),
+		WithFile(outageCSV(t, 50)),
+		WithRunStore(runs),
+		WithOutagePause(OutagePolicy{Threshold: 1, Window: 5, MaxBackoff: time.Millisecond, MaxPause: 20 * time.Millisecond},
+			func(ctx context.Context) error { return errors.New("connection refused") }),
+	)
+	if !errors.Is(err, ErrDatabaseOutage) {
+		t.Fatalf("Run() error = %v, want ErrDatabaseOutage", err)
+	}
+	if runs.updated == nil || runs.updated.Status != models.RunFailed {
+		t.Errorf("unexpected finished run: %+v", runs.updated)
+	}
+}
+
+func TestRun_OtherErrorsDoNotPause(t *testing.T) {
+	repo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			return repository.UpsertNoOp, errors.New("data too long for column")
+		},
+	}
+
+	stats, err := Run(
+		context.Background(),
+		service.NewSegmentationService(repo),
+		zaptest.NewLogger(t),
+		WithFile(outageCSV(t, 20)),
+		WithOutagePause(OutagePolicy{Threshold: 0.5, Window: 5, MaxBackoff: time.Millisecond},
+			func(ctx context.Context) error {
+				t.Error("a run without connection errors should not ping")
+				return nil
+			}),
+	)
+	if err != nil || stats.Failed != 20 {
+		t.Fatalf("Run() = %+v, %v; want 20 failed rows", stats, err)
+	}
+}
+
+func TestOutagePolicyFromConfig(t *testing.T) {
+	cfg := config.Processor{OutageThreshold: 0.5, OutageWindow: 100, OutageMaxBackoff: 30 * time.Second}
+	if _, err := OutagePolicyFromConfig(cfg); err != nil {
+		t.Fatalf("OutagePolicyFromConfig() error = %v", err)
+	}
+
+	for _, mutate := range []func(*config.Processor){
+		func(c *config.Processor) { c.OutageThreshold = 1.5 },
+		func(c *config.Processor) { c.OutageWindow = 0 },
+		func(c *config.Processor) { c.OutageMaxBackoff = time.Millisecond },
+		func(c *config.Processor) { c.OutageMaxPause = -time.Second },
+	} {
+		bad := cfg
+		mutate(&bad)
+		if _, err := OutagePolicyFromConfig(bad); err == nil {
+			t.Errorf("OutagePolicyFromConfig(%+v) should fail", bad)
+		}
+	}
+}
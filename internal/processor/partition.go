@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/service"
+)
+
+// ClaimPartition é o índice que pede ao run store a primeira partição livre
+// do job
+const ClaimPartition = -1
+
+// partition é a fatia do arquivo gravada pelo run; count 1 grava tudo
+type partition struct {
+	jobID string
+	index int
+	count int
+}
+
+// WithPartition divide o arquivo em count partições por user_id e grava só
+// a de índice index. As instâncias de uma mesma importação usam o mesmo
+// jobID; com index ClaimPartition a partição é escolhida pelo run store
+// (WithRunStore) entre as que não têm run em andamento ou concluído.
+func WithPartition(jobID string, index, count int) Option {
+	return func(cfg *runConfig) {
+		cfg.partition = partition{jobID: jobID, index: index, count: count}
+	}
+}
+
+// PartitionOf é a partição de userID entre partitions. Os bits são
+// misturados (splitmix64) para que IDs sequenciais ou com passo fixo se
+// espalhem por igual.
+func PartitionOf(userID uint64, partitions int) int {
+	z := userID + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return int(z % uint64(partitions))
+}
+
+// split reporta se o arquivo é dividido entre instâncias
+func (p partition) split() bool {
+	return p.count > 1
+}
+
+// primary reporta se o run fica com as linhas sem partição (ilegíveis ou
+// sem user_id válido), para que entrem uma vez só nos contadores e no
+// dead-letter
+func (p partition) primary() bool {
+	return p.index == 0
+}
+
+// owns reporta se a linha pertence à partição do run
+func (p partition) owns(row []string) bool {
+	if !p.split() {
+		return true
+	}
+	if len(row) < 4 {
+		return p.primary()
+	}
+	userID, err := service.ParseUserID(row[0], 0)
+	if err != nil {
+		return p.primary()
+	}
+	return PartitionOf(userID, p.count) == p.index
+}
+
+// PendingPartitions lista as partições do job sem run concluído com
+// sucesso; vazia quando a importação inteira terminou
+func PendingPartitions(runs []models.Run, partitions int) []int {
+	done := make(map[int]bool, partitions)
+	for _, r := range runs {
+		if r.Status == models.RunSucceeded {
+			done[r.Partition] = true
+		}
+	}
+	var pending []int
+	for i := 0; i < partitions; i++ {
+		if !done[i] {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
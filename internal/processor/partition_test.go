@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPartitionOf_Spread(t *testing.T) {
+	const partitions = 4
+	counts := make([]int, partitions)
+	for id := uint64(1); id <= 10000; id++ {
+		p := PartitionOf(id*partitions, partitions) // IDs with a stride equal to the partition count
+		if p < 0 || p >= partitions {
+			t.Fatalf("PartitionOf(%d) = %d, out of range", id, p)
+		}
+		counts[p]++
+	}
+	for p, n := range counts {
+		if n < 2000 || n > 3000 {
+			t.Errorf("partition %d got %d of 10000 users, want about 2500", p, n)
+		}
+	}
+}
+
+func TestPartition_Owns(t *testing.T) {
+	first := partition{index: 0, count: 2}
+	other := partition{index: 1, count: 2}
+
+	for _, row := range [][]string{{"abc", "drug", "x", "{}"}, {"1", "drug"}} {
+		if !first.owns(row) || other.owns(row) {
+			t.Errorf("row %v without a partition should belong to partition 0 only", row)
+		}
+	}
+
+	row := []string{"42", "drug", "x", "{}"}
+	if first.owns(row) == other.owns(row) {
+		t.Errorf("row %v should belong to exactly one partition", row)
+	}
+	if !(partition{}).owns(row) {
+		t.Error("an unpartitioned run owns every row")
+	}
+}
+
+func TestPendingPartitions(t *testing.T) {
+	runs := []models.Run{
+		{Partition: 0, Status: models.RunSucceeded},
+		{Partition: 1, Status: models.RunFailed},
+		{Partition: 2, Status: models.RunRunning},
+		{Partition: 1, Status: models.RunSucceeded},
+	}
+	if got := PendingPartitions(runs, 4); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("PendingPartitions() = %v, want [2 3]", got)
+	}
+	if got := PendingPartitions(runs[:1], 1); got != nil {
+		t.Errorf("PendingPartitions() = %v, want none", got)
+	}
+}
+
+func TestRun_Partitioned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n" + "abc,drug,Aspirina,{}\n"
+	for id := 1; id <= 50; id++ {
+		csv += fmt.Sprintf("%d,drug,Aspirina,{}\n", id)
+	}
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		written = map[uint64]int{}
+	)
+	svc := service.NewSegmentationService(&MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			written[s.UserID]++
+			return repository.UpsertInserted, nil
+		},
+	})
+
+	runs := &memoryRunStore{}
+	var invalid uint64
+	for i := 0; i < 3; i++ {
+		_, err := Run(context.Background(), svc, zaptest.NewLogger(t),
+			WithFile(path),
+			WithRunID(fmt.Sprintf("run-%d", i)),
+			WithRunStore(runs),
+			WithPartition("job-1", ClaimPartition, 3),
+		)
+		if err != nil {
+			t.Fatalf("partition run %d: %v", i, err)
+		}
+		got := runs.updated
+		if got.JobID != "job-1" || got.Partition != i || got.Partitions != 3 || got.Status != models.RunSucceeded {
+			t.Fatalf("unexpected run %+v", got)
+		}
+		invalid += got.Invalid
+	}
+
+	if len(written) != 50 {
+		t.Errorf("wrote %d users, want 50", len(written))
+	}
+	for id, n := range written {
+		if n != 1 {
+			t.Errorf("user %d written %d times, want once", id, n)
+		}
+	}
+	if invalid != 1 {
+		t.Errorf("invalid rows = %d across partitions, want 1", invalid)
+	}
+
+	_, err := Run(context.Background(), svc, zaptest.NewLogger(t),
+		WithFile(path), WithRunStore(runs), WithPartition("job-1", ClaimPartition, 3))
+	if !errors.Is(err, repository.ErrNoPartition) {
+		t.Errorf("Run() error = %v, want ErrNoPartition once every partition is claimed", err)
+	}
+}
+
+func TestRun_PartitionOutOfRange(t *testing.T) {
+	svc := service.NewSegmentationService(&MockProcessorRepository{})
+	for _, index := range []int{-2, 3} {
+		if _, err := Run(context.Background(), svc, zaptest.NewLogger(t), WithPartition("job-1", index, 3)); err == nil {
+			t.Errorf("Run() with partition %d of 3 should fail", index)
+		}
+	}
+	if _, err := Run(context.Background(), svc, zaptest.NewLogger(t), WithPartition("job-1", ClaimPartition, 3)); err == nil {
+		t.Error("claiming a partition without a run store should fail")
+	}
+}
@@ -0,0 +1,40 @@
+package processor
+
+import "sync"
+
+// maxPooledData limita o buffer de data guardado no pool, para que uma
+// linha excepcionalmente grande não fique retida pelo resto da importação
+const maxPooledData = 64 << 10
+
+// recordPool reaproveita os registros, com os slices de fields e data,
+// entre as linhas de uma importação
+var recordPool = sync.Pool{
+	New: func() any { return new(record) },
+}
+
+// newRecord pega um registro do pool com a linha row, cujos campos são
+// copiados: o csv.Reader reaproveita o slice na leitura seguinte
+func newRecord(row int, fields []string) *record {
+	r := recordPool.Get().(*record)
+	r.row = row
+	r.fields = append(r.fields[:0], fields...)
+	return r
+}
+
+// release devolve r ao pool. Depois dele nem r nem seus slices (fields e
+// data) podem ser usados, inclusive por quem os recebeu de r.
+func (r *record) release() {
+	clear(r.fields) // não segura as strings da linha
+	fields, data := r.fields[:0], r.data[:0]
+	if cap(data) > maxPooledData {
+		data = nil
+	}
+	*r = record{fields: fields, data: data}
+	recordPool.Put(r)
+}
+
+func releaseAll(records []*record) {
+	for _, r := range records {
+		r.release()
+	}
+}
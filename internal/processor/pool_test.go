@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestNewRecord_CopiesReusedFields(t *testing.T) {
+	reader := csv.NewReader(strings.NewReader("1,drug,Aspirina,{}\n2,exam,Hemograma,{}\n"))
+	reader.ReuseRecord = true
+
+	row, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := newRecord(2, row)
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(first.fields, ","); got != "1,drug,Aspirina,{}" {
+		t.Errorf("fields = %q after the next read, want the first row", got)
+	}
+}
+
+func TestRecordRelease(t *testing.T) {
+	r := newRecord(7, []string{"1", "drug", "Aspirina", "{}"})
+	r.userID, r.segType, r.name = 1, "drug", "Aspirina"
+	r.data = append(r.data, "{}"...)
+	fields := r.fields[:4]
+
+	r.release()
+
+	if r.row != 0 || r.userID != 0 || r.segType != "" || r.name != "" || len(r.fields) != 0 || len(r.data) != 0 {
+		t.Errorf("released record was not reset: %+v", r)
+	}
+	if fields[0] != "" {
+		t.Error("released record still holds the row strings")
+	}
+
+	r.data = make([]byte, 0, maxPooledData+1)
+	r.release()
+	if r.data != nil {
+		t.Error("oversized data buffer was kept in the pool")
+	}
+}
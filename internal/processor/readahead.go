@@ -0,0 +1,16 @@
+package processor
+
+// readAheadBuffer é quantas linhas por parser ficam lidas à frente
+const readAheadBuffer = 64
+
+// WithReadAhead separa a leitura do CSV da validação: o producer só lê as
+// linhas e parsers goroutines as validam e entregam aos workers, para que
+// a validação não atrase a leitura do arquivo. Com 0 (o padrão) o producer
+// valida cada linha antes de ler a próxima. A ordem das linhas entre os
+// workers não é garantida, como já não era entre workers; com read-ahead o
+// span de leitura não inclui o tempo de validação.
+func WithReadAhead(parsers int) Option {
+	return func(cfg *runConfig) {
+		cfg.readAhead = parsers
+	}
+}
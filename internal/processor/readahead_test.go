@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRun_ReadAhead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n"
+	for i := 1; i <= 1000; i++ {
+		switch {
+		case i%100 == 0:
+			csv += "abc,drug,Aspirina,{}\n"
+		case i%50 == 0:
+			csv += fmt.Sprintf("%d,drug,Dipirona,{not json}\n", i)
+		default:
+			csv += fmt.Sprintf("%d,drug,Aspirina,{}\n", i)
+		}
+	}
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			return repository.UpsertInserted, nil
+		},
+	}
+	runs := &memoryRunStore{}
+	deadLetters := &memoryDeadLetterStore{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(mockRepo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunStore(runs),
+		WithDeadLetters(deadLetters),
+		WithReadAhead(4),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	got := runs.updated
+	if got == nil || got.Status != models.RunSucceeded {
+		t.Fatalf("unexpected finished run: %+v", got)
+	}
+	if got.RowsRead != 1000 || got.Inserted != 980 || got.Invalid != 20 {
+		t.Errorf("read = %d, inserted = %d, invalid = %d, want 1000, 980 and 20", got.RowsRead, got.Inserted, got.Invalid)
+	}
+	if n := len(deadLetters.entries); n != 20 {
+		t.Errorf("dead letters = %d, want 20", n)
+	}
+}
+
+func TestRun_ReadAheadCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n"
+	for i := 1; i <= 1000; i++ {
+		csv += fmt.Sprintf("%d,drug,Aspirina,{}\n", i)
+	}
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			cancel()
+			return repository.UpsertInserted, nil
+		},
+	}
+
+	// the producer and the parsers must not block on a cancelled run
+	Run(
+		ctx,
+		service.NewSegmentationService(mockRepo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithReadAhead(2),
+	)
+}
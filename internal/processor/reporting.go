@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/reporting"
+)
+
+// reportFlushTimeout limita a espera pelo envio dos reports antes de um
+// panic derrubar o processo
+const reportFlushTimeout = 2 * time.Second
+
+// runReporter envia ao error tracker as falhas de um run. Cada mensagem de
+// erro distinta é reportada uma vez por run: uma queda do banco geraria um
+// evento por linha.
+type runReporter struct {
+	reporter reporting.Reporter
+	runID    string
+	seen     sync.Map
+}
+
+func newRunReporter(reporter reporting.Reporter, runID string) *runReporter {
+	if reporter == nil {
+		reporter = reporting.Nop{}
+	}
+	return &runReporter{reporter: reporter, runID: runID}
+}
+
+func (r *runReporter) tags(kv ...string) map[string]string {
+	tags := map[string]string{"run_id": r.runID}
+	for i := 0; i+1 < len(kv); i += 2 {
+		tags[kv[i]] = kv[i+1]
+	}
+	return tags
+}
+
+// upsertFailed reporta a falha de escrita de uma linha
+func (r *runReporter) upsertFailed(ctx context.Context, workerID, row int, err error) {
+	if !reporting.Reportable(err) {
+		return
+	}
+	if _, dup := r.seen.LoadOrStore(err.Error(), struct{}{}); dup {
+		return
+	}
+	r.reporter.Report(ctx, err, r.tags(
+		"worker", strconv.Itoa(workerID),
+		"row", strconv.Itoa(row),
+	))
+}
+
+// failed reporta o erro que encerrou o run
+func (r *runReporter) failed(ctx context.Context, err error) {
+	r.reporter.Report(ctx, err, r.tags())
+}
+
+// recoverPanic deve ser chamado com defer: reporta o panic, espera o envio
+// e o relança
+func (r *runReporter) recoverPanic(ctx context.Context, where string) {
+	if p := recover(); p != nil {
+		r.reporter.Report(ctx, reporting.PanicError(p), r.tags("goroutine", where))
+		r.reporter.Flush(reportFlushTimeout)
+		panic(p)
+	}
+}
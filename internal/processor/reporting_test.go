@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap/zaptest"
+)
+
+type memoryReporter struct {
+	mu      sync.Mutex
+	errs    []error
+	tags    []map[string]string
+	flushed int
+}
+
+func (m *memoryReporter) Report(_ context.Context, err error, tags map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs = append(m.errs, err)
+	m.tags = append(m.tags, tags)
+}
+
+func (m *memoryReporter) Flush(time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushed++
+}
+
+func TestRun_ReportsDistinctUpsertErrorsOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n" +
+		"1,drug,Aspirina,{}\n" +
+		"2,drug,Aspirina,{}\n" +
+		"3,drug,Aspirina,{}\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			return repository.UpsertNoOp, errors.New("db down")
+		},
+	}
+	reporter := &memoryReporter{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(mockRepo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunID("run-42"),
+		WithErrorReporter(reporter),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(reporter.errs) != 1 || reporter.errs[0].Error() != "db down" {
+		t.Fatalf("reports = %v, want a single db down", reporter.errs)
+	}
+	tags := reporter.tags[0]
+	if tags["run_id"] != "run-42" || tags["worker"] == "" || tags["row"] == "" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestRun_ReportsFailedRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.csv")
+	reporter := &memoryReporter{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(&MockProcessorRepository{}),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunID("run-42"),
+		WithErrorReporter(reporter),
+	)
+	if err == nil {
+		t.Fatal("Run() should fail when the file is missing")
+	}
+	if len(reporter.errs) != 1 || !errors.Is(reporter.errs[0], os.ErrNotExist) {
+		t.Fatalf("reports = %v, want the open error", reporter.errs)
+	}
+}
+
+func TestRunReporter_RecoverPanic(t *testing.T) {
+	reporter := &memoryReporter{}
+	reports := newRunReporter(reporter, "run-42")
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Fatalf("panic should be re-raised, got %v", p)
+		}
+		if len(reporter.errs) != 1 || reporter.errs[0].Error() != "panic: boom" || reporter.flushed != 1 {
+			t.Errorf("unexpected reports: %v (flushed %d)", reporter.errs, reporter.flushed)
+		}
+		if reporter.tags[0]["goroutine"] != "worker" {
+			t.Errorf("unexpected tags: %v", reporter.tags[0])
+		}
+	}()
+
+	func() {
+		defer reports.recoverPanic(context.Background(), "worker")
+		panic("boom")
+	}()
+}
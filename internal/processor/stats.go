@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// RunStats são os contadores de um run, devolvidos por Run também quando
+// ele falha (com o que foi feito até ali). São os mesmos gravados na
+// tabela runs e no log processor_finished.
+type RunStats struct {
+	// atualizados com sync/atomic durante o run; os campos uint64 vêm
+	// primeiro para ficarem alinhados em plataformas de 32 bits
+	Read       uint64 // linhas lidas do CSV
+	Enqueued   uint64 // linhas válidas enviadas aos workers
+	Inserted   uint64
+	Updated    uint64
+	Duplicates uint64 // no-ops: linhas iguais às já gravadas
+	Failed     uint64 // escritas recusadas pelo MySQL
+	Invalid    uint64 // linhas rejeitadas pela validação
+	Warnings   uint64 // avisos de validação (modo lenient)
+
+	RunID    string
+	Duration time.Duration
+}
+
+// snapshot lê os contadores enquanto os workers ainda podem alterá-los
+func (s *RunStats) snapshot() RunStats {
+	return RunStats{
+		Read:       atomic.LoadUint64(&s.Read),
+		Enqueued:   atomic.LoadUint64(&s.Enqueued),
+		Inserted:   atomic.LoadUint64(&s.Inserted),
+		Updated:    atomic.LoadUint64(&s.Updated),
+		Duplicates: atomic.LoadUint64(&s.Duplicates),
+		Failed:     atomic.LoadUint64(&s.Failed),
+		Invalid:    atomic.LoadUint64(&s.Invalid),
+		Warnings:   atomic.LoadUint64(&s.Warnings),
+		RunID:      s.RunID,
+		Duration:   s.Duration,
+	}
+}
+
+// record copia os contadores para o registro do run
+func (s RunStats) record(run *models.Run) {
+	run.RowsRead = s.Read
+	run.Enqueued = s.Enqueued
+	run.Inserted = s.Inserted
+	run.Updated = s.Updated
+	run.Duplicates = s.Duplicates
+	run.Failed = s.Failed
+	run.Invalid = s.Invalid
+	run.Warnings = s.Warnings
+}
+
+// syncRun grava os contadores do run em andamento, quando mudaram, para o
+// GET /imports acompanhar o progresso, e cancela o run se o cancelamento
+// foi pedido. Falhas só são registradas: o fim do run é gravado de qualquer
+// forma.
+func syncRun(ctx context.Context, runs repository.RunRepository, runID string, stats *RunStats, changed bool, cancel context.CancelCauseFunc, logger *zap.Logger) {
+	if changed {
+		run := models.Run{ID: runID}
+		stats.snapshot().record(&run)
+		if err := runs.UpdateProgress(ctx, &run); err != nil && ctx.Err() == nil {
+			logger.Warn("run_progress_update_error", zap.Error(err))
+		}
+	}
+	requested, err := runs.CancelRequested(ctx, runID)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Warn("run_cancel_check_error", zap.Error(err))
+		}
+		return
+	}
+	if requested {
+		logger.Warn("run_cancel_requested")
+		cancel(ErrCancelRequested)
+	}
+}
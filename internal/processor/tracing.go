@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"segmentation-api/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "segmentation-api/internal/processor"
+	// traceBatchSize agrupa linhas em um span; um span por linha seria
+	// inviável com milhões de registros
+	traceBatchSize = 1000
+)
+
+// readBatch é o span de um lote de linhas lidas pelo producer. Os tempos de
+// leitura, validação e espera no channel são acumulados como atributos.
+type readBatch struct {
+	span         trace.Span
+	firstRow     int
+	invalidStart uint64
+	read         time.Duration
+	validate     time.Duration
+	enqueue      time.Duration
+}
+
+func startReadBatch(ctx context.Context, tracer trace.Tracer, firstRow int, invalid uint64) *readBatch {
+	_, span := tracer.Start(ctx, "processor.read_batch",
+		trace.WithAttributes(attribute.Int("batch.first_row", firstRow)),
+	)
+	return &readBatch{span: span, firstRow: firstRow, invalidStart: invalid}
+}
+
+func (b *readBatch) full(row int) bool {
+	return row-b.firstRow+1 >= traceBatchSize
+}
+
+func (b *readBatch) end(lastRow int, invalid uint64) {
+	b.span.SetAttributes(
+		attribute.Int("batch.rows", lastRow-b.firstRow+1),
+		attribute.Int64("batch.invalid", int64(invalid-b.invalidStart)),
+		attribute.Int64("read.duration_ms", b.read.Milliseconds()),
+		attribute.Int64("validate.duration_ms", b.validate.Milliseconds()),
+		attribute.Int64("enqueue_wait.duration_ms", b.enqueue.Milliseconds()),
+	)
+	b.span.End()
+}
+
+// writeBatch é o span de um lote de registros gravados por um worker
+type writeBatch struct {
+	ctx     context.Context
+	span    trace.Span
+	records int
+	results map[string]int
+	db      time.Duration
+}
+
+func startWriteBatch(ctx context.Context, tracer trace.Tracer, workerID int) *writeBatch {
+	ctx, span := tracer.Start(ctx, "processor.write_batch",
+		trace.WithAttributes(attribute.Int("worker.id", workerID)),
+	)
+	return &writeBatch{ctx: ctx, span: span, results: make(map[string]int, 4)}
+}
+
+func (b *writeBatch) record(result string, elapsed time.Duration, err error) {
+	b.records++
+	b.results[result]++
+	b.db += elapsed
+	if err != nil {
+		b.span.RecordError(err)
+		b.span.SetStatus(codes.Error, "upsert failed")
+	}
+}
+
+// bulk registra um lote gravado com um único upsert
+func (b *writeBatch) bulk(result repository.BulkUpsertResult, elapsed time.Duration) {
+	b.records += result.Inserted + result.Updated + result.Ignored
+	b.results["inserted"] += result.Inserted
+	b.results["updated"] += result.Updated
+	b.results["noop"] += result.Ignored
+	b.db += elapsed
+}
+
+// bulkFailed registra um lote recusado; as linhas são regravadas uma a uma
+// e registradas por record
+func (b *writeBatch) bulkFailed(rows int, elapsed time.Duration, err error) {
+	b.db += elapsed
+	b.span.AddEvent("bulk_upsert_failed", trace.WithAttributes(
+		attribute.Int("batch.rows", rows),
+		attribute.String("error", err.Error()),
+	))
+}
+
+func (b *writeBatch) end() {
+	b.span.SetAttributes(
+		attribute.Int("batch.records", b.records),
+		attribute.Int("batch.inserted", b.results["inserted"]),
+		attribute.Int("batch.updated", b.results["updated"]),
+		attribute.Int("batch.noop", b.results["noop"]),
+		attribute.Int("batch.failed", b.results["failed"]),
+		attribute.Int64("db.duration_ms", b.db.Milliseconds()),
+	)
+	b.span.End()
+}
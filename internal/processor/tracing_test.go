@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRun_EmitsPipelineSpans(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("user_id,segmentation_type,segmentation_name,data\n")
+	rows := traceBatchSize + 10
+	for i := 1; i <= rows; i++ {
+		fmt.Fprintf(&csv, "%d,drug,Aspirina,{}\n", i)
+	}
+	csv.WriteString("abc,drug,Aspirina,{}\n")
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(csv.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			return repository.UpsertInserted, nil
+		},
+	}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(mockRepo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunID("run-7"),
+		WithTracerProvider(provider),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = append(spans[s.Name()], s)
+	}
+
+	if len(spans["processor.run"]) != 1 || len(spans["processor.open_file"]) != 1 {
+		t.Fatalf("expected one run and one open_file span, got %v", spans)
+	}
+	if got := len(spans["processor.read_batch"]); got != 2 {
+		t.Errorf("read_batch spans = %d, want 2", got)
+	}
+	if len(spans["processor.write_batch"]) == 0 {
+		t.Error("expected write_batch spans")
+	}
+
+	run := spans["processor.run"][0]
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range run.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["run.id"].AsString() != "run-7" || attrs["file.path"].AsString() != path {
+		t.Errorf("unexpected run attributes: %v", attrs)
+	}
+	if attrs["rows.read"].AsInt64() != int64(rows+1) || attrs["rows.invalid"].AsInt64() != 1 {
+		t.Errorf("unexpected row counters: %v", attrs)
+	}
+
+	var written int64
+	for _, s := range spans["processor.write_batch"] {
+		if s.Parent().SpanID() != run.SpanContext().SpanID() {
+			t.Error("write_batch should be a child of the run span")
+		}
+		for _, kv := range s.Attributes() {
+			if kv.Key == "batch.records" {
+				written += kv.Value.AsInt64()
+			}
+		}
+	}
+	if written != int64(rows) {
+		t.Errorf("records across write batches = %d, want %d", written, rows)
+	}
+}
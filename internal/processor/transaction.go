@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+)
+
+// ErrRolledBack encerra um run transacional desfeito por uma linha
+// rejeitada ou por uma escrita com falha
+var ErrRolledBack = errors.New("file transaction rolled back")
+
+// transaction é o modo transacional de um run; active quando o run já
+// está dentro da transação, e runs adia o registro final do run até o
+// commit
+type transaction struct {
+	active bool
+	runs   *txRuns
+}
+
+// WithTransaction grava o arquivo inteiro numa única transação: a primeira
+// linha inválida ou escrita com falha desfaz tudo o que o run gravou e o
+// run termina com ErrRolledBack, assim como um run cancelado não grava
+// nada. As escritas passam a usar um único worker, já que a transação tem
+// uma conexão só; as linhas rejeitadas seguem para o dead-letter.
+func WithTransaction() Option {
+	return func(cfg *runConfig) {
+		cfg.transaction = &transaction{}
+	}
+}
+
+// txRuns adia o Update final do run até o fim da transação, para que um
+// commit recusado não fique registrado como sucesso
+type txRuns struct {
+	repository.RunRepository
+	final *models.Run
+}
+
+func (r *txRuns) Update(_ context.Context, run *models.Run) error {
+	final := *run
+	r.final = &final
+	return nil
+}
+
+// runInTransaction roda o run dentro de uma transação de svc, com os
+// contadores em stats, e registra o run depois do commit ou do rollback
+func runInTransaction(ctx context.Context, svc *service.SegmentationService, logger *zap.Logger, cfg runConfig, opts []Option, stats *RunStats) error {
+	if cfg.runID == "" {
+		cfg.runID = NewRunID()
+		logger = logger.With(zap.String("run_id", cfg.runID))
+		opts = append(opts, WithRunID(cfg.runID))
+	}
+	tx := &transaction{active: true}
+	if cfg.runs != nil {
+		tx.runs = &txRuns{RunRepository: cfg.runs}
+	}
+	opts = append(opts, func(c *runConfig) {
+		c.transaction = tx
+		if tx.runs != nil {
+			c.runs = tx.runs
+		}
+	})
+
+	err := svc.Transaction(ctx, func(txSvc *service.SegmentationService) error {
+		var err error
+		*stats, err = Run(ctx, txSvc, logger, opts...)
+		if err == nil && ctx.Err() != nil {
+			// cancelado: o que foi gravado até aqui é desfeito
+			err = ctx.Err()
+		}
+		if err == nil && tx.runs != nil && tx.runs.final != nil && tx.runs.final.Status == models.RunCancelled {
+			// cancelado pela API
+			err = ErrCancelRequested
+		}
+		return err
+	})
+	if err != nil {
+		logger.Warn("file_transaction_rolled_back", zap.Error(err))
+	} else {
+		logger.Info("file_transaction_committed")
+	}
+
+	if tx.runs != nil && tx.runs.final != nil {
+		run := tx.runs.final
+		if err != nil && run.Status == models.RunSucceeded {
+			// o run terminou, mas o commit falhou
+			run.Status = models.RunFailed
+			run.Error = err.Error()
+		}
+		if uerr := cfg.runs.Update(context.WithoutCancel(ctx), run); uerr != nil {
+			logger.Error("run_update_error", zap.Error(uerr))
+		}
+	}
+	return err
+}
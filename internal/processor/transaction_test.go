@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// bufferedRepository só aplica em committed as escritas de uma transação
+// que terminou sem erro
+type bufferedRepository struct {
+	MockProcessorRepository
+	pending   []models.Segmentation
+	committed []models.Segmentation
+}
+
+func (b *bufferedRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+	res, err := b.MockProcessorRepository.Upsert(ctx, s)
+	if err == nil {
+		b.pending = append(b.pending, *s)
+	}
+	return res, err
+}
+
+func (b *bufferedRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, b.Upsert, items)
+}
+
+func (b *bufferedRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	b.pending = nil
+	if err := fn(b); err != nil {
+		b.pending = nil
+		return err
+	}
+	b.committed = append(b.committed, b.pending...)
+	return nil
+}
+
+func writeCSV(t *testing.T, rows string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n" + rows
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_TransactionCommits(t *testing.T) {
+	path := writeCSV(t, "1,drug,Aspirina,{}\n2,drug,Dipirona,{}\n")
+	repo := &bufferedRepository{}
+	runs := &memoryRunStore{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(repo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunStore(runs),
+		WithTransaction(),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(repo.committed) != 2 {
+		t.Errorf("committed = %d, want 2", len(repo.committed))
+	}
+	if runs.updated == nil || runs.updated.Status != models.RunSucceeded || runs.updated.Inserted != 2 {
+		t.Fatalf("unexpected finished run: %+v", runs.updated)
+	}
+}
+
+func TestRun_TransactionRollsBackOnInvalidRow(t *testing.T) {
+	path := writeCSV(t, "1,drug,Aspirina,{}\nabc,drug,Aspirina,{}\n2,drug,Dipirona,{}\n")
+	repo := &bufferedRepository{}
+	runs := &memoryRunStore{}
+	deadLetters := &memoryDeadLetterStore{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(repo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunStore(runs),
+		WithDeadLetters(deadLetters),
+		WithTransaction(),
+	)
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Run() error = %v, want ErrRolledBack", err)
+	}
+	if len(repo.committed) != 0 {
+		t.Errorf("committed = %d, want 0", len(repo.committed))
+	}
+	if runs.updated == nil || runs.updated.Status != models.RunFailed || runs.updated.Error == "" {
+		t.Fatalf("unexpected finished run: %+v", runs.updated)
+	}
+	if len(deadLetters.entries) != 1 || deadLetters.entries[0].RowNumber != 3 {
+		t.Errorf("unexpected dead letters: %+v", deadLetters.entries)
+	}
+}
@@ -6,77 +6,389 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"log"
 	"os"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"segmentation-api/internal/models"
+	"segmentation-api/internal/reporting"
 	"segmentation-api/internal/repository"
 	"segmentation-api/internal/service"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
+// record é uma linha do CSV a caminho dos workers. Vem de recordPool e
+// volta a ele depois de gravada (release).
 type record struct {
+	row     int
+	fields  []string // linha original, para o dead-letter
 	userID  uint64
 	segType string
 	name    string
+	nameKey string
 	data    []byte
 }
 
-func Run(ctx context.Context, svc *service.SegmentationService, logger *log.Logger) error {
-	filepath := os.Getenv("DATAFILEPATH")
+// Option customiza uma execução do processor
+type Option func(*runConfig)
+
+type runConfig struct {
+	file        string
+	source      string
+	runID       string
+	runs        repository.RunRepository
+	runCreated  bool
+	deadLetters repository.DeadLetterRepository
+	tracer      trace.TracerProvider
+	reporter    reporting.Reporter
+	logs        LogConfig
+	heartbeat   func()
+	partition   partition
+	batching    batching
+	readAhead   int
+	workers     int
+	transaction *transaction
+	outage      *outageConfig
+
+	drainTimeout time.Duration
+}
+
+// WithFile define o CSV importado pelo run
+func WithFile(path string) Option {
+	return func(cfg *runConfig) {
+		cfg.file = path
+	}
+}
+
+// WithSource define a origem gravada no run (models.Run.Source) quando o
+// arquivo é uma cópia local, como os baixados do SFTP; por padrão é o
+// próprio arquivo
+func WithSource(source string) Option {
+	return func(cfg *runConfig) {
+		cfg.source = source
+	}
+}
+
+// WithRunID define o ID do run; o chamador já deve ter incluído o campo
+// run_id no logger. Sem ele um novo ID é gerado e adicionado ao logger.
+func WithRunID(id string) Option {
+	return func(cfg *runConfig) {
+		cfg.runID = id
+	}
+}
+
+// WithRunStore registra o run (status, contadores, início e fim) na tabela runs
+func WithRunStore(runs repository.RunRepository) Option {
+	return func(cfg *runConfig) {
+		cfg.runs = runs
+	}
+}
+
+// WithCreatedRun indica que o run já está na tabela runs, criado por quem
+// recebeu o arquivo (POST /imports): o processor só o atualiza
+func WithCreatedRun() Option {
+	return func(cfg *runConfig) {
+		cfg.runCreated = true
+	}
+}
+
+// WithDeadLetters grava as linhas rejeitadas ou com falha na tabela dead_letters
+func WithDeadLetters(store repository.DeadLetterRepository) Option {
+	return func(cfg *runConfig) {
+		cfg.deadLetters = store
+	}
+}
+
+// WithTracerProvider define o provider dos spans do run; por padrão usa o
+// provider global do OpenTelemetry
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(cfg *runConfig) {
+		cfg.tracer = tp
+	}
+}
+
+// WithErrorReporter envia ao error tracker os panics, o erro que encerra o
+// run e as falhas de escrita (uma vez por mensagem distinta)
+func WithErrorReporter(reporter reporting.Reporter) Option {
+	return func(cfg *runConfig) {
+		cfg.reporter = reporter
+	}
+}
+
+// WithHeartbeat chama beat a cada progress_interval em que o run avançou
+// (linhas lidas ou gravadas); um run travado para de chamá-lo
+func WithHeartbeat(beat func()) Option {
+	return func(cfg *runConfig) {
+		cfg.heartbeat = beat
+	}
+}
+
+// WithWorkers define quantos workers gravam as linhas; 0 (o padrão) usa um
+// por CPU
+func WithWorkers(n int) Option {
+	return func(cfg *runConfig) {
+		cfg.workers = n
+	}
+}
+
+// ErrCancelRequested encerra um run cujo cancelamento foi pedido pela API
+var ErrCancelRequested = errors.New("cancellation requested")
+
+// NewRunID gera o identificador de um run
+func NewRunID() string {
+	return uuid.NewString()
+}
+
+// Run importa o CSV configurado pelas opções e devolve os contadores do
+// run, também quando ele falha
+func Run(ctx context.Context, svc *service.SegmentationService, logger *zap.Logger, opts ...Option) (RunStats, error) {
+	var stats RunStats
+	err := run(ctx, svc, logger, &stats, opts)
+	// um worker abandonado pelo drain timeout ainda pode contar
+	return stats.snapshot(), err
+}
+
+func run(ctx context.Context, svc *service.SegmentationService, logger *zap.Logger, stats *RunStats, opts []Option) (err error) {
+	cfg := runConfig{
+		tracer:   otel.GetTracerProvider(),
+		logs:     DefaultLogConfig(),
+		batching: batching{size: 1},
+
+		drainTimeout: DefaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.transaction != nil && !cfg.transaction.active {
+		return runInTransaction(ctx, svc, logger, cfg, opts, stats)
+	}
+	if cfg.runID == "" {
+		cfg.runID = NewRunID()
+		logger = logger.With(zap.String("run_id", cfg.runID))
+	}
+	stats.RunID = cfg.runID
+	// os eventos do outbox gravados pelo run levam o seu ID
+	ctx = repository.WithRunID(ctx, cfg.runID)
+
+	reports := newRunReporter(cfg.reporter, cfg.runID)
+	successes := newSuccessLog(cfg.logs)
+	defer reports.recoverPanic(ctx, "producer")
+	defer func() {
+		// sem partição livre não há nada a fazer, não é uma falha
+		if err != nil && !errors.Is(err, repository.ErrNoPartition) {
+			reports.failed(ctx, err)
+		}
+	}()
+
+	filepath := cfg.file
+	source := cfg.source
+	if source == "" {
+		source = filepath
+	}
+
+	// ─────────────────────────────────────────────
+	// Tracing: run → open_file, read_batch, write_batch
+	// ─────────────────────────────────────────────
+	tracer := cfg.tracer.Tracer(tracerName)
+	ctx, runSpan := tracer.Start(ctx, "processor.run", trace.WithAttributes(
+		attribute.String("run.id", cfg.runID),
+		attribute.String("file.path", filepath),
+	))
+	defer func() {
+		if err != nil {
+			runSpan.RecordError(err)
+			runSpan.SetStatus(codes.Error, err.Error())
+		}
+		runSpan.End()
+	}()
+	// sem exporter configurado o hot path não mede nada
+	tracing := runSpan.IsRecording()
+
+	var (
+		wg        sync.WaitGroup
+		startTime = time.Now()
+		doneCh    = make(chan struct{})
+	)
+	defer func() { stats.Duration = time.Since(startTime) }()
+
+	part := cfg.partition
+	if part.split() {
+		switch {
+		case part.index < ClaimPartition || part.index >= part.count:
+			return fmt.Errorf("partition %d out of range [0, %d)", part.index, part.count)
+		case part.index == ClaimPartition && cfg.runs == nil:
+			return errors.New("claiming a partition requires a run store")
+		}
+	}
+
+	// pedido de cancelamento pela API (runs.cancel_requested_at), conferido
+	// com o progresso; cancelRun é chamado por último, depois de o run ser
+	// registrado
+	var cancelRun context.CancelCauseFunc
+	if cfg.runs != nil {
+		ctx, cancelRun = context.WithCancelCause(ctx)
+		defer cancelRun(nil)
+
+		run := &models.Run{
+			ID:         cfg.runID,
+			JobID:      cfg.runID,
+			Partitions: 1,
+			Source:     source,
+			Status:     models.RunRunning,
+			StartedAt:  startTime.Unix(),
+		}
+		if part.split() {
+			run.JobID, run.Partition, run.Partitions = part.jobID, part.index, part.count
+		}
+		switch {
+		case run.Partition == ClaimPartition:
+			err = cfg.runs.ClaimPartition(ctx, run)
+			part.index = run.Partition
+		case cfg.runCreated:
+		default:
+			err = cfg.runs.Create(ctx, run)
+		}
+		if err != nil {
+			return err
+		}
+
+		// ctx do run, não o do rollback transacional, cancelado ao sair
+		defer func(ctx context.Context) {
+			switch {
+			case err != nil:
+				run.Status = models.RunFailed
+				run.Error = err.Error()
+			case ctx.Err() != nil:
+				run.Status = models.RunCancelled
+				// o motivo, quando o cancelamento foi pedido (aqui ou por
+				// quem iniciou o run)
+				if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+					run.Error = cause.Error()
+				}
+			default:
+				run.Status = models.RunSucceeded
+			}
+			stats.snapshot().record(run)
+			run.FinishedAt = time.Now().Unix()
+
+			if uerr := cfg.runs.Update(context.WithoutCancel(ctx), run); uerr != nil {
+				logger.Error("run_update_error", zap.Error(uerr))
+			}
+		}(ctx)
+	}
+
+	if part.split() {
+		logger = logger.With(zap.String("job_id", part.jobID), zap.Int("partition", part.index))
+		logger.Info("partition_assigned", zap.Int("partitions", part.count))
+	}
+
+	deadLetters := newDeadLetterWriter(ctx, cfg.deadLetters, cfg.runID, logger)
+	defer func() {
+		if !deadLetters.close(ctx, cfg.drainTimeout) {
+			logger.Error("dead_letters_abandoned", zap.Duration("timeout", cfg.drainTimeout))
+		}
+	}()
+
+	// run transacional: a primeira linha rejeitada cancela o run, e o erro
+	// devolvido desfaz a transação
+	var rollback context.CancelCauseFunc
+	if cfg.transaction != nil {
+		ctx, rollback = context.WithCancelCause(ctx)
+		defer rollback(nil)
+	}
+	// reject manda a linha ao dead-letter e, no run transacional, desfaz o
+	// run
+	reject := func(row int, fields []string, reason error) {
+		deadLetters.add(row, fields, reason)
+		if rollback != nil {
+			rollback(fmt.Errorf("%w: row %d: %w", ErrRolledBack, row, reason))
+		}
+	}
+
+	// queda do banco: o run pausa em vez de contar as linhas como failed, e
+	// abort o encerra se a pausa passar do limite
+	var (
+		outage *outageGate
+		abort  context.CancelCauseFunc
+	)
+	if cfg.outage != nil && cfg.transaction == nil {
+		ctx, abort = context.WithCancelCause(ctx)
+		defer abort(nil)
+		outage = newOutageGate(cfg.outage, abort, logger)
+	}
+
+	_, openSpan := tracer.Start(ctx, "processor.open_file")
 	file, err := os.Open(filepath)
 	if err != nil {
+		openSpan.End()
 		return err
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(bufio.NewReader(file))
 	reader.FieldsPerRecord = -1
+	// a linha lida é copiada para um registro do pool antes da próxima leitura
+	reader.ReuseRecord = true
 
 	// discard header
-	if _, err := reader.Read(); err != nil {
+	_, err = reader.Read()
+	openSpan.End()
+	if err != nil {
 		return err
 	}
 
-	workers := runtime.NumCPU()
-	ch := make(chan record, workers*4)
-
-	var (
-		wg              sync.WaitGroup
-		totalRead       uint64 // linhas lidas do CSV
-		totalEnqueued   uint64 // registros válidos enviados ao channel
-		totalProcessed  uint64 // registros inseridos
-		totalFailed     uint64
-		totalInvalid    uint64
-		totalUpdated    uint64 // registros atualizados (duplicados)
-		totalDuplicates uint64 // no-op duplicatas
-		startTime       = time.Now()
-		doneCh          = make(chan struct{})
-	)
+	workers := cfg.workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if cfg.transaction != nil {
+		// a transação tem uma conexão só
+		workers = 1
+	}
+	ch := make(chan *record, workers*4)
 
 	// ─────────────────────────────────────────────
 	// Progress reporter (fora do hot path)
 	// ─────────────────────────────────────────────
 	go func() {
-		ticker := time.NewTicker(2 * time.Second)
+		ticker := time.NewTicker(cfg.logs.ProgressInterval)
 		defer ticker.Stop()
 
+		var lastDone uint64
 		for {
 			select {
 			case <-ticker.C:
-				read := atomic.LoadUint64(&totalRead)
-				enq := atomic.LoadUint64(&totalEnqueued)
-				ok := atomic.LoadUint64(&totalProcessed)
-				upd := atomic.LoadUint64(&totalUpdated)
-				dup := atomic.LoadUint64(&totalDuplicates)
-				fail := atomic.LoadUint64(&totalFailed)
-				invalid := atomic.LoadUint64(&totalInvalid)
+				read := atomic.LoadUint64(&stats.Read)
+				enq := atomic.LoadUint64(&stats.Enqueued)
+				ok := atomic.LoadUint64(&stats.Inserted)
+				upd := atomic.LoadUint64(&stats.Updated)
+				dup := atomic.LoadUint64(&stats.Duplicates)
+				fail := atomic.LoadUint64(&stats.Failed)
+				invalid := atomic.LoadUint64(&stats.Invalid)
+				warn := atomic.LoadUint64(&stats.Warnings)
+
+				// pausado o run não avança, mas não está travado
+				done := read + ok + upd + dup + fail
+				if done != lastDone || outage.pausing() {
+					if cfg.heartbeat != nil {
+						cfg.heartbeat()
+					}
+				}
+				if cfg.runs != nil {
+					syncRun(ctx, cfg.runs, cfg.runID, stats, done != lastDone, cancelRun, logger)
+				}
+				lastDone = done
 
 				if read == 0 {
 					continue
@@ -85,14 +397,22 @@ func Run(ctx context.Context, svc *service.SegmentationService, logger *log.Logg
 				elapsed := time.Since(startTime).Seconds()
 				rate := float64(ok+upd+dup) / elapsed
 
-				logger.Printf(
-					"progress read=%d enqueued=%d inserted=%d updated=%d duplicates=%d failed=%d invalid=%d rate=%.1f rec/s elapsed=%.fs",
-					read, enq, ok, upd, dup, fail, invalid, rate, elapsed,
+				logger.Info("progress",
+					zap.Uint64("read", read),
+					zap.Uint64("enqueued", enq),
+					zap.Uint64("inserted", ok),
+					zap.Uint64("updated", upd),
+					zap.Uint64("duplicates", dup),
+					zap.Uint64("failed", fail),
+					zap.Uint64("invalid", invalid),
+					zap.Uint64("warnings", warn),
+					zap.Float64("rate", rate),
+					zap.Float64("elapsed_s", elapsed),
 				)
 			case <-doneCh:
 				return
 			case <-ctx.Done():
-				logger.Println("processor_context_cancelled")
+				logger.Warn("processor_context_cancelled")
 				return
 			}
 		}
@@ -105,142 +425,437 @@ func Run(ctx context.Context, svc *service.SegmentationService, logger *log.Logg
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
+			defer reports.recoverPanic(ctx, "worker")
 
-			for r := range ch {
-				select {
-				case <-ctx.Done():
-					return
-				default:
+			var batch *writeBatch
+			defer func() {
+				if batch != nil {
+					batch.end()
+				}
+			}()
+
+			// traced abre o span do lote de escrita e devolve o contexto das
+			// escritas; sem tracing é o próprio ctx
+			traced := func() context.Context {
+				if !tracing {
+					return ctx
+				}
+				if batch == nil {
+					batch = startWriteBatch(ctx, tracer, workerID)
+				}
+				return batch.ctx
+			}
+			endTrace := func() {
+				if batch != nil && batch.records >= traceBatchSize {
+					batch.end()
+					batch = nil
+				}
+			}
+
+			// write grava um registro sozinho
+			write := func(r *record) {
+				writeCtx := traced()
+				var begin time.Time
+				if batch != nil {
+					begin = time.Now()
 				}
 
 				seg := models.Segmentation{
 					UserID:           r.userID,
 					SegmentationType: r.segType,
 					SegmentationName: r.name,
+					NameKey:          r.nameKey,
 					Data:             r.data,
 				}
-				result, err := svc.Create(ctx, &seg)
+				result, err := svc.Create(writeCtx, &seg)
+				for err != nil && outage.retry(ctx, err, 1) {
+					result, err = svc.Create(writeCtx, &seg)
+				}
+				if err == nil {
+					outage.ok(1)
+				}
+
+				if batch != nil {
+					label := service.ResultName(result)
+					if err != nil {
+						label = "failed"
+					}
+					batch.record(label, time.Since(begin), err)
+					endTrace()
+				}
+
 				if err != nil {
-					atomic.AddUint64(&totalFailed, 1)
-					logger.Printf(
-						"upsert_error worker=%d user_id=%d seg_type=%s seg_name=%s err=%v",
-						workerID,
-						r.userID,
-						r.segType,
-						r.name,
-						err,
+					atomic.AddUint64(&stats.Failed, 1)
+					reject(r.row, r.fields, err)
+					reports.upsertFailed(ctx, workerID, r.row, err)
+					logger.Error("upsert_error",
+						zap.Int("worker", workerID),
+						zap.Uint64("user_id", r.userID),
+						zap.String("seg_type", r.segType),
+						zap.String("seg_name", r.name),
+						zap.Error(err),
 					)
-					continue
+					return
 				}
 
+				var event string
 				switch result {
 				case repository.UpsertInserted:
-					atomic.AddUint64(&totalProcessed, 1)
-					// logger.Printf(
-					// 	"upsert_inserted worker=%d user_id=%d seg_type=%s seg_name=%s",
-					// 	workerID,
-					// 	r.userID,
-					// 	r.segType,
-					// 	r.name,
-					// )
-
+					atomic.AddUint64(&stats.Inserted, 1)
+					event = "upsert_inserted"
 				case repository.UpsertUpdated:
-					atomic.AddUint64(&totalUpdated, 1)
-					// logger.Printf(
-					// 	"upsert_updated worker=%d user_id=%d seg_type=%s seg_name=%s",
-					// 	workerID,
-					// 	r.userID,
-					// 	r.segType,
-					// 	r.name,
-					// )
-
+					atomic.AddUint64(&stats.Updated, 1)
+					event = "upsert_updated"
 				case repository.UpsertNoOp:
-					atomic.AddUint64(&totalDuplicates, 1)
-					logger.Printf(
-						"upsert_noop worker=%d user_id=%d seg_type=%s seg_name=%s",
-						workerID,
-						r.userID,
-						r.segType,
-						r.name,
+					atomic.AddUint64(&stats.Duplicates, 1)
+					event = "upsert_noop"
+				}
+
+				// Check evita montar os campos no hot path quando o sucesso não é registrado
+				if ce := successes.check(logger, event); ce != nil {
+					ce.Write(
+						zap.Int("worker", workerID),
+						zap.Uint64("user_id", r.userID),
+						zap.String("seg_type", r.segType),
+						zap.String("seg_name", r.name),
 					)
 				}
 			}
+
+			if cfg.batching.size <= 1 {
+				for r := range ch {
+					select {
+					case <-ctx.Done():
+						return
+					default:
+					}
+					write(r)
+					r.release()
+				}
+				return
+			}
+
+			// em lotes: cada flush grava os registros pendentes com um único upsert
+			segs := make([]models.Segmentation, 0, cfg.batching.size)
+			collect(ctx, ch, cfg.batching, func(pending []*record) {
+				defer releaseAll(pending)
+				segs = segs[:0]
+				for _, r := range pending {
+					segs = append(segs, models.Segmentation{
+						UserID:           r.userID,
+						SegmentationType: r.segType,
+						SegmentationName: r.name,
+						NameKey:          r.nameKey,
+						Data:             r.data,
+					})
+				}
+
+				writeCtx := traced()
+				begin := time.Now()
+				result, err := svc.CreateBatch(writeCtx, segs)
+				for err != nil && outage.retry(ctx, err, len(pending)) {
+					result, err = svc.CreateBatch(writeCtx, segs)
+				}
+				if err != nil {
+					if batch != nil {
+						batch.bulkFailed(len(pending), time.Since(begin), err)
+					}
+					if rollback != nil {
+						// na transação não adianta isolar as linhas: o run é desfeito
+						atomic.AddUint64(&stats.Failed, uint64(len(pending)))
+						rollback(fmt.Errorf("%w: batch of %d rows from row %d: %w", ErrRolledBack, len(pending), pending[0].row, err))
+						return
+					}
+					// regrava linha a linha para isolar as linhas com falha
+					logger.Warn("batch_upsert_error",
+						zap.Int("worker", workerID),
+						zap.Int("rows", len(pending)),
+						zap.Error(err),
+					)
+					for _, r := range pending {
+						write(r)
+					}
+					return
+				}
+
+				outage.ok(len(pending))
+				if batch != nil {
+					batch.bulk(result, time.Since(begin))
+					endTrace()
+				}
+				atomic.AddUint64(&stats.Inserted, uint64(result.Inserted))
+				atomic.AddUint64(&stats.Updated, uint64(result.Updated))
+				atomic.AddUint64(&stats.Duplicates, uint64(result.Ignored))
+
+				for _, r := range pending {
+					if ce := successes.check(logger, "upsert_batched"); ce != nil {
+						ce.Write(
+							zap.Int("worker", workerID),
+							zap.Uint64("user_id", r.userID),
+							zap.String("seg_type", r.segType),
+							zap.String("seg_name", r.name),
+						)
+					}
+				}
+			})
 		}(i)
 	}
 
 	// ─────────────────────────────────────────────
 	// Producer
 	// ─────────────────────────────────────────────
+	// prepare valida a linha de rec (row e fields) e preenche o restante do
+	// registro; false quando a linha é rejeitada (contada, registrada e
+	// enviada ao dead-letter). O tempo de validação vai para batch, que pode
+	// ser nil.
+	prepare := func(rec *record, batch *readBatch) bool {
+		rowNum, row := rec.row, rec.fields
+		if len(row) < 4 {
+			atomic.AddUint64(&stats.Invalid, 1)
+			logger.Warn("invalid_row_size", zap.Int("row", rowNum), zap.Int("size", len(row)))
+			reject(rowNum, row, fmt.Errorf("expected 4 columns, got %d", len(row)))
+			return false
+		}
+
+		// mesma regra da API: " 12", "+12" ou "012" não viram o usuário 12
+		userID, err := svc.ParseUserID(row[0])
+		if err != nil {
+			atomic.AddUint64(&stats.Invalid, 1)
+			logger.Warn("invalid_user_id", zap.Int("row", rowNum), zap.String("value", row[0]), zap.Error(err))
+			reject(rowNum, row, err)
+			return false
+		}
+
+		// data é copiado para o buffer do registro, reaproveitado entre linhas
+		rec.data = append(rec.data[:0], strings.TrimSpace(row[3])...)
+		// o tamanho é conferido antes do parse: um blob de megabytes não
+		// chega ao JSON nem ao lote, onde estouraria o max_allowed_packet
+		if err := svc.CheckDataSize(rec.data); err != nil {
+			atomic.AddUint64(&stats.Invalid, 1)
+			logger.Warn("data_too_large", zap.Int("row", rowNum), zap.Int("size", len(rec.data)))
+			reject(rowNum, row, err)
+			return false
+		}
+		if !json.Valid(rec.data) {
+			atomic.AddUint64(&stats.Invalid, 1)
+			logger.Warn("invalid_json", zap.Int("row", rowNum))
+			reject(rowNum, row, errors.New("data is not valid JSON"))
+			return false
+		}
+
+		seg := models.Segmentation{
+			UserID:           userID,
+			SegmentationType: strings.TrimSpace(row[1]),
+			SegmentationName: strings.TrimSpace(row[2]),
+			Data:             rec.data,
+		}
+
+		// regras de validação configuráveis (tipos depreciados, chaves desconhecidas)
+		var validateStart time.Time
+		if batch != nil {
+			validateStart = time.Now()
+		}
+		warnings, err := svc.Validate(&seg)
+		if batch != nil {
+			batch.validate += time.Since(validateStart)
+		}
+		if err != nil {
+			atomic.AddUint64(&stats.Invalid, 1)
+			logger.Warn("invalid_row", zap.Int("row", rowNum), zap.Error(err))
+			reject(rowNum, row, err)
+			return false
+		}
+		for _, w := range warnings {
+			atomic.AddUint64(&stats.Warnings, 1)
+			logger.Warn("validation_warning",
+				zap.Int("row", rowNum),
+				zap.String("code", w.Code),
+				zap.String("field", w.Field),
+				zap.String("msg", w.Message),
+			)
+		}
+
+		rec.userID = seg.UserID
+		rec.segType = seg.SegmentationType
+		rec.name = seg.SegmentationName
+		rec.nameKey = seg.NameKey
+		return true
+	}
+
+	// enqueue entrega o registro aos workers; false quando ctx terminou
+	// e eles podem não estar mais lendo
+	enqueue := func(rec *record) bool {
+		// durante a pausa a leitura espera o banco voltar
+		if outage.wait(ctx) != nil {
+			return false
+		}
+		atomic.AddUint64(&stats.Enqueued, 1)
+		select {
+		case ch <- rec:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// read-ahead: o producer só lê o CSV e os parsers validam as linhas em
+	// paralelo, mantendo o channel dos workers cheio
+	var (
+		rows    chan *record
+		parsers sync.WaitGroup
+	)
+	if cfg.readAhead > 0 {
+		rows = make(chan *record, cfg.readAhead*readAheadBuffer)
+		for i := 0; i < cfg.readAhead; i++ {
+			parsers.Add(1)
+			go func() {
+				defer parsers.Done()
+				defer reports.recoverPanic(ctx, "parser")
+				for rec := range rows {
+					if !prepare(rec, nil) {
+						rec.release()
+						continue
+					}
+					if !enqueue(rec) {
+						return
+					}
+				}
+			}()
+		}
+	}
+
 	rowNum := 1 // header já descartado
+	var batch *readBatch
 
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Println("producer_context_cancelled")
+			logger.Warn("producer_context_cancelled")
 			goto finish
 		default:
 		}
 
+		var readStart time.Time
+		if tracing {
+			if batch != nil && batch.full(rowNum) {
+				batch.end(rowNum, atomic.LoadUint64(&stats.Invalid))
+				batch = nil
+			}
+			if batch == nil {
+				batch = startReadBatch(ctx, tracer, rowNum+1, atomic.LoadUint64(&stats.Invalid))
+			}
+			readStart = time.Now()
+		}
+
 		row, err := reader.Read()
 		rowNum++
 
+		if batch != nil {
+			batch.read += time.Since(readStart)
+		}
+
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			logger.Printf("csv_read_error row=%d err=%v", rowNum, err)
+			// linhas ilegíveis ficam com uma partição só
+			if part.primary() {
+				logger.Warn("csv_read_error", zap.Int("row", rowNum), zap.Error(err))
+				reject(rowNum, row, err)
+			}
 			continue
 		}
 
-		atomic.AddUint64(&totalRead, 1)
-
-		if len(row) < 4 {
-			atomic.AddUint64(&totalInvalid, 1)
-			logger.Printf("invalid_row_size row=%d size=%d", rowNum, len(row))
+		// com o arquivo particionado, as linhas de outras partições são puladas
+		if !part.owns(row) {
 			continue
 		}
 
-		userID, err := strconv.ParseUint(strings.TrimSpace(row[0]), 10, 64)
-		if err != nil {
-			atomic.AddUint64(&totalInvalid, 1)
-			logger.Printf("invalid_user_id row=%d value=%q", rowNum, row[0])
-			continue
-		}
+		atomic.AddUint64(&stats.Read, 1)
 
-		raw := strings.TrimSpace(row[3])
-		if !json.Valid([]byte(raw)) {
-			atomic.AddUint64(&totalInvalid, 1)
-			logger.Printf("invalid_json row=%d", rowNum)
+		rec := newRecord(rowNum, row)
+		if rows == nil && !prepare(rec, batch) {
+			rec.release()
 			continue
 		}
 
-		atomic.AddUint64(&totalEnqueued, 1)
-
-		ch <- record{
-			userID:  userID,
-			segType: strings.TrimSpace(row[1]),
-			name:    strings.TrimSpace(row[2]),
-			data:    []byte(raw),
+		var enqueueStart time.Time
+		if batch != nil {
+			enqueueStart = time.Now()
+		}
+		sent := true
+		if rows != nil {
+			select {
+			case rows <- rec:
+			case <-ctx.Done():
+				sent = false
+			}
+		} else {
+			sent = enqueue(rec)
+		}
+		if batch != nil {
+			batch.enqueue += time.Since(enqueueStart)
+		}
+		if !sent {
+			logger.Warn("producer_context_cancelled")
+			goto finish
 		}
 	}
 
 finish:
-	close(ch)
-	wg.Wait()
+	if batch != nil {
+		batch.end(rowNum, atomic.LoadUint64(&stats.Invalid))
+	}
+	// depois de um cancelamento uma escrita que ignora ctx não pode
+	// segurar o run para sempre: as goroutines que passarem do drain
+	// timeout são abandonadas
+	drained := drain(ctx, cfg.drainTimeout, func() {
+		if rows != nil {
+			close(rows)
+			parsers.Wait()
+		}
+		close(ch)
+		wg.Wait()
+	})
 	close(doneCh)
+	if !drained {
+		logger.Error("drain_timeout", zap.Duration("timeout", cfg.drainTimeout))
+		return ErrDrainTimeout
+	}
+	if rollback != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, ErrRolledBack) {
+			logger.Warn("processor_rolled_back", zap.Error(cause))
+			return cause
+		}
+	}
+	if abort != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, ErrDatabaseOutage) {
+			return cause
+		}
+	}
 
 	elapsed := time.Since(startTime)
 
-	logger.Printf(
-		"processor_finished read=%d enqueued=%d inserted=%d updated=%d duplicates=%d failed=%d invalid=%d elapsed=%s",
-		totalRead,
-		totalEnqueued,
-		totalProcessed,
-		totalUpdated,
-		totalDuplicates,
-		totalFailed,
-		totalInvalid,
-		elapsed.String(),
+	runSpan.SetAttributes(
+		attribute.Int64("rows.read", int64(stats.Read)),
+		attribute.Int64("rows.enqueued", int64(stats.Enqueued)),
+		attribute.Int64("rows.inserted", int64(stats.Inserted)),
+		attribute.Int64("rows.updated", int64(stats.Updated)),
+		attribute.Int64("rows.duplicates", int64(stats.Duplicates)),
+		attribute.Int64("rows.failed", int64(stats.Failed)),
+		attribute.Int64("rows.invalid", int64(stats.Invalid)),
+	)
+
+	logger.Info("processor_finished",
+		zap.Uint64("read", stats.Read),
+		zap.Uint64("enqueued", stats.Enqueued),
+		zap.Uint64("inserted", stats.Inserted),
+		zap.Uint64("updated", stats.Updated),
+		zap.Uint64("duplicates", stats.Duplicates),
+		zap.Uint64("failed", stats.Failed),
+		zap.Uint64("invalid", stats.Invalid),
+		zap.Uint64("warnings", stats.Warnings),
+		zap.Duration("elapsed", elapsed),
 	)
 
 	return nil
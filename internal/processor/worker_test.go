@@ -2,13 +2,22 @@ package processor
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"segmentation-api/internal/models"
 	"segmentation-api/internal/repository"
 	"segmentation-api/internal/service"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
 )
 
 // MockProcessorRepository for testing
@@ -24,6 +33,18 @@ func (m *MockProcessorRepository) Upsert(ctx context.Context, s *models.Segmenta
 	return repository.UpsertInserted, nil
 }
 
+func (m *MockProcessorRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *MockProcessorRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *MockProcessorRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
 func (m *MockProcessorRepository) FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
 	if m.findFunc != nil {
 		return m.findFunc(ctx, userID)
@@ -31,6 +52,10 @@ func (m *MockProcessorRepository) FindByUserID(ctx context.Context, userID uint6
 	return nil, nil
 }
 
+func (m *MockProcessorRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func TestRecordStructure(t *testing.T) {
 	rec := record{
 		userID:  123,
@@ -82,12 +107,12 @@ func TestRun_WithCancelledContext(t *testing.T) {
 	}
 
 	svc := service.NewSegmentationService(mockRepo)
-	logger := log.New(os.Stderr, "", 0)
+	logger := zaptest.NewLogger(t)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	err := Run(ctx, svc, logger)
+	_, err := Run(ctx, svc, logger)
 	if err == nil {
 		t.Error("Run() should return error when context is already cancelled")
 	}
@@ -103,12 +128,12 @@ func TestRun_WithValidContext(t *testing.T) {
 	}
 
 	svc := service.NewSegmentationService(mockRepo)
-	logger := log.New(os.Stderr, "", 0)
+	logger := zaptest.NewLogger(t)
 
 	ctx := context.Background()
 
 	// Run should process the CSV file
-	err := Run(ctx, svc, logger)
+	_, err := Run(ctx, svc, logger)
 
 	if err != nil {
 		t.Logf("Run() error (expected if data.csv not found): %v", err)
@@ -120,10 +145,10 @@ func TestRun_WithValidContext(t *testing.T) {
 func TestRun_LoggerNotNil(t *testing.T) {
 	mockRepo := &MockProcessorRepository{}
 	_ = service.NewSegmentationService(mockRepo)
-	logger := log.New(os.Stderr, "[TEST] ", log.LstdFlags)
+	logger := zaptest.NewLogger(t)
 
 	// Verify logger works
-	logger.Println("test message")
+	logger.Info("test message")
 	// If no panic, logger is usable
 }
 
@@ -153,7 +178,7 @@ func TestRun_ContextCancel(t *testing.T) {
 	}
 
 	svc := service.NewSegmentationService(mockRepo)
-	logger := log.New(os.Stderr, "", 0)
+	logger := zaptest.NewLogger(t)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -162,6 +187,369 @@ func TestRun_ContextCancel(t *testing.T) {
 		cancel()
 	}()
 
-	_ = Run(ctx, svc, logger)
+	_, _ = Run(ctx, svc, logger)
 	// If context was properly cancelled, this should complete
 }
+
+func TestRun_Heartbeat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n"
+	for i := 1; i <= 10; i++ {
+		csv += fmt.Sprintf("%d,drug,Aspirina,{}\n", i)
+	}
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			time.Sleep(10 * time.Millisecond)
+			return repository.UpsertInserted, nil
+		},
+	}
+
+	var beats atomic.Int32
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(mockRepo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithLogConfig(LogConfig{Mode: LogAggregate, ProgressInterval: time.Millisecond}),
+		WithHeartbeat(func() { beats.Add(1) }),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if beats.Load() == 0 {
+		t.Error("expected the run to beat while making progress")
+	}
+}
+
+type memoryRunStore struct {
+	created *models.Run
+	updated *models.Run
+	claimed []int
+
+	// written by the progress goroutine
+	mu       sync.Mutex
+	progress []models.Run
+	// cancel reports the cancellation requested from the nth check on, 0
+	// never
+	cancel int
+	checks int
+}
+
+func (m *memoryRunStore) Create(ctx context.Context, run *models.Run) error {
+	c := *run
+	m.created = &c
+	return nil
+}
+
+func (m *memoryRunStore) Update(ctx context.Context, run *models.Run) error {
+	u := *run
+	m.updated = &u
+	return nil
+}
+
+func (m *memoryRunStore) ClaimPartition(ctx context.Context, run *models.Run) error {
+	run.Partition = len(m.claimed)
+	if run.Partition == run.Partitions {
+		return repository.ErrNoPartition
+	}
+	m.claimed = append(m.claimed, run.Partition)
+	return m.Create(ctx, run)
+}
+
+func (m *memoryRunStore) Get(ctx context.Context, id string) (*models.Run, error) {
+	return m.updated, nil
+}
+
+func (m *memoryRunStore) Latest(ctx context.Context) (*models.Run, error) {
+	return m.updated, nil
+}
+
+func (m *memoryRunStore) JobRuns(ctx context.Context, jobID string) ([]models.Run, error) {
+	if m.updated == nil {
+		return nil, nil
+	}
+	return []models.Run{*m.updated}, nil
+}
+
+func (m *memoryRunStore) SourceRuns(ctx context.Context, source string) ([]models.Run, error) {
+	return m.JobRuns(ctx, "")
+}
+
+func (m *memoryRunStore) UpdateProgress(ctx context.Context, run *models.Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.progress = append(m.progress, *run)
+	return nil
+}
+
+func (m *memoryRunStore) RequestCancel(ctx context.Context, id string, at int64) (bool, error) {
+	return false, nil
+}
+
+func (m *memoryRunStore) CancelRequested(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks++
+	return m.cancel > 0 && m.checks >= m.cancel, nil
+}
+
+func (m *memoryRunStore) List(ctx context.Context, filter repository.RunFilter) ([]models.Run, error) {
+	return m.JobRuns(ctx, "")
+}
+
+func TestRun_RecordsRunAndDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n" +
+		"1,drug,Aspirina,{}\n" +
+		"abc,drug,Aspirina,{}\n" +
+		"+7,drug,Aspirina,{}\n" +
+		"2,drug,Dipirona,{not json}\n" +
+		"3,drug,Falha,{}\n" +
+		`4,drug,Grande,"{""notes"":""` + strings.Repeat("x", 256) + `""}"` + "\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			// the outbox events of the writes carry the run
+			if runID := repository.RunIDFrom(ctx); runID != "run-42" {
+				t.Errorf("write run_id = %q, want run-42", runID)
+			}
+			if s.SegmentationName == "Falha" {
+				return repository.UpsertNoOp, errors.New("db down")
+			}
+			return repository.UpsertInserted, nil
+		},
+	}
+	runs := &memoryRunStore{}
+	deadLetters := &memoryDeadLetterStore{}
+
+	stats, err := Run(
+		context.Background(),
+		service.NewSegmentationService(mockRepo, service.WithValidationRules(service.ValidationRules{MaxDataBytes: 128})),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunID("run-42"),
+		WithRunStore(runs),
+		WithDeadLetters(deadLetters),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if runs.created == nil || runs.created.Status != models.RunRunning || runs.created.Source != path {
+		t.Fatalf("unexpected created run: %+v", runs.created)
+	}
+	got := runs.updated
+	if got == nil || got.ID != "run-42" || got.Status != models.RunSucceeded {
+		t.Fatalf("unexpected finished run: %+v", got)
+	}
+	if got.RowsRead != 6 || got.Inserted != 1 || got.Invalid != 4 || got.Failed != 1 || got.FinishedAt == 0 {
+		t.Errorf("unexpected counters: %+v", got)
+	}
+
+	// the returned counters are the ones recorded
+	if stats.RunID != "run-42" || stats.Read != got.RowsRead || stats.Inserted != got.Inserted ||
+		stats.Invalid != got.Invalid || stats.Failed != got.Failed || stats.Duration <= 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if len(deadLetters.entries) != 5 {
+		t.Fatalf("dead letters = %d, want 5", len(deadLetters.entries))
+	}
+	rows := map[int]bool{}
+	for _, e := range deadLetters.entries {
+		if e.RunID != "run-42" {
+			t.Errorf("dead letter run_id = %q, want run-42", e.RunID)
+		}
+		rows[e.RowNumber] = true
+	}
+	for _, row := range []int{3, 4, 5, 6, 7} {
+		if !rows[row] {
+			t.Errorf("expected a dead letter for row %d, got %v", row, rows)
+		}
+	}
+}
+
+func TestRun_RecordsFailedRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.csv")
+	runs := &memoryRunStore{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(&MockProcessorRepository{}),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunStore(runs),
+	)
+	if err == nil {
+		t.Fatal("Run() should fail when the file is missing")
+	}
+
+	if runs.updated == nil || runs.updated.Status != models.RunFailed || runs.updated.Error == "" {
+		t.Fatalf("unexpected finished run: %+v", runs.updated)
+	}
+	if runs.updated.ID == "" {
+		t.Error("a run ID should be generated when none is given")
+	}
+}
+
+func TestRun_WithSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sftp-123-daily.csv")
+	if err := os.WriteFile(path, []byte("user_id,segmentation_type,segmentation_name,data\n1,drug,Aspirina,{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runs := &memoryRunStore{}
+	source := "sftp://sftp.example:22/inbox/daily.csv?mtime=1760000000"
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(&MockProcessorRepository{}),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithSource(source),
+		WithRunStore(runs),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if runs.created == nil || runs.created.Source != source {
+		t.Errorf("the run should record the source instead of the local copy: %+v", runs.created)
+	}
+}
+
+func TestRun_WithCreatedRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.csv")
+	if err := os.WriteFile(path, []byte("user_id,segmentation_type,segmentation_name,data\n1,drug,Aspirina,{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runs := &memoryRunStore{}
+
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(&MockProcessorRepository{}),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunID("run-7"),
+		WithRunStore(runs),
+		WithCreatedRun(),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if runs.created != nil {
+		t.Errorf("a run already recorded should not be created again: %+v", runs.created)
+	}
+	if runs.updated == nil || runs.updated.ID != "run-7" || runs.updated.Status != models.RunSucceeded || runs.updated.Inserted != 1 {
+		t.Errorf("unexpected finished run: %+v", runs.updated)
+	}
+}
+
+func TestRun_DrainTimeoutAfterCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n"
+	for i := 1; i <= 100; i++ {
+		csv += fmt.Sprintf("%d,drug,Aspirina,{}\n", i)
+	}
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a write that ignores ctx keeps its worker stuck after the cancel
+	started := make(chan struct{}, 100)
+	release := make(chan struct{})
+	defer close(release)
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			started <- struct{}{}
+			<-release
+			return repository.UpsertInserted, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	runs := &memoryRunStore{}
+	done := make(chan error, 1)
+	go func() {
+		_, err := Run(
+			ctx,
+			service.NewSegmentationService(mockRepo),
+			// the abandoned worker logs after the test ends
+			zap.NewNop(),
+			WithFile(path),
+			WithRunStore(runs),
+			WithDrainTimeout(20*time.Millisecond),
+		)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrDrainTimeout) {
+			t.Fatalf("Run() error = %v, want ErrDrainTimeout", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the cancel")
+	}
+	if runs.updated == nil || runs.updated.Status != models.RunFailed {
+		t.Fatalf("unexpected finished run: %+v", runs.updated)
+	}
+}
+
+func TestRun_ProgressAndCancelRequested(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	csv := "user_id,segmentation_type,segmentation_name,data\n"
+	for i := 1; i <= 1000; i++ {
+		csv += fmt.Sprintf("%d,drug,Aspirina,{}\n", i)
+	}
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockRepo := &MockProcessorRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(time.Millisecond):
+				return repository.UpsertInserted, nil
+			}
+		},
+	}
+
+	runs := &memoryRunStore{cancel: 5}
+	_, err := Run(
+		context.Background(),
+		service.NewSegmentationService(mockRepo),
+		zaptest.NewLogger(t),
+		WithFile(path),
+		WithRunID("run-9"),
+		WithRunStore(runs),
+		WithLogConfig(LogConfig{Mode: LogAggregate, ProgressInterval: 5 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if runs.updated == nil || runs.updated.Status != models.RunCancelled || runs.updated.Error != ErrCancelRequested.Error() {
+		t.Fatalf("unexpected finished run: %+v", runs.updated)
+	}
+	if runs.updated.Inserted == 1000 {
+		t.Error("the run should stop before the end of the file")
+	}
+
+	runs.mu.Lock()
+	defer runs.mu.Unlock()
+	if len(runs.progress) == 0 || runs.progress[0].ID != "run-9" || runs.progress[0].RowsRead == 0 {
+		t.Errorf("expected the counters to be written while running, got %+v", runs.progress)
+	}
+}
@@ -0,0 +1,241 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/service"
+)
+
+const (
+	// maxPipeline is how many changes are published in one round trip
+	maxPipeline = 500
+	// retryBase is the wait after a failed connection, doubled after each
+	// one up to retryMax
+	retryBase = time.Second
+	retryMax  = 30 * time.Second
+	// writeTimeout bounds a round trip of PUBLISH commands
+	writeTimeout = 5 * time.Second
+	// drainTimeout bounds the publishing of the changes still queued at
+	// shutdown
+	drainTimeout = 2 * time.Second
+)
+
+// Message is the payload published for a change. Origin tells which
+// process made the write, so a replica can skip its own changes.
+type Message struct {
+	service.Change
+	Origin string `json:"origin"`
+	// At is the unix time of the write
+	At int64 `json:"at"`
+}
+
+type options struct {
+	origin string
+	logger *zap.Logger
+}
+
+// Option customizes a Notifier or a Subscriber
+type Option func(*options)
+
+// WithOrigin sets the origin of the published messages, and the origin
+// whose messages a Subscriber skips; the default is hostname:pid
+func WithOrigin(origin string) Option {
+	return func(o *options) {
+		o.origin = origin
+	}
+}
+
+// WithLogger sets the logger of connection failures and dropped changes
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+func newOptions(opts []Option) options {
+	host, _ := os.Hostname()
+	o := options{origin: fmt.Sprintf("%s:%d", host, os.Getpid()), logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Notifier publishes the changes of the service (it is a
+// service.Notifier). Notify only queues them; Run publishes them.
+type Notifier struct {
+	cfg     Config
+	opts    options
+	queue   chan Message
+	dropped atomic.Int64
+	now     func() time.Time
+}
+
+// NewNotifier creates a notifier publishing to the server of cfg, with up
+// to queueSize changes waiting (DefaultQueueSize when not positive)
+func NewNotifier(cfg Config, queueSize int, opts ...Option) *Notifier {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Notifier{
+		cfg:   cfg,
+		opts:  newOptions(opts),
+		queue: make(chan Message, queueSize),
+		now:   time.Now,
+	}
+}
+
+// Notify queues changes for Run, dropping those that do not fit
+func (n *Notifier) Notify(changes []service.Change) {
+	at := n.now().Unix()
+	for _, c := range changes {
+		select {
+		case n.queue <- Message{Change: c, Origin: n.opts.origin, At: at}:
+		default:
+			n.dropped.Add(1)
+		}
+	}
+}
+
+// Run publishes the queued changes until ctx is cancelled, reconnecting
+// with backoff when Redis fails. Changes of a failed round trip are
+// dropped. At shutdown the changes still queued get a last attempt.
+func (n *Notifier) Run(ctx context.Context) {
+	var c *conn
+	defer func() {
+		if c != nil {
+			c.Close()
+		}
+	}()
+	backoff := retryBase
+	batch := make([]Message, 0, maxPipeline)
+
+	for {
+		if ctx.Err() != nil {
+			n.drain(c, nil)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			n.drain(c, nil)
+			return
+		case m := <-n.queue:
+			batch = append(batch[:0], m)
+		}
+		// whatever else is waiting goes in the same round trip
+	fill:
+		for len(batch) < maxPipeline {
+			select {
+			case m := <-n.queue:
+				batch = append(batch, m)
+			default:
+				break fill
+			}
+		}
+		n.logDropped()
+
+		if c == nil {
+			var err error
+			if c, err = dial(ctx, n.cfg); err != nil {
+				if ctx.Err() != nil {
+					n.drain(nil, batch)
+					return
+				}
+				n.dropped.Add(int64(len(batch)))
+				n.opts.logger.Warn("redis_connect_failed", zap.Duration("backoff", backoff), zap.Error(err))
+				if !sleep(ctx, backoff) {
+					n.drain(nil, nil)
+					return
+				}
+				backoff = min(2*backoff, retryMax)
+				continue
+			}
+			backoff = retryBase
+		}
+		if err := n.publish(c, batch); err != nil {
+			n.dropped.Add(int64(len(batch)))
+			n.opts.logger.Warn("redis_publish_failed", zap.Int("changes", len(batch)), zap.Error(err))
+			c.Close()
+			c = nil
+		}
+	}
+}
+
+// publish sends the PUBLISH commands of batch in one round trip
+func (n *Notifier) publish(c *conn, batch []Message) error {
+	c.nc.SetDeadline(time.Now().Add(writeTimeout))
+	defer c.nc.SetDeadline(time.Time{})
+	for _, m := range batch {
+		payload, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		c.write("PUBLISH", n.cfg.UserChannel(m.UserID), string(payload))
+		c.write("PUBLISH", n.cfg.TypeChannel(m.Type), string(payload))
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for range 2 * len(batch) {
+		if _, err := c.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drain publishes batch and what is still queued, connecting when c is
+// nil, within drainTimeout
+func (n *Notifier) drain(c *conn, batch []Message) {
+	for len(n.queue) > 0 {
+		batch = append(batch, <-n.queue)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if c == nil {
+		var err error
+		if c, err = dial(ctx, n.cfg); err != nil {
+			n.opts.logger.Warn("redis_changes_dropped", zap.Int("changes", len(batch)), zap.Error(err))
+			return
+		}
+		defer c.Close()
+	}
+	for len(batch) > 0 {
+		k := min(len(batch), maxPipeline)
+		if err := n.publish(c, batch[:k]); err != nil {
+			n.opts.logger.Warn("redis_changes_dropped", zap.Int("changes", len(batch)), zap.Error(err))
+			return
+		}
+		batch = batch[k:]
+	}
+}
+
+// logDropped reports the changes dropped since the last report
+func (n *Notifier) logDropped() {
+	if dropped := n.dropped.Swap(0); dropped > 0 {
+		n.opts.logger.Warn("redis_changes_dropped", zap.Int64("changes", dropped))
+	}
+}
+
+// sleep waits d, or less when ctx is cancelled; it reports whether the
+// wait was complete
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
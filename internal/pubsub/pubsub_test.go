@@ -0,0 +1,257 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/service"
+)
+
+// fakeRedis speaks enough RESP for AUTH, SELECT, PUBLISH and PSUBSCRIBE
+type fakeRedis struct {
+	t        *testing.T
+	ln       net.Listener
+	password string
+
+	mu        sync.Mutex
+	conns     []net.Conn
+	subs      map[*conn]string // subscriber → pattern
+	published []published
+}
+
+type published struct {
+	channel string
+	message Message
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{t: t, ln: ln, password: password, subs: map[*conn]string{}}
+	t.Cleanup(func() {
+		ln.Close()
+		f.dropConnections()
+	})
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) config() Config {
+	return Config{Addr: f.ln.Addr().String(), Password: f.password, DB: 2}
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		nc, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, nc)
+		f.mu.Unlock()
+		go f.handle(&conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)})
+	}
+}
+
+func (f *fakeRedis) handle(c *conn) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.subs, c)
+		f.mu.Unlock()
+		c.Close()
+	}()
+	authed := f.password == ""
+	for {
+		req, err := c.read()
+		if err != nil {
+			return
+		}
+		args := req.([]any)
+		f.mu.Lock()
+		switch args[0] {
+		case "AUTH":
+			authed = args[len(args)-1] == f.password
+			if authed {
+				c.w.WriteString("+OK\r\n")
+			} else {
+				c.w.WriteString("-WRONGPASS invalid password\r\n")
+			}
+		case "SELECT":
+			c.w.WriteString("+OK\r\n")
+		case "PUBLISH":
+			if !authed {
+				c.w.WriteString("-NOAUTH Authentication required.\r\n")
+				break
+			}
+			channel, payload := args[1].(string), args[2].(string)
+			var m Message
+			json.Unmarshal([]byte(payload), &m)
+			f.published = append(f.published, published{channel, m})
+			for sub, pattern := range f.subs {
+				if ok, _ := path.Match(pattern, channel); ok {
+					sub.write("pmessage", pattern, channel, payload)
+					sub.w.Flush()
+				}
+			}
+			c.w.WriteString(":1\r\n")
+		case "PSUBSCRIBE":
+			f.subs[c] = args[1].(string)
+			c.w.WriteString("*3\r\n$10\r\npsubscribe\r\n")
+			c.w.WriteString("$" + itoa(len(args[1].(string))) + "\r\n" + args[1].(string) + "\r\n:1\r\n")
+		}
+		c.w.Flush()
+		f.mu.Unlock()
+	}
+}
+
+func itoa(n int) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, nc := range f.conns {
+		nc.Close()
+	}
+	f.conns = nil
+}
+
+func (f *fakeRedis) subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
+
+func (f *fakeRedis) messages() []published {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]published(nil), f.published...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	srv := newFakeRedis(t, "s3cret")
+	n := NewNotifier(srv.config(), 0, WithOrigin("api-1"))
+	n.now = func() time.Time { return time.Unix(1760000000, 0) }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Notify([]service.Change{
+		{Kind: service.ChangeUpserted, UserID: 123, Type: "drug", Name: "Aspirina"},
+		{Kind: service.ChangeDeleted, UserID: 7, Type: "specialty", Name: "Cardiologia"},
+	})
+	waitFor(t, "4 messages", func() bool { return len(srv.messages()) == 4 })
+
+	got := srv.messages()
+	want := []string{"segmentations:user:123", "segmentations:type:drug", "segmentations:user:7", "segmentations:type:specialty"}
+	for i, ch := range want {
+		if got[i].channel != ch {
+			t.Errorf("message %d went to %s, want %s", i, got[i].channel, ch)
+		}
+	}
+	if m := got[0].message; m.Kind != "upserted" || m.UserID != 123 || m.Name != "Aspirina" || m.Origin != "api-1" || m.At != 1760000000 {
+		t.Errorf("unexpected message %+v", m)
+	}
+}
+
+func TestNotifier_DrainsAtShutdown(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	n := NewNotifier(srv.config(), 0)
+	n.Notify([]service.Change{{Kind: service.ChangeUpserted, UserID: 1, Type: "drug", Name: "Aspirina"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n.Run(ctx)
+	if len(srv.messages()) != 2 {
+		t.Errorf("queued changes should be published at shutdown, got %+v", srv.messages())
+	}
+}
+
+func TestNotifier_DropsWhenFull(t *testing.T) {
+	n := NewNotifier(Config{Addr: "127.0.0.1:1"}, 1)
+	n.Notify(make([]service.Change, 3))
+	if len(n.queue) != 1 || n.dropped.Load() != 2 {
+		t.Errorf("queued %d, dropped %d, want 1 and 2", len(n.queue), n.dropped.Load())
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	srv := newFakeRedis(t, "s3cret")
+	var mu sync.Mutex
+	var users []uint64
+	resyncs := 0
+	sub := NewSubscriber(srv.config(), "user:*", WithOrigin("api-2"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sub.Run(ctx, func(m Message) {
+			mu.Lock()
+			users = append(users, m.UserID)
+			mu.Unlock()
+		}, func() {
+			mu.Lock()
+			resyncs++
+			mu.Unlock()
+		})
+	}()
+	waitFor(t, "the subscription", func() bool { return srv.subscribers() == 1 })
+
+	other := NewNotifier(srv.config(), 0, WithOrigin("api-1"))
+	self := NewNotifier(srv.config(), 0, WithOrigin("api-2"))
+	pubCtx, stopPub := context.WithCancel(context.Background())
+	defer stopPub()
+	go other.Run(pubCtx)
+	go self.Run(pubCtx)
+
+	self.Notify([]service.Change{{UserID: 1, Type: "drug"}})
+	other.Notify([]service.Change{{UserID: 2, Type: "drug"}})
+	waitFor(t, "the message of the other origin", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(users) == 1
+	})
+	waitFor(t, "both publishes", func() bool { return len(srv.messages()) == 4 })
+	mu.Lock()
+	if users[0] != 2 || resyncs != 0 {
+		t.Errorf("users = %v, resyncs = %d; own changes should be skipped", users, resyncs)
+	}
+	mu.Unlock()
+
+	// a lost subscription is made again and asks for a resync
+	srv.dropConnections()
+	waitFor(t, "the resync", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return resyncs == 1
+	})
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run should return when the context is cancelled")
+	}
+}
@@ -0,0 +1,194 @@
+// Package pubsub publishes compact change notifications to Redis channels
+// after each write, and listens to them. Every change goes to two
+// channels, one per user and one per segmentation type:
+//
+//	segmentations:user:123
+//	segmentations:type:drug
+//
+// so consumers subscribe to the users or types they care about, or to a
+// pattern such as segmentations:type:*. The API replicas listen to the
+// user channels to drop the users other replicas and the processor wrote
+// from their response cache.
+//
+// Notifications are best effort: Redis pub/sub keeps nothing for
+// disconnected subscribers, and the publisher drops changes it cannot
+// queue. Consumers that need every change use the outbox instead. The
+// package speaks RESP directly and only depends on the standard library.
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultPrefix starts the name of every channel
+	DefaultPrefix = "segmentations"
+	// DefaultQueueSize is how many changes wait to be published before new
+	// ones are dropped
+	DefaultQueueSize = 10000
+
+	dialTimeout = 5 * time.Second
+	// maxBulkBytes bounds a bulk string read from the server
+	maxBulkBytes = 1 << 20
+)
+
+// Config locates the Redis server. Password authenticates with AUTH, as
+// Username when set (Redis 6 ACLs); DB is selected when not 0.
+type Config struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	TLS      bool
+	// Prefix starts the channel names; DefaultPrefix when empty
+	Prefix string
+}
+
+func (c Config) prefix() string {
+	if c.Prefix == "" {
+		return DefaultPrefix
+	}
+	return c.Prefix
+}
+
+// UserChannel is the channel of the changes of a user
+func (c Config) UserChannel(userID uint64) string {
+	return c.prefix() + ":user:" + strconv.FormatUint(userID, 10)
+}
+
+// TypeChannel is the channel of the changes of a segmentation type
+func (c Config) TypeChannel(segType string) string {
+	return c.prefix() + ":type:" + segType
+}
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// conn is a connection to Redis speaking RESP2
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// dial connects to cfg.Addr, authenticates and selects the database
+func dial(ctx context.Context, cfg Config) (*conn, error) {
+	var nc net.Conn
+	var err error
+	if cfg.TLS {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		d := &tls.Dialer{NetDialer: &net.Dialer{Timeout: dialTimeout}, Config: &tls.Config{ServerName: host}}
+		nc, err = d.DialContext(ctx, "tcp", cfg.Addr)
+	} else {
+		d := &net.Dialer{Timeout: dialTimeout}
+		nc, err = d.DialContext(ctx, "tcp", cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %s: %w", cfg.Addr, err)
+	}
+	c := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	// the handshake may not outlive the dial timeout
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	if cfg.Password != "" {
+		args := []string{"AUTH", cfg.Password}
+		if cfg.Username != "" {
+			args = []string{"AUTH", cfg.Username, cfg.Password}
+		}
+		if _, err := c.do(args...); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if cfg.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(cfg.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	nc.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *conn) Close() error {
+	return c.nc.Close()
+}
+
+// do sends one command and reads its reply
+func (c *conn) do(args ...string) (any, error) {
+	c.write(args...)
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// write buffers a command as an array of bulk strings
+func (c *conn) write(args ...string) {
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(a)) + "\r\n")
+		c.w.WriteString(a)
+		c.w.WriteString("\r\n")
+	}
+}
+
+// read reads one reply: a string for simple and bulk strings (nil for a
+// null bulk), an int64, a []any, or a redisError returned as the error
+func (c *conn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxBulkBytes {
+			return nil, errors.New("redis: malformed bulk length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, errors.New("redis: malformed array length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+}
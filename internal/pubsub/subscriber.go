@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Subscriber receives the changes published to the channels matching a
+// pattern
+type Subscriber struct {
+	cfg     Config
+	pattern string
+	opts    options
+}
+
+// NewSubscriber creates a subscriber of the channels of cfg matching
+// pattern, a glob such as "user:*" written after the prefix
+func NewSubscriber(cfg Config, pattern string, opts ...Option) *Subscriber {
+	return &Subscriber{cfg: cfg, pattern: cfg.prefix() + ":" + pattern, opts: newOptions(opts)}
+}
+
+// Run calls fn with each message published by another origin, until ctx
+// is cancelled, reconnecting with backoff when Redis fails. Messages
+// published while disconnected are lost, so resync, when not nil, is
+// called each time the subscription is made again after a failure.
+func (s *Subscriber) Run(ctx context.Context, fn func(Message), resync func()) {
+	backoff := retryBase
+	failed := false
+	for ctx.Err() == nil {
+		err := s.listen(ctx, fn, func() {
+			backoff = retryBase
+			if failed && resync != nil {
+				resync()
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		failed = true
+		s.opts.logger.Warn("redis_subscription_lost", zap.Duration("backoff", backoff), zap.Error(err))
+		if !sleep(ctx, backoff) {
+			return
+		}
+		backoff = min(2*backoff, retryMax)
+	}
+}
+
+// listen subscribes and reads messages until the connection fails or ctx
+// is cancelled; subscribed is called once the server confirmed the
+// subscription
+func (s *Subscriber) listen(ctx context.Context, fn func(Message), subscribed func()) error {
+	c, err := dial(ctx, s.cfg)
+	if err != nil {
+		return err
+	}
+	// a blocked read only returns when the connection is closed
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+	defer c.Close()
+
+	c.write("PSUBSCRIBE", s.pattern)
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return err
+		}
+		items, ok := reply.([]any)
+		if !ok || len(items) < 3 {
+			return fmt.Errorf("redis: unexpected reply %v", reply)
+		}
+		switch items[0] {
+		case "psubscribe":
+			subscribed()
+		case "pmessage":
+			if len(items) != 4 {
+				return errors.New("redis: malformed pmessage")
+			}
+			payload, _ := items[3].(string)
+			var m Message
+			if err := json.Unmarshal([]byte(payload), &m); err != nil {
+				s.opts.logger.Debug("redis_message_ignored", zap.Any("channel", items[2]), zap.Error(err))
+				continue
+			}
+			if m.Origin != s.opts.origin {
+				fn(m)
+			}
+		}
+	}
+}
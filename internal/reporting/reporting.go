@@ -0,0 +1,87 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"segmentation-api/internal/config"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Reporter sends errors that need a human look to an error tracker.
+// Tags carry the request or run context the error happened in.
+// Implementations skip errors for which Reportable is false.
+type Reporter interface {
+	Report(ctx context.Context, err error, tags map[string]string)
+	// Flush waits up to timeout for pending reports to be delivered
+	Flush(timeout time.Duration)
+}
+
+// Nop discards every report; it is used when no tracker is configured
+type Nop struct{}
+
+func (Nop) Report(context.Context, error, map[string]string) {}
+func (Nop) Flush(time.Duration)                              {}
+
+// New returns a Sentry reporter when cfg.DSN is set and Nop otherwise.
+// The environment and release are passed through.
+func New(cfg config.Sentry) (Reporter, error) {
+	if cfg.DSN == "" {
+		return Nop{}, nil
+	}
+	return NewSentry(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      cfg.Environment,
+		Release:          cfg.Release,
+		AttachStacktrace: true,
+	})
+}
+
+// Sentry reports errors to Sentry
+type Sentry struct {
+	hub *sentry.Hub
+}
+
+// NewSentry creates a reporter with its own Sentry client
+func NewSentry(opts sentry.ClientOptions) (*Sentry, error) {
+	client, err := sentry.NewClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("sentry: %w", err)
+	}
+	return &Sentry{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+func (s *Sentry) Report(ctx context.Context, err error, tags map[string]string) {
+	if !Reportable(err) {
+		return
+	}
+	hub := s.hub.Clone()
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		hub.CaptureException(err)
+	})
+}
+
+func (s *Sentry) Flush(timeout time.Duration) {
+	s.hub.Flush(timeout)
+}
+
+// Reportable reports whether err is worth sending: cancellations and
+// deadlines are transient and expected during shutdowns or client
+// disconnects
+func Reportable(err error) bool {
+	return err != nil &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// PanicError wraps a recovered panic value as an error
+func PanicError(p any) error {
+	if err, ok := p.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", p)
+}
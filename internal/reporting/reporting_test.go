@@ -0,0 +1,53 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"segmentation-api/internal/config"
+)
+
+func TestReportable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: context.Canceled, want: false},
+		{err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: false},
+		{err: errors.New("db down"), want: true},
+	}
+	for _, tt := range tests {
+		if got := Reportable(tt.err); got != tt.want {
+			t.Errorf("Reportable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	cause := errors.New("nil map")
+	if err := PanicError(cause); !errors.Is(err, cause) || err.Error() != "panic: nil map" {
+		t.Errorf("PanicError(error) = %v", err)
+	}
+	if err := PanicError(42); err.Error() != "panic: 42" {
+		t.Errorf("PanicError(42) = %v", err)
+	}
+}
+
+func TestNew_NopWithoutDSN(t *testing.T) {
+	r, err := New(config.Sentry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.(Nop); !ok {
+		t.Errorf("New() = %T, want Nop", r)
+	}
+}
+
+func TestNew_InvalidDSN(t *testing.T) {
+	if _, err := New(config.Sentry{DSN: "not a dsn"}); err == nil {
+		t.Error("expected error for invalid DSN")
+	}
+}
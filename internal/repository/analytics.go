@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+// SegmentCount é um segmento (tipo e nome) com o número de usuários nele
+type SegmentCount struct {
+	SegmentationType string `json:"segmentation_type" example:"drug"`
+	SegmentationName string `json:"segmentation_name" example:"Dipirona"`
+	Users            int64  `json:"users"`
+}
+
+// UserCount diz quantos usuários têm exatamente Segmentations segmentações
+type UserCount struct {
+	Segmentations int64
+	Users         int64
+}
+
+// Segment identifica um segmento pelo tipo e pelo nome
+type Segment struct {
+	Type string
+	Name string
+}
+
+// SnapshotFilter restringe a consulta dos snapshots; campos vazios não
+// filtram. From e To são dias (YYYY-MM-DD), inclusivos.
+type SnapshotFilter struct {
+	Type string
+	From string
+	To   string
+}
+
+// AnalyticsRepository calcula os agregados da tabela de segmentações para o
+// painel interno. As consultas varrem a tabela ou um índice inteiro, então
+// quem chama deve guardar o resultado.
+type AnalyticsRepository interface {
+	// TopSegments retorna os limit segmentos com mais usuários de cada
+	// tipo, ou só de segType quando informado, por tipo e do maior para o
+	// menor
+	TopSegments(ctx context.Context, segType string, limit int) ([]SegmentCount, error)
+	// UserCounts retorna quantos usuários têm cada número de segmentações,
+	// contando só as de segType quando informado, em ordem de
+	// Segmentations; usuários sem nenhuma não aparecem
+	UserCounts(ctx context.Context, segType string) ([]UserCount, error)
+	// TakeSnapshot grava o tamanho de cada tipo no dia day, substituindo o
+	// snapshot anterior do mesmo dia, e retorna quantos tipos gravou
+	TakeSnapshot(ctx context.Context, day string, takenAt int64) (int64, error)
+	// Snapshots retorna os snapshots em ordem de dia e tipo
+	Snapshots(ctx context.Context, filter SnapshotFilter) ([]models.SegmentSnapshot, error)
+	// SegmentSizes retorna o número de usuários de cada segmento, na ordem
+	// dada
+	SegmentSizes(ctx context.Context, segments []Segment) ([]int64, error)
+	// Overlap retorna quantos usuários estão em todos os segmentos
+	// (interseção) e em ao menos um (união). Com samplePermille entre 1 e
+	// 999 conta só os usuários cujo CRC32 do ID módulo 1000 é menor que
+	// ele, a mesma amostra em todos os segmentos; 0 conta todos.
+	Overlap(ctx context.Context, segments []Segment, samplePermille int) (intersection, union int64, err error)
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+// AuditFilter restringe a consulta do audit log; campos vazios não filtram
+type AuditFilter struct {
+	Actor   string
+	Action  string
+	Since   int64  // unix, inclusivo
+	Until   int64  // unix, exclusivo
+	AfterID uint64 // exclusivo, para paginar: só entradas mais antigas que esta
+	Limit   int
+}
+
+type AuditLogRepository interface {
+	Insert(ctx context.Context, entry *models.AuditLog) error
+	// List retorna as entradas mais recentes primeiro, na ordem do id, de
+	// modo que o id da última entrada de uma página é o AfterID da próxima
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditLog, error)
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+// BackupRepository lê e grava a tabela de segmentações inteira, para
+// backup e restore
+type BackupRepository interface {
+	// Scan percorre as segmentações que passam em filter (todas com um
+	// filtro vazio) em ordem de id, em lotes de até batchSize, sem carregar
+	// a tabela em memória
+	Scan(ctx context.Context, filter Filter, batchSize int, fn func(batch []models.Segmentation) error) error
+	// Restore grava um lote pela chave (user_id, tipo, nome), mantendo
+	// data, created_at e updated_at do backup
+	Restore(ctx context.Context, items []models.Segmentation) error
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+// ChangeCursor é a posição no feed de mudanças: a última linha entregue,
+// pela ordem (updated_at, id). ID 0 inclui todas as linhas de UpdatedAt.
+type ChangeCursor struct {
+	UpdatedAt int64 // unix
+	ID        uint64
+}
+
+// ChangeRepository lê as segmentações alteradas, para sincronizações
+// incrementais
+type ChangeRepository interface {
+	// Changes retorna até limit segmentações depois de after que passam em
+	// filter, em ordem de (updated_at, id)
+	Changes(ctx context.Context, after ChangeCursor, filter Filter, limit int) ([]models.Segmentation, error)
+}
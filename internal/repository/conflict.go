@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"strings"
+
+	"segmentation-api/internal/apperrors"
+)
+
+// ErrConflict indica que a escrita colidiu com uma linha que já existe numa
+// chave única, em geral por escritas concorrentes da mesma chave
+var ErrConflict = apperrors.New("conflicting write", apperrors.ErrConflict)
+
+// ConflictError é a escrita recusada por chave única duplicada. Key são as
+// colunas da chave com os nomes usados na API e Value o valor repetido,
+// ambos vazios quando a chave não é conhecida. O erro do MySQL fica só em
+// Err, para que o nome do índice e o schema não apareçam nas respostas.
+type ConflictError struct {
+	Key   []string
+	Value string
+	Err   error
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Key) == 0 {
+		return ErrConflict.Error()
+	}
+	return ErrConflict.Error() + " on (" + strings.Join(e.Key, ", ") + ")"
+}
+
+// Details devolve a chave e o valor em conflito para a resposta da API
+func (e *ConflictError) Details() map[string]any {
+	if len(e.Key) == 0 {
+		return nil
+	}
+	return map[string]any{"key": e.Key, "value": e.Value}
+}
+
+func (e *ConflictError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrConflict}
+	}
+	return []error{ErrConflict, e.Err}
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+type DeadLetterRepository interface {
+	Insert(ctx context.Context, entries []models.DeadLetter) error
+	// List retorna até limit entradas do run com ID maior que afterID, em
+	// ordem de ID (a ordem em que foram gravadas)
+	List(ctx context.Context, runID string, afterID uint64, limit int) ([]models.DeadLetter, error)
+}
@@ -0,0 +1,126 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+)
+
+// bulkAction é uma operação do _bulk; o corpo vai na linha seguinte, menos
+// no delete
+type bulkAction struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Version     int64  `json:"version"`
+	VersionType string `json:"version_type"`
+}
+
+// Publish espelha eventos do outbox no índice: upserts indexam o
+// documento e deletes o apagam, na ordem dos eventos. É o
+// outbox.Publisher do índice.
+func (x *Index) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range events {
+		key := e.NameKey
+		if key == "" {
+			// eventos de antes da coluna name_key
+			key = e.SegmentationName
+		}
+		action := bulkAction{
+			Index:       x.cfg.Index,
+			ID:          documentID(e.UserID, e.SegmentationType, key),
+			Version:     e.CreatedAt,
+			VersionType: "external_gte",
+		}
+		if e.EventType == models.OutboxDeleted {
+			enc.Encode(map[string]bulkAction{"delete": action})
+			continue
+		}
+		enc.Encode(map[string]bulkAction{"index": action})
+		if err := enc.Encode(newDocument(e.UserID, e.SegmentationType, e.SegmentationName, e.Data, e.CreatedAt)); err != nil {
+			return err
+		}
+	}
+	return x.bulk(ctx, body.Bytes(), len(events))
+}
+
+// IndexSegmentations indexa as segmentações como estão no MySQL, para
+// carregar o índice pela primeira vez ou reconstruí-lo
+func (x *Index) IndexSegmentations(ctx context.Context, segs []models.Segmentation) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, s := range segs {
+		enc.Encode(map[string]bulkAction{"index": {
+			Index:       x.cfg.Index,
+			ID:          documentID(s.UserID, s.SegmentationType, s.UniqueName()),
+			Version:     s.UpdatedAt,
+			VersionType: "external_gte",
+		}})
+		if err := enc.Encode(newDocument(s.UserID, s.SegmentationType, s.SegmentationName, s.Data, s.UpdatedAt)); err != nil {
+			return err
+		}
+	}
+	return x.bulk(ctx, body.Bytes(), len(segs))
+}
+
+// bulk envia as operações num _bulk. Conflitos de versão (o índice já tem
+// uma escrita mais nova) e deletes de documentos ausentes não são erro; um
+// documento recusado pelo índice é registrado e pulado, para não travar o
+// outbox. Falhas do índice inteiro falham o lote, que é reenviado.
+func (x *Index) bulk(ctx context.Context, body []byte, n int) error {
+	if n == 0 {
+		return nil
+	}
+	if err := x.EnsureIndex(ctx); err != nil {
+		return err
+	}
+	status, raw, err := x.do(ctx, http.MethodPost, "/_bulk", body, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	if status/100 != 2 {
+		return fmt.Errorf("elasticsearch: bulk: %s", errorMessage(status, raw))
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return fmt.Errorf("elasticsearch: bulk: invalid response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	for _, item := range resp.Items {
+		for op, r := range item {
+			switch {
+			case r.Error == nil, r.Status == http.StatusConflict, op == "delete" && r.Status == http.StatusNotFound:
+			case r.Status == http.StatusTooManyRequests || r.Status >= 500:
+				return fmt.Errorf("%w: bulk %s %s: %s: %s", ErrUnavailable, op, r.ID, r.Error.Type, r.Error.Reason)
+			default:
+				x.logger.Error("search_document_rejected",
+					zap.String("op", op),
+					zap.String("id", r.ID),
+					zap.Int("status", r.Status),
+					zap.String("reason", r.Error.Type+": "+r.Error.Reason),
+				)
+			}
+		}
+	}
+	return nil
+}
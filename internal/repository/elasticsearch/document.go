@@ -0,0 +1,93 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// maxDataValues limita os valores de data indexados por segmentação
+const maxDataValues = 1000
+
+// document é uma segmentação no índice. Não há created_at: os eventos do
+// outbox não o trazem, e cada escrita substitui o documento inteiro.
+type document struct {
+	UserID           uint64          `json:"user_id"`
+	SegmentationType string          `json:"segmentation_type"`
+	SegmentationName string          `json:"segmentation_name"`
+	Data             json.RawMessage `json:"data,omitempty"`
+	DataPairs        []string        `json:"data_pairs,omitempty"`
+	DataText         []string        `json:"data_text,omitempty"`
+	UpdatedAt        int64           `json:"updated_at"`
+}
+
+// documentID identifica a segmentação pela chave única (user_id, tipo,
+// chave do nome); o hash mantém o ID dentro dos 512 bytes do índice
+func documentID(userID uint64, segType, nameKey string) string {
+	sum := sha256.Sum256([]byte(strconv.FormatUint(userID, 10) + "\x00" + segType + "\x00" + nameKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func newDocument(userID uint64, segType, name string, data []byte, updatedAt int64) document {
+	doc := document{
+		UserID:           userID,
+		SegmentationType: segType,
+		SegmentationName: name,
+		UpdatedAt:        updatedAt,
+	}
+	if len(bytes.TrimSpace(data)) > 0 && json.Valid(data) {
+		doc.Data = json.RawMessage(data)
+		doc.DataPairs, doc.DataText = flatten(data)
+	}
+	return doc
+}
+
+// flatten achata o JSON de data: cada valor escalar vira um par
+// "chave.aninhada=valor", com o valor como texto (a comparação dos filtros
+// de data também é por texto), e os valores de texto vão para a busca
+// aproximada. Os elementos de um array ficam sob a chave do array.
+func flatten(data []byte) (pairs, text []string) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil {
+		return nil, nil
+	}
+
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		if len(pairs) >= maxDataValues {
+			return
+		}
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				if path != "" {
+					k = path + "." + k
+				}
+				walk(k, child)
+			}
+		case []any:
+			for _, child := range t {
+				walk(path, child)
+			}
+		case string:
+			pairs = append(pairs, path+"="+t)
+			text = append(text, t)
+		case json.Number:
+			pairs = append(pairs, path+"="+t.String())
+		case bool:
+			pairs = append(pairs, path+"="+strconv.FormatBool(t))
+		}
+	}
+	walk("", v)
+	return pairs, text
+}
+
+// dataPair é o valor de data_pairs de uma condição data.<chave> = value
+func dataPair(path []string, value string) string {
+	return strings.Join(path, ".") + "=" + value
+}
@@ -0,0 +1,206 @@
+// Package elasticsearch espelha as segmentações num índice do
+// Elasticsearch ou do OpenSearch e busca nele: nome aproximado (fuzzy) e
+// filtros por chaves de data, que as funções JSON do MySQL atendem mal.
+//
+// O índice é alimentado pelo outbox (Index implementa outbox.Publisher),
+// então upserts e deletes chegam na ordem das escritas, e pelo comando
+// reindex, que carrega a tabela inteira. Cada documento é versionado pelo
+// updated_at da escrita (version_type external_gte): um documento antigo
+// nunca sobrescreve um mais novo, qualquer que seja o caminho.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/apperrors"
+)
+
+// ErrUnavailable indica que o índice não respondeu; a API responde 503
+var ErrUnavailable = apperrors.New("search index unavailable", apperrors.ErrUnavailable)
+
+// Config localiza o índice. A autenticação é por APIKey (Elasticsearch) ou
+// por Username e Password (básica, também a do OpenSearch).
+type Config struct {
+	URL      string
+	Index    string
+	Username string
+	Password string
+	APIKey   string
+}
+
+// Index lê e grava as segmentações no índice
+type Index struct {
+	cfg    Config
+	base   *url.URL
+	http   *http.Client
+	logger *zap.Logger
+
+	// ready indica que o índice já existe com o mapeamento de mapping
+	mu    sync.Mutex
+	ready bool
+}
+
+// Option personaliza um Index
+type Option func(*Index)
+
+// WithLogger define o logger dos documentos recusados pelo índice
+func WithLogger(logger *zap.Logger) Option {
+	return func(x *Index) {
+		x.logger = logger
+	}
+}
+
+// New cria o acesso ao índice de cfg por hc, ou http.DefaultClient se nil
+func New(cfg Config, hc *http.Client, opts ...Option) (*Index, error) {
+	base, err := url.Parse(strings.TrimRight(cfg.URL, "/"))
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("elasticsearch: invalid URL %q", cfg.URL)
+	}
+	if cfg.Index == "" || strings.ContainsAny(cfg.Index, `/\*?"<>| ,#:`) || cfg.Index != strings.ToLower(cfg.Index) {
+		return nil, fmt.Errorf("elasticsearch: invalid index name %q", cfg.Index)
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	x := &Index{cfg: cfg, base: base, http: hc, logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(x)
+	}
+	return x, nil
+}
+
+// mapping é o do índice: data fica só no _source (sem explosão de campos),
+// data_pairs guarda "chave.aninhada=valor" de cada valor de data, para os
+// filtros, e data_text o texto dos valores, para a busca aproximada
+var mapping = map[string]any{
+	"settings": map[string]any{
+		"analysis": map[string]any{
+			"analyzer": map[string]any{
+				"folded": map[string]any{
+					"tokenizer": "standard",
+					"filter":    []string{"lowercase", "asciifolding"},
+				},
+			},
+		},
+	},
+	"mappings": map[string]any{
+		"dynamic": "strict",
+		"properties": map[string]any{
+			"user_id":           map[string]any{"type": "long"},
+			"segmentation_type": map[string]any{"type": "keyword"},
+			"segmentation_name": map[string]any{
+				"type":     "text",
+				"analyzer": "folded",
+				"fields":   map[string]any{"raw": map[string]any{"type": "keyword"}},
+			},
+			"data":       map[string]any{"type": "object", "enabled": false},
+			"data_pairs": map[string]any{"type": "keyword", "ignore_above": 1024},
+			"data_text":  map[string]any{"type": "text", "analyzer": "folded"},
+			"updated_at": map[string]any{"type": "date", "format": "epoch_second"},
+		},
+	},
+}
+
+// EnsureIndex cria o índice com o mapeamento, se ele ainda não existir.
+// As escritas chamam antes da primeira gravação, para o índice nunca
+// nascer com o mapeamento dinâmico.
+func (x *Index) EnsureIndex(ctx context.Context) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.ready {
+		return nil
+	}
+
+	status, _, err := x.do(ctx, http.MethodHead, "/"+x.cfg.Index, nil, "")
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		body, _ := json.Marshal(mapping)
+		status, raw, err := x.do(ctx, http.MethodPut, "/"+x.cfg.Index, body, "application/json")
+		if err != nil {
+			return err
+		}
+		// outra instância pode ter criado o índice no meio tempo
+		if status/100 != 2 && !strings.Contains(string(raw), "resource_already_exists_exception") {
+			return fmt.Errorf("elasticsearch: create index %s: %s", x.cfg.Index, errorMessage(status, raw))
+		}
+	} else if status/100 != 2 {
+		return fmt.Errorf("elasticsearch: check index %s: status %d", x.cfg.Index, status)
+	}
+	x.ready = true
+	return nil
+}
+
+// do envia uma requisição autenticada e devolve o status e o corpo da
+// resposta; falhas de conexão e respostas 429/5xx saem como ErrUnavailable
+func (x *Index) do(ctx context.Context, method, path string, body []byte, contentType string) (int, []byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, x.base.String()+path, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case x.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+x.cfg.APIKey)
+	case x.cfg.Username != "":
+		req.SetBasicAuth(x.cfg.Username, x.cfg.Password)
+	}
+
+	resp, err := x.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, nil, err
+		}
+		return 0, nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return resp.StatusCode, raw, fmt.Errorf("%w: %s", ErrUnavailable, errorMessage(resp.StatusCode, raw))
+	}
+	return resp.StatusCode, raw, nil
+}
+
+// errorMessage extrai o motivo de uma resposta de erro,
+// {"error": {"type": ..., "reason": ...}}, ou devolve o corpo
+func errorMessage(status int, raw []byte) string {
+	var body struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error.Reason != "" {
+		return fmt.Sprintf("status %d: %s: %s", status, body.Error.Type, body.Error.Reason)
+	}
+	msg := strings.TrimSpace(string(raw))
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	return fmt.Sprintf("status %d: %s", status, msg)
+}
+
+// isIndexNotFound informa se a resposta é de um índice que não existe
+func isIndexNotFound(status int, raw []byte) bool {
+	return status == http.StatusNotFound && bytes.Contains(raw, []byte("index_not_found_exception"))
+}
@@ -0,0 +1,194 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+func newTestIndex(t *testing.T, h http.HandlerFunc) *Index {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	x, err := New(Config{URL: srv.URL, Index: "segmentations", Username: "elastic", Password: "changeme"}, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return x
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{URL: "localhost:9200", Index: "segmentations"},
+		{URL: "http://localhost:9200", Index: "Segmentations"},
+		{URL: "http://localhost:9200", Index: "a/b"},
+		{URL: "http://localhost:9200"},
+	} {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("New(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	pairs, text := flatten([]byte(`{"posologia":{"dose":500,"unidade":"mg"},"tags":["a","b"],"ativo":true,"obs":null}`))
+	slices.Sort(pairs)
+	want := []string{"ativo=true", "posologia.dose=500", "posologia.unidade=mg", "tags=a", "tags=b"}
+	if !slices.Equal(pairs, want) {
+		t.Errorf("pairs = %v, want %v", pairs, want)
+	}
+	slices.Sort(text)
+	if !slices.Equal(text, []string{"a", "b", "mg"}) {
+		t.Errorf("text = %v", text)
+	}
+}
+
+func TestIndex_Publish(t *testing.T) {
+	var created bool
+	var bulk []map[string]any
+	x := newTestIndex(t, func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "elastic" || pass != "changeme" {
+			t.Errorf("unexpected credentials %q %q", user, pass)
+		}
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/segmentations":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut && r.URL.Path == "/segmentations":
+			raw, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(raw), `"dynamic":"strict"`) {
+				t.Errorf("the index should be created with its mapping, got %s", raw)
+			}
+			created = true
+		case r.URL.Path == "/_bulk":
+			sc := bufio.NewScanner(r.Body)
+			for sc.Scan() {
+				var line map[string]any
+				json.Unmarshal(sc.Bytes(), &line)
+				bulk = append(bulk, line)
+			}
+			io.WriteString(w, `{"errors":true,"items":[
+				{"index":{"_id":"a","status":201}},
+				{"delete":{"_id":"b","status":404,"error":{"type":"not_found","reason":"missing"}}},
+				{"index":{"_id":"c","status":409,"error":{"type":"version_conflict_engine_exception","reason":"newer"}}},
+				{"index":{"_id":"d","status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	events := []models.OutboxEvent{
+		{EventType: models.OutboxUpserted, UserID: 1, SegmentationType: "drug", SegmentationName: "Aspirina", NameKey: "aspirina",
+			Data: datatypes.JSON(`{"dose":"500mg"}`), CreatedAt: 100},
+		{EventType: models.OutboxDeleted, UserID: 1, SegmentationType: "drug", SegmentationName: "ASPIRINA", NameKey: "aspirina", CreatedAt: 200},
+		{EventType: models.OutboxUpserted, UserID: 2, SegmentationType: "drug", SegmentationName: "Dipirona", CreatedAt: 300},
+		{EventType: models.OutboxUpserted, UserID: 3, SegmentationType: "drug", SegmentationName: "Losartana", CreatedAt: 300},
+	}
+	if err := x.Publish(context.Background(), events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if !created || len(bulk) != 7 {
+		t.Fatalf("created = %v, %d bulk lines, want 7", created, len(bulk))
+	}
+
+	index := bulk[0]["index"].(map[string]any)
+	del := bulk[2]["delete"].(map[string]any)
+	if index["_id"] != del["_id"] || index["_id"] != documentID(1, "drug", "aspirina") {
+		t.Errorf("the upsert and the delete of the same key should hit one document: %v %v", index, del)
+	}
+	if index["version"] != 100.0 || index["version_type"] != "external_gte" || del["version"] != 200.0 {
+		t.Errorf("documents should be versioned by the write time: %v %v", index, del)
+	}
+	if pairs := bulk[1]["data_pairs"].([]any); len(pairs) != 1 || pairs[0] != "dose=500mg" {
+		t.Errorf("unexpected document %v", bulk[1])
+	}
+}
+
+func TestIndex_PublishUnavailable(t *testing.T) {
+	x := newTestIndex(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		io.WriteString(w, `{"errors":true,"items":[{"index":{"_id":"a","status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}]}`)
+	})
+	seg := models.Segmentation{UserID: 1, SegmentationType: "drug", SegmentationName: "Aspirina", UpdatedAt: 100}
+	err := x.IndexSegmentations(context.Background(), []models.Segmentation{seg})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("IndexSegmentations() error = %v, want a retryable failure", err)
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	var query map[string]any
+	x := newTestIndex(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/segmentations/_search" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&query)
+		io.WriteString(w, `{"hits":{"total":{"value":42},"hits":[
+			{"_score":3.5,"_source":{"user_id":7,"segmentation_type":"drug","segmentation_name":"Aspirina","data":{"dose":"500mg"},"updated_at":100}}]}}`)
+	})
+
+	res, err := x.Search(context.Background(), repository.SearchQuery{
+		Text: "aspirna",
+		Filter: repository.Filter{
+			{Field: "segmentation_type", Op: repository.FilterEq, Values: []string{"drug"}},
+			{Field: "data.posologia.unidade", Op: repository.FilterIn, Values: []string{"mg", "g"}},
+			{Field: "updated_at", Op: repository.FilterGte, Values: []string{"100"}},
+			{Field: "user_id", Op: repository.FilterNe, Values: []string{"1"}},
+		},
+		Limit:  10,
+		Offset: 20,
+	})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if res.Total != 42 || len(res.Hits) != 1 || res.Hits[0].Score != 3.5 || res.Hits[0].Segmentation.UserID != 7 ||
+		string(res.Hits[0].Segmentation.Data) != `{"dose":"500mg"}` {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	raw, _ := json.Marshal(query)
+	for _, want := range []string{
+		`"fuzziness":"AUTO"`,
+		`{"term":{"segmentation_type":"drug"}}`,
+		`{"terms":{"data_pairs":["posologia.unidade=mg","posologia.unidade=g"]}}`,
+		`{"range":{"updated_at":{"gte":"100"}}}`,
+		`"must_not":[{"term":{"user_id":"1"}}]`,
+		`"from":20`,
+	} {
+		if !bytes.Contains(raw, []byte(want)) {
+			t.Errorf("query should contain %s:\n%s", want, raw)
+		}
+	}
+}
+
+func TestIndex_SearchErrors(t *testing.T) {
+	x := newTestIndex(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":{"type":"index_not_found_exception","reason":"no such index [segmentations]"}}`)
+	})
+	res, err := x.Search(context.Background(), repository.SearchQuery{Limit: 10})
+	if err != nil || res.Total != 0 || res.Hits == nil {
+		t.Errorf("a missing index should give no results, got %+v, %v", res, err)
+	}
+
+	_, err = x.Search(context.Background(), repository.SearchQuery{Limit: 10, Filter: repository.Filter{
+		{Field: "created_at", Op: repository.FilterGt, Values: []string{"1"}},
+	}})
+	if !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("Search() error = %v, want ErrInvalidSearch for created_at", err)
+	}
+}
@@ -0,0 +1,148 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// ErrInvalidSearch é devolvido para uma busca que o índice não atende
+var ErrInvalidSearch = apperrors.New("invalid search", apperrors.ErrValidation)
+
+// searchFields são os campos filtráveis no índice e o campo de cada um;
+// created_at não é indexado
+var searchFields = map[string]string{
+	"user_id":           "user_id",
+	"segmentation_type": "segmentation_type",
+	"segmentation_name": "segmentation_name.raw",
+	"updated_at":        "updated_at",
+}
+
+var rangeOps = map[repository.FilterOp]string{
+	repository.FilterLt:  "lt",
+	repository.FilterLte: "lte",
+	repository.FilterGt:  "gt",
+	repository.FilterGte: "gte",
+}
+
+// Search busca Text de forma aproximada (fuzziness AUTO, sem acentos nem
+// caixa) no nome, com peso maior, e nos valores de texto de data. Os
+// filtros de data.<chave> comparam o valor como texto, e casam com
+// qualquer elemento quando a chave é um array.
+func (x *Index) Search(ctx context.Context, q repository.SearchQuery) (*repository.SearchResult, error) {
+	filter, mustNot, err := filterClauses(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	boolQuery := map[string]any{"filter": filter, "must_not": mustNot}
+	sort := []any{map[string]string{"updated_at": "desc"}}
+	if q.Text != "" {
+		boolQuery["must"] = map[string]any{"multi_match": map[string]any{
+			"query":     q.Text,
+			"fields":    []string{"segmentation_name^3", "data_text"},
+			"fuzziness": "AUTO",
+			"operator":  "and",
+		}}
+		sort = append([]any{"_score"}, sort...)
+	}
+	body, err := json.Marshal(map[string]any{
+		"from":             q.Offset,
+		"size":             q.Limit,
+		"track_total_hits": true,
+		"query":            map[string]any{"bool": boolQuery},
+		"sort":             sort,
+		"_source":          []string{"user_id", "segmentation_type", "segmentation_name", "data", "updated_at"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status, raw, err := x.do(ctx, http.MethodPost, "/"+x.cfg.Index+"/_search", body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	if isIndexNotFound(status, raw) {
+		// nada foi indexado ainda
+		return &repository.SearchResult{Hits: []repository.SearchHit{}}, nil
+	}
+	if status/100 != 2 {
+		return nil, fmt.Errorf("elasticsearch: search: %s", errorMessage(status, raw))
+	}
+
+	var resp struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Score  *float64 `json:"_score"`
+				Source document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("elasticsearch: search: invalid response: %w", err)
+	}
+	result := &repository.SearchResult{
+		Total: resp.Hits.Total.Value,
+		Hits:  make([]repository.SearchHit, 0, len(resp.Hits.Hits)),
+	}
+	for _, h := range resp.Hits.Hits {
+		hit := repository.SearchHit{Segmentation: models.Segmentation{
+			UserID:           h.Source.UserID,
+			SegmentationType: h.Source.SegmentationType,
+			SegmentationName: h.Source.SegmentationName,
+			Data:             datatypes.JSON(h.Source.Data),
+			UpdatedAt:        h.Source.UpdatedAt,
+		}}
+		if h.Score != nil {
+			hit.Score = *h.Score
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
+
+// filterClauses traduz as condições para cláusulas filter e must_not de
+// uma consulta bool
+func filterClauses(f repository.Filter) (filter, mustNot []any, err error) {
+	filter, mustNot = []any{}, []any{}
+	for _, c := range f {
+		field, values := searchFields[c.Field], c.Values
+		if path := c.DataPath(); path != nil {
+			field = "data_pairs"
+			values = make([]string, len(c.Values))
+			for i, v := range c.Values {
+				values[i] = dataPair(path, v)
+			}
+		}
+		if field == "" {
+			return nil, nil, fmt.Errorf("%w: field %q cannot be searched", ErrInvalidSearch, c.Field)
+		}
+
+		switch c.Op {
+		case repository.FilterEq:
+			filter = append(filter, map[string]any{"term": map[string]string{field: values[0]}})
+		case repository.FilterNe:
+			mustNot = append(mustNot, map[string]any{"term": map[string]string{field: values[0]}})
+		case repository.FilterIn:
+			filter = append(filter, map[string]any{"terms": map[string][]string{field: values}})
+		case repository.FilterPrefix:
+			filter = append(filter, map[string]any{"prefix": map[string]string{field: values[0]}})
+		default:
+			op, ok := rangeOps[c.Op]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: operator %q", ErrInvalidSearch, c.Op)
+			}
+			filter = append(filter, map[string]any{"range": map[string]any{field: map[string]string{op: values[0]}}})
+		}
+	}
+	return filter, mustNot, nil
+}
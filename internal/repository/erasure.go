@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+// ErasureFilter restringe a consulta dos registros de eliminação; campos
+// vazios não filtram
+type ErasureFilter struct {
+	UserID  uint64
+	AfterID uint64 // exclusivo, para paginar
+	Limit   int
+}
+
+// ErasureRepository apaga os dados de um usuário e guarda a prova da
+// eliminação
+type ErasureRepository interface {
+	// Erase apaga numa única transação as segmentações de erasure.UserID,
+	// os eventos do outbox do usuário e as entregas de webhook desses
+	// eventos; grava os eventos de remoção das segmentações apagadas, com o
+	// outbox ligado, e por último o registro. Preenche as contagens,
+	// PrevHash (o Hash do último registro) e Hash de erasure e retorna as
+	// segmentações apagadas.
+	Erase(ctx context.Context, erasure *models.Erasure) ([]models.Segmentation, error)
+	// List retorna os registros em ordem de ID, a ordem da cadeia
+	List(ctx context.Context, filter ErasureFilter) ([]models.Erasure, error)
+}
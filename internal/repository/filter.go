@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FilterOp é o operador de uma Condition
+type FilterOp string
+
+const (
+	FilterEq     FilterOp = "eq"
+	FilterNe     FilterOp = "ne"
+	FilterLt     FilterOp = "lt"
+	FilterLte    FilterOp = "lte"
+	FilterGt     FilterOp = "gt"
+	FilterGte    FilterOp = "gte"
+	FilterIn     FilterOp = "in"
+	FilterPrefix FilterOp = "prefix"
+)
+
+// DataFieldPrefix inicia os campos que filtram por uma chave de data, como
+// data.dose ou data.posologia.unidade
+const DataFieldPrefix = "data."
+
+// MaxFilterValues limita os valores de uma condição in
+const MaxFilterValues = 100
+
+// fieldKind diz quais operadores e valores um campo aceita
+type fieldKind int
+
+const (
+	numericField fieldKind = iota
+	textField
+)
+
+// filterFields são os únicos campos filtráveis, além das chaves de data.
+// Nenhum outro nome vindo de um cliente é aceito.
+var filterFields = map[string]fieldKind{
+	"user_id":           numericField,
+	"segmentation_type": textField,
+	"segmentation_name": textField,
+	"created_at":        numericField,
+	"updated_at":        numericField,
+}
+
+var fieldOps = map[fieldKind][]FilterOp{
+	numericField: {FilterEq, FilterNe, FilterLt, FilterLte, FilterGt, FilterGte, FilterIn},
+	textField:    {FilterEq, FilterNe, FilterIn, FilterPrefix},
+}
+
+// dataKey é uma chave de data aceita num filtro; as chaves vão como
+// parâmetro para o SQL de qualquer forma, isto só recusa cedo o que nunca
+// seria uma chave
+var dataKey = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// maxDataDepth limita os níveis de uma chave de data
+const maxDataDepth = 8
+
+// Condition restringe um campo: Values tem um valor, ou vários com
+// FilterIn. Chaves de data são comparadas como texto.
+type Condition struct {
+	Field  string
+	Op     FilterOp
+	Values []string
+}
+
+// Filter é a conjunção das suas condições
+type Filter []Condition
+
+// DataPath devolve as chaves de um campo data.<chave>[.<chave>...], ou nil
+// se o campo não é de data
+func (c Condition) DataPath() []string {
+	path, ok := strings.CutPrefix(c.Field, DataFieldPrefix)
+	if !ok {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// Validate confere campos, operadores e valores contra a lista de campos
+// filtráveis. Os repositórios validam de novo antes de montar a consulta.
+func (f Filter) Validate() error {
+	for _, c := range f {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Condition) validate() error {
+	kind, ok := filterFields[c.Field]
+	if path := c.DataPath(); path != nil {
+		if len(path) > maxDataDepth {
+			return fmt.Errorf("field %q: at most %d levels of data keys", c.Field, maxDataDepth)
+		}
+		for _, key := range path {
+			if !dataKey.MatchString(key) {
+				return fmt.Errorf("field %q: data keys are letters, digits, _ and -", c.Field)
+			}
+		}
+		kind, ok = textField, true
+	}
+	if !ok {
+		return fmt.Errorf("field %q cannot be filtered", c.Field)
+	}
+	if !slices.Contains(fieldOps[kind], c.Op) {
+		return fmt.Errorf("field %q does not support %q", c.Field, c.Op)
+	}
+
+	switch {
+	case len(c.Values) == 0:
+		return fmt.Errorf("field %q: missing value", c.Field)
+	case c.Op != FilterIn && len(c.Values) > 1:
+		return fmt.Errorf("field %q: %q takes a single value", c.Field, c.Op)
+	case len(c.Values) > MaxFilterValues:
+		return fmt.Errorf("field %q: at most %d values", c.Field, MaxFilterValues)
+	}
+	if kind == numericField {
+		for _, v := range c.Values {
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+				return fmt.Errorf("field %q: %q is not an integer", c.Field, v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilter_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr string
+	}{
+		{name: "column", filter: Filter{{Field: "segmentation_type", Op: FilterEq, Values: []string{"drug"}}}},
+		{name: "numeric in", filter: Filter{{Field: "user_id", Op: FilterIn, Values: []string{"1", "2"}}}},
+		{name: "data key", filter: Filter{{Field: "data.posologia.unidade", Op: FilterPrefix, Values: []string{"m"}}}},
+		{name: "unknown field", filter: Filter{{Field: "name_key", Op: FilterEq, Values: []string{"x"}}}, wantErr: "cannot be filtered"},
+		{name: "injected field", filter: Filter{{Field: "user_id = 1 OR 1", Op: FilterEq, Values: []string{"1"}}}, wantErr: "cannot be filtered"},
+		{name: "injected data key", filter: Filter{{Field: `data.a") OR 1=1 -- `, Op: FilterEq, Values: []string{"x"}}}, wantErr: "data keys"},
+		{name: "empty data key", filter: Filter{{Field: "data.", Op: FilterEq, Values: []string{"x"}}}, wantErr: "data keys"},
+		{name: "unknown op", filter: Filter{{Field: "user_id", Op: "like", Values: []string{"1"}}}, wantErr: "does not support"},
+		{name: "prefix on number", filter: Filter{{Field: "user_id", Op: FilterPrefix, Values: []string{"1"}}}, wantErr: "does not support"},
+		{name: "range on text", filter: Filter{{Field: "segmentation_name", Op: FilterGt, Values: []string{"a"}}}, wantErr: "does not support"},
+		{name: "not a number", filter: Filter{{Field: "created_at", Op: FilterGte, Values: []string{"yesterday"}}}, wantErr: "not an integer"},
+		{name: "many values", filter: Filter{{Field: "user_id", Op: FilterEq, Values: []string{"1", "2"}}}, wantErr: "single value"},
+		{name: "no value", filter: Filter{{Field: "user_id", Op: FilterIn}}, wantErr: "missing value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
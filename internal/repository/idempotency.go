@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+type IdempotencyRepository interface {
+	// Find retorna nil, nil quando a chave não existe ou já expirou
+	Find(ctx context.Context, key string, now int64) (*models.IdempotencyKey, error)
+	Save(ctx context.Context, k *models.IdempotencyKey) error
+	DeleteExpired(ctx context.Context, now int64) (int64, error)
+}
@@ -0,0 +1,194 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type analyticsRepository struct {
+	db *gorm.DB
+}
+
+func NewAnalyticsRepository(db *gorm.DB) repository.AnalyticsRepository {
+	return &analyticsRepository{db: db}
+}
+
+func (r *analyticsRepository) TopSegments(
+	ctx context.Context,
+	segType string,
+	limit int,
+) ([]repository.SegmentCount, error) {
+
+	// a chave única (user_id, tipo, nome) garante uma linha por usuário em
+	// cada segmento, então COUNT(*) conta usuários; o agrupamento percorre
+	// idx_segmentations_type_name
+	where, args := "", []any{}
+	if segType != "" {
+		where, args = "WHERE segmentation_type = ?", append(args, segType)
+	}
+	args = append(args, limit)
+
+	var counts []repository.SegmentCount
+	err := r.db.WithContext(ctx).Raw(`
+	SELECT segmentation_type, segmentation_name, users
+	FROM (
+		SELECT segmentation_type, segmentation_name, COUNT(*) AS users,
+		ROW_NUMBER() OVER (
+			PARTITION BY segmentation_type
+			ORDER BY COUNT(*) DESC, segmentation_name
+		) AS position
+		FROM segmentations
+		`+where+`
+		GROUP BY segmentation_type, segmentation_name
+	) ranked
+	WHERE position <= ?
+	ORDER BY segmentation_type, position
+	`, args...).Scan(&counts).Error
+	return counts, err
+}
+
+func (r *analyticsRepository) UserCounts(
+	ctx context.Context,
+	segType string,
+) ([]repository.UserCount, error) {
+
+	where, args := "", []any{}
+	if segType != "" {
+		where, args = "WHERE segmentation_type = ?", append(args, segType)
+	}
+
+	var counts []repository.UserCount
+	err := r.db.WithContext(ctx).Raw(`
+	SELECT segmentations, COUNT(*) AS users
+	FROM (
+		SELECT COUNT(*) AS segmentations
+		FROM segmentations
+		`+where+`
+		GROUP BY user_id
+	) per_user
+	GROUP BY segmentations
+	ORDER BY segmentations
+	`, args...).Scan(&counts).Error
+	return counts, err
+}
+
+func (r *analyticsRepository) TakeSnapshot(
+	ctx context.Context,
+	day string,
+	takenAt int64,
+) (int64, error) {
+
+	tx := r.db.WithContext(ctx).Exec(`
+	INSERT INTO segment_snapshots
+	(day, segmentation_type, segmentations, users, segments, taken_at)
+	SELECT ?, segmentation_type, COUNT(*), COUNT(DISTINCT user_id), COUNT(DISTINCT segmentation_name), ?
+	FROM segmentations
+	GROUP BY segmentation_type
+	ON DUPLICATE KEY UPDATE
+	segmentations = VALUES(segmentations),
+	users = VALUES(users),
+	segments = VALUES(segments),
+	taken_at = VALUES(taken_at)
+	`, day, takenAt)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	// o MySQL conta 2 por linha atualizada; o número de tipos vem da
+	// consulta
+	var types int64
+	err := r.db.WithContext(ctx).Model(&models.SegmentSnapshot{}).
+		Where("day = ?", day).
+		Count(&types).Error
+	return types, err
+}
+
+func (r *analyticsRepository) Snapshots(
+	ctx context.Context,
+	filter repository.SnapshotFilter,
+) ([]models.SegmentSnapshot, error) {
+
+	q := r.db.WithContext(ctx).Model(&models.SegmentSnapshot{})
+
+	if filter.Type != "" {
+		q = q.Where("segmentation_type = ?", filter.Type)
+	}
+	if filter.From != "" {
+		q = q.Where("day >= ?", filter.From)
+	}
+	if filter.To != "" {
+		q = q.Where("day <= ?", filter.To)
+	}
+
+	var snapshots []models.SegmentSnapshot
+	err := q.Order("day").Order("segmentation_type").Find(&snapshots).Error
+	return snapshots, err
+}
+
+func (r *analyticsRepository) SegmentSizes(
+	ctx context.Context,
+	segments []repository.Segment,
+) ([]int64, error) {
+
+	// uma consulta por segmento, cada uma um intervalo de
+	// idx_segmentations_type_name, numa única ida ao banco
+	parts := make([]string, len(segments))
+	args := make([]any, 0, 3*len(segments))
+	for i, s := range segments {
+		parts[i] = "SELECT ? AS position, COUNT(*) AS users FROM segmentations WHERE segmentation_type = ? AND segmentation_name = ?"
+		args = append(args, i, s.Type, s.Name)
+	}
+
+	var rows []struct {
+		Position int
+		Users    int64
+	}
+	err := r.db.WithContext(ctx).Raw(strings.Join(parts, " UNION ALL "), args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	sizes := make([]int64, len(segments))
+	for _, row := range rows {
+		sizes[row.Position] = row.Users
+	}
+	return sizes, nil
+}
+
+func (r *analyticsRepository) Overlap(
+	ctx context.Context,
+	segments []repository.Segment,
+	samplePermille int,
+) (int64, int64, error) {
+
+	// INTERSECT precisa do MySQL 8.0.31; UNION sem ALL já descarta os
+	// usuários repetidos
+	sample := ""
+	if samplePermille > 0 && samplePermille < 1000 {
+		sample = " AND CRC32(user_id) % 1000 < ?"
+	}
+	parts := make([]string, len(segments))
+	var args []any
+	for i, s := range segments {
+		parts[i] = "(SELECT user_id FROM segmentations WHERE segmentation_type = ? AND segmentation_name = ?" + sample + ")"
+		args = append(args, s.Type, s.Name)
+		if sample != "" {
+			args = append(args, samplePermille)
+		}
+	}
+
+	var counts struct {
+		IntersectionSize int64
+		UnionSize        int64
+	}
+	err := r.db.WithContext(ctx).Raw(`
+	SELECT
+	(SELECT COUNT(*) FROM (`+strings.Join(parts, " INTERSECT ")+`) i) AS intersection_size,
+	(SELECT COUNT(*) FROM (`+strings.Join(parts, " UNION ")+`) u) AS union_size
+	`, append(args, args...)...).Scan(&counts).Error
+	return counts.IntersectionSize, counts.UnionSize, err
+}
@@ -0,0 +1,11 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestAnalyticsRepositoryInterface(t *testing.T) {
+	var _ repository.AnalyticsRepository = (*analyticsRepository)(nil)
+}
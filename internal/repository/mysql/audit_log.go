@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) repository.AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+func (r *auditLogRepository) Insert(
+	ctx context.Context,
+	entry *models.AuditLog,
+) error {
+
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r *auditLogRepository) List(
+	ctx context.Context,
+	filter repository.AuditFilter,
+) ([]models.AuditLog, error) {
+
+	q := r.db.WithContext(ctx).Model(&models.AuditLog{})
+
+	if filter.Actor != "" {
+		q = q.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.Since > 0 {
+		q = q.Where("created_at >= ?", filter.Since)
+	}
+	if filter.Until > 0 {
+		q = q.Where("created_at < ?", filter.Until)
+	}
+	if filter.AfterID > 0 {
+		q = q.Where("id < ?", filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	var entries []models.AuditLog
+	// created_at vem do relógio de cada instância; o id é a ordem de
+	// inserção e serve de cursor
+	err := q.Order("id DESC").Find(&entries).Error
+	return entries, err
+}
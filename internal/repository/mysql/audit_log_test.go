@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestAuditLogRepositoryInterface(t *testing.T) {
+	var _ repository.AuditLogRepository = (*auditLogRepository)(nil)
+}
+
+func TestNewAuditLogRepository(t *testing.T) {
+	repo := NewAuditLogRepository(nil)
+	if repo == nil {
+		t.Fatal("NewAuditLogRepository should not return nil")
+	}
+
+	if _, ok := repo.(*auditLogRepository); !ok {
+		t.Error("NewAuditLogRepository should return *auditLogRepository")
+	}
+}
@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type backupRepository struct {
+	db *gorm.DB
+}
+
+func NewBackupRepository(db *gorm.DB) repository.BackupRepository {
+	return &backupRepository{db: db}
+}
+
+// Scan pagina por id (keyset) em vez de OFFSET, que fica lento no fim de
+// tabelas grandes
+func (r *backupRepository) Scan(
+	ctx context.Context,
+	filter repository.Filter,
+	batchSize int,
+	fn func(batch []models.Segmentation) error,
+) error {
+
+	var lastID uint64
+	for {
+		q, err := applyFilter(r.db.WithContext(ctx).Where("id > ?", lastID), filter)
+		if err != nil {
+			return err
+		}
+		var batch []models.Segmentation
+		err = q.Order("id").
+			Limit(batchSize).
+			Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := inflate(batch); err != nil {
+			return err
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+func (r *backupRepository) Restore(
+	ctx context.Context,
+	items []models.Segmentation,
+) error {
+
+	// backups anteriores à política de nomes não trazem name_key
+	for i := range items {
+		items[i].NameKey = items[i].UniqueName()
+	}
+
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{
+				{Name: "user_id"},
+				{Name: "segmentation_type"},
+				{Name: "name_key"},
+			},
+			DoUpdates: clause.AssignmentColumns([]string{"segmentation_name", "data", "data_compressed", "created_at", "updated_at"}),
+		}).
+		Create(&items).Error
+}
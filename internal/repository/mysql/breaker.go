@@ -0,0 +1,238 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/repository"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	breakerName = "circuit_breaker"
+	pingTimeout = 2 * time.Second
+)
+
+// Erros do servidor que indicam que ele não aceita conexões
+var unavailableServerErrors = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR: too many connections
+	1053: true, // ER_SERVER_SHUTDOWN
+}
+
+// Breaker é um plugin do GORM que abre o circuito depois de
+// db.breaker_threshold falhas de conexão seguidas. Com o circuito aberto as
+// queries falham na hora com repository.UnavailableError em vez de esperar
+// o timeout de conexão, e um loop em background pinga o banco com backoff
+// exponencial (db.breaker_cooldown dobrando até db.breaker_max_cooldown),
+// fechando o circuito assim que o banco volta.
+type Breaker struct {
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	logger      *zap.Logger
+	ping        func(ctx context.Context) error
+
+	mu       sync.Mutex
+	open     bool
+	failures int
+	backoff  time.Duration
+	retryAt  time.Time
+}
+
+// NewBreaker cria o circuit breaker; ele passa a valer com db.Use(breaker)
+func NewBreaker(cfg config.DB, logger *zap.Logger) *Breaker {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Breaker{
+		threshold:   cfg.BreakerThreshold,
+		cooldown:    cfg.BreakerCooldown,
+		maxCooldown: cfg.BreakerMaxCooldown,
+		logger:      logger,
+	}
+}
+
+func (b *Breaker) Name() string {
+	return breakerName
+}
+
+// Initialize registra os callbacks em volta de cada operação do GORM
+func (b *Breaker) Initialize(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	b.ping = sqlDB.PingContext
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("breaker:before_create", b.before),
+		cb.Create().After("gorm:create").Register("breaker:after_create", b.after),
+		cb.Query().Before("gorm:query").Register("breaker:before_query", b.before),
+		cb.Query().After("gorm:query").Register("breaker:after_query", b.after),
+		cb.Update().Before("gorm:update").Register("breaker:before_update", b.before),
+		cb.Update().After("gorm:update").Register("breaker:after_update", b.after),
+		cb.Delete().Before("gorm:delete").Register("breaker:before_delete", b.before),
+		cb.Delete().After("gorm:delete").Register("breaker:after_delete", b.after),
+		cb.Row().Before("gorm:row").Register("breaker:before_row", b.before),
+		cb.Row().After("gorm:row").Register("breaker:after_row", b.after),
+		cb.Raw().Before("gorm:raw").Register("breaker:before_raw", b.before),
+		cb.Raw().After("gorm:raw").Register("breaker:after_raw", b.after),
+	)
+}
+
+// Open informa se o circuito está aberto
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+func (b *Breaker) before(db *gorm.DB) {
+	if err := b.allow(); err != nil {
+		db.AddError(err)
+	}
+}
+
+// after registra o resultado e troca falhas de conexão por
+// repository.UnavailableError, para a API responder 503 em vez de 500
+func (b *Breaker) after(db *gorm.DB) {
+	var unavailable *repository.UnavailableError
+	if errors.As(db.Error, &unavailable) {
+		return
+	}
+	b.record(db.Error)
+	if isConnError(db.Error) {
+		db.Error = &repository.UnavailableError{RetryAfter: b.retryAfter(), Err: db.Error}
+	}
+}
+
+// allow recusa a operação enquanto o circuito está aberto
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	return &repository.UnavailableError{RetryAfter: b.untilRetry()}
+}
+
+// record conta as falhas de conexão seguidas; qualquer outro resultado
+// mostra que o banco responde e zera a contagem
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isConnError(err) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.open || b.threshold <= 0 || b.failures < b.threshold {
+		return
+	}
+
+	b.open = true
+	b.backoff = b.cooldown
+	b.retryAt = time.Now().Add(b.backoff)
+	b.logger.Error("db_circuit_opened", zap.Int("failures", b.failures), zap.Error(err))
+	go b.reconnect()
+}
+
+// reconnect pinga o banco com backoff exponencial até ele responder
+func (b *Breaker) reconnect() {
+	for attempt := 1; ; attempt++ {
+		b.mu.Lock()
+		wait := time.Until(b.retryAt)
+		b.mu.Unlock()
+		time.Sleep(wait)
+
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := b.ping(ctx)
+		cancel()
+
+		b.mu.Lock()
+		if err == nil {
+			b.open = false
+			b.failures = 0
+			b.mu.Unlock()
+			b.logger.Info("db_circuit_closed", zap.Int("attempts", attempt))
+			return
+		}
+		b.backoff = min(b.backoff*2, b.maxCooldown)
+		b.retryAt = time.Now().Add(b.backoff)
+		b.mu.Unlock()
+		b.logger.Warn("db_reconnect_failed", zap.Int("attempt", attempt), zap.Error(err))
+	}
+}
+
+// retryAfter é a espera sugerida ao cliente após uma falha de conexão
+func (b *Breaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return b.untilRetry()
+	}
+	return b.cooldown
+}
+
+// untilRetry é o tempo até o próximo ping de reconexão; exige b.mu
+func (b *Breaker) untilRetry() time.Duration {
+	if wait := time.Until(b.retryAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// guardTransaction aplica o breaker de db ao início de uma transação, ou a
+// uma query feita direto no database/sql; nenhuma passa pelos callbacks do
+// GORM
+func guardTransaction(db *gorm.DB, fn func() error) error {
+	b, ok := db.Config.Plugins[breakerName].(*Breaker)
+	if !ok {
+		return fn()
+	}
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	if isConnError(err) {
+		b.record(err)
+		return &repository.UnavailableError{RetryAfter: b.retryAfter(), Err: err}
+	}
+	return err
+}
+
+// isConnError informa se err indica que o banco está inacessível. Erros de
+// contexto não contam: vêm do cliente que desistiu, não do banco.
+func isConnError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysqldriver.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return unavailableServerErrors[mysqlErr.Number]
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/repository"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestIsConnError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "bad conn", err: driver.ErrBadConn, want: true},
+		{name: "invalid conn", err: fmt.Errorf("query: %w", mysqldriver.ErrInvalidConn), want: true},
+		{name: "refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "too many connections", err: &mysqldriver.MySQLError{Number: 1040}, want: true},
+		{name: "duplicate key", err: &mysqldriver.MySQLError{Number: 1062}, want: false},
+		{name: "not found", err: gorm.ErrRecordNotFound, want: false},
+		{name: "cancelled", err: context.Canceled, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnError(tt.err); got != tt.want {
+				t.Errorf("isConnError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestBreaker(ping func(context.Context) error) *Breaker {
+	b := NewBreaker(config.DB{
+		BreakerThreshold:   3,
+		BreakerCooldown:    10 * time.Millisecond,
+		BreakerMaxCooldown: 40 * time.Millisecond,
+	}, nil)
+	b.ping = ping
+	return b
+}
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	var up atomic.Bool
+	var pings atomic.Int32
+	b := newTestBreaker(func(context.Context) error {
+		pings.Add(1)
+		if up.Load() {
+			return nil
+		}
+		return driver.ErrBadConn
+	})
+
+	b.record(driver.ErrBadConn)
+	b.record(driver.ErrBadConn)
+	b.record(errors.New("syntax error")) // the database answered
+	b.record(driver.ErrBadConn)
+	b.record(driver.ErrBadConn)
+	if b.Open() {
+		t.Fatal("the circuit should only open after 3 consecutive connection failures")
+	}
+
+	b.record(driver.ErrBadConn)
+	if !b.Open() {
+		t.Fatal("the circuit should be open")
+	}
+
+	err := b.allow()
+	var unavailable *repository.UnavailableError
+	if !errors.As(err, &unavailable) || !errors.Is(err, repository.ErrUnavailable) {
+		t.Fatalf("allow() = %v, want an UnavailableError", err)
+	}
+	if unavailable.RetryAfter > 10*time.Millisecond {
+		t.Errorf("RetryAfter = %s, want at most the cooldown", unavailable.RetryAfter)
+	}
+
+	// a few failed pings with backoff, then the database comes back
+	time.Sleep(50 * time.Millisecond)
+	up.Store(true)
+
+	deadline := time.Now().Add(time.Second)
+	for b.Open() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if b.Open() {
+		t.Fatal("the circuit should close once the database answers")
+	}
+	if pings.Load() < 2 {
+		t.Errorf("pings = %d, want retries before recovery", pings.Load())
+	}
+	if err := b.allow(); err != nil {
+		t.Errorf("allow() after recovery = %v", err)
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b := NewBreaker(config.DB{BreakerCooldown: time.Second, BreakerMaxCooldown: time.Second}, nil)
+	for i := 0; i < 10; i++ {
+		b.record(driver.ErrBadConn)
+	}
+	if b.Open() {
+		t.Error("a zero threshold should never open the circuit")
+	}
+}
+
+func TestGuardTransaction_WithoutBreaker(t *testing.T) {
+	db := &gorm.DB{Config: &gorm.Config{Plugins: map[string]gorm.Plugin{}}}
+	want := errors.New("boom")
+	if err := guardTransaction(db, func() error { return want }); err != want {
+		t.Errorf("guardTransaction() = %v, want %v", err, want)
+	}
+}
+
+func TestGuardTransaction(t *testing.T) {
+	b := newTestBreaker(func(context.Context) error { return nil })
+	db := &gorm.DB{Config: &gorm.Config{Plugins: map[string]gorm.Plugin{breakerName: b}}}
+
+	err := guardTransaction(db, func() error { return driver.ErrBadConn })
+	if !errors.Is(err, repository.ErrUnavailable) || !errors.Is(err, driver.ErrBadConn) {
+		t.Errorf("guardTransaction() = %v, want an unavailable error wrapping the failure", err)
+	}
+}
@@ -0,0 +1,49 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type changeRepository struct {
+	db *gorm.DB
+}
+
+func NewChangeRepository(db *gorm.DB) repository.ChangeRepository {
+	return &changeRepository{db: db}
+}
+
+// Changes pagina por (updated_at, id) sobre idx_segmentations_updated_at,
+// que no InnoDB já termina com a chave primária. O updated_at >= ? separado
+// deixa o MySQL usar o índice como range, o que o OR sozinho não garante.
+func (r *changeRepository) Changes(
+	ctx context.Context,
+	after repository.ChangeCursor,
+	filter repository.Filter,
+	limit int,
+) ([]models.Segmentation, error) {
+
+	q, err := applyFilter(r.db.WithContext(ctx), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var segs []models.Segmentation
+	err = q.
+		Where("updated_at >= ?", after.UpdatedAt).
+		Where("updated_at > ? OR id > ?", after.UpdatedAt, after.ID).
+		Order("updated_at, id").
+		Limit(limit).
+		Find(&segs).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := inflate(segs); err != nil {
+		return nil, err
+	}
+	return segs, nil
+}
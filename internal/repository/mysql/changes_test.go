@@ -0,0 +1,11 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestChangeRepositoryInterface(t *testing.T) {
+	var _ repository.ChangeRepository = (*changeRepository)(nil)
+}
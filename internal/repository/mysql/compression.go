@@ -0,0 +1,108 @@
+package mysql
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/models"
+)
+
+// WithCompression grava comprimido o data com threshold bytes ou mais: a
+// coluna data fica NULL e o conteúdo vai para data_compressed. O formato é
+// o de COMPRESS() do MySQL (tamanho original em 4 bytes little-endian e o
+// stream zlib), então UNCOMPRESS(data_compressed) lê a coluna em SQL e o
+// down da migration consegue devolver os dados a data. Um data que não
+// diminui comprimido é gravado como está. A leitura descomprime sempre,
+// com ou sem a opção, para que desligá-la não esconda linhas já gravadas.
+func WithCompression(threshold int) Option {
+	return func(r *segmentationRepository) {
+		r.compressAbove = threshold
+	}
+}
+
+var zlibWriters = sync.Pool{
+	New: func() any { return zlib.NewWriter(nil) },
+}
+
+// storedData devolve os valores das colunas data e data_compressed
+func (r *segmentationRepository) storedData(data datatypes.JSON) (any, any, error) {
+	if r.compressAbove <= 0 || len(data) < r.compressAbove {
+		return data, nil, nil
+	}
+	compressed, err := compressData(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(compressed) >= len(data) {
+		return data, nil, nil
+	}
+	return nil, compressed, nil
+}
+
+// compressData comprime no formato de COMPRESS()
+func compressData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(4 + len(data)/2)
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(data)))
+	buf.Write(size[:])
+
+	zw := zlibWriters.Get().(*zlib.Writer)
+	defer zlibWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// uncompressData é o inverso de compressData, como UNCOMPRESS()
+func uncompressData(compressed []byte) ([]byte, error) {
+	if len(compressed) == 0 {
+		return nil, nil
+	}
+	if len(compressed) < 4 {
+		return nil, fmt.Errorf("compressed data too short: %d bytes", len(compressed))
+	}
+	size := binary.LittleEndian.Uint32(compressed[:4])
+	zr, err := zlib.NewReader(bytes.NewReader(compressed[4:]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data := make([]byte, 0, size)
+	buf := bytes.NewBuffer(data)
+	if _, err := io.Copy(buf, zr); err != nil {
+		return nil, err
+	}
+	if buf.Len() != int(size) {
+		return nil, fmt.Errorf("compressed data has %d bytes, header says %d", buf.Len(), size)
+	}
+	return buf.Bytes(), nil
+}
+
+// inflate descomprime data_compressed em Data nas segmentações lidas
+func inflate(segs []models.Segmentation) error {
+	for i := range segs {
+		s := &segs[i]
+		if s.DataCompressed == nil {
+			continue
+		}
+		data, err := uncompressData(s.DataCompressed)
+		if err != nil {
+			return fmt.Errorf("segmentation %d: %w", s.ID, err)
+		}
+		s.Data, s.DataCompressed = data, nil
+	}
+	return nil
+}
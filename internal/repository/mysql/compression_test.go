@@ -0,0 +1,108 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/models"
+)
+
+func TestCompressData_RoundTrip(t *testing.T) {
+	data := []byte(`{"items":"` + strings.Repeat("abc", 1000) + `"}`)
+	compressed, err := compressData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("compressed %d bytes into %d", len(data), len(compressed))
+	}
+	got, err := uncompressData(compressed)
+	if err != nil {
+		t.Fatalf("uncompressData() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("round trip changed the data")
+	}
+}
+
+// the column holds the format of MySQL's COMPRESS(), so UNCOMPRESS() reads
+// it in SQL (and in the down migration)
+func TestUncompressData_MySQLFormat(t *testing.T) {
+	// COMPRESS('{"count":42}')
+	compressed, _ := hex.DecodeString("0c000000789cab564ace2fcd2b51b23231aa05001b7c0406")
+	got, err := uncompressData(compressed)
+	if err != nil {
+		t.Fatalf("uncompressData() error = %v", err)
+	}
+	if string(got) != `{"count":42}` {
+		t.Errorf("uncompressData() = %q", got)
+	}
+
+	ours, err := compressData(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ours[:4], compressed[:4]) {
+		t.Errorf("length header = %x, want %x", ours[:4], compressed[:4])
+	}
+
+	if _, err := uncompressData(compressed[:3]); err == nil {
+		t.Error("uncompressData() should reject a truncated header")
+	}
+	if _, err := uncompressData(append([]byte{0xff, 0, 0, 0}, compressed[4:]...)); err == nil {
+		t.Error("uncompressData() should reject a wrong length")
+	}
+}
+
+func TestStoredData(t *testing.T) {
+	large := datatypes.JSON(`{"items":"` + strings.Repeat("x", 2000) + `"}`)
+	small := datatypes.JSON(`{"a":1}`)
+
+	off := &segmentationRepository{}
+	if data, compressed, _ := off.storedData(large); compressed != nil || data == nil {
+		t.Error("without compression data should be stored as is")
+	}
+
+	on := &segmentationRepository{compressAbove: 1024}
+	if data, compressed, _ := on.storedData(small); compressed != nil || data == nil {
+		t.Error("data under the threshold should be stored as is")
+	}
+	data, compressed, err := on.storedData(large)
+	if err != nil || data != nil || compressed == nil {
+		t.Fatalf("data over the threshold should be compressed: data = %v, compressed = %v, err = %v", data, compressed != nil, err)
+	}
+
+}
+
+func TestInflate(t *testing.T) {
+	compressed, err := compressData([]byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	segs := []models.Segmentation{
+		{ID: 1, Data: datatypes.JSON(`{"b":2}`)},
+		{ID: 2, DataCompressed: compressed},
+	}
+	if err := inflate(segs); err != nil {
+		t.Fatalf("inflate() error = %v", err)
+	}
+	if string(segs[0].Data) != `{"b":2}` || string(segs[1].Data) != `{"a":1}` || segs[1].DataCompressed != nil {
+		t.Errorf("unexpected segmentations after inflate: %+v", segs)
+	}
+
+	bad := []models.Segmentation{{ID: 3, DataCompressed: []byte("garbage")}}
+	if err := inflate(bad); err == nil || !strings.Contains(err.Error(), "segmentation 3") {
+		t.Errorf("inflate() error = %v, want one naming the segmentation", err)
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	repo := NewSegmentationRepository(nil, WithCompression(4096)).(*segmentationRepository)
+	if repo.compressAbove != 4096 {
+		t.Errorf("compressAbove = %d, want 4096", repo.compressAbove)
+	}
+}
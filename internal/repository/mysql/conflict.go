@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"errors"
+	"regexp"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/repository"
+)
+
+const (
+	conflictsName = "conflict_errors"
+
+	// erDupEntry é o ER_DUP_ENTRY do MySQL
+	erDupEntry = 1062
+)
+
+// uniqueKeys traduz os índices únicos para as colunas da chave como a API
+// as chama; PRIMARY fica de fora por ser ambíguo entre as tabelas
+var uniqueKeys = map[string][]string{
+	"uniq_user_seg":               {"user_id", "segmentation_type", "name_key"},
+	"idx_segmentation_types_name": {"name"},
+}
+
+// A partir do MySQL 8.0.19 o nome do índice vem prefixado pela tabela
+var dupEntryPattern = regexp.MustCompile(`^Duplicate entry '(.*)' for key '(?:[^'.]+\.)?([^'.]+)'$`)
+
+// conflictErrors é um plugin do GORM que troca o ER_DUP_ENTRY das escritas
+// por repository.ConflictError, para a API responder 409 em vez de 500
+type conflictErrors struct{}
+
+func (conflictErrors) Name() string {
+	return conflictsName
+}
+
+func (conflictErrors) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().After("gorm:create").Register("conflicts:after_create", translateConflict),
+		cb.Update().After("gorm:update").Register("conflicts:after_update", translateConflict),
+		cb.Raw().After("gorm:raw").Register("conflicts:after_raw", translateConflict),
+	)
+}
+
+func translateConflict(db *gorm.DB) {
+	if err := conflictError(db.Error); err != nil {
+		db.Error = err
+	}
+}
+
+// conflictError converte um ER_DUP_ENTRY em repository.ConflictError; nil
+// para qualquer outro erro
+func conflictError(err error) error {
+	var mysqlErr *mysqldriver.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != erDupEntry {
+		return nil
+	}
+	conflict := &repository.ConflictError{Err: err}
+	if m := dupEntryPattern.FindStringSubmatch(mysqlErr.Message); m != nil {
+		if key, ok := uniqueKeys[m[2]]; ok {
+			conflict.Key, conflict.Value = key, m[1]
+		}
+	}
+	return conflict
+}
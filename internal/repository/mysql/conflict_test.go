@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestConflictError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantKey   []string
+		wantValue string
+		wantMsg   string
+	}{
+		{
+			name:      "MySQL 8 key name",
+			err:       &mysqldriver.MySQLError{Number: erDupEntry, Message: "Duplicate entry '42-drug-Anti-inflamatórios' for key 'segmentations.uniq_user_seg'"},
+			wantKey:   []string{"user_id", "segmentation_type", "name_key"},
+			wantValue: "42-drug-Anti-inflamatórios",
+			wantMsg:   "conflicting write on (user_id, segmentation_type, name_key)",
+		},
+		{
+			name:      "wrapped, older key name",
+			err:       fmt.Errorf("create: %w", &mysqldriver.MySQLError{Number: erDupEntry, Message: "Duplicate entry 'drug' for key 'idx_segmentation_types_name'"}),
+			wantKey:   []string{"name"},
+			wantValue: "drug",
+			wantMsg:   "conflicting write on (name)",
+		},
+		{
+			name:    "unknown key",
+			err:     &mysqldriver.MySQLError{Number: erDupEntry, Message: "Duplicate entry '7' for key 'segmentations.PRIMARY'"},
+			wantMsg: "conflicting write",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := conflictError(tt.err)
+			var conflict *repository.ConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("conflictError() = %v, want a ConflictError", err)
+			}
+			if !reflect.DeepEqual(conflict.Key, tt.wantKey) || conflict.Value != tt.wantValue {
+				t.Errorf("key %v value %q, want %v %q", conflict.Key, conflict.Value, tt.wantKey, tt.wantValue)
+			}
+			if err.Error() != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.wantMsg)
+			}
+			var mysqlErr *mysqldriver.MySQLError
+			if !errors.Is(err, repository.ErrConflict) || !errors.As(err, &mysqlErr) {
+				t.Error("ConflictError should unwrap to ErrConflict and the driver error")
+			}
+		})
+	}
+
+	for _, err := range []error{nil, errors.New("boom"), &mysqldriver.MySQLError{Number: 1213, Message: "Deadlock found"}} {
+		if got := conflictError(err); got != nil {
+			t.Errorf("conflictError(%v) = %v, want nil", err, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type deadLetterRepository struct {
+	db *gorm.DB
+}
+
+func NewDeadLetterRepository(db *gorm.DB) repository.DeadLetterRepository {
+	return &deadLetterRepository{db: db}
+}
+
+func (r *deadLetterRepository) Insert(
+	ctx context.Context,
+	entries []models.DeadLetter,
+) error {
+
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(entries, 500).Error
+}
+
+// List pagina pelo índice de run_id, que no InnoDB já termina com a chave
+// primária
+func (r *deadLetterRepository) List(
+	ctx context.Context,
+	runID string,
+	afterID uint64,
+	limit int,
+) ([]models.DeadLetter, error) {
+
+	var entries []models.DeadLetter
+	err := r.db.WithContext(ctx).
+		Where("run_id = ? AND id > ?", runID, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestDeadLetterRepositoryInterface(t *testing.T) {
+	var _ repository.DeadLetterRepository = (*deadLetterRepository)(nil)
+}
+
+func TestNewDeadLetterRepository(t *testing.T) {
+	repo := NewDeadLetterRepository(nil)
+	if repo == nil {
+		t.Fatal("NewDeadLetterRepository should not return nil")
+	}
+
+	if _, ok := repo.(*deadLetterRepository); !ok {
+		t.Error("NewDeadLetterRepository should return *deadLetterRepository")
+	}
+}
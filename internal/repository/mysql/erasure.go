@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type erasureRepository struct {
+	db *gorm.DB
+	// outbox grava os eventos de remoção, como WithOutbox no repositório de
+	// segmentações
+	outbox bool
+}
+
+// NewErasureRepository aceita as opções do repositório de segmentações;
+// só WithOutbox muda o comportamento
+func NewErasureRepository(db *gorm.DB, opts ...Option) repository.ErasureRepository {
+	segs := &segmentationRepository{db: db}
+	for _, opt := range opts {
+		opt(segs)
+	}
+	return &erasureRepository{db: db, outbox: segs.outbox}
+}
+
+func (r *erasureRepository) Erase(
+	ctx context.Context,
+	erasure *models.Erasure,
+) ([]models.Segmentation, error) {
+
+	var rows []models.Segmentation
+	err := guardTransaction(r.db, func() error {
+		return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// travar o último registro serializa as eliminações, para que
+			// cada uma encadeie no hash da anterior
+			var last models.Erasure
+			err := tx.Select("id", "hash").
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Order("id DESC").
+				Limit(1).
+				Find(&last).Error
+			if err != nil {
+				return err
+			}
+
+			rows = nil
+			err = tx.Select("id", "user_id", "segmentation_type", "segmentation_name", "name_key").
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ?", erasure.UserID).
+				Order("id").
+				Find(&rows).Error
+			if err != nil {
+				return err
+			}
+
+			deleted := tx.Where("user_id = ?", erasure.UserID).Delete(&models.Segmentation{})
+			if deleted.Error != nil {
+				return deleted.Error
+			}
+			erasure.Segmentations = deleted.RowsAffected
+
+			// as entregas guardam o payload dos eventos, com os dados
+			deliveries := tx.Exec(`
+			DELETE FROM webhook_deliveries
+			WHERE event_id IN (SELECT id FROM segmentation_outbox WHERE user_id = ?)
+			`, erasure.UserID)
+			if deliveries.Error != nil {
+				return deliveries.Error
+			}
+			erasure.Deliveries = deliveries.RowsAffected
+
+			events := tx.Where("user_id = ?", erasure.UserID).Delete(&models.OutboxEvent{})
+			if events.Error != nil {
+				return events.Error
+			}
+			erasure.Events = events.RowsAffected
+
+			// as linhas rejeitadas pelo processor guardam a linha CSV crua
+			deadLetters := tx.Where("user_id = ?", erasure.UserID).Delete(&models.DeadLetter{})
+			if deadLetters.Error != nil {
+				return deadLetters.Error
+			}
+			erasure.DeadLetters = deadLetters.RowsAffected
+
+			// e as respostas guardadas pelo Idempotency-Key, as segmentações
+			keys := tx.Where("user_id = ?", erasure.UserID).Delete(&models.IdempotencyKey{})
+			if keys.Error != nil {
+				return keys.Error
+			}
+			erasure.IdempotencyKeys = keys.RowsAffected
+
+			// os assinantes recebem a remoção de cada segmentação apagada,
+			// sem os dados
+			if r.outbox && len(rows) > 0 {
+				if err := tx.CreateInBatches(deleteEvents(rows, time.Now().Unix()), 500).Error; err != nil {
+					return err
+				}
+			}
+
+			erasure.PrevHash = last.Hash
+			erasure.Hash = erasure.ComputeHash()
+			return tx.Create(erasure).Error
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *erasureRepository) List(
+	ctx context.Context,
+	filter repository.ErasureFilter,
+) ([]models.Erasure, error) {
+
+	q := r.db.WithContext(ctx).Model(&models.Erasure{})
+
+	if filter.UserID > 0 {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	if filter.AfterID > 0 {
+		q = q.Where("id > ?", filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	var erasures []models.Erasure
+	err := q.Order("id").Find(&erasures).Error
+	return erasures, err
+}
@@ -0,0 +1,24 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestErasureRepositoryInterface(t *testing.T) {
+	var _ repository.ErasureRepository = (*erasureRepository)(nil)
+}
+
+func TestNewErasureRepository(t *testing.T) {
+	repo, ok := NewErasureRepository(nil, WithOutbox()).(*erasureRepository)
+	if !ok {
+		t.Fatal("NewErasureRepository should return *erasureRepository")
+	}
+	if !repo.outbox {
+		t.Error("WithOutbox should write the deletion events")
+	}
+	if NewErasureRepository(nil).(*erasureRepository).outbox {
+		t.Error("the deletion events should only be written with WithOutbox")
+	}
+}
@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/repository"
+)
+
+// filterColumns mapeia os campos filtráveis para as colunas; só estes nomes
+// e os fragmentos de filterOperators entram no texto do SQL
+var filterColumns = map[string]string{
+	"user_id":           "user_id",
+	"segmentation_type": "segmentation_type",
+	"segmentation_name": "segmentation_name",
+	"created_at":        "created_at",
+	"updated_at":        "updated_at",
+}
+
+var filterOperators = map[repository.FilterOp]string{
+	repository.FilterEq:     "= ?",
+	repository.FilterNe:     "<> ?",
+	repository.FilterLt:     "< ?",
+	repository.FilterLte:    "<= ?",
+	repository.FilterGt:     "> ?",
+	repository.FilterGte:    ">= ?",
+	repository.FilterIn:     "IN ?",
+	repository.FilterPrefix: "LIKE ? ESCAPE '!'",
+}
+
+// filterDataExpr lê uma chave de data como texto, também nas linhas
+// comprimidas; o caminho JSON vai como parâmetro. Uma linha sem a chave dá
+// NULL e não passa em nenhuma condição, nem em ne.
+const filterDataExpr = "JSON_UNQUOTE(JSON_EXTRACT(COALESCE(data, CONVERT(UNCOMPRESS(data_compressed) USING utf8mb4)), ?))"
+
+// applyFilter acrescenta as condições de f a q. Campos, operadores e
+// caminhos são validados de novo aqui e todo valor vai como parâmetro, então
+// nada do que o cliente mandou é concatenado ao SQL.
+func applyFilter(q *gorm.DB, f repository.Filter) (*gorm.DB, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	for _, c := range f {
+		op, ok := filterOperators[c.Op]
+		if !ok {
+			return nil, fmt.Errorf("unsupported filter operator %q", c.Op)
+		}
+		if path := c.DataPath(); path != nil {
+			q = q.Where(filterDataExpr+" "+op, jsonPath(path), filterValue(c, false))
+			continue
+		}
+		column, ok := filterColumns[c.Field]
+		if !ok {
+			return nil, fmt.Errorf("field %q cannot be filtered", c.Field)
+		}
+		numeric := column == "user_id" || column == "created_at" || column == "updated_at"
+		q = q.Where(column+" "+op, filterValue(c, numeric))
+	}
+	return q, nil
+}
+
+// jsonPath monta o caminho $."a"."b"; as chaves já foram validadas, as
+// aspas só evitam que uma chave numérica vire índice de array
+func jsonPath(keys []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, k := range keys {
+		b.WriteString(".")
+		b.WriteString(strconv.Quote(k))
+	}
+	return b.String()
+}
+
+// filterValue devolve o argumento da condição: a lista de in, o padrão
+// escapado de prefix ou o valor, como inteiro nas colunas numéricas
+func filterValue(c repository.Condition, numeric bool) any {
+	values := make([]any, len(c.Values))
+	for i, v := range c.Values {
+		values[i] = v
+		if numeric {
+			// conferido por Validate
+			n, _ := strconv.ParseInt(v, 10, 64)
+			values[i] = n
+		}
+	}
+	switch c.Op {
+	case repository.FilterIn:
+		return values
+	case repository.FilterPrefix:
+		return likeEscaper.Replace(c.Values[0]) + "%"
+	default:
+		return values[0]
+	}
+}
+
+// likeEscaper escapa os curingas do LIKE com o ESCAPE '!' de
+// filterOperators
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
@@ -0,0 +1,66 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// dryRun is a gorm.DB that builds statements without a server
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/db", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestApplyFilter_BindsEveryValue(t *testing.T) {
+	hostile := `x' OR '1'='1`
+	filter := repository.Filter{
+		{Field: "segmentation_type", Op: repository.FilterEq, Values: []string{hostile}},
+		{Field: "user_id", Op: repository.FilterIn, Values: []string{"1", "2"}},
+		{Field: "segmentation_name", Op: repository.FilterPrefix, Values: []string{"50%_off!"}},
+		{Field: "data.posologia.unidade", Op: repository.FilterNe, Values: []string{hostile}},
+	}
+
+	q, err := applyFilter(dryRun(t), filter)
+	if err != nil {
+		t.Fatalf("applyFilter() error = %v", err)
+	}
+	var segs []models.Segmentation
+	stmt := q.Find(&segs).Statement
+	sql := stmt.SQL.String()
+
+	if strings.Contains(sql, hostile) || strings.Contains(sql, "posologia") || strings.Contains(sql, "50%") {
+		t.Errorf("values leaked into the SQL: %s", sql)
+	}
+	for _, want := range []string{"segmentation_type = ?", "user_id IN (?,?)", "segmentation_name LIKE ? ESCAPE '!'", "JSON_EXTRACT("} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL %s does not contain %q", sql, want)
+		}
+	}
+
+	want := []any{hostile, int64(1), int64(2), "50!%!_off!!%", `$."posologia"."unidade"`, hostile}
+	if len(stmt.Vars) != len(want) {
+		t.Fatalf("vars = %#v, want %#v", stmt.Vars, want)
+	}
+	for i := range want {
+		if stmt.Vars[i] != want[i] {
+			t.Errorf("var %d = %#v, want %#v", i, stmt.Vars[i], want[i])
+		}
+	}
+}
+
+func TestApplyFilter_RejectsUnknownFields(t *testing.T) {
+	filter := repository.Filter{{Field: "1=1; DROP TABLE segmentations", Op: repository.FilterEq, Values: []string{"x"}}}
+	if _, err := applyFilter(dryRun(t), filter); err == nil {
+		t.Fatal("applyFilter() should refuse a field outside the whitelist")
+	}
+}
@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) repository.IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+func (r *idempotencyRepository) Find(
+	ctx context.Context,
+	key string,
+	now int64,
+) (*models.IdempotencyKey, error) {
+
+	var rows []models.IdempotencyKey
+
+	// Find com slice evita o log de "record not found" do First
+	err := r.db.WithContext(ctx).
+		Where("idempotency_key = ? AND expires_at > ?", key, now).
+		Limit(1).
+		Find(&rows).Error
+
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+func (r *idempotencyRepository) Save(
+	ctx context.Context,
+	k *models.IdempotencyKey,
+) error {
+
+	// sobrescreve entradas expiradas que ainda não foram limpas
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(k).Error
+}
+
+func (r *idempotencyRepository) DeleteExpired(
+	ctx context.Context,
+	now int64,
+) (int64, error) {
+
+	tx := r.db.WithContext(ctx).
+		Where("expires_at <= ?", now).
+		Delete(&models.IdempotencyKey{})
+
+	return tx.RowsAffected, tx.Error
+}
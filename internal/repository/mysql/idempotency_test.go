@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestIdempotencyRepositoryInterface(t *testing.T) {
+	var _ repository.IdempotencyRepository = (*idempotencyRepository)(nil)
+}
+
+func TestNewIdempotencyRepository(t *testing.T) {
+	repo := NewIdempotencyRepository(nil)
+	if repo == nil {
+		t.Fatal("NewIdempotencyRepository should not return nil")
+	}
+
+	if _, ok := repo.(*idempotencyRepository); !ok {
+		t.Error("NewIdempotencyRepository should return *idempotencyRepository")
+	}
+}
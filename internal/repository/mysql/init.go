@@ -1,49 +1,79 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"os"
+	"net"
 	"time"
 
+	"segmentation-api/internal/config"
+
+	driver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func NewMySQL(gormLogger logger.Interface) (*gorm.DB, error) {
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	name := os.Getenv("DB_NAME")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASSWORD")
+// CredentialsFunc retorna o usuário e a senha de uma nova conexão; é
+// chamada a cada conexão aberta pelo pool
+type CredentialsFunc func(ctx context.Context) (user, password string, err error)
+
+// ConnOption customiza a conexão com o MySQL
+type ConnOption func(*driver.Config) error
 
-	if host == "" || name == "" || user == "" {
-		return nil, fmt.Errorf("database env vars not set")
+// WithCredentials busca as credenciais a cada nova conexão em vez de usar
+// db.user e db.password, para que credenciais renovadas (ex.: Vault)
+// sejam usadas sem reiniciar o processo
+func WithCredentials(fn CredentialsFunc) ConnOption {
+	return func(cfg *driver.Config) error {
+		return cfg.Apply(driver.BeforeConnect(func(ctx context.Context, c *driver.Config) error {
+			user, password, err := fn(ctx)
+			if err != nil {
+				return fmt.Errorf("database credentials: %w", err)
+			}
+			c.User, c.Passwd = user, password
+			return nil
+		}))
 	}
+}
 
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4&loc=Local",
-		user,
-		pass,
-		host,
-		port,
-		name,
-	)
+// driverConfig monta a configuração do driver a partir de cfg
+func driverConfig(cfg config.DB, opts ...ConnOption) (*driver.Config, error) {
+	dc := driver.NewConfig()
+	dc.User = cfg.User
+	dc.Passwd = cfg.Password
+	dc.Net = "tcp"
+	dc.Addr = net.JoinHostPort(cfg.Host, cfg.Port)
+	dc.DBName = cfg.Name
+	dc.ParseTime = true
+	dc.Loc = time.Local
+	dc.Params = map[string]string{"charset": "utf8mb4"}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger:      gormLogger,
-		PrepareStmt: true,
-	})
+	for _, opt := range opts {
+		if err := opt(dc); err != nil {
+			return nil, err
+		}
+	}
+	return dc, nil
+}
 
+func NewMySQL(cfg config.DB, gormLogger logger.Interface, opts ...ConnOption) (*gorm.DB, error) {
+	if cfg.Host == "" || cfg.Name == "" {
+		return nil, fmt.Errorf("database config not set")
+	}
+
+	dc, err := driverConfig(cfg, opts...)
 	if err != nil {
 		return nil, err
 	}
-
-	sqlDB, err := db.DB()
+	connector, err := driver.NewConnector(dc)
 	if err != nil {
 		return nil, err
 	}
 
+	sqlDB := sql.OpenDB(connector)
+
 	// sqlDB.SetMaxOpenConns(50)
 	// sqlDB.SetMaxIdleConns(10)
 	// sqlDB.SetConnMaxLifetime(time.Hour)
@@ -58,14 +88,69 @@ func NewMySQL(gormLogger logger.Interface) (*gorm.DB, error) {
 	// sqlDB.SetMaxIdleConns(32)
 	// sqlDB.SetConnMaxLifetime(60 * time.Minute)
 
-	sqlDB.SetMaxOpenConns(32)
-	sqlDB.SetMaxIdleConns(32)
-	sqlDB.SetConnMaxLifetime(30 * time.Second)
+	// o pool vem do perfil de APP_ENV; zero mantém o padrão de 32 conexões
+	maxOpen, maxIdle, lifetime := cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime
+	if maxOpen <= 0 {
+		maxOpen, maxIdle = 32, 32
+	}
+	if lifetime <= 0 {
+		lifetime = 30 * time.Second
+	}
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(lifetime)
 
 	// 👇 garante DB disponível antes de subir worker
-	if err := sqlDB.Ping(); err != nil {
+	if err := waitForDB(sqlDB, cfg.ConnectTimeout); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:      sqlDB,
+		DSNConfig: dc,
+	}), &gorm.Config{
+		Logger:      gormLogger,
+		PrepareStmt: true,
+	})
+
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	// chave única duplicada vira repository.ConflictError
+	if err := db.Use(conflictErrors{}); err != nil {
+		sqlDB.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// Backoff das tentativas de conexão na subida
+const (
+	connectInitialBackoff = 250 * time.Millisecond
+	connectMaxBackoff     = 5 * time.Second
+)
+
+// waitForDB pinga o banco com backoff exponencial até ele responder ou
+// timeout passar, para o processo subir junto com um MySQL que ainda está
+// iniciando. Com timeout zero é feita uma única tentativa.
+func waitForDB(sqlDB *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := connectInitialBackoff
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := sqlDB.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("database not reachable after %s: %w", timeout, err)
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, connectMaxBackoff)
+	}
+}
@@ -0,0 +1,30 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+)
+
+func TestDriverConfig(t *testing.T) {
+	dc, err := driverConfig(config.DB{Host: "mysql", Port: "3306", Name: "segmentation", User: "app", Password: "p@ss:w/rd"})
+	if err != nil {
+		t.Fatalf("driverConfig() error = %v", err)
+	}
+
+	if dc.Addr != "mysql:3306" || dc.DBName != "segmentation" || dc.User != "app" || dc.Passwd != "p@ss:w/rd" {
+		t.Errorf("unexpected config: %+v", dc)
+	}
+	if !dc.ParseTime || dc.Loc != time.Local || dc.Params["charset"] != "utf8mb4" {
+		t.Errorf("unexpected options: %+v", dc)
+	}
+}
+
+func TestWithCredentials(t *testing.T) {
+	creds := func(context.Context) (string, string, error) { return "vault-user", "vault-pass", nil }
+	if _, err := driverConfig(config.DB{Host: "mysql", Port: "3306", Name: "segmentation"}, WithCredentials(creds)); err != nil {
+		t.Fatalf("driverConfig() error = %v", err)
+	}
+}
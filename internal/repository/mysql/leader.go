@@ -0,0 +1,147 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotLeader indica que outra instância mantém o lock de líder
+var ErrNotLeader = errors.New("another instance holds the leader lock")
+
+// leaderCheckInterval é o intervalo entre as verificações de que o lock
+// continua com esta instância
+const leaderCheckInterval = 5 * time.Second
+
+// LeaderLockName deriva o nome do lock do processor a partir do arquivo
+// importado, para que só instâncias do mesmo arquivo concorram. O MySQL
+// limita o nome a 64 caracteres, daí o hash.
+func LeaderLockName(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return "segmentation_processor:" + hex.EncodeToString(sum[:8])
+}
+
+// LeaderLock elege uma única instância por nome com um advisory lock do
+// MySQL (GET_LOCK). O lock pertence à sessão: ele fica preso a uma conexão
+// dedicada e é liberado pelo MySQL se a conexão ou a instância cair.
+type LeaderLock struct {
+	db   *gorm.DB
+	name string
+}
+
+// NewLeaderLock cria o lock de nome name sobre db
+func NewLeaderLock(db *gorm.DB, name string) *LeaderLock {
+	return &LeaderLock{db: db, name: name}
+}
+
+// Leadership é o lock obtido por Acquire, mantido até Release
+type Leadership struct {
+	conn *sql.Conn
+	name string
+	lost chan struct{}
+	stop chan struct{}
+	once sync.Once
+	err  error
+}
+
+// Acquire tenta obter o lock esperando até wait (arredondado para cima em
+// segundos; 0 não espera) e devolve ErrNotLeader quando outra instância o
+// mantém
+func (l *LeaderLock) Acquire(ctx context.Context, wait time.Duration) (*Leadership, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var got sql.NullInt64
+	timeout := int(math.Ceil(wait.Seconds()))
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.name, timeout).Scan(&got); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if got.Int64 != 1 {
+		conn.Close()
+		return nil, ErrNotLeader
+	}
+
+	lead := &Leadership{
+		conn: conn,
+		name: l.name,
+		lost: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+	go lead.watch(leaderCheckInterval)
+	return lead, nil
+}
+
+// Lost é fechado quando a instância deixa de ter o lock, por exemplo
+// quando a conexão com o banco cai; Err diz o motivo
+func (l *Leadership) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err é o motivo da perda do lock, depois que Lost foi fechado
+func (l *Leadership) Err() error {
+	select {
+	case <-l.lost:
+		return l.err
+	default:
+		return nil
+	}
+}
+
+// Release libera o lock e a conexão
+func (l *Leadership) Release() error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.name)
+		if cerr := l.conn.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+// watch confere periodicamente que a sessão ainda é a dona do lock
+func (l *Leadership) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			var owner sql.NullBool
+			err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", l.name).Scan(&owner)
+			cancel()
+			if err == nil && !owner.Bool {
+				err = errors.New("leader lock was released")
+			}
+			if err != nil {
+				select {
+				case <-l.stop:
+					return
+				default:
+				}
+				l.err = err
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
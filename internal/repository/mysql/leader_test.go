@@ -0,0 +1,19 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLeaderLockName(t *testing.T) {
+	a := LeaderLockName("/app/data/data.csv")
+	if a != LeaderLockName("/app/data/data.csv") {
+		t.Error("LeaderLockName should be deterministic")
+	}
+	if a == LeaderLockName("/app/data/other.csv") {
+		t.Error("different files should get different locks")
+	}
+	if !strings.HasPrefix(a, "segmentation_processor:") || len(a) > 64 {
+		t.Errorf("LeaderLockName() = %q, want a prefixed name of at most 64 characters", a)
+	}
+}
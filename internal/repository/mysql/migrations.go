@@ -1,13 +1,418 @@
 package mysql
 
 import (
-	"segmentation-api/internal/models"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-func RunMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.Segmentation{},
-	)
+// MigrationsDir é onde `migrate create` grava as migrations, relativo à
+// raiz do repositório; os arquivos são embutidos no binário
+const MigrationsDir = "internal/repository/mysql/migrations"
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// Migrations são as migrations embutidas no binário
+var Migrations = mustSub(embeddedMigrations, "migrations")
+
+const (
+	migrationsTable = "schema_migrations"
+	// migrationsLock evita que dois processos apliquem migrations ao mesmo tempo
+	migrationsLock        = "segmentation_schema_migrations"
+	migrationsLockTimeout = 60
+)
+
+var (
+	// nome dos arquivos: <versão>_<nome>.up.sql e <versão>_<nome>.down.sql
+	migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nameSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Migration é uma versão do schema. AppliedAt é zero enquanto pendente.
+type Migration struct {
+	Version   int64
+	Name      string
+	Up        string
+	Down      string
+	AppliedAt int64
+}
+
+// Applied informa se a migration já foi aplicada
+func (m Migration) Applied() bool {
+	return m.AppliedAt != 0
+}
+
+// appliedMigration é uma linha de schema_migrations
+type appliedMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255;not null"`
+	AppliedAt int64  `gorm:"not null"`
+}
+
+func (appliedMigration) TableName() string {
+	return migrationsTable
+}
+
+// LoadMigrations lê as migrations de fsys ordenadas por versão; toda
+// versão precisa dos arquivos up e down
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.up.sql or .down.sql", e.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d: names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s: up and down files are required and must not be empty", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator aplica e reverte as migrations versionadas, registrando as
+// aplicadas em schema_migrations
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	onlineDDL  bool
+}
+
+// MigratorOption customiza o Migrator
+type MigratorOption func(*Migrator)
+
+// OnlineDDLMarker marca, no fim de um ALTER TABLE de uma migration, onde
+// entra o algoritmo online. Por ser um comentário, o arquivo continua
+// válido quando executado à mão.
+const OnlineDDLMarker = "/*online_ddl*/"
+
+// WithOnlineDDL troca OnlineDDLMarker por ALGORITHM=INPLACE, LOCK=NONE: o
+// MySQL altera a tabela sem bloquear as escritas ou recusa a instrução,
+// em vez de cair para uma cópia com a tabela travada. Sem a opção o
+// marcador é só um comentário e o MySQL escolhe o algoritmo.
+func WithOnlineDDL() MigratorOption {
+	return func(m *Migrator) {
+		m.onlineDDL = true
+	}
+}
+
+// NewMigrator usa as migrations de fsys (normalmente Migrations)
+func NewMigrator(db *gorm.DB, fsys fs.FS, opts ...MigratorOption) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: db, migrations: migrations}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Status retorna todas as migrations, aplicadas e pendentes, por versão.
+// Versões aplicadas que não existem mais nos arquivos também aparecem,
+// sem Up e Down.
+func (m *Migrator) Status(ctx context.Context) ([]Migration, error) {
+	return m.status(m.db.WithContext(ctx))
+}
+
+// Pending retorna as versões ainda não aplicadas
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	all, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, mig := range all {
+		if !mig.Applied() {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Version retorna a maior versão aplicada (0 quando nenhuma foi) e
+// quantas migrations do binário ainda estão pendentes
+func (m *Migrator) Version(ctx context.Context) (version int64, pending int, err error) {
+	all, err := m.Status(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, mig := range all {
+		if mig.Applied() {
+			version = max(version, mig.Version)
+		} else {
+			pending++
+		}
+	}
+	return version, pending, nil
+}
+
+// Up aplica as migrations pendentes em ordem e retorna as aplicadas
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		all, err := m.status(conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range all {
+			if mig.Applied() {
+				continue
+			}
+			if err := execScript(conn, mig.Up, m.onlineDDL); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			mig.AppliedAt = time.Now().Unix()
+			row := appliedMigration{Version: mig.Version, Name: mig.Name, AppliedAt: mig.AppliedAt}
+			if err := conn.Create(&row).Error; err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverte as últimas steps migrations aplicadas, da mais nova para a
+// mais antiga, e retorna as revertidas
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		all, err := m.status(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(all) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := all[i]
+			if !mig.Applied() {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s is applied but its files are missing", mig.Version, mig.Name)
+			}
+			if err := execScript(conn, mig.Down, m.onlineDDL); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			if err := conn.Delete(&appliedMigration{}, mig.Version).Error; err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func (m *Migrator) status(db *gorm.DB) ([]Migration, error) {
+	all := make([]Migration, len(m.migrations))
+	copy(all, m.migrations)
+	if !db.Migrator().HasTable(migrationsTable) {
+		return all, nil
+	}
+
+	var rows []appliedMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	index := make(map[int64]int, len(all))
+	for i, mig := range all {
+		index[mig.Version] = i
+	}
+	for _, row := range rows {
+		if i, ok := index[row.Version]; ok {
+			all[i].AppliedAt = row.AppliedAt
+			continue
+		}
+		all = append(all, Migration{Version: row.Version, Name: row.Name, AppliedAt: row.AppliedAt})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// locked roda fn numa única conexão segurando o lock de migrations. DDL no
+// MySQL não é transacional, então o lock é o que impede duas réplicas de
+// aplicarem a mesma migration.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var got sql.NullInt64
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", migrationsLock, migrationsLockTimeout).Row().Scan(&got); err != nil {
+			return err
+		}
+		if got.Int64 != 1 {
+			return errors.New("timed out waiting for the migrations lock")
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", migrationsLock)
+
+		if err := conn.Exec(`CREATE TABLE IF NOT EXISTS ` + migrationsTable + ` (
+  version bigint NOT NULL,
+  name varchar(255) NOT NULL,
+  applied_at bigint NOT NULL,
+  PRIMARY KEY (version)
+)`).Error; err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// execScript executa as instruções de um arquivo de migration, uma a uma;
+// online expande OnlineDDLMarker
+func execScript(db *gorm.DB, script string, online bool) error {
+	for _, stmt := range splitStatements(script) {
+		if err := db.Exec(expandOnlineDDL(stmt, online)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandOnlineDDL troca OnlineDDLMarker pelas cláusulas online quando
+// online é true
+func expandOnlineDDL(stmt string, online bool) string {
+	if !online {
+		return stmt
+	}
+	return strings.ReplaceAll(stmt, OnlineDDLMarker, ", ALGORITHM=INPLACE, LOCK=NONE")
+}
+
+// splitStatements separa um script nas instruções terminadas por ";" no
+// fim da linha, ignorando linhas de comentário (--)
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
+
+// CreateMigration grava os arquivos up e down vazios de uma nova migration
+// em dir, com a data UTC de now como versão, e retorna seus caminhos
+func CreateMigration(dir, name string, now time.Time) (up, down string, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = nameSeparator.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	base := now.UTC().Format("20060102150405") + "_" + name
+	up = filepath.Join(dir, base+".up.sql")
+	down = filepath.Join(dir, base+".down.sql")
+	for _, f := range []struct{ path, body string }{
+		{up, "-- " + name + ": alterações do schema\n"},
+		{down, "-- " + name + ": desfaz o up\n"},
+	} {
+		file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		_, err = file.WriteString(f.body)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return up, down, nil
+}
+
+// RunMigrations aplica as migrations pendentes (migrate up)
+func RunMigrations(ctx context.Context, db *gorm.DB, opts ...MigratorOption) ([]Migration, error) {
+	migrator, err := NewMigrator(db, Migrations, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return migrator.Up(ctx)
+}
+
+// PendingMigrations retorna as migrations ainda não aplicadas no banco
+func PendingMigrations(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	migrator, err := NewMigrator(db, Migrations)
+	if err != nil {
+		return nil, err
+	}
+	return migrator.Pending(ctx)
+}
+
+// SchemaVersion retorna a versão do schema do banco e quantas migrations
+// embutidas estão pendentes
+func SchemaVersion(ctx context.Context, db *gorm.DB) (int64, int, error) {
+	migrator, err := NewMigrator(db, Migrations)
+	if err != nil {
+		return 0, 0, err
+	}
+	return migrator.Version(ctx)
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
 }
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS dead_letters;
+DROP TABLE IF EXISTS runs;
+DROP TABLE IF EXISTS segmentation_types;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS segmentations;
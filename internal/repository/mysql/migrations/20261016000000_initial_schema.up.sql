@@ -0,0 +1,85 @@
+-- Schema criado até aqui pelo AutoMigrate. IF NOT EXISTS marca como
+-- aplicada a migration em bancos que já têm as tabelas.
+
+CREATE TABLE IF NOT EXISTS segmentations (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  user_id bigint unsigned NOT NULL,
+  segmentation_type varchar(50) NOT NULL,
+  segmentation_name varchar(100) NOT NULL,
+  data json,
+  created_at bigint,
+  updated_at bigint,
+  PRIMARY KEY (id),
+  UNIQUE INDEX uniq_user_seg (user_id, segmentation_type, segmentation_name)
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  idempotency_key varchar(255) NOT NULL,
+  fingerprint varchar(64) NOT NULL,
+  status_code bigint NOT NULL,
+  content_type varchar(100),
+  body mediumblob,
+  created_at bigint,
+  expires_at bigint NOT NULL,
+  PRIMARY KEY (idempotency_key),
+  INDEX idx_idempotency_keys_expires_at (expires_at)
+);
+
+CREATE TABLE IF NOT EXISTS segmentation_types (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  name varchar(50) NOT NULL,
+  display_name varchar(100) NOT NULL,
+  description text,
+  data_schema json,
+  labels json,
+  active boolean NOT NULL DEFAULT true,
+  created_at bigint,
+  updated_at bigint,
+  PRIMARY KEY (id),
+  UNIQUE INDEX idx_segmentation_types_name (name)
+);
+
+CREATE TABLE IF NOT EXISTS runs (
+  id varchar(36) NOT NULL,
+  source varchar(500),
+  status varchar(20) NOT NULL,
+  rows_read bigint unsigned,
+  enqueued bigint unsigned,
+  inserted bigint unsigned,
+  updated bigint unsigned,
+  duplicates bigint unsigned,
+  failed bigint unsigned,
+  invalid bigint unsigned,
+  warnings bigint unsigned,
+  error text,
+  started_at bigint NOT NULL,
+  finished_at bigint,
+  PRIMARY KEY (id),
+  INDEX idx_runs_status (status),
+  INDEX idx_runs_started_at (started_at)
+);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  run_id varchar(36) NOT NULL,
+  csv_row bigint NOT NULL,
+  raw_line text,
+  error text,
+  created_at bigint,
+  PRIMARY KEY (id),
+  INDEX idx_dead_letters_run_id (run_id)
+);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  actor varchar(100) NOT NULL,
+  action varchar(100) NOT NULL,
+  target varchar(255),
+  status bigint,
+  client_ip varchar(45),
+  created_at bigint NOT NULL,
+  PRIMARY KEY (id),
+  INDEX idx_audit_logs_actor (actor),
+  INDEX idx_audit_logs_action (action),
+  INDEX idx_audit_logs_created_at (created_at)
+);
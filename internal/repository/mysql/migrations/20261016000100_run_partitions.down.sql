@@ -0,0 +1,5 @@
+ALTER TABLE runs
+  DROP INDEX idx_runs_job_id,
+  DROP COLUMN partitions,
+  DROP COLUMN partition_index,
+  DROP COLUMN job_id;
@@ -0,0 +1,11 @@
+-- Particionamento horizontal: os runs das partições de uma importação
+-- compartilham o job_id; runs anteriores viram jobs de uma partição.
+-- "partition" é palavra reservada no MySQL, daí partition_index.
+
+ALTER TABLE runs
+  ADD COLUMN job_id varchar(36) NOT NULL DEFAULT '',
+  ADD COLUMN partition_index bigint NOT NULL DEFAULT 0,
+  ADD COLUMN partitions bigint NOT NULL DEFAULT 1,
+  ADD INDEX idx_runs_job_id (job_id);
+
+UPDATE runs SET job_id = id WHERE job_id = '';
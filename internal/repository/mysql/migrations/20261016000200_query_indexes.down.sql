@@ -0,0 +1,3 @@
+ALTER TABLE segmentations
+  DROP INDEX idx_segmentations_updated_at,
+  DROP INDEX idx_segmentations_type_name /*online_ddl*/;
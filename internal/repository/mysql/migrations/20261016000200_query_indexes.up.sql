@@ -0,0 +1,8 @@
+-- Índices dos padrões de acesso planejados: busca reversa por tipo e nome
+-- (quais usuários têm uma segmentação) e varredura por updated_at para
+-- exportações incrementais. Com db.online_ddl o marcador no fim do ALTER
+-- vira ALGORITHM=INPLACE, LOCK=NONE.
+
+ALTER TABLE segmentations
+  ADD INDEX idx_segmentations_type_name (segmentation_type, segmentation_name),
+  ADD INDEX idx_segmentations_updated_at (updated_at) /*online_ddl*/;
@@ -0,0 +1,7 @@
+-- devolve a data o que estava comprimido antes de remover a coluna
+UPDATE segmentations
+  SET data = CONVERT(UNCOMPRESS(data_compressed) USING utf8mb4), data_compressed = NULL
+  WHERE data_compressed IS NOT NULL;
+
+ALTER TABLE segmentations
+  DROP COLUMN data_compressed /*online_ddl*/;
@@ -0,0 +1,5 @@
+-- data comprimido pelo repositório (db.compression_threshold), no formato
+-- de COMPRESS(); data fica NULL nessas linhas.
+
+ALTER TABLE segmentations
+  ADD COLUMN data_compressed mediumblob /*online_ddl*/;
@@ -0,0 +1,6 @@
+ALTER TABLE segmentations
+  DROP INDEX uniq_user_seg,
+  ADD UNIQUE INDEX uniq_user_seg (user_id, segmentation_type, segmentation_name) /*online_ddl*/;
+
+ALTER TABLE segmentations
+  DROP COLUMN name_key /*online_ddl*/;
@@ -0,0 +1,13 @@
+-- name_key é segmentation_name normalizado pela política de nomes
+-- (validation.name_*) e passa a compor a chave única, enquanto
+-- segmentation_name guarda o nome como foi escrito. As linhas existentes
+-- recebem o próprio nome como chave.
+
+ALTER TABLE segmentations
+  ADD COLUMN name_key varchar(100) NOT NULL DEFAULT '' /*online_ddl*/;
+
+UPDATE segmentations SET name_key = segmentation_name WHERE name_key = '';
+
+ALTER TABLE segmentations
+  DROP INDEX uniq_user_seg,
+  ADD UNIQUE INDEX uniq_user_seg (user_id, segmentation_type, name_key) /*online_ddl*/;
@@ -0,0 +1,8 @@
+-- Usuários conhecidos, consultados pela API com api.user_lookup=table.
+-- Quem sincroniza a base de usuários grava aqui; a API só lê.
+
+CREATE TABLE IF NOT EXISTS users (
+  id bigint unsigned NOT NULL,
+  created_at bigint,
+  PRIMARY KEY (id)
+);
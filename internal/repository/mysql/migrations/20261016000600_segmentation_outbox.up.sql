@@ -0,0 +1,17 @@
+-- Outbox transacional: cada escrita de segmentação grava aqui, na mesma
+-- transação, um evento da mudança. O relay publica os eventos pendentes
+-- (published_at NULL) e os marca; os publicados são apagados depois de
+-- outbox.retention.
+
+CREATE TABLE IF NOT EXISTS segmentation_outbox (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  event_type varchar(32) NOT NULL,
+  user_id bigint unsigned NOT NULL,
+  segmentation_type varchar(50) NOT NULL,
+  segmentation_name varchar(100) NOT NULL,
+  data json,
+  created_at bigint NOT NULL,
+  published_at bigint,
+  PRIMARY KEY (id),
+  INDEX idx_segmentation_outbox_pending (published_at, id)
+);
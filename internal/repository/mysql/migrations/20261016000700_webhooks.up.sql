@@ -0,0 +1,34 @@
+-- Inscrições de webhook e as entregas dos eventos do outbox a cada uma.
+-- A chave única de webhook_deliveries evita entregar duas vezes um evento
+-- republicado pelo relay.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  url varchar(2048) NOT NULL,
+  secret varchar(128) NOT NULL,
+  event_types varchar(255) NOT NULL,
+  active boolean NOT NULL DEFAULT true,
+  created_at bigint,
+  updated_at bigint,
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  webhook_id bigint unsigned NOT NULL,
+  event_id bigint unsigned NOT NULL,
+  event_type varchar(32) NOT NULL,
+  payload json,
+  status varchar(16) NOT NULL,
+  attempts bigint NOT NULL,
+  last_status_code bigint,
+  last_error text,
+  next_attempt_at bigint NOT NULL,
+  created_at bigint NOT NULL,
+  delivered_at bigint,
+  PRIMARY KEY (id),
+  UNIQUE INDEX uniq_webhook_deliveries_event (webhook_id, event_id),
+  INDEX idx_webhook_deliveries_webhook (webhook_id, id),
+  INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+  INDEX idx_webhook_deliveries_created_at (created_at)
+);
@@ -0,0 +1,6 @@
+DELETE FROM runs WHERE kind <> 'import';
+
+ALTER TABLE runs
+  DROP INDEX idx_runs_kind_started_at,
+  DROP COLUMN destination,
+  DROP COLUMN kind;
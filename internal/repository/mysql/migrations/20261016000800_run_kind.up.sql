@@ -0,0 +1,8 @@
+-- Exportações da tabela também ficam em runs: kind separa os runs do
+-- processor (import) dos de POST /admin/exports (export), e destination
+-- guarda onde os arquivos da exportação foram gravados.
+
+ALTER TABLE runs
+  ADD COLUMN kind varchar(20) NOT NULL DEFAULT 'import',
+  ADD COLUMN destination varchar(500) NULL,
+  ADD INDEX idx_runs_kind_started_at (kind, started_at);
@@ -0,0 +1,11 @@
+-- Marca d'água das sincronizações incrementais (warehouse): a última linha
+-- entregue por (updated_at, id), de onde a próxima sincronização continua.
+
+CREATE TABLE IF NOT EXISTS sync_watermarks (
+  name varchar(64) NOT NULL,
+  last_updated_at bigint NOT NULL,
+  last_id bigint unsigned NOT NULL,
+  synced_rows bigint NOT NULL,
+  synced_at bigint NOT NULL,
+  PRIMARY KEY (name)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE segmentation_outbox
+  DROP COLUMN name_key;
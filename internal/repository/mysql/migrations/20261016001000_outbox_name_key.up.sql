@@ -0,0 +1,6 @@
+-- Chave do nome nos eventos do outbox, para quem espelha as segmentações
+-- (índice de busca) identificar a linha mesmo quando o nome muda de grafia.
+-- Eventos antigos ficam com '' e o consumidor usa o nome.
+
+ALTER TABLE segmentation_outbox
+  ADD COLUMN name_key varchar(100) NOT NULL DEFAULT '';
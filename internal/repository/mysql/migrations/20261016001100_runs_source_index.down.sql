@@ -0,0 +1,2 @@
+ALTER TABLE runs
+  DROP INDEX idx_runs_source;
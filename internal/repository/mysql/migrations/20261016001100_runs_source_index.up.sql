@@ -0,0 +1,5 @@
+-- A origem SFTP do processor procura os imports de cada arquivo pela
+-- origem (source); um prefixo de 255 caracteres basta para o índice.
+
+ALTER TABLE runs
+  ADD INDEX idx_runs_source (source(255)) /*online_ddl*/;
@@ -0,0 +1,2 @@
+ALTER TABLE segmentation_types
+  DROP COLUMN applied_spec;
@@ -0,0 +1,6 @@
+-- PUT /admin/segmentation-types/:name declara o estado de um tipo
+-- (infraestrutura como código); applied_spec guarda a última declaração
+-- aplicada, comparada ao estado atual para informar o drift.
+
+ALTER TABLE segmentation_types
+  ADD COLUMN applied_spec json NULL;
@@ -0,0 +1,6 @@
+-- format escolhe a codificação das entregas de um webhook: json, ou a
+-- mensagem protobuf segmentation.v1.SegmentationEvent. Os webhooks
+-- existentes continuam recebendo JSON.
+
+ALTER TABLE webhooks
+  ADD COLUMN format varchar(16) NOT NULL DEFAULT 'json' AFTER event_types;
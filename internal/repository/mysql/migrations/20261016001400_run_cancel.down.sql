@@ -0,0 +1,2 @@
+ALTER TABLE runs
+  DROP COLUMN cancel_requested_at;
@@ -0,0 +1,7 @@
+-- cancel_requested_at registra o pedido de cancelamento de um import em
+-- andamento (POST /imports/:run_id/cancel). O processor que executa o run
+-- o confere a cada progress_interval, então o pedido chega a qualquer
+-- instância, inclusive a um import rodado pela linha de comando.
+
+ALTER TABLE runs
+  ADD COLUMN cancel_requested_at bigint NOT NULL DEFAULT 0 AFTER finished_at;
@@ -0,0 +1,5 @@
+-- pseudonymized marca os exports de POST /admin/exports gravados com os
+-- user IDs trocados por pseudônimos (LGPD), que não podem ser restaurados.
+
+ALTER TABLE runs
+  ADD COLUMN pseudonymized boolean NOT NULL DEFAULT false AFTER cancel_requested_at;
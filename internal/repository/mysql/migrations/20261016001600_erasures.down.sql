@@ -0,0 +1,4 @@
+ALTER TABLE segmentation_outbox
+  DROP INDEX idx_segmentation_outbox_user_id;
+
+DROP TABLE IF EXISTS erasures;
@@ -0,0 +1,28 @@
+-- Registro das eliminações de dados de usuários (DELETE /users/:user_id),
+-- a prova exigida pela LGPD. Cada linha guarda o hash da anterior
+-- (prev_hash) e o seu, calculado sobre os campos e prev_hash: alterar ou
+-- apagar uma linha quebra a cadeia a partir dela.
+--
+-- O índice em segmentation_outbox.user_id serve à eliminação, que apaga
+-- o histórico de eventos do usuário; com db.online_ddl é criado sem
+-- travar as escritas.
+
+CREATE TABLE IF NOT EXISTS erasures (
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  user_id bigint unsigned NOT NULL,
+  actor varchar(100) NOT NULL,
+  client_ip varchar(45),
+  reference varchar(255),
+  segmentations bigint NOT NULL,
+  events bigint NOT NULL,
+  deliveries bigint NOT NULL,
+  created_at bigint NOT NULL,
+  prev_hash varchar(64) NOT NULL,
+  hash varchar(64) NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX idx_erasures_hash (hash),
+  INDEX idx_erasures_user_id (user_id)
+);
+
+ALTER TABLE segmentation_outbox
+  ADD INDEX idx_segmentation_outbox_user_id (user_id) /*online_ddl*/;
@@ -0,0 +1,13 @@
+-- Tamanho diário de cada tipo de segmentação, gravado pelos snapshots de
+-- analytics (GET /admin/analytics/growth). Um snapshot do mesmo dia
+-- substitui o anterior.
+
+CREATE TABLE IF NOT EXISTS segment_snapshots (
+  day date NOT NULL,
+  segmentation_type varchar(50) NOT NULL,
+  segmentations bigint NOT NULL,
+  users bigint NOT NULL,
+  segments bigint NOT NULL,
+  taken_at bigint NOT NULL,
+  PRIMARY KEY (day, segmentation_type)
+);
@@ -0,0 +1,11 @@
+ALTER TABLE erasures
+  DROP COLUMN idempotency_keys,
+  DROP COLUMN dead_letters;
+
+ALTER TABLE idempotency_keys
+  DROP INDEX idx_idempotency_keys_user_id,
+  DROP COLUMN user_id /*online_ddl*/;
+
+ALTER TABLE dead_letters
+  DROP INDEX idx_dead_letters_user_id,
+  DROP COLUMN user_id /*online_ddl*/;
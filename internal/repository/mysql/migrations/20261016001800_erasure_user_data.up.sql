@@ -0,0 +1,22 @@
+-- A eliminação dos dados de um usuário também apaga as linhas rejeitadas
+-- pelo processor (raw_line guarda a linha CSV crua) e as respostas
+-- guardadas pelo Idempotency-Key (body traz as segmentações), que passam a
+-- registrar o user_id. As linhas rejeitadas existentes recebem o user_id
+-- lido da primeira coluna; as respostas existentes ficam com 0 e expiram
+-- pelo TTL.
+
+ALTER TABLE dead_letters
+  ADD COLUMN user_id bigint unsigned NOT NULL DEFAULT 0,
+  ADD INDEX idx_dead_letters_user_id (user_id) /*online_ddl*/;
+
+UPDATE dead_letters
+SET user_id = CAST(TRIM(LEADING '+' FROM TRIM(SUBSTRING_INDEX(raw_line, ',', 1))) AS UNSIGNED)
+WHERE user_id = 0 AND TRIM(SUBSTRING_INDEX(raw_line, ',', 1)) REGEXP '^[+]?[0-9]{1,19}$';
+
+ALTER TABLE idempotency_keys
+  ADD COLUMN user_id bigint unsigned NOT NULL DEFAULT 0,
+  ADD INDEX idx_idempotency_keys_user_id (user_id) /*online_ddl*/;
+
+ALTER TABLE erasures
+  ADD COLUMN dead_letters bigint NOT NULL DEFAULT 0,
+  ADD COLUMN idempotency_keys bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE segmentation_outbox
+  DROP COLUMN run_id /*online_ddl*/;
@@ -0,0 +1,6 @@
+-- Run do processor que gravou cada evento do outbox, publicado nos
+-- webhooks e no Kafka para quem quer agrupar as mudanças de uma
+-- importação. Escritas da API e eventos antigos ficam com ''.
+
+ALTER TABLE segmentation_outbox
+  ADD COLUMN run_id varchar(36) NOT NULL DEFAULT '' /*online_ddl*/;
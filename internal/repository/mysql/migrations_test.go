@@ -0,0 +1,173 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"segmentation-api/internal/models"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20260201000000_add_index.up.sql":   {Data: []byte("CREATE INDEX idx ON t (c);")},
+		"20260201000000_add_index.down.sql": {Data: []byte("DROP INDEX idx ON t;")},
+		"20260101000000_create_t.up.sql":    {Data: []byte("CREATE TABLE t (c int);")},
+		"20260101000000_create_t.down.sql":  {Data: []byte("DROP TABLE t;")},
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 20260101000000 || migrations[1].Name != "add_index" {
+		t.Fatalf("unexpected migrations %+v", migrations)
+	}
+	if migrations[0].Down != "DROP TABLE t;" || migrations[0].Applied() {
+		t.Errorf("unexpected first migration %+v", migrations[0])
+	}
+}
+
+func TestLoadMigrations_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{name: "bad name", fsys: fstest.MapFS{"create_t.sql": {Data: []byte("x")}}},
+		{name: "missing down", fsys: fstest.MapFS{"1_create_t.up.sql": {Data: []byte("x")}}},
+		{name: "empty up", fsys: fstest.MapFS{
+			"1_create_t.up.sql":   {Data: []byte(" \n")},
+			"1_create_t.down.sql": {Data: []byte("x")},
+		}},
+		{name: "name mismatch", fsys: fstest.MapFS{
+			"1_create_t.up.sql":   {Data: []byte("x")},
+			"1_create_u.down.sql": {Data: []byte("x")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadMigrations(tt.fsys); err == nil {
+				t.Error("LoadMigrations() should fail")
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment
+CREATE TABLE a (
+  id int -- inline comments stay
+);
+
+DROP TABLE b;
+UPDATE c SET d = 1`
+
+	got := splitStatements(script)
+	want := []string{
+		"CREATE TABLE a (\n  id int -- inline comments stay\n)",
+		"DROP TABLE b",
+		"UPDATE c SET d = 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestExpandOnlineDDL(t *testing.T) {
+	stmt := "ALTER TABLE a\n  ADD INDEX idx_b (b) " + OnlineDDLMarker
+
+	if got := expandOnlineDDL(stmt, false); got != stmt {
+		t.Errorf("expandOnlineDDL(off) = %q, want the statement unchanged", got)
+	}
+	want := "ALTER TABLE a\n  ADD INDEX idx_b (b) , ALGORITHM=INPLACE, LOCK=NONE"
+	if got := expandOnlineDDL(stmt, true); got != want {
+		t.Errorf("expandOnlineDDL(on) = %q, want %q", got, want)
+	}
+}
+
+func TestNewMigrator_WithOnlineDDL(t *testing.T) {
+	m, err := NewMigrator(nil, Migrations, WithOnlineDDL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.onlineDDL {
+		t.Error("WithOnlineDDL should enable the online DDL clauses")
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 11, 2, 13, 4, 5, 0, time.UTC)
+
+	up, down, err := CreateMigration(dir, "Add Runs Source!", now)
+	if err != nil {
+		t.Fatalf("CreateMigration() error = %v", err)
+	}
+	if filepath.Base(up) != "20261102130405_add_runs_source.up.sql" ||
+		filepath.Base(down) != "20261102130405_add_runs_source.down.sql" {
+		t.Errorf("unexpected files %s, %s", up, down)
+	}
+
+	migrations, err := LoadMigrations(os.DirFS(dir))
+	if err != nil || len(migrations) != 1 {
+		t.Fatalf("created files should load: %v %+v", err, migrations)
+	}
+
+	if _, _, err := CreateMigration(dir, "add runs source", now); err == nil {
+		t.Error("CreateMigration() should not overwrite existing files")
+	}
+	if _, _, err := CreateMigration(dir, " !! ", now); err == nil {
+		t.Error("CreateMigration() should require a name")
+	}
+}
+
+// every model must have its table and columns created by an embedded
+// migration, in the CREATE TABLE or by a later ADD COLUMN
+func TestMigrations_CoverModels(t *testing.T) {
+	migrations, err := LoadMigrations(Migrations)
+	if err != nil {
+		t.Fatalf("embedded migrations: %v", err)
+	}
+	var up strings.Builder
+	for _, m := range migrations {
+		up.WriteString(m.Up)
+	}
+
+	for _, model := range []any{
+		&models.Segmentation{},
+		&models.IdempotencyKey{},
+		&models.SegmentationType{},
+		&models.Run{},
+		&models.DeadLetter{},
+		&models.AuditLog{},
+		&models.User{},
+		&models.OutboxEvent{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.SyncWatermark{},
+		&models.Erasure{},
+		&models.SegmentSnapshot{},
+	} {
+		s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(up.String(), "CREATE TABLE IF NOT EXISTS "+s.Table+" (") {
+			t.Errorf("no migration creates table %s", s.Table)
+			continue
+		}
+		for _, f := range s.DBNames {
+			if !strings.Contains(up.String(), "\n  "+f+" ") && !strings.Contains(up.String(), "ADD COLUMN "+f+" ") {
+				t.Errorf("no migration creates column %s.%s", s.Table, f)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// rekeyBatchSize é o número de linhas lidas por query em RekeyNames
+const rekeyBatchSize = 1000
+
+// NameKeyConflict é uma linha que não recebeu a nova chave porque outra
+// segmentação do mesmo usuário e tipo já a usa
+type NameKeyConflict struct {
+	ID               uint64
+	UserID           uint64
+	SegmentationType string
+	SegmentationName string
+	Key              string
+}
+
+// RekeyResult resume uma execução de RekeyNames
+type RekeyResult struct {
+	Scanned   int
+	Rekeyed   int
+	Conflicts []NameKeyConflict
+}
+
+// RekeyNames recalcula name_key de todas as linhas com key, para que uma
+// mudança na política de nomes valha também para o que já está gravado.
+// Linhas cuja nova chave já pertence a outra linha ficam como estão e são
+// devolvidas em Conflicts, para serem resolvidas à mão. Com dryRun nada é
+// gravado e Rekeyed conta as linhas que mudariam; conflitos só aparecem
+// na execução de verdade.
+func RekeyNames(
+	ctx context.Context,
+	db *gorm.DB,
+	key func(name string) string,
+	dryRun bool,
+) (RekeyResult, error) {
+
+	var result RekeyResult
+	var lastID uint64
+	for {
+		var batch []models.Segmentation
+		err := db.WithContext(ctx).
+			Select("id", "user_id", "segmentation_type", "segmentation_name", "name_key").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(rekeyBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return result, err
+		}
+
+		for _, s := range batch {
+			result.Scanned++
+			want := key(s.SegmentationName)
+			if want == s.NameKey {
+				continue
+			}
+			if dryRun {
+				result.Rekeyed++
+				continue
+			}
+			err := db.WithContext(ctx).Exec("UPDATE segmentations SET name_key = ? WHERE id = ?", want, s.ID).Error
+			if errors.Is(err, repository.ErrConflict) {
+				result.Conflicts = append(result.Conflicts, NameKeyConflict{
+					ID:               s.ID,
+					UserID:           s.UserID,
+					SegmentationType: s.SegmentationType,
+					SegmentationName: s.SegmentationName,
+					Key:              want,
+				})
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+			result.Rekeyed++
+		}
+
+		if len(batch) < rekeyBatchSize {
+			return result, nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
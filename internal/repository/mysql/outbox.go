@@ -0,0 +1,158 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// outboxPurgeBatch é quantos eventos DeletePublished apaga por instrução
+const outboxPurgeBatch = 5000
+
+// WithOutbox grava em segmentation_outbox um evento de cada escrita, na
+// mesma transação da escrita: ou as duas ficam gravadas, ou nenhuma. Fora
+// de Transaction cada Upsert, BulkUpsert e DeleteByIDs passa a abrir a sua.
+// Um Upsert que não muda nada (mesmos dados, INSERT IGNORE de linha
+// existente) não gera evento; o BulkUpsert não sabe quais itens mudaram e
+// gera um evento por item, inclusive os ignorados pelo WithInsertIgnore,
+// então o consumidor pode receber um upsert que não mudou a linha.
+func WithOutbox() Option {
+	return func(r *segmentationRepository) {
+		r.outbox = true
+	}
+}
+
+// inOutboxTx roda fn numa transação quando o outbox está ligado e ainda não
+// há uma, para que a escrita e os eventos sejam gravados juntos
+func (r *segmentationRepository) inOutboxTx(
+	ctx context.Context,
+	fn func(tx repository.SegmentationRepository) error,
+) (bool, error) {
+
+	if !r.outbox || r.inTx {
+		return false, nil
+	}
+	return true, r.Transaction(ctx, fn)
+}
+
+// writeOutbox grava os eventos, se o outbox estiver ligado, com o run de
+// ctx
+func (r *segmentationRepository) writeOutbox(ctx context.Context, events []models.OutboxEvent) error {
+	if !r.outbox || len(events) == 0 {
+		return nil
+	}
+	if runID := repository.RunIDFrom(ctx); runID != "" {
+		for i := range events {
+			events[i].RunID = runID
+		}
+	}
+	return r.db.WithContext(ctx).CreateInBatches(events, 500).Error
+}
+
+// upsertEvents monta os eventos de segmentações gravadas, com o data como
+// foi escrito (antes da compressão)
+func upsertEvents(items []models.Segmentation, now int64) []models.OutboxEvent {
+	events := make([]models.OutboxEvent, len(items))
+	for i, s := range items {
+		events[i] = models.OutboxEvent{
+			EventType:        models.OutboxUpserted,
+			UserID:           s.UserID,
+			SegmentationType: s.SegmentationType,
+			SegmentationName: s.SegmentationName,
+			NameKey:          s.UniqueName(),
+			Data:             s.Data,
+			CreatedAt:        now,
+		}
+	}
+	return events
+}
+
+// deleteEvents monta os eventos de segmentações apagadas
+func deleteEvents(rows []models.Segmentation, now int64) []models.OutboxEvent {
+	events := make([]models.OutboxEvent, len(rows))
+	for i, s := range rows {
+		events[i] = models.OutboxEvent{
+			EventType:        models.OutboxDeleted,
+			UserID:           s.UserID,
+			SegmentationType: s.SegmentationType,
+			SegmentationName: s.SegmentationName,
+			NameKey:          s.UniqueName(),
+			CreatedAt:        now,
+		}
+	}
+	return events
+}
+
+// lockForDelete lê e trava as linhas que DeleteByIDs vai apagar, para que
+// os eventos tragam usuário, tipo, nome e chave do nome
+func (r *segmentationRepository) lockForDelete(ctx context.Context, ids []uint64) ([]models.Segmentation, error) {
+	var rows []models.Segmentation
+	err := r.db.WithContext(ctx).
+		Select("id", "user_id", "segmentation_type", "segmentation_name", "name_key").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&rows).Error
+	return rows, err
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) repository.OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Pending(
+	ctx context.Context,
+	limit int,
+) ([]models.OutboxEvent, error) {
+
+	var events []models.OutboxEvent
+	err := r.db.WithContext(ctx).
+		Where("published_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *outboxRepository) MarkPublished(
+	ctx context.Context,
+	ids []uint64,
+	at int64,
+) error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id IN ?", ids).
+		Update("published_at", at).Error
+}
+
+// DeletePublished apaga em lotes, para não segurar locks de uma limpeza
+// grande de uma vez
+func (r *outboxRepository) DeletePublished(
+	ctx context.Context,
+	before int64,
+) (int64, error) {
+
+	var total int64
+	for {
+		tx := r.db.WithContext(ctx).
+			Where("published_at IS NOT NULL AND published_at < ?", before).
+			Limit(outboxPurgeBatch).
+			Delete(&models.OutboxEvent{})
+		total += tx.RowsAffected
+		if tx.Error != nil || tx.RowsAffected < outboxPurgeBatch {
+			return total, tx.Error
+		}
+	}
+}
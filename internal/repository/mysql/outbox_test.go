@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+func TestOutboxRepositoryInterface(t *testing.T) {
+	var _ repository.OutboxRepository = (*outboxRepository)(nil)
+}
+
+func TestNewSegmentationRepository_WithOutbox(t *testing.T) {
+	if r := NewSegmentationRepository(nil).(*segmentationRepository); r.outbox {
+		t.Error("the outbox should be off by default")
+	}
+	if r := NewSegmentationRepository(nil, WithOutbox()).(*segmentationRepository); !r.outbox || r.inTx {
+		t.Error("WithOutbox should turn the outbox on outside a transaction")
+	}
+}
+
+func TestOutboxEvents(t *testing.T) {
+	items := []models.Segmentation{
+		{UserID: 1, SegmentationType: "drug", SegmentationName: "Aspirina", NameKey: "aspirina", Data: datatypes.JSON(`{"dose":"500mg"}`)},
+		{UserID: 2, SegmentationType: "specialty", SegmentationName: "Cardiologia", DataCompressed: []byte{1}},
+	}
+
+	upserts := upsertEvents(items, 100)
+	if len(upserts) != 2 || upserts[0].EventType != models.OutboxUpserted ||
+		string(upserts[0].Data) != `{"dose":"500mg"}` || upserts[1].UserID != 2 || upserts[1].CreatedAt != 100 ||
+		upserts[0].NameKey != "aspirina" || upserts[1].NameKey != "Cardiologia" {
+		t.Errorf("unexpected upsert events %+v", upserts)
+	}
+
+	deletes := deleteEvents(items, 200)
+	if len(deletes) != 2 || deletes[0].EventType != models.OutboxDeleted || deletes[0].Data != nil ||
+		deletes[1].SegmentationName != "Cardiologia" || deletes[1].PublishedAt != nil || deletes[0].NameKey != "aspirina" {
+		t.Errorf("unexpected delete events %+v", deletes)
+	}
+}
+
+func TestOutboxRepository_Statements(t *testing.T) {
+	db := dryRun(t)
+	db.SkipDefaultTransaction = true // writes open no transaction without a server
+	var statements []string
+	capture := func(tx *gorm.DB) { statements = append(statements, tx.Statement.SQL.String()) }
+	db.Callback().Query().After("gorm:query").Register("test:capture", capture)
+	db.Callback().Update().After("gorm:update").Register("test:capture", capture)
+	db.Callback().Delete().After("gorm:delete").Register("test:capture", capture)
+
+	r := NewOutboxRepository(db)
+	ctx := context.Background()
+	if _, err := r.Pending(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.MarkPublished(ctx, []uint64{1, 2}, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DeletePublished(ctx, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"SELECT * FROM `segmentation_outbox` WHERE published_at IS NULL ORDER BY id LIMIT ?",
+		"UPDATE `segmentation_outbox` SET `published_at`=? WHERE id IN (?,?)",
+		"DELETE FROM `segmentation_outbox` WHERE published_at IS NOT NULL AND published_at < ? LIMIT ?",
+	}
+	if len(statements) != len(want) {
+		t.Fatalf("statements = %q", statements)
+	}
+	for i := range want {
+		if statements[i] != want[i] {
+			t.Errorf("statement %d = %s, want %s", i, statements[i], want[i])
+		}
+	}
+}
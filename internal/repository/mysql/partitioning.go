@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// MaxPartitions é o limite de partições de uma tabela no MySQL
+const MaxPartitions = 8192
+
+// PartitionStatements retorna o DDL que particiona segmentations por
+// HASH(user_id) em partitions partições, ou que remove o particionamento
+// com partitions 0.
+//
+// Toda chave única de uma tabela particionada precisa conter a coluna do
+// particionamento, então a chave primária passa de (id) para (id, user_id);
+// uniq_user_seg já começa por user_id. Sem particionamento ela volta a ser
+// (id). As duas operações reconstroem a tabela inteira.
+func PartitionStatements(partitions int) ([]string, error) {
+	if partitions < 0 || partitions > MaxPartitions {
+		return nil, fmt.Errorf("partitions must be between 0 and %d, got %d", MaxPartitions, partitions)
+	}
+	if partitions == 0 {
+		return []string{
+			"ALTER TABLE segmentations REMOVE PARTITIONING",
+			"ALTER TABLE segmentations DROP PRIMARY KEY, ADD PRIMARY KEY (id)",
+		}, nil
+	}
+	return []string{fmt.Sprintf(
+		"ALTER TABLE segmentations DROP PRIMARY KEY, ADD PRIMARY KEY (id, user_id) PARTITION BY HASH(user_id) PARTITIONS %d",
+		partitions,
+	)}, nil
+}
+
+// SegmentationPartitions retorna o número de partições de segmentations;
+// 0 quando a tabela não é particionada
+func SegmentationPartitions(ctx context.Context, db *gorm.DB) (int, error) {
+	var n int
+	err := db.WithContext(ctx).Raw(`SELECT COUNT(*) FROM information_schema.PARTITIONS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'segmentations' AND PARTITION_NAME IS NOT NULL`).
+		Scan(&n).Error
+	return n, err
+}
+
+// PartitionSegmentations deixa segmentations com partitions partições (0
+// remove o particionamento) e retorna o número anterior. Roda com o lock
+// das migrations, para não concorrer com um migrate up; não faz nada
+// quando a tabela já tem esse número de partições.
+func PartitionSegmentations(ctx context.Context, db *gorm.DB, partitions int) (int, error) {
+	stmts, err := PartitionStatements(partitions)
+	if err != nil {
+		return 0, err
+	}
+	migrator, err := NewMigrator(db, Migrations)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int
+	err = migrator.locked(ctx, func(conn *gorm.DB) error {
+		n, err := SegmentationPartitions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current = n; current == partitions {
+			return nil
+		}
+		if partitions > 0 && current > 0 {
+			// já particionada: a chave primária já é (id, user_id)
+			stmts = []string{fmt.Sprintf("ALTER TABLE segmentations PARTITION BY HASH(user_id) PARTITIONS %d", partitions)}
+		}
+		for _, stmt := range stmts {
+			if err := conn.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return current, err
+}
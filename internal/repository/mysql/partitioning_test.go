@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPartitionStatements(t *testing.T) {
+	stmts, err := PartitionStatements(32)
+	if err != nil {
+		t.Fatalf("PartitionStatements(32) error = %v", err)
+	}
+	if len(stmts) != 1 || !strings.Contains(stmts[0], "ADD PRIMARY KEY (id, user_id)") ||
+		!strings.HasSuffix(stmts[0], "PARTITION BY HASH(user_id) PARTITIONS 32") {
+		t.Errorf("unexpected partitioning DDL: %q", stmts)
+	}
+
+	stmts, err = PartitionStatements(0)
+	if err != nil {
+		t.Fatalf("PartitionStatements(0) error = %v", err)
+	}
+	if len(stmts) != 2 || stmts[0] != "ALTER TABLE segmentations REMOVE PARTITIONING" ||
+		!strings.Contains(stmts[1], "ADD PRIMARY KEY (id)") {
+		t.Errorf("unexpected DDL removing the partitioning: %q", stmts)
+	}
+
+	for _, n := range []int{-1, MaxPartitions + 1} {
+		if _, err := PartitionStatements(n); err == nil {
+			t.Errorf("PartitionStatements(%d) should fail", n)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Privilégios usados pela aplicação e, além deles, pelas migrations
+var (
+	DataPrivileges      = []string{"SELECT", "INSERT", "UPDATE", "DELETE"}
+	MigrationPrivileges = []string{"CREATE", "ALTER", "DROP", "INDEX"}
+)
+
+// MissingPrivileges retorna os privilégios de required que o usuário da
+// conexão não tem no banco atual, globais ou no schema. Privilégios vindos
+// de roles (MySQL 8) não aparecem no information_schema e contam como
+// ausentes.
+func MissingPrivileges(ctx context.Context, db *gorm.DB, required ...string) ([]string, error) {
+	db = db.WithContext(ctx)
+
+	var current, schema string
+	if err := db.Raw("SELECT CURRENT_USER(), DATABASE()").Row().Scan(&current, &schema); err != nil {
+		return nil, err
+	}
+	user, host := current, "%"
+	if i := strings.LastIndex(current, "@"); i >= 0 {
+		user, host = current[:i], current[i+1:]
+	}
+	grantee := "'" + user + "'@'" + host + "'"
+
+	var granted []string
+	err := db.Raw(`SELECT PRIVILEGE_TYPE FROM information_schema.USER_PRIVILEGES WHERE GRANTEE = ?
+UNION
+SELECT PRIVILEGE_TYPE FROM information_schema.SCHEMA_PRIVILEGES WHERE GRANTEE = ? AND ? LIKE TABLE_SCHEMA`,
+		grantee, grantee, schema).Scan(&granted).Error
+	if err != nil {
+		return nil, err
+	}
+
+	has := make(map[string]bool, len(granted))
+	for _, p := range granted {
+		has[strings.ToUpper(p)] = true
+	}
+	var missing []string
+	for _, p := range required {
+		if !has[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,219 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type runRepository struct {
+	db *gorm.DB
+}
+
+func NewRunRepository(db *gorm.DB) repository.RunRepository {
+	return &runRepository{db: db}
+}
+
+func (r *runRepository) Create(
+	ctx context.Context,
+	run *models.Run,
+) error {
+
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+// claimLockTimeout é a espera, em segundos, pelo lock das partições do job
+const claimLockTimeout = 10
+
+func (r *runRepository) ClaimPartition(
+	ctx context.Context,
+	run *models.Run,
+) error {
+
+	// o lock serializa as instâncias que escolhem partição no mesmo job;
+	// o nome cabe nos 64 caracteres do MySQL (job_id tem até 36)
+	lock := "segmentation_job:" + run.JobID
+
+	return r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var got sql.NullInt64
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", lock, claimLockTimeout).Row().Scan(&got); err != nil {
+			return err
+		}
+		if got.Int64 != 1 {
+			return errors.New("timed out waiting for the job partitions lock")
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", lock)
+
+		// partições com run falho ou cancelado podem ser tomadas de novo
+		var taken []int
+		err := conn.Model(&models.Run{}).
+			Where("job_id = ? AND status IN ?", run.JobID, []string{models.RunRunning, models.RunSucceeded}).
+			Distinct().
+			Pluck("partition_index", &taken).Error
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < run.Partitions; i++ {
+			if !slices.Contains(taken, i) {
+				run.Partition = i
+				return conn.Create(run).Error
+			}
+		}
+		return repository.ErrNoPartition
+	})
+}
+
+func (r *runRepository) Update(
+	ctx context.Context,
+	run *models.Run,
+) error {
+
+	// Select("*") grava também contadores zerados; o tipo e o modo do run
+	// não mudam, e o pedido de cancelamento é da API
+	return r.db.WithContext(ctx).
+		Model(run).
+		Select("*").
+		Omit("id", "kind", "started_at", "cancel_requested_at", "pseudonymized").
+		Updates(run).Error
+}
+
+func (r *runRepository) UpdateProgress(
+	ctx context.Context,
+	run *models.Run,
+) error {
+
+	return r.db.WithContext(ctx).
+		Model(&models.Run{}).
+		Where("id = ? AND status = ?", run.ID, models.RunRunning).
+		Updates(map[string]any{
+			"rows_read":  run.RowsRead,
+			"enqueued":   run.Enqueued,
+			"inserted":   run.Inserted,
+			"updated":    run.Updated,
+			"duplicates": run.Duplicates,
+			"failed":     run.Failed,
+			"invalid":    run.Invalid,
+			"warnings":   run.Warnings,
+		}).Error
+}
+
+func (r *runRepository) RequestCancel(
+	ctx context.Context,
+	id string,
+	at int64,
+) (bool, error) {
+
+	res := r.db.WithContext(ctx).
+		Model(&models.Run{}).
+		Where("id = ? AND status = ? AND cancel_requested_at = 0", id, models.RunRunning).
+		Update("cancel_requested_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *runRepository) CancelRequested(
+	ctx context.Context,
+	id string,
+) (bool, error) {
+
+	var at []int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Run{}).
+		Where("id = ?", id).
+		Limit(1).
+		Pluck("cancel_requested_at", &at).Error
+	return len(at) > 0 && at[0] > 0, err
+}
+
+func (r *runRepository) Get(
+	ctx context.Context,
+	id string,
+) (*models.Run, error) {
+
+	var rows []models.Run
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Limit(1).
+		Find(&rows).Error
+
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+func (r *runRepository) Latest(
+	ctx context.Context,
+) (*models.Run, error) {
+
+	var rows []models.Run
+
+	// Find com slice evita o log de "record not found" do First
+	err := r.db.WithContext(ctx).
+		Where("kind = ?", models.RunImport).
+		Order("started_at DESC").
+		Limit(1).
+		Find(&rows).Error
+
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+func (r *runRepository) JobRuns(
+	ctx context.Context,
+	jobID string,
+) ([]models.Run, error) {
+
+	var runs []models.Run
+	err := r.db.WithContext(ctx).
+		Where("job_id = ?", jobID).
+		Order("started_at ASC").
+		Find(&runs).Error
+	return runs, err
+}
+
+func (r *runRepository) SourceRuns(
+	ctx context.Context,
+	source string,
+) ([]models.Run, error) {
+
+	var runs []models.Run
+	err := r.db.WithContext(ctx).
+		Where("kind = ? AND source = ?", models.RunImport, source).
+		Order("started_at ASC").
+		Find(&runs).Error
+	return runs, err
+}
+
+func (r *runRepository) List(
+	ctx context.Context,
+	filter repository.RunFilter,
+) ([]models.Run, error) {
+
+	q := r.db.WithContext(ctx)
+	if filter.Kind != "" {
+		q = q.Where("kind = ?", filter.Kind)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if b := filter.Before; b != nil {
+		// o ID desempata os runs iniciados no mesmo segundo
+		q = q.Where("started_at < ? OR (started_at = ? AND id < ?)", b.StartedAt, b.StartedAt, b.ID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	var runs []models.Run
+	err := q.Order("started_at DESC, id DESC").Find(&runs).Error
+	return runs, err
+}
@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestRunRepositoryInterface(t *testing.T) {
+	var _ repository.RunRepository = (*runRepository)(nil)
+}
+
+func TestNewRunRepository(t *testing.T) {
+	repo := NewRunRepository(nil)
+	if repo == nil {
+		t.Fatal("NewRunRepository should not return nil")
+	}
+
+	if _, ok := repo.(*runRepository); !ok {
+		t.Error("NewRunRepository should return *runRepository")
+	}
+}
@@ -2,10 +2,9 @@ package mysql
 
 import (
 	"context"
-	"log"
+	"strings"
 
-	// "log"
-	"gorm.io/gorm/clause"
+	"go.uber.org/zap"
 
 	"segmentation-api/internal/models"
 	"segmentation-api/internal/repository"
@@ -15,11 +14,47 @@ import (
 )
 
 type segmentationRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	logger *zap.Logger
+	raw    *rawReader // nil lê pelo GORM
+	// insertIgnore grava com INSERT IGNORE, sem atualizar as existentes
+	insertIgnore bool
+	// compressAbove é o tamanho a partir do qual data é comprimido (0 desliga)
+	compressAbove int
+	// outbox grava um evento de cada escrita em segmentation_outbox
+	outbox bool
+	// inTx indica que db já é uma transação aberta por Transaction
+	inTx bool
 }
 
-func NewSegmentationRepository(db *gorm.DB) repository.SegmentationRepository {
-	return &segmentationRepository{db: db}
+// Option customiza o repositório de segmentações
+type Option func(*segmentationRepository)
+
+// WithLogger define o logger usado pelo repositório; por padrão nada é logado
+func WithLogger(logger *zap.Logger) Option {
+	return func(r *segmentationRepository) {
+		r.logger = logger
+	}
+}
+
+// WithInsertIgnore troca o upsert por INSERT IGNORE, para a carga inicial
+// de uma tabela vazia: sem o ON DUPLICATE KEY UPDATE a instrução fica mais
+// barata, mas uma linha que já existe é mantida (UpsertNoOp) em vez de
+// atualizada, e o IGNORE transforma em warnings erros que o modo estrito
+// recusaria (um valor longo demais é truncado). Só serve para dados já
+// validados, como os do processor.
+func WithInsertIgnore() Option {
+	return func(r *segmentationRepository) {
+		r.insertIgnore = true
+	}
+}
+
+func NewSegmentationRepository(db *gorm.DB, opts ...Option) repository.SegmentationRepository {
+	r := &segmentationRepository{db: db, logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *segmentationRepository) FindByUserID(
@@ -27,14 +62,49 @@ func (r *segmentationRepository) FindByUserID(
 	userID uint64,
 ) ([]models.Segmentation, error) {
 
+	if r.raw != nil {
+		return r.raw.findByUserID(ctx, r, userID)
+	}
+
 	var segs []models.Segmentation
 
 	err := r.db.WithContext(ctx).
 		Where("user_id = ?", userID).
 		Order("segmentation_type, segmentation_name").
 		Find(&segs).Error
+	if err != nil {
+		return nil, err
+	}
+	return segs, inflate(segs)
+}
+
+// EachByUserID lê com a query de WithRawReads, mas pelo GORM: as linhas
+// vêm do cursor do driver, e a conexão fica com a leitura até o fim
+func (r *segmentationRepository) EachByUserID(
+	ctx context.Context,
+	userID uint64,
+	fn func(models.Segmentation) error,
+) error {
 
-	return segs, err
+	rows, err := r.db.WithContext(ctx).Raw(findByUserIDQuery, userID).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		seg := make([]models.Segmentation, 1)
+		if err := scanSegmentation(rows, &seg[0]); err != nil {
+			return err
+		}
+		if err := inflate(seg); err != nil {
+			return err
+		}
+		if err := fn(seg[0]); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 func (r *segmentationRepository) Upsert(
@@ -56,29 +126,60 @@ func (r *segmentationRepository) Upsert(
 	// 	}).
 	// 	Create(s)
 
+	var result repository.UpsertResult
+	if ok, err := r.inOutboxTx(ctx, func(tx repository.SegmentationRepository) error {
+		var err error
+		result, err = tx.Upsert(ctx, s)
+		return err
+	}); ok {
+		return result, err
+	}
+
+	data, compressed, err := r.storedData(s.Data)
+	if err != nil {
+		return repository.UpsertNoOp, err
+	}
+	if r.insertIgnore {
+		return r.insertIgnoreOne(ctx, s, data, compressed)
+	}
+	now := time.Now().Unix()
+
 	tx := r.db.WithContext(ctx).Exec(`
 	INSERT INTO segmentations
-	(user_id, segmentation_type, segmentation_name, data, updated_at)
-	VALUES (?, ?, ?, ?, ?)
+	(user_id, segmentation_type, segmentation_name, name_key, data, data_compressed, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	ON DUPLICATE KEY UPDATE
+	segmentation_name = VALUES(segmentation_name),
 	data = VALUES(data),
+	data_compressed = VALUES(data_compressed),
 	updated_at = VALUES(updated_at)
 	`,
 		s.UserID,
 		s.SegmentationType,
 		s.SegmentationName,
-		s.Data,
-		time.Now().Unix(),
+		s.UniqueName(),
+		data,
+		compressed,
+		createdAt(s.CreatedAt, now),
+		now,
 	)
 
 	if tx.Error != nil {
-		log.Printf(
-			"upsert_error user_id=%d seg_type=%s seg_name=%s error=%v",
-			s.UserID, s.SegmentationType, s.SegmentationName, tx.Error,
+		r.logger.Error("upsert_error",
+			zap.Uint64("user_id", s.UserID),
+			zap.String("seg_type", s.SegmentationType),
+			zap.String("seg_name", s.SegmentationName),
+			zap.Error(tx.Error),
 		)
 		return repository.UpsertNoOp, tx.Error
 	}
 
+	if tx.RowsAffected > 0 {
+		if err := r.writeOutbox(ctx, upsertEvents([]models.Segmentation{*s}, now)); err != nil {
+			return repository.UpsertNoOp, err
+		}
+	}
+
 	// MySQL trick (important)
 	if tx.RowsAffected == 1 {
 		return repository.UpsertInserted, nil
@@ -86,22 +187,203 @@ func (r *segmentationRepository) Upsert(
 	return repository.UpsertUpdated, nil
 }
 
+func (r *segmentationRepository) DeleteByIDs(
+	ctx context.Context,
+	ids []uint64,
+) (int64, error) {
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var deleted int64
+	if ok, err := r.inOutboxTx(ctx, func(tx repository.SegmentationRepository) error {
+		var err error
+		deleted, err = tx.DeleteByIDs(ctx, ids)
+		return err
+	}); ok {
+		return deleted, err
+	}
+
+	var rows []models.Segmentation
+	if r.outbox {
+		var err error
+		if rows, err = r.lockForDelete(ctx, ids); err != nil {
+			return 0, err
+		}
+	}
+
+	tx := r.db.WithContext(ctx).
+		Where("id IN ?", ids).
+		Delete(&models.Segmentation{})
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return tx.RowsAffected, r.writeOutbox(ctx, deleteEvents(rows, time.Now().Unix()))
+}
+
+func (r *segmentationRepository) Transaction(
+	ctx context.Context,
+	fn func(tx repository.SegmentationRepository) error,
+) error {
+
+	return guardTransaction(r.db, func() error {
+		return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(&segmentationRepository{
+				db:            tx,
+				logger:        r.logger,
+				insertIgnore:  r.insertIgnore,
+				compressAbove: r.compressAbove,
+				outbox:        r.outbox,
+				inTx:          true,
+			})
+		})
+	})
+}
+
 func (r *segmentationRepository) BulkUpsert(
 	ctx context.Context,
 	items []models.Segmentation,
-) error {
+) (repository.BulkUpsertResult, error) {
+
+	if len(items) == 0 {
+		return repository.BulkUpsertResult{}, nil
+	}
+
+	var result repository.BulkUpsertResult
+	if ok, err := r.inOutboxTx(ctx, func(tx repository.SegmentationRepository) error {
+		var err error
+		result, err = tx.BulkUpsert(ctx, items)
+		return err
+	}); ok {
+		return result, err
+	}
+
+	// mesmo upsert de Upsert, com uma tupla por item
+	now := time.Now().Unix()
+	var sql strings.Builder
+	if r.insertIgnore {
+		sql.WriteString("INSERT IGNORE")
+	} else {
+		sql.WriteString("INSERT")
+	}
+	sql.WriteString(` INTO segmentations
+	(user_id, segmentation_type, segmentation_name, name_key, data, data_compressed, created_at, updated_at)
+	VALUES `)
+	args := make([]interface{}, 0, len(items)*8)
+	for i, s := range items {
+		if i > 0 {
+			sql.WriteString(", ")
+		}
+		data, compressed, err := r.storedData(s.Data)
+		if err != nil {
+			return repository.BulkUpsertResult{}, err
+		}
+		sql.WriteString("(?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, s.UserID, s.SegmentationType, s.SegmentationName, s.UniqueName(), data, compressed, createdAt(s.CreatedAt, now), now)
+	}
+	if !r.insertIgnore {
+		sql.WriteString(`
+	ON DUPLICATE KEY UPDATE
+	segmentation_name = VALUES(segmentation_name),
+	data = VALUES(data),
+	data_compressed = VALUES(data_compressed),
+	updated_at = VALUES(updated_at)`)
+	}
 
-	return r.db.WithContext(ctx).
-		Clauses(clause.OnConflict{
-			Columns: []clause.Column{
-				{Name: "user_id"},
-				{Name: "segmentation_type"},
-				{Name: "segmentation_name"},
-			},
-			DoUpdates: clause.Assignments(map[string]interface{}{
-				"data":       gorm.Expr("VALUES(data)"),
-				"updated_at": time.Now().Unix(),
-			}),
-		}).
-		Create(&items).Error
+	tx := r.db.WithContext(ctx).Exec(sql.String(), args...)
+	if tx.Error != nil {
+		r.logger.Error("bulk_upsert_error", zap.Int("items", len(items)), zap.Error(tx.Error))
+		return repository.BulkUpsertResult{}, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		if err := r.writeOutbox(ctx, upsertEvents(items, now)); err != nil {
+			return repository.BulkUpsertResult{}, err
+		}
+	}
+	if r.insertIgnore {
+		// cada linha inserida conta 1; as ignoradas, 0
+		inserted := int(tx.RowsAffected)
+		return repository.BulkUpsertResult{Inserted: inserted, Ignored: len(items) - inserted}, nil
+	}
+	return bulkUpsertCounts(len(items), tx.RowsAffected), nil
+}
+
+// insertIgnoreOne é o Upsert de WithInsertIgnore, com data e compressed
+// já preparados por storedData
+func (r *segmentationRepository) insertIgnoreOne(
+	ctx context.Context,
+	s *models.Segmentation,
+	data, compressed any,
+) (repository.UpsertResult, error) {
+
+	now := time.Now().Unix()
+	tx := r.db.WithContext(ctx).Exec(`
+	INSERT IGNORE INTO segmentations
+	(user_id, segmentation_type, segmentation_name, name_key, data, data_compressed, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		s.UserID,
+		s.SegmentationType,
+		s.SegmentationName,
+		s.UniqueName(),
+		data,
+		compressed,
+		createdAt(s.CreatedAt, now),
+		now,
+	)
+	if tx.Error != nil {
+		r.logger.Error("upsert_error",
+			zap.Uint64("user_id", s.UserID),
+			zap.String("seg_type", s.SegmentationType),
+			zap.String("seg_name", s.SegmentationName),
+			zap.Error(tx.Error),
+		)
+		return repository.UpsertNoOp, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return repository.UpsertNoOp, nil
+	}
+	if err := r.writeOutbox(ctx, upsertEvents([]models.Segmentation{*s}, now)); err != nil {
+		return repository.UpsertNoOp, err
+	}
+	return repository.UpsertInserted, nil
+}
+
+// bulkUpsertCounts separa inseridas de atualizadas: o MySQL conta 1 por
+// linha inserida e 2 por linha atualizada. Uma linha regravada sem mudança
+// (mesmo data no mesmo segundo) conta 0, então nesse caso a divisão é
+// aproximada.
+func bulkUpsertCounts(items int, affected int64) repository.BulkUpsertResult {
+	updated := int(affected) - items
+	if updated < 0 {
+		updated = 0
+	}
+	return repository.BulkUpsertResult{Inserted: items - updated, Updated: updated}
+}
+
+// SegmentationsEmpty reporta se a tabela de segmentações está vazia, a
+// condição da carga inicial com WithInsertIgnore
+func SegmentationsEmpty(ctx context.Context, db *gorm.DB) (bool, error) {
+	var found int
+	err := db.WithContext(ctx).Raw("SELECT 1 FROM segmentations LIMIT 1").Scan(&found).Error
+	return found == 0, err
+}
+
+// Ping confere se o banco aceita queries pelo GORM, ou seja, também pelo
+// circuit breaker: com o circuito ainda aberto ele falha mesmo com o
+// servidor de volta
+func Ping(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Exec("SELECT 1").Error
+}
+
+// createdAt é o created_at de uma linha inserida: o informado na escrita ou
+// now. O ON DUPLICATE KEY UPDATE não o altera, então uma atualização mantém
+// o da inserção.
+func createdAt(given, now int64) int64 {
+	if given > 0 {
+		return given
+	}
+	return now
 }
@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+
+	"segmentation-api/internal/models"
+)
+
+// rawSegmentationColumns são as colunas lidas pelo caminho database/sql, na
+// ordem do Scan em scanSegmentation
+var rawSegmentationColumns = []string{
+	"id", "user_id", "segmentation_type", "segmentation_name", "name_key", "data", "data_compressed", "created_at", "updated_at",
+}
+
+var findByUserIDQuery = "SELECT " + strings.Join(rawSegmentationColumns, ", ") +
+	" FROM segmentations WHERE user_id = ? ORDER BY segmentation_type, segmentation_name"
+
+// WithRawReads faz FindByUserID, o endpoint mais quente da API, usar
+// database/sql com um prepared statement e scan manual em vez da reflexão
+// do GORM. As leituras dentro de Transaction continuam no GORM, e a query
+// não passa pelo logger do GORM (nem pelo log de queries lentas); o circuit
+// breaker continua valendo.
+func WithRawReads() Option {
+	return func(r *segmentationRepository) {
+		r.raw = &rawReader{}
+	}
+}
+
+// rawReader guarda o prepared statement de FindByUserID, preparado no
+// primeiro uso; uma falha ao preparar (banco fora do ar) é tentada de novo
+// na próxima leitura
+type rawReader struct {
+	mu   sync.Mutex
+	stmt *sql.Stmt
+}
+
+func (rr *rawReader) prepare(ctx context.Context, r *segmentationRepository) (*sql.Stmt, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if rr.stmt != nil {
+		return rr.stmt, nil
+	}
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := sqlDB.PrepareContext(ctx, findByUserIDQuery)
+	if err != nil {
+		return nil, err
+	}
+	rr.stmt = stmt
+	return stmt, nil
+}
+
+// findByUserID é FindByUserID sobre database/sql
+func (rr *rawReader) findByUserID(
+	ctx context.Context,
+	r *segmentationRepository,
+	userID uint64,
+) ([]models.Segmentation, error) {
+
+	var segs []models.Segmentation
+	err := guardTransaction(r.db, func() error {
+		stmt, err := rr.prepare(ctx, r)
+		if err != nil {
+			return err
+		}
+		rows, err := stmt.QueryContext(ctx, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var s models.Segmentation
+			if err := scanSegmentation(rows, &s); err != nil {
+				return err
+			}
+			segs = append(segs, s)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return inflate(segs)
+	})
+	return segs, err
+}
+
+// scanSegmentation lê uma linha com as colunas de rawSegmentationColumns
+func scanSegmentation(rows *sql.Rows, s *models.Segmentation) error {
+	var data []byte
+	var createdAt, updatedAt sql.NullInt64
+	if err := rows.Scan(
+		&s.ID, &s.UserID, &s.SegmentationType, &s.SegmentationName, &s.NameKey, &data, &s.DataCompressed, &createdAt, &updatedAt,
+	); err != nil {
+		return err
+	}
+	s.Data = data
+	s.CreatedAt = createdAt.Int64
+	s.UpdatedAt = updatedAt.Int64
+	return nil
+}
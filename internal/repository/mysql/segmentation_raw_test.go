@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"segmentation-api/internal/models"
+
+	"gorm.io/gorm/schema"
+)
+
+// O caminho database/sql lê as colunas pelo nome fixo: a lista precisa
+// acompanhar o modelo
+func TestRawSegmentationColumns_MatchModel(t *testing.T) {
+	s, err := schema.Parse(&models.Segmentation{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rawSegmentationColumns, s.DBNames) {
+		t.Errorf("raw columns = %v, model columns = %v", rawSegmentationColumns, s.DBNames)
+	}
+}
+
+func TestWithRawReads(t *testing.T) {
+	repo := NewSegmentationRepository(nil, WithRawReads()).(*segmentationRepository)
+	if repo.raw == nil {
+		t.Fatal("WithRawReads should enable the database/sql read path")
+	}
+	if plain := NewSegmentationRepository(nil).(*segmentationRepository); plain.raw != nil {
+		t.Error("reads should use GORM by default")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"segmentation-api/internal/models"
 	"segmentation-api/internal/repository"
 
+	"go.uber.org/zap"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -311,3 +312,47 @@ func TestSegmentationModelValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSegmentationRepository_WithLogger(t *testing.T) {
+	logger := zap.NewExample()
+	repo := NewSegmentationRepository(nil, WithLogger(logger))
+
+	r, ok := repo.(*segmentationRepository)
+	if !ok {
+		t.Fatal("NewSegmentationRepository should return *segmentationRepository")
+	}
+	if r.logger != logger {
+		t.Error("WithLogger should set the repository logger")
+	}
+}
+
+func TestNewSegmentationRepository_WithInsertIgnore(t *testing.T) {
+	if r := NewSegmentationRepository(nil).(*segmentationRepository); r.insertIgnore {
+		t.Error("the repository should upsert by default")
+	}
+	if r := NewSegmentationRepository(nil, WithInsertIgnore()).(*segmentationRepository); !r.insertIgnore {
+		t.Error("WithInsertIgnore should switch the repository to INSERT IGNORE")
+	}
+}
+
+func TestBulkUpsertCounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    int
+		affected int64
+		want     repository.BulkUpsertResult
+	}{
+		{name: "all inserted", items: 3, affected: 3, want: repository.BulkUpsertResult{Inserted: 3}},
+		{name: "all updated", items: 3, affected: 6, want: repository.BulkUpsertResult{Updated: 3}},
+		{name: "mixed", items: 3, affected: 4, want: repository.BulkUpsertResult{Inserted: 2, Updated: 1}},
+		{name: "unchanged rows", items: 3, affected: 2, want: repository.BulkUpsertResult{Inserted: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bulkUpsertCounts(tt.items, tt.affected); got != tt.want {
+				t.Errorf("bulkUpsertCounts(%d, %d) = %+v, want %+v", tt.items, tt.affected, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type segmentationTypeRepository struct {
+	db *gorm.DB
+}
+
+func NewSegmentationTypeRepository(db *gorm.DB) repository.SegmentationTypeRepository {
+	return &segmentationTypeRepository{db: db}
+}
+
+func (r *segmentationTypeRepository) List(
+	ctx context.Context,
+) ([]models.SegmentationType, error) {
+
+	var types []models.SegmentationType
+
+	err := r.db.WithContext(ctx).
+		Order("name").
+		Find(&types).Error
+
+	return types, err
+}
+
+func (r *segmentationTypeRepository) FindByName(
+	ctx context.Context,
+	name string,
+) (*models.SegmentationType, error) {
+
+	var types []models.SegmentationType
+
+	err := r.db.WithContext(ctx).
+		Where("name = ?", name).
+		Limit(1).
+		Find(&types).Error
+
+	if err != nil || len(types) == 0 {
+		return nil, err
+	}
+	return &types[0], nil
+}
+
+func (r *segmentationTypeRepository) Create(
+	ctx context.Context,
+	t *models.SegmentationType,
+) error {
+
+	now := models.Timestamp(time.Now().Unix())
+	t.CreatedAt = now
+	t.UpdatedAt = now
+
+	// Select explícito para gravar active=false (zero value seria ignorado)
+	return r.db.WithContext(ctx).
+		Select("*").
+		Omit("id").
+		Create(t).Error
+}
+
+func (r *segmentationTypeRepository) Update(
+	ctx context.Context,
+	t *models.SegmentationType,
+) error {
+
+	t.UpdatedAt = models.Timestamp(time.Now().Unix())
+
+	return r.db.WithContext(ctx).
+		Model(&models.SegmentationType{}).
+		Where("name = ?", t.Name).
+		Updates(map[string]interface{}{
+			"display_name": t.DisplayName,
+			"description":  t.Description,
+			"data_schema":  t.DataSchema,
+			"labels":       t.Labels,
+			"active":       t.Active,
+			"applied_spec": t.AppliedSpec,
+			"updated_at":   t.UpdatedAt,
+		}).Error
+}
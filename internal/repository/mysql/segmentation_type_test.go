@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestSegmentationTypeRepositoryInterface(t *testing.T) {
+	var _ repository.SegmentationTypeRepository = (*segmentationTypeRepository)(nil)
+}
+
+func TestNewSegmentationTypeRepository(t *testing.T) {
+	repo := NewSegmentationTypeRepository(nil)
+	if repo == nil {
+		t.Fatal("NewSegmentationTypeRepository should not return nil")
+	}
+
+	if _, ok := repo.(*segmentationTypeRepository); !ok {
+		t.Error("NewSegmentationTypeRepository should return *segmentationTypeRepository")
+	}
+}
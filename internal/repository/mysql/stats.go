@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/repository"
+)
+
+// analyzedTables são as tabelas lidas pela API, cujas estatísticas de
+// índice o otimizador usa para escolher os planos
+var analyzedTables = []string{"segmentations", "segmentation_types"}
+
+// AnalyzeTables recalcula as estatísticas de índice das tabelas da API com
+// ANALYZE TABLE, útil depois de uma carga grande que deixou os planos
+// ruins. Só trava a tabela para leitura de metadados; as escritas seguem.
+func AnalyzeTables(ctx context.Context, db *gorm.DB) ([]repository.TableStats, error) {
+	rows, err := db.WithContext(ctx).Raw("ANALYZE TABLE " + strings.Join(analyzedTables, ", ")).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []repository.TableStats
+	for rows.Next() {
+		var s repository.TableStats
+		var op string
+		if err := rows.Scan(&s.Table, &op, &s.MsgType, &s.MsgText); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,40 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type watermarkRepository struct {
+	db *gorm.DB
+}
+
+func NewWatermarkRepository(db *gorm.DB) repository.WatermarkRepository {
+	return &watermarkRepository{db: db}
+}
+
+func (r *watermarkRepository) Get(ctx context.Context, name string) (*models.SyncWatermark, error) {
+	var rows []models.SyncWatermark
+
+	// Find com slice evita o log de "record not found" do First
+	err := r.db.WithContext(ctx).
+		Where("name = ?", name).
+		Limit(1).
+		Find(&rows).Error
+
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+func (r *watermarkRepository) Save(ctx context.Context, w *models.SyncWatermark) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(w).Error
+}
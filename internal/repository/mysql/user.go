@@ -0,0 +1,30 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type userDirectory struct {
+	db *gorm.DB
+}
+
+// NewUserDirectory consulta a tabela users, mantida por quem sincroniza a
+// base de usuários
+func NewUserDirectory(db *gorm.DB) repository.UserDirectory {
+	return &userDirectory{db: db}
+}
+
+func (r *userDirectory) Exists(ctx context.Context, userID uint64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", userID).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
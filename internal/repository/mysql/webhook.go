@@ -0,0 +1,199 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) repository.WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) List(
+	ctx context.Context,
+) ([]models.Webhook, error) {
+
+	var hooks []models.Webhook
+	err := r.db.WithContext(ctx).Order("id").Find(&hooks).Error
+	return hooks, err
+}
+
+func (r *webhookRepository) Find(
+	ctx context.Context,
+	id uint64,
+) (*models.Webhook, error) {
+
+	var hooks []models.Webhook
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Limit(1).
+		Find(&hooks).Error
+	if err != nil || len(hooks) == 0 {
+		return nil, err
+	}
+	return &hooks[0], nil
+}
+
+func (r *webhookRepository) Create(
+	ctx context.Context,
+	w *models.Webhook,
+) error {
+
+	now := time.Now().Unix()
+	w.CreatedAt = now
+	w.UpdatedAt = now
+
+	// Select explícito para gravar active=false (zero value seria ignorado)
+	return r.db.WithContext(ctx).
+		Select("*").
+		Omit("id").
+		Create(w).Error
+}
+
+func (r *webhookRepository) Update(
+	ctx context.Context,
+	w *models.Webhook,
+) error {
+
+	w.UpdatedAt = time.Now().Unix()
+
+	return r.db.WithContext(ctx).
+		Model(&models.Webhook{}).
+		Where("id = ?", w.ID).
+		Updates(map[string]interface{}{
+			"url":         w.URL,
+			"secret":      w.Secret,
+			"event_types": w.EventTypes,
+			"format":      w.Format,
+			"active":      w.Active,
+			"updated_at":  w.UpdatedAt,
+		}).Error
+}
+
+func (r *webhookRepository) Delete(
+	ctx context.Context,
+	id uint64,
+) (bool, error) {
+
+	var deleted bool
+	err := guardTransaction(r.db, func() error {
+		return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			res := tx.Where("id = ?", id).Delete(&models.Webhook{})
+			if res.Error != nil {
+				return res.Error
+			}
+			deleted = res.RowsAffected > 0
+			return tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error
+		})
+	})
+	return deleted, err
+}
+
+func (r *webhookRepository) Enqueue(
+	ctx context.Context,
+	deliveries []models.WebhookDelivery,
+) error {
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	// um evento republicado pelo relay não gera uma segunda entrega
+	return r.db.WithContext(ctx).
+		Clauses(clause.Insert{Modifier: "IGNORE"}).
+		CreateInBatches(deliveries, 500).Error
+}
+
+func (r *webhookRepository) Due(
+	ctx context.Context,
+	now int64,
+	limit int,
+) ([]models.WebhookDelivery, error) {
+
+	var deliveries []models.WebhookDelivery
+	err := r.db.WithContext(ctx).
+		Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id AND webhooks.active").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("webhook_deliveries.next_attempt_at, webhook_deliveries.id").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *webhookRepository) Deliveries(
+	ctx context.Context,
+	filter repository.DeliveryFilter,
+) ([]models.WebhookDelivery, error) {
+
+	q := r.db.WithContext(ctx).Model(&models.WebhookDelivery{})
+	if filter.WebhookID > 0 {
+		q = q.Where("webhook_id = ?", filter.WebhookID)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		q = q.Where("id < ?", filter.BeforeID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := q.Order("id DESC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *webhookRepository) FindDelivery(
+	ctx context.Context,
+	webhookID, id uint64,
+) (*models.WebhookDelivery, error) {
+
+	var deliveries []models.WebhookDelivery
+	err := r.db.WithContext(ctx).
+		Where("id = ? AND webhook_id = ?", id, webhookID).
+		Limit(1).
+		Find(&deliveries).Error
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+func (r *webhookRepository) SaveDelivery(
+	ctx context.Context,
+	d *models.WebhookDelivery,
+) error {
+
+	return r.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("id = ?", d.ID).
+		Updates(map[string]interface{}{
+			"status":           d.Status,
+			"attempts":         d.Attempts,
+			"last_status_code": d.LastStatusCode,
+			"last_error":       d.LastError,
+			"next_attempt_at":  d.NextAttemptAt,
+			"delivered_at":     d.DeliveredAt,
+		}).Error
+}
+
+func (r *webhookRepository) DeleteFinished(
+	ctx context.Context,
+	before int64,
+) (int64, error) {
+
+	tx := r.db.WithContext(ctx).
+		Where("status IN ? AND created_at < ?", []string{models.DeliveryDelivered, models.DeliveryFailed}, before).
+		Delete(&models.WebhookDelivery{})
+	return tx.RowsAffected, tx.Error
+}
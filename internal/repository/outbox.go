@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+type runIDKey struct{}
+
+// WithRunID marca as escritas feitas com ctx como do run runID do
+// processor; os eventos do outbox que elas gravam levam o ID
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunIDFrom retorna o run de WithRunID, ou "" numa escrita da API
+func RunIDFrom(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDKey{}).(string)
+	return runID
+}
+
+// OutboxRepository lê e marca os eventos do outbox, gravados junto com as
+// escritas pelo repositório de segmentações
+type OutboxRepository interface {
+	// Pending retorna até limit eventos ainda não publicados, em ordem de
+	// ID (a ordem das escritas)
+	Pending(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	// MarkPublished grava at (unix) como published_at dos eventos
+	MarkPublished(ctx context.Context, ids []uint64, at int64) error
+	// DeletePublished apaga os eventos publicados antes de before (unix) e
+	// retorna quantos apagou
+	DeletePublished(ctx context.Context, before int64) (int64, error)
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunIDFrom(t *testing.T) {
+	ctx := context.Background()
+	if got := RunIDFrom(ctx); got != "" {
+		t.Errorf("RunIDFrom() without a run = %q", got)
+	}
+	if got := RunIDFrom(WithRunID(ctx, "run-1")); got != "run-1" {
+		t.Errorf("RunIDFrom() = %q, want run-1", got)
+	}
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"segmentation-api/internal/models"
+)
+
+// ErrNoPartition indica que todas as partições do job já têm um run em
+// andamento ou concluído
+var ErrNoPartition = errors.New("every partition of the job is claimed")
+
+// RunFilter seleciona os runs de List; os campos vazios não filtram
+type RunFilter struct {
+	Kind   string
+	Status string
+	// Before pagina: só os runs que vêm depois dele na ordem de List
+	Before *models.Run
+	Limit  int
+}
+
+type RunRepository interface {
+	Create(ctx context.Context, run *models.Run) error
+	// ClaimPartition cria run na primeira partição do job (run.JobID, de 0
+	// a run.Partitions-1) sem run em andamento ou concluído e grava o índice
+	// em run.Partition; ErrNoPartition quando não sobra nenhuma
+	ClaimPartition(ctx context.Context, run *models.Run) error
+	// Update grava status, contadores e horário de término do run
+	Update(ctx context.Context, run *models.Run) error
+	// UpdateProgress grava só os contadores do run, e só enquanto ele está
+	// em andamento: uma gravação atrasada não desfaz o fim do run
+	UpdateProgress(ctx context.Context, run *models.Run) error
+	// RequestCancel grava at como o pedido de cancelamento do run em
+	// andamento; false se o run não está em andamento ou o pedido já existe
+	RequestCancel(ctx context.Context, id string, at int64) (bool, error)
+	// CancelRequested informa se o cancelamento do run foi pedido
+	CancelRequested(ctx context.Context, id string) (bool, error)
+	// Get retorna o run com o ID, de qualquer tipo, ou nil se ele não existir
+	Get(ctx context.Context, id string) (*models.Run, error)
+	// Latest retorna o run do processor iniciado por último, ou nil se não
+	// houver nenhum; exportações não contam
+	Latest(ctx context.Context) (*models.Run, error)
+	// JobRuns retorna os runs do job, do mais antigo ao mais recente
+	JobRuns(ctx context.Context, jobID string) ([]models.Run, error)
+	// SourceRuns retorna os imports da origem (models.Run.Source), do mais
+	// antigo ao mais recente
+	SourceRuns(ctx context.Context, source string) ([]models.Run, error)
+	// List retorna os runs do filtro, do iniciado por último ao primeiro
+	List(ctx context.Context, filter RunFilter) ([]models.Run, error)
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+
+	"segmentation-api/internal/models"
+)
+
+// SearchQuery é uma busca de segmentações num índice de busca: Text casa
+// de forma aproximada com o nome (e com os valores de data), Filter
+// restringe os campos como em Changes. Sem Text os resultados saem do mais
+// recente ao mais antigo.
+type SearchQuery struct {
+	Text   string
+	Filter Filter
+	Limit  int
+	Offset int
+}
+
+// SearchHit é uma segmentação encontrada e a relevância dela para o Text
+type SearchHit struct {
+	Segmentation models.Segmentation
+	Score        float64
+}
+
+// SearchResult traz os resultados de uma página e o total de segmentações
+// que passam na busca
+type SearchResult struct {
+	Total int64
+	Hits  []SearchHit
+}
+
+// SearchRepository busca segmentações num índice espelhado do MySQL
+type SearchRepository interface {
+	Search(ctx context.Context, q SearchQuery) (*SearchResult, error)
+}
@@ -13,7 +13,72 @@ const (
 	UpsertNoOp
 )
 
+// BulkUpsertResult conta as linhas inseridas e atualizadas por um
+// BulkUpsert; Ignored são as que já existiam e ficaram como estavam
+// (INSERT IGNORE da carga inicial)
+type BulkUpsertResult struct {
+	Inserted int
+	Updated  int
+	Ignored  int
+}
+
 type SegmentationRepository interface {
 	FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error)
+	// EachByUserID chama fn para cada segmentação do usuário, na ordem de
+	// FindByUserID, lendo uma linha por vez em vez de carregar todas; um
+	// erro de fn interrompe a leitura e é devolvido
+	EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error
 	Upsert(ctx context.Context, s *models.Segmentation) (UpsertResult, error) // retorna UpsertResult agora
+	// BulkUpsert grava items numa única instrução; um erro vale para o lote
+	// inteiro
+	BulkUpsert(ctx context.Context, items []models.Segmentation) (BulkUpsertResult, error)
+	DeleteByIDs(ctx context.Context, ids []uint64) (int64, error)
+	// Transaction executa fn com um repositório ligado a uma única transação;
+	// qualquer erro retornado por fn faz rollback
+	Transaction(ctx context.Context, fn func(tx SegmentationRepository) error) error
+}
+
+// EachFound implementa EachByUserID sobre FindByUserID, para repositórios
+// que já guardam as linhas em memória
+func EachFound(
+	ctx context.Context,
+	find func(ctx context.Context, userID uint64) ([]models.Segmentation, error),
+	userID uint64,
+	fn func(models.Segmentation) error,
+) error {
+	segs, err := find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, s := range segs {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpsertEach implementa BulkUpsert com um upsert por item, para
+// repositórios sem instrução em lote; para no primeiro erro
+func UpsertEach(
+	ctx context.Context,
+	upsert func(ctx context.Context, s *models.Segmentation) (UpsertResult, error),
+	items []models.Segmentation,
+) (BulkUpsertResult, error) {
+	var result BulkUpsertResult
+	for i := range items {
+		res, err := upsert(ctx, &items[i])
+		if err != nil {
+			return result, err
+		}
+		switch res {
+		case UpsertInserted:
+			result.Inserted++
+		case UpsertNoOp:
+			result.Ignored++
+		default:
+			result.Updated++
+		}
+	}
+	return result, nil
 }
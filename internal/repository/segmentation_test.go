@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"testing"
+
+	"segmentation-api/internal/models"
 )
 
 func TestUpsertResultConstants(t *testing.T) {
@@ -57,3 +61,28 @@ func TestUpsertResultDistinct(t *testing.T) {
 		seen[val] = true
 	}
 }
+
+func TestUpsertEach(t *testing.T) {
+	calls := 0
+	upsert := func(ctx context.Context, s *models.Segmentation) (UpsertResult, error) {
+		calls++
+		switch s.UserID {
+		case 2:
+			return UpsertUpdated, nil
+		case 3:
+			return UpsertNoOp, nil
+		case 4:
+			return UpsertNoOp, errors.New("boom")
+		}
+		return UpsertInserted, nil
+	}
+
+	items := []models.Segmentation{{UserID: 1}, {UserID: 2}, {UserID: 3}, {UserID: 4}, {UserID: 5}}
+	result, err := UpsertEach(context.Background(), upsert, items)
+	if err == nil || calls != 4 {
+		t.Errorf("UpsertEach should stop at the first error, err = %v, calls = %d", err, calls)
+	}
+	if result != (BulkUpsertResult{Inserted: 1, Updated: 1, Ignored: 1}) {
+		t.Errorf("result = %+v, want 1 inserted, 1 updated and 1 ignored", result)
+	}
+}
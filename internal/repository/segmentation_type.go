@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+type SegmentationTypeRepository interface {
+	List(ctx context.Context) ([]models.SegmentationType, error)
+	// FindByName retorna nil, nil quando o tipo não está registrado
+	FindByName(ctx context.Context, name string) (*models.SegmentationType, error)
+	Create(ctx context.Context, t *models.SegmentationType) error
+	Update(ctx context.Context, t *models.SegmentationType) error
+}
@@ -0,0 +1,9 @@
+package repository
+
+// TableStats é o resultado do recálculo das estatísticas de uma tabela
+// (uma linha de ANALYZE TABLE)
+type TableStats struct {
+	Table   string `json:"table"`
+	MsgType string `json:"msg_type"`
+	MsgText string `json:"msg_text"`
+}
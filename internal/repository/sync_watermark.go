@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+// WatermarkRepository guarda até onde cada sincronização incremental leu o
+// feed de mudanças (ChangeRepository)
+type WatermarkRepository interface {
+	// Get retorna a marca de name, ou nil se a sincronização nunca rodou
+	Get(ctx context.Context, name string) (*models.SyncWatermark, error)
+	// Save grava a marca, criando-a na primeira vez
+	Save(ctx context.Context, w *models.SyncWatermark) error
+}
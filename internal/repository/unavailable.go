@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"time"
+
+	"segmentation-api/internal/apperrors"
+)
+
+// ErrUnavailable indica que o banco está inacessível: a conexão falhou ou
+// o circuit breaker está aberto e a operação nem foi tentada
+var ErrUnavailable = apperrors.New("database unavailable", apperrors.ErrUnavailable)
+
+// UnavailableError é o erro de uma operação recusada ou interrompida por
+// falta de conexão; RetryAfter estima quando vale tentar de novo
+type UnavailableError struct {
+	RetryAfter time.Duration
+	// Err é a falha de conexão original, se houve tentativa
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Err == nil {
+		return ErrUnavailable.Error()
+	}
+	return ErrUnavailable.Error() + ": " + e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrUnavailable}
+	}
+	return []error{ErrUnavailable, e.Err}
+}
@@ -0,0 +1,9 @@
+package repository
+
+import "context"
+
+// UserDirectory diz se um usuário existe, para a API separar um usuário
+// desconhecido (404) de um usuário conhecido sem segmentações (200 vazio)
+type UserDirectory interface {
+	Exists(ctx context.Context, userID uint64) (bool, error)
+}
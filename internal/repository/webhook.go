@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"segmentation-api/internal/models"
+)
+
+// DeliveryFilter restringe a listagem de entregas de um webhook; campos
+// vazios não filtram
+type DeliveryFilter struct {
+	WebhookID uint64
+	Status    string
+	BeforeID  uint64 // pagina a partir da entrega anterior a esta
+	Limit     int
+}
+
+type WebhookRepository interface {
+	List(ctx context.Context) ([]models.Webhook, error)
+	// Find retorna nil, nil quando o webhook não existe
+	Find(ctx context.Context, id uint64) (*models.Webhook, error)
+	Create(ctx context.Context, w *models.Webhook) error
+	Update(ctx context.Context, w *models.Webhook) error
+	// Delete apaga o webhook e suas entregas; false quando ele não existe
+	Delete(ctx context.Context, id uint64) (bool, error)
+
+	// Enqueue grava entregas pendentes, ignorando as de um evento que o
+	// webhook já tem
+	Enqueue(ctx context.Context, deliveries []models.WebhookDelivery) error
+	// Due retorna até limit entregas pendentes de webhooks ativos com
+	// next_attempt_at até now, as mais antigas primeiro
+	Due(ctx context.Context, now int64, limit int) ([]models.WebhookDelivery, error)
+	// Deliveries lista as entregas, mais recentes primeiro
+	Deliveries(ctx context.Context, filter DeliveryFilter) ([]models.WebhookDelivery, error)
+	// FindDelivery retorna nil, nil quando a entrega não é do webhook
+	FindDelivery(ctx context.Context, webhookID, id uint64) (*models.WebhookDelivery, error)
+	// SaveDelivery grava o estado de uma entrega depois de uma tentativa
+	SaveDelivery(ctx context.Context, d *models.WebhookDelivery) error
+	// DeleteFinished apaga as entregas concluídas (entregues ou falhas)
+	// criadas antes de before e retorna quantas apagou
+	DeleteFinished(ctx context.Context, before int64) (int64, error)
+}
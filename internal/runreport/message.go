@@ -0,0 +1,186 @@
+package runreport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"segmentation-api/internal/models"
+)
+
+// report is what the email of a run says
+type report struct {
+	Run       *models.Run
+	Host      string
+	ErrorsURL string
+	// Highlights are the errors of the first Sampled dead-letter entries
+	Highlights []*highlight
+	Sampled    int
+	// Attachment is the CSV of the dead-letter entries; TooLarge tells it
+	// was left out
+	Attachment []byte
+	TooLarge   bool
+}
+
+// highlight is an error shared by Count dead-letter entries
+type highlight struct {
+	Error    string
+	Count    int
+	FirstRow int
+}
+
+// outcome describes the status of the run
+func (r *report) outcome() string {
+	switch {
+	case r.Run.Status == models.RunFailed:
+		return "failed"
+	case r.Run.Status == models.RunCancelled:
+		return "was cancelled"
+	case r.Run.Status == models.RunRunning:
+		return "is still running"
+	case r.Run.Invalid > 0 || r.Run.Failed > 0:
+		return "succeeded with rejected rows"
+	}
+	return "succeeded"
+}
+
+func (r *report) subject() string {
+	return fmt.Sprintf("Import %s: %s (run %s)", r.outcome(), path.Base(r.Run.Source), r.Run.ID)
+}
+
+// body is the plain text of the email
+func (r *report) body() string {
+	var b strings.Builder
+	run := r.Run
+	fmt.Fprintf(&b, "The import run %s %s.\n\n", run.ID, r.outcome())
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Source:\t%s\n", run.Source)
+	if r.Host != "" {
+		fmt.Fprintf(tw, "Host:\t%s\n", r.Host)
+	}
+	if run.Partitions > 1 {
+		fmt.Fprintf(tw, "Partition:\t%d of %d (job %s)\n", run.Partition+1, run.Partitions, run.JobID)
+	}
+	started := time.Unix(run.StartedAt, 0).UTC()
+	fmt.Fprintf(tw, "Started:\t%s\n", started.Format(time.DateTime+" MST"))
+	if run.FinishedAt > 0 {
+		fmt.Fprintf(tw, "Finished:\t%s\n", time.Unix(run.FinishedAt, 0).UTC().Format(time.DateTime+" MST"))
+		fmt.Fprintf(tw, "Duration:\t%s\n", time.Duration(run.FinishedAt-run.StartedAt)*time.Second)
+	}
+	fmt.Fprintln(tw, "\t")
+	fmt.Fprintf(tw, "Rows read:\t%d\n", run.RowsRead)
+	fmt.Fprintf(tw, "Inserted:\t%d\n", run.Inserted)
+	fmt.Fprintf(tw, "Updated:\t%d\n", run.Updated)
+	fmt.Fprintf(tw, "Unchanged:\t%d\n", run.Duplicates)
+	fmt.Fprintf(tw, "Invalid:\t%d\n", run.Invalid)
+	fmt.Fprintf(tw, "Failed:\t%d\n", run.Failed)
+	fmt.Fprintf(tw, "Warnings:\t%d\n", run.Warnings)
+	tw.Flush()
+
+	if run.Error != "" {
+		fmt.Fprintf(&b, "\nError: %s\n", run.Error)
+	}
+
+	if len(r.Highlights) > 0 {
+		rejected := run.Invalid + run.Failed
+		if uint64(r.Sampled) < rejected {
+			fmt.Fprintf(&b, "\nMost common errors among the first %d of %d rejected rows:\n", r.Sampled, rejected)
+		} else {
+			b.WriteString("\nMost common errors of the rejected rows:\n")
+		}
+		for _, h := range r.Highlights[:min(highlights, len(r.Highlights))] {
+			msg := h.Error
+			if len(msg) > maxHighlightBytes {
+				msg = strings.ToValidUTF8(msg[:maxHighlightBytes], "") + "…"
+			}
+			fmt.Fprintf(&b, "  %6d × %s (first at row %d)\n", h.Count, msg, h.FirstRow)
+		}
+		if n := len(r.Highlights) - highlights; n > 0 {
+			fmt.Fprintf(&b, "  and %d other errors\n", n)
+		}
+	}
+
+	if run.Invalid > 0 || run.Failed > 0 {
+		b.WriteString("\n")
+		if r.ErrorsURL != "" {
+			fmt.Fprintf(&b, "Every rejected row, with its error: %s\n", r.ErrorsURL)
+		}
+		switch {
+		case r.Attachment != nil:
+			fmt.Fprintf(&b, "The rejected rows are attached as %s.\n", r.attachmentName())
+		case r.TooLarge:
+			b.WriteString("The rejected rows are too many to attach.\n")
+		}
+	}
+	return b.String()
+}
+
+func (r *report) attachmentName() string {
+	return "dead_letters_" + r.Run.ID + ".csv"
+}
+
+// message is the email of the report, with the attachment when there is
+// one
+func (r *report) message(from string, to []string, now time.Time) ([]byte, error) {
+	var msg bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&msg, "%s: %s\r\n", key, value)
+	}
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", r.subject()))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	mw := multipart.NewWriter(&msg)
+	header("Content-Type", `multipart/mixed; boundary="`+mw.Boundary()+`"`)
+	msg.WriteString("\r\n")
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(r.body(), "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	if r.Attachment != nil {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {`text/csv; charset=utf-8; name="` + r.attachmentName() + `"`},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {`attachment; filename="` + r.attachmentName() + `"`},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// base64 in lines of 76 characters, as MIME requires
+		encoded := base64.StdEncoding.EncodeToString(r.Attachment)
+		for len(encoded) > 0 {
+			n := min(76, len(encoded))
+			if _, err := fmt.Fprintf(part, "%s\r\n", encoded[:n]); err != nil {
+				return nil, err
+			}
+			encoded = encoded[n:]
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
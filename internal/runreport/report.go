@@ -0,0 +1,203 @@
+// Package runreport emails a summary of each processor run to the people
+// who own the data but do not watch the dashboards: its status, counters
+// and duration, the most common reasons rows were rejected, and the
+// dead-letter entries as a CSV attachment and as a link to
+// GET /imports/:run_id/errors.
+//
+// The summary is read back from the runs and dead_letters tables once the
+// run has been recorded, so it reports exactly what the API serves.
+package runreport
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+)
+
+const (
+	// highlightRows is how many dead-letter entries the highlights are
+	// drawn from
+	highlightRows = 500
+	// highlights is how many distinct errors the summary lists
+	highlights = 5
+	// maxHighlightBytes truncates long error messages in the summary
+	maxHighlightBytes = 200
+)
+
+// Config is the SMTP server and the recipients of the reports
+type Config struct {
+	SMTP
+	From string
+	To   []string
+	// ErrorsURL links to the dead-letter report of the run, with {run_id}
+	// replaced by its ID, as in
+	// https://api.example.com/imports/{run_id}/errors?format=csv;
+	// no link when empty
+	ErrorsURL string
+	// AttachMaxBytes caps the CSV of the dead-letter entries attached to
+	// the report; a larger one is left out. 0 attaches nothing.
+	AttachMaxBytes int
+	// OnlyProblems skips the runs that succeeded without rejecting rows
+	OnlyProblems bool
+}
+
+// Mailer emails the report of each run
+type Mailer struct {
+	cfg         Config
+	sender      string // address of From, for the envelope
+	runs        repository.RunRepository
+	deadLetters repository.DeadLetterRepository
+	errors      *service.ImportErrors
+	logger      *zap.Logger
+	send        func(ctx context.Context, cfg SMTP, from string, to []string, msg []byte) error
+	hostname    string
+	now         func() time.Time
+}
+
+// Option customizes a Mailer
+type Option func(*Mailer)
+
+// WithLogger sets the logger of the reports sent
+func WithLogger(logger *zap.Logger) Option {
+	return func(m *Mailer) {
+		m.logger = logger
+	}
+}
+
+// NewMailer creates a mailer reporting the runs recorded in runs and
+// deadLetters
+func NewMailer(cfg Config, runs repository.RunRepository, deadLetters repository.DeadLetterRepository, opts ...Option) (*Mailer, error) {
+	if cfg.Addr == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("runreport: SMTP address, sender and recipients are required")
+	}
+	sender, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("runreport: sender: %w", err)
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+	if !slices.Contains([]string{TLSStartTLS, TLSImplicit, TLSNone}, cfg.TLS) {
+		return nil, fmt.Errorf("runreport: unknown TLS mode %q", cfg.TLS)
+	}
+	hostname, _ := os.Hostname()
+	m := &Mailer{
+		cfg:         cfg,
+		sender:      sender.Address,
+		runs:        runs,
+		deadLetters: deadLetters,
+		errors:      service.NewImportErrors(runs, deadLetters),
+		logger:      zap.NewNop(),
+		send:        send,
+		hostname:    hostname,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Send emails the report of a finished run. It does nothing for a run the
+// runs table does not know and, with OnlyProblems, for a clean run.
+func (m *Mailer) Send(ctx context.Context, runID string) error {
+	run, err := m.runs.Get(ctx, runID)
+	if err != nil {
+		return err
+	}
+	if run == nil || (m.cfg.OnlyProblems && run.Status == models.RunSucceeded && run.Invalid == 0 && run.Failed == 0) {
+		return nil
+	}
+
+	report := &report{Run: run, Host: m.hostname, ErrorsURL: strings.ReplaceAll(m.cfg.ErrorsURL, "{run_id}", run.ID)}
+	if run.Invalid > 0 || run.Failed > 0 {
+		if err := m.highlight(ctx, report); err != nil {
+			return err
+		}
+		if err := m.attach(ctx, report); err != nil {
+			return err
+		}
+	}
+
+	msg, err := report.message(m.cfg.From, m.cfg.To, m.now())
+	if err != nil {
+		return err
+	}
+	if err := m.send(ctx, m.cfg.SMTP, m.sender, m.cfg.To, msg); err != nil {
+		return fmt.Errorf("runreport: send: %w", err)
+	}
+	m.logger.Info("run_report_sent", zap.String("run_id", run.ID), zap.Strings("to", m.cfg.To),
+		zap.Bool("attachment", report.Attachment != nil))
+	return nil
+}
+
+// highlight groups the first dead-letter entries of the run by error, most
+// common first
+func (m *Mailer) highlight(ctx context.Context, r *report) error {
+	entries, err := m.deadLetters.List(ctx, r.Run.ID, 0, highlightRows)
+	if err != nil {
+		return err
+	}
+	byError := map[string]*highlight{}
+	for _, e := range entries {
+		h := byError[e.Error]
+		if h == nil {
+			h = &highlight{Error: e.Error, FirstRow: e.RowNumber}
+			byError[e.Error] = h
+			r.Highlights = append(r.Highlights, h)
+		}
+		h.Count++
+	}
+	slices.SortStableFunc(r.Highlights, func(a, b *highlight) int { return cmp.Compare(b.Count, a.Count) })
+	r.Sampled = len(entries)
+	return nil
+}
+
+// attach writes the dead-letter entries of the run as CSV, unless they
+// exceed AttachMaxBytes
+func (m *Mailer) attach(ctx context.Context, r *report) error {
+	if m.cfg.AttachMaxBytes <= 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	err := m.errors.Write(ctx, r.Run.ID, service.ExportFormatCSV, &cappedWriter{w: &buf, left: m.cfg.AttachMaxBytes})
+	switch {
+	case errors.Is(err, errTooLarge):
+		r.TooLarge = true
+		return nil
+	case err != nil:
+		return err
+	}
+	r.Attachment = buf.Bytes()
+	return nil
+}
+
+var errTooLarge = errors.New("attachment too large")
+
+// cappedWriter fails once more than left bytes are written
+type cappedWriter struct {
+	w    io.Writer
+	left int
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	if len(p) > c.left {
+		return 0, errTooLarge
+	}
+	c.left -= len(p)
+	return c.w.Write(p)
+}
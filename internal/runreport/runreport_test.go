@@ -0,0 +1,263 @@
+package runreport
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// memoryRuns knows the runs in byID; the other methods are unused
+type memoryRuns struct {
+	repository.RunRepository
+	byID map[string]*models.Run
+}
+
+func (m memoryRuns) Get(ctx context.Context, id string) (*models.Run, error) {
+	return m.byID[id], nil
+}
+
+type memoryDeadLetters struct {
+	repository.DeadLetterRepository
+	entries []models.DeadLetter
+}
+
+func (m memoryDeadLetters) List(ctx context.Context, runID string, afterID uint64, limit int) ([]models.DeadLetter, error) {
+	var out []models.DeadLetter
+	for _, e := range m.entries {
+		if e.RunID == runID && e.ID > afterID && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// fakeSMTP accepts one message and records what it was sent
+type fakeSMTP struct {
+	addr string
+
+	mu   sync.Mutex
+	auth string
+	from string
+	to   []string
+	data string
+	done chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeSMTP{addr: ln.Addr().String(), done: make(chan struct{})}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		defer close(f.done)
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ready")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			f.mu.Lock()
+			switch strings.ToUpper(verb) {
+			case "EHLO":
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				f.auth = arg
+				tp.PrintfLine("235 accepted")
+			case "MAIL":
+				f.from = arg
+				tp.PrintfLine("250 ok")
+			case "RCPT":
+				f.to = append(f.to, arg)
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, _ := io.ReadAll(tp.DotReader())
+				f.data = string(data)
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				f.mu.Unlock()
+				return
+			default:
+				tp.PrintfLine("502 unknown")
+			}
+			f.mu.Unlock()
+		}
+	}()
+	return f
+}
+
+var testRun = &models.Run{
+	ID:         "run-1",
+	Source:     "/data/segmentations.csv",
+	Status:     models.RunSucceeded,
+	RowsRead:   10,
+	Inserted:   6,
+	Invalid:    3,
+	Failed:     1,
+	StartedAt:  time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC).Unix(),
+	FinishedAt: time.Date(2026, 10, 17, 9, 4, 12, 0, time.UTC).Unix(),
+}
+
+var testDeadLetters = memoryDeadLetters{entries: []models.DeadLetter{
+	{ID: 1, RunID: "run-1", RowNumber: 3, RawLine: "abc,drug,Aspirina,{}", Error: "invalid user_id"},
+	{ID: 2, RunID: "run-1", RowNumber: 5, RawLine: "1,,Aspirina,{}", Error: "segmentation_type is required"},
+	{ID: 3, RunID: "run-1", RowNumber: 8, RawLine: "x,drug,Dipirona,{}", Error: "invalid user_id"},
+	{ID: 4, RunID: "run-1", RowNumber: 9, RawLine: "2,drug,Dipirona,{}", Error: "Error 1205: lock wait timeout"},
+}}
+
+func TestMailer_Send(t *testing.T) {
+	srv := newFakeSMTP(t)
+	m, err := NewMailer(Config{
+		SMTP:           SMTP{Addr: srv.addr, Username: "reports", Password: "s3cret", TLS: TLSNone},
+		From:           "Segmentation <reports@example.com>",
+		To:             []string{"data-ops@example.com", "vendor@example.com"},
+		ErrorsURL:      "https://api.example.com/imports/{run_id}/errors?format=csv",
+		AttachMaxBytes: 1 << 20,
+	}, memoryRuns{byID: map[string]*models.Run{"run-1": testRun}}, testDeadLetters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Send(ctx, "run-1"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	<-srv.done
+
+	if auth, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(srv.auth, "PLAIN ")); string(auth) != "\x00reports\x00s3cret" {
+		t.Errorf("AUTH = %q", auth)
+	}
+	if srv.from != "FROM:<reports@example.com>" || len(srv.to) != 2 {
+		t.Errorf("envelope from %q to %q", srv.from, srv.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(srv.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Header.Get("Subject"), "Import succeeded with rejected rows: segmentations.csv (run run-1)"; got != want {
+		t.Errorf("Subject = %q, want %q", got, want)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+
+	part, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := io.ReadAll(quotedprintable.NewReader(part))
+	for _, want := range []string{
+		"Duration:   4m12s",
+		"Invalid:    3",
+		"2 × invalid user_id (first at row 3)",
+		"1 × segmentation_type is required (first at row 5)",
+		"https://api.example.com/imports/run-1/errors?format=csv",
+		"attached as dead_letters_run-1.csv",
+	} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("body should contain %q:\n%s", want, text)
+		}
+	}
+
+	part, err = parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if part.FileName() != "dead_letters_run-1.csv" {
+		t.Errorf("attachment = %q", part.FileName())
+	}
+	raw, _ := io.ReadAll(part)
+	csv, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(raw), "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := bufio.NewReader(strings.NewReader(string(csv))).ReadString('\n')
+	if header != "row_number,raw_line,error\n" || strings.Count(string(csv), "\n") != 5 {
+		t.Errorf("attachment:\n%s", csv)
+	}
+}
+
+func TestMailer_Skips(t *testing.T) {
+	clean := &models.Run{ID: "run-2", Source: "/data/a.csv", Status: models.RunSucceeded, RowsRead: 10, Inserted: 10}
+	failed := &models.Run{ID: "run-3", Source: "/data/a.csv", Status: models.RunFailed, Error: "db down"}
+	runs := memoryRuns{byID: map[string]*models.Run{"run-1": testRun, "run-2": clean, "run-3": failed}}
+
+	tests := []struct {
+		name         string
+		runID        string
+		onlyProblems bool
+		attachBytes  int
+		wantSent     bool
+		wantBody     string
+	}{
+		{name: "clean run", runID: "run-2", wantSent: true, wantBody: "The import run run-2 succeeded."},
+		{name: "clean run, only problems", runID: "run-2", onlyProblems: true},
+		{name: "failed run, only problems", runID: "run-3", onlyProblems: true, wantSent: true, wantBody: "Error: db down"},
+		{name: "unknown run", runID: "run-9"},
+		{name: "attachment too large", runID: "run-1", attachBytes: 40, wantSent: true, wantBody: "too many to attach"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMailer(Config{SMTP: SMTP{Addr: "smtp:25"}, From: "reports@example.com", To: []string{"ops@example.com"},
+				OnlyProblems: tt.onlyProblems, AttachMaxBytes: tt.attachBytes}, runs, testDeadLetters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sent []byte
+			m.send = func(ctx context.Context, cfg SMTP, from string, to []string, msg []byte) error {
+				sent = msg
+				return nil
+			}
+			if err := m.Send(context.Background(), tt.runID); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if (sent != nil) != tt.wantSent {
+				t.Fatalf("sent = %v, want %v", sent != nil, tt.wantSent)
+			}
+			if sent == nil {
+				return
+			}
+			body, _ := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(sent))))
+			if !strings.Contains(string(body), tt.wantBody) || strings.Contains(string(body), "filename=") {
+				t.Errorf("message should contain %q and no attachment:\n%s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestNewMailer_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{From: "reports@example.com", To: []string{"ops@example.com"}},
+		{SMTP: SMTP{Addr: "smtp:25"}, From: "not an address", To: []string{"ops@example.com"}},
+		{SMTP: SMTP{Addr: "smtp:25", TLS: "ssl"}, From: "reports@example.com", To: []string{"ops@example.com"}},
+	} {
+		if _, err := NewMailer(cfg, memoryRuns{}, memoryDeadLetters{}); err == nil {
+			t.Errorf("NewMailer(%+v) should fail", cfg)
+		}
+	}
+}
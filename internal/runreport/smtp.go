@@ -0,0 +1,91 @@
+package runreport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// TLS modes of the SMTP connection
+const (
+	// TLSStartTLS upgrades the connection with STARTTLS when the server
+	// offers it (usually port 587)
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects over TLS (usually port 465)
+	TLSImplicit = "tls"
+	// TLSNone sends in clear text, for a local relay
+	TLSNone = "none"
+)
+
+// SMTP is the server the reports are sent through. Username, when set,
+// authenticates with PLAIN, which net/smtp only allows over TLS or to
+// localhost.
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	TLS      string
+}
+
+// send delivers msg through the server of cfg. ctx bounds the whole
+// exchange.
+func send(ctx context.Context, cfg SMTP, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+	if cfg.TLS == TLSImplicit {
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if cfg.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
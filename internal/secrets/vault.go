@@ -0,0 +1,211 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/config"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	// retryInterval is the wait before trying again after a failed renewal
+	retryInterval = 10 * time.Second
+)
+
+// ErrNotFetched is returned by Credentials before the first Fetch
+var ErrNotFetched = errors.New("vault credentials not fetched")
+
+// Credentials are a database user and password
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Vault fetches the database credentials from HashiCorp Vault and keeps
+// them valid: the lease is renewed at two thirds of its duration, and new
+// credentials are fetched when it can no longer be renewed (max TTL
+// reached, lease revoked or secret not renewable)
+type Vault struct {
+	addr   string
+	token  string
+	path   string
+	client *http.Client
+
+	mu        sync.RWMutex
+	creds     Credentials
+	fetched   bool
+	leaseID   string
+	renewable bool
+	// granted is the duration of the lease when the credentials were
+	// fetched; remaining is the one of the last fetch or renewal
+	granted   time.Duration
+	remaining time.Duration
+}
+
+func NewVault(cfg config.Vault) *Vault {
+	return &Vault{
+		addr:   strings.TrimRight(cfg.Addr, "/"),
+		token:  cfg.Token,
+		path:   strings.Trim(cfg.DBPath, "/"),
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// secretResponse is the part of a Vault secret response used here
+type secretResponse struct {
+	LeaseID       string          `json:"lease_id"`
+	LeaseDuration int             `json:"lease_duration"`
+	Renewable     bool            `json:"renewable"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// credentialsData reads username and password from the data of the
+// database secrets engine, or from the nested data of KV v2
+type credentialsData struct {
+	Username string           `json:"username"`
+	Password string           `json:"password"`
+	Data     *credentialsData `json:"data"`
+}
+
+// Fetch reads new credentials from the configured path
+func (v *Vault) Fetch(ctx context.Context) error {
+	resp, err := v.do(ctx, http.MethodGet, v.path, nil)
+	if err != nil {
+		return err
+	}
+
+	var data credentialsData
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return fmt.Errorf("vault %s: invalid data: %w", v.path, err)
+	}
+	if data.Username == "" && data.Data != nil {
+		data = *data.Data
+	}
+	if data.Username == "" || data.Password == "" {
+		return fmt.Errorf("vault %s: username and password are required", v.path)
+	}
+
+	lease := time.Duration(resp.LeaseDuration) * time.Second
+	v.mu.Lock()
+	v.creds = Credentials{Username: data.Username, Password: data.Password}
+	v.fetched = true
+	v.leaseID = resp.LeaseID
+	v.renewable = resp.Renewable && resp.LeaseID != ""
+	v.granted = lease
+	v.remaining = lease
+	v.mu.Unlock()
+	return nil
+}
+
+// Credentials returns the current user and password; it has the signature
+// of mysql.CredentialsFunc so every new connection uses the latest ones
+func (v *Vault) Credentials(context.Context) (string, string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if !v.fetched {
+		return "", "", ErrNotFetched
+	}
+	return v.creds.Username, v.creds.Password, nil
+}
+
+// Run keeps the credentials valid until ctx is cancelled. It returns
+// right away when the secret has no lease (e.g. KV v2).
+func (v *Vault) Run(ctx context.Context, onError func(error)) {
+	wait := v.nextRefresh()
+	for wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		if err := v.refresh(ctx); err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			wait = retryInterval
+			continue
+		}
+		wait = v.nextRefresh()
+	}
+}
+
+// nextRefresh is two thirds of the remaining lease, or 0 without a lease
+func (v *Vault) nextRefresh() time.Duration {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.remaining * 2 / 3
+}
+
+// refresh renews the lease, or fetches new credentials when the lease is
+// not renewable, the renewal fails or the lease is close to its max TTL
+func (v *Vault) refresh(ctx context.Context) error {
+	v.mu.RLock()
+	leaseID, renewable, granted := v.leaseID, v.renewable, v.granted
+	v.mu.RUnlock()
+
+	if renewable {
+		resp, err := v.do(ctx, http.MethodPut, "sys/leases/renew", map[string]string{"lease_id": leaseID})
+		if err == nil {
+			remaining := time.Duration(resp.LeaseDuration) * time.Second
+			if remaining >= granted/3 {
+				v.mu.Lock()
+				v.remaining = remaining
+				v.mu.Unlock()
+				return nil
+			}
+		}
+	}
+	return v.Fetch(ctx)
+}
+
+func (v *Vault) do(ctx context.Context, method, path string, body any) (*secretResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+"/v1/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(res.Body).Decode(&e)
+		return nil, fmt.Errorf("vault %s: status %d: %s", path, res.StatusCode, strings.Join(e.Errors, "; "))
+	}
+
+	var resp secretResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("vault %s: invalid response: %w", path, err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,172 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/config"
+)
+
+// fakeVault serves dynamic credentials (user-1, user-2, ...) at
+// database/creds/app and renews their lease with renewTTL seconds
+type fakeVault struct {
+	fetches   int32
+	renewals  int32
+	renewTTL  int
+	failRenew bool
+}
+
+func (f *fakeVault) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/database/creds/app":
+			n := atomic.AddInt32(&f.fetches, 1)
+			fmt.Fprintf(w, `{"lease_id":"database/creds/app/%d","lease_duration":3600,"renewable":true,
+				"data":{"username":"user-%d","password":"pass-%d"}}`, n, n, n)
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/renew":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["lease_id"] == "" {
+				t.Errorf("renewal without lease_id")
+			}
+			atomic.AddInt32(&f.renewals, 1)
+			if f.failRenew {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors":["lease not found"]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"lease_id":%q,"lease_duration":%d,"renewable":true}`, body["lease_id"], f.renewTTL)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+		}
+	})
+}
+
+func newTestVault(t *testing.T, f *fakeVault, path string) *Vault {
+	srv := httptest.NewServer(f.handler(t))
+	t.Cleanup(srv.Close)
+	return NewVault(config.Vault{Addr: srv.URL + "/", Token: "root-token", DBPath: path})
+}
+
+func TestVault_Fetch(t *testing.T) {
+	v := newTestVault(t, &fakeVault{}, "/database/creds/app")
+
+	if _, _, err := v.Credentials(context.Background()); !errors.Is(err, ErrNotFetched) {
+		t.Fatalf("Credentials() before Fetch error = %v, want ErrNotFetched", err)
+	}
+
+	if err := v.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	user, pass, err := v.Credentials(context.Background())
+	if err != nil || user != "user-1" || pass != "pass-1" {
+		t.Errorf("Credentials() = %q, %q, %v", user, pass, err)
+	}
+	if got := v.nextRefresh(); got != 40*time.Minute {
+		t.Errorf("nextRefresh() = %s, want 40m", got)
+	}
+}
+
+func TestVault_FetchKV2(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"lease_duration":0,"data":{"data":{"username":"app","password":"s3cret"},"metadata":{"version":3}}}`)
+	}))
+	defer srv.Close()
+
+	v := NewVault(config.Vault{Addr: srv.URL, Token: "t", DBPath: "secret/data/app"})
+	if err := v.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if user, pass, _ := v.Credentials(context.Background()); user != "app" || pass != "s3cret" {
+		t.Errorf("Credentials() = %q, %q", user, pass)
+	}
+
+	// without a lease there is nothing to renew
+	done := make(chan struct{})
+	go func() {
+		v.Run(context.Background(), nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() should return when the secret has no lease")
+	}
+}
+
+func TestVault_FetchErrors(t *testing.T) {
+	v := newTestVault(t, &fakeVault{}, "database/creds/app")
+	v.token = "wrong"
+	err := v.Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Fetch() error = %v, want permission denied", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"username":"app"}}`)
+	}))
+	defer srv.Close()
+	v = NewVault(config.Vault{Addr: srv.URL, Token: "t", DBPath: "secret/app"})
+	if err := v.Fetch(context.Background()); err == nil {
+		t.Error("Fetch() should fail without a password")
+	}
+}
+
+func TestVault_RefreshRenewsLease(t *testing.T) {
+	f := &fakeVault{renewTTL: 3600}
+	v := newTestVault(t, f, "database/creds/app")
+	if err := v.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if f.renewals != 1 || f.fetches != 1 {
+		t.Errorf("renewals = %d, fetches = %d, want 1 and 1", f.renewals, f.fetches)
+	}
+	if user, _, _ := v.Credentials(context.Background()); user != "user-1" {
+		t.Errorf("a renewal should keep the credentials, got %q", user)
+	}
+}
+
+func TestVault_RefreshFetchesNewCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		f    *fakeVault
+	}{
+		{name: "renewal fails", f: &fakeVault{failRenew: true}},
+		{name: "max TTL reached", f: &fakeVault{renewTTL: 60}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestVault(t, tt.f, "database/creds/app")
+			if err := v.Fetch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := v.refresh(context.Background()); err != nil {
+				t.Fatalf("refresh() error = %v", err)
+			}
+			if user, _, _ := v.Credentials(context.Background()); user != "user-2" {
+				t.Errorf("Credentials() user = %q, want new credentials user-2", user)
+			}
+		})
+	}
+}
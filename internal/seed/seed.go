@@ -0,0 +1,165 @@
+// Package seed writes realistic fake segmentations for development, QA
+// and frontend environments that have no production CSV to import.
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// catalog lists the segmentation names of a type and builds their data
+type catalog struct {
+	segType string
+	names   []string
+	data    func(r *rand.Rand) map[string]any
+}
+
+var catalogs = []catalog{
+	{
+		segType: "drug",
+		names: []string{
+			"Amoxicilina 500mg", "Dipirona 1g", "Losartana 50mg", "Omeprazol 20mg",
+			"Metformina 850mg", "Sinvastatina 20mg", "Paracetamol 750mg", "Ibuprofeno 600mg",
+			"Azitromicina 500mg", "Enalapril 10mg", "Levotiroxina 50mcg", "Atenolol 25mg",
+			"Alopáticos", "Antibióticos", "Fitoterápicos", "Genéricos",
+		},
+		data: func(r *rand.Rand) map[string]any {
+			return map[string]any{"quantity": strconv.Itoa(10 * (1 + r.IntN(50)))}
+		},
+	},
+	{
+		segType: "specialty",
+		names: []string{
+			"Cardiologia", "Pediatria", "Dermatologia", "Ginecologia", "Ortopedia",
+			"Psiquiatria", "Neurologia", "Endocrinologia", "Clínica Geral", "Oftalmologia",
+		},
+		data: func(r *rand.Rand) map[string]any {
+			return map[string]any{"years": 1 + r.IntN(35)}
+		},
+	},
+	{
+		segType: "patient",
+		names: []string{
+			"Gestantes", "Idosos", "Crianças", "Hipertensos", "Diabéticos", "Crônicos",
+		},
+		data: func(r *rand.Rand) map[string]any {
+			return map[string]any{"count": 1 + r.IntN(500)}
+		},
+	},
+}
+
+// Result summarizes a seed run
+type Result struct {
+	Users    int
+	Inserted int
+	Updated  int
+	Failed   int
+}
+
+// Generator produces the fake segmentations of each user. It is
+// deterministic: the same config.Seed always yields the same items.
+type Generator struct {
+	cfg config.Seed
+	rnd *rand.Rand
+}
+
+func NewGenerator(cfg config.Seed) *Generator {
+	seed := uint64(cfg.RandomSeed)
+	return &Generator{cfg: cfg, rnd: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// User returns 1 to cfg.MaxPerUser segmentations for userID, never two
+// with the same type and name
+func (g *Generator) User(userID uint64) []service.BulkItem {
+	n := 1 + g.rnd.IntN(g.cfg.MaxPerUser)
+	items := make([]service.BulkItem, 0, n)
+	used := make(map[string]bool, n)
+
+	for len(items) < n && len(used) < catalogSize() {
+		c := catalogs[g.rnd.IntN(len(catalogs))]
+		name := c.names[g.rnd.IntN(len(c.names))]
+		if used[c.segType+"\x00"+name] {
+			continue
+		}
+		used[c.segType+"\x00"+name] = true
+
+		data, _ := json.Marshal(c.data(g.rnd))
+		items = append(items, service.BulkItem{
+			UserID: userID,
+			UpsertRequest: service.UpsertRequest{
+				SegmentationType: c.segType,
+				SegmentationName: name,
+				Data:             data,
+			},
+		})
+	}
+	return items
+}
+
+func catalogSize() int {
+	n := 0
+	for _, c := range catalogs {
+		n += len(c.names)
+	}
+	return n
+}
+
+// Run writes the segmentations of cfg.Users users through svc, so they go
+// through the same validation as the API and the processor. Items are sent
+// in batches of service.MaxBulkItems; rejected items are counted in
+// Result.Failed and logged, without stopping the run. Running it again
+// with the same config updates the same rows.
+func Run(ctx context.Context, svc *service.SegmentationService, cfg config.Seed, logger *zap.Logger) (*Result, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	gen := NewGenerator(cfg)
+	result := &Result{}
+	var batch []service.BulkItem
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		res, err := svc.BulkUpsert(ctx, service.BulkRequest{Items: batch})
+		if err != nil {
+			return err
+		}
+		result.Inserted += res.Inserted
+		result.Updated += res.Updated
+		result.Failed += res.Failed
+		for _, e := range res.Errors {
+			logger.Warn("seed_item_failed",
+				zap.Uint64("user_id", batch[e.Index].UserID),
+				zap.String("segmentation_type", batch[e.Index].SegmentationType),
+				zap.String("error", e.Error),
+			)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for i := 0; i < cfg.Users; i++ {
+		items := gen.User(uint64(cfg.FirstUserID + i))
+		if len(batch)+len(items) > service.MaxBulkItems {
+			if err := flush(); err != nil {
+				return result, fmt.Errorf("seed: %w", err)
+			}
+		}
+		batch = append(batch, items...)
+		result.Users++
+	}
+	if err := flush(); err != nil {
+		return result, fmt.Errorf("seed: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+)
+
+// memoryRepository keeps the upserted segmentations by user, type and name
+type memoryRepository struct {
+	rows map[string]models.Segmentation
+}
+
+func (m *memoryRepository) key(s *models.Segmentation) string {
+	return fmt.Sprintf("%d/%s/%s", s.UserID, s.SegmentationType, s.SegmentationName)
+}
+
+func (m *memoryRepository) FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+	return nil, nil
+}
+
+func (m *memoryRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
+func (m *memoryRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+	k := m.key(s)
+	_, found := m.rows[k]
+	m.rows[k] = *s
+	if found {
+		return repository.UpsertUpdated, nil
+	}
+	return repository.UpsertInserted, nil
+}
+
+func (m *memoryRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *memoryRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
+func TestGenerator_Deterministic(t *testing.T) {
+	cfg := config.Seed{Users: 10, MaxPerUser: 8, FirstUserID: 1, RandomSeed: 42}
+
+	a, b := NewGenerator(cfg), NewGenerator(cfg)
+	for id := uint64(1); id <= 10; id++ {
+		itemsA, itemsB := a.User(id), b.User(id)
+		if !reflect.DeepEqual(itemsA, itemsB) {
+			t.Fatalf("user %d: the same seed should produce the same items", id)
+		}
+		if len(itemsA) < 1 || len(itemsA) > cfg.MaxPerUser {
+			t.Errorf("user %d: %d items, want 1 to %d", id, len(itemsA), cfg.MaxPerUser)
+		}
+
+		seen := map[string]bool{}
+		for _, item := range itemsA {
+			k := item.SegmentationType + "/" + item.SegmentationName
+			if seen[k] {
+				t.Errorf("user %d: duplicated %s", id, k)
+			}
+			seen[k] = true
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	repo := &memoryRepository{rows: map[string]models.Segmentation{}}
+	svc := service.NewSegmentationService(repo)
+	cfg := config.Seed{Users: 600, MaxPerUser: 5, FirstUserID: 100, RandomSeed: 7}
+
+	result, err := Run(context.Background(), svc, cfg, nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Users != 600 || result.Failed != 0 || result.Inserted != len(repo.rows) {
+		t.Errorf("unexpected result %+v with %d rows", result, len(repo.rows))
+	}
+	if result.Inserted <= service.MaxBulkItems {
+		t.Fatalf("the test should span more than one batch, got %d items", result.Inserted)
+	}
+
+	// a second run with the same seed updates the same rows
+	again, err := Run(context.Background(), svc, cfg, nil)
+	if err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if again.Inserted != 0 || again.Updated != result.Inserted {
+		t.Errorf("second run = %+v, want only updates", again)
+	}
+}
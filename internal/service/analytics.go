@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// ErrInvalidAnalytics is returned for an analytics query with invalid
+// parameters
+var ErrInvalidAnalytics = apperrors.New("invalid analytics query", apperrors.ErrValidation)
+
+const (
+	// maxOverlapSegments is how many segments an overlap query compares
+	maxOverlapSegments = 10
+	// maxSegmentName is the size of segmentations.segmentation_name
+	maxSegmentName = 100
+)
+
+// DefaultHistogramBounds are the upper bounds of the buckets of the user
+// histogram; users with more segmentations than the last fall in a final,
+// unbounded bucket
+var DefaultHistogramBounds = []int64{1, 2, 5, 10, 20, 50, 100}
+
+// TopSegments are the largest segments of each type
+type TopSegments struct {
+	// Types maps each segmentation type to its segments, largest first
+	Types map[string][]SegmentSize `json:"types"`
+	// GeneratedAt is when the aggregate was computed, which may be up to
+	// the cache TTL ago
+	GeneratedAt int64 `json:"generated_at"`
+}
+
+// SegmentSize is a segment and how many users are in it
+type SegmentSize struct {
+	Name  string `json:"name" example:"Dipirona"`
+	Users int64  `json:"users"`
+}
+
+// UserHistogram is how the users spread over the number of segmentations
+// they have, of one type or of all
+type UserHistogram struct {
+	SegmentationType string `json:"segmentation_type,omitempty"`
+	// Users counts the users with at least one segmentation
+	Users         int64             `json:"users"`
+	Segmentations int64             `json:"segmentations"`
+	Buckets       []HistogramBucket `json:"buckets"`
+	GeneratedAt   int64             `json:"generated_at"`
+}
+
+// HistogramBucket counts the users with between Min and Max segmentations,
+// both inclusive; Max is omitted in the last bucket, which has no bound
+type HistogramBucket struct {
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max,omitempty"`
+	Users int64 `json:"users"`
+}
+
+// Growth is the size of each type over time, from the daily snapshots
+type Growth struct {
+	// Types maps each segmentation type to its points, oldest first
+	Types       map[string][]GrowthPoint `json:"types"`
+	GeneratedAt int64                    `json:"generated_at"`
+}
+
+// GrowthPoint is the size of a type on a day. The changes are relative to
+// the previous snapshot of the type, zero on the first one.
+type GrowthPoint struct {
+	Day                 string `json:"day" example:"2026-10-17"`
+	Segmentations       int64  `json:"segmentations"`
+	Users               int64  `json:"users"`
+	Segments            int64  `json:"segments"`
+	SegmentationsChange int64  `json:"segmentations_change"`
+	UsersChange         int64  `json:"users_change"`
+}
+
+// OverlapSegment is a segment of an overlap query and how many users are
+// in it
+type OverlapSegment struct {
+	Type  string `json:"type" example:"specialty"`
+	Name  string `json:"name" example:"Cardiologia"`
+	Users int64  `json:"users"`
+}
+
+// Overlap is how many users two or more segments share
+type Overlap struct {
+	Segments []OverlapSegment `json:"segments"`
+	// Intersection counts the users in every segment
+	Intersection int64 `json:"intersection"`
+	// Union counts the users in at least one segment
+	Union int64 `json:"union"`
+	// Jaccard is Intersection over Union, 0 when both are
+	Jaccard float64 `json:"jaccard"`
+	// Sampled tells Intersection and Union are estimated from the
+	// SampleRate of the users; the segment sizes are always exact
+	Sampled     bool    `json:"sampled"`
+	SampleRate  float64 `json:"sample_rate,omitempty"`
+	GeneratedAt int64   `json:"generated_at"`
+}
+
+// Analytics serves the aggregates of the internal dashboard: the largest
+// segments, the user histograms and the growth of each type. The
+// aggregates scan the whole table, so each is computed once per ttl and
+// served from memory in between; concurrent requests for an expired one
+// wait for a single query.
+type Analytics struct {
+	repo repository.AnalyticsRepository
+	ttl  time.Duration
+	// sampleThreshold is the segment size above which overlaps are
+	// sampled; 0 never samples
+	sampleThreshold int64
+
+	mu      sync.Mutex
+	entries map[string]*analyticsEntry
+	now     func() time.Time
+}
+
+type analyticsEntry struct {
+	done    chan struct{} // closed once value and err are set
+	value   any
+	err     error
+	expires time.Time
+}
+
+// AnalyticsOption customizes Analytics
+type AnalyticsOption func(*Analytics)
+
+// WithOverlapSampling estimates the overlaps of segments larger than
+// threshold users from a sample of the users, sized so the largest
+// segment contributes about threshold of them; 0 always counts them all
+func WithOverlapSampling(threshold int64) AnalyticsOption {
+	return func(a *Analytics) {
+		a.sampleThreshold = threshold
+	}
+}
+
+// NewAnalytics computes the aggregates with repo and keeps each for ttl
+func NewAnalytics(repo repository.AnalyticsRepository, ttl time.Duration, opts ...AnalyticsOption) *Analytics {
+	a := &Analytics{
+		repo:    repo,
+		ttl:     ttl,
+		entries: make(map[string]*analyticsEntry),
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// TopSegments returns the limit segments with the most users of each
+// type, or of segType only when given
+func (a *Analytics) TopSegments(ctx context.Context, segType string, limit int) (*TopSegments, error) {
+	segType = analyticsType(segType)
+	v, err := a.cached(ctx, fmt.Sprintf("top:%s:%d", segType, limit), func(ctx context.Context) (any, error) {
+		counts, err := a.repo.TopSegments(ctx, segType, limit)
+		if err != nil {
+			return nil, err
+		}
+		top := &TopSegments{Types: make(map[string][]SegmentSize), GeneratedAt: a.now().Unix()}
+		for _, c := range counts {
+			top.Types[c.SegmentationType] = append(top.Types[c.SegmentationType], SegmentSize{Name: c.SegmentationName, Users: c.Users})
+		}
+		return top, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*TopSegments), nil
+}
+
+// UserHistogram returns how many users have how many segmentations, of
+// segType only when given, in buckets up to each of bounds (ascending;
+// DefaultHistogramBounds when empty)
+func (a *Analytics) UserHistogram(ctx context.Context, segType string, bounds []int64) (*UserHistogram, error) {
+	if len(bounds) == 0 {
+		bounds = DefaultHistogramBounds
+	}
+	for i, b := range bounds {
+		if b < 1 || (i > 0 && b <= bounds[i-1]) {
+			return nil, fmt.Errorf("%w: buckets must be ascending positive integers", ErrInvalidAnalytics)
+		}
+	}
+
+	segType = analyticsType(segType)
+	v, err := a.cached(ctx, fmt.Sprintf("users:%s:%v", segType, bounds), func(ctx context.Context) (any, error) {
+		counts, err := a.repo.UserCounts(ctx, segType)
+		if err != nil {
+			return nil, err
+		}
+		return histogram(segType, counts, bounds, a.now().Unix()), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*UserHistogram), nil
+}
+
+// histogram puts the user counts into the buckets of bounds
+func histogram(segType string, counts []repository.UserCount, bounds []int64, now int64) *UserHistogram {
+	h := &UserHistogram{
+		SegmentationType: segType,
+		Buckets:          make([]HistogramBucket, len(bounds)+1),
+		GeneratedAt:      now,
+	}
+	lo := int64(1)
+	for i, b := range bounds {
+		h.Buckets[i] = HistogramBucket{Min: lo, Max: b}
+		lo = b + 1
+	}
+	h.Buckets[len(bounds)] = HistogramBucket{Min: lo}
+
+	for _, c := range counts {
+		h.Users += c.Users
+		h.Segmentations += c.Segmentations * c.Users
+		i := 0
+		for i < len(bounds) && c.Segmentations > bounds[i] {
+			i++
+		}
+		h.Buckets[i].Users += c.Users
+	}
+	return h
+}
+
+// Growth returns the daily size of each type, or of segType only when
+// given, between the days from and to (YYYY-MM-DD, inclusive; empty for
+// no bound)
+func (a *Analytics) Growth(ctx context.Context, segType, from, to string) (*Growth, error) {
+	for _, day := range []string{from, to} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, day); err != nil {
+			return nil, fmt.Errorf("%w: %q is not a day (YYYY-MM-DD)", ErrInvalidAnalytics, day)
+		}
+	}
+	if from != "" && to != "" && from > to {
+		return nil, fmt.Errorf("%w: from is after to", ErrInvalidAnalytics)
+	}
+
+	segType = analyticsType(segType)
+	v, err := a.cached(ctx, "growth:"+segType+":"+from+":"+to, func(ctx context.Context) (any, error) {
+		snapshots, err := a.repo.Snapshots(ctx, repository.SnapshotFilter{Type: segType, From: from, To: to})
+		if err != nil {
+			return nil, err
+		}
+		return growth(snapshots, a.now().Unix()), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Growth), nil
+}
+
+// growth turns the snapshots, in order of day, into a series per type
+func growth(snapshots []models.SegmentSnapshot, now int64) *Growth {
+	g := &Growth{Types: make(map[string][]GrowthPoint), GeneratedAt: now}
+	for _, s := range snapshots {
+		points := g.Types[s.SegmentationType]
+		p := GrowthPoint{Day: s.Day, Segmentations: s.Segmentations, Users: s.Users, Segments: s.Segments}
+		if len(points) > 0 {
+			prev := points[len(points)-1]
+			p.SegmentationsChange = s.Segmentations - prev.Segmentations
+			p.UsersChange = s.Users - prev.Users
+		}
+		g.Types[s.SegmentationType] = append(points, p)
+	}
+	return g
+}
+
+// Overlap returns how many users the segments share (Intersection) and
+// how many are in any of them (Union), for 2 to 10 segments given by type
+// (singular or group key) and name. When the largest segment has more
+// users than the sampling threshold, both are estimated from the same
+// sample of users in every segment and scaled up, kept within the bounds
+// the exact sizes allow.
+func (a *Analytics) Overlap(ctx context.Context, segments []OverlapSegment) (*Overlap, error) {
+	if len(segments) < 2 || len(segments) > maxOverlapSegments {
+		return nil, fmt.Errorf("%w: overlap takes 2 to %d segments", ErrInvalidAnalytics, maxOverlapSegments)
+	}
+	query := make([]repository.Segment, len(segments))
+	seen := make(map[repository.Segment]bool, len(segments))
+	for i, s := range segments {
+		seg := repository.Segment{Type: analyticsType(s.Type), Name: nfc(strings.TrimSpace(s.Name))}
+		if seg.Type == "" || len(seg.Type) > maxTypeLength || seg.Name == "" || len(seg.Name) > maxSegmentName {
+			return nil, fmt.Errorf("%w: segment %d needs a type and a name of at most %d bytes", ErrInvalidAnalytics, i+1, maxSegmentName)
+		}
+		if seen[seg] {
+			return nil, fmt.Errorf("%w: segment %s:%s is repeated", ErrInvalidAnalytics, seg.Type, seg.Name)
+		}
+		seen[seg] = true
+		query[i] = seg
+	}
+
+	var key strings.Builder
+	key.WriteString("overlap")
+	for _, seg := range query {
+		key.WriteString("\x00" + seg.Type + "\x00" + seg.Name)
+	}
+	v, err := a.cached(ctx, key.String(), func(ctx context.Context) (any, error) {
+		return a.overlap(ctx, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Overlap), nil
+}
+
+// overlap computes the overlap of segments, sampling the users when the
+// largest segment is above the threshold
+func (a *Analytics) overlap(ctx context.Context, segments []repository.Segment) (*Overlap, error) {
+	sizes, err := a.repo.SegmentSizes(ctx, segments)
+	if err != nil {
+		return nil, err
+	}
+	o := &Overlap{Segments: make([]OverlapSegment, len(segments)), GeneratedAt: a.now().Unix()}
+	smallest, largest, total := sizes[0], int64(0), int64(0)
+	for i, seg := range segments {
+		o.Segments[i] = OverlapSegment{Type: seg.Type, Name: seg.Name, Users: sizes[i]}
+		smallest, largest, total = min(smallest, sizes[i]), max(largest, sizes[i]), total+sizes[i]
+	}
+
+	permille := 0
+	if a.sampleThreshold > 0 && largest > a.sampleThreshold {
+		permille = int(max(1, a.sampleThreshold*1000/largest))
+	}
+	intersection, union, err := a.repo.Overlap(ctx, segments, permille)
+	if err != nil {
+		return nil, err
+	}
+	if permille > 0 {
+		o.Sampled, o.SampleRate = true, float64(permille)/1000
+		intersection = min(smallest, intersection*1000/int64(permille))
+		union = min(total, max(largest, union*1000/int64(permille)))
+	}
+	o.Intersection, o.Union = intersection, union
+	if union > 0 {
+		o.Jaccard = float64(intersection) / float64(union)
+	}
+	return o, nil
+}
+
+// TakeSnapshot records the size of every type today (UTC), replacing the
+// earlier snapshot of the day, and returns how many types it recorded
+func (a *Analytics) TakeSnapshot(ctx context.Context) (int64, error) {
+	now := a.now().UTC()
+	return a.repo.TakeSnapshot(ctx, now.Format(time.DateOnly), now.Unix())
+}
+
+// cached returns the value of key, computing it with load when missing or
+// expired. Errors are not kept. The query runs to the end even when the
+// request that started it goes away, since others may be waiting for it.
+func (a *Analytics) cached(ctx context.Context, key string, load func(context.Context) (any, error)) (any, error) {
+	a.mu.Lock()
+	e, ok := a.entries[key]
+	if !ok || a.expired(e) {
+		e = &analyticsEntry{done: make(chan struct{})}
+		a.prune()
+		a.entries[key] = e
+		a.mu.Unlock()
+
+		value, err := load(context.WithoutCancel(ctx))
+		a.mu.Lock()
+		e.value, e.err, e.expires = value, err, a.now().Add(a.ttl)
+		if err != nil {
+			delete(a.entries, key)
+		}
+		a.mu.Unlock()
+		close(e.done)
+		return value, err
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return e.value, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// expired reports whether e was computed and is past its ttl; a.mu must
+// be held
+func (a *Analytics) expired(e *analyticsEntry) bool {
+	select {
+	case <-e.done:
+		return !a.now().Before(e.expires)
+	default:
+		return false
+	}
+}
+
+// prune drops the expired entries, so keys no longer asked for do not
+// pile up; a.mu must be held
+func (a *Analytics) prune() {
+	for key, e := range a.entries {
+		if a.expired(e) {
+			delete(a.entries, key)
+		}
+	}
+}
+
+// analyticsType returns the stored form of a type given singular or as
+// its group key
+func analyticsType(segType string) string {
+	return strings.ToLower(denormalizeType(nfc(strings.TrimSpace(segType))))
+}
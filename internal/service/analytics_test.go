@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// countingAnalytics answers fixed aggregates and counts the queries
+type countingAnalytics struct {
+	queries   atomic.Int32
+	release   chan struct{} // when set, queries wait for it
+	snapshots []models.SegmentSnapshot
+	taken     string
+	// sizes are the segment sizes by name; the overlap answers 40 and 200
+	// users of the sample
+	sizes    map[string]int64
+	permille int
+}
+
+func (r *countingAnalytics) TopSegments(ctx context.Context, segType string, limit int) ([]repository.SegmentCount, error) {
+	r.queries.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return []repository.SegmentCount{
+		{SegmentationType: "drug", SegmentationName: "Dipirona", Users: 30},
+		{SegmentationType: "drug", SegmentationName: "Losartana", Users: 20},
+		{SegmentationType: "specialty", SegmentationName: "Cardiologia", Users: 5},
+	}, nil
+}
+
+func (r *countingAnalytics) UserCounts(ctx context.Context, segType string) ([]repository.UserCount, error) {
+	r.queries.Add(1)
+	return []repository.UserCount{
+		{Segmentations: 1, Users: 40},
+		{Segmentations: 2, Users: 10},
+		{Segmentations: 3, Users: 5},
+		{Segmentations: 7, Users: 2},
+		{Segmentations: 250, Users: 1},
+	}, nil
+}
+
+func (r *countingAnalytics) TakeSnapshot(ctx context.Context, day string, takenAt int64) (int64, error) {
+	r.taken = day
+	return 2, nil
+}
+
+func (r *countingAnalytics) Snapshots(ctx context.Context, filter repository.SnapshotFilter) ([]models.SegmentSnapshot, error) {
+	r.queries.Add(1)
+	return r.snapshots, nil
+}
+
+func (r *countingAnalytics) SegmentSizes(ctx context.Context, segments []repository.Segment) ([]int64, error) {
+	sizes := make([]int64, len(segments))
+	for i, s := range segments {
+		sizes[i] = r.sizes[s.Name]
+	}
+	return sizes, nil
+}
+
+func (r *countingAnalytics) Overlap(ctx context.Context, segments []repository.Segment, samplePermille int) (int64, int64, error) {
+	r.queries.Add(1)
+	r.permille = samplePermille
+	return 40, 200, nil
+}
+
+func TestAnalytics_TopSegments(t *testing.T) {
+	repo := &countingAnalytics{}
+	a := NewAnalytics(repo, time.Minute)
+	now := time.Unix(1767229200, 0)
+	a.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	top, err := a.TopSegments(ctx, "", 10)
+	if err != nil {
+		t.Fatalf("TopSegments() error = %v", err)
+	}
+	if drugs := top.Types["drug"]; len(drugs) != 2 || drugs[0].Name != "Dipirona" || drugs[0].Users != 30 || len(top.Types["specialty"]) != 1 {
+		t.Errorf("unexpected top segments %+v", top.Types)
+	}
+
+	// served from memory until the TTL passes; another limit or type is
+	// another aggregate
+	a.TopSegments(ctx, "", 10)
+	if n := repo.queries.Load(); n != 1 {
+		t.Errorf("%d queries, want 1 while cached", n)
+	}
+	a.TopSegments(ctx, "drugs", 10)
+	a.TopSegments(ctx, "drug", 10)
+	if n := repo.queries.Load(); n != 2 {
+		t.Errorf("%d queries, want drugs and drug to share one", n)
+	}
+	now = now.Add(time.Minute)
+	if top, _ := a.TopSegments(ctx, "", 10); repo.queries.Load() != 3 || top.GeneratedAt != now.Unix() {
+		t.Errorf("an expired aggregate should be computed again, got %d queries", repo.queries.Load())
+	}
+}
+
+func TestAnalytics_SingleQuery(t *testing.T) {
+	repo := &countingAnalytics{release: make(chan struct{})}
+	a := NewAnalytics(repo, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := a.TopSegments(context.Background(), "", 10); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for repo.queries.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(repo.release)
+	wg.Wait()
+	if n := repo.queries.Load(); n != 1 {
+		t.Errorf("%d queries, want concurrent requests to share one", n)
+	}
+}
+
+func TestAnalytics_UserHistogram(t *testing.T) {
+	a := NewAnalytics(&countingAnalytics{}, time.Minute)
+
+	h, err := a.UserHistogram(context.Background(), "", nil)
+	if err != nil {
+		t.Fatalf("UserHistogram() error = %v", err)
+	}
+	if h.Users != 58 || h.Segmentations != 40+20+15+14+250 || len(h.Buckets) != len(DefaultHistogramBounds)+1 {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+	want := []HistogramBucket{{1, 1, 40}, {2, 2, 10}, {3, 5, 5}, {6, 10, 2}, {11, 20, 0}, {21, 50, 0}, {51, 100, 0}, {101, 0, 1}}
+	for i, b := range want {
+		if h.Buckets[i] != b {
+			t.Errorf("bucket %d = %+v, want %+v", i, h.Buckets[i], b)
+		}
+	}
+
+	h, err = a.UserHistogram(context.Background(), "Drugs", []int64{2, 10})
+	if err != nil || h.SegmentationType != "drug" || len(h.Buckets) != 3 || h.Buckets[0].Users != 50 || h.Buckets[2] != (HistogramBucket{11, 0, 1}) {
+		t.Errorf("UserHistogram() with bounds = %+v, %v", h, err)
+	}
+
+	for _, bounds := range [][]int64{{0}, {5, 5}, {10, 2}} {
+		if _, err := a.UserHistogram(context.Background(), "", bounds); !errors.Is(err, ErrInvalidAnalytics) {
+			t.Errorf("UserHistogram(%v) error = %v, want ErrInvalidAnalytics", bounds, err)
+		}
+	}
+}
+
+func TestAnalytics_Growth(t *testing.T) {
+	repo := &countingAnalytics{snapshots: []models.SegmentSnapshot{
+		{Day: "2026-10-15", SegmentationType: "drug", Segmentations: 100, Users: 40, Segments: 10},
+		{Day: "2026-10-15", SegmentationType: "specialty", Segmentations: 8, Users: 8, Segments: 2},
+		{Day: "2026-10-16", SegmentationType: "drug", Segmentations: 130, Users: 45, Segments: 11},
+	}}
+	a := NewAnalytics(repo, time.Minute)
+
+	g, err := a.Growth(context.Background(), "", "2026-10-01", "")
+	if err != nil {
+		t.Fatalf("Growth() error = %v", err)
+	}
+	drugs := g.Types["drug"]
+	if len(drugs) != 2 || drugs[0].UsersChange != 0 || drugs[1].SegmentationsChange != 30 || drugs[1].UsersChange != 5 || len(g.Types["specialty"]) != 1 {
+		t.Errorf("unexpected growth %+v", g.Types)
+	}
+
+	for _, tt := range [][2]string{{"yesterday", ""}, {"", "2026-13-01"}, {"2026-10-16", "2026-10-15"}} {
+		if _, err := a.Growth(context.Background(), "", tt[0], tt[1]); !errors.Is(err, ErrInvalidAnalytics) {
+			t.Errorf("Growth(%q, %q) error = %v, want ErrInvalidAnalytics", tt[0], tt[1], err)
+		}
+	}
+
+	if n, err := a.TakeSnapshot(context.Background()); err != nil || n != 2 || len(repo.taken) != len("2026-10-17") {
+		t.Errorf("TakeSnapshot() = %d, %v (day %q)", n, err, repo.taken)
+	}
+}
+
+func TestAnalytics_Overlap(t *testing.T) {
+	repo := &countingAnalytics{sizes: map[string]int64{"Cardiologia": 100, "Alopáticos": 150, "Dipirona": 4_000_000}}
+	a := NewAnalytics(repo, time.Minute, WithOverlapSampling(1_000_000))
+	ctx := context.Background()
+
+	o, err := a.Overlap(ctx, []OverlapSegment{{Type: "specialties", Name: " Cardiologia "}, {Type: "Drug", Name: "Alopáticos"}})
+	if err != nil {
+		t.Fatalf("Overlap() error = %v", err)
+	}
+	if o.Sampled || repo.permille != 0 || o.Intersection != 40 || o.Union != 200 || o.Jaccard != 0.2 {
+		t.Errorf("unexpected overlap %+v", o)
+	}
+	if o.Segments[0] != (OverlapSegment{Type: "specialty", Name: "Cardiologia", Users: 100}) || o.Segments[1].Users != 150 {
+		t.Errorf("unexpected segments %+v", o.Segments)
+	}
+
+	// the largest segment has 4x the threshold: a quarter of the users,
+	// scaled back and kept within the exact sizes
+	o, err = a.Overlap(ctx, []OverlapSegment{{Type: "drug", Name: "Dipirona"}, {Type: "drug", Name: "Alopáticos"}})
+	if err != nil {
+		t.Fatalf("Overlap() error = %v", err)
+	}
+	if !o.Sampled || o.SampleRate != 0.25 || repo.permille != 250 || o.Intersection != 150 || o.Union != 4_000_000 {
+		t.Errorf("unexpected sampled overlap %+v (permille %d)", o, repo.permille)
+	}
+
+	for _, segments := range [][]OverlapSegment{
+		{{Type: "drug", Name: "Dipirona"}},
+		{{Type: "drug", Name: "Dipirona"}, {Type: "drug", Name: ""}},
+		{{Type: "drug", Name: "Dipirona"}, {Type: "drugs", Name: "Dipirona"}},
+		make([]OverlapSegment, 11),
+	} {
+		if _, err := a.Overlap(ctx, segments); !errors.Is(err, ErrInvalidAnalytics) {
+			t.Errorf("Overlap(%v) error = %v, want ErrInvalidAnalytics", segments, err)
+		}
+	}
+}
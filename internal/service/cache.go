@@ -0,0 +1,122 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// UserCache is a bounded LRU of GetByUserID responses, each kept for at
+// most ttl. Writes through the service invalidate the user; writes made
+// elsewhere (the processor, another API replica) show up once the entry
+// expires.
+type UserCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // front is the most recently used
+	entries map[uint64]*list.Element
+	// gen counts invalidations, so a read that started before a write
+	// does not store what it read after the write was done
+	gen uint64
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	userID  uint64
+	resp    *SegmentationResponse
+	expires time.Time
+}
+
+// NewUserCache creates a cache of up to size users kept for ttl
+func NewUserCache(size int, ttl time.Duration) *UserCache {
+	return &UserCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[uint64]*list.Element, size),
+		now:     time.Now,
+	}
+}
+
+// Len is the number of cached users, expired entries included
+func (c *UserCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// SetTTL changes how long responses cached from now on are kept; entries
+// already cached keep their expiry
+func (c *UserCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+// Flush drops every cached response and returns how many there were
+func (c *UserCache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	n := c.order.Len()
+	c.order.Init()
+	clear(c.entries)
+	return n
+}
+
+// Invalidate drops the cached response of userID
+func (c *UserCache) Invalidate(userID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if el, ok := c.entries[userID]; ok {
+		c.remove(el)
+	}
+}
+
+// get returns a copy of the cached response of userID, so callers can set
+// fields such as Labels without changing the cache; the generation is
+// passed back to add after a miss
+func (c *UserCache) get(userID uint64) (*SegmentationResponse, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[userID]
+	if !ok {
+		return nil, c.gen, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.now().After(entry.expires) {
+		c.remove(el)
+		return nil, c.gen, false
+	}
+	c.order.MoveToFront(el)
+	resp := *entry.resp
+	return &resp, c.gen, true
+}
+
+// add stores resp unless a user was invalidated since gen, evicting the
+// least recently used user when full
+func (c *UserCache) add(userID uint64, resp *SegmentationResponse, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	stored := *resp
+	entry := &cacheEntry{userID: userID, resp: &stored, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[userID]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[userID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *UserCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).userID)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"gorm.io/datatypes"
+)
+
+func TestUserCache_LRU(t *testing.T) {
+	c := NewUserCache(2, time.Minute)
+	resp := func(id uint64) *SegmentationResponse { return &SegmentationResponse{UserID: id} }
+
+	_, gen, _ := c.get(1)
+	c.add(1, resp(1), gen)
+	c.add(2, resp(2), gen)
+	c.get(1) // 2 becomes the least recently used
+	c.add(3, resp(3), gen)
+
+	if _, _, ok := c.get(2); ok {
+		t.Error("user 2 should have been evicted")
+	}
+	for _, id := range []uint64{1, 3} {
+		if got, _, ok := c.get(id); !ok || got.UserID != id {
+			t.Errorf("get(%d) = %v, %v", id, got, ok)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+}
+
+func TestUserCache_TTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewUserCache(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, gen, _ := c.get(1)
+	c.add(1, &SegmentationResponse{UserID: 1}, gen)
+
+	now = now.Add(time.Minute)
+	if _, _, ok := c.get(1); !ok {
+		t.Error("entry should live for the whole ttl")
+	}
+	now = now.Add(time.Second)
+	if _, _, ok := c.get(1); ok || c.Len() != 0 {
+		t.Errorf("expired entry: ok = %v, Len() = %d", ok, c.Len())
+	}
+}
+
+func TestUserCache_SetTTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewUserCache(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, gen, _ := c.get(1)
+	c.add(1, &SegmentationResponse{UserID: 1}, gen)
+	c.SetTTL(time.Second)
+	c.add(2, &SegmentationResponse{UserID: 2}, gen)
+
+	now = now.Add(2 * time.Second)
+	if _, _, ok := c.get(1); !ok {
+		t.Error("entry cached before SetTTL should keep its expiry")
+	}
+	if _, _, ok := c.get(2); ok {
+		t.Error("entry cached after SetTTL should use the new ttl")
+	}
+}
+
+func TestUserCache_Flush(t *testing.T) {
+	c := NewUserCache(10, time.Minute)
+
+	_, gen, _ := c.get(1)
+	c.add(1, &SegmentationResponse{UserID: 1}, gen)
+	c.add(2, &SegmentationResponse{UserID: 2}, gen)
+	if n := c.Flush(); n != 2 || c.Len() != 0 {
+		t.Errorf("Flush() = %d, Len() = %d", n, c.Len())
+	}
+
+	// a read that started before the flush does not store what it read
+	c.add(1, &SegmentationResponse{UserID: 1}, gen)
+	if _, _, ok := c.get(1); ok {
+		t.Error("a stale read should not be cached after Flush")
+	}
+}
+
+func TestUserCache_Invalidate(t *testing.T) {
+	c := NewUserCache(10, time.Minute)
+
+	_, gen, _ := c.get(1)
+	c.add(1, &SegmentationResponse{UserID: 1}, gen)
+	c.Invalidate(1)
+	if _, _, ok := c.get(1); ok {
+		t.Error("invalidated user should miss")
+	}
+
+	// a read that started before a write must not store what it read
+	_, gen, _ = c.get(1)
+	c.Invalidate(1)
+	c.add(1, &SegmentationResponse{UserID: 1}, gen)
+	if _, _, ok := c.get(1); ok {
+		t.Error("a stale read should not be cached")
+	}
+}
+
+func TestUserCache_ReturnsCopies(t *testing.T) {
+	c := NewUserCache(10, time.Minute)
+
+	_, gen, _ := c.get(1)
+	c.add(1, &SegmentationResponse{UserID: 1}, gen)
+	got, _, _ := c.get(1)
+	got.Labels = map[string]string{"drugs": "Drugs"}
+
+	if again, _, _ := c.get(1); again.Labels != nil {
+		t.Error("changing a returned response should not change the cache")
+	}
+}
+
+func TestSegmentationServiceGetByUserID_Cache(t *testing.T) {
+	finds := 0
+	repo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			finds++
+			return []models.Segmentation{
+				{UserID: userID, SegmentationType: "drug", SegmentationName: "aspirin", Data: datatypes.JSON(`{}`)},
+			}, nil
+		},
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			return repository.UpsertUpdated, nil
+		},
+	}
+	svc := NewSegmentationService(repo, WithCache(NewUserCache(10, time.Minute)))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.GetByUserID(ctx, 42); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if finds != 1 {
+		t.Fatalf("repeated reads hit the repository %d times, want 1", finds)
+	}
+
+	req := UpsertRequest{SegmentationType: "drug", SegmentationName: "aspirin", Data: []byte(`{"dose":1}`)}
+	if _, _, err := svc.Upsert(ctx, 42, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetByUserID(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	if finds != 2 {
+		t.Errorf("a write should invalidate the user, finds = %d, want 2", finds)
+	}
+
+	if _, err := svc.Replace(ctx, 42, ReplaceRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	svc.GetByUserID(ctx, 42)
+	if finds != 4 { // one read inside Replace, one after it
+		t.Errorf("a replace should invalidate the user, finds = %d, want 4", finds)
+	}
+}
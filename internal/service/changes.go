@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/repository"
+)
+
+// ErrInvalidChangeCursor is returned for a cursor not produced by the feed
+var ErrInvalidChangeCursor = apperrors.New("invalid cursor", apperrors.ErrValidation)
+
+// ChangeItem is one row of the changes feed
+type ChangeItem struct {
+	UserID    uint64          `json:"user_id"`
+	Type      string          `json:"segmentation_type"`
+	Name      string          `json:"segmentation_name"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+	CreatedAt Timestamp       `json:"created_at" swaggertype:"string" format:"date-time"`
+	UpdatedAt Timestamp       `json:"updated_at" swaggertype:"string" format:"date-time"`
+}
+
+// ChangesPage is a page of the changes feed. NextCursor continues right
+// after the last item, or from the same position when the page is empty,
+// so consumers can poll it until new writes arrive.
+type ChangesPage struct {
+	Changes    []ChangeItem `json:"changes"`
+	NextCursor string       `json:"next_cursor"`
+	HasMore    bool         `json:"has_more"`
+}
+
+// ChangeFeed pages through the segmentations in the order they were last
+// written, for incremental syncs that would otherwise pull full dumps
+type ChangeFeed struct {
+	repo repository.ChangeRepository
+}
+
+// NewChangeFeed creates a feed reading from repo
+func NewChangeFeed(repo repository.ChangeRepository) *ChangeFeed {
+	return &ChangeFeed{repo: repo}
+}
+
+// After returns up to limit segmentations written after the cursor that
+// match filter. The cursor only records a position, so a consumer changing
+// its filter keeps its place in the feed.
+func (f *ChangeFeed) After(
+	ctx context.Context,
+	after repository.ChangeCursor,
+	filter repository.Filter,
+	limit int,
+) (*ChangesPage, error) {
+
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	// one extra row tells whether another page follows
+	records, err := f.repo.Changes(ctx, after, filter, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ChangesPage{Changes: make([]ChangeItem, 0, min(len(records), limit))}
+	if len(records) > limit {
+		records = records[:limit]
+		page.HasMore = true
+	}
+
+	next := after
+	for _, r := range records {
+		data := json.RawMessage(r.Data)
+		if len(data) == 0 {
+			data = json.RawMessage("null")
+		}
+		page.Changes = append(page.Changes, ChangeItem{
+			UserID:    r.UserID,
+			Type:      r.SegmentationType,
+			Name:      r.SegmentationName,
+			Data:      data,
+			CreatedAt: Timestamp(r.CreatedAt),
+			UpdatedAt: Timestamp(r.UpdatedAt),
+		})
+		next = repository.ChangeCursor{UpdatedAt: r.UpdatedAt, ID: r.ID}
+	}
+	page.NextCursor = EncodeChangeCursor(next)
+	return page, nil
+}
+
+// EncodeChangeCursor encodes c as the opaque cursor of the feed
+func EncodeChangeCursor(c repository.ChangeCursor) string {
+	raw := strconv.FormatInt(c.UpdatedAt, 10) + "." + strconv.FormatUint(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseChangeCursor decodes a cursor produced by EncodeChangeCursor
+func ParseChangeCursor(s string) (repository.ChangeCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return repository.ChangeCursor{}, ErrInvalidChangeCursor
+	}
+	updatedAt, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return repository.ChangeCursor{}, ErrInvalidChangeCursor
+	}
+	var c repository.ChangeCursor
+	if c.UpdatedAt, err = strconv.ParseInt(updatedAt, 10, 64); err != nil {
+		return repository.ChangeCursor{}, ErrInvalidChangeCursor
+	}
+	if c.ID, err = strconv.ParseUint(id, 10, 64); err != nil {
+		return repository.ChangeCursor{}, ErrInvalidChangeCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// fakeChanges holds rows already in (updated_at, id) order
+type fakeChanges []models.Segmentation
+
+func (f fakeChanges) Changes(ctx context.Context, after repository.ChangeCursor, filter repository.Filter, limit int) ([]models.Segmentation, error) {
+	var out []models.Segmentation
+	for _, s := range f {
+		if s.UpdatedAt > after.UpdatedAt || s.UpdatedAt == after.UpdatedAt && s.ID > after.ID {
+			out = append(out, s)
+		}
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func TestChangeFeed_After(t *testing.T) {
+	rows := fakeChanges{
+		{ID: 4, UserID: 1, UpdatedAt: 100},
+		{ID: 2, UserID: 2, UpdatedAt: 200},
+		{ID: 5, UserID: 3, UpdatedAt: 200},
+		{ID: 1, UserID: 4, UpdatedAt: 300, Data: []byte(`{"a":1}`)},
+	}
+	feed := NewChangeFeed(rows)
+	ctx := context.Background()
+
+	// pages of two rows, each one continuing from the previous cursor,
+	// including rows sharing an updated_at across the page boundary
+	var users []uint64
+	after := repository.ChangeCursor{UpdatedAt: 150}
+	for range 3 {
+		page, err := feed.After(ctx, after, nil, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Changes {
+			users = append(users, item.UserID)
+		}
+		if after, err = ParseChangeCursor(page.NextCursor); err != nil {
+			t.Fatal(err)
+		}
+		if !page.HasMore {
+			break
+		}
+	}
+	if len(users) != 3 || users[0] != 2 || users[1] != 3 || users[2] != 4 {
+		t.Errorf("users = %v, want [2 3 4]", users)
+	}
+	if after != (repository.ChangeCursor{UpdatedAt: 300, ID: 1}) {
+		t.Errorf("last cursor = %+v", after)
+	}
+
+	// an empty page keeps the cursor, so the consumer can poll with it
+	page, err := feed.After(ctx, after, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Changes) != 0 || page.HasMore || page.NextCursor != EncodeChangeCursor(after) {
+		t.Errorf("empty page = %+v", page)
+	}
+}
+
+func TestParseChangeCursor(t *testing.T) {
+	want := repository.ChangeCursor{UpdatedAt: 1767225600, ID: 42}
+	got, err := ParseChangeCursor(EncodeChangeCursor(want))
+	if err != nil || got != want {
+		t.Errorf("round trip = %+v, %v; want %+v", got, err, want)
+	}
+
+	for _, s := range []string{"", "!!", "MTIz", "YS5i"} {
+		if _, err := ParseChangeCursor(s); !errors.Is(err, ErrInvalidChangeCursor) {
+			t.Errorf("ParseChangeCursor(%q) error = %v, want ErrInvalidChangeCursor", s, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"segmentation-api/internal/apperrors"
+)
+
+// ErrDataTooComplex is returned, wrapped in ErrInvalidSegmentation, when
+// the data of a write is nested deeper, has more keys or longer strings
+// than the configured limits
+var ErrDataTooComplex = apperrors.New("data too complex", apperrors.ErrUnprocessable)
+
+// DataLimits bounds the shape of a data document so that a broken or
+// adversarial payload cannot store a document every later read pays for.
+// A zero field disables its limit.
+type DataLimits struct {
+	// MaxDepth is the deepest nesting of objects and arrays; the top-level
+	// object is depth 1
+	MaxDepth int
+	// MaxKeys is the number of object keys in the whole document, at any
+	// depth
+	MaxKeys int
+	// MaxStringBytes is the longest key or string value, in bytes
+	MaxStringBytes int
+}
+
+func (l DataLimits) enabled() bool {
+	return l.MaxDepth > 0 || l.MaxKeys > 0 || l.MaxStringBytes > 0
+}
+
+// check walks the tokens of data and returns an ErrDataTooComplex at the
+// first limit exceeded. The document is never fully decoded, so a deep or
+// wide payload is rejected without building it. Invalid JSON is left to the
+// structural validation and passes.
+func (l DataLimits) check(data []byte) error {
+	if !l.enabled() {
+		return nil
+	}
+
+	// stack has one frame per open object or array; key tells whether the
+	// next token of an object is a key
+	type frame struct{ object, key bool }
+	var stack []frame
+	keys := 0
+
+	// value marks the value of a key as read, so the next token of the
+	// object is a key again
+	value := func() {
+		if n := len(stack); n > 0 && stack[n-1].object {
+			stack[n-1].key = true
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			// io.EOF at the end of the document, or JSON the structural
+			// validation rejects
+			return nil
+		}
+
+		switch v := tok.(type) {
+		case json.Delim:
+			switch v {
+			case '{', '[':
+				value()
+				stack = append(stack, frame{object: v == '{', key: true})
+				if l.MaxDepth > 0 && len(stack) > l.MaxDepth {
+					return l.exceeded("nested more than %d levels", l.MaxDepth)
+				}
+			default:
+				stack = stack[:len(stack)-1]
+			}
+		case string:
+			if l.MaxStringBytes > 0 && len(v) > l.MaxStringBytes {
+				return l.exceeded("has a string of %d bytes, at most %d allowed", len(v), l.MaxStringBytes)
+			}
+			if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].key {
+				stack[n-1].key = false
+				keys++
+				if l.MaxKeys > 0 && keys > l.MaxKeys {
+					return l.exceeded("has more than %d keys", l.MaxKeys)
+				}
+				continue
+			}
+			value()
+		default:
+			value()
+		}
+	}
+}
+
+func (l DataLimits) exceeded(format string, args ...any) error {
+	return fmt.Errorf("%w: %w: data %s", ErrInvalidSegmentation, ErrDataTooComplex, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func TestDataLimits_Check(t *testing.T) {
+	limits := DataLimits{MaxDepth: 3, MaxKeys: 4, MaxStringBytes: 8}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "flat", data: `{"dose":"500mg","quantity":2}`},
+		{name: "at max depth", data: `{"a":{"b":[1,2]}}`},
+		{name: "too deep", data: `{"a":{"b":{"c":[1]}}}`, wantErr: true},
+		{name: "deep arrays", data: `[[[[1]]]]`, wantErr: true},
+		{name: "keys at every depth count", data: `{"a":{"b":1,"c":2},"d":{"e":3}}`, wantErr: true},
+		{name: "string values are not keys", data: `{"a":["x","y","z","w","v"]}`},
+		{name: "long value", data: `{"a":"123456789"}`, wantErr: true},
+		{name: "long key", data: `{"123456789":1}`, wantErr: true},
+		{name: "invalid JSON is left to the structural checks", data: `{"a":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limits.check([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check(%s) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+			if err != nil && (!errors.Is(err, ErrDataTooComplex) || !errors.Is(err, ErrInvalidSegmentation)) {
+				t.Errorf("unexpected error kind: %v", err)
+			}
+		})
+	}
+
+	if err := (DataLimits{}).check([]byte(`{"a":{"b":{"c":"` + strings.Repeat("x", 1<<16) + `"}}}`)); err != nil {
+		t.Errorf("zero limits should accept everything, got %v", err)
+	}
+}
+
+func TestValidate_DataLimits(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{}, WithValidationRules(ValidationRules{
+		Mode:       ValidationLenient,
+		DataLimits: DataLimits{MaxDepth: 2},
+	}))
+
+	seg := &models.Segmentation{SegmentationType: "drug", Data: datatypes.JSON(`{"a":{"b":{"c":1}}}`)}
+	_, err := svc.Validate(seg)
+	if !errors.Is(err, ErrDataTooComplex) {
+		t.Fatalf("over-nested data should fail even in lenient mode, got %v", err)
+	}
+	if apperrors.Status(err) != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422", apperrors.Status(err))
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"segmentation-api/internal/apperrors"
+
+	"go.uber.org/zap"
+)
+
+// ErrSegmentationNotFound is returned by Delete when the user has no
+// segmentation with the given type and name
+var ErrSegmentationNotFound = apperrors.New("segmentation not found", apperrors.ErrNotFound)
+
+// Delete removes one segmentation of a user. segType may be given singular
+// ("drug") or as its group key ("drugs") and is resolved against the
+// user's rows, and name is matched like writes match it, through the name
+// policy, so any spelling that would update the row also deletes it.
+func (s *SegmentationService) Delete(ctx context.Context, userID uint64, segType, name string) error {
+	if userID == 0 {
+		return fmt.Errorf("%w: user_id must be greater than zero", ErrInvalidSegmentation)
+	}
+	rows, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	segType, err = s.resolveType(segType, func() ([]string, error) {
+		return rowTypes(rows), nil
+	})
+	if err != nil {
+		return err
+	}
+	seg, err := newSegmentation(userID, segType, name, nil)
+	if err != nil {
+		return err
+	}
+	group := normalizeType(seg.SegmentationType)
+	key := s.rules.Names.Key(s.rules.Names.Normalize(seg.SegmentationName))
+
+	var ids []uint64
+	var changes []Change
+	for _, row := range rows {
+		rowKey := row.NameKey
+		if rowKey == "" {
+			rowKey = s.rules.Names.Key(row.SegmentationName)
+		}
+		if normalizeType(row.SegmentationType) == group && rowKey == key {
+			ids = append(ids, row.ID)
+			changes = append(changes, Change{
+				Kind:   ChangeDeleted,
+				UserID: userID,
+				Type:   row.SegmentationType,
+				Name:   row.SegmentationName,
+			})
+		}
+	}
+	if len(ids) == 0 {
+		return ErrSegmentationNotFound
+	}
+
+	deleted, err := s.repo.DeleteByIDs(ctx, ids)
+	s.invalidate(userID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		// removed by a concurrent write between the read and the delete
+		return ErrSegmentationNotFound
+	}
+	s.notify(changes...)
+
+	s.logger.Info("segmentation_deleted",
+		zap.Uint64("user_id", userID),
+		zap.String("segmentation_type", seg.SegmentationType),
+		zap.String("segmentation_name", seg.SegmentationName),
+	)
+	return nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	repo := seededMemoryRepository()
+	svc := NewSegmentationService(repo)
+	ctx := context.Background()
+
+	// group key and extra whitespace match like a write would
+	if err := svc.Delete(ctx, 10, "drugs", "  Dipirona "); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	rows, _ := repo.FindByUserID(ctx, 10)
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows left, got %d", len(rows))
+	}
+	for _, r := range rows {
+		if r.SegmentationName == "Dipirona" {
+			t.Error("Dipirona should have been deleted")
+		}
+	}
+
+	for _, tt := range []struct {
+		name, segType, segName string
+		want                   error
+	}{
+		{name: "already deleted", segType: "drug", segName: "Dipirona", want: ErrSegmentationNotFound},
+		{name: "other type", segType: "patient", segName: "Aspirina", want: ErrSegmentationNotFound},
+		{name: "empty name", segType: "drug", segName: " ", want: ErrInvalidSegmentation},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.Delete(ctx, 10, tt.segType, tt.segName); !errors.Is(err, tt.want) {
+				t.Errorf("Delete() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidErasure is returned for an erasure request that is too large
+var ErrInvalidErasure = apperrors.New("invalid erasure request", apperrors.ErrValidation)
+
+const (
+	// maxErasureReference is the size of erasures.reference
+	maxErasureReference = 255
+	// erasureVerifyBatch is how many records Verify reads at a time
+	erasureVerifyBatch = 1000
+)
+
+// ErasureRequest is the optional body of a right-to-erasure request
+type ErasureRequest struct {
+	// Reference identifies the request of the data subject, such as a
+	// ticket number, and is kept in the erasure record
+	Reference string `json:"reference" example:"DPO-2026-0042"`
+}
+
+// ErasureChain is the outcome of verifying the erasure records: Valid
+// when every hash matches its record and links to the previous one. Head
+// is the hash of the last record, which auditors can keep elsewhere to
+// detect records removed from the end.
+type ErasureChain struct {
+	Valid    bool   `json:"valid"`
+	Records  int    `json:"records"`
+	Head     string `json:"head,omitempty"`
+	BrokenAt uint64 `json:"broken_at,omitempty"`
+}
+
+// Erasures erases all the data of a user on request (LGPD, GDPR) and keeps
+// the proof of it
+type Erasures struct {
+	repo  repository.ErasureRepository
+	segs  *SegmentationService
+	queue Purger
+}
+
+// Purger drops the writes of a user waiting to be applied, as
+// writequeue.Queue does
+type Purger interface {
+	Purge(userID uint64) (int, error)
+}
+
+// ErasuresOption customizes Erasures
+type ErasuresOption func(*Erasures)
+
+// WithQueuePurge purges the writes of the user still waiting in q before
+// erasing, so a write accepted earlier cannot bring the user's rows back
+func WithQueuePurge(q Purger) ErasuresOption {
+	return func(s *Erasures) {
+		s.queue = q
+	}
+}
+
+// NewErasures erases through repo; segs is the service whose cache and
+// notifier learn about the erased segmentations
+func NewErasures(repo repository.ErasureRepository, segs *SegmentationService, opts ...ErasuresOption) *Erasures {
+	s := &Erasures{repo: repo, segs: segs}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ParseUserID parses a user_id path parameter as the segmentation
+// endpoints do
+func (s *Erasures) ParseUserID(value string) (uint64, error) {
+	return s.segs.ParseUserID(value)
+}
+
+// Erase hard-deletes the segmentations of a user with their change
+// history and records the erasure, by actor from clientIP, after purging
+// the user's queued writes (WithQueuePurge). Subscribers get a deletion
+// event per erased segmentation. A user without data is recorded too, with
+// zero counts, as proof the request was handled.
+func (s *Erasures) Erase(ctx context.Context, userID uint64, actor, clientIP string, req ErasureRequest) (*models.Erasure, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("%w: user_id must be greater than zero", ErrInvalidErasure)
+	}
+	if len(req.Reference) > maxErasureReference {
+		return nil, fmt.Errorf("%w: reference has at most %d bytes", ErrInvalidErasure, maxErasureReference)
+	}
+
+	var queued int
+	if s.queue != nil {
+		var err error
+		// before the erasure: a purge that fails leaves nothing recorded,
+		// and the request can be repeated
+		if queued, err = s.queue.Purge(userID); err != nil {
+			return nil, fmt.Errorf("purge queued writes: %w", err)
+		}
+	}
+
+	erasure := &models.Erasure{
+		UserID:    userID,
+		Actor:     actor,
+		ClientIP:  clientIP,
+		Reference: req.Reference,
+		CreatedAt: Timestamp(time.Now().Unix()),
+	}
+	rows, err := s.repo.Erase(ctx, erasure)
+	s.segs.invalidate(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0, len(rows))
+	for _, row := range rows {
+		changes = append(changes, Change{
+			Kind:   ChangeDeleted,
+			UserID: userID,
+			Type:   row.SegmentationType,
+			Name:   row.SegmentationName,
+		})
+	}
+	s.segs.notify(changes...)
+
+	s.segs.logger.Info("user_erased",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("erasure_id", erasure.ID),
+		zap.Int64("segmentations", erasure.Segmentations),
+		zap.Int64("events", erasure.Events),
+		zap.Int64("deliveries", erasure.Deliveries),
+		zap.Int64("dead_letters", erasure.DeadLetters),
+		zap.Int64("idempotency_keys", erasure.IdempotencyKeys),
+		zap.Int("queued_writes", queued),
+	)
+	return erasure, nil
+}
+
+// List returns the erasure records in chain order, those of a user when
+// filter.UserID is set
+func (s *Erasures) List(ctx context.Context, filter repository.ErasureFilter) ([]models.Erasure, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// Verify walks the whole chain of erasure records and reports the first
+// record that does not match its hash or does not link to the previous
+// one
+func (s *Erasures) Verify(ctx context.Context) (*ErasureChain, error) {
+	chain := &ErasureChain{Valid: true}
+	var after uint64
+	for {
+		batch, err := s.repo.List(ctx, repository.ErasureFilter{AfterID: after, Limit: erasureVerifyBatch})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range batch {
+			if e.PrevHash != chain.Head || e.Hash != e.ComputeHash() {
+				chain.Valid, chain.BrokenAt = false, e.ID
+				return chain, nil
+			}
+			chain.Records++
+			chain.Head = e.Hash
+		}
+		if len(batch) < erasureVerifyBatch {
+			return chain, nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// memoryErasures erases the rows of a memoryRepository and chains the
+// records as the MySQL repository does
+type memoryErasures struct {
+	segs    *memoryRepository
+	records []models.Erasure
+}
+
+func (m *memoryErasures) Erase(ctx context.Context, erasure *models.Erasure) ([]models.Segmentation, error) {
+	var erased, kept []models.Segmentation
+	for _, row := range m.segs.rows {
+		if row.UserID == erasure.UserID {
+			erased = append(erased, row)
+		} else {
+			kept = append(kept, row)
+		}
+	}
+	m.segs.rows = kept
+	erasure.Segmentations = int64(len(erased))
+	if len(m.records) > 0 {
+		erasure.PrevHash = m.records[len(m.records)-1].Hash
+	}
+	erasure.Hash = erasure.ComputeHash()
+	erasure.ID = uint64(len(m.records) + 1)
+	m.records = append(m.records, *erasure)
+	return erased, nil
+}
+
+func (m *memoryErasures) List(ctx context.Context, filter repository.ErasureFilter) ([]models.Erasure, error) {
+	var out []models.Erasure
+	for _, e := range m.records {
+		if e.ID > filter.AfterID && (filter.UserID == 0 || e.UserID == filter.UserID) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func TestErasures_Erase(t *testing.T) {
+	repo := seededMemoryRepository()
+	notifier := &recordingNotifier{}
+	store := &memoryErasures{segs: repo}
+	erasures := NewErasures(store, NewSegmentationService(repo, WithNotifier(notifier)))
+	ctx := context.Background()
+
+	erasure, err := erasures.Erase(ctx, 10, "admin", "10.0.0.1", ErasureRequest{Reference: "DPO-1"})
+	if err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if erasure.Segmentations != 3 || erasure.Actor != "admin" || erasure.Reference != "DPO-1" || erasure.Hash == "" || erasure.PrevHash != "" {
+		t.Errorf("unexpected erasure %+v", erasure)
+	}
+	if rows, _ := repo.FindByUserID(ctx, 10); len(rows) != 0 {
+		t.Errorf("%d rows left, want none", len(rows))
+	}
+	if len(notifier.changes) != 3 || notifier.changes[0].Kind != ChangeDeleted {
+		t.Errorf("unexpected changes %+v", notifier.changes)
+	}
+
+	// a user without data is recorded too, chained to the previous record
+	second, err := erasures.Erase(ctx, 10, "admin", "10.0.0.1", ErasureRequest{})
+	if err != nil || second.Segmentations != 0 || second.PrevHash != erasure.Hash {
+		t.Errorf("second Erase() = %+v, %v", second, err)
+	}
+
+	for _, tt := range []struct {
+		userID uint64
+		req    ErasureRequest
+	}{
+		{0, ErasureRequest{}},
+		{10, ErasureRequest{Reference: strings.Repeat("x", 256)}},
+	} {
+		if _, err := erasures.Erase(ctx, tt.userID, "admin", "", tt.req); !errors.Is(err, ErrInvalidErasure) {
+			t.Errorf("Erase(%d) error = %v, want ErrInvalidErasure", tt.userID, err)
+		}
+	}
+}
+
+func TestErasures_Verify(t *testing.T) {
+	repo := seededMemoryRepository()
+	store := &memoryErasures{segs: repo}
+	erasures := NewErasures(store, NewSegmentationService(repo))
+	ctx := context.Background()
+
+	chain, err := erasures.Verify(ctx)
+	if err != nil || !chain.Valid || chain.Records != 0 {
+		t.Fatalf("Verify() of no records = %+v, %v", chain, err)
+	}
+
+	for _, userID := range []uint64{10, 11, 12} {
+		if _, err := erasures.Erase(ctx, userID, "admin", "", ErasureRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chain, err = erasures.Verify(ctx)
+	if err != nil || !chain.Valid || chain.Records != 3 || chain.Head != store.records[2].Hash {
+		t.Fatalf("Verify() = %+v, %v", chain, err)
+	}
+
+	// an edited record no longer matches its hash
+	store.records[1].Segmentations = 0
+	store.records[1].UserID = 99
+	if chain, _ := erasures.Verify(ctx); chain.Valid || chain.BrokenAt != 2 || chain.Records != 1 {
+		t.Errorf("Verify() of an edited record = %+v", chain)
+	}
+
+	// a removed record breaks the link of the next one
+	store.records[1] = store.records[2]
+	store.records = store.records[:2]
+	if chain, _ := erasures.Verify(ctx); chain.Valid || chain.BrokenAt != 3 {
+		t.Errorf("Verify() after a removed record = %+v", chain)
+	}
+}
+
+// purger records the users purged from a write queue
+type purger struct {
+	users []uint64
+	err   error
+}
+
+func (p *purger) Purge(userID uint64) (int, error) {
+	p.users = append(p.users, userID)
+	return 1, p.err
+}
+
+func TestErasures_PurgesQueuedWrites(t *testing.T) {
+	repo := seededMemoryRepository()
+	store := &memoryErasures{segs: repo}
+	queue := &purger{err: errors.New("disk full")}
+	erasures := NewErasures(store, NewSegmentationService(repo), WithQueuePurge(queue))
+	ctx := context.Background()
+
+	// nothing is erased nor recorded while queued writes may remain
+	if _, err := erasures.Erase(ctx, 10, "admin", "", ErasureRequest{}); err == nil {
+		t.Fatal("Erase() should fail when the purge fails")
+	}
+	if len(store.records) != 0 || len(repo.rows) != 3 {
+		t.Errorf("a failed purge erased %d rows and recorded %d erasures", 3-len(repo.rows), len(store.records))
+	}
+
+	queue.err = nil
+	if _, err := erasures.Erase(ctx, 10, "admin", "", ErasureRequest{}); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if len(queue.users) != 2 || queue.users[1] != 10 || len(store.records) != 1 {
+		t.Errorf("purged %v, recorded %d erasures", queue.users, len(store.records))
+	}
+}
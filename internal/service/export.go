@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+)
+
+// ExportFormat identifies the document format produced by Export
+type ExportFormat string
+
+const (
+	ExportFormatJSON ExportFormat = "json"
+	ExportFormatCSV  ExportFormat = "csv"
+)
+
+// ErrUnsupportedExportFormat is returned when the requested format is unknown
+var ErrUnsupportedExportFormat = apperrors.New("unsupported export format", apperrors.ErrValidation)
+
+// ParseExportFormat validates a user supplied format, defaulting to JSON
+func ParseExportFormat(f string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(strings.TrimSpace(f))) {
+	case "", ExportFormatJSON:
+		return ExportFormatJSON, nil
+	case ExportFormatCSV:
+		return ExportFormatCSV, nil
+	default:
+		return "", ErrUnsupportedExportFormat
+	}
+}
+
+// ContentType returns the MIME type of the exported document
+func (f ExportFormat) ContentType() string {
+	if f == ExportFormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// ExportItem is one row of an export document
+type ExportItem struct {
+	Type      string          `json:"segmentation_type"`
+	Name      string          `json:"segmentation_name"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt Timestamp       `json:"created_at" swaggertype:"string" format:"date-time"`
+	UpdatedAt Timestamp       `json:"updated_at" swaggertype:"string" format:"date-time"`
+}
+
+// utf8BOM leads a CSV export with WithBOM, so spreadsheet applications
+// such as Excel read the accents as UTF-8 instead of the locale's code page
+const utf8BOM = "\ufeff"
+
+// ExportOption customizes one Export
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	bom bool
+}
+
+// WithBOM starts a CSV export with the UTF-8 byte order mark; JSON exports
+// ignore it
+func WithBOM() ExportOption {
+	return func(o *exportOptions) {
+		o.bom = true
+	}
+}
+
+// csvExportHeader mirrors the processor input layout so exports can be re-imported
+var csvExportHeader = []string{
+	"user_id",
+	"segmentation_type",
+	"segmentation_name",
+	"data",
+	"created_at",
+	"updated_at",
+}
+
+// Export writes the complete segmentation set of a user to w in the given
+// format. Rows are encoded as they are read from the repository, so the
+// document is never fully buffered in memory. Fields of a CSV export are
+// quoted when they hold the separator, quotes or line breaks, so names and
+// JSON data survive the round trip.
+func (s *SegmentationService) Export(
+	ctx context.Context,
+	userID uint64,
+	format ExportFormat,
+	w io.Writer,
+	opts ...ExportOption,
+) error {
+
+	var o exportOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var enc exportEncoder
+	switch format {
+	case ExportFormatJSON:
+		enc = &jsonExportEncoder{w: w, userID: userID}
+	case ExportFormatCSV:
+		enc = &csvExportEncoder{w: w, userID: strconv.FormatUint(userID, 10), bom: o.bom}
+	default:
+		return ErrUnsupportedExportFormat
+	}
+
+	// the document starts with the first row, so a lookup that fails
+	// before it writes nothing and the caller can still answer an error
+	started := false
+	err := s.repo.EachByUserID(ctx, userID, func(r models.Segmentation) error {
+		if !started {
+			started = true
+			if err := enc.begin(); err != nil {
+				return err
+			}
+		}
+		return enc.item(exportItem(r))
+	})
+	if err != nil {
+		return err
+	}
+	if !started {
+		if err := enc.begin(); err != nil {
+			return err
+		}
+	}
+	return enc.end()
+}
+
+// exportItem converts a stored row; a row without data exports null
+func exportItem(r models.Segmentation) ExportItem {
+	data := json.RawMessage(r.Data)
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+	return ExportItem{
+		Type:      r.SegmentationType,
+		Name:      r.SegmentationName,
+		Data:      data,
+		CreatedAt: Timestamp(r.CreatedAt),
+		UpdatedAt: Timestamp(r.UpdatedAt),
+	}
+}
+
+// exportEncoder writes an export document one item at a time
+type exportEncoder interface {
+	begin() error
+	item(ExportItem) error
+	end() error
+}
+
+type jsonExportEncoder struct {
+	w      io.Writer
+	userID uint64
+	n      int
+}
+
+func (e *jsonExportEncoder) begin() error {
+	head := `{"user_id":` + strconv.FormatUint(e.userID, 10) +
+		`,"exported_at":"` + time.Now().UTC().Format(time.RFC3339) + `"` +
+		`,"segmentations":[`
+	_, err := io.WriteString(e.w, head)
+	return err
+}
+
+func (e *jsonExportEncoder) item(item ExportItem) error {
+	if e.n > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.n++
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExportEncoder) end() error {
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
+
+type csvExportEncoder struct {
+	w      io.Writer
+	cw     *csv.Writer
+	userID string
+	bom    bool
+}
+
+func (e *csvExportEncoder) begin() error {
+	if e.bom {
+		if _, err := io.WriteString(e.w, utf8BOM); err != nil {
+			return err
+		}
+	}
+	e.cw = csv.NewWriter(e.w)
+	return e.cw.Write(csvExportHeader)
+}
+
+func (e *csvExportEncoder) item(item ExportItem) error {
+	return e.cw.Write([]string{
+		e.userID,
+		item.Type,
+		item.Name,
+		string(item.Data),
+		item.CreatedAt.String(),
+		item.UpdatedAt.String(),
+	})
+}
+
+func (e *csvExportEncoder) end() error {
+	e.cw.Flush()
+	return e.cw.Error()
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func exportMockRepo() *MockRepository {
+	return &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{
+				{
+					UserID:           42,
+					SegmentationType: "drug",
+					SegmentationName: "Antibióticos",
+					Data:             datatypes.JSON(`{"type": "antibiotic", "note": "a,b"}`),
+					CreatedAt:        1700000000,
+					UpdatedAt:        1700000100,
+				},
+				{
+					UserID:           42,
+					SegmentationType: "specialty",
+					SegmentationName: "Cardiologia",
+					Data:             datatypes.JSON(`{"years": 15}`),
+				},
+			}, nil
+		},
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ExportFormat
+		wantErr  bool
+	}{
+		{input: "", expected: ExportFormatJSON},
+		{input: "json", expected: ExportFormatJSON},
+		{input: "CSV", expected: ExportFormatCSV},
+		{input: " csv ", expected: ExportFormatCSV},
+		{input: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("format_"+tt.input, func(t *testing.T) {
+			got, err := ParseExportFormat(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedExportFormat) {
+					t.Fatalf("expected ErrUnsupportedExportFormat, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseExportFormat(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExport_JSON(t *testing.T) {
+	svc := NewSegmentationService(exportMockRepo())
+
+	var buf bytes.Buffer
+	if err := svc.Export(context.Background(), 42, ExportFormatJSON, &buf); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var doc struct {
+		UserID        uint64       `json:"user_id"`
+		ExportedAt    Timestamp    `json:"exported_at"`
+		Segmentations []ExportItem `json:"segmentations"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("export is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	if doc.UserID != 42 {
+		t.Errorf("user_id = %d, want 42", doc.UserID)
+	}
+	if doc.ExportedAt == 0 {
+		t.Error("exported_at should be set")
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"created_at":"2023-11-14T22:13:20Z"`)) {
+		t.Errorf("created_at should be RFC3339: %s", buf.String())
+	}
+	if len(doc.Segmentations) != 2 {
+		t.Fatalf("expected 2 segmentations, got %d", len(doc.Segmentations))
+	}
+	if doc.Segmentations[0].Name != "Antibióticos" || doc.Segmentations[0].CreatedAt != 1700000000 {
+		t.Errorf("unexpected first item: %+v", doc.Segmentations[0])
+	}
+}
+
+func TestExport_JSONEmpty(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{})
+
+	var buf bytes.Buffer
+	if err := svc.Export(context.Background(), 7, ExportFormatJSON, &buf); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if items, ok := doc["segmentations"].([]interface{}); !ok || len(items) != 0 {
+		t.Errorf("expected empty segmentations array, got %v", doc["segmentations"])
+	}
+}
+
+func TestExport_CSV(t *testing.T) {
+	svc := NewSegmentationService(exportMockRepo())
+
+	var buf bytes.Buffer
+	if err := svc.Export(context.Background(), 42, ExportFormatCSV, &buf); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d", len(rows))
+	}
+	if rows[0][0] != "user_id" || rows[0][3] != "data" {
+		t.Errorf("unexpected header: %v", rows[0])
+	}
+	if rows[1][0] != "42" || rows[1][2] != "Antibióticos" {
+		t.Errorf("unexpected row: %v", rows[1])
+	}
+	if rows[1][4] != "2023-11-14T22:13:20Z" || rows[1][5] != "2023-11-14T22:15:00Z" {
+		t.Errorf("timestamps = %v, want RFC3339", rows[1][4:])
+	}
+	if !json.Valid([]byte(rows[1][3])) {
+		t.Errorf("data column should round-trip as JSON, got %q", rows[1][3])
+	}
+}
+
+func TestExport_CSVQuotingAndBOM(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{{
+				UserID:           42,
+				SegmentationType: "drug",
+				SegmentationName: "Ácido \"fólico\", 5mg",
+				Data:             datatypes.JSON("{\"note\": \"line 1\\nline 2\"}"),
+			}}, nil
+		},
+	})
+
+	var buf bytes.Buffer
+	if err := svc.Export(context.Background(), 42, ExportFormatCSV, &buf, WithBOM()); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("\xef\xbb\xbfuser_id,")) {
+		t.Fatalf("export should start with the UTF-8 BOM, got %q", buf.String())
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"Ácido ""fólico"", 5mg"`)) {
+		t.Errorf("name should be quoted, got %s", buf.String())
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(buf.Bytes(), []byte("\xef\xbb\xbf")))).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if rows[1][2] != `Ácido "fólico", 5mg` || !json.Valid([]byte(rows[1][3])) {
+		t.Errorf("unexpected row: %q", rows[1])
+	}
+
+	buf.Reset()
+	if err := svc.Export(context.Background(), 42, ExportFormatJSON, &buf, WithBOM()); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Errorf("JSON exports should ignore the BOM, got %q", buf.String())
+	}
+}
+
+func TestExport_RepositoryError(t *testing.T) {
+	repoErr := errors.New("db down")
+	svc := NewSegmentationService(&MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return nil, repoErr
+		},
+	})
+
+	var buf bytes.Buffer
+	err := svc.Export(context.Background(), 1, ExportFormatCSV, &buf)
+	if !errors.Is(err, repoErr) {
+		t.Fatalf("expected repository error, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Error("nothing should be written when the lookup fails")
+	}
+}
+
+// streamingRepository yields rows one at a time and records how much of
+// the document was written before each one
+type streamingRepository struct {
+	MockRepository
+	rows    []models.Segmentation
+	out     *bytes.Buffer
+	written []int
+}
+
+func (r *streamingRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	for _, row := range r.rows {
+		r.written = append(r.written, r.out.Len())
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExport_EncodesRowsAsRead(t *testing.T) {
+	var buf bytes.Buffer
+	rows, _ := exportMockRepo().FindByUserID(context.Background(), 42)
+	repo := &streamingRepository{rows: rows, out: &buf}
+
+	if err := NewSegmentationService(repo).Export(context.Background(), 42, ExportFormatJSON, &buf); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if len(repo.written) != 2 || repo.written[1] <= repo.written[0] {
+		t.Errorf("the first row should be written before the second is read, written %v", repo.written)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Errorf("invalid JSON %q", buf.String())
+	}
+}
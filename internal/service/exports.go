@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// ErrExportNotFound is returned for an ID that is not the one of an export
+var ErrExportNotFound = apperrors.New("export not found", apperrors.ErrNotFound)
+
+// maxExportSource is the size of runs.source, where the filter is kept
+const maxExportSource = 500
+
+// ExportStarter starts an export of the rows passing a filter in the
+// background; source describes the filter in the runs table and
+// pseudonymize asks for pseudonymized rows
+type ExportStarter interface {
+	Start(ctx context.Context, filter repository.Filter, source string, pseudonymize bool) (*models.Run, error)
+}
+
+// ExportRequest starts a table export; without a filter every segmentation
+// is exported
+type ExportRequest struct {
+	// Filter takes the field:op:value conditions of GET
+	// /segmentations/changes
+	Filter []string `json:"filter" example:"segmentation_type:eq:drug"`
+	// Pseudonymize replaces the user IDs by pseudonyms and drops the
+	// sensitive data keys, for datasets shared outside the company
+	Pseudonymize bool `json:"pseudonymize"`
+}
+
+// ExportJob is an export as the API shows it. Rows counts the rows
+// exported so far; the files are under Destination once Status is
+// succeeded.
+type ExportJob struct {
+	ID     string   `json:"id" example:"6f1c2a9e-3b7d-4c8e-9f10-2a3b4c5d6e7f"`
+	Status string   `json:"status" enums:"running,succeeded,failed,cancelled"`
+	Filter []string `json:"filter"`
+	// Pseudonymized exports identify no user and cannot be restored
+	Pseudonymized bool   `json:"pseudonymized"`
+	Destination   string `json:"destination" example:"s3://warehouse/exports/6f1c2a9e-3b7d-4c8e-9f10-2a3b4c5d6e7f/"`
+	Rows          uint64 `json:"rows"`
+	Error         string `json:"error,omitempty"`
+	StartedAt     int64  `json:"started_at"`
+	FinishedAt    int64  `json:"finished_at,omitempty"`
+}
+
+// Exports starts table exports and reports their progress from the runs
+// table
+type Exports struct {
+	runs    repository.RunRepository
+	starter ExportStarter
+}
+
+func NewExports(runs repository.RunRepository, starter ExportStarter) *Exports {
+	return &Exports{runs: runs, starter: starter}
+}
+
+// Start validates the filter and starts the export
+func (s *Exports) Start(ctx context.Context, req ExportRequest) (*ExportJob, error) {
+	filter, err := ParseFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	source := ""
+	if len(req.Filter) > 0 {
+		b, err := json.Marshal(req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > maxExportSource {
+			return nil, fmt.Errorf("%w: at most %d bytes of conditions", ErrInvalidFilter, maxExportSource)
+		}
+		source = string(b)
+	}
+
+	run, err := s.starter.Start(ctx, filter, source, req.Pseudonymize)
+	if err != nil {
+		return nil, err
+	}
+	return exportView(run), nil
+}
+
+// Get returns an export by ID
+func (s *Exports) Get(ctx context.Context, id string) (*ExportJob, error) {
+	run, err := s.runs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil || run.Kind != models.RunExport {
+		return nil, ErrExportNotFound
+	}
+	return exportView(run), nil
+}
+
+func exportView(run *models.Run) *ExportJob {
+	job := &ExportJob{
+		ID:            run.ID,
+		Status:        run.Status,
+		Filter:        []string{},
+		Pseudonymized: run.Pseudonymized,
+		Destination:   run.Destination,
+		Rows:          run.RowsRead,
+		Error:         run.Error,
+		StartedAt:     run.StartedAt,
+		FinishedAt:    run.FinishedAt,
+	}
+	if run.Source != "" {
+		// written by Start; a run that does not parse shows no filter
+		_ = json.Unmarshal([]byte(run.Source), &job.Filter)
+	}
+	return job
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type recordingStarter struct {
+	filter       repository.Filter
+	source       string
+	pseudonymize bool
+}
+
+func (s *recordingStarter) Start(ctx context.Context, filter repository.Filter, source string, pseudonymize bool) (*models.Run, error) {
+	s.filter, s.source, s.pseudonymize = filter, source, pseudonymize
+	return &models.Run{ID: "export-1", Kind: models.RunExport, Status: models.RunRunning, Source: source, Pseudonymized: pseudonymize}, nil
+}
+
+func TestExports_Start(t *testing.T) {
+	starter := &recordingStarter{}
+	exports := NewExports(memoryRuns{}, starter)
+
+	job, err := exports.Start(context.Background(), ExportRequest{Filter: []string{"segmentation_type:eq:drug", "user_id:in:1,2"}})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if len(starter.filter) != 2 || starter.filter[1].Values[1] != "2" || starter.source != `["segmentation_type:eq:drug","user_id:in:1,2"]` {
+		t.Errorf("unexpected filter %+v, source %q", starter.filter, starter.source)
+	}
+	if job.ID != "export-1" || job.Status != models.RunRunning || len(job.Filter) != 2 {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	job, err = exports.Start(context.Background(), ExportRequest{Pseudonymize: true})
+	if err != nil || !starter.pseudonymize || !job.Pseudonymized {
+		t.Errorf("a pseudonymized export = %+v, %v, want it pseudonymized", job, err)
+	}
+
+	for _, filter := range [][]string{{"password:eq:x"}, {"user_id:in:" + strings.Repeat("1234567,", 70) + "1"}} {
+		if _, err := exports.Start(context.Background(), ExportRequest{Filter: filter}); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Start(%.40q) error = %v, want ErrInvalidFilter", filter, err)
+		}
+	}
+}
+
+func TestExports_Get(t *testing.T) {
+	runs := memoryRuns{byID: map[string]*models.Run{
+		"export-1": {ID: "export-1", Kind: models.RunExport, Status: models.RunSucceeded, RowsRead: 42, Destination: "/data/exports/export-1/"},
+		"import-1": {ID: "import-1", Kind: models.RunImport},
+	}}
+	exports := NewExports(runs, &recordingStarter{})
+
+	job, err := exports.Get(context.Background(), "export-1")
+	if err != nil || job.Rows != 42 || job.Filter == nil || job.Destination != "/data/exports/export-1/" {
+		t.Fatalf("Get() = %+v, %v", job, err)
+	}
+	for _, id := range []string{"import-1", "missing"} {
+		if _, err := exports.Get(context.Background(), id); !errors.Is(err, ErrExportNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrExportNotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"segmentation-api/internal/apperrors"
+)
+
+// ErrInvalidFields is returned when the fields parameter names an unknown field
+var ErrInvalidFields = apperrors.New("invalid fields", apperrors.ErrValidation)
+
+// Fields selects the members of each segmentation item a response carries,
+// as requested with ?fields=name,data.quantity. A nil *Fields selects
+// name and data.
+type Fields struct {
+	name bool
+	data bool
+	// dataKeys are the top-level keys of data kept when data is not
+	// selected whole
+	dataKeys []string
+	// metadata adds created_at and updated_at
+	metadata bool
+}
+
+// IncludeMetadata returns a copy of f that also selects created_at and
+// updated_at, as requested with ?include=metadata
+func (f *Fields) IncludeMetadata() *Fields {
+	with := Fields{name: true, data: true}
+	if f != nil {
+		with = *f
+	}
+	with.metadata = true
+	return &with
+}
+
+// ParseFields parses a comma separated list of name, data and data.<key>;
+// an empty list returns nil, i.e. every field
+func ParseFields(s string) (*Fields, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	f := &Fields{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		key, isDataKey := strings.CutPrefix(field, "data.")
+		switch {
+		case field == "name":
+			f.name = true
+		case field == "data":
+			f.data = true
+		case isDataKey && key != "":
+			if !slices.Contains(f.dataKeys, key) {
+				f.dataKeys = append(f.dataKeys, key)
+			}
+		default:
+			return nil, fmt.Errorf("%w %q: must be name, data or data.<key>", ErrInvalidFields, field)
+		}
+	}
+	if f.data {
+		f.dataKeys = nil
+	}
+	return f, nil
+}
+
+// encodeItem encodes the selected members of item, the same as
+// json.Marshal does for the members it keeps
+func (f *Fields) encodeItem(item SegmentationItem) ([]byte, error) {
+	if f == nil {
+		return json.Marshal(item)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	member := func(name string) {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"` + name + `":`)
+	}
+	if f.name {
+		name, err := json.Marshal(item.Name)
+		if err != nil {
+			return nil, err
+		}
+		member("name")
+		buf.Write(name)
+	}
+	if f.data || len(f.dataKeys) > 0 {
+		member("data")
+		if err := f.writeData(&buf, item.Data); err != nil {
+			return nil, err
+		}
+	}
+	if f.metadata {
+		created, _ := item.CreatedAt.MarshalJSON()
+		updated, _ := item.UpdatedAt.MarshalJSON()
+		member("created_at")
+		buf.Write(created)
+		member("updated_at")
+		buf.Write(updated)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeData writes data whole or only its selected keys, in the order they
+// were requested; keys missing from data are left out and data that is not
+// an object is written as null
+func (f *Fields) writeData(buf *bytes.Buffer, data json.RawMessage) error {
+	if len(data) == 0 {
+		buf.WriteString("null")
+		return nil
+	}
+	if f.data {
+		return json.Compact(buf, data)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil || values == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	buf.WriteByte('{')
+	n := 0
+	for _, key := range f.dataKeys {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		if err := json.Compact(buf, value); err != nil {
+			return err
+		}
+		n++
+	}
+	buf.WriteByte('}')
+	return nil
+}
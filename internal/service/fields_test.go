@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *Fields
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: " ", want: nil},
+		{in: "name", want: &Fields{name: true}},
+		{in: "name, data", want: &Fields{name: true, data: true}},
+		{in: "data.quantity,data.dose,data.quantity", want: &Fields{dataKeys: []string{"quantity", "dose"}}},
+		{in: "data.quantity,data", want: &Fields{data: true}},
+		{in: "id", wantErr: true},
+		{in: "name,", wantErr: true},
+		{in: "data.", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFields(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidFields) {
+					t.Errorf("ParseFields(%q) error = %v, want ErrInvalidFields", tt.in, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFields(%q) error = %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFields(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentationResponse_WriteJSONFields(t *testing.T) {
+	resp := SegmentationResponse{
+		UserID: 7,
+		Segmentations: map[string][]SegmentationItem{
+			"drugs": {
+				{Name: "Dipirona <b>", Data: json.RawMessage(`{"quantity": "200", "dose": 1, "note": "x"}`)},
+				{Name: "Ibuprofeno", Data: json.RawMessage(`{"dose":2}`)},
+				{Name: "Paracetamol"},
+				{Name: "Aspirina", Data: json.RawMessage(`[1]`)},
+			},
+		},
+	}
+
+	tests := []struct {
+		fields string
+		want   string
+	}{
+		{
+			fields: "name",
+			want:   `{"user_id":7,"segmentations":{"drugs":[{"name":"Dipirona \u003cb\u003e"},{"name":"Ibuprofeno"},{"name":"Paracetamol"},{"name":"Aspirina"}]}}`,
+		},
+		{
+			fields: "data",
+			want:   `{"user_id":7,"segmentations":{"drugs":[{"data":{"quantity":"200","dose":1,"note":"x"}},{"data":{"dose":2}},{"data":null},{"data":[1]}]}}`,
+		},
+		{
+			fields: "name,data.quantity,data.dose",
+			want:   `{"user_id":7,"segmentations":{"drugs":[{"name":"Dipirona \u003cb\u003e","data":{"quantity":"200","dose":1}},{"name":"Ibuprofeno","data":{"dose":2}},{"name":"Paracetamol","data":null},{"name":"Aspirina","data":null}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fields, func(t *testing.T) {
+			fields, err := ParseFields(tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := resp.WriteJSONFields(&buf, fields); err != nil {
+				t.Fatalf("WriteJSONFields() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("WriteJSONFields() = %s\nwant %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/repository"
+)
+
+// ErrInvalidFilter is returned for a filter naming a field, operator or
+// value the repository does not accept
+var ErrInvalidFilter = apperrors.New("invalid filter", apperrors.ErrValidation)
+
+// maxFilterConditions caps the conditions of one request
+const maxFilterConditions = 20
+
+// ParseFilter parses filter expressions written as field:op:value, such as
+// segmentation_type:eq:drug, data.dose:prefix:500 or user_id:in:1,2,3 (in
+// splits its value on commas). The value is everything after the second
+// colon. Fields and operators are checked against the filterable ones;
+// the values are passed to the database as parameters, never as SQL.
+func ParseFilter(exprs []string) (repository.Filter, error) {
+	if len(exprs) > maxFilterConditions {
+		return nil, fmt.Errorf("%w: at most %d conditions", ErrInvalidFilter, maxFilterConditions)
+	}
+	var filter repository.Filter
+	for _, expr := range exprs {
+		field, rest, ok := strings.Cut(expr, ":")
+		op, value, ok2 := strings.Cut(rest, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%w: %q is not field:op:value", ErrInvalidFilter, expr)
+		}
+		c := repository.Condition{Field: field, Op: repository.FilterOp(op), Values: []string{value}}
+		if c.Op == repository.FilterIn {
+			c.Values = strings.Split(value, ",")
+		}
+		filter = append(filter, c)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+	return filter, nil
+}
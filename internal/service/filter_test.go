@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/repository"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter([]string{"segmentation_type:eq:drug", "user_id:in:1,2,3", "data.url:eq:http://x:8080"})
+	if err != nil {
+		t.Fatalf("ParseFilter() error = %v", err)
+	}
+	if len(filter) != 3 ||
+		filter[0].Field != "segmentation_type" || filter[0].Op != repository.FilterEq || filter[0].Values[0] != "drug" ||
+		filter[1].Op != repository.FilterIn || len(filter[1].Values) != 3 || filter[1].Values[2] != "3" ||
+		filter[2].Field != "data.url" || filter[2].Values[0] != "http://x:8080" {
+		t.Errorf("ParseFilter() = %+v", filter)
+	}
+
+	for _, exprs := range [][]string{
+		{"segmentation_type"},
+		{"segmentation_type:eq"},
+		{"name_key:eq:x"},
+		{"user_id:eq:abc"},
+		{"data.a b:eq:x"},
+	} {
+		if _, err := ParseFilter(exprs); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("ParseFilter(%q) error = %v, want ErrInvalidFilter", exprs, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// importErrorsPage is how many dead-letter entries are read per query
+const importErrorsPage = 500
+
+// ErrRunNotFound is returned for a run ID the runs table does not know
+var ErrRunNotFound = apperrors.New("run not found", apperrors.ErrNotFound)
+
+// ImportError is a row of an import that the processor rejected or failed
+// to write
+type ImportError struct {
+	RowNumber int    `json:"row_number"`
+	RawLine   string `json:"raw_line"`
+	Error     string `json:"error"`
+}
+
+var csvImportErrorsHeader = []string{"row_number", "raw_line", "error"}
+
+// ImportErrors reports the rows of processor runs that were dead-lettered,
+// so data providers can see why each of their rows was rejected
+type ImportErrors struct {
+	runs        repository.RunRepository
+	deadLetters repository.DeadLetterRepository
+}
+
+// NewImportErrors creates a report reading runs and deadLetters
+func NewImportErrors(runs repository.RunRepository, deadLetters repository.DeadLetterRepository) *ImportErrors {
+	return &ImportErrors{runs: runs, deadLetters: deadLetters}
+}
+
+// Write writes the dead-letter entries of a run to w in the given format,
+// in the order they were recorded. Entries are read a page at a time, so
+// runs with millions of rejected rows are never fully buffered. A run
+// still in progress reports the entries recorded so far.
+func (r *ImportErrors) Write(
+	ctx context.Context,
+	runID string,
+	format ExportFormat,
+	w io.Writer,
+) error {
+
+	run, err := r.runs.Get(ctx, runID)
+	if err != nil {
+		return err
+	}
+	if run == nil || run.Kind == models.RunExport {
+		return ErrRunNotFound
+	}
+
+	var write func(ImportError) error
+	var finish func() error
+	switch format {
+	case ExportFormatJSON:
+		write, finish, err = jsonImportErrors(w, run)
+	case ExportFormatCSV:
+		write, finish, err = csvImportErrors(w)
+	default:
+		return ErrUnsupportedExportFormat
+	}
+	if err != nil {
+		return err
+	}
+
+	var after uint64
+	for {
+		entries, err := r.deadLetters.List(ctx, runID, after, importErrorsPage)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := write(ImportError{RowNumber: e.RowNumber, RawLine: e.RawLine, Error: e.Error}); err != nil {
+				return err
+			}
+			after = e.ID
+		}
+		if len(entries) < importErrorsPage {
+			return finish()
+		}
+	}
+}
+
+func jsonImportErrors(w io.Writer, run *models.Run) (func(ImportError) error, func() error, error) {
+	head := `{"run_id":` + strconv.Quote(run.ID) +
+		`,"status":` + strconv.Quote(run.Status) +
+		`,"errors":[`
+	if _, err := io.WriteString(w, head); err != nil {
+		return nil, nil, err
+	}
+
+	first := true
+	write := func(e ImportError) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	finish := func() error {
+		_, err := io.WriteString(w, "]}\n")
+		return err
+	}
+	return write, finish, nil
+}
+
+func csvImportErrors(w io.Writer) (func(ImportError) error, func() error, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvImportErrorsHeader); err != nil {
+		return nil, nil, err
+	}
+
+	write := func(e ImportError) error {
+		return cw.Write([]string{strconv.Itoa(e.RowNumber), e.RawLine, e.Error})
+	}
+	finish := func() error {
+		cw.Flush()
+		return cw.Error()
+	}
+	return write, finish, nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// memoryRuns knows the runs in byID; the other methods are unused
+type memoryRuns struct {
+	repository.RunRepository
+	byID map[string]*models.Run
+}
+
+func (m memoryRuns) Get(ctx context.Context, id string) (*models.Run, error) {
+	return m.byID[id], nil
+}
+
+type memoryDeadLetters struct {
+	repository.DeadLetterRepository
+	entries []models.DeadLetter
+	queries int
+}
+
+func (m *memoryDeadLetters) List(ctx context.Context, runID string, afterID uint64, limit int) ([]models.DeadLetter, error) {
+	m.queries++
+	var out []models.DeadLetter
+	for _, e := range m.entries {
+		if e.RunID == runID && e.ID > afterID && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func TestImportErrors_CSV(t *testing.T) {
+	deadLetters := &memoryDeadLetters{}
+	total := importErrorsPage + 2
+	for i := 1; i <= total; i++ {
+		deadLetters.entries = append(deadLetters.entries, models.DeadLetter{
+			ID:        uint64(i * 2),
+			RunID:     "run-1",
+			RowNumber: i + 1,
+			RawLine:   fmt.Sprintf("abc,drug,\"Aspirina, %d\",{}", i),
+			Error:     "invalid user_id",
+		})
+	}
+	deadLetters.entries = append(deadLetters.entries, models.DeadLetter{ID: 9999, RunID: "run-2", RowNumber: 2})
+	report := NewImportErrors(memoryRuns{byID: map[string]*models.Run{"run-1": {ID: "run-1"}}}, deadLetters)
+
+	var buf bytes.Buffer
+	if err := report.Write(context.Background(), "run-1", ExportFormatCSV, &buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != total+1 || records[0][0] != "row_number" {
+		t.Fatalf("rows = %d (header %v), want %d", len(records), records[0], total+1)
+	}
+	if got := records[1]; got[0] != "2" || got[1] != `abc,drug,"Aspirina, 1",{}` || got[2] != "invalid user_id" {
+		t.Errorf("first row = %v", got)
+	}
+	if got := records[total][0]; got != fmt.Sprint(total+1) {
+		t.Errorf("last row_number = %s, want %d", got, total+1)
+	}
+	if deadLetters.queries != 2 {
+		t.Errorf("queries = %d, want 2", deadLetters.queries)
+	}
+}
+
+func TestImportErrors_JSON(t *testing.T) {
+	deadLetters := &memoryDeadLetters{entries: []models.DeadLetter{
+		{ID: 1, RunID: "run-1", RowNumber: 3, RawLine: "1,drug,,{}", Error: "empty name"},
+	}}
+	report := NewImportErrors(memoryRuns{byID: map[string]*models.Run{"run-1": {ID: "run-1", Status: models.RunSucceeded}}}, deadLetters)
+
+	var buf bytes.Buffer
+	if err := report.Write(context.Background(), "run-1", ExportFormatJSON, &buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var doc struct {
+		RunID  string        `json:"run_id"`
+		Status string        `json:"status"`
+		Errors []ImportError `json:"errors"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+	}
+	if doc.RunID != "run-1" || doc.Status != models.RunSucceeded || len(doc.Errors) != 1 || doc.Errors[0].RowNumber != 3 {
+		t.Errorf("unexpected report: %+v", doc)
+	}
+}
+
+func TestImportErrors_UnknownRun(t *testing.T) {
+	report := NewImportErrors(memoryRuns{byID: map[string]*models.Run{"export-1": {ID: "export-1", Kind: models.RunExport}}}, &memoryDeadLetters{})
+
+	var buf bytes.Buffer
+	for _, id := range []string{"missing", "export-1"} {
+		if err := report.Write(context.Background(), id, ExportFormatCSV, &buf); !errors.Is(err, ErrRunNotFound) {
+			t.Fatalf("Write(%q) error = %v, want ErrRunNotFound", id, err)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("nothing should be written, got %q", buf.String())
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+var (
+	// ErrImportSourceRequired is returned for an import request with
+	// neither a file nor a URL
+	ErrImportSourceRequired = apperrors.New("a file or a url is required", apperrors.ErrValidation)
+	// ErrImportNotRunning is returned when cancelling an import that has
+	// already finished
+	ErrImportNotRunning = apperrors.New("the import is not running", apperrors.ErrConflict)
+	// ErrInvalidImportFilter is returned for an unknown status or before
+	// in an import listing
+	ErrInvalidImportFilter = apperrors.New("invalid import filter", apperrors.ErrValidation)
+)
+
+// ImportStarter stores a data file and imports it in the background: an
+// upload named name by the client, or the object at an s3:// URL. Cancel
+// stops the import runID when this instance runs it.
+type ImportStarter interface {
+	Start(ctx context.Context, name string, body io.Reader) (*models.Run, error)
+	StartS3(ctx context.Context, rawURL string) (*models.Run, error)
+	Cancel(runID string) bool
+}
+
+// ImportFilter selects the imports of a listing; empty fields do not
+// filter
+type ImportFilter struct {
+	Status string
+	// Before is the ID of the last import of the previous page
+	Before string
+	Limit  int
+}
+
+// ImportRequest starts the import of a CSV stored in S3, as an
+// alternative to uploading it
+type ImportRequest struct {
+	URL string `json:"url" form:"url" example:"s3://partner-drops/segmentations/2026-10-17.csv"`
+}
+
+// ImportJob is an import as the API shows it, with the counters of the
+// processor run
+type ImportJob struct {
+	ID         string `json:"id" example:"0b6f8d2e-4c1a-4f3b-9a7d-5e2c1b0a9f8e"`
+	Status     string `json:"status" enums:"running,succeeded,failed,cancelled"`
+	Source     string `json:"source" example:"upload:segmentations.csv"`
+	RowsRead   uint64 `json:"rows_read"`
+	Inserted   uint64 `json:"inserted"`
+	Updated    uint64 `json:"updated"`
+	Duplicates uint64 `json:"duplicates"`
+	Invalid    uint64 `json:"invalid"`
+	Failed     uint64 `json:"failed"`
+	Error      string `json:"error,omitempty"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+	// CancelRequestedAt is when the cancellation was requested; the import
+	// stays running until the processor notices it
+	CancelRequestedAt int64 `json:"cancel_requested_at,omitempty"`
+}
+
+// Imports starts the imports of POST /imports and follows the imports in
+// the runs table, whichever instance or command runs them
+type Imports struct {
+	runs    repository.RunRepository
+	starter ImportStarter
+}
+
+// NewImports follows the imports recorded in runs; starter, nil when this
+// instance does not import files, starts and cancels them locally
+func NewImports(runs repository.RunRepository, starter ImportStarter) *Imports {
+	return &Imports{runs: runs, starter: starter}
+}
+
+// Upload stores the uploaded file and starts its import
+func (s *Imports) Upload(ctx context.Context, name string, body io.Reader) (*ImportJob, error) {
+	run, err := s.starter.Start(ctx, name, body)
+	if err != nil {
+		return nil, err
+	}
+	return importView(run), nil
+}
+
+// Fetch starts the import of the file at the URL of req
+func (s *Imports) Fetch(ctx context.Context, req ImportRequest) (*ImportJob, error) {
+	if req.URL == "" {
+		return nil, ErrImportSourceRequired
+	}
+	run, err := s.starter.StartS3(ctx, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	return importView(run), nil
+}
+
+// List returns the imports of filter, started most recently first, with
+// the counters written so far by the running ones
+func (s *Imports) List(ctx context.Context, filter ImportFilter) ([]ImportJob, error) {
+	if filter.Status != "" && !slices.Contains([]string{models.RunRunning, models.RunSucceeded, models.RunFailed, models.RunCancelled}, filter.Status) {
+		return nil, fmt.Errorf("%w: status must be running, succeeded, failed or cancelled", ErrInvalidImportFilter)
+	}
+	runFilter := repository.RunFilter{Kind: models.RunImport, Status: filter.Status, Limit: filter.Limit}
+	if filter.Before != "" {
+		before, err := s.find(ctx, filter.Before)
+		if err == ErrRunNotFound {
+			return nil, fmt.Errorf("%w: before must be the ID of an import", ErrInvalidImportFilter)
+		}
+		if err != nil {
+			return nil, err
+		}
+		runFilter.Before = before
+	}
+
+	runs, err := s.runs.List(ctx, runFilter)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]ImportJob, 0, len(runs))
+	for i := range runs {
+		jobs = append(jobs, *importView(&runs[i]))
+	}
+	return jobs, nil
+}
+
+// Get returns the import with the ID
+func (s *Imports) Get(ctx context.Context, id string) (*ImportJob, error) {
+	run, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return importView(run), nil
+}
+
+// Cancel requests the cancellation of a running import. The request is
+// recorded in the runs table, where the processor running the import
+// checks it with its progress; the import is cancelled at once when this
+// instance runs it. Cancelling an import already being cancelled is a
+// no-op.
+func (s *Imports) Cancel(ctx context.Context, id string) (*ImportJob, error) {
+	run, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != models.RunRunning {
+		return nil, ErrImportNotRunning
+	}
+	requested, err := s.runs.RequestCancel(ctx, id, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if !requested {
+		// finished meanwhile, or already requested
+		if run, err = s.find(ctx, id); err != nil {
+			return nil, err
+		}
+		if run.Status != models.RunRunning {
+			return nil, ErrImportNotRunning
+		}
+	}
+	if s.starter != nil {
+		s.starter.Cancel(id)
+	}
+
+	run, err = s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return importView(run), nil
+}
+
+// find returns the import with the ID, or ErrRunNotFound
+func (s *Imports) find(ctx context.Context, id string) (*models.Run, error) {
+	run, err := s.runs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil || run.Kind != models.RunImport {
+		return nil, ErrRunNotFound
+	}
+	return run, nil
+}
+
+func importView(run *models.Run) *ImportJob {
+	return &ImportJob{
+		ID:         run.ID,
+		Status:     run.Status,
+		Source:     run.Source,
+		RowsRead:   run.RowsRead,
+		Inserted:   run.Inserted,
+		Updated:    run.Updated,
+		Duplicates: run.Duplicates,
+		Invalid:    run.Invalid,
+		Failed:     run.Failed,
+		Error:      run.Error,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+
+		CancelRequestedAt: run.CancelRequestedAt,
+	}
+}
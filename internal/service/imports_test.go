@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+type recordingImports struct {
+	name, content, url string
+	cancelled          []string
+}
+
+func (s *recordingImports) Cancel(runID string) bool {
+	s.cancelled = append(s.cancelled, runID)
+	return true
+}
+
+func (s *recordingImports) Start(ctx context.Context, name string, body io.Reader) (*models.Run, error) {
+	b, _ := io.ReadAll(body)
+	s.name, s.content = name, string(b)
+	return &models.Run{ID: "import-1", Status: models.RunRunning, Source: "upload:" + name, StartedAt: 1760000000}, nil
+}
+
+func (s *recordingImports) StartS3(ctx context.Context, rawURL string) (*models.Run, error) {
+	s.url = rawURL
+	return &models.Run{ID: "import-2", Status: models.RunRunning, Source: rawURL}, nil
+}
+
+func TestImports(t *testing.T) {
+	starter := &recordingImports{}
+	imports := NewImports(memoryRuns{}, starter)
+
+	job, err := imports.Upload(context.Background(), "daily.csv", strings.NewReader("user_id\n"))
+	if err != nil || job.ID != "import-1" || job.Source != "upload:daily.csv" || job.StartedAt != 1760000000 {
+		t.Fatalf("Upload() = %+v, %v", job, err)
+	}
+	if starter.name != "daily.csv" || starter.content != "user_id\n" {
+		t.Errorf("unexpected upload %q: %q", starter.name, starter.content)
+	}
+
+	job, err = imports.Fetch(context.Background(), ImportRequest{URL: "s3://drops/daily.csv"})
+	if err != nil || job.ID != "import-2" || starter.url != "s3://drops/daily.csv" {
+		t.Errorf("Fetch() = %+v, %v", job, err)
+	}
+	if _, err := imports.Fetch(context.Background(), ImportRequest{}); !errors.Is(err, ErrImportSourceRequired) {
+		t.Errorf("Fetch() without a URL error = %v, want ErrImportSourceRequired", err)
+	}
+}
+
+// importRuns adds the listing and the cancellation requests to memoryRuns
+type importRuns struct {
+	memoryRuns
+	filters []repository.RunFilter
+}
+
+func (m *importRuns) List(ctx context.Context, filter repository.RunFilter) ([]models.Run, error) {
+	m.filters = append(m.filters, filter)
+	return []models.Run{*m.byID["import-1"]}, nil
+}
+
+func (m *importRuns) RequestCancel(ctx context.Context, id string, at int64) (bool, error) {
+	run := m.byID[id]
+	if run.Status != models.RunRunning || run.CancelRequestedAt != 0 {
+		return false, nil
+	}
+	run.CancelRequestedAt = at
+	return true, nil
+}
+
+func TestImports_ListGetCancel(t *testing.T) {
+	runs := &importRuns{memoryRuns: memoryRuns{byID: map[string]*models.Run{
+		"import-1": {ID: "import-1", Kind: models.RunImport, Status: models.RunRunning, RowsRead: 40},
+		"import-2": {ID: "import-2", Kind: models.RunImport, Status: models.RunSucceeded},
+		"export-1": {ID: "export-1", Kind: models.RunExport, Status: models.RunRunning},
+	}}}
+	starter := &recordingImports{}
+	imports := NewImports(runs, starter)
+	ctx := context.Background()
+
+	jobs, err := imports.List(ctx, ImportFilter{Status: models.RunRunning, Before: "import-2", Limit: 10})
+	if err != nil || len(jobs) != 1 || jobs[0].RowsRead != 40 {
+		t.Fatalf("List() = %+v, %v", jobs, err)
+	}
+	if f := runs.filters[0]; f.Kind != models.RunImport || f.Status != models.RunRunning || f.Before.ID != "import-2" || f.Limit != 10 {
+		t.Errorf("unexpected filter %+v", f)
+	}
+	for _, filter := range []ImportFilter{{Status: "done"}, {Before: "export-1"}, {Before: "missing"}} {
+		if _, err := imports.List(ctx, filter); !errors.Is(err, ErrInvalidImportFilter) {
+			t.Errorf("List(%+v) error = %v, want ErrInvalidImportFilter", filter, err)
+		}
+	}
+
+	if _, err := imports.Get(ctx, "export-1"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("Get() of an export error = %v, want ErrRunNotFound", err)
+	}
+
+	job, err := imports.Cancel(ctx, "import-1")
+	if err != nil || job.Status != models.RunRunning || job.CancelRequestedAt == 0 {
+		t.Fatalf("Cancel() = %+v, %v", job, err)
+	}
+	if len(starter.cancelled) != 1 || starter.cancelled[0] != "import-1" {
+		t.Errorf("the local import should be cancelled, got %v", starter.cancelled)
+	}
+	// a second request is a no-op
+	if again, err := imports.Cancel(ctx, "import-1"); err != nil || again.CancelRequestedAt != job.CancelRequestedAt {
+		t.Errorf("second Cancel() = %+v, %v", again, err)
+	}
+	if _, err := imports.Cancel(ctx, "import-2"); !errors.Is(err, ErrImportNotRunning) {
+		t.Errorf("Cancel() of a finished import error = %v, want ErrImportNotRunning", err)
+	}
+	if _, err := imports.Cancel(ctx, "missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("Cancel() of an unknown import error = %v, want ErrRunNotFound", err)
+	}
+}
@@ -27,12 +27,28 @@ func (m *RepositoryMock) FindByUserID(ctx context.Context, userID uint64) ([]mod
 	return m.findByUserIDResult, nil
 }
 
+func (m *RepositoryMock) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func (m *RepositoryMock) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
 	m.upsertCalled = true
 	m.upsertInput = s
 	return m.upsertResult, nil
 }
 
+func (m *RepositoryMock) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *RepositoryMock) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *RepositoryMock) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
 // TestIntegration_ServiceCallsRepositoryFindByUserID verifies service -> repository flow
 func TestIntegration_ServiceCallsRepositoryFindByUserID(t *testing.T) {
 	mockRepo := &RepositoryMock{
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// SupportedLocales are the locales group labels can be served in; the first
+// one is the default
+var SupportedLocales = []language.Tag{
+	language.BrazilianPortuguese,
+	language.English,
+}
+
+var localeMatcher = language.NewMatcher(SupportedLocales)
+
+// MatchLocale picks the supported locale that best matches an
+// Accept-Language header. It returns "" when the header is absent or
+// nothing acceptable matches, in which case no labels are rendered.
+func MatchLocale(acceptLanguage string) string {
+	if strings.TrimSpace(acceptLanguage) == "" {
+		return ""
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return ""
+	}
+
+	_, idx, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return ""
+	}
+	return SupportedLocales[idx].String()
+}
+
+// GroupLabels returns the localized display label of each group key of a
+// response (e.g. "drugs" -> "Medicamentos"), sourced from the type
+// registry. Groups whose type is not registered are left out.
+func (s *SegmentationService) GroupLabels(groups []string, locale string) map[string]string {
+	if s.types == nil || locale == "" {
+		return nil
+	}
+
+	labels := make(map[string]string, len(groups))
+	for _, group := range groups {
+		t, ok := s.types.lookup(groupType(group))
+		if !ok {
+			continue
+		}
+		labels[group] = t.label(locale)
+	}
+	return labels
+}
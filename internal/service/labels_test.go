@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"pt-BR", "pt-BR"},
+		{"pt", "pt-BR"},
+		{"en-US,en;q=0.9", "en"},
+		{"fr-FR,en;q=0.5", "en"},
+		{"fr-FR", ""},
+		{";;;", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := MatchLocale(tt.header); got != tt.want {
+				t.Errorf("MatchLocale(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupLabels(t *testing.T) {
+	repo := newMemoryTypeRepository(
+		models.SegmentationType{
+			Name:        "drug",
+			DisplayName: "Medicamentos",
+			Active:      true,
+			Labels:      datatypes.JSON(`{"pt-BR": "Medicamentos", "en": "Drugs"}`),
+		},
+		models.SegmentationType{Name: "specialty", DisplayName: "Especialidades", Active: true},
+	)
+	reg := NewTypeRegistry(repo, 0)
+	if err := reg.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	svc := NewSegmentationService(nil, WithTypeRegistry(reg))
+
+	groups := []string{"drugs", "specialties", "unknowns"}
+
+	en := svc.GroupLabels(groups, "en")
+	if en["drugs"] != "Drugs" {
+		t.Errorf("en drugs = %q, want Drugs", en["drugs"])
+	}
+	if en["specialties"] != "Especialidades" {
+		t.Errorf("en specialties = %q, want display name fallback", en["specialties"])
+	}
+	if _, ok := en["unknowns"]; ok {
+		t.Errorf("unregistered group should have no label")
+	}
+
+	if got := svc.GroupLabels(groups, "pt-BR")["drugs"]; got != "Medicamentos" {
+		t.Errorf("pt-BR drugs = %q, want Medicamentos", got)
+	}
+	if got := svc.GroupLabels(groups, "en-GB")["drugs"]; got != "Drugs" {
+		t.Errorf("en-GB drugs = %q, want base language fallback", got)
+	}
+
+	if got := NewSegmentationService(nil).GroupLabels(groups, "en"); got != nil {
+		t.Errorf("without registry labels = %v, want nil", got)
+	}
+}
+
+func TestTypeRegistry_Labels(t *testing.T) {
+	reg := NewTypeRegistry(newMemoryTypeRepository(), 0)
+
+	created, err := reg.Register(context.Background(), TypeRequest{
+		Name:   "exam",
+		Labels: map[string]string{"pt-br": "Exames", "en": "Exams"},
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if string(created.Labels) != `{"en":"Exams","pt-BR":"Exames"}` {
+		t.Errorf("labels = %s, want canonical tags", created.Labels)
+	}
+
+	_, err = reg.Register(context.Background(), TypeRequest{
+		Name:   "bad",
+		Labels: map[string]string{"not a locale!": "x"},
+	})
+	if !errors.Is(err, ErrInvalidType) {
+		t.Errorf("invalid locale error = %v, want ErrInvalidType", err)
+	}
+
+	updated, err := reg.Update(context.Background(), "exam", TypePatch{Labels: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.Labels != nil {
+		t.Errorf("empty labels patch should clear labels, got %s", updated.Labels)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NamePolicy decides when two segmentation names are the same segmentation.
+// Names are always put in NFC and trimmed with inner whitespace collapsed; the key that
+// identifies a row can also ignore case and accents, while the stored name
+// keeps the spelling of the last write for display.
+type NamePolicy struct {
+	// FoldCase makes "Cardiologia" and "cardiologia" the same key
+	FoldCase bool
+	// StripAccents makes "Dipirona Sódica" and "Dipirona Sodica" the same key
+	StripAccents bool
+}
+
+// Normalize puts name in NFC, trims it and collapses its inner whitespace
+// to single spaces
+func (p NamePolicy) Normalize(name string) string {
+	return strings.Join(strings.Fields(nfc(name)), " ")
+}
+
+// nfc returns s in Unicode Normalization Form C, so the same text typed on
+// different systems ("ó" precomposed, or "o" followed by a combining
+// accent, as macOS file names and some CSV exports have it) has the same
+// bytes. It does not repair text decoded with the wrong charset.
+func nfc(s string) string {
+	return norm.NFC.String(s)
+}
+
+// Key returns the unique key of a normalized name
+func (p NamePolicy) Key(name string) string {
+	if p.StripAccents {
+		// NFD separates the accents into combining marks, which are dropped
+		t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		if stripped, _, err := transform.String(t, name); err == nil {
+			name = stripped
+		}
+	}
+	if p.FoldCase {
+		name = strings.ToLower(name)
+	}
+	return name
+}
@@ -0,0 +1,31 @@
+package service
+
+import "testing"
+
+func TestNamePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   NamePolicy
+		in       string
+		wantName string
+		wantKey  string
+	}{
+		{name: "default", in: "  Dipirona   Sódica\t", wantName: "Dipirona Sódica", wantKey: "Dipirona Sódica"},
+		{name: "fold case", policy: NamePolicy{FoldCase: true}, in: "Cardiologia ", wantName: "Cardiologia", wantKey: "cardiologia"},
+		{name: "strip accents", policy: NamePolicy{StripAccents: true}, in: "Dipirona Sódica", wantName: "Dipirona Sódica", wantKey: "Dipirona Sodica"},
+		{name: "decomposed", in: "Antibio\u0301ticos", wantName: "Antibióticos", wantKey: "Antibióticos"},
+		{name: "both", policy: NamePolicy{FoldCase: true, StripAccents: true}, in: "ANTI-INFLAMATÓRIOS", wantName: "ANTI-INFLAMATÓRIOS", wantKey: "anti-inflamatorios"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := tt.policy.Normalize(tt.in)
+			if name != tt.wantName {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, name, tt.wantName)
+			}
+			if key := tt.policy.Key(name); key != tt.wantKey {
+				t.Errorf("Key(%q) = %q, want %q", name, key, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"segmentation-api/internal/models"
+)
+
+// Kinds of a Change
+const (
+	ChangeUpserted = "upserted"
+	ChangeDeleted  = "deleted"
+)
+
+// Change tells that one segmentation of a user was written. It carries no
+// data: it is a hint for caches and lightweight consumers, which read what
+// they need from the API.
+type Change struct {
+	Kind   string `json:"kind"`
+	UserID uint64 `json:"user_id"`
+	Type   string `json:"segmentation_type"`
+	Name   string `json:"segmentation_name"`
+}
+
+// Notifier is told about the writes of the service once they are applied,
+// and about the writes of a transaction once it commits. Notify is called
+// on the path of the write, so it must not block: implementations queue
+// the changes and drop them when they cannot keep up.
+type Notifier interface {
+	Notify(changes []Change)
+}
+
+// WithNotifier tells n about every write of the service
+func WithNotifier(n Notifier) Option {
+	return func(s *SegmentationService) {
+		s.notifier = n
+	}
+}
+
+// changeBuffer holds the changes of a transaction until it commits
+type changeBuffer struct {
+	changes []Change
+}
+
+func (b *changeBuffer) Notify(changes []Change) {
+	b.changes = append(b.changes, changes...)
+}
+
+// notify passes changes to the notifier, if any
+func (s *SegmentationService) notify(changes ...Change) {
+	if s.notifier != nil && len(changes) > 0 {
+		s.notifier.Notify(changes)
+	}
+}
+
+// upserted returns the changes of writing segs
+func upserted(segs ...*models.Segmentation) []Change {
+	changes := make([]Change, 0, len(segs))
+	for _, seg := range segs {
+		changes = append(changes, Change{
+			Kind:   ChangeUpserted,
+			UserID: seg.UserID,
+			Type:   seg.SegmentationType,
+			Name:   seg.SegmentationName,
+		})
+	}
+	return changes
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// recordingNotifier keeps the changes it was told about
+type recordingNotifier struct {
+	changes []Change
+}
+
+func (n *recordingNotifier) Notify(changes []Change) {
+	n.changes = append(n.changes, changes...)
+}
+
+func TestNotifier(t *testing.T) {
+	repo := seededMemoryRepository()
+	notifier := &recordingNotifier{}
+	svc := NewSegmentationService(repo, WithNotifier(notifier))
+	ctx := context.Background()
+
+	if _, _, err := svc.Upsert(ctx, 10, UpsertRequest{SegmentationType: "drug", SegmentationName: "Ibuprofeno"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, 10, "drugs", "Dipirona"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := svc.Replace(ctx, 10, ReplaceRequest{Segmentations: map[string][]SegmentationInput{
+		"specialties": {{Name: "Cardiologia", Data: json.RawMessage(`{"years": 5}`)}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{Kind: ChangeUpserted, UserID: 10, Type: "drug", Name: "Ibuprofeno"},
+		{Kind: ChangeDeleted, UserID: 10, Type: "drug", Name: "Dipirona"},
+		// the replace leaves Cardiologia unchanged and deletes the drugs
+		{Kind: ChangeDeleted, UserID: 10, Type: "drug", Name: "Aspirina"},
+		{Kind: ChangeDeleted, UserID: 10, Type: "drug", Name: "Ibuprofeno"},
+	}
+	if len(notifier.changes) != len(want) {
+		t.Fatalf("changes = %+v, want %+v", notifier.changes, want)
+	}
+	for i := range want {
+		if notifier.changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, notifier.changes[i], want[i])
+		}
+	}
+}
+
+func TestNotifier_Transaction(t *testing.T) {
+	repo := seededMemoryRepository()
+	notifier := &recordingNotifier{}
+	svc := NewSegmentationService(repo, WithNotifier(notifier))
+	ctx := context.Background()
+
+	err := svc.Transaction(ctx, func(tx *SegmentationService) error {
+		if _, _, err := tx.Upsert(ctx, 11, UpsertRequest{SegmentationType: "drug", SegmentationName: "Ibuprofeno"}); err != nil {
+			return err
+		}
+		if len(notifier.changes) != 0 {
+			t.Error("changes should wait for the commit")
+		}
+		return nil
+	})
+	if err != nil || len(notifier.changes) != 1 || notifier.changes[0].UserID != 11 {
+		t.Fatalf("Transaction() = %v, changes %+v", err, notifier.changes)
+	}
+
+	err = svc.Transaction(ctx, func(tx *SegmentationService) error {
+		tx.Upsert(ctx, 12, UpsertRequest{SegmentationType: "drug", SegmentationName: "Ibuprofeno"})
+		return errors.New("rolled back")
+	})
+	if err == nil || len(notifier.changes) != 1 {
+		t.Errorf("a rolled back transaction should notify nothing, got %+v", notifier.changes)
+	}
+}
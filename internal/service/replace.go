@@ -0,0 +1,362 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// ErrInvalidSegmentation is returned when a write payload fails validation
+var ErrInvalidSegmentation = apperrors.New("invalid segmentation", apperrors.ErrValidation)
+
+const (
+	maxTypeLength = 50
+	maxNameLength = 100
+)
+
+// SegmentationInput is one segmentation of a write payload
+type SegmentationInput struct {
+	Name string          `json:"name" validate:"required" maxLength:"100" example:"Aspirina"`
+	Data json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// ReplaceRequest is the complete grouped segmentation set of a user, keyed by
+// the same group names returned by GetByUserID (e.g. "drugs", "specialties")
+type ReplaceRequest struct {
+	Segmentations map[string][]SegmentationInput `json:"segmentations"`
+}
+
+// ReplaceResult summarizes the changes applied by Replace
+type ReplaceResult struct {
+	UserID    uint64    `json:"user_id"`
+	Inserted  int       `json:"inserted"`
+	Updated   int       `json:"updated"`
+	Deleted   int       `json:"deleted"`
+	Unchanged int       `json:"unchanged"`
+	Warnings  []Warning `json:"warnings,omitempty"`
+}
+
+type segmentationKey struct {
+	group string
+	name  string
+}
+
+// Replace makes the stored segmentations of a user match req exactly.
+// The current rows are diffed against the payload and the resulting
+// inserts, updates and deletes are applied in a single transaction.
+func (s *SegmentationService) Replace(
+	ctx context.Context,
+	userID uint64,
+	req ReplaceRequest,
+) (*ReplaceResult, error) {
+
+	if userID == 0 {
+		return nil, fmt.Errorf("%w: user_id must be greater than zero", ErrInvalidSegmentation)
+	}
+
+	desired, order, warnings, err := s.buildDesiredSet(userID, req, s.storedTypes(ctx, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReplaceResult{UserID: userID}
+	var changes []Change
+
+	err = s.repo.Transaction(ctx, func(tx repository.SegmentationRepository) error {
+		// reset counters in case the transaction is retried
+		*result = ReplaceResult{UserID: userID, Warnings: warnings}
+		changes = changes[:0]
+
+		current, err := tx.FindByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[segmentationKey]bool, len(current))
+		var toDelete []uint64
+
+		for _, row := range current {
+			key := segmentationKey{group: normalizeType(row.SegmentationType), name: row.UniqueName()}
+			want, ok := desired[key]
+			if !ok || seen[key] {
+				toDelete = append(toDelete, row.ID)
+				changes = append(changes, Change{
+					Kind:   ChangeDeleted,
+					UserID: userID,
+					Type:   row.SegmentationType,
+					Name:   row.SegmentationName,
+				})
+				continue
+			}
+			seen[key] = true
+
+			if row.SegmentationName == want.SegmentationName && jsonEqual(row.Data, want.Data) {
+				result.Unchanged++
+				continue
+			}
+
+			// preserve the stored spelling of the type so the unique key matches
+			want.SegmentationType = row.SegmentationType
+			if _, err := tx.Upsert(ctx, want); err != nil {
+				return err
+			}
+			result.Updated++
+			changes = append(changes, upserted(want)...)
+		}
+
+		for _, key := range order {
+			if seen[key] {
+				continue
+			}
+			res, err := tx.Upsert(ctx, desired[key])
+			if err != nil {
+				return err
+			}
+			if res == repository.UpsertInserted {
+				result.Inserted++
+			} else {
+				result.Updated++
+			}
+			changes = append(changes, upserted(desired[key])...)
+		}
+
+		deleted, err := tx.DeleteByIDs(ctx, toDelete)
+		if err != nil {
+			return err
+		}
+		result.Deleted = int(deleted)
+
+		return nil
+	})
+	s.invalidate(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(changes...)
+
+	s.logger.Info("segmentations_replaced",
+		zap.Uint64("user_id", userID),
+		zap.Int("inserted", result.Inserted),
+		zap.Int("updated", result.Updated),
+		zap.Int("deleted", result.Deleted),
+		zap.Int("unchanged", result.Unchanged),
+	)
+
+	return result, nil
+}
+
+// buildDesiredSet validates the payload and flattens it into segmentations
+// keyed by group and name, each group resolved to the type it was read
+// from (see resolveType). The returned slice fixes the write order so
+// replays of the same payload behave identically.
+func (s *SegmentationService) buildDesiredSet(
+	userID uint64,
+	req ReplaceRequest,
+	stored func() ([]string, error),
+) (map[segmentationKey]*models.Segmentation, []segmentationKey, []Warning, error) {
+
+	desired := make(map[segmentationKey]*models.Segmentation)
+	var order []segmentationKey
+	var warnings []Warning
+
+	groups := make([]string, 0, len(req.Segmentations))
+	for group := range req.Segmentations {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		items := req.Segmentations[group]
+		segType, err := s.resolveType(group, stored)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for i, item := range items {
+			seg, err := newSegmentation(userID, segType, item.Name, item.Data)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			itemWarnings, err := s.Validate(seg)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			for _, w := range itemWarnings {
+				w.Field = fmt.Sprintf("segmentations.%s[%d].%s", group, i, w.Field)
+				warnings = append(warnings, w)
+			}
+
+			key := segmentationKey{group: normalizeType(seg.SegmentationType), name: seg.UniqueName()}
+			if _, dup := desired[key]; dup {
+				return nil, nil, nil, fmt.Errorf("%w: duplicate name %q in %q", ErrInvalidSegmentation, seg.SegmentationName, group)
+			}
+
+			desired[key] = seg
+			order = append(order, key)
+		}
+	}
+
+	return desired, order, warnings, nil
+}
+
+// newSegmentation validates and trims a single write and builds the model
+// persisted by the repository. segType may be given singular ("drug") or as
+// its group key ("drugs").
+func newSegmentation(userID uint64, segType, name string, data []byte) (*models.Segmentation, error) {
+	segType = denormalizeType(nfc(strings.TrimSpace(segType)))
+	if segType == "" {
+		return nil, fmt.Errorf("%w: empty segmentation type", ErrInvalidSegmentation)
+	}
+	if len(segType) > maxTypeLength {
+		return nil, fmt.Errorf("%w: segmentation type %q exceeds %d characters", ErrInvalidSegmentation, segType, maxTypeLength)
+	}
+
+	name = nfc(strings.TrimSpace(name))
+	if name == "" {
+		return nil, fmt.Errorf("%w: empty name for type %q", ErrInvalidSegmentation, segType)
+	}
+	if len(name) > maxNameLength {
+		return nil, fmt.Errorf("%w: name %q exceeds %d characters", ErrInvalidSegmentation, name, maxNameLength)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%w: invalid data for %q", ErrInvalidSegmentation, name)
+	}
+
+	return &models.Segmentation{
+		UserID:           userID,
+		SegmentationType: segType,
+		SegmentationName: name,
+		Data:             datatypes.JSON(data),
+	}, nil
+}
+
+// denormalizeType maps the built-in group keys back to the singular type
+// stored in the database; any other input is a type and is kept as given,
+// so a type ending in "s" such as "status" is not rewritten
+func denormalizeType(group string) string {
+	switch strings.ToLower(group) {
+	case "specialties":
+		return "specialty"
+	case "drugs":
+		return "drug"
+	case "patients":
+		return "patient"
+	default:
+		return group
+	}
+}
+
+// groupType is the exact inverse of normalizeType, for group keys built by
+// it: the type whose group key is group
+func groupType(group string) string {
+	if t := denormalizeType(group); t != group {
+		return t
+	}
+	return strings.TrimSuffix(group, "s")
+}
+
+// resolveType returns the stored type of segType, given singular or as its
+// group key. The built-in group keys map to their type and a type the
+// registry or the deprecated types rule knows is kept. Any other group key
+// resolves through groupType when that type is known or is one the user
+// already has, as listed by stored, so the "exams" returned for an
+// unregistered "exam" finds its rows again. stored, which may be nil, is
+// only called for input that ends in "s"; anything left unresolved is kept
+// as given.
+func (s *SegmentationService) resolveType(segType string, stored func() ([]string, error)) (string, error) {
+	segType = denormalizeType(nfc(strings.TrimSpace(segType)))
+	if s.knownType(segType) {
+		return segType, nil
+	}
+	singular := groupType(segType)
+	if singular == segType || singular == "" {
+		return segType, nil
+	}
+	if s.knownType(singular) {
+		return singular, nil
+	}
+	if stored == nil {
+		return segType, nil
+	}
+
+	types, err := stored()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range types {
+		if strings.EqualFold(normalizeType(t), segType) {
+			return t, nil
+		}
+	}
+	return segType, nil
+}
+
+// storedTypes lists the types user userID already has, for resolveType.
+// The rows are read on the first call only.
+func (s *SegmentationService) storedTypes(ctx context.Context, userID uint64) func() ([]string, error) {
+	var types []string
+	loaded := false
+	return func() ([]string, error) {
+		if loaded {
+			return types, nil
+		}
+		rows, err := s.repo.FindByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		types, loaded = rowTypes(rows), true
+		return types, nil
+	}
+}
+
+// rowTypes returns the type of each row
+func rowTypes(rows []models.Segmentation) []string {
+	types := make([]string, len(rows))
+	for i, row := range rows {
+		types[i] = row.SegmentationType
+	}
+	return types
+}
+
+// knownType reports whether segType is registered or mapped by the
+// deprecated types rule
+func (s *SegmentationService) knownType(segType string) bool {
+	if _, ok := s.rules.DeprecatedTypes[strings.ToLower(segType)]; ok {
+		return true
+	}
+	if s.types == nil {
+		return false
+	}
+	_, ok := s.types.lookup(segType)
+	return ok
+}
+
+// jsonEqual compares two JSON documents semantically, since MySQL rewrites
+// stored JSON (key order, whitespace)
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		return bytes.Equal(a, b)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
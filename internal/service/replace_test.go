@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"gorm.io/datatypes"
+)
+
+// memoryRepository is a transactional in-memory repository: writes made
+// inside Transaction are discarded when fn returns an error
+type memoryRepository struct {
+	rows      []models.Segmentation
+	nextID    uint64
+	failOn    string
+	txStarted int
+}
+
+func (m *memoryRepository) FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+	var out []models.Segmentation
+	for _, r := range m.rows {
+		if r.UserID == userID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
+func (m *memoryRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+	if m.failOn != "" && s.SegmentationName == m.failOn {
+		return repository.UpsertNoOp, errors.New("forced failure")
+	}
+	for i, r := range m.rows {
+		if r.UserID == s.UserID && r.SegmentationType == s.SegmentationType && r.UniqueName() == s.UniqueName() {
+			m.rows[i].SegmentationName = s.SegmentationName
+			m.rows[i].Data = s.Data
+			return repository.UpsertUpdated, nil
+		}
+	}
+	m.nextID++
+	row := *s
+	row.ID = m.nextID
+	m.rows = append(m.rows, row)
+	return repository.UpsertInserted, nil
+}
+
+func (m *memoryRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	drop := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	kept := m.rows[:0]
+	var n int64
+	for _, r := range m.rows {
+		if drop[r.ID] {
+			n++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	m.rows = kept
+	return n, nil
+}
+
+func (m *memoryRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *memoryRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	m.txStarted++
+	snapshot := append([]models.Segmentation(nil), m.rows...)
+	if err := fn(m); err != nil {
+		m.rows = snapshot
+		return err
+	}
+	return nil
+}
+
+func seededMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		nextID: 3,
+		rows: []models.Segmentation{
+			{ID: 1, UserID: 10, SegmentationType: "drug", SegmentationName: "Aspirina", Data: datatypes.JSON(`{"dose": "500mg", "unit": "mg"}`)},
+			{ID: 2, UserID: 10, SegmentationType: "drug", SegmentationName: "Dipirona", Data: datatypes.JSON(`{"dose": "1g"}`)},
+			{ID: 3, UserID: 10, SegmentationType: "specialty", SegmentationName: "Cardiologia", Data: datatypes.JSON(`{"years": 5}`)},
+		},
+	}
+}
+
+func TestReplace_AppliesDiff(t *testing.T) {
+	repo := seededMemoryRepository()
+	svc := NewSegmentationService(repo)
+
+	req := ReplaceRequest{
+		Segmentations: map[string][]SegmentationInput{
+			"drugs": {
+				// same document, different key order: unchanged
+				{Name: "Aspirina", Data: json.RawMessage(`{"unit": "mg", "dose": "500mg"}`)},
+				{Name: "Ibuprofeno", Data: json.RawMessage(`{"dose": "400mg"}`)},
+			},
+			"specialties": {
+				{Name: "Cardiologia", Data: json.RawMessage(`{"years": 6}`)},
+			},
+		},
+	}
+
+	result, err := svc.Replace(context.Background(), 10, req)
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	if result.Inserted != 1 || result.Updated != 1 || result.Deleted != 1 || result.Unchanged != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if repo.txStarted != 1 {
+		t.Errorf("expected exactly one transaction, got %d", repo.txStarted)
+	}
+
+	rows, _ := repo.FindByUserID(context.Background(), 10)
+	names := make(map[string]string)
+	for _, r := range rows {
+		names[r.SegmentationName] = r.SegmentationType
+	}
+	if _, ok := names["Dipirona"]; ok {
+		t.Error("Dipirona should have been deleted")
+	}
+	if names["Ibuprofeno"] != "drug" {
+		t.Errorf("Ibuprofeno should be stored with singular type, got %q", names["Ibuprofeno"])
+	}
+}
+
+func TestReplace_FoldedNames(t *testing.T) {
+	repo := seededMemoryRepository()
+	for i := range repo.rows {
+		repo.rows[i].NameKey = strings.ToLower(repo.rows[i].SegmentationName)
+	}
+	svc := NewSegmentationService(repo, WithValidationRules(ValidationRules{
+		Mode:  ValidationLenient,
+		Names: NamePolicy{FoldCase: true},
+	}))
+
+	// a new spelling of a stored name updates it instead of adding a row
+	req := ReplaceRequest{Segmentations: map[string][]SegmentationInput{
+		"drugs":       {{Name: "Aspirina", Data: json.RawMessage(`{"dose": "500mg", "unit": "mg"}`)}},
+		"specialties": {{Name: "  CARDIOLOGIA ", Data: json.RawMessage(`{"years": 5}`)}},
+	}}
+	result, err := svc.Replace(context.Background(), 10, req)
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if result.Inserted != 0 || result.Updated != 1 || result.Deleted != 1 || result.Unchanged != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	rows, _ := repo.FindByUserID(context.Background(), 10)
+	if len(rows) != 2 || rows[1].SegmentationName != "CARDIOLOGIA" {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+
+	req.Segmentations["drugs"] = append(req.Segmentations["drugs"], SegmentationInput{Name: "aspirina"})
+	if _, err := svc.Replace(context.Background(), 10, req); !errors.Is(err, ErrInvalidSegmentation) {
+		t.Errorf("names equal under the policy should be duplicates, got %v", err)
+	}
+}
+
+func TestReplace_RoundTripsUnregisteredTypes(t *testing.T) {
+	ctx := context.Background()
+	repo := seededMemoryRepository()
+	repo.rows = append(repo.rows,
+		models.Segmentation{ID: 4, UserID: 10, SegmentationType: "exam", SegmentationName: "Hemograma", Data: datatypes.JSON(`{}`)},
+		models.Segmentation{ID: 5, UserID: 10, SegmentationType: "status", SegmentationName: "Ativo", Data: datatypes.JSON(`{}`)},
+	)
+	repo.nextID = 5
+	svc := NewSegmentationService(repo)
+
+	// PUT back exactly what GET returned
+	before, err := svc.GetByUserID(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetByUserID() error = %v", err)
+	}
+	req := ReplaceRequest{Segmentations: map[string][]SegmentationInput{}}
+	for group, items := range before.Segmentations {
+		for _, item := range items {
+			req.Segmentations[group] = append(req.Segmentations[group], SegmentationInput{Name: item.Name, Data: item.Data})
+		}
+	}
+	result, err := svc.Replace(ctx, 10, req)
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if result.Inserted != 0 || result.Updated != 0 || result.Deleted != 0 || result.Unchanged != 5 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	after, err := svc.GetByUserID(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetByUserID() error = %v", err)
+	}
+	for group := range before.Segmentations {
+		if len(after.Segmentations[group]) != len(before.Segmentations[group]) {
+			t.Errorf("group %q: got %+v, want %+v", group, after.Segmentations[group], before.Segmentations[group])
+		}
+	}
+	if len(after.Segmentations) != len(before.Segmentations) {
+		t.Errorf("groups = %v, want %v", after.Segmentations, before.Segmentations)
+	}
+}
+
+func TestReplace_EmptyPayloadDeletesAll(t *testing.T) {
+	repo := seededMemoryRepository()
+	svc := NewSegmentationService(repo)
+
+	result, err := svc.Replace(context.Background(), 10, ReplaceRequest{})
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if result.Deleted != 3 {
+		t.Errorf("expected 3 deletions, got %d", result.Deleted)
+	}
+}
+
+func TestReplace_RollsBackOnError(t *testing.T) {
+	repo := seededMemoryRepository()
+	repo.failOn = "Ibuprofeno"
+	svc := NewSegmentationService(repo)
+
+	req := ReplaceRequest{
+		Segmentations: map[string][]SegmentationInput{
+			"drugs": {
+				{Name: "Ibuprofeno", Data: json.RawMessage(`{}`)},
+			},
+		},
+	}
+
+	if _, err := svc.Replace(context.Background(), 10, req); err == nil {
+		t.Fatal("Replace() should fail when a write fails")
+	}
+	if len(repo.rows) != 3 {
+		t.Errorf("rows should be untouched after rollback, got %d", len(repo.rows))
+	}
+}
+
+func TestReplace_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint64
+		req    ReplaceRequest
+	}{
+		{
+			name:   "zero user id",
+			userID: 0,
+			req:    ReplaceRequest{},
+		},
+		{
+			name:   "empty name",
+			userID: 1,
+			req: ReplaceRequest{Segmentations: map[string][]SegmentationInput{
+				"drugs": {{Name: "  "}},
+			}},
+		},
+		{
+			name:   "duplicate name",
+			userID: 1,
+			req: ReplaceRequest{Segmentations: map[string][]SegmentationInput{
+				"drugs": {{Name: "Aspirina"}, {Name: "Aspirina "}},
+			}},
+		},
+		{
+			name:   "invalid data",
+			userID: 1,
+			req: ReplaceRequest{Segmentations: map[string][]SegmentationInput{
+				"drugs": {{Name: "Aspirina", Data: json.RawMessage(`{bad`)}},
+			}},
+		},
+		{
+			name:   "empty type",
+			userID: 1,
+			req: ReplaceRequest{Segmentations: map[string][]SegmentationInput{
+				"": {{Name: "Aspirina"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seededMemoryRepository()
+			svc := NewSegmentationService(repo)
+
+			_, err := svc.Replace(context.Background(), tt.userID, tt.req)
+			if !errors.Is(err, ErrInvalidSegmentation) {
+				t.Fatalf("expected ErrInvalidSegmentation, got %v", err)
+			}
+			if repo.txStarted != 0 {
+				t.Error("validation failures should not open a transaction")
+			}
+		})
+	}
+}
+
+func TestDenormalizeType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "specialties", expected: "specialty"},
+		{input: "drugs", expected: "drug"},
+		{input: "patients", expected: "patient"},
+		{input: "customs", expected: "customs"},
+		{input: "status", expected: "status"},
+		{input: "drug", expected: "drug"},
+	}
+
+	for _, tt := range tests {
+		t.Run("denormalize_"+tt.input, func(t *testing.T) {
+			if got := denormalizeType(tt.input); got != tt.expected {
+				t.Errorf("denormalizeType(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGroupType(t *testing.T) {
+	for _, segType := range []string{"specialty", "drug", "patient", "exam", "status"} {
+		if got := groupType(normalizeType(segType)); got != segType {
+			t.Errorf("groupType(normalizeType(%q)) = %q", segType, got)
+		}
+	}
+}
+
+func TestResolveType_KeepsTypesEndingInS(t *testing.T) {
+	ctx := context.Background()
+	repo := seededMemoryRepository()
+	svc := NewSegmentationService(repo)
+
+	if _, _, err := svc.Upsert(ctx, 10, UpsertRequest{SegmentationType: "status", SegmentationName: "Ativo"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	items, err := svc.GetByType(ctx, 10, "status")
+	if err != nil || len(items) != 1 || items[0].Name != "Ativo" {
+		t.Fatalf("GetByType(status) = %+v, %v", items, err)
+	}
+	rows, _ := repo.FindByUserID(ctx, 10)
+	for _, r := range rows {
+		if r.SegmentationName == "Ativo" && r.SegmentationType != "status" {
+			t.Errorf("stored type = %q, want status", r.SegmentationType)
+		}
+	}
+	if err := svc.Delete(ctx, 10, "status", "Ativo"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// the group key of a registered type still resolves to it
+	reg := NewTypeRegistry(newMemoryTypeRepository(
+		models.SegmentationType{Name: "exam", Active: true},
+		models.SegmentationType{Name: "status", Active: true},
+	), 0)
+	if err := reg.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	svc = NewSegmentationService(repo, WithTypeRegistry(reg))
+	for input, want := range map[string]string{"exams": "exam", "exam": "exam", "status": "status", "drugs": "drug", "bus": "bus"} {
+		if got, _ := svc.resolveType(input, nil); got != want {
+			t.Errorf("resolveType(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestResolveType_WritesAndDeletesStoredTypes(t *testing.T) {
+	ctx := context.Background()
+	repo := seededMemoryRepository()
+	repo.rows = append(repo.rows,
+		models.Segmentation{ID: 4, UserID: 10, SegmentationType: "exam", SegmentationName: "Hemograma", Data: datatypes.JSON(`{}`)},
+		models.Segmentation{ID: 5, UserID: 10, SegmentationType: "status", SegmentationName: "Ativo", Data: datatypes.JSON(`{}`)},
+	)
+	repo.nextID = 5
+	svc := NewSegmentationService(repo)
+
+	// group keys of unregistered stored types write to those types
+	writes := []UpsertRequest{
+		{SegmentationType: "exams", SegmentationName: "Glicemia"},
+		{SegmentationType: "statuss", SegmentationName: "Inativo"},
+		{SegmentationType: "status", SegmentationName: "Pendente"},
+	}
+	for _, req := range writes {
+		if _, _, err := svc.Upsert(ctx, 10, req); err != nil {
+			t.Fatalf("Upsert(%q) error = %v", req.SegmentationType, err)
+		}
+	}
+	rows, _ := repo.FindByUserID(ctx, 10)
+	for _, r := range rows {
+		if r.SegmentationType != "drug" && r.SegmentationType != "specialty" && r.SegmentationType != "exam" && r.SegmentationType != "status" {
+			t.Errorf("%s stored as %q", r.SegmentationName, r.SegmentationType)
+		}
+	}
+
+	// and delete their rows
+	for _, del := range [][2]string{{"exams", "Hemograma"}, {"exams", "Glicemia"}, {"statuss", "Ativo"}, {"status", "Inativo"}, {"status", "Pendente"}} {
+		if err := svc.Delete(ctx, 10, del[0], del[1]); err != nil {
+			t.Errorf("Delete(%q, %q) error = %v", del[0], del[1], err)
+		}
+	}
+	if rows, _ := repo.FindByUserID(ctx, 10); len(rows) != 3 {
+		t.Errorf("rows left = %+v, want the 3 seeded ones", rows)
+	}
+}
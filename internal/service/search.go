@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/repository"
+)
+
+const (
+	// MaxSearchWindow caps offset+limit: deeper pages cost the index more
+	// than they are worth, so clients narrow the query instead
+	MaxSearchWindow = 10000
+	// maxSearchText caps the bytes of the text searched for
+	maxSearchText = 256
+)
+
+// ErrInvalidSearch is returned for a search text or page the index does
+// not accept
+var ErrInvalidSearch = apperrors.New("invalid search", apperrors.ErrValidation)
+
+// SearchHit is one segmentation found by a search; Score is its relevance
+// to the text searched for
+type SearchHit struct {
+	UserID    uint64          `json:"user_id"`
+	Type      string          `json:"segmentation_type"`
+	Name      string          `json:"segmentation_name"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+	UpdatedAt Timestamp       `json:"updated_at" swaggertype:"string" format:"date-time"`
+	Score     float64         `json:"score"`
+}
+
+// SearchPage is a page of search results; Total counts every segmentation
+// matching the search
+type SearchPage struct {
+	Total int64       `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// Searcher searches the segmentations mirrored into a search index
+type Searcher struct {
+	repo repository.SearchRepository
+}
+
+// NewSearcher creates a searcher reading from repo
+func NewSearcher(repo repository.SearchRepository) *Searcher {
+	return &Searcher{repo: repo}
+}
+
+// Search returns the page of limit hits after offset of the segmentations
+// matching filter whose name or data text resemble text, best first; an
+// empty text returns them all, most recently written first. The index
+// lags the database by the outbox relay.
+func (s *Searcher) Search(
+	ctx context.Context,
+	text string,
+	filter repository.Filter,
+	limit, offset int,
+) (*SearchPage, error) {
+
+	switch {
+	case !utf8.ValidString(text) || len(text) > maxSearchText:
+		return nil, fmt.Errorf("%w: q must be valid UTF-8 of at most %d bytes", ErrInvalidSearch, maxSearchText)
+	case limit < 1 || offset < 0 || offset+limit > MaxSearchWindow:
+		return nil, fmt.Errorf("%w: offset + limit must not exceed %d", ErrInvalidSearch, MaxSearchWindow)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	res, err := s.repo.Search(ctx, repository.SearchQuery{Text: text, Filter: filter, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SearchPage{Total: res.Total, Hits: make([]SearchHit, 0, len(res.Hits))}
+	for _, h := range res.Hits {
+		data := json.RawMessage(h.Segmentation.Data)
+		if len(data) == 0 {
+			data = json.RawMessage("null")
+		}
+		page.Hits = append(page.Hits, SearchHit{
+			UserID:    h.Segmentation.UserID,
+			Type:      h.Segmentation.SegmentationType,
+			Name:      h.Segmentation.SegmentationName,
+			Data:      data,
+			UpdatedAt: Timestamp(h.Segmentation.UpdatedAt),
+			Score:     h.Score,
+		})
+	}
+	return page, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// fakeSearch answers every search with its hits and keeps the last query
+type fakeSearch struct {
+	hits []repository.SearchHit
+	last repository.SearchQuery
+}
+
+func (f *fakeSearch) Search(ctx context.Context, q repository.SearchQuery) (*repository.SearchResult, error) {
+	f.last = q
+	return &repository.SearchResult{Total: int64(len(f.hits)), Hits: f.hits}, nil
+}
+
+func TestSearcher_Search(t *testing.T) {
+	repo := &fakeSearch{hits: []repository.SearchHit{
+		{Segmentation: models.Segmentation{UserID: 7, SegmentationType: "drug", SegmentationName: "Aspirina", UpdatedAt: 100}, Score: 2.5},
+	}}
+	filter := repository.Filter{{Field: "segmentation_type", Op: repository.FilterEq, Values: []string{"drug"}}}
+
+	page, err := NewSearcher(repo).Search(context.Background(), "aspirna", filter, 20, 40)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if page.Total != 1 || len(page.Hits) != 1 || page.Hits[0].Name != "Aspirina" || page.Hits[0].Score != 2.5 ||
+		string(page.Hits[0].Data) != "null" {
+		t.Errorf("unexpected page %+v", page)
+	}
+	if q := repo.last; q.Text != "aspirna" || q.Limit != 20 || q.Offset != 40 || len(q.Filter) != 1 {
+		t.Errorf("unexpected query %+v", q)
+	}
+}
+
+func TestSearcher_SearchInvalid(t *testing.T) {
+	s := NewSearcher(&fakeSearch{})
+	ctx := context.Background()
+
+	if _, err := s.Search(ctx, "", nil, 100, MaxSearchWindow-99); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("a page past the window should fail, got %v", err)
+	}
+	if _, err := s.Search(ctx, strings.Repeat("a", maxSearchText+1), nil, 10, 0); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("an oversized text should fail, got %v", err)
+	}
+	filter := repository.Filter{{Field: "password", Op: repository.FilterEq, Values: []string{"x"}}}
+	if _, err := s.Search(ctx, "", filter, 10, 0); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("an unknown field should fail, got %v", err)
+	}
+}
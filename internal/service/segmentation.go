@@ -3,27 +3,94 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"segmentation-api/internal/apperrors"
 	"segmentation-api/internal/models"
 	"segmentation-api/internal/repository"
 	"strings"
+
+	"go.uber.org/zap"
 )
 
+// ErrUserNotFound is returned by GetByUserID for a user unknown to the
+// user directory (WithUserDirectory)
+var ErrUserNotFound = apperrors.New("user not found", apperrors.ErrNotFound)
+
 type SegmentationService struct {
-	repo repository.SegmentationRepository
+	repo     repository.SegmentationRepository
+	rules    ValidationRules
+	types    *TypeRegistry
+	cache    *UserCache
+	users    repository.UserDirectory
+	notifier Notifier
+	logger   *zap.Logger
 }
 
-func NewSegmentationService(r repository.SegmentationRepository) *SegmentationService {
-	return &SegmentationService{repo: r}
+// Option customizes a SegmentationService
+type Option func(*SegmentationService)
+
+// WithValidationRules sets the rules applied by Validate on every write
+func WithValidationRules(rules ValidationRules) Option {
+	return func(s *SegmentationService) {
+		s.rules = rules
+	}
+}
+
+// WithTypeRegistry validates writes against the segmentation type registry
+func WithTypeRegistry(types *TypeRegistry) Option {
+	return func(s *SegmentationService) {
+		s.types = types
+	}
+}
+
+// WithCache serves GetByUserID from cache, invalidated by the writes of
+// the service
+func WithCache(cache *UserCache) Option {
+	return func(s *SegmentationService) {
+		s.cache = cache
+	}
+}
+
+// WithUserDirectory makes GetByUserID return ErrUserNotFound for a user
+// without segmentations that the directory does not know; without it such
+// a user gets an empty response
+func WithUserDirectory(users repository.UserDirectory) Option {
+	return func(s *SegmentationService) {
+		s.users = users
+	}
+}
+
+// WithLogger sets the logger used by the service; the default discards
+// everything
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *SegmentationService) {
+		s.logger = logger
+	}
+}
+
+func NewSegmentationService(r repository.SegmentationRepository, opts ...Option) *SegmentationService {
+	s := &SegmentationService{repo: r, rules: DefaultValidationRules(), logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 type SegmentationItem struct {
-	Name string                 `json:"name"`
-	Data map[string]interface{} `json:"data"`
+	Name string `json:"name"`
+	// Data is the stored JSON, passed through without decoding so the
+	// response keeps its values and key order
+	Data json.RawMessage `json:"data" swaggertype:"object"`
+	// CreatedAt and UpdatedAt are only encoded when the metadata is
+	// requested (Fields.IncludeMetadata)
+	CreatedAt Timestamp `json:"-"`
+	UpdatedAt Timestamp `json:"-"`
 }
 
 type SegmentationResponse struct {
 	UserID        uint64                        `json:"user_id"`
 	Segmentations map[string][]SegmentationItem `json:"segmentations"`
+	// Labels holds localized group labels, set only when a locale was negotiated
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 func (s *SegmentationService) GetByUserID(
@@ -31,10 +98,41 @@ func (s *SegmentationService) GetByUserID(
 	userID uint64,
 ) (*SegmentationResponse, error) {
 
+	var gen uint64
+	if s.cache != nil {
+		cached, g, hit := s.cache.get(userID)
+		if hit {
+			return cached, nil
+		}
+		gen = g
+	}
+
 	records, err := s.repo.FindByUserID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	return s.response(ctx, userID, records, gen)
+}
+
+// response groups the records of a user into the response of GetByUserID
+// and caches it under the cache generation gen
+func (s *SegmentationService) response(
+	ctx context.Context,
+	userID uint64,
+	records []models.Segmentation,
+	gen uint64,
+) (*SegmentationResponse, error) {
+
+	// a user with segmentations is known; only an empty result is looked up
+	if len(records) == 0 && s.users != nil {
+		exists, err := s.users.Exists(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrUserNotFound
+		}
+	}
 
 	result := &SegmentationResponse{
 		UserID:        userID,
@@ -42,23 +140,69 @@ func (s *SegmentationService) GetByUserID(
 	}
 
 	for _, r := range records {
-		var data map[string]interface{}
-		_ = json.Unmarshal(r.Data, &data)
-
 		key := normalizeType(r.SegmentationType)
-
-		result.Segmentations[key] = append(
-			result.Segmentations[key],
-			SegmentationItem{
-				Name: r.SegmentationName,
-				Data: data,
-			},
-		)
+		result.Segmentations[key] = append(result.Segmentations[key], segmentationItem(r))
 	}
 
+	if s.cache != nil {
+		s.cache.add(userID, result, gen)
+	}
 	return result, nil
 }
 
+// segmentationItem is a stored segmentation as it is answered
+func segmentationItem(r models.Segmentation) SegmentationItem {
+	// an empty blob would not encode as a RawMessage; nil encodes as null
+	var data json.RawMessage
+	if len(r.Data) > 0 {
+		data = json.RawMessage(r.Data)
+	}
+	return SegmentationItem{
+		Name:      r.SegmentationName,
+		Data:      data,
+		CreatedAt: Timestamp(r.CreatedAt),
+		UpdatedAt: Timestamp(r.UpdatedAt),
+	}
+}
+
+// invalidate drops the cached response of a user after a write
+func (s *SegmentationService) invalidate(userID uint64) {
+	if s.cache != nil {
+		s.cache.Invalidate(userID)
+	}
+}
+
+// FlushCache drops every cached response, so the next reads come from the
+// database; it returns how many users were cached (0 without a cache)
+func (s *SegmentationService) FlushCache() int {
+	if s.cache == nil {
+		return 0
+	}
+	return s.cache.Flush()
+}
+
+// Transaction runs fn with a service whose writes go to a single database
+// transaction, committed when fn returns nil and rolled back otherwise.
+// The cache is flushed afterwards, since the transaction may have written
+// any user, and the notifier is told about the writes once they commit.
+func (s *SegmentationService) Transaction(ctx context.Context, fn func(tx *SegmentationService) error) error {
+	var pending *changeBuffer
+	err := s.repo.Transaction(ctx, func(repo repository.SegmentationRepository) error {
+		// a fresh buffer per attempt, in case the transaction is retried
+		pending = &changeBuffer{}
+		tx := *s
+		tx.repo = repo
+		tx.cache = nil
+		tx.notifier = pending
+		return fn(&tx)
+	})
+	s.FlushCache()
+	if err == nil {
+		s.notify(pending.changes...)
+	}
+	return err
+}
+
 func normalizeType(t string) string {
 	switch strings.ToLower(t) {
 	case "specialty":
@@ -76,5 +220,34 @@ func (s *SegmentationService) Create(
 	ctx context.Context,
 	seg *models.Segmentation,
 ) (repository.UpsertResult, error) {
-	return s.repo.Upsert(ctx, seg)
+	defer s.invalidate(seg.UserID)
+	result, err := s.repo.Upsert(ctx, seg)
+	if err == nil && result != repository.UpsertNoOp {
+		s.notify(upserted(seg)...)
+	}
+	return result, err
+}
+
+// CreateBatch writes already validated segmentations in a single statement.
+// An error applies to the whole batch; callers that need to know which
+// rows failed write them again one by one with Create.
+func (s *SegmentationService) CreateBatch(
+	ctx context.Context,
+	segs []models.Segmentation,
+) (repository.BulkUpsertResult, error) {
+	defer func() {
+		for _, seg := range segs {
+			s.invalidate(seg.UserID)
+		}
+	}()
+	result, err := s.repo.BulkUpsert(ctx, segs)
+	if err == nil {
+		// a bulk write cannot tell which rows changed
+		written := make([]*models.Segmentation, len(segs))
+		for i := range segs {
+			written[i] = &segs[i]
+		}
+		s.notify(upserted(written...)...)
+	}
+	return result, err
 }
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"segmentation-api/internal/models"
@@ -22,6 +24,10 @@ func (m *MockRepository) FindByUserID(ctx context.Context, userID uint64) ([]mod
 	return nil, nil
 }
 
+func (m *MockRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
 func (m *MockRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
 	if m.upsertFunc != nil {
 		return m.upsertFunc(ctx, s)
@@ -29,6 +35,18 @@ func (m *MockRepository) Upsert(ctx context.Context, s *models.Segmentation) (re
 	return repository.UpsertNoOp, nil
 }
 
+func (m *MockRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	return int64(len(ids)), nil
+}
+
+func (m *MockRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *MockRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
 func TestNormalizeType(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -101,6 +119,38 @@ func TestSegmentationServiceGetByUserID(t *testing.T) {
 	}
 }
 
+// userSet is a UserDirectory of fixed user IDs
+type userSet map[uint64]bool
+
+func (u userSet) Exists(ctx context.Context, userID uint64) (bool, error) {
+	return u[userID], nil
+}
+
+func TestSegmentationServiceGetByUserID_UserDirectory(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			if userID == 100 {
+				return []models.Segmentation{{UserID: 100, SegmentationType: "drug", SegmentationName: "Aspirina"}}, nil
+			}
+			return nil, nil
+		},
+	}
+	// user 100 has segmentations but is missing from the directory: the
+	// rows are enough to know it
+	svc := NewSegmentationService(mockRepo, WithUserDirectory(userSet{200: true}))
+
+	if result, err := svc.GetByUserID(ctx, 100); err != nil || len(result.Segmentations["drugs"]) != 1 {
+		t.Errorf("user with segmentations: %+v, %v", result, err)
+	}
+	if result, err := svc.GetByUserID(ctx, 200); err != nil || len(result.Segmentations) != 0 {
+		t.Errorf("known user without segmentations: %+v, %v", result, err)
+	}
+	if _, err := svc.GetByUserID(ctx, 300); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: error = %v, want ErrUserNotFound", err)
+	}
+}
+
 func TestSegmentationServiceGetByUserIDGrouping(t *testing.T) {
 	ctx := context.Background()
 
@@ -162,6 +212,32 @@ func TestSegmentationServiceGetByUserIDGrouping(t *testing.T) {
 	}
 }
 
+func TestSegmentationServiceGetByUserIDRawData(t *testing.T) {
+	mockRepo := &MockRepository{
+		findByUserIDFunc: func(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+			return []models.Segmentation{
+				{UserID: userID, SegmentationType: "drug", SegmentationName: "Antibióticos", Data: datatypes.JSON(`{"z": 1, "a": [true]}`)},
+				{UserID: userID, SegmentationType: "drug", SegmentationName: "Analgésicos"},
+			}, nil
+		},
+	}
+
+	result, err := NewSegmentationService(mockRepo).GetByUserID(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("GetByUserID() error = %v", err)
+	}
+
+	body, err := json.Marshal(result.Segmentations["drugs"])
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	// encoding/json compacts the raw data but keeps its key order
+	want := `[{"name":"Antibióticos","data":{"z":1,"a":[true]}},{"name":"Analgésicos","data":null}]`
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
 func TestSegmentationServiceCreate(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"segmentation-api/internal/models"
+)
+
+// Len is the number of segmentations in the response
+func (r *SegmentationResponse) Len() int {
+	n := 0
+	for _, items := range r.Segmentations {
+		n += len(items)
+	}
+	return n
+}
+
+// WriteJSON writes r to w as the same document json.Marshal produces, but
+// encodes one segmentation at a time so a user with tens of thousands of
+// rows is never fully buffered in memory
+func (r *SegmentationResponse) WriteJSON(w io.Writer) error {
+	return r.WriteJSONFields(w, nil)
+}
+
+// WriteJSONFields is WriteJSON with each item trimmed to fields; members
+// left out are not encoded at all
+func (r *SegmentationResponse) WriteJSONFields(w io.Writer, fields *Fields) error {
+	head := `{"user_id":` + strconv.FormatUint(r.UserID, 10) + `,"segmentations":`
+	if _, err := io.WriteString(w, head); err != nil {
+		return err
+	}
+
+	if r.Segmentations == nil {
+		if _, err := io.WriteString(w, "null"); err != nil {
+			return err
+		}
+	} else {
+		if err := writeGroups(w, r.Segmentations, fields); err != nil {
+			return err
+		}
+	}
+
+	// labels are few, encoded at once like json.Marshal does (omitempty)
+	if len(r.Labels) > 0 {
+		b, err := json.Marshal(r.Labels)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `,"labels":`); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+// writeGroups writes the groups in key order, as json.Marshal sorts map keys
+func writeGroups(w io.Writer, groups map[string][]SegmentationItem, fields *Fields) error {
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	for i, k := range keys {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			key = append([]byte{','}, key...)
+		}
+		if _, err := w.Write(append(key, ':')); err != nil {
+			return err
+		}
+
+		if err := writeItems(w, groups[k], fields); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+// writeItems writes the items of a group as an array, null when nil
+func writeItems(w io.Writer, items []SegmentationItem, fields *Fields) error {
+	if items == nil {
+		_, err := io.WriteString(w, "null")
+		return err
+	}
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for j, item := range items {
+		if j > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		b, err := fields.encodeItem(item)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// ResponseStream is where StreamByUserID writes a response too large to
+// build in memory
+type ResponseStream struct {
+	// Open is called once, right before the first byte, and returns the
+	// writer of the response
+	Open func() io.Writer
+	// Fields trims each item, as in WriteJSONFields
+	Fields *Fields
+	// Labels, when set, returns the labels of the groups written
+	Labels func(groups []string) map[string]string
+}
+
+// StreamByUserID reads the segmentations of a user like GetByUserID, but
+// one row at a time. A cached response, or one of at most threshold
+// segmentations, is returned like GetByUserID returns it. A user with
+// more is written to stream as it is read, as the JSON of WriteJSONFields
+// with its groups in the order of their types, and is not cached; nil is
+// returned then, with the error that cut the stream short, if any.
+func (s *SegmentationService) StreamByUserID(
+	ctx context.Context,
+	userID uint64,
+	threshold int,
+	stream ResponseStream,
+) (*SegmentationResponse, error) {
+
+	var gen uint64
+	if s.cache != nil {
+		cached, g, hit := s.cache.get(userID)
+		if hit {
+			return cached, nil
+		}
+		gen = g
+	}
+
+	var records []models.Segmentation
+	var w *responseWriter
+	err := s.repo.EachByUserID(ctx, userID, func(r models.Segmentation) error {
+		if w != nil {
+			return w.add(r)
+		}
+		if len(records) < threshold {
+			records = append(records, r)
+			return nil
+		}
+		w = &responseWriter{w: stream.Open(), fields: stream.Fields, userID: userID}
+		for _, buffered := range records {
+			if err := w.add(buffered); err != nil {
+				return err
+			}
+		}
+		records = nil
+		return w.add(r)
+	})
+	if w != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, w.close(stream.Labels)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.response(ctx, userID, records, gen)
+}
+
+// responseWriter writes a response a segmentation at a time, the rows of
+// a group being consecutive
+type responseWriter struct {
+	w      io.Writer
+	fields *Fields
+	userID uint64
+	groups []string
+}
+
+func (rw *responseWriter) add(r models.Segmentation) error {
+	group := normalizeType(r.SegmentationType)
+	var sep string
+	switch {
+	case len(rw.groups) == 0:
+		sep = `{"user_id":` + strconv.FormatUint(rw.userID, 10) + `,"segmentations":{`
+	case rw.groups[len(rw.groups)-1] != group:
+		sep = "],"
+	default:
+		sep = ","
+	}
+	if sep != "," {
+		key, err := json.Marshal(group)
+		if err != nil {
+			return err
+		}
+		sep += string(key) + ":["
+		rw.groups = append(rw.groups, group)
+	}
+	if _, err := io.WriteString(rw.w, sep); err != nil {
+		return err
+	}
+
+	b, err := rw.fields.encodeItem(segmentationItem(r))
+	if err != nil {
+		return err
+	}
+	_, err = rw.w.Write(b)
+	return err
+}
+
+// close ends the document, with the labels of the groups written
+func (rw *responseWriter) close(labels func(groups []string) map[string]string) error {
+	end := "]}"
+	if labels != nil {
+		if l := labels(rw.groups); len(l) > 0 {
+			b, err := json.Marshal(l)
+			if err != nil {
+				return err
+			}
+			end += `,"labels":` + string(b)
+		}
+	}
+	_, err := io.WriteString(rw.w, end+"}")
+	return err
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSegmentationResponse_WriteJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		resp SegmentationResponse
+	}{
+		{name: "nil groups", resp: SegmentationResponse{UserID: 1}},
+		{name: "empty groups", resp: SegmentationResponse{UserID: 2, Segmentations: map[string][]SegmentationItem{}}},
+		{
+			name: "groups and labels",
+			resp: SegmentationResponse{
+				UserID: 3,
+				Segmentations: map[string][]SegmentationItem{
+					"specialties": {{Name: "Cardiologia", Data: json.RawMessage(`{"years":15}`)}},
+					"drugs": {
+						{Name: "Antibióticos <b>", Data: json.RawMessage(`{"z":1,"a":[true]}`)},
+						{Name: "Analgésicos"},
+					},
+					"patients": nil,
+				},
+				Labels: map[string]string{"drugs": "Medicamentos", "specialties": "Especialidades"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := json.Marshal(&tt.resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := tt.resp.WriteJSON(&buf); err != nil {
+				t.Fatalf("WriteJSON() error = %v", err)
+			}
+			if buf.String() != string(want) {
+				t.Errorf("WriteJSON() = %s\nwant %s", buf.String(), want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{ after int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.after == 0 {
+		return 0, errors.New("connection reset")
+	}
+	w.after--
+	return len(p), nil
+}
+
+func TestSegmentationResponse_WriteJSONError(t *testing.T) {
+	resp := SegmentationResponse{
+		UserID:        1,
+		Segmentations: map[string][]SegmentationItem{"drugs": {{Name: "a"}, {Name: "b"}}},
+	}
+	if resp.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", resp.Len())
+	}
+	for after := 0; after < 6; after++ {
+		if err := resp.WriteJSON(&failingWriter{after: after}); err == nil {
+			t.Errorf("WriteJSON() should fail when write %d fails", after+1)
+		}
+	}
+}
+
+func BenchmarkSegmentationResponse_WriteJSON(b *testing.B) {
+	resp := SegmentationResponse{UserID: 1, Segmentations: map[string][]SegmentationItem{}}
+	for _, group := range []string{"drugs", "specialties", "patients"} {
+		for i := 0; i < 2000; i++ {
+			resp.Segmentations[group] = append(resp.Segmentations[group], SegmentationItem{
+				Name: "Segment",
+				Data: json.RawMessage(`{"count":42,"tags":["a","b"]}`),
+			})
+		}
+	}
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := json.Marshal(&resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf bytes.Buffer
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := resp.WriteJSON(&buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestStreamByUserID(t *testing.T) {
+	ctx := context.Background()
+	cache := NewUserCache(10, time.Minute)
+	svc := NewSegmentationService(seededMemoryRepository(), WithCache(cache))
+	want, err := NewSegmentationService(seededMemoryRepository()).GetByUserID(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, _ := json.Marshal(want)
+
+	// above the threshold: written as read, not cached
+	var buf bytes.Buffer
+	opened := 0
+	stream := ResponseStream{Open: func() io.Writer { opened++; return &buf }}
+	resp, err := svc.StreamByUserID(ctx, 10, 2, stream)
+	if err != nil || resp != nil || opened != 1 {
+		t.Fatalf("StreamByUserID() = %+v, %v after %d opens", resp, err, opened)
+	}
+	if buf.String() != string(wantJSON) {
+		t.Errorf("streamed %s\nwant %s", buf.String(), wantJSON)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("a streamed user should not be cached, cache has %d", cache.Len())
+	}
+
+	// labels of the groups written close the document
+	buf.Reset()
+	stream.Labels = func(groups []string) map[string]string {
+		return map[string]string{groups[0]: "first"}
+	}
+	if _, err := svc.StreamByUserID(ctx, 10, 2, stream); err != nil {
+		t.Fatal(err)
+	}
+	var doc SegmentationResponse
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil || doc.Labels["drugs"] != "first" {
+		t.Errorf("streamed labels = %v, %v", doc.Labels, err)
+	}
+
+	// within it: built and cached like GetByUserID
+	resp, err = svc.StreamByUserID(ctx, 10, 3, stream)
+	if err != nil || resp == nil || resp.Len() != 3 || opened != 2 {
+		t.Fatalf("StreamByUserID() = %+v, %v after %d opens", resp, err, opened)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("cache has %d users, want 1", cache.Len())
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// The Table methods lay the read responses out as CSV rows, one per
+// segmentation, with the same values as their JSON: data is its JSON
+// document and timestamps are RFC 3339, empty when unset.
+
+// Table is TableFields with every field
+func (r *SegmentationResponse) Table() ([]string, [][]string, error) {
+	return r.TableFields(nil)
+}
+
+// TableFields has a row per segmentation, groups in key order, with the
+// user, the group and the members of fields
+func (r *SegmentationResponse) TableFields(fields *Fields) ([]string, [][]string, error) {
+	f := tableFields(fields)
+	header := append([]string{"user_id", "group"}, f.columns()...)
+
+	groups := make([]string, 0, len(r.Segmentations))
+	for group := range r.Segmentations {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	uid := strconv.FormatUint(r.UserID, 10)
+	rows := make([][]string, 0, r.Len())
+	for _, group := range groups {
+		for _, item := range r.Segmentations[group] {
+			row, err := f.row(item)
+			if err != nil {
+				return nil, nil, err
+			}
+			rows = append(rows, append([]string{uid, group}, row...))
+		}
+	}
+	return header, rows, nil
+}
+
+// Table is TableFields with every field
+func (items SegmentationItems) Table() ([]string, [][]string, error) {
+	return items.TableFields(nil)
+}
+
+// TableFields has a row per item with the members of fields
+func (items SegmentationItems) TableFields(fields *Fields) ([]string, [][]string, error) {
+	f := tableFields(fields)
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		row, err := f.row(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	return f.columns(), rows, nil
+}
+
+// tableFields is fields, or name and data when nil
+func tableFields(fields *Fields) *Fields {
+	if fields == nil {
+		return &Fields{name: true, data: true}
+	}
+	return fields
+}
+
+// columns are the table columns of the members of f
+func (f *Fields) columns() []string {
+	var header []string
+	if f.name {
+		header = append(header, "name")
+	}
+	if f.data || len(f.dataKeys) > 0 {
+		header = append(header, "data")
+	}
+	if f.metadata {
+		header = append(header, "created_at", "updated_at")
+	}
+	return header
+}
+
+// row is the table row of item, in the order of columns
+func (f *Fields) row(item SegmentationItem) ([]string, error) {
+	var row []string
+	if f.name {
+		row = append(row, item.Name)
+	}
+	if f.data || len(f.dataKeys) > 0 {
+		var buf bytes.Buffer
+		if err := f.writeData(&buf, item.Data); err != nil {
+			return nil, err
+		}
+		row = append(row, buf.String())
+	}
+	if f.metadata {
+		row = append(row, item.CreatedAt.String(), item.UpdatedAt.String())
+	}
+	return row, nil
+}
+
+// Table has a row per change; the cursor of the next page is not part of
+// it
+func (p *ChangesPage) Table() ([]string, [][]string, error) {
+	header := []string{"user_id", "segmentation_type", "segmentation_name", "data", "created_at", "updated_at"}
+	rows := make([][]string, 0, len(p.Changes))
+	for _, item := range p.Changes {
+		rows = append(rows, []string{
+			strconv.FormatUint(item.UserID, 10),
+			item.Type,
+			item.Name,
+			tableData(item.Data),
+			item.CreatedAt.String(),
+			item.UpdatedAt.String(),
+		})
+	}
+	return header, rows, nil
+}
+
+// Table has a row per hit, best first; the total is not part of it
+func (p *SearchPage) Table() ([]string, [][]string, error) {
+	header := []string{"user_id", "segmentation_type", "segmentation_name", "data", "updated_at", "score"}
+	rows := make([][]string, 0, len(p.Hits))
+	for _, hit := range p.Hits {
+		rows = append(rows, []string{
+			strconv.FormatUint(hit.UserID, 10),
+			hit.Type,
+			hit.Name,
+			tableData(hit.Data),
+			hit.UpdatedAt.String(),
+			strconv.FormatFloat(hit.Score, 'g', -1, 64),
+		})
+	}
+	return header, rows, nil
+}
+
+// tableData is data as its compact JSON, null when empty
+func tableData(data []byte) string {
+	if len(data) == 0 {
+		return "null"
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestChangesPage_Table(t *testing.T) {
+	page := &ChangesPage{Changes: []ChangeItem{
+		{UserID: 7, Type: "drug", Name: "Dipirona", Data: json.RawMessage(`{ "dose": 1 }`), CreatedAt: 1767225600, UpdatedAt: 1767229200},
+		{UserID: 8, Type: "specialty", Name: "Cardiologia"},
+	}, NextCursor: "c", HasMore: true}
+
+	header, rows, err := page.Table()
+	if err != nil {
+		t.Fatalf("Table() error = %v", err)
+	}
+	if want := []string{"user_id", "segmentation_type", "segmentation_name", "data", "created_at", "updated_at"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header = %v, want %v", header, want)
+	}
+	want := [][]string{
+		{"7", "drug", "Dipirona", `{"dose":1}`, "2026-01-01T00:00:00Z", "2026-01-01T01:00:00Z"},
+		{"8", "specialty", "Cardiologia", "null", "", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestSearchPage_Table(t *testing.T) {
+	page := &SearchPage{Total: 10, Hits: []SearchHit{
+		{UserID: 7, Type: "drug", Name: "Dipirona", Data: json.RawMessage(`{}`), UpdatedAt: 1767229200, Score: 2.5},
+	}}
+
+	header, rows, err := page.Table()
+	if err != nil {
+		t.Fatalf("Table() error = %v", err)
+	}
+	if want := []string{"user_id", "segmentation_type", "segmentation_name", "data", "updated_at", "score"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header = %v, want %v", header, want)
+	}
+	if want := [][]string{{"7", "drug", "Dipirona", "{}", "2026-01-01T01:00:00Z", "2.5"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestSegmentationResponse_TableFields(t *testing.T) {
+	resp := &SegmentationResponse{UserID: 7, Segmentations: map[string][]SegmentationItem{
+		"specialties": {{Name: "Cardiologia"}},
+		"drugs":       {{Name: "Dipirona", Data: json.RawMessage(`{"dose": 1, "quantity": "200"}`)}},
+	}}
+	fields, _ := ParseFields("data.quantity")
+
+	header, rows, err := resp.TableFields(fields)
+	if err != nil {
+		t.Fatalf("TableFields() error = %v", err)
+	}
+	if want := []string{"user_id", "group", "data"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header = %v, want %v", header, want)
+	}
+	want := [][]string{{"7", "drugs", `{"quantity":"200"}`}, {"7", "specialties", "null"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestSegmentationItems_TableFields(t *testing.T) {
+	items := SegmentationItems{
+		{Name: "Dipirona", Data: json.RawMessage(`{"dose": 1}`), CreatedAt: 1767225600, UpdatedAt: 1767229200},
+	}
+
+	header, rows, err := items.Table()
+	if err != nil {
+		t.Fatalf("Table() error = %v", err)
+	}
+	if want := []string{"name", "data"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header = %v, want %v", header, want)
+	}
+	if want := [][]string{{"Dipirona", `{"dose":1}`}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+
+	fields, _ := ParseFields("name")
+	header, rows, _ = items.TableFields(fields.IncludeMetadata())
+	if want := []string{"name", "created_at", "updated_at"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header = %v, want %v", header, want)
+	}
+	if want := [][]string{{"Dipirona", "2026-01-01T00:00:00Z", "2026-01-01T01:00:00Z"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
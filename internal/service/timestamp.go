@@ -0,0 +1,7 @@
+package service
+
+import "segmentation-api/internal/models"
+
+// Timestamp is the time of the API payloads, unix seconds stored and
+// RFC3339 in JSON; see models.Timestamp
+type Timestamp = models.Timestamp
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// SegmentationItems are the segmentations of a user of one type, the
+// response of the per-type read endpoints
+type SegmentationItems []SegmentationItem
+
+// GetByType returns the segmentations of a user of one type, given
+// singular ("drug") or as its group key ("drugs"), matched regardless of
+// case; the group key of a type the user has stored resolves to it even
+// when the type is not registered. With a populated type registry a type
+// it does not know is ErrTypeNotFound; a known type the user has none of
+// is an empty list. It reads through GetByUserID, so it shares its cache
+// and its ErrUserNotFound.
+func (s *SegmentationService) GetByType(ctx context.Context, userID uint64, segType string) (SegmentationItems, error) {
+	var result *SegmentationResponse
+	segType, err := s.resolveType(segType, func() ([]string, error) {
+		var err error
+		if result, err = s.GetByUserID(ctx, userID); err != nil {
+			return nil, err
+		}
+		types := make([]string, 0, len(result.Segmentations))
+		for group := range result.Segmentations {
+			types = append(types, groupType(group))
+		}
+		return types, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if segType == "" || len(segType) > maxTypeLength {
+		return nil, ErrTypeNotFound
+	}
+	if s.types != nil && !s.types.empty() {
+		if _, ok := s.types.lookup(segType); !ok {
+			return nil, ErrTypeNotFound
+		}
+	}
+
+	if result == nil {
+		if result, err = s.GetByUserID(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	group := normalizeType(segType)
+	items := SegmentationItems{}
+	for key, groupItems := range result.Segmentations {
+		if strings.EqualFold(key, group) {
+			items = append(items, groupItems...)
+		}
+	}
+	return items, nil
+}
+
+// WriteJSONFields writes the items as a JSON array, each trimmed to
+// fields; nil fields writes them whole
+func (items SegmentationItems) WriteJSONFields(w io.Writer, fields *Fields) error {
+	return writeItems(w, items, fields)
+}
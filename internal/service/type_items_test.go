@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func TestGetByType(t *testing.T) {
+	svc := NewSegmentationService(seededMemoryRepository())
+	ctx := context.Background()
+
+	for _, segType := range []string{"drugs", "drug", " Drugs "} {
+		items, err := svc.GetByType(ctx, 10, segType)
+		if err != nil || len(items) != 2 || items[0].Name != "Aspirina" {
+			t.Errorf("GetByType(%q) = %+v, %v", segType, items, err)
+		}
+	}
+	if items, err := svc.GetByType(ctx, 10, "patients"); err != nil || items == nil || len(items) != 0 {
+		t.Errorf("a type the user has none of should be an empty list, got %#v, %v", items, err)
+	}
+	if _, err := svc.GetByType(ctx, 10, " "); !errors.Is(err, ErrTypeNotFound) {
+		t.Errorf("an empty type error = %v, want ErrTypeNotFound", err)
+	}
+
+	// with a registry only its types are served
+	reg := NewTypeRegistry(newMemoryTypeRepository(models.SegmentationType{Name: "drug", Active: true}), 0)
+	if err := reg.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	svc = NewSegmentationService(seededMemoryRepository(), WithTypeRegistry(reg))
+	if items, err := svc.GetByType(ctx, 10, "drugs"); err != nil || len(items) != 2 {
+		t.Errorf("a registered type = %+v, %v", items, err)
+	}
+	if _, err := svc.GetByType(ctx, 10, "specialties"); !errors.Is(err, ErrTypeNotFound) {
+		t.Errorf("an unregistered type error = %v, want ErrTypeNotFound", err)
+	}
+}
+
+func TestGetByType_UnregisteredTypes(t *testing.T) {
+	repo := seededMemoryRepository()
+	repo.rows = append(repo.rows,
+		models.Segmentation{ID: 4, UserID: 10, SegmentationType: "exam", SegmentationName: "Hemograma", Data: datatypes.JSON(`{}`)},
+		models.Segmentation{ID: 5, UserID: 10, SegmentationType: "status", SegmentationName: "Ativo", Data: datatypes.JSON(`{}`)},
+	)
+	svc := NewSegmentationService(repo)
+	ctx := context.Background()
+
+	// every group key GetByUserID returns, and the stored type itself
+	for segType, want := range map[string]string{"exams": "Hemograma", "exam": "Hemograma", "statuss": "Ativo", "status": "Ativo"} {
+		items, err := svc.GetByType(ctx, 10, segType)
+		if err != nil || len(items) != 1 || items[0].Name != want {
+			t.Errorf("GetByType(%q) = %+v, %v", segType, items, err)
+		}
+	}
+}
+
+func TestSegmentationItems_WriteJSONFields(t *testing.T) {
+	items, _ := NewSegmentationService(seededMemoryRepository()).GetByType(context.Background(), 10, "drugs")
+	fields, _ := ParseFields("name,data.dose")
+
+	var buf bytes.Buffer
+	if err := items.WriteJSONFields(&buf, fields); err != nil {
+		t.Fatalf("WriteJSONFields() error = %v", err)
+	}
+	if want := `[{"name":"Aspirina","data":{"dose":"500mg"}},{"name":"Dipirona","data":{"dose":"1g"}}]`; buf.String() != want {
+		t.Errorf("WriteJSONFields() = %s, want %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := (SegmentationItems{}).WriteJSONFields(&buf, nil); err != nil || buf.String() != "[]" {
+		t.Errorf("no items = %s, %v, want []", buf.String(), err)
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// ApplyResult tells what applying a declaration did to the registry
+type ApplyResult string
+
+const (
+	ApplyCreated   ApplyResult = "created"
+	ApplyUpdated   ApplyResult = "updated"
+	ApplyUnchanged ApplyResult = "unchanged"
+)
+
+// TypeSpec is the declared state of a type, as kept by infrastructure as
+// code: every field is set by a declaration, an omitted one is reset to
+// its default (display name = name, no schema, no labels, active)
+type TypeSpec struct {
+	DisplayName string            `json:"display_name" maxLength:"100" example:"Medicamento"`
+	Description string            `json:"description"`
+	DataSchema  json.RawMessage   `json:"data_schema,omitempty" swaggertype:"object"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Active      *bool             `json:"active,omitempty"`
+}
+
+// TypeDrift compares a type to its last declaration. Managed is false for
+// a type that was never declared through Apply; otherwise Fields lists the
+// fields changed since, by PATCH or directly in the database.
+type TypeDrift struct {
+	Managed bool      `json:"managed"`
+	InSync  bool      `json:"in_sync"`
+	Fields  []string  `json:"fields,omitempty" example:"description"`
+	Applied *TypeSpec `json:"applied,omitempty"`
+}
+
+// TypeState is a registered type with its drift
+type TypeState struct {
+	models.SegmentationType
+	Drift TypeDrift `json:"drift"`
+}
+
+// AppliedType is the type after a declaration, with what it did
+type AppliedType struct {
+	TypeState
+	Result ApplyResult `json:"result" enums:"created,updated,unchanged"`
+}
+
+// States returns every registered type with its drift
+func (r *TypeRegistry) States(ctx context.Context) ([]TypeState, error) {
+	types, err := r.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]TypeState, len(types))
+	for i := range types {
+		states[i] = typeState(&types[i])
+	}
+	return states, nil
+}
+
+// State returns a registered type with its drift
+func (r *TypeRegistry) State(ctx context.Context, name string) (*TypeState, error) {
+	t, err := r.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	state := typeState(t)
+	return &state, nil
+}
+
+// Apply makes the type name match spec, creating it if needed, and
+// records spec as its declaration. Applying the same declaration again
+// writes nothing and returns ApplyUnchanged.
+func (r *TypeRegistry) Apply(ctx context.Context, name string, spec TypeSpec) (*AppliedType, error) {
+	spec.DataSchema = canonicalJSON(spec.DataSchema)
+	t, err := newType(typeKey(name), spec)
+	if err != nil {
+		return nil, err
+	}
+	declared := specOf(t)
+	if t.AppliedSpec, err = json.Marshal(declared); err != nil {
+		return nil, err
+	}
+
+	existing, err := r.repo.FindByName(ctx, t.Name)
+	if err != nil {
+		return nil, err
+	}
+	result := ApplyCreated
+	if existing != nil {
+		applied := appliedSpec(existing)
+		if applied != nil && len(diffSpecs(*applied, declared)) == 0 && len(diffSpecs(specOf(existing), declared)) == 0 {
+			return &AppliedType{TypeState: typeState(existing), Result: ApplyUnchanged}, nil
+		}
+		t.ID, t.CreatedAt = existing.ID, existing.CreatedAt
+		err = r.repo.Update(ctx, t)
+		result = ApplyUpdated
+	} else {
+		err = r.repo.Create(ctx, t)
+		// a concurrent declaration of the same name won the unique key
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrTypeExists
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &AppliedType{TypeState: typeState(t), Result: result}, r.Refresh(ctx)
+}
+
+// ApplySchema sets the data schema of a registered type; null removes it.
+// A declared type keeps the schema in its declaration too, so that it does
+// not drift; the other fields are left as they are.
+func (r *TypeRegistry) ApplySchema(ctx context.Context, name string, schema json.RawMessage) (*AppliedType, error) {
+	t, err := r.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	schema = canonicalJSON(schema)
+	applied := appliedSpec(t)
+	if bytes.Equal(canonicalJSON(t.DataSchema), schema) && (applied == nil || bytes.Equal(applied.DataSchema, schema)) {
+		return &AppliedType{TypeState: typeState(t), Result: ApplyUnchanged}, nil
+	}
+
+	if err := setSchema(t, schema); err != nil {
+		return nil, err
+	}
+	if applied != nil {
+		applied.DataSchema = schema
+		if t.AppliedSpec, err = json.Marshal(applied); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.repo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	return &AppliedType{TypeState: typeState(t), Result: ApplyUpdated}, r.Refresh(ctx)
+}
+
+func typeState(t *models.SegmentationType) TypeState {
+	state := TypeState{SegmentationType: *t}
+	if applied := appliedSpec(t); applied != nil {
+		fields := diffSpecs(*applied, specOf(t))
+		state.Drift = TypeDrift{Managed: true, InSync: len(fields) == 0, Fields: fields, Applied: applied}
+	}
+	return state
+}
+
+// specOf is the current state of a type as a declaration
+func specOf(t *models.SegmentationType) TypeSpec {
+	active := t.Active
+	spec := TypeSpec{
+		DisplayName: t.DisplayName,
+		Description: t.Description,
+		DataSchema:  canonicalJSON(t.DataSchema),
+		Active:      &active,
+	}
+	if len(t.Labels) > 0 {
+		// the labels were validated when set; unreadable ones show as drift
+		_ = json.Unmarshal(t.Labels, &spec.Labels)
+	}
+	return spec
+}
+
+// appliedSpec is the last declaration of a type, nil if it has none
+func appliedSpec(t *models.SegmentationType) *TypeSpec {
+	if len(t.AppliedSpec) == 0 {
+		return nil
+	}
+	var spec TypeSpec
+	if err := json.Unmarshal(t.AppliedSpec, &spec); err != nil {
+		return nil
+	}
+	spec.DataSchema = canonicalJSON(spec.DataSchema)
+	if spec.Active == nil {
+		active := true
+		spec.Active = &active
+	}
+	return &spec
+}
+
+// diffSpecs returns the names of the fields that differ between a and b
+func diffSpecs(a, b TypeSpec) []string {
+	var fields []string
+	if a.DisplayName != b.DisplayName {
+		fields = append(fields, "display_name")
+	}
+	if a.Description != b.Description {
+		fields = append(fields, "description")
+	}
+	if !bytes.Equal(a.DataSchema, b.DataSchema) {
+		fields = append(fields, "data_schema")
+	}
+	if !maps.Equal(a.Labels, b.Labels) {
+		fields = append(fields, "labels")
+	}
+	if (a.Active == nil || *a.Active) != (b.Active == nil || *b.Active) {
+		fields = append(fields, "active")
+	}
+	return fields
+}
+
+// canonicalJSON re-encodes a document with sorted keys and no spacing, so
+// documents compare equal however they were written or stored (MySQL
+// reorders the members of JSON columns). Empty and null documents are nil;
+// invalid ones are returned as they are, for validation to refuse.
+func canonicalJSON(raw []byte) json.RawMessage {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+	if !json.Valid(raw) {
+		return raw
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return raw
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return out
+}
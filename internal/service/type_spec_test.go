@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"segmentation-api/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func TestTypeRegistry_Apply(t *testing.T) {
+	reg := NewTypeRegistry(newMemoryTypeRepository(), 0)
+	ctx := context.Background()
+	spec := TypeSpec{
+		DisplayName: "Medicamentos",
+		DataSchema:  json.RawMessage(`{"required": ["quantity"], "properties": {"quantity": {"type": "string"}}}`),
+		Labels:      map[string]string{"pt-br": "Medicamentos", "en": "Drugs"},
+	}
+
+	got, err := reg.Apply(ctx, "Drug", spec)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got.Result != ApplyCreated || got.Name != "drug" || !got.Active || !got.Drift.Managed || !got.Drift.InSync {
+		t.Errorf("unexpected first apply: %+v", got)
+	}
+	if _, ok := reg.lookup("drug"); !ok {
+		t.Error("applied type should be in the snapshot immediately")
+	}
+
+	// the same declaration, written differently
+	spec.DataSchema = json.RawMessage(`{"properties":{"quantity":{"type":"string"}},"required":["quantity"]}`)
+	spec.Labels = map[string]string{"en": "Drugs", "pt-BR": "Medicamentos"}
+	if got, err = reg.Apply(ctx, "drug", spec); err != nil || got.Result != ApplyUnchanged {
+		t.Errorf("Apply() again = %+v, %v, want unchanged", got, err)
+	}
+
+	spec.Description = "Medicamentos prescritos"
+	if got, err = reg.Apply(ctx, "drug", spec); err != nil || got.Result != ApplyUpdated || got.Description != spec.Description {
+		t.Errorf("Apply() changed = %+v, %v, want updated", got, err)
+	}
+
+	if _, err := reg.Apply(ctx, "drug", TypeSpec{DataSchema: json.RawMessage(`{"type": "array"}`)}); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("expected ErrInvalidType, got %v", err)
+	}
+}
+
+func TestTypeRegistry_Drift(t *testing.T) {
+	reg := NewTypeRegistry(newMemoryTypeRepository(models.SegmentationType{Name: "legacy", DisplayName: "Legacy"}), 0)
+	ctx := context.Background()
+
+	if _, err := reg.Apply(ctx, "drug", TypeSpec{DisplayName: "Medicamentos"}); err != nil {
+		t.Fatal(err)
+	}
+	inactive, description := false, "changed by hand"
+	if _, err := reg.Update(ctx, "drug", TypePatch{Active: &inactive, Description: &description}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := reg.State(ctx, "drug")
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if state.Drift.InSync || !reflect.DeepEqual(state.Drift.Fields, []string{"description", "active"}) {
+		t.Errorf("drift = %+v, want description and active", state.Drift)
+	}
+	if state.Drift.Applied == nil || state.Drift.Applied.DisplayName != "Medicamentos" || !*state.Drift.Applied.Active {
+		t.Errorf("drift should hold the declaration, got %+v", state.Drift.Applied)
+	}
+
+	// applying the declaration again brings the type back to it
+	got, err := reg.Apply(ctx, "drug", TypeSpec{DisplayName: "Medicamentos"})
+	if err != nil || got.Result != ApplyUpdated || !got.Active || got.Description != "" || !got.Drift.InSync {
+		t.Errorf("Apply() = %+v, %v, want the drift reverted", got, err)
+	}
+
+	state, _ = reg.State(ctx, "legacy")
+	if state.Drift.Managed || state.Drift.InSync {
+		t.Errorf("a type never declared is not managed, got %+v", state.Drift)
+	}
+	// the first declaration of an existing type takes it over
+	if got, err = reg.Apply(ctx, "legacy", TypeSpec{DisplayName: "Legacy"}); err != nil || got.Result != ApplyUpdated || !got.Drift.Managed {
+		t.Errorf("Apply() = %+v, %v, want the type managed", got, err)
+	}
+}
+
+func TestTypeRegistry_ApplySchema(t *testing.T) {
+	reg := NewTypeRegistry(newMemoryTypeRepository(models.SegmentationType{Name: "legacy", DisplayName: "Legacy", Active: true}), 0)
+	ctx := context.Background()
+	if _, err := reg.Apply(ctx, "drug", TypeSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	schema := json.RawMessage(`{"properties": {"dose": {"type": "number"}}}`)
+
+	got, err := reg.ApplySchema(ctx, "drug", schema)
+	if err != nil || got.Result != ApplyUpdated {
+		t.Fatalf("ApplySchema() = %+v, %v", got, err)
+	}
+	if string(got.DataSchema) != `{"properties":{"dose":{"type":"number"}}}` || !got.Drift.InSync {
+		t.Errorf("the schema should be set in the type and its declaration, got %+v", got)
+	}
+	if got, err = reg.ApplySchema(ctx, "drug", schema); err != nil || got.Result != ApplyUnchanged {
+		t.Errorf("ApplySchema() again = %+v, %v, want unchanged", got, err)
+	}
+	if got, err = reg.ApplySchema(ctx, "drug", json.RawMessage(`null`)); err != nil || got.DataSchema != nil || !got.Drift.InSync {
+		t.Errorf("null should remove the schema, got %+v, %v", got, err)
+	}
+
+	if got, err = reg.ApplySchema(ctx, "legacy", schema); err != nil || got.Drift.Managed {
+		t.Errorf("ApplySchema() should leave an undeclared type unmanaged, got %+v, %v", got, err)
+	}
+	if _, err := reg.ApplySchema(ctx, "missing", schema); !errors.Is(err, ErrTypeNotFound) {
+		t.Errorf("expected ErrTypeNotFound, got %v", err)
+	}
+	if _, err := reg.ApplySchema(ctx, "drug", json.RawMessage(`{"type": "array"}`)); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("expected ErrInvalidType, got %v", err)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	// MySQL gives JSON columns back with its own spacing and member order
+	stored := datatypes.JSON(`{"required": ["a"], "properties": {"a": {"type": "string", "maxLength": 10.50}}}`)
+	want := `{"properties":{"a":{"maxLength":10.50,"type":"string"}},"required":["a"]}`
+	if got := canonicalJSON(stored); string(got) != want {
+		t.Errorf("canonicalJSON() = %s, want %s", got, want)
+	}
+	for _, raw := range []string{"", " null ", "\n"} {
+		if got := canonicalJSON([]byte(raw)); got != nil {
+			t.Errorf("canonicalJSON(%q) = %s, want nil", raw, got)
+		}
+	}
+	if got := canonicalJSON([]byte(`{"a":`)); string(got) != `{"a":` {
+		t.Errorf("invalid documents should be kept for validation, got %s", got)
+	}
+}
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"golang.org/x/text/language"
+	"gorm.io/datatypes"
+)
+
+var (
+	// ErrTypeNotFound is returned when a type is not in the registry
+	ErrTypeNotFound = apperrors.New("segmentation type not found", apperrors.ErrNotFound)
+	// ErrTypeExists is returned when registering a type twice
+	ErrTypeExists = apperrors.New("segmentation type already registered", apperrors.ErrConflict)
+	// ErrInvalidType is returned when a registry payload fails validation
+	ErrInvalidType = apperrors.New("invalid segmentation type", apperrors.ErrValidation)
+)
+
+const maxDisplayNameLength = 100
+
+// TypeRequest registers a new segmentation type
+type TypeRequest struct {
+	Name        string            `json:"name" validate:"required" maxLength:"50" example:"drug"`
+	DisplayName string            `json:"display_name" maxLength:"100" example:"Medicamento"`
+	Description string            `json:"description"`
+	DataSchema  json.RawMessage   `json:"data_schema" swaggertype:"object"`
+	Labels      map[string]string `json:"labels"`
+	Active      *bool             `json:"active"`
+}
+
+// TypePatch partially updates a registered type; nil fields are kept
+type TypePatch struct {
+	DisplayName *string           `json:"display_name" maxLength:"100"`
+	Description *string           `json:"description"`
+	DataSchema  *json.RawMessage  `json:"data_schema" swaggertype:"object"`
+	Labels      map[string]string `json:"labels"`
+	Active      *bool             `json:"active"`
+}
+
+// TypeRegistry manages the segmentation_types table and keeps an in-memory
+// snapshot used by write validation, refreshed every ttl and after every
+// change made through it
+type TypeRegistry struct {
+	repo repository.SegmentationTypeRepository
+	ttl  time.Duration
+
+	mu    sync.RWMutex
+	types map[string]registeredType
+}
+
+type registeredType struct {
+	models.SegmentationType
+	schema *dataSchema
+	labels map[string]string
+}
+
+func NewTypeRegistry(repo repository.SegmentationTypeRepository, ttl time.Duration) *TypeRegistry {
+	return &TypeRegistry{repo: repo, ttl: ttl, types: make(map[string]registeredType)}
+}
+
+// Refresh reloads the snapshot from the repository
+func (r *TypeRegistry) Refresh(ctx context.Context) error {
+	types, err := r.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	snapshot := make(map[string]registeredType, len(types))
+	for _, t := range types {
+		schema, err := parseDataSchema(t.DataSchema)
+		if err != nil {
+			return fmt.Errorf("type %q: %w", t.Name, err)
+		}
+		var labels map[string]string
+		if len(t.Labels) > 0 {
+			if err := json.Unmarshal(t.Labels, &labels); err != nil {
+				return fmt.Errorf("type %q: invalid labels: %w", t.Name, err)
+			}
+		}
+		snapshot[typeKey(t.Name)] = registeredType{SegmentationType: t, schema: schema, labels: labels}
+	}
+
+	r.mu.Lock()
+	r.types = snapshot
+	r.mu.Unlock()
+	return nil
+}
+
+// Run refreshes the snapshot every ttl until ctx is cancelled
+func (r *TypeRegistry) Run(ctx context.Context, onError func(error)) {
+	if r.ttl <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// typeKey is the registry key for a type name: trimmed, NFC and lower-case
+func typeKey(name string) string {
+	return strings.ToLower(nfc(strings.TrimSpace(name)))
+}
+
+// lookup returns a type from the snapshot
+func (r *TypeRegistry) lookup(name string) (registeredType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[typeKey(name)]
+	return t, ok
+}
+
+// active returns the sorted names of the active types in the snapshot
+func (r *TypeRegistry) active() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.types))
+	for name, t := range r.types {
+		if t.Active {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// empty reports whether no type is registered yet; validation against the
+// registry only starts once it has been populated
+func (r *TypeRegistry) empty() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.types) == 0
+}
+
+// List returns every registered type, active or not
+func (r *TypeRegistry) List(ctx context.Context) ([]models.SegmentationType, error) {
+	return r.repo.List(ctx)
+}
+
+// Get returns a registered type by name
+func (r *TypeRegistry) Get(ctx context.Context, name string) (*models.SegmentationType, error) {
+	t, err := r.repo.FindByName(ctx, typeKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTypeNotFound
+	}
+	return t, nil
+}
+
+// Register adds a new type to the registry
+func (r *TypeRegistry) Register(ctx context.Context, req TypeRequest) (*models.SegmentationType, error) {
+	name := typeKey(req.Name)
+	t, err := newType(name, TypeSpec{
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+		DataSchema:  req.DataSchema,
+		Labels:      req.Labels,
+		Active:      req.Active,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := r.repo.FindByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrTypeExists
+	}
+
+	if err := r.repo.Create(ctx, t); err != nil {
+		// a concurrent registration of the same name won the unique key
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrTypeExists
+		}
+		return nil, err
+	}
+	return t, r.Refresh(ctx)
+}
+
+// Update applies a partial update to a registered type
+func (r *TypeRegistry) Update(ctx context.Context, name string, patch TypePatch) (*models.SegmentationType, error) {
+	t, err := r.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.DisplayName != nil {
+		t.DisplayName = strings.TrimSpace(*patch.DisplayName)
+		if t.DisplayName == "" {
+			t.DisplayName = t.Name
+		}
+		if len(t.DisplayName) > maxDisplayNameLength {
+			return nil, fmt.Errorf("%w: display_name exceeds %d characters", ErrInvalidType, maxDisplayNameLength)
+		}
+	}
+	if patch.Description != nil {
+		t.Description = strings.TrimSpace(*patch.Description)
+	}
+	if patch.DataSchema != nil {
+		if err := setSchema(t, *patch.DataSchema); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Labels != nil {
+		if err := setLabels(t, patch.Labels); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Active != nil {
+		t.Active = *patch.Active
+	}
+
+	if err := r.repo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, r.Refresh(ctx)
+}
+
+// newType validates a type named name, already a typeKey, as described by
+// spec; the display name defaults to the name and active to true
+func newType(name string, spec TypeSpec) (*models.SegmentationType, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidType)
+	}
+	if len(name) > maxTypeLength {
+		return nil, fmt.Errorf("%w: name exceeds %d characters", ErrInvalidType, maxTypeLength)
+	}
+
+	t := &models.SegmentationType{
+		Name:        name,
+		DisplayName: strings.TrimSpace(spec.DisplayName),
+		Description: strings.TrimSpace(spec.Description),
+		Active:      true,
+	}
+	if spec.Active != nil {
+		t.Active = *spec.Active
+	}
+	if t.DisplayName == "" {
+		t.DisplayName = name
+	}
+	if err := setSchema(t, spec.DataSchema); err != nil {
+		return nil, err
+	}
+	if err := setLabels(t, spec.Labels); err != nil {
+		return nil, err
+	}
+	if len(t.DisplayName) > maxDisplayNameLength {
+		return nil, fmt.Errorf("%w: display_name exceeds %d characters", ErrInvalidType, maxDisplayNameLength)
+	}
+	return t, nil
+}
+
+func setSchema(t *models.SegmentationType, raw json.RawMessage) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		t.DataSchema = nil
+		return nil
+	}
+	if _, err := parseDataSchema(raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidType, err)
+	}
+	t.DataSchema = datatypes.JSON(raw)
+	return nil
+}
+
+// setLabels validates the locale tags and stores them in canonical form
+// ("pt-br" becomes "pt-BR"). An empty map clears the labels.
+func setLabels(t *models.SegmentationType, labels map[string]string) error {
+	if len(labels) == 0 {
+		t.Labels = nil
+		return nil
+	}
+
+	canonical := make(map[string]string, len(labels))
+	for locale, label := range labels {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return fmt.Errorf("%w: invalid locale %q", ErrInvalidType, locale)
+		}
+		label = strings.TrimSpace(label)
+		if label == "" || len(label) > maxDisplayNameLength {
+			return fmt.Errorf("%w: label for %q must have 1 to %d characters", ErrInvalidType, locale, maxDisplayNameLength)
+		}
+		canonical[tag.String()] = label
+	}
+
+	raw, err := json.Marshal(canonical)
+	if err != nil {
+		return err
+	}
+	t.Labels = datatypes.JSON(raw)
+	return nil
+}
+
+// label returns the display label of a type for locale, falling back to a
+// label of the same base language and finally to the display name
+func (t registeredType) label(locale string) string {
+	if l, ok := t.labels[locale]; ok {
+		return l
+	}
+
+	tag, err := language.Parse(locale)
+	if err == nil {
+		base, _ := tag.Base()
+		if l, ok := t.labels[base.String()]; ok {
+			return l
+		}
+		for loc, l := range t.labels {
+			if other, err := language.Parse(loc); err == nil {
+				if b, _ := other.Base(); b == base {
+					return l
+				}
+			}
+		}
+	}
+
+	return t.DisplayName
+}
+
+// dataSchema is the JSON Schema subset supported by the registry: top-level
+// property types, required keys and additionalProperties
+type dataSchema struct {
+	Type       string `json:"type"`
+	Properties map[string]struct {
+		Type string `json:"type"`
+	} `json:"properties"`
+	Required             []string `json:"required"`
+	AdditionalProperties *bool    `json:"additionalProperties"`
+}
+
+var schemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"object": true, "array": true, "null": true,
+}
+
+func parseDataSchema(raw []byte) (*dataSchema, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var s dataSchema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("data_schema is not a valid schema: %v", err)
+	}
+	if s.Type != "" && s.Type != "object" {
+		return nil, fmt.Errorf("data_schema type must be \"object\"")
+	}
+	for key, prop := range s.Properties {
+		if prop.Type != "" && !schemaTypes[prop.Type] {
+			return nil, fmt.Errorf("data_schema property %q has unsupported type %q", key, prop.Type)
+		}
+	}
+	return &s, nil
+}
+
+// check validates data against the schema. Unknown keys (when
+// additionalProperties is false) are returned separately so callers can
+// treat them as warnings; missing or mistyped keys are errors.
+func (s *dataSchema) check(data []byte) (unknown []string, err error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.New("data must be a JSON object")
+	}
+
+	for _, key := range s.Required {
+		if _, ok := doc[key]; !ok {
+			return nil, fmt.Errorf("data.%s is required", key)
+		}
+	}
+
+	for key, value := range doc {
+		prop, known := s.Properties[key]
+		if !known {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				unknown = append(unknown, key)
+			}
+			continue
+		}
+		if prop.Type != "" && !matchesSchemaType(value, prop.Type) {
+			return nil, fmt.Errorf("data.%s must be of type %s", key, prop.Type)
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+func matchesSchemaType(v interface{}, t string) bool {
+	switch t {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
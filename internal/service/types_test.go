@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"gorm.io/datatypes"
+)
+
+type memoryTypeRepository struct {
+	types map[string]models.SegmentationType
+}
+
+func newMemoryTypeRepository(types ...models.SegmentationType) *memoryTypeRepository {
+	m := &memoryTypeRepository{types: make(map[string]models.SegmentationType)}
+	for _, t := range types {
+		m.types[t.Name] = t
+	}
+	return m
+}
+
+func (m *memoryTypeRepository) List(ctx context.Context) ([]models.SegmentationType, error) {
+	var out []models.SegmentationType
+	for _, t := range m.types {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (m *memoryTypeRepository) FindByName(ctx context.Context, name string) (*models.SegmentationType, error) {
+	t, ok := m.types[name]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (m *memoryTypeRepository) Create(ctx context.Context, t *models.SegmentationType) error {
+	m.types[t.Name] = *t
+	return nil
+}
+
+func (m *memoryTypeRepository) Update(ctx context.Context, t *models.SegmentationType) error {
+	m.types[t.Name] = *t
+	return nil
+}
+
+func drugSchemaRegistry(t *testing.T) *TypeRegistry {
+	t.Helper()
+	repo := newMemoryTypeRepository(
+		models.SegmentationType{
+			Name:        "drug",
+			DisplayName: "Medicamentos",
+			Active:      true,
+			DataSchema: datatypes.JSON(`{
+				"type": "object",
+				"properties": {"quantity": {"type": "string"}, "dose": {"type": "number"}},
+				"required": ["quantity"],
+				"additionalProperties": false
+			}`),
+		},
+		models.SegmentationType{Name: "legacy", DisplayName: "Legacy", Active: false},
+	)
+	reg := NewTypeRegistry(repo, 0)
+	if err := reg.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	return reg
+}
+
+func TestTypeRegistry_RegisterAndGet(t *testing.T) {
+	reg := NewTypeRegistry(newMemoryTypeRepository(), 0)
+	ctx := context.Background()
+
+	created, err := reg.Register(ctx, TypeRequest{
+		Name:        " Specialty ",
+		DisplayName: "Especialidades",
+		DataSchema:  json.RawMessage(`{"properties": {"years": {"type": "integer"}}}`),
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if created.Name != "specialty" || !created.Active {
+		t.Errorf("unexpected type: %+v", created)
+	}
+
+	if _, ok := reg.lookup("SPECIALTY"); !ok {
+		t.Error("registered type should be in the snapshot immediately")
+	}
+
+	if _, err := reg.Register(ctx, TypeRequest{Name: "Especialização"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, ok := reg.lookup("especializac\u0327a\u0303o"); !ok {
+		t.Error("a decomposed type name should find the registered type")
+	}
+
+	if _, err := reg.Register(ctx, TypeRequest{Name: "specialty"}); !errors.Is(err, ErrTypeExists) {
+		t.Errorf("expected ErrTypeExists, got %v", err)
+	}
+
+	if _, err := reg.Get(ctx, "missing"); !errors.Is(err, ErrTypeNotFound) {
+		t.Errorf("expected ErrTypeNotFound, got %v", err)
+	}
+}
+
+// racingTypeRepository loses every Create to a concurrent registration
+type racingTypeRepository struct {
+	*memoryTypeRepository
+}
+
+func (r racingTypeRepository) Create(ctx context.Context, t *models.SegmentationType) error {
+	return &repository.ConflictError{Key: []string{"name"}, Value: t.Name}
+}
+
+func TestTypeRegistry_RegisterConflict(t *testing.T) {
+	reg := NewTypeRegistry(racingTypeRepository{newMemoryTypeRepository()}, 0)
+	if _, err := reg.Register(context.Background(), TypeRequest{Name: "drug"}); !errors.Is(err, ErrTypeExists) {
+		t.Errorf("expected ErrTypeExists, got %v", err)
+	}
+}
+
+func TestTypeRegistry_RegisterValidation(t *testing.T) {
+	reg := NewTypeRegistry(newMemoryTypeRepository(), 0)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  TypeRequest
+	}{
+		{name: "empty name", req: TypeRequest{Name: " "}},
+		{name: "bad schema json", req: TypeRequest{Name: "x", DataSchema: json.RawMessage(`[1]`)}},
+		{name: "non object schema", req: TypeRequest{Name: "x", DataSchema: json.RawMessage(`{"type": "array"}`)}},
+		{name: "unsupported property type", req: TypeRequest{Name: "x", DataSchema: json.RawMessage(`{"properties": {"a": {"type": "date"}}}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := reg.Register(ctx, tt.req); !errors.Is(err, ErrInvalidType) {
+				t.Fatalf("expected ErrInvalidType, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTypeRegistry_Update(t *testing.T) {
+	reg := drugSchemaRegistry(t)
+	inactive := false
+
+	updated, err := reg.Update(context.Background(), "drug", TypePatch{Active: &inactive})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.Active || updated.DisplayName != "Medicamentos" {
+		t.Errorf("only active should change, got %+v", updated)
+	}
+
+	got, _ := reg.lookup("drug")
+	if got.Active {
+		t.Error("snapshot should reflect the update")
+	}
+}
+
+func TestValidate_AgainstRegistry(t *testing.T) {
+	reg := drugSchemaRegistry(t)
+
+	tests := []struct {
+		name         string
+		mode         ValidationMode
+		segType      string
+		data         string
+		wantErr      bool
+		wantWarnings int
+	}{
+		{name: "valid", mode: ValidationLenient, segType: "drug", data: `{"quantity": "10"}`},
+		{name: "unknown key lenient", mode: ValidationLenient, segType: "drug", data: `{"quantity": "10", "x": 1}`, wantWarnings: 1},
+		{name: "unknown key strict", mode: ValidationStrict, segType: "drug", data: `{"quantity": "10", "x": 1}`, wantErr: true},
+		{name: "missing required", mode: ValidationLenient, segType: "drug", data: `{"dose": 1}`, wantErr: true},
+		{name: "wrong type", mode: ValidationLenient, segType: "drug", data: `{"quantity": 10}`, wantErr: true},
+		{name: "unregistered lenient", mode: ValidationLenient, segType: "patient", data: `{}`, wantWarnings: 1},
+		{name: "unregistered strict", mode: ValidationStrict, segType: "patient", data: `{}`, wantErr: true},
+		{name: "inactive strict", mode: ValidationStrict, segType: "legacy", data: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewSegmentationService(
+				&MockRepository{},
+				WithValidationRules(ValidationRules{Mode: tt.mode}),
+				WithTypeRegistry(reg),
+			)
+			seg := &models.Segmentation{SegmentationType: tt.segType, Data: datatypes.JSON(tt.data)}
+
+			warnings, err := svc.Validate(seg)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSegmentation) {
+					t.Fatalf("expected ErrInvalidSegmentation, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Fatalf("expected %d warnings, got %+v", tt.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidate_StrictUnknownType(t *testing.T) {
+	svc := NewSegmentationService(
+		&MockRepository{},
+		WithValidationRules(ValidationRules{Mode: ValidationStrict}),
+		WithTypeRegistry(drugSchemaRegistry(t)),
+	)
+
+	for _, segType := range []string{"patient", "legacy"} {
+		_, err := svc.Validate(&models.Segmentation{SegmentationType: segType, Data: datatypes.JSON(`{}`)})
+		var unknown *UnknownTypeError
+		if !errors.As(err, &unknown) || !errors.Is(err, ErrInvalidSegmentation) {
+			t.Fatalf("%s: expected an UnknownTypeError, got %v", segType, err)
+		}
+		if unknown.Type != segType || !reflect.DeepEqual(unknown.Allowed, []string{"drug"}) {
+			t.Errorf("%s: unexpected error %+v", segType, unknown)
+		}
+	}
+}
+
+func TestValidate_EmptyRegistryIsIgnored(t *testing.T) {
+	reg := NewTypeRegistry(newMemoryTypeRepository(), 0)
+	svc := NewSegmentationService(
+		&MockRepository{},
+		WithValidationRules(ValidationRules{Mode: ValidationStrict}),
+		WithTypeRegistry(reg),
+	)
+
+	if _, err := svc.Validate(&models.Segmentation{SegmentationType: "drug", Data: datatypes.JSON(`{}`)}); err != nil {
+		t.Fatalf("an empty registry should not reject writes: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"segmentation-api/internal/apperrors"
+)
+
+// ErrInvalidUserID is wrapped by every UserIDError
+var ErrInvalidUserID = apperrors.New("invalid user_id", apperrors.ErrValidation)
+
+// UserIDError is a user_id that is not a canonical decimal within bounds.
+// It also wraps ErrInvalidSegmentation, so writes answer it as any other
+// invalid input.
+type UserIDError struct {
+	Value  string
+	Reason string
+}
+
+func (e *UserIDError) Error() string {
+	return fmt.Sprintf("invalid user_id %q: %s", e.Value, e.Reason)
+}
+
+// Details reports the offending value and the rule it broke
+func (e *UserIDError) Details() map[string]any {
+	return map[string]any{"value": e.Value, "reason": e.Reason}
+}
+
+func (e *UserIDError) Unwrap() []error {
+	return []error{ErrInvalidUserID, ErrInvalidSegmentation}
+}
+
+// ParseUserID parses a user_id written as a canonical decimal: digits only,
+// without sign, surrounding whitespace or leading zeros, and at most max
+// (0 allows the whole uint64 range). Anything else is a *UserIDError, so
+// junk never becomes a valid key by accident.
+func ParseUserID(v string, max uint64) (uint64, error) {
+	invalid := func(reason string) (uint64, error) {
+		return 0, &UserIDError{Value: v, Reason: reason}
+	}
+
+	switch {
+	case v == "":
+		return invalid("must not be empty")
+	case v[0] == '-':
+		return invalid("must not be negative")
+	case v[0] == '+':
+		return invalid("must not have a sign")
+	}
+	for i := 0; i < len(v); i++ {
+		if v[i] < '0' || v[i] > '9' {
+			return invalid("must contain only digits")
+		}
+	}
+	if len(v) > 1 && v[0] == '0' {
+		return invalid("must not have leading zeros")
+	}
+
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return invalid("out of range")
+	}
+	return id, checkUserID(v, id, max)
+}
+
+// ParseUserID parses v with ParseUserID and the configured maximum
+func (s *SegmentationService) ParseUserID(v string) (uint64, error) {
+	return ParseUserID(v, s.rules.MaxUserID)
+}
+
+// checkUserID rejects ids above max; v is the value reported in the error
+func checkUserID(v string, id, max uint64) error {
+	if max > 0 && id > max {
+		return &UserIDError{Value: v, Reason: fmt.Sprintf("must be at most %d", max)}
+	}
+	return nil
+}
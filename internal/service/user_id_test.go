@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseUserID(t *testing.T) {
+	tests := []struct {
+		value  string
+		max    uint64
+		want   uint64
+		reason string
+	}{
+		{value: "42", want: 42},
+		{value: "0", want: 0},
+		{value: "18446744073709551615", want: 1<<64 - 1},
+		{value: "9007199254740991", max: 1<<53 - 1, want: 1<<53 - 1},
+		{value: "", reason: "must not be empty"},
+		{value: "-1", reason: "must not be negative"},
+		{value: "+42", reason: "must not have a sign"},
+		{value: "042", reason: "must not have leading zeros"},
+		{value: " 42", reason: "must contain only digits"},
+		{value: "42 ", reason: "must contain only digits"},
+		{value: "4_2", reason: "must contain only digits"},
+		{value: "0x2a", reason: "must contain only digits"},
+		{value: "18446744073709551616", reason: "out of range"},
+		{value: "9007199254740992", max: 1<<53 - 1, reason: "must be at most 9007199254740991"},
+	}
+	for _, tt := range tests {
+		got, err := ParseUserID(tt.value, tt.max)
+		if tt.reason == "" {
+			if err != nil || got != tt.want {
+				t.Errorf("ParseUserID(%q) = %d, %v; want %d", tt.value, got, err, tt.want)
+			}
+			continue
+		}
+		var invalid *UserIDError
+		if !errors.As(err, &invalid) || invalid.Value != tt.value || invalid.Reason != tt.reason {
+			t.Errorf("ParseUserID(%q) error = %v, want reason %q", tt.value, err, tt.reason)
+		}
+		if !errors.Is(err, ErrInvalidUserID) || !errors.Is(err, ErrInvalidSegmentation) {
+			t.Errorf("ParseUserID(%q) error = %v should wrap ErrInvalidUserID and ErrInvalidSegmentation", tt.value, err)
+		}
+	}
+}
+
+func TestPrepare_MaxUserID(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{}, WithValidationRules(ValidationRules{MaxUserID: 1000}))
+	req := UpsertRequest{SegmentationType: "drug", SegmentationName: "Aspirina", Data: []byte(`{}`)}
+
+	if _, _, err := svc.Prepare(context.Background(), 1000, req); err != nil {
+		t.Errorf("Prepare(1000) error = %v", err)
+	}
+	if _, _, err := svc.Prepare(context.Background(), 1001, req); !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("Prepare(1001) error = %v, want ErrInvalidUserID", err)
+	}
+}
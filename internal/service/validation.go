@@ -0,0 +1,251 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/models"
+)
+
+// ValidationMode controls how non-fatal validation issues are handled
+type ValidationMode string
+
+const (
+	// ValidationLenient accepts non-fatal issues and reports them as warnings
+	ValidationLenient ValidationMode = "lenient"
+	// ValidationStrict rejects writes with any validation issue
+	ValidationStrict ValidationMode = "strict"
+)
+
+// Warning codes reported in lenient mode
+const (
+	WarningUnknownDataKey = "unknown_data_key"
+	WarningDeprecatedType = "deprecated_type"
+	WarningUnregistered   = "unregistered_type"
+)
+
+// ErrDataTooLarge is returned, wrapped in ErrInvalidSegmentation, when the
+// data of a write exceeds the configured limit
+var ErrDataTooLarge = apperrors.New("data too large", apperrors.ErrTooLarge)
+
+// ErrUnknownType is returned, wrapped in an UnknownTypeError, when strict
+// mode rejects a type missing from the registry or inactive in it
+var ErrUnknownType = apperrors.New("unknown segmentation type", apperrors.ErrUnprocessable)
+
+// UnknownTypeError rejects a write in strict mode because its type is not an
+// active registered type. Allowed lists the types that would be accepted.
+type UnknownTypeError struct {
+	Type    string
+	Allowed []string
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("%s: %s %q", ErrInvalidSegmentation, ErrUnknownType, e.Type)
+}
+
+// Details lists the allowed types in the error response
+func (e *UnknownTypeError) Details() map[string]any {
+	return map[string]any{"allowed": e.Allowed}
+}
+
+// Unwrap makes errors.Is match both ErrInvalidSegmentation and ErrUnknownType
+func (e *UnknownTypeError) Unwrap() []error {
+	return []error{ErrInvalidSegmentation, ErrUnknownType}
+}
+
+// Warning is a non-fatal validation finding
+type Warning struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationRules describes the checks applied on top of the structural
+// validation every write goes through
+type ValidationRules struct {
+	Mode ValidationMode
+	// DeprecatedTypes maps a legacy type name to its canonical replacement
+	DeprecatedTypes map[string]string
+	// DataKeys lists the known top-level data keys per type; types without
+	// an entry accept any key
+	DataKeys map[string][]string
+	// MaxDataBytes caps the size of the data document; 0 disables the limit
+	MaxDataBytes int
+	// DataLimits caps the nesting, key count and string length of the
+	// data document
+	DataLimits DataLimits
+	// Names normalizes segmentation names and derives their unique key
+	Names NamePolicy
+	// MaxUserID is the largest accepted user_id; 0 allows the whole uint64
+	// range
+	MaxUserID uint64
+}
+
+// DefaultValidationRules is lenient with no deprecations or key lists
+func DefaultValidationRules() ValidationRules {
+	return ValidationRules{Mode: ValidationLenient}
+}
+
+// ValidationRulesFromConfig builds the rules of cfg. Type names are
+// lowercased like every stored segmentation type.
+func ValidationRulesFromConfig(cfg config.Validation) (ValidationRules, error) {
+	rules := DefaultValidationRules()
+
+	switch mode := ValidationMode(strings.ToLower(strings.TrimSpace(cfg.Mode))); mode {
+	case "":
+	case ValidationLenient, ValidationStrict:
+		rules.Mode = mode
+	default:
+		return rules, fmt.Errorf("invalid validation mode %q", mode)
+	}
+
+	if len(cfg.DeprecatedTypes) > 0 {
+		rules.DeprecatedTypes = make(map[string]string, len(cfg.DeprecatedTypes))
+		for k, v := range cfg.DeprecatedTypes {
+			rules.DeprecatedTypes[strings.ToLower(k)] = strings.ToLower(v)
+		}
+	}
+
+	if len(cfg.DataKeys) > 0 {
+		rules.DataKeys = make(map[string][]string, len(cfg.DataKeys))
+		for t, keys := range cfg.DataKeys {
+			rules.DataKeys[strings.ToLower(t)] = keys
+		}
+	}
+
+	rules.MaxDataBytes = cfg.MaxDataBytes
+	rules.DataLimits = DataLimits{
+		MaxDepth:       cfg.MaxDataDepth,
+		MaxKeys:        cfg.MaxDataKeys,
+		MaxStringBytes: cfg.MaxDataStringBytes,
+	}
+	rules.Names = NamePolicy{FoldCase: cfg.NameFoldCase, StripAccents: cfg.NameStripAccents}
+	rules.MaxUserID = cfg.MaxUserID
+
+	return rules, nil
+}
+
+// CheckDataSize returns an ErrDataTooLarge when data exceeds the configured
+// limit. Validate applies it too; the processor calls it first so oversized
+// rows are rejected before their JSON is parsed.
+func (s *SegmentationService) CheckDataSize(data []byte) error {
+	if limit := s.rules.MaxDataBytes; limit > 0 && len(data) > limit {
+		return fmt.Errorf("%w: %w: %d bytes, at most %d allowed", ErrInvalidSegmentation, ErrDataTooLarge, len(data), limit)
+	}
+	return nil
+}
+
+// Validate applies the configured rules to a structurally valid
+// segmentation. Data larger than the configured limit, or beyond the
+// DataLimits, is always an error.
+// The type is put in NFC, the name is normalized in place and NameKey set
+// by the name policy.
+// Deprecated types are rewritten to their canonical name in
+// place. When a populated type registry is configured, unregistered or
+// inactive types are flagged and data is checked against the type's schema. In lenient mode
+// findings are returned as warnings; in strict mode the first finding is
+// returned as an ErrInvalidSegmentation, an UnknownTypeError when the type
+// is not an active registered one. Schema violations other than unknown keys
+// are always errors.
+func (s *SegmentationService) Validate(seg *models.Segmentation) ([]Warning, error) {
+	if err := s.CheckDataSize(seg.Data); err != nil {
+		return nil, err
+	}
+	if err := s.rules.DataLimits.check(seg.Data); err != nil {
+		return nil, err
+	}
+
+	seg.SegmentationType = nfc(seg.SegmentationType)
+	seg.SegmentationName = s.rules.Names.Normalize(seg.SegmentationName)
+	seg.NameKey = s.rules.Names.Key(seg.SegmentationName)
+
+	var warnings []Warning
+
+	segType := strings.ToLower(seg.SegmentationType)
+	if canonical, ok := s.rules.DeprecatedTypes[segType]; ok {
+		warnings = append(warnings, Warning{
+			Code:    WarningDeprecatedType,
+			Field:   "segmentation_type",
+			Message: fmt.Sprintf("type %q is deprecated, use %q", seg.SegmentationType, canonical),
+		})
+		seg.SegmentationType = canonical
+		segType = canonical
+	}
+
+	if s.types != nil && !s.types.empty() {
+		registered, ok := s.types.lookup(segType)
+		switch {
+		case !ok:
+			warnings = append(warnings, Warning{
+				Code:    WarningUnregistered,
+				Field:   "segmentation_type",
+				Message: fmt.Sprintf("type %q is not registered", seg.SegmentationType),
+			})
+		case !registered.Active:
+			warnings = append(warnings, Warning{
+				Code:    WarningUnregistered,
+				Field:   "segmentation_type",
+				Message: fmt.Sprintf("type %q is inactive", seg.SegmentationType),
+			})
+		default:
+			unknown, err := registered.schema.check(seg.Data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSegmentation, err)
+			}
+			for _, key := range unknown {
+				warnings = append(warnings, Warning{
+					Code:    WarningUnknownDataKey,
+					Field:   "data." + key,
+					Message: fmt.Sprintf("key %q is not in the schema of type %q", key, segType),
+				})
+			}
+		}
+	}
+
+	if allowed, ok := s.rules.DataKeys[segType]; ok {
+		for _, key := range unknownKeys(seg.Data, allowed) {
+			warnings = append(warnings, Warning{
+				Code:    WarningUnknownDataKey,
+				Field:   "data." + key,
+				Message: fmt.Sprintf("key %q is not known for type %q", key, segType),
+			})
+		}
+	}
+
+	if s.rules.Mode == ValidationStrict && len(warnings) > 0 {
+		for _, w := range warnings {
+			if w.Code == WarningUnregistered {
+				return nil, &UnknownTypeError{Type: seg.SegmentationType, Allowed: s.types.active()}
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSegmentation, warnings[0].Message)
+	}
+	return warnings, nil
+}
+
+// unknownKeys returns the sorted top-level keys of data not in allowed.
+// Non-object documents have no keys to check.
+func unknownKeys(data []byte, allowed []string) []string {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+
+	known := make(map[string]bool, len(allowed))
+	for _, k := range allowed {
+		known[k] = true
+	}
+
+	var unknown []string
+	for k := range doc {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/config"
+	"segmentation-api/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func testRules(mode ValidationMode) ValidationRules {
+	return ValidationRules{
+		Mode:            mode,
+		DeprecatedTypes: map[string]string{"medication": "drug"},
+		DataKeys:        map[string][]string{"drug": {"quantity", "dose"}},
+	}
+}
+
+func TestValidate_LenientCollectsWarnings(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{}, WithValidationRules(testRules(ValidationLenient)))
+
+	seg := &models.Segmentation{
+		UserID:           1,
+		SegmentationType: "medication",
+		SegmentationName: "Aspirina",
+		Data:             datatypes.JSON(`{"dose": "1g", "legacy_code": 7, "color": "red"}`),
+	}
+
+	warnings, err := svc.Validate(seg)
+	if err != nil {
+		t.Fatalf("lenient mode should not fail: %v", err)
+	}
+	if seg.SegmentationType != "drug" {
+		t.Errorf("deprecated type should be rewritten, got %q", seg.SegmentationType)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("expected 3 warnings, got %d: %+v", len(warnings), warnings)
+	}
+	if warnings[0].Code != WarningDeprecatedType {
+		t.Errorf("first warning should be the deprecation, got %s", warnings[0].Code)
+	}
+	if warnings[1].Field != "data.color" || warnings[2].Field != "data.legacy_code" {
+		t.Errorf("unknown keys should be reported sorted, got %+v", warnings[1:])
+	}
+}
+
+func TestValidate_StrictRejects(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{}, WithValidationRules(testRules(ValidationStrict)))
+
+	seg := &models.Segmentation{
+		SegmentationType: "drug",
+		Data:             datatypes.JSON(`{"unexpected": true}`),
+	}
+
+	if _, err := svc.Validate(seg); !errors.Is(err, ErrInvalidSegmentation) {
+		t.Fatalf("strict mode should reject unknown keys, got %v", err)
+	}
+}
+
+func TestValidate_NoRulesNoWarnings(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{})
+
+	seg := &models.Segmentation{
+		SegmentationType: "anything",
+		Data:             datatypes.JSON(`{"x": 1}`),
+	}
+
+	warnings, err := svc.Validate(seg)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("default rules should accept everything, got %v / %v", warnings, err)
+	}
+}
+
+func TestValidate_NormalizesToNFC(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{})
+
+	composed := &models.Segmentation{SegmentationType: "classe", SegmentationName: "Antibióticos"}
+	decomposed := &models.Segmentation{SegmentationType: "classe", SegmentationName: "Antibio\u0301ticos"}
+	for _, seg := range []*models.Segmentation{composed, decomposed} {
+		if _, err := svc.Validate(seg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if decomposed.SegmentationName != composed.SegmentationName || decomposed.NameKey != composed.NameKey {
+		t.Errorf("decomposed name stored as %q (key %q), want %q (key %q)",
+			decomposed.SegmentationName, decomposed.NameKey, composed.SegmentationName, composed.NameKey)
+	}
+}
+
+func TestValidate_NonObjectData(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{}, WithValidationRules(testRules(ValidationStrict)))
+
+	seg := &models.Segmentation{SegmentationType: "drug", Data: datatypes.JSON(`[1, 2]`)}
+	if _, err := svc.Validate(seg); err != nil {
+		t.Fatalf("non-object data has no keys to check: %v", err)
+	}
+}
+
+func TestValidate_MaxDataBytes(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{}, WithValidationRules(ValidationRules{
+		Mode:         ValidationLenient,
+		MaxDataBytes: 16,
+	}))
+
+	small := &models.Segmentation{SegmentationType: "drug", Data: datatypes.JSON(`{"dose":"1g"}`)}
+	if _, err := svc.Validate(small); err != nil {
+		t.Fatalf("data under the limit should pass: %v", err)
+	}
+
+	large := &models.Segmentation{SegmentationType: "drug", Data: datatypes.JSON(`{"notes":"0123456789"}`)}
+	_, err := svc.Validate(large)
+	if !errors.Is(err, ErrDataTooLarge) || !errors.Is(err, ErrInvalidSegmentation) {
+		t.Fatalf("oversized data should fail even in lenient mode, got %v", err)
+	}
+}
+
+func TestUpsert_ReturnsWarnings(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{}, WithValidationRules(testRules(ValidationLenient)))
+
+	resp, _, err := svc.Upsert(context.Background(), 1, UpsertRequest{
+		SegmentationType: "medications",
+		SegmentationName: "Aspirina",
+		Data:             json.RawMessage(`{"dose": "1g"}`),
+	})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if resp.SegmentationType != "drug" || len(resp.Warnings) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestReplace_WarningsCarryItemPath(t *testing.T) {
+	repo := seededMemoryRepository()
+	svc := NewSegmentationService(repo, WithValidationRules(testRules(ValidationLenient)))
+
+	result, err := svc.Replace(context.Background(), 10, ReplaceRequest{
+		Segmentations: map[string][]SegmentationInput{
+			"drugs": {{Name: "Aspirina", Data: json.RawMessage(`{"foo": 1}`)}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Field != "segmentations.drugs[0].data.foo" {
+		t.Fatalf("unexpected warnings: %+v", result.Warnings)
+	}
+}
+
+func TestValidationRulesFromConfig(t *testing.T) {
+	rules, err := ValidationRulesFromConfig(config.Validation{
+		Mode:            "STRICT",
+		DeprecatedTypes: map[string]string{"Medication": "drug", "especialidade": "Specialty"},
+		DataKeys:        map[string][]string{"Drug": {"quantity", "dose"}},
+		MaxDataBytes:    1024,
+	})
+	if err != nil {
+		t.Fatalf("ValidationRulesFromConfig() error = %v", err)
+	}
+	if rules.MaxDataBytes != 1024 {
+		t.Errorf("max data bytes = %d, want 1024", rules.MaxDataBytes)
+	}
+	if rules.Mode != ValidationStrict {
+		t.Errorf("mode = %s, want strict", rules.Mode)
+	}
+	if rules.DeprecatedTypes["medication"] != "drug" || rules.DeprecatedTypes["especialidade"] != "specialty" {
+		t.Errorf("unexpected deprecated types: %v", rules.DeprecatedTypes)
+	}
+	if len(rules.DataKeys["drug"]) != 2 {
+		t.Errorf("unexpected data keys: %v", rules.DataKeys)
+	}
+}
+
+func TestValidationRulesFromConfig_Invalid(t *testing.T) {
+	if _, err := ValidationRulesFromConfig(config.Validation{Mode: "paranoid"}); err == nil {
+		t.Error("unknown mode should fail")
+	}
+}
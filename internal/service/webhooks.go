@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+var (
+	// ErrWebhookNotFound is returned when a webhook does not exist
+	ErrWebhookNotFound = apperrors.New("webhook not found", apperrors.ErrNotFound)
+	// ErrDeliveryNotFound is returned when a delivery is not one of the
+	// webhook's
+	ErrDeliveryNotFound = apperrors.New("webhook delivery not found", apperrors.ErrNotFound)
+	// ErrInvalidWebhook is returned when a webhook payload fails validation
+	ErrInvalidWebhook = apperrors.New("invalid webhook", apperrors.ErrValidation)
+	// ErrDeliveryNotFailed is returned when retrying a delivery that has not
+	// failed
+	ErrDeliveryNotFailed = apperrors.New("only failed deliveries can be retried", apperrors.ErrConflict)
+)
+
+const (
+	maxWebhookURLLength    = 2048
+	minWebhookSecretLength = 16
+	maxWebhookSecretLength = 128
+)
+
+// WebhookRequest registers a webhook. Without a secret one is generated;
+// without event types the webhook gets every event; without a format it
+// gets JSON bodies.
+type WebhookRequest struct {
+	URL        string   `json:"url" validate:"required" maxLength:"2048" example:"https://crm.example.com/hooks/segmentations"`
+	Secret     string   `json:"secret" minLength:"16" maxLength:"128"`
+	EventTypes []string `json:"event_types" enums:"segmentation.upserted,segmentation.deleted"`
+	Format     string   `json:"format" enums:"json,protobuf"`
+	Active     *bool    `json:"active"`
+}
+
+// WebhookPatch partially updates a webhook; nil fields are kept. An empty
+// secret generates a new one.
+type WebhookPatch struct {
+	URL        *string   `json:"url" maxLength:"2048"`
+	Secret     *string   `json:"secret" maxLength:"128"`
+	EventTypes *[]string `json:"event_types" enums:"segmentation.upserted,segmentation.deleted"`
+	Format     *string   `json:"format" enums:"json,protobuf"`
+	Active     *bool     `json:"active"`
+}
+
+// Webhook is a webhook as the API shows it. Secret is only set in the
+// answer of the request that chose or generated it.
+type Webhook struct {
+	ID         uint64    `json:"id" example:"1"`
+	URL        string    `json:"url" example:"https://crm.example.com/hooks/segmentations"`
+	EventTypes []string  `json:"event_types"`
+	Format     string    `json:"format" enums:"json,protobuf"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  Timestamp `json:"created_at" swaggertype:"string" format:"date-time"`
+	UpdatedAt  Timestamp `json:"updated_at" swaggertype:"string" format:"date-time"`
+}
+
+// Webhooks manages the webhook subscriptions and shows their deliveries
+type Webhooks struct {
+	repo repository.WebhookRepository
+}
+
+func NewWebhooks(repo repository.WebhookRepository) *Webhooks {
+	return &Webhooks{repo: repo}
+}
+
+// List returns every webhook
+func (s *Webhooks) List(ctx context.Context) ([]Webhook, error) {
+	hooks, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Webhook, len(hooks))
+	for i, w := range hooks {
+		out[i] = webhookView(w)
+	}
+	return out, nil
+}
+
+// Get returns a webhook by ID
+func (s *Webhooks) Get(ctx context.Context, id uint64) (*Webhook, error) {
+	w, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	view := webhookView(*w)
+	return &view, nil
+}
+
+// Create registers a webhook
+func (s *Webhooks) Create(ctx context.Context, req WebhookRequest) (*Webhook, error) {
+	w := &models.Webhook{Active: true}
+	if req.Active != nil {
+		w.Active = *req.Active
+	}
+	var err error
+	if w.URL, err = webhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	if w.Secret, err = webhookSecret(req.Secret); err != nil {
+		return nil, err
+	}
+	if w.EventTypes, err = webhookEventTypes(req.EventTypes); err != nil {
+		return nil, err
+	}
+	if w.Format, err = webhookFormat(req.Format); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+	view := webhookView(*w)
+	view.Secret = w.Secret
+	return &view, nil
+}
+
+// Update applies a partial update to a webhook
+func (s *Webhooks) Update(ctx context.Context, id uint64, patch WebhookPatch) (*Webhook, error) {
+	w, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.URL != nil {
+		if w.URL, err = webhookURL(*patch.URL); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Secret != nil {
+		if w.Secret, err = webhookSecret(*patch.Secret); err != nil {
+			return nil, err
+		}
+	}
+	if patch.EventTypes != nil {
+		if w.EventTypes, err = webhookEventTypes(*patch.EventTypes); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Format != nil {
+		if w.Format, err = webhookFormat(*patch.Format); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Active != nil {
+		w.Active = *patch.Active
+	}
+
+	if err := s.repo.Update(ctx, w); err != nil {
+		return nil, err
+	}
+	view := webhookView(*w)
+	if patch.Secret != nil {
+		view.Secret = w.Secret
+	}
+	return &view, nil
+}
+
+// Delete removes a webhook and its deliveries
+func (s *Webhooks) Delete(ctx context.Context, id uint64) error {
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deliveries lists the deliveries of a webhook, most recent first, filtered
+// by status and paged with filter.BeforeID
+func (s *Webhooks) Deliveries(ctx context.Context, filter repository.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	if _, err := s.find(ctx, filter.WebhookID); err != nil {
+		return nil, err
+	}
+	if filter.Status != "" && !slices.Contains([]string{models.DeliveryPending, models.DeliveryDelivered, models.DeliveryFailed}, filter.Status) {
+		return nil, fmt.Errorf("%w: status must be pending, delivered or failed", ErrInvalidWebhook)
+	}
+	deliveries, err := s.repo.Deliveries(ctx, filter)
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	return deliveries, err
+}
+
+// Retry schedules a failed delivery again, with a fresh set of attempts
+func (s *Webhooks) Retry(ctx context.Context, webhookID, deliveryID uint64) (*models.WebhookDelivery, error) {
+	d, err := s.repo.FindDelivery(ctx, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDeliveryNotFound
+	}
+	if d.Status != models.DeliveryFailed {
+		return nil, ErrDeliveryNotFailed
+	}
+
+	d.Status = models.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now().Unix()
+	if err := s.repo.SaveDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *Webhooks) find(ctx context.Context, id uint64) (*models.Webhook, error) {
+	w, err := s.repo.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+func webhookView(w models.Webhook) Webhook {
+	return Webhook{
+		ID:         w.ID,
+		URL:        w.URL,
+		EventTypes: w.Subscribed(),
+		Format:     w.BodyFormat(),
+		Active:     w.Active,
+		CreatedAt:  Timestamp(w.CreatedAt),
+		UpdatedAt:  Timestamp(w.UpdatedAt),
+	}
+}
+
+// webhookURL validates a callback URL: absolute http or https, with a host
+func webhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: url is required", ErrInvalidWebhook)
+	}
+	if len(raw) > maxWebhookURLLength {
+		return "", fmt.Errorf("%w: url exceeds %d characters", ErrInvalidWebhook, maxWebhookURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return "", fmt.Errorf("%w: url must be an absolute http or https URL without credentials", ErrInvalidWebhook)
+	}
+	return u.String(), nil
+}
+
+// webhookSecret validates a chosen secret or generates one
+func webhookSecret(secret string) (string, error) {
+	if secret == "" {
+		return rand.Text() + rand.Text(), nil
+	}
+	if len(secret) < minWebhookSecretLength || len(secret) > maxWebhookSecretLength {
+		return "", fmt.Errorf("%w: secret must have %d to %d characters", ErrInvalidWebhook, minWebhookSecretLength, maxWebhookSecretLength)
+	}
+	return secret, nil
+}
+
+// webhookEventTypes validates the subscribed event types and stores them
+// sorted and comma-separated; none, or all of them, is stored empty
+func webhookEventTypes(types []string) (string, error) {
+	seen := make([]string, 0, len(types))
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if !slices.Contains(models.OutboxEventTypes, t) {
+			return "", fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, t)
+		}
+		if !slices.Contains(seen, t) {
+			seen = append(seen, t)
+		}
+	}
+	if len(seen) == len(models.OutboxEventTypes) {
+		return "", nil
+	}
+	slices.Sort(seen)
+	return strings.Join(seen, ","), nil
+}
+
+// webhookFormat validates a body format; empty is JSON
+func webhookFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		return models.WebhookFormatJSON, nil
+	}
+	if !slices.Contains(models.WebhookFormats, format) {
+		return "", fmt.Errorf("%w: format must be json or protobuf", ErrInvalidWebhook)
+	}
+	return format, nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// MaxBulkItems caps the number of items accepted by a single BulkUpsert call
+const MaxBulkItems = 1000
+
+// maxClockSkew is how far in the future a created_at given by a client may be
+const maxClockSkew = time.Minute
+
+// UpsertRequest is a single segmentation write for a known user
+type UpsertRequest struct {
+	SegmentationType string          `json:"segmentation_type" validate:"required" maxLength:"50" example:"drug"`
+	SegmentationName string          `json:"segmentation_name" validate:"required" maxLength:"100" example:"Aspirina"`
+	Data             json.RawMessage `json:"data" swaggertype:"object"`
+	// CreatedAt keeps the creation time of a segmentation migrated from
+	// another system; it only applies when the write inserts the row
+	CreatedAt Timestamp `json:"created_at,omitempty" swaggertype:"string" format:"date-time"`
+}
+
+// UpsertResponse describes the outcome of a single write
+type UpsertResponse struct {
+	UserID           uint64    `json:"user_id"`
+	SegmentationType string    `json:"segmentation_type"`
+	SegmentationName string    `json:"segmentation_name"`
+	Result           string    `json:"result" enums:"inserted,updated,noop"`
+	Warnings         []Warning `json:"warnings,omitempty"`
+}
+
+// BulkItem is one entry of a bulk write
+type BulkItem struct {
+	UserID uint64 `json:"user_id" validate:"required" example:"12345"`
+	UpsertRequest
+}
+
+// BulkRequest is the payload of a bulk write
+type BulkRequest struct {
+	Items []BulkItem `json:"items" validate:"required,min=1,max=1000"`
+	// Transactional applies the items in a single transaction: an invalid
+	// or failing item rolls back the whole request (BulkRollbackError)
+	Transactional bool `json:"transactional,omitempty"`
+}
+
+// BulkItemError reports why an item of a bulk write was not applied
+type BulkItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// BulkItemWarning is a warning raised by an item of a bulk write
+type BulkItemWarning struct {
+	Index int `json:"index"`
+	Warning
+}
+
+// BulkResult summarizes a bulk write
+type BulkResult struct {
+	Inserted int               `json:"inserted"`
+	Updated  int               `json:"updated"`
+	Failed   int               `json:"failed"`
+	Errors   []BulkItemError   `json:"errors,omitempty"`
+	Warnings []BulkItemWarning `json:"warnings,omitempty"`
+}
+
+// ErrBulkRolledBack is wrapped by every BulkRollbackError
+var ErrBulkRolledBack = errors.New("bulk write rolled back")
+
+// BulkRollbackError is a transactional bulk write of which nothing was
+// applied because of the items in Errors. It wraps the error of the first
+// of them, so the kind of that error sets the response status.
+type BulkRollbackError struct {
+	Errors []BulkItemError
+	Err    error
+}
+
+func (e *BulkRollbackError) Error() string {
+	return fmt.Sprintf("%s: item %d: %s", ErrBulkRolledBack, e.Errors[0].Index, e.Err)
+}
+
+// Details lists the items that caused the rollback in the error response
+func (e *BulkRollbackError) Details() map[string]any {
+	return map[string]any{"errors": e.Errors}
+}
+
+func (e *BulkRollbackError) Unwrap() []error {
+	return []error{ErrBulkRolledBack, e.Err}
+}
+
+// ResultName returns the wire name of an UpsertResult
+func ResultName(r repository.UpsertResult) string {
+	switch r {
+	case repository.UpsertInserted:
+		return "inserted"
+	case repository.UpsertUpdated:
+		return "updated"
+	default:
+		return "noop"
+	}
+}
+
+// Prepare builds and validates the segmentation of a write without
+// applying it. The type is resolved like the other reads and writes
+// resolve it, which can read the user's stored types (see resolveType).
+func (s *SegmentationService) Prepare(
+	ctx context.Context,
+	userID uint64,
+	req UpsertRequest,
+) (*models.Segmentation, []Warning, error) {
+
+	if userID == 0 {
+		return nil, nil, fmt.Errorf("%w: user_id must be greater than zero", ErrInvalidSegmentation)
+	}
+	if err := checkUserID(strconv.FormatUint(userID, 10), userID, s.rules.MaxUserID); err != nil {
+		return nil, nil, err
+	}
+
+	segType, err := s.resolveType(req.SegmentationType, s.storedTypes(ctx, userID))
+	if err != nil {
+		return nil, nil, err
+	}
+	seg, err := newSegmentation(userID, segType, req.SegmentationName, req.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case req.CreatedAt < 0:
+		return nil, nil, fmt.Errorf("%w: created_at must be after 1970-01-01T00:00:00Z", ErrInvalidSegmentation)
+	case req.CreatedAt.Time().After(time.Now().Add(maxClockSkew)):
+		return nil, nil, fmt.Errorf("%w: created_at %s is in the future", ErrInvalidSegmentation, req.CreatedAt)
+	}
+	seg.CreatedAt = int64(req.CreatedAt)
+
+	warnings, err := s.Validate(seg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return seg, warnings, nil
+}
+
+// Upsert validates and writes a single segmentation for a user
+func (s *SegmentationService) Upsert(
+	ctx context.Context,
+	userID uint64,
+	req UpsertRequest,
+) (*UpsertResponse, repository.UpsertResult, error) {
+
+	seg, warnings, err := s.Prepare(ctx, userID, req)
+	if err != nil {
+		return nil, repository.UpsertNoOp, err
+	}
+
+	// invalidated on errors too: the write may have been applied anyway
+	result, err := s.repo.Upsert(ctx, seg)
+	s.invalidate(userID)
+	if err != nil {
+		return nil, repository.UpsertNoOp, err
+	}
+	if result != repository.UpsertNoOp {
+		s.notify(upserted(seg)...)
+	}
+
+	return &UpsertResponse{
+		UserID:           seg.UserID,
+		SegmentationType: seg.SegmentationType,
+		SegmentationName: seg.SegmentationName,
+		Result:           ResultName(result),
+		Warnings:         warnings,
+	}, result, nil
+}
+
+// BulkUpsert writes many segmentations, possibly for different users.
+// Invalid or failing items are reported individually and do not stop the
+// remaining items from being applied, unless the request is transactional.
+func (s *SegmentationService) BulkUpsert(
+	ctx context.Context,
+	req BulkRequest,
+) (*BulkResult, error) {
+
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: items must not be empty", ErrInvalidSegmentation)
+	}
+	if len(req.Items) > MaxBulkItems {
+		return nil, fmt.Errorf("%w: at most %d items per request", ErrInvalidSegmentation, MaxBulkItems)
+	}
+	if req.Transactional {
+		return s.bulkUpsertAtomic(ctx, req)
+	}
+
+	result := &BulkResult{}
+	for i, item := range req.Items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		resp, res, err := s.Upsert(ctx, item.UserID, item.UpsertRequest)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, BulkItemError{Index: i, Error: err.Error()})
+			s.logger.Debug("bulk_item_failed",
+				zap.Int("index", i),
+				zap.Uint64("user_id", item.UserID),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		for _, w := range resp.Warnings {
+			result.Warnings = append(result.Warnings, BulkItemWarning{Index: i, Warning: w})
+		}
+
+		switch res {
+		case repository.UpsertInserted:
+			result.Inserted++
+		default:
+			result.Updated++
+		}
+	}
+
+	s.logger.Info("bulk_upsert",
+		zap.Int("inserted", result.Inserted),
+		zap.Int("updated", result.Updated),
+		zap.Int("failed", result.Failed),
+		zap.Int("warnings", len(result.Warnings)),
+	)
+
+	return result, nil
+}
+
+// bulkUpsertAtomic applies a transactional bulk write: every item is
+// validated before anything is written, then all of them are written in a
+// single transaction, rolled back by the first failure
+func (s *SegmentationService) bulkUpsertAtomic(
+	ctx context.Context,
+	req BulkRequest,
+) (*BulkResult, error) {
+
+	result := &BulkResult{}
+	segs := make([]*models.Segmentation, len(req.Items))
+	var invalid []BulkItemError
+	var firstErr error
+	for i, item := range req.Items {
+		seg, warnings, err := s.Prepare(ctx, item.UserID, item.UpsertRequest)
+		if err != nil {
+			invalid = append(invalid, BulkItemError{Index: i, Error: err.Error()})
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		segs[i] = seg
+		for _, w := range warnings {
+			result.Warnings = append(result.Warnings, BulkItemWarning{Index: i, Warning: w})
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, &BulkRollbackError{Errors: invalid, Err: firstErr}
+	}
+
+	err := s.repo.Transaction(ctx, func(tx repository.SegmentationRepository) error {
+		// reset counters in case the transaction is retried
+		result.Inserted, result.Updated = 0, 0
+		for i, seg := range segs {
+			res, err := tx.Upsert(ctx, seg)
+			if err != nil {
+				return &BulkRollbackError{Errors: []BulkItemError{{Index: i, Error: err.Error()}}, Err: err}
+			}
+			if res == repository.UpsertInserted {
+				result.Inserted++
+			} else {
+				result.Updated++
+			}
+		}
+		return nil
+	})
+	// invalidated on errors too: the commit may have been applied anyway
+	for _, seg := range segs {
+		s.invalidate(seg.UserID)
+	}
+	if err != nil {
+		s.logger.Debug("bulk_rolled_back", zap.Int("items", len(segs)), zap.Error(err))
+		return nil, err
+	}
+	s.notify(upserted(segs...)...)
+
+	s.logger.Info("bulk_upsert",
+		zap.Bool("transactional", true),
+		zap.Int("inserted", result.Inserted),
+		zap.Int("updated", result.Updated),
+		zap.Int("warnings", len(result.Warnings)),
+	)
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/writequeue"
+
+	"go.uber.org/zap"
+)
+
+// Enqueuer accepts writes to be applied later, as writequeue.Queue does
+type Enqueuer interface {
+	Enqueue(items []models.Segmentation) (writequeue.Status, error)
+}
+
+// QueuedResponse answers a write accepted by the write queue. ID and State
+// are empty when no item was valid and nothing was queued.
+type QueuedResponse struct {
+	ID       string            `json:"id,omitempty"`
+	State    writequeue.State  `json:"state,omitempty"`
+	Items    int               `json:"items"`
+	Rejected int               `json:"rejected,omitempty"`
+	Errors   []BulkItemError   `json:"errors,omitempty"`
+	Warnings []BulkItemWarning `json:"warnings,omitempty"`
+}
+
+// QueueUpsert validates a single write like Upsert and hands it to q
+// instead of writing it
+func (s *SegmentationService) QueueUpsert(
+	ctx context.Context,
+	q Enqueuer,
+	userID uint64,
+	req UpsertRequest,
+) (*QueuedResponse, error) {
+
+	seg, warnings, err := s.Prepare(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := q.Enqueue([]models.Segmentation{*seg})
+	if err != nil {
+		return nil, err
+	}
+	resp := &QueuedResponse{ID: status.ID, State: status.State, Items: status.Items}
+	for _, w := range warnings {
+		resp.Warnings = append(resp.Warnings, BulkItemWarning{Index: 0, Warning: w})
+	}
+	return resp, nil
+}
+
+// QueueBulk validates a bulk write like BulkUpsert and hands its valid
+// items to q as a single entry; invalid items are reported right away
+func (s *SegmentationService) QueueBulk(
+	ctx context.Context,
+	q Enqueuer,
+	req BulkRequest,
+) (*QueuedResponse, error) {
+
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: items must not be empty", ErrInvalidSegmentation)
+	}
+	if len(req.Items) > MaxBulkItems {
+		return nil, fmt.Errorf("%w: at most %d items per request", ErrInvalidSegmentation, MaxBulkItems)
+	}
+
+	resp := &QueuedResponse{}
+	segs := make([]models.Segmentation, 0, len(req.Items))
+	for i, item := range req.Items {
+		seg, warnings, err := s.Prepare(ctx, item.UserID, item.UpsertRequest)
+		if err != nil {
+			resp.Rejected++
+			resp.Errors = append(resp.Errors, BulkItemError{Index: i, Error: err.Error()})
+			continue
+		}
+		for _, w := range warnings {
+			resp.Warnings = append(resp.Warnings, BulkItemWarning{Index: i, Warning: w})
+		}
+		segs = append(segs, *seg)
+	}
+	if len(segs) == 0 {
+		return resp, nil
+	}
+
+	status, err := q.Enqueue(segs)
+	if err != nil {
+		return nil, err
+	}
+	resp.ID, resp.State, resp.Items = status.ID, status.State, status.Items
+	return resp, nil
+}
+
+// FlushQueued writes the items of a write queue entry, the
+// writequeue.FlushFunc of the API. The entry is written in one statement;
+// when that fails for another reason than the database being unavailable,
+// the items are written one by one so the ones MySQL refuses are reported
+// and the others applied. Unavailability fails the entry, to be retried.
+func (s *SegmentationService) FlushQueued(
+	ctx context.Context,
+	items []models.Segmentation,
+) ([]writequeue.ItemError, error) {
+
+	_, err := s.CreateBatch(ctx, items)
+	if err == nil || retryLater(ctx, err) {
+		return nil, err
+	}
+	s.logger.Warn("write_queue_batch_failed", zap.Int("items", len(items)), zap.Error(err))
+
+	var failed []writequeue.ItemError
+	for i := range items {
+		if _, err := s.Create(ctx, &items[i]); err != nil {
+			if retryLater(ctx, err) {
+				return nil, err
+			}
+			failed = append(failed, writequeue.ItemError{
+				Index:            i,
+				UserID:           items[i].UserID,
+				SegmentationType: items[i].SegmentationType,
+				SegmentationName: items[i].SegmentationName,
+				Error:            err.Error(),
+			})
+		}
+	}
+	return failed, nil
+}
+
+// retryLater reports whether a write failed for lack of a database rather
+// than because of its contents
+func retryLater(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, repository.ErrUnavailable)
+}
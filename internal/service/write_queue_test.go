@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/writequeue"
+)
+
+// fakeEnqueuer records the entries handed to the queue
+type fakeEnqueuer struct {
+	entries [][]models.Segmentation
+	err     error
+}
+
+func (f *fakeEnqueuer) Enqueue(items []models.Segmentation) (writequeue.Status, error) {
+	if f.err != nil {
+		return writequeue.Status{}, f.err
+	}
+	f.entries = append(f.entries, items)
+	return writequeue.Status{ID: "w1", State: writequeue.StateQueued, Items: len(items)}, nil
+}
+
+func TestQueueBulk(t *testing.T) {
+	svc := NewSegmentationService(&memoryRepository{})
+	q := &fakeEnqueuer{}
+
+	resp, err := svc.QueueBulk(context.Background(), q, BulkRequest{Items: []BulkItem{
+		{UserID: 1, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "a", Data: json.RawMessage(`{}`)}},
+		{UserID: 0, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "b", Data: json.RawMessage(`{}`)}},
+		{UserID: 2, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "c", Data: json.RawMessage(`{}`)}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "w1" || resp.Items != 2 || resp.Rejected != 1 || resp.Errors[0].Index != 1 {
+		t.Errorf("QueueBulk() = %+v", resp)
+	}
+	if len(q.entries) != 1 || len(q.entries[0]) != 2 || q.entries[0][1].SegmentationName != "c" {
+		t.Errorf("entries = %+v", q.entries)
+	}
+
+	// nothing valid: nothing queued
+	resp, err = svc.QueueBulk(context.Background(), q, BulkRequest{Items: []BulkItem{{UserID: 0}}})
+	if err != nil || resp.ID != "" || resp.Rejected != 1 || len(q.entries) != 1 {
+		t.Errorf("QueueBulk() = %+v, %v", resp, err)
+	}
+
+	q.err = writequeue.ErrFull
+	if _, err := svc.QueueUpsert(context.Background(), q, 1, UpsertRequest{SegmentationType: "drug", SegmentationName: "d", Data: json.RawMessage(`{}`)}); !errors.Is(err, writequeue.ErrFull) {
+		t.Errorf("QueueUpsert() error = %v, want ErrFull", err)
+	}
+}
+
+func TestFlushQueued(t *testing.T) {
+	items := []models.Segmentation{
+		{UserID: 1, SegmentationType: "drug", SegmentationName: "a"},
+		{UserID: 1, SegmentationType: "drug", SegmentationName: "b"},
+		{UserID: 1, SegmentationType: "drug", SegmentationName: "c"},
+	}
+
+	// the failed batch is written item by item, reporting the refused one
+	repo := &memoryRepository{failOn: "b"}
+	failed, err := NewSegmentationService(repo).FlushQueued(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].Index != 1 || failed[0].SegmentationName != "b" || len(repo.rows) != 2 {
+		t.Errorf("FlushQueued() = %+v, rows %d", failed, len(repo.rows))
+	}
+
+	// an unavailable database fails the entry, to be retried
+	down := &MockRepository{upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+		return repository.UpsertNoOp, &repository.UnavailableError{}
+	}}
+	if _, err := NewSegmentationService(down).FlushQueued(context.Background(), items); !errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("FlushQueued() error = %v, want ErrUnavailable", err)
+	}
+}
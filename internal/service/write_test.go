@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+func TestUpsert_Success(t *testing.T) {
+	var stored *models.Segmentation
+	svc := NewSegmentationService(&MockRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			stored = s
+			return repository.UpsertInserted, nil
+		},
+	})
+
+	resp, result, err := svc.Upsert(context.Background(), 5, UpsertRequest{
+		SegmentationType: "drugs",
+		SegmentationName: " Aspirina ",
+		Data:             json.RawMessage(`{"dose": "500mg"}`),
+	})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if result != repository.UpsertInserted || resp.Result != "inserted" {
+		t.Errorf("unexpected result %v / %s", result, resp.Result)
+	}
+	if stored.SegmentationType != "drug" || stored.SegmentationName != "Aspirina" {
+		t.Errorf("segmentation should be normalized before storage, got %+v", stored)
+	}
+}
+
+func TestUpsert_Validation(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{})
+
+	_, _, err := svc.Upsert(context.Background(), 0, UpsertRequest{SegmentationType: "drug", SegmentationName: "x"})
+	if !errors.Is(err, ErrInvalidSegmentation) {
+		t.Errorf("zero user id: expected ErrInvalidSegmentation, got %v", err)
+	}
+
+	_, _, err = svc.Upsert(context.Background(), 1, UpsertRequest{SegmentationType: "", SegmentationName: "x"})
+	if !errors.Is(err, ErrInvalidSegmentation) {
+		t.Errorf("empty type: expected ErrInvalidSegmentation, got %v", err)
+	}
+}
+
+func TestPrepare_CreatedAt(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{})
+
+	var req UpsertRequest
+	body := `{"segmentation_type": "drug", "segmentation_name": "Aspirina", "created_at": "2025-12-31T21:00:00-03:00"}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	seg, _, err := svc.Prepare(context.Background(), 1, req)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if seg.CreatedAt != 1767225600 {
+		t.Errorf("CreatedAt = %d, want 1767225600", seg.CreatedAt)
+	}
+
+	req.CreatedAt = Timestamp(time.Now().Add(time.Hour).Unix())
+	if _, _, err := svc.Prepare(context.Background(), 1, req); !errors.Is(err, ErrInvalidSegmentation) {
+		t.Errorf("future created_at: expected ErrInvalidSegmentation, got %v", err)
+	}
+}
+
+func TestBulkUpsert_ReportsItemErrors(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{
+		upsertFunc: func(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+			if s.SegmentationName == "boom" {
+				return repository.UpsertNoOp, errors.New("db error")
+			}
+			if s.UserID == 2 {
+				return repository.UpsertUpdated, nil
+			}
+			return repository.UpsertInserted, nil
+		},
+	})
+
+	req := BulkRequest{Items: []BulkItem{
+		{UserID: 1, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "a"}},
+		{UserID: 2, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "b"}},
+		{UserID: 3, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: ""}},
+		{UserID: 4, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "boom"}},
+	}}
+
+	result, err := svc.BulkUpsert(context.Background(), req)
+	if err != nil {
+		t.Fatalf("BulkUpsert() error = %v", err)
+	}
+	if result.Inserted != 1 || result.Updated != 1 || result.Failed != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Index != 2 || result.Errors[1].Index != 3 {
+		t.Errorf("unexpected item errors: %+v", result.Errors)
+	}
+}
+
+func TestBulkUpsert_Limits(t *testing.T) {
+	svc := NewSegmentationService(&MockRepository{})
+
+	if _, err := svc.BulkUpsert(context.Background(), BulkRequest{}); !errors.Is(err, ErrInvalidSegmentation) {
+		t.Errorf("empty batch: expected ErrInvalidSegmentation, got %v", err)
+	}
+
+	big := BulkRequest{Items: make([]BulkItem, MaxBulkItems+1)}
+	if _, err := svc.BulkUpsert(context.Background(), big); !errors.Is(err, ErrInvalidSegmentation) {
+		t.Errorf("oversized batch: expected ErrInvalidSegmentation, got %v", err)
+	}
+}
+
+func TestBulkUpsert_TransactionalRejectsInvalidItems(t *testing.T) {
+	repo := seededMemoryRepository()
+	svc := NewSegmentationService(repo)
+
+	req := BulkRequest{Transactional: true, Items: []BulkItem{
+		{UserID: 1, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "a"}},
+		{UserID: 2, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: ""}},
+	}}
+
+	_, err := svc.BulkUpsert(context.Background(), req)
+	var rollback *BulkRollbackError
+	if !errors.As(err, &rollback) || !errors.Is(err, ErrInvalidSegmentation) {
+		t.Fatalf("expected BulkRollbackError wrapping ErrInvalidSegmentation, got %v", err)
+	}
+	if len(rollback.Errors) != 1 || rollback.Errors[0].Index != 1 {
+		t.Errorf("unexpected item errors: %+v", rollback.Errors)
+	}
+	if repo.txStarted != 0 || len(repo.rows) != 3 {
+		t.Errorf("nothing should be written: %d transactions, %d rows", repo.txStarted, len(repo.rows))
+	}
+}
+
+func TestBulkUpsert_TransactionalRollsBack(t *testing.T) {
+	repo := seededMemoryRepository()
+	repo.failOn = "boom"
+	svc := NewSegmentationService(repo)
+
+	req := BulkRequest{Transactional: true, Items: []BulkItem{
+		{UserID: 1, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "a"}},
+		{UserID: 2, UpsertRequest: UpsertRequest{SegmentationType: "drug", SegmentationName: "boom"}},
+	}}
+
+	_, err := svc.BulkUpsert(context.Background(), req)
+	var rollback *BulkRollbackError
+	if !errors.As(err, &rollback) || !errors.Is(err, ErrBulkRolledBack) {
+		t.Fatalf("expected BulkRollbackError, got %v", err)
+	}
+	if rollback.Errors[0].Index != 1 {
+		t.Errorf("unexpected item errors: %+v", rollback.Errors)
+	}
+	if len(repo.rows) != 3 {
+		t.Errorf("expected the write of item 0 to be rolled back, got %d rows", len(repo.rows))
+	}
+
+	repo.failOn = ""
+	result, err := svc.BulkUpsert(context.Background(), req)
+	if err != nil {
+		t.Fatalf("BulkUpsert() error = %v", err)
+	}
+	if result.Inserted != 2 || len(repo.rows) != 5 {
+		t.Errorf("unexpected result: %+v, %d rows", result, len(repo.rows))
+	}
+}
@@ -0,0 +1,595 @@
+// Package sftp polls a directory of an SFTP server for the files the data
+// vendors drop there: each new file matching a pattern is downloaded,
+// imported by the processor and moved to an archive directory on the
+// server. The runs table tells which files were already imported, so a
+// file whose archiving failed is not imported twice.
+//
+// The client speaks version 3 of the SFTP protocol, the one OpenSSH and
+// most servers implement, over the "sftp" subsystem of an SSH session,
+// and only implements the requests the poller needs.
+package sftp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// packet types of SFTP version 3
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpMkdir    = 14
+	fxpStat     = 17
+	fxpRename   = 18
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+	sftpVersion = 3
+)
+
+// status codes
+const (
+	fxOK            = 0
+	fxEOF           = 1
+	fxNoSuchFile    = 2
+	fxPermission    = 3
+	fxFailure       = 4
+	fxOpUnsupported = 8
+)
+
+// attribute flags
+const (
+	attrSize        = 0x1
+	attrUIDGID      = 0x2
+	attrPermissions = 0x4
+	attrACModTime   = 0x8
+	attrExtended    = 0x80000000
+)
+
+const (
+	// readSize is the length asked by each READ; servers answer at most
+	// 32KiB to 64KiB
+	readSize = 32 << 10
+	// maxReads is how many READs of a download are in flight at once
+	maxReads = 16
+	// maxPacket bounds a packet read from the server
+	maxPacket = 256 << 10
+
+	modeDir = 0o040000
+	modeFmt = 0o170000
+	// openRead is SSH_FXF_READ
+	openRead = 0x1
+)
+
+// StatusError is a request refused by the server. It matches
+// fs.ErrNotExist and fs.ErrPermission with errors.Is.
+type StatusError struct {
+	Code uint32
+	Msg  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sftp: %s (status %d)", e.Msg, e.Code)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.Code == fxNoSuchFile
+	case fs.ErrPermission:
+		return e.Code == fxPermission
+	}
+	return false
+}
+
+// ErrClosed is returned by the requests of a closed client, or of one
+// whose connection failed
+var ErrClosed = errors.New("sftp: connection closed")
+
+// File describes a remote file
+type File struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// packet is a response of the server, without its length, type and id
+type packet struct {
+	kind byte
+	data []byte
+}
+
+// Client is an SFTP session. Requests may be made concurrently: they are
+// matched to their responses by id.
+type Client struct {
+	w      io.Writer
+	closer io.Closer
+
+	wmu sync.Mutex // serializes the writes of requests
+
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan packet
+	err     error // why the session ended
+}
+
+// newClient starts a session over r and w, the output and input of the
+// "sftp" subsystem; closer, when not nil, is closed by Close and when the
+// connection fails
+func newClient(r io.Reader, w io.Writer, closer io.Closer) (*Client, error) {
+	c := &Client{w: w, closer: closer, pending: map[uint32]chan packet{}}
+	br := bufio.NewReaderSize(r, 64<<10)
+
+	var init []byte
+	init = append(init, fxpInit)
+	init = binary.BigEndian.AppendUint32(init, sftpVersion)
+	if err := c.writePacket(init); err != nil {
+		return nil, err
+	}
+	kind, data, err := readPacket(br)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: handshake: %w", err)
+	}
+	if kind != fxpVersion || len(data) < 4 {
+		return nil, fmt.Errorf("sftp: handshake: unexpected packet %d", kind)
+	}
+	if v := binary.BigEndian.Uint32(data); v < sftpVersion {
+		return nil, fmt.Errorf("sftp: server speaks version %d, want %d", v, sftpVersion)
+	}
+	go c.receive(br)
+	return c, nil
+}
+
+// Close ends the session
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	if c.closer != nil {
+		return c.closer.Close()
+	}
+	return nil
+}
+
+// receive delivers the responses to the requests waiting for them until
+// the connection fails
+func (c *Client) receive(r *bufio.Reader) {
+	for {
+		kind, data, err := readPacket(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = ErrClosed
+			}
+			c.fail(err)
+			return
+		}
+		if len(data) < 4 {
+			c.fail(errors.New("sftp: short packet"))
+			return
+		}
+		id := binary.BigEndian.Uint32(data)
+		c.mu.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- packet{kind: kind, data: data[4:]}
+		}
+	}
+}
+
+// fail ends the requests still waiting with err
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	pending := c.pending
+	c.pending = map[uint32]chan packet{}
+	c.mu.Unlock()
+	for _, ch := range pending {
+		close(ch)
+	}
+	if c.closer != nil && err != ErrClosed {
+		c.closer.Close()
+	}
+}
+
+// send writes a request and returns the channel of its response, closed
+// if the session ends first
+func (c *Client) send(kind byte, payload []byte) (chan packet, error) {
+	ch := make(chan packet, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	buf := make([]byte, 0, 5+len(payload))
+	buf = append(buf, kind)
+	buf = binary.BigEndian.AppendUint32(buf, id)
+	buf = append(buf, payload...)
+	if err := c.writePacket(buf); err != nil {
+		c.fail(err)
+		return nil, err
+	}
+	return ch, nil
+}
+
+// wait returns the response of a request sent by send
+func (c *Client) wait(ch chan packet) (packet, error) {
+	p, ok := <-ch
+	if !ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return packet{}, c.err
+	}
+	return p, nil
+}
+
+// request sends a request and waits for its response
+func (c *Client) request(kind byte, payload []byte) (packet, error) {
+	ch, err := c.send(kind, payload)
+	if err != nil {
+		return packet{}, err
+	}
+	return c.wait(ch)
+}
+
+func (c *Client) writePacket(b []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(b)))
+	if _, err := c.w.Write(append(length[:], b...)); err != nil {
+		return fmt.Errorf("sftp: %w", err)
+	}
+	return nil
+}
+
+func readPacket(r io.Reader) (byte, []byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 || n > maxPacket {
+		return 0, nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, nil, err
+	}
+	return buf[0], buf[1:], nil
+}
+
+// ─────────────────────────────────────────────
+// Requests
+// ─────────────────────────────────────────────
+
+// Stat describes the file at path, following links
+func (c *Client) Stat(path string) (File, error) {
+	p, err := c.request(fxpStat, appendString(nil, path))
+	if err != nil {
+		return File{}, err
+	}
+	if p.kind != fxpAttrs {
+		return File{}, statusOf(p)
+	}
+	f, _, err := parseAttrs(p.data)
+	f.Name = baseName(path)
+	return f, err
+}
+
+// ReadDir lists the directory at path, without "." and ".."
+func (c *Client) ReadDir(path string) ([]File, error) {
+	handle, err := c.handle(fxpOpendir, appendString(nil, path))
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(handle)
+
+	var files []File
+	for {
+		p, err := c.request(fxpReaddir, appendString(nil, handle))
+		if err != nil {
+			return nil, err
+		}
+		if p.kind != fxpName {
+			if err := statusOf(p); !isEOF(err) {
+				return nil, err
+			}
+			return files, nil
+		}
+		entries, err := parseNames(p.data)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range entries {
+			if f.Name != "." && f.Name != ".." {
+				files = append(files, f)
+			}
+		}
+	}
+}
+
+// Mkdir creates the directory at path
+func (c *Client) Mkdir(path string) error {
+	payload := appendString(nil, path)
+	payload = binary.BigEndian.AppendUint32(payload, 0) // no attributes
+	p, err := c.request(fxpMkdir, payload)
+	if err != nil {
+		return err
+	}
+	return statusOf(p)
+}
+
+// Rename moves the file at from to to, which must not exist
+func (c *Client) Rename(from, to string) error {
+	p, err := c.request(fxpRename, appendString(appendString(nil, from), to))
+	if err != nil {
+		return err
+	}
+	return statusOf(p)
+}
+
+// Download copies the file at path to dst and returns its length. Up to
+// maxReads reads are in flight, each written at its offset as it arrives.
+func (c *Client) Download(path string, dst io.WriterAt) (int64, error) {
+	payload := appendString(nil, path)
+	payload = binary.BigEndian.AppendUint32(payload, openRead)
+	payload = binary.BigEndian.AppendUint32(payload, 0) // no attributes
+	handle, err := c.handle(fxpOpen, payload)
+	if err != nil {
+		return 0, err
+	}
+	defer c.closeHandle(handle)
+
+	type read struct {
+		offset int64
+		length uint32
+		ch     chan packet
+	}
+	var (
+		inflight []read
+		next     int64 // offset of the next read
+		size     int64 // end of the data received
+		eof      bool  // a read reached the end of the file
+		firstErr error
+	)
+	issue := func(offset int64, length uint32) {
+		req := appendString(nil, handle)
+		req = binary.BigEndian.AppendUint64(req, uint64(offset))
+		req = binary.BigEndian.AppendUint32(req, length)
+		ch, err := c.send(fxpRead, req)
+		if err != nil {
+			firstErr = err
+			return
+		}
+		inflight = append(inflight, read{offset, length, ch})
+	}
+
+	for {
+		for firstErr == nil && !eof && len(inflight) < maxReads {
+			issue(next, readSize)
+			next += readSize
+		}
+		if len(inflight) == 0 {
+			break
+		}
+		r := inflight[0]
+		inflight = inflight[1:]
+		p, err := c.wait(r.ch)
+		switch {
+		case err != nil:
+			firstErr = err
+			continue
+		case firstErr != nil:
+			// the remaining reads are only drained
+			continue
+		case p.kind != fxpData:
+			if err := statusOf(p); !isEOF(err) {
+				firstErr = err
+			}
+			eof = true
+			continue
+		}
+		data, _, err := readString(p.data)
+		if err != nil {
+			firstErr = err
+			continue
+		}
+		if _, err := dst.WriteAt(data, r.offset); err != nil {
+			firstErr = err
+			continue
+		}
+		size = max(size, r.offset+int64(len(data)))
+		if n := uint32(len(data)); n < r.length && firstErr == nil {
+			// a short read: the rest of the range is asked again
+			issue(r.offset+int64(n), r.length-n)
+		}
+	}
+	return size, firstErr
+}
+
+// handle sends a request answered with a handle
+func (c *Client) handle(kind byte, payload []byte) (string, error) {
+	p, err := c.request(kind, payload)
+	if err != nil {
+		return "", err
+	}
+	if p.kind != fxpHandle {
+		return "", statusOf(p)
+	}
+	h, _, err := readString(p.data)
+	return string(h), err
+}
+
+func (c *Client) closeHandle(handle string) {
+	c.request(fxpClose, appendString(nil, handle))
+}
+
+// ─────────────────────────────────────────────
+// Encoding
+// ─────────────────────────────────────────────
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+var errMalformed = errors.New("sftp: malformed packet")
+
+func readUint32(b []byte) (uint32, []byte, error) {
+	if len(b) < 4 {
+		return 0, nil, errMalformed
+	}
+	return binary.BigEndian.Uint32(b), b[4:], nil
+}
+
+func readString(b []byte) ([]byte, []byte, error) {
+	n, b, err := readUint32(b)
+	if err != nil || uint32(len(b)) < n {
+		return nil, nil, errMalformed
+	}
+	return b[:n], b[n:], nil
+}
+
+// statusOf turns a STATUS response into its error, nil for SSH_FX_OK
+func statusOf(p packet) error {
+	if p.kind != fxpStatus {
+		return fmt.Errorf("sftp: unexpected packet %d", p.kind)
+	}
+	code, rest, err := readUint32(p.data)
+	if err != nil {
+		return err
+	}
+	if code == fxOK {
+		return nil
+	}
+	msg, _, err := readString(rest)
+	if err != nil || len(msg) == 0 {
+		msg = []byte(statusText(code))
+	}
+	return &StatusError{Code: code, Msg: string(msg)}
+}
+
+func statusText(code uint32) string {
+	switch code {
+	case fxEOF:
+		return "end of file"
+	case fxNoSuchFile:
+		return "no such file"
+	case fxPermission:
+		return "permission denied"
+	case fxOpUnsupported:
+		return "operation unsupported"
+	}
+	return "failure"
+}
+
+func isEOF(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == fxEOF
+}
+
+// parseAttrs reads the ATTRS structure at the start of b
+func parseAttrs(b []byte) (File, []byte, error) {
+	var f File
+	flags, b, err := readUint32(b)
+	if err != nil {
+		return f, nil, err
+	}
+	if flags&attrSize != 0 {
+		if len(b) < 8 {
+			return f, nil, errMalformed
+		}
+		f.Size = int64(binary.BigEndian.Uint64(b))
+		b = b[8:]
+	}
+	if flags&attrUIDGID != 0 {
+		if len(b) < 8 {
+			return f, nil, errMalformed
+		}
+		b = b[8:]
+	}
+	if flags&attrPermissions != 0 {
+		var mode uint32
+		if mode, b, err = readUint32(b); err != nil {
+			return f, nil, err
+		}
+		f.IsDir = mode&modeFmt == modeDir
+	}
+	if flags&attrACModTime != 0 {
+		if len(b) < 8 {
+			return f, nil, errMalformed
+		}
+		f.ModTime = time.Unix(int64(binary.BigEndian.Uint32(b[4:])), 0)
+		b = b[8:]
+	}
+	if flags&attrExtended != 0 {
+		var count uint32
+		if count, b, err = readUint32(b); err != nil {
+			return f, nil, err
+		}
+		for range 2 * count {
+			if _, b, err = readString(b); err != nil {
+				return f, nil, err
+			}
+		}
+	}
+	return f, b, nil
+}
+
+// parseNames reads the entries of a NAME response
+func parseNames(b []byte) ([]File, error) {
+	count, b, err := readUint32(b)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, count)
+	for range count {
+		var name []byte
+		if name, b, err = readString(b); err != nil {
+			return nil, err
+		}
+		// the long name, as ls -l prints it
+		if _, b, err = readString(b); err != nil {
+			return nil, err
+		}
+		var f File
+		if f, b, err = parseAttrs(b); err != nil {
+			return nil, err
+		}
+		f.Name = string(name)
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func baseName(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
@@ -0,0 +1,268 @@
+package sftp
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// DefaultMaxAttempts is how many failed imports of a file are retried
+const DefaultMaxAttempts = 3
+
+// Source is a directory of an SFTP server polled for files to import
+type Source struct {
+	Config
+	// Pattern selects the files, as in /inbox/*.csv: its directory is
+	// listed and the names matched with path.Match
+	Pattern string
+	// ArchiveDir receives the imported files, renamed
+	// <time>_<name>; it is created when missing
+	ArchiveDir string
+	// FailedDir receives the files whose import failed MaxAttempts times;
+	// when empty they are left in place and skipped
+	FailedDir string
+	// MinAge skips the files modified more recently, which may still be
+	// being uploaded
+	MinAge time.Duration
+	// MaxAttempts is how many failed imports of a file are made;
+	// DefaultMaxAttempts when not positive
+	MaxAttempts int
+	// DownloadDir holds the local copies of the files while they are
+	// imported; os.TempDir() when empty
+	DownloadDir string
+}
+
+// Name identifies the source, as in sftp://host:22/inbox/*.csv
+func (s Source) Name() string {
+	return "sftp://" + s.Addr() + s.Pattern
+}
+
+// ImportFunc imports the local copy of a remote file. source must be
+// recorded as the models.Run.Source of the import, which is how the
+// poller finds the runs of the file.
+type ImportFunc func(ctx context.Context, file, source string) error
+
+// Poller imports the new files of a Source
+type Poller struct {
+	src    Source
+	runs   repository.RunRepository
+	logger *zap.Logger
+	dial   func(ctx context.Context, cfg Config) (*Client, error)
+	now    func() time.Time
+}
+
+// Option customizes a Poller
+type Option func(*Poller)
+
+// WithLogger sets the logger of the polls and of each file
+func WithLogger(logger *zap.Logger) Option {
+	return func(p *Poller) {
+		p.logger = logger
+	}
+}
+
+// NewPoller creates a poller of src; runs holds the imports of each file
+func NewPoller(src Source, runs repository.RunRepository, opts ...Option) *Poller {
+	if src.MaxAttempts <= 0 {
+		src.MaxAttempts = DefaultMaxAttempts
+	}
+	p := &Poller{src: src, runs: runs, logger: zap.NewNop(), dial: Dial, now: time.Now}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Run polls every interval until ctx is cancelled, calling beat before
+// each poll when not nil. Failures are logged and the next poll retries.
+func (p *Poller) Run(ctx context.Context, interval time.Duration, fn ImportFunc, beat func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if beat != nil {
+			beat()
+		}
+		if n, err := p.Poll(ctx, fn); err != nil && ctx.Err() == nil {
+			p.logger.Error("sftp_poll_error", zap.Int("imported", n), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll imports the new files of the source one by one, oldest first, and
+// archives them. A file already imported by a succeeded run is only
+// archived; one whose import failed is retried by the next polls, up to
+// MaxAttempts runs. Poll returns the number of files imported and the
+// errors of those that failed; a cancelled import stops the poll and
+// leaves the file in place.
+func (p *Poller) Poll(ctx context.Context, fn ImportFunc) (int, error) {
+	client, err := p.dial(ctx, p.src.Config)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	dir, pattern := path.Split(p.src.Pattern)
+	entries, err := client.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("sftp: list %s: %w", dir, err)
+	}
+	cutoff := p.now().Add(-p.src.MinAge)
+	var files []File
+	for _, f := range entries {
+		if ok, _ := path.Match(pattern, f.Name); ok && !f.IsDir && !f.ModTime.After(cutoff) {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return 0, nil
+	}
+	slices.SortFunc(files, func(a, b File) int {
+		if c := a.ModTime.Compare(b.ModTime); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	if err := ensureDir(client, p.src.ArchiveDir); err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	var errs []error
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := p.importFile(ctx, client, dir, f, fn)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Name, err))
+		}
+		if ok {
+			imported++
+		}
+	}
+	return imported, errors.Join(errs...)
+}
+
+// importFile imports and archives one file, reporting whether it was
+// imported
+func (p *Poller) importFile(ctx context.Context, client *Client, dir string, f File, fn ImportFunc) (bool, error) {
+	remote := path.Join(dir, f.Name)
+	source := p.source(remote, f)
+	logger := p.logger.With(zap.String("file", remote), zap.String("source", source))
+
+	runs, err := p.runs.SourceRuns(ctx, source)
+	if err != nil {
+		return false, err
+	}
+	failed := 0
+	for _, r := range runs {
+		switch r.Status {
+		case models.RunSucceeded:
+			// imported by an earlier poll that could not archive it
+			logger.Info("sftp_file_already_imported", zap.String("run_id", r.ID))
+			return false, p.archive(client, remote, p.src.ArchiveDir, logger)
+		case models.RunFailed:
+			failed++
+		}
+	}
+	if failed >= p.src.MaxAttempts {
+		return false, p.giveUp(client, remote, failed, logger)
+	}
+
+	local, err := p.download(client, remote)
+	if err != nil {
+		return false, err
+	}
+	logger.Info("sftp_file_downloaded", zap.Int64("bytes", f.Size))
+	err = fn(ctx, local, source)
+	os.Remove(local)
+	switch {
+	case ctx.Err() != nil:
+		return false, nil
+	case err != nil:
+		logger.Error("sftp_import_failed", zap.Int("attempt", failed+1), zap.Error(err))
+		if failed+1 >= p.src.MaxAttempts {
+			if gerr := p.giveUp(client, remote, failed+1, logger); gerr != nil {
+				err = errors.Join(err, gerr)
+			}
+		}
+		return false, err
+	}
+	return true, p.archive(client, remote, p.src.ArchiveDir, logger)
+}
+
+// source is the models.Run.Source of a file. The modification time tells
+// apart the files a vendor uploads under the same name every day.
+func (p *Poller) source(remote string, f File) string {
+	return fmt.Sprintf("sftp://%s%s?mtime=%d", p.src.Addr(), remote, f.ModTime.Unix())
+}
+
+// download copies the remote file to a local temporary file
+func (p *Poller) download(client *Client, remote string) (string, error) {
+	tmp, err := os.CreateTemp(p.src.DownloadDir, "sftp-*-"+path.Base(remote))
+	if err != nil {
+		return "", err
+	}
+	_, err = client.Download(remote, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("sftp: download %s: %w", remote, err)
+	}
+	return tmp.Name(), nil
+}
+
+// archive moves the file to dir, prefixing its name with the time
+func (p *Poller) archive(client *Client, remote, dir string, logger *zap.Logger) error {
+	to := path.Join(dir, p.now().UTC().Format("20060102T150405Z")+"_"+path.Base(remote))
+	if err := client.Rename(remote, to); err != nil {
+		logger.Error("sftp_archive_failed", zap.String("to", to), zap.Error(err))
+		return fmt.Errorf("sftp: archive to %s: %w", to, err)
+	}
+	logger.Info("sftp_file_archived", zap.String("to", to))
+	return nil
+}
+
+// giveUp moves a file that failed every attempt to FailedDir, or leaves it
+// in place without one
+func (p *Poller) giveUp(client *Client, remote string, failed int, logger *zap.Logger) error {
+	if p.src.FailedDir == "" {
+		logger.Warn("sftp_file_skipped", zap.Int("failed_runs", failed))
+		return nil
+	}
+	if err := ensureDir(client, p.src.FailedDir); err != nil {
+		return err
+	}
+	logger.Warn("sftp_file_failed", zap.Int("failed_runs", failed))
+	return p.archive(client, remote, p.src.FailedDir, logger)
+}
+
+// ensureDir creates dir when it does not exist; its parent must
+func ensureDir(client *Client, dir string) error {
+	_, err := client.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = client.Mkdir(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("sftp: directory %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,532 @@
+package sftp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// fakeServer serves the SFTP requests of the client on a local directory.
+// Reads answer at most maxRead bytes, to exercise short reads.
+type fakeServer struct {
+	root    string
+	maxRead int
+
+	mu      sync.Mutex
+	handles map[string]any // *os.File or []os.DirEntry still to list
+	next    int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	return &fakeServer{root: t.TempDir(), maxRead: 10000, handles: map[string]any{}}
+}
+
+// client starts a session with the server over pipes
+func (s *fakeServer) client(t *testing.T) *Client {
+	t.Helper()
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	go s.serve(sr, sw)
+	c, err := newClient(cr, cw, cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (s *fakeServer) local(p string) string {
+	return filepath.Join(s.root, filepath.FromSlash(p))
+}
+
+func (s *fakeServer) write(t *testing.T, p, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(s.local(p)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.local(p), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(s.local(p), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ls lists the names in a directory of the server
+func (s *fakeServer) ls(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(s.local(dir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func (s *fakeServer) serve(r io.Reader, w io.WriteCloser) {
+	defer w.Close()
+	br := bufio.NewReader(r)
+	for {
+		kind, data, err := readPacket(br)
+		if err != nil {
+			return
+		}
+		if kind == fxpInit {
+			w.Write(frame(fxpVersion, binary.BigEndian.AppendUint32(nil, sftpVersion)))
+			continue
+		}
+		id, data, _ := readUint32(data)
+		reply, payload := s.handle(kind, data)
+		w.Write(frame(reply, append(binary.BigEndian.AppendUint32(nil, id), payload...)))
+	}
+}
+
+func frame(kind byte, payload []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)))
+	return append(append(b, kind), payload...)
+}
+
+func status(code uint32) (byte, []byte) {
+	b := binary.BigEndian.AppendUint32(nil, code)
+	b = appendString(b, statusText(code))
+	return fxpStatus, appendString(b, "")
+}
+
+func statusFor(err error) (byte, []byte) {
+	switch {
+	case err == nil:
+		return status(fxOK)
+	case errors.Is(err, fs.ErrNotExist):
+		return status(fxNoSuchFile)
+	}
+	return status(fxFailure)
+}
+
+func attrs(info fs.FileInfo) []byte {
+	b := binary.BigEndian.AppendUint32(nil, attrSize|attrPermissions|attrACModTime)
+	b = binary.BigEndian.AppendUint64(b, uint64(info.Size()))
+	mode := uint32(0o100644)
+	if info.IsDir() {
+		mode = 0o040755
+	}
+	b = binary.BigEndian.AppendUint32(b, mode)
+	b = binary.BigEndian.AppendUint32(b, uint32(info.ModTime().Unix()))
+	return binary.BigEndian.AppendUint32(b, uint32(info.ModTime().Unix()))
+}
+
+func (s *fakeServer) handle(kind byte, data []byte) (byte, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	arg, rest, _ := readString(data)
+	switch kind {
+	case fxpStat:
+		info, err := os.Stat(s.local(string(arg)))
+		if err != nil {
+			return statusFor(err)
+		}
+		return fxpAttrs, attrs(info)
+	case fxpMkdir:
+		return statusFor(os.Mkdir(s.local(string(arg)), 0o755))
+	case fxpRename:
+		to, _, _ := readString(rest)
+		if _, err := os.Stat(s.local(string(to))); err == nil {
+			return status(fxFailure)
+		}
+		return statusFor(os.Rename(s.local(string(arg)), s.local(string(to))))
+	case fxpOpendir:
+		entries, err := os.ReadDir(s.local(string(arg)))
+		if err != nil {
+			return statusFor(err)
+		}
+		return fxpHandle, appendString(nil, s.open(entries))
+	case fxpOpen:
+		f, err := os.Open(s.local(string(arg)))
+		if err != nil {
+			return statusFor(err)
+		}
+		return fxpHandle, appendString(nil, s.open(f))
+	case fxpReaddir:
+		entries, ok := s.handles[string(arg)].([]os.DirEntry)
+		if !ok {
+			return status(fxFailure)
+		}
+		if len(entries) == 0 {
+			return status(fxEOF)
+		}
+		// two entries per response, "." first
+		b := binary.BigEndian.AppendUint32(nil, uint32(min(2, len(entries))+1))
+		b = appendString(appendString(b, "."), ".")
+		b = binary.BigEndian.AppendUint32(b, 0)
+		for _, e := range entries[:min(2, len(entries))] {
+			info, _ := e.Info()
+			b = appendString(appendString(b, e.Name()), e.Name())
+			b = append(b, attrs(info)...)
+		}
+		s.handles[string(arg)] = entries[min(2, len(entries)):]
+		return fxpName, b
+	case fxpRead:
+		f, ok := s.handles[string(arg)].(*os.File)
+		if !ok {
+			return status(fxFailure)
+		}
+		offset := binary.BigEndian.Uint64(rest)
+		length := binary.BigEndian.Uint32(rest[8:])
+		buf := make([]byte, min(int(length), s.maxRead))
+		n, err := f.ReadAt(buf, int64(offset))
+		if n == 0 && err == io.EOF {
+			return status(fxEOF)
+		}
+		return fxpData, appendString(nil, string(buf[:n]))
+	case fxpClose:
+		if f, ok := s.handles[string(arg)].(*os.File); ok {
+			f.Close()
+		}
+		delete(s.handles, string(arg))
+		return status(fxOK)
+	}
+	return status(fxOpUnsupported)
+}
+
+func (s *fakeServer) open(v any) string {
+	s.next++
+	h := string(rune('a' + s.next))
+	s.handles[h] = v
+	return h
+}
+
+func TestClient(t *testing.T) {
+	srv := newFakeServer(t)
+	modTime := time.Unix(1760000000, 0)
+	content := strings.Repeat("0123456789", 20000) // 200KB, many reads
+	srv.write(t, "/inbox/big.csv", content, modTime)
+	for _, name := range []string{"a.csv", "b.csv", "c.txt"} {
+		srv.write(t, "/inbox/"+name, name, modTime)
+	}
+	c := srv.client(t)
+
+	files, err := c.ReadDir("/inbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("ReadDir() = %+v, want 4 files without . and ..", files)
+	}
+	f, err := c.Stat("/inbox/big.csv")
+	if err != nil || f.Size != int64(len(content)) || !f.ModTime.Equal(modTime) || f.IsDir || f.Name != "big.csv" {
+		t.Errorf("Stat() = %+v, %v", f, err)
+	}
+	if _, err := c.Stat("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() of a missing file = %v, want fs.ErrNotExist", err)
+	}
+
+	// the server answers short reads: the rest of each range is asked again
+	dst := filepath.Join(t.TempDir(), "big.csv")
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := c.Download("/inbox/big.csv", out)
+	out.Close()
+	if err != nil || n != int64(len(content)) {
+		t.Fatalf("Download() = %d, %v", n, err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, []byte(content)) {
+		t.Error("downloaded content differs")
+	}
+
+	if err := c.Mkdir("/archive"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Rename("/inbox/a.csv", "/archive/a.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.ls(t, "/archive"); len(got) != 1 || got[0] != "a.csv" {
+		t.Errorf("archive = %v", got)
+	}
+
+	c.Close()
+	if _, err := c.Stat("/inbox"); !errors.Is(err, ErrClosed) {
+		t.Errorf("requests after Close() = %v, want ErrClosed", err)
+	}
+}
+
+// memoryRuns keeps the runs of each source
+type memoryRuns struct {
+	mu       sync.Mutex
+	bySource map[string][]models.Run
+}
+
+func (m *memoryRuns) add(source, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bySource[source] = append(m.bySource[source], models.Run{ID: source + "#" + status, Source: source, Status: status})
+}
+
+func (m *memoryRuns) Create(ctx context.Context, run *models.Run) error         { return nil }
+func (m *memoryRuns) ClaimPartition(ctx context.Context, run *models.Run) error { return nil }
+func (m *memoryRuns) Update(ctx context.Context, run *models.Run) error         { return nil }
+func (m *memoryRuns) Get(ctx context.Context, id string) (*models.Run, error)   { return nil, nil }
+func (m *memoryRuns) Latest(ctx context.Context) (*models.Run, error)           { return nil, nil }
+func (m *memoryRuns) JobRuns(ctx context.Context, jobID string) ([]models.Run, error) {
+	return nil, nil
+}
+
+func (m *memoryRuns) SourceRuns(ctx context.Context, source string) ([]models.Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Run(nil), m.bySource[source]...), nil
+}
+
+func (m *memoryRuns) UpdateProgress(ctx context.Context, run *models.Run) error { return nil }
+func (m *memoryRuns) RequestCancel(ctx context.Context, id string, at int64) (bool, error) {
+	return false, nil
+}
+
+func (m *memoryRuns) CancelRequested(ctx context.Context, id string) (bool, error) {
+	return false, nil
+}
+
+func (m *memoryRuns) List(ctx context.Context, filter repository.RunFilter) ([]models.Run, error) {
+	return nil, nil
+}
+
+func TestPoller(t *testing.T) {
+	srv := newFakeServer(t)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-2 * time.Hour)
+	srv.write(t, "/inbox/old.csv", "old rows", old)
+	srv.write(t, "/inbox/done.csv", "done rows", old)
+	srv.write(t, "/inbox/bad.csv", "bad rows", old)
+	srv.write(t, "/inbox/notes.txt", "not a csv", old)
+	srv.write(t, "/inbox/uploading.csv", "half", now.Add(-10*time.Second))
+
+	downloads := t.TempDir()
+	src := Source{
+		Config:      Config{Host: "sftp.example"},
+		Pattern:     "/inbox/*.csv",
+		ArchiveDir:  "/archive",
+		FailedDir:   "/failed",
+		MinAge:      time.Minute,
+		DownloadDir: downloads,
+	}
+	runs := &memoryRuns{bySource: map[string][]models.Run{}}
+	p := NewPoller(src, runs)
+	p.now = func() time.Time { return now }
+	p.dial = func(ctx context.Context, cfg Config) (*Client, error) { return srv.client(t), nil }
+
+	source := func(name string) string {
+		return "sftp://sftp.example:22/inbox/" + name + "?mtime=" + strconv.FormatInt(old.Unix(), 10)
+	}
+	// done.csv was imported by a poll that could not archive it, and
+	// bad.csv failed twice already
+	runs.add(source("done.csv"), models.RunSucceeded)
+	runs.add(source("bad.csv"), models.RunFailed)
+	runs.add(source("bad.csv"), models.RunFailed)
+
+	imported := map[string]string{}
+	importFn := func(ctx context.Context, file, source string) error {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		imported[source] = string(content)
+		if strings.Contains(source, "bad.csv") {
+			runs.add(source, models.RunFailed)
+			return errors.New("rolled back")
+		}
+		runs.add(source, models.RunSucceeded)
+		return nil
+	}
+
+	n, err := p.Poll(context.Background(), importFn)
+	if n != 1 {
+		t.Errorf("Poll() imported %d files, want 1", n)
+	}
+	if err == nil || !strings.Contains(err.Error(), "bad.csv") {
+		t.Errorf("Poll() error = %v, want the failure of bad.csv", err)
+	}
+	if len(imported) != 2 || imported[source("old.csv")] != "old rows" || imported[source("bad.csv")] != "bad rows" {
+		t.Errorf("imported %v; only the new files should be imported, under their remote source", imported)
+	}
+
+	stamp := "20261017T120000Z_"
+	if got := srv.ls(t, "/archive"); strings.Join(got, ",") != stamp+"done.csv,"+stamp+"old.csv" {
+		t.Errorf("archive = %v", got)
+	}
+	if got := srv.ls(t, "/failed"); strings.Join(got, ",") != stamp+"bad.csv" {
+		t.Errorf("failed = %v; bad.csv failed its last attempt", got)
+	}
+	if got := srv.ls(t, "/inbox"); strings.Join(got, ",") != "notes.txt,uploading.csv" {
+		t.Errorf("inbox = %v", got)
+	}
+	if left, _ := os.ReadDir(downloads); len(left) != 0 {
+		t.Errorf("local copies should be removed, found %d", len(left))
+	}
+
+	// nothing is left to import
+	n, err = p.Poll(context.Background(), importFn)
+	if n != 0 || err != nil || len(imported) != 2 {
+		t.Errorf("second Poll() = %d, %v", n, err)
+	}
+}
+
+func TestPoller_CancelledImportStaysInPlace(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.write(t, "/inbox/a.csv", "rows", time.Unix(1760000000, 0))
+	p := NewPoller(Source{Config: Config{Host: "sftp.example"}, Pattern: "/inbox/*.csv", ArchiveDir: "/archive"},
+		&memoryRuns{bySource: map[string][]models.Run{}})
+	p.dial = func(ctx context.Context, cfg Config) (*Client, error) { return srv.client(t), nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err := p.Poll(ctx, func(ctx context.Context, file, source string) error {
+		cancel()
+		return ctx.Err()
+	})
+	if n != 0 || err != nil {
+		t.Errorf("Poll() = %d, %v; a cancelled import is not a failure", n, err)
+	}
+	if got := srv.ls(t, "/inbox"); len(got) != 1 {
+		t.Errorf("inbox = %v; the file should be imported again by the next poll", got)
+	}
+}
+
+// sshServer serves the "sftp" subsystem of fake on a local port, to the
+// client key only
+type sshServer struct {
+	addr    string
+	hostKey ssh.Signer
+}
+
+func newSSHServer(t *testing.T, fake *fakeServer, clientKey ssh.PublicKey) *sshServer {
+	t.Helper()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	cfg.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nch := range chans {
+					ch, reqs, _ := nch.Accept()
+					go func() {
+						for req := range reqs {
+							ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+							req.Reply(ok, nil)
+							if ok {
+								go fake.serve(ch, ch)
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return &sshServer{addr: ln.Addr().String(), hostKey: hostKey}
+}
+
+// clientConfigFiles writes key and a known_hosts file trusting hostKey for the
+// server at addr
+func clientConfigFiles(t *testing.T, addr string, key ed25519.PrivateKey, hostKey ssh.PublicKey) Config {
+	t.Helper()
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	writeKnownHosts(t, knownHosts, addr, hostKey)
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	return Config{Host: host, Port: p, User: "vendor", KeyFile: keyFile, KnownHosts: knownHosts}
+}
+
+func writeKnownHosts(t *testing.T, file, addr string, hostKey ssh.PublicKey) {
+	t.Helper()
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey) + "\n"
+	if err := os.WriteFile(file, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	fake := newFakeServer(t)
+	fake.write(t, "/inbox/a.csv", "rows", time.Unix(1760000000, 0))
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newSSHServer(t, fake, signer.PublicKey())
+	cfg := clientConfigFiles(t, srv.addr, key, srv.hostKey.PublicKey())
+
+	c, err := Dial(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	files, err := c.ReadDir("/inbox")
+	c.Close()
+	if err != nil || len(files) != 1 || files[0].Name != "a.csv" {
+		t.Errorf("ReadDir() = %+v, %v", files, err)
+	}
+
+	// a host key missing from known_hosts is refused
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	otherSigner, _ := ssh.NewSignerFromKey(other)
+	writeKnownHosts(t, cfg.KnownHosts, srv.addr, otherSigner.PublicKey())
+	if _, err := Dial(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "key mismatch") {
+		t.Errorf("Dial() with an unknown host key = %v, want a key mismatch", err)
+	}
+}
@@ -0,0 +1,118 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultPort is the port of SSH
+const DefaultPort = 22
+
+const dialTimeout = 15 * time.Second
+
+// Config locates the SFTP server. The client authenticates as User with
+// the private key in KeyFile, decrypted with KeyPassphrase when set, and
+// only trusts the host keys listed for Host in the KnownHosts file, as
+// written by ssh-keyscan.
+type Config struct {
+	Host          string
+	Port          int
+	User          string
+	KeyFile       string
+	KeyPassphrase string
+	KnownHosts    string
+}
+
+// Addr is the host:port of the server
+func (c Config) Addr() string {
+	port := c.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// clientConfig reads the key and the known hosts of cfg
+func clientConfig(cfg Config) (*ssh.ClientConfig, error) {
+	pem, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: private key: %w", err)
+	}
+	var signer ssh.Signer
+	if cfg.KeyPassphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(cfg.KeyPassphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pem)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sftp: private key %s: %w", cfg.KeyFile, err)
+	}
+	hostKeys, err := knownhosts.New(cfg.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: known hosts: %w", err)
+	}
+	return &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeys,
+		Timeout:         dialTimeout,
+	}, nil
+}
+
+// Dial connects to the server of cfg and starts an SFTP session; Close
+// ends the session and the connection
+func Dial(ctx context.Context, cfg Config) (*Client, error) {
+	sshCfg, err := clientConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	d := net.Dialer{Timeout: dialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", cfg.Addr())
+	if err != nil {
+		return nil, fmt.Errorf("sftp: dial %s: %w", cfg.Addr(), err)
+	}
+	// the SSH handshake may not outlive the dial timeout, nor ctx
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	stop := context.AfterFunc(ctx, func() { nc.Close() })
+	conn, chans, reqs, err := ssh.NewClientConn(nc, cfg.Addr(), sshCfg)
+	stop()
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("sftp: ssh handshake with %s: %w", cfg.Addr(), err)
+	}
+	nc.SetDeadline(time.Time{})
+	sc := ssh.NewClient(conn, chans, reqs)
+
+	session, err := sc.NewSession()
+	if err != nil {
+		sc.Close()
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		sc.Close()
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		sc.Close()
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		sc.Close()
+		return nil, fmt.Errorf("sftp: subsystem: %w", err)
+	}
+	c, err := newClient(r, w, sc)
+	if err != nil {
+		sc.Close()
+		return nil, err
+	}
+	return c, nil
+}
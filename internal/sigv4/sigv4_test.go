@@ -0,0 +1,43 @@
+package sigv4
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// the example of the AWS documentation on deriving a signing key
+func TestSigningKey(t *testing.T) {
+	got := hex.EncodeToString(signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam"))
+	if want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"; got != want {
+		t.Errorf("signingKey() = %s, want %s", got, want)
+	}
+}
+
+// get-vanilla of the AWS Signature Version 4 test suite
+func TestSign(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	s := Signer{
+		Credentials: Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		Region:      "us-east-1",
+		Service:     "service",
+		Now:         func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+	s.Sign(req, PayloadHash(nil))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	if got := URIEncode("/exports/a b/ç~.ndjson", false); got != "/exports/a%20b/%C3%A7~.ndjson" {
+		t.Errorf("URIEncode() = %q", got)
+	}
+	if got := URIEncode("a/b", true); got != "a%2Fb" {
+		t.Errorf("URIEncode() = %q", got)
+	}
+}
@@ -0,0 +1,58 @@
+// Package users looks up users in an external user service
+package users
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Placeholder is replaced by the user ID in the URL of an HTTPDirectory
+const Placeholder = "{user_id}"
+
+// HTTPDirectory asks a user service whether a user exists: a GET on the
+// URL with Placeholder replaced answers 2xx for a known user and 404 for
+// an unknown one; any other status is an error
+type HTTPDirectory struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPDirectory creates a directory for url, which must contain
+// Placeholder; each lookup takes at most timeout
+func NewHTTPDirectory(url string, timeout time.Duration) *HTTPDirectory {
+	return &HTTPDirectory{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (d *HTTPDirectory) Exists(ctx context.Context, userID uint64) (bool, error) {
+	url := strings.ReplaceAll(d.url, Placeholder, strconv.FormatUint(userID, 10))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("user lookup: %w", err)
+	}
+	defer res.Body.Close()
+	// the body is not needed, but reading it lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return false, nil
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		return true, nil
+	default:
+		return false, fmt.Errorf("user lookup: status %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,40 @@
+package users
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPDirectory_Exists(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/1":
+			w.Write([]byte(`{"id":1}`))
+		case "/users/2":
+			http.NotFound(w, r)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	dir := NewHTTPDirectory(srv.URL+"/users/"+Placeholder, time.Second)
+	tests := []struct {
+		userID  uint64
+		want    bool
+		wantErr bool
+	}{
+		{userID: 1, want: true},
+		{userID: 2, want: false},
+		{userID: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := dir.Exists(context.Background(), tt.userID)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Exists(%d) = %v, %v; want %v, error %v", tt.userID, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bigQueryEndpoint = "https://bigquery.googleapis.com"
+	bigQueryScope    = "https://www.googleapis.com/auth/bigquery"
+	googleTokenURI   = "https://oauth2.googleapis.com/token"
+)
+
+// BigQueryConfig is the table the rows are appended to. The table needs
+// the columns of Row: id INT64, user_id INT64, segmentation_type STRING,
+// segmentation_name STRING, data JSON and TIMESTAMPs created_at,
+// updated_at, synced_at and, for the tombstones, a nullable erased_at.
+type BigQueryConfig struct {
+	Project string
+	Dataset string
+	Table   string
+	// Location is the location of the dataset; empty for the US and EU
+	// multi-regions
+	Location string
+	// Credentials is the JSON key file of a service account allowed to
+	// run load jobs on the table
+	Credentials []byte
+}
+
+// BigQuery appends the batches with load jobs, uploading each batch along
+// with the job. It only uses the standard library: the service account
+// key signs the JWT exchanged for an access token.
+type BigQuery struct {
+	cfg      BigQueryConfig
+	account  serviceAccount
+	http     *http.Client
+	endpoint string
+	now      func() time.Time
+	// pollInterval is the wait between the checks of a running job
+	pollInterval time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// serviceAccount is the part of a key file used to get access tokens
+type serviceAccount struct {
+	email    string
+	keyID    string
+	tokenURI string
+	key      *rsa.PrivateKey
+}
+
+// NewBigQuery loads into the table of cfg through hc, or
+// http.DefaultClient when nil
+func NewBigQuery(cfg BigQueryConfig, hc *http.Client) (*BigQuery, error) {
+	if cfg.Project == "" || cfg.Dataset == "" || cfg.Table == "" {
+		return nil, errors.New("bigquery: project, dataset and table are required")
+	}
+	account, err := parseServiceAccount(cfg.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &BigQuery{
+		cfg:          cfg,
+		account:      account,
+		http:         hc,
+		endpoint:     bigQueryEndpoint,
+		now:          time.Now,
+		pollInterval: 2 * time.Second,
+	}, nil
+}
+
+func parseServiceAccount(raw []byte) (serviceAccount, error) {
+	var file struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKeyID string `json:"private_key_id"`
+		PrivateKey   string `json:"private_key"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return serviceAccount{}, fmt.Errorf("bigquery: invalid credentials: %w", err)
+	}
+	if file.Type != "service_account" || file.ClientEmail == "" {
+		return serviceAccount{}, errors.New("bigquery: credentials are not the key file of a service account")
+	}
+	block, _ := pem.Decode([]byte(file.PrivateKey))
+	if block == nil {
+		return serviceAccount{}, errors.New("bigquery: credentials without a PEM private key")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		key, _ = parsed.(*rsa.PrivateKey)
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return serviceAccount{}, fmt.Errorf("bigquery: invalid private key: %w", err)
+	}
+	if key == nil {
+		return serviceAccount{}, errors.New("bigquery: the private key is not an RSA key")
+	}
+	if file.TokenURI == "" {
+		file.TokenURI = googleTokenURI
+	}
+	return serviceAccount{email: file.ClientEmail, keyID: file.PrivateKeyID, tokenURI: file.TokenURI, key: key}, nil
+}
+
+// bigQueryJob is the part of a job resource read back
+type bigQueryJob struct {
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Status struct {
+		State       string           `json:"state"`
+		ErrorResult *bigQueryError   `json:"errorResult"`
+		Errors      []*bigQueryError `json:"errors"`
+	} `json:"status"`
+}
+
+type bigQueryError struct {
+	Reason   string `json:"reason"`
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// Load creates a load job of b and waits for it to finish
+func (q *BigQuery) Load(ctx context.Context, b Batch) error {
+	jobID := "segmentations_" + b.ID
+	jobRef := map[string]string{"projectId": q.cfg.Project, "jobId": jobID}
+	if q.cfg.Location != "" {
+		jobRef["location"] = q.cfg.Location
+	}
+	meta := map[string]any{
+		"jobReference": jobRef,
+		"configuration": map[string]any{
+			"load": map[string]any{
+				"destinationTable": map[string]string{
+					"projectId": q.cfg.Project,
+					"datasetId": q.cfg.Dataset,
+					"tableId":   q.cfg.Table,
+				},
+				"sourceFormat":     "NEWLINE_DELIMITED_JSON",
+				"writeDisposition": "WRITE_APPEND",
+			},
+		},
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		write       func(io.Writer) error
+	}{
+		{"application/json; charset=UTF-8", func(w io.Writer) error { return json.NewEncoder(w).Encode(meta) }},
+		{"application/octet-stream", func(w io.Writer) error { _, err := w.Write(b.Body); return err }},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+		if err := part.write(w); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	target := q.endpoint + "/upload/bigquery/v2/projects/" + url.PathEscape(q.cfg.Project) + "/jobs?uploadType=multipart"
+	var job bigQueryJob
+	if err := q.do(ctx, http.MethodPost, target, "multipart/related; boundary="+mw.Boundary(), &body, &job); err != nil {
+		return fmt.Errorf("bigquery: create load job %s: %w", jobID, err)
+	}
+
+	for job.Status.State != "DONE" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(q.pollInterval):
+		}
+		target := q.endpoint + "/bigquery/v2/projects/" + url.PathEscape(q.cfg.Project) + "/jobs/" + url.PathEscape(jobID)
+		if loc := job.JobReference.Location; loc != "" {
+			target += "?location=" + url.QueryEscape(loc)
+		}
+		if err := q.do(ctx, http.MethodGet, target, "", nil, &job); err != nil {
+			return fmt.Errorf("bigquery: get load job %s: %w", jobID, err)
+		}
+	}
+	if e := job.Status.ErrorResult; e != nil {
+		msg := e.Message
+		// the first row error tells more than "Error while reading data"
+		for _, detail := range job.Status.Errors {
+			if detail.Message != e.Message {
+				msg += ": " + detail.Message
+				break
+			}
+		}
+		return fmt.Errorf("bigquery: load job %s failed (%s): %s", jobID, e.Reason, msg)
+	}
+	return nil
+}
+
+// do sends an authenticated request and decodes the JSON answer into out
+func (q *BigQuery) do(ctx context.Context, method, target, contentType string, body io.Reader, out any) error {
+	token, err := q.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := q.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return googleError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// accessToken returns the cached access token, or exchanges a new JWT
+// signed by the service account for one when it is about to expire
+func (q *BigQuery) accessToken(ctx context.Context) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	if q.token != "" && now.Before(q.expiry) {
+		return q.token, nil
+	}
+
+	assertion, err := q.account.assertion(now)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.account.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := q.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("bigquery: access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("bigquery: access token: %w", googleError(resp))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("bigquery: access token: invalid answer: %v", err)
+	}
+	// renewed a minute early, so a token never expires mid-request
+	q.token = tok.AccessToken
+	q.expiry = now.Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return q.token, nil
+}
+
+// assertion returns a JWT of the service account, signed with RS256 and
+// valid for an hour
+func (a serviceAccount) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": a.keyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   a.email,
+		"scope": bigQueryScope,
+		"aud":   a.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("bigquery: sign JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// googleError returns the message of an error answer of a Google API,
+// {"error": {"message": ...}} or the OAuth {"error_description": ...}
+func googleError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<10))
+	var body struct {
+		Error            json.RawMessage `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+	msg := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &body) == nil {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		switch {
+		case json.Unmarshal(body.Error, &apiErr) == nil && apiErr.Message != "":
+			msg = apiErr.Message
+		case body.ErrorDescription != "":
+			msg = body.ErrorDescription
+		}
+	}
+	return fmt.Errorf("%s: %s", resp.Status, msg)
+}
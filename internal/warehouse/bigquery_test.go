@@ -0,0 +1,149 @@
+package warehouse
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bigQueryServer serves the token endpoint and the jobs API; the created
+// job runs until it is polled once, then finishes with errorResult
+func bigQueryServer(t *testing.T, key *rsa.PrivateKey, errorResult string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var tokens atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		tokens.Add(1)
+		r.ParseForm()
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		if len(parts) != 3 {
+			t.Fatalf("invalid assertion %q", r.PostForm.Get("assertion"))
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+			t.Errorf("assertion signature: %v", err)
+		}
+		claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		if !strings.Contains(string(claims), `"iss":"loader@project.iam.gserviceaccount.com"`) {
+			t.Errorf("unexpected claims %s", claims)
+		}
+		io.WriteString(w, `{"access_token":"ya29.token","expires_in":3600}`)
+	})
+	mux.HandleFunc("POST /upload/bigquery/v2/projects/analytics/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.token" || r.URL.Query().Get("uploadType") != "multipart" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		meta, _ := mr.NextPart()
+		raw, _ := io.ReadAll(meta)
+		if !strings.Contains(string(raw), `"tableId":"segmentations"`) || !strings.Contains(string(raw), `"jobId":"segmentations_b1"`) {
+			t.Errorf("unexpected job %s", raw)
+		}
+		data, _ := mr.NextPart()
+		if raw, _ := io.ReadAll(data); string(raw) != "{\"id\":1}\n" {
+			t.Errorf("unexpected data %q", raw)
+		}
+		io.WriteString(w, `{"jobReference":{"jobId":"segmentations_b1","location":"southamerica-east1"},"status":{"state":"RUNNING"}}`)
+	})
+	mux.HandleFunc("GET /bigquery/v2/projects/analytics/jobs/segmentations_b1", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("location") != "southamerica-east1" {
+			t.Errorf("the job should be polled in its location, got %q", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": map[string]any{"state": "DONE", "errorResult": json.RawMessage(errorResult)},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &tokens
+}
+
+func newTestBigQuery(t *testing.T, srv *httptest.Server, key *rsa.PrivateKey) *BigQuery {
+	t.Helper()
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	creds, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "loader@project.iam.gserviceaccount.com",
+		"private_key_id": "k1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      srv.URL + "/token",
+	})
+	q, err := NewBigQuery(BigQueryConfig{
+		Project:     "analytics",
+		Dataset:     "segmentation",
+		Table:       "segmentations",
+		Location:    "southamerica-east1",
+		Credentials: creds,
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewBigQuery() error = %v", err)
+	}
+	q.endpoint = srv.URL
+	q.pollInterval = time.Millisecond
+	return q
+}
+
+func TestBigQuery_Load(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, tokens := bigQueryServer(t, key, "null")
+	q := newTestBigQuery(t, srv, key)
+
+	for range 2 {
+		if err := q.Load(context.Background(), Batch{ID: "b1", Rows: 1, Body: []byte("{\"id\":1}\n")}); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+	}
+	if tokens.Load() != 1 {
+		t.Errorf("the access token should be reused, got %d exchanges", tokens.Load())
+	}
+}
+
+func TestBigQuery_LoadJobError(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := bigQueryServer(t, key, `{"reason":"invalid","message":"JSON parsing error in row starting at position 0"}`)
+	q := newTestBigQuery(t, srv, key)
+
+	err = q.Load(context.Background(), Batch{ID: "b1", Rows: 1, Body: []byte("{\"id\":1}\n")})
+	if err == nil || !strings.Contains(err.Error(), "JSON parsing error") {
+		t.Errorf("Load() error = %v, want the error of the job", err)
+	}
+}
+
+func TestNewBigQuery_InvalidCredentials(t *testing.T) {
+	for _, creds := range []string{
+		``,
+		`{"type":"authorized_user","client_email":"a@b"}`,
+		`{"type":"service_account","client_email":"a@b","private_key":"not a key"}`,
+	} {
+		cfg := BigQueryConfig{Project: "p", Dataset: "d", Table: "t", Credentials: []byte(creds)}
+		if _, err := NewBigQuery(cfg, nil); err == nil {
+			t.Errorf("NewBigQuery(%q) should fail", creds)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"segmentation-api/internal/export"
+	"segmentation-api/internal/sigv4"
+)
+
+// redshiftTable is a table name, optionally schema-qualified, that can go
+// into the COPY unquoted
+var redshiftTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// RedshiftConfig is the table the rows are copied to, on a provisioned
+// cluster (ClusterID) or a serverless workgroup (Workgroup). The table
+// needs the columns of Row: id and user_id BIGINT, segmentation_type and
+// segmentation_name VARCHAR, data SUPER and TIMESTAMPs created_at,
+// updated_at, synced_at and, for the tombstones, a nullable erased_at.
+type RedshiftConfig struct {
+	Region    string
+	ClusterID string
+	Workgroup string
+	Database  string
+	// DBUser connects to a cluster with temporary credentials; SecretARN
+	// names a Secrets Manager secret holding them instead
+	DBUser    string
+	SecretARN string
+	Table     string
+	// IAMRole is the role COPY reads the staged files with
+	IAMRole string
+	sigv4.Credentials
+}
+
+// Redshift stages each batch as a gzip-compressed file in S3 and copies it
+// into the table with a COPY run through the Redshift Data API, signed
+// with AWS Signature Version 4. The staged files are left in the bucket,
+// for a lifecycle rule to expire.
+type Redshift struct {
+	cfg      RedshiftConfig
+	stage    export.Sink
+	http     *http.Client
+	endpoint string
+	signer   sigv4.Signer
+	// pollInterval is the wait between the checks of a running COPY
+	pollInterval time.Duration
+}
+
+// NewRedshift copies into the table of cfg the files staged in stage, an
+// S3 bucket IAMRole can read, calling the Data API through hc, or
+// http.DefaultClient when nil
+func NewRedshift(cfg RedshiftConfig, stage export.Sink, hc *http.Client) (*Redshift, error) {
+	switch {
+	case (cfg.ClusterID == "") == (cfg.Workgroup == ""):
+		return nil, errors.New("redshift: exactly one of cluster ID and workgroup is required")
+	case cfg.Region == "" || cfg.Database == "" || cfg.IAMRole == "":
+		return nil, errors.New("redshift: region, database and IAM role are required")
+	case !redshiftTable.MatchString(cfg.Table):
+		return nil, fmt.Errorf("redshift: invalid table %q: must be [schema.]name", cfg.Table)
+	case cfg.AccessKeyID == "" || cfg.SecretAccessKey == "":
+		return nil, errors.New("redshift: access key ID and secret access key are required")
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Redshift{
+		cfg:          cfg,
+		stage:        stage,
+		http:         hc,
+		endpoint:     "https://redshift-data." + cfg.Region + ".amazonaws.com",
+		signer:       sigv4.Signer{Credentials: cfg.Credentials, Region: cfg.Region, Service: "redshift-data"},
+		pollInterval: 2 * time.Second,
+	}, nil
+}
+
+// Load stages b and runs the COPY of it, waiting for it to finish
+func (r *Redshift) Load(ctx context.Context, b Batch) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b.Body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	name := "segmentations_" + b.ID + ".ndjson.gz"
+	if err := r.stage.Put(ctx, name, bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		return fmt.Errorf("redshift: stage %s: %w", name, err)
+	}
+
+	sql := fmt.Sprintf("COPY %s FROM %s IAM_ROLE %s FORMAT AS JSON 'auto' GZIP TIMEFORMAT 'auto'",
+		r.cfg.Table, quoteLiteral(r.stage.Location(name)), quoteLiteral(r.cfg.IAMRole))
+	var exec struct {
+		ID string `json:"Id"`
+	}
+	err := r.call(ctx, "ExecuteStatement", map[string]string{
+		"ClusterIdentifier": r.cfg.ClusterID,
+		"WorkgroupName":     r.cfg.Workgroup,
+		"Database":          r.cfg.Database,
+		"DbUser":            r.cfg.DBUser,
+		"SecretArn":         r.cfg.SecretARN,
+		"Sql":               sql,
+		"StatementName":     "segmentations_" + b.ID,
+	}, &exec)
+	if err != nil {
+		return fmt.Errorf("redshift: execute COPY: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.pollInterval):
+		}
+		var st struct {
+			Status string `json:"Status"`
+			Error  string `json:"Error"`
+		}
+		if err := r.call(ctx, "DescribeStatement", map[string]string{"Id": exec.ID}, &st); err != nil {
+			return fmt.Errorf("redshift: describe COPY %s: %w", exec.ID, err)
+		}
+		switch st.Status {
+		case "FINISHED":
+			return nil
+		case "FAILED", "ABORTED":
+			return fmt.Errorf("redshift: COPY %s %s: %s", exec.ID, strings.ToLower(st.Status), st.Error)
+		}
+	}
+}
+
+// call sends a Data API action; empty fields of in are left out
+func (r *Redshift) call(ctx context.Context, action string, in map[string]string, out any) error {
+	for k, v := range in {
+		if v == "" {
+			delete(in, k)
+		}
+	}
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "RedshiftData."+action)
+	r.signer.Sign(req, sigv4.PayloadHash(payload))
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<10))
+		var body struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(raw, &body) != nil || body.Message == "" {
+			body.Message = strings.TrimSpace(string(raw))
+		}
+		return fmt.Errorf("%s: %s %s", resp.Status, body.Type, body.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// quoteLiteral quotes s as an SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,146 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/sigv4"
+)
+
+// memorySink keeps the staged files
+type memorySink map[string][]byte
+
+func (m memorySink) Put(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	raw, err := io.ReadAll(body)
+	m[name] = raw
+	return err
+}
+
+func (m memorySink) Location(name string) string {
+	return "s3://staging/warehouse/" + name
+}
+
+func newTestRedshift(t *testing.T, h http.HandlerFunc) (*Redshift, memorySink) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	stage := memorySink{}
+	r, err := NewRedshift(RedshiftConfig{
+		Region:      "sa-east-1",
+		Workgroup:   "analytics",
+		Database:    "dev",
+		Table:       "segmentation.segmentations",
+		IAMRole:     "arn:aws:iam::123456789012:role/redshift-copy",
+		Credentials: sigv4.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	}, stage, srv.Client())
+	if err != nil {
+		t.Fatalf("NewRedshift() error = %v", err)
+	}
+	r.endpoint = srv.URL
+	r.pollInterval = time.Millisecond
+	return r, stage
+}
+
+func TestRedshift_Load(t *testing.T) {
+	var describes atomic.Int32
+	r, stage := newTestRedshift(t, func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(req.Header.Get("Authorization"), "/sa-east-1/redshift-data/aws4_request, SignedHeaders=host;x-amz-date;x-amz-target,") {
+			t.Errorf("unexpected Authorization %q", req.Header.Get("Authorization"))
+		}
+		var in map[string]string
+		json.NewDecoder(req.Body).Decode(&in)
+
+		switch req.Header.Get("X-Amz-Target") {
+		case "RedshiftData.ExecuteStatement":
+			want := "COPY segmentation.segmentations FROM 's3://staging/warehouse/segmentations_b1.ndjson.gz' " +
+				"IAM_ROLE 'arn:aws:iam::123456789012:role/redshift-copy' FORMAT AS JSON 'auto' GZIP TIMEFORMAT 'auto'"
+			if in["Sql"] != want || in["WorkgroupName"] != "analytics" || in["Database"] != "dev" {
+				t.Errorf("unexpected statement %+v", in)
+			}
+			if _, ok := in["ClusterIdentifier"]; ok {
+				t.Error("empty fields should be left out")
+			}
+			io.WriteString(w, `{"Id":"st-1"}`)
+		case "RedshiftData.DescribeStatement":
+			if in["Id"] != "st-1" {
+				t.Errorf("unexpected statement ID %q", in["Id"])
+			}
+			if describes.Add(1) == 1 {
+				io.WriteString(w, `{"Status":"STARTED"}`)
+				return
+			}
+			io.WriteString(w, `{"Status":"FINISHED"}`)
+		default:
+			t.Errorf("unexpected action %q", req.Header.Get("X-Amz-Target"))
+		}
+	})
+
+	if err := r.Load(context.Background(), Batch{ID: "b1", Rows: 1, Body: []byte("{\"id\":1}\n")}); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(stage["segmentations_b1.ndjson.gz"]))
+	if err != nil {
+		t.Fatalf("the batch should be staged gzip-compressed: %v", err)
+	}
+	if raw, _ := io.ReadAll(zr); string(raw) != "{\"id\":1}\n" {
+		t.Errorf("unexpected staged file %q", raw)
+	}
+	if describes.Load() != 2 {
+		t.Errorf("the COPY should be polled until it finishes, got %d polls", describes.Load())
+	}
+}
+
+func TestRedshift_LoadFailed(t *testing.T) {
+	r, _ := newTestRedshift(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Amz-Target") == "RedshiftData.ExecuteStatement" {
+			io.WriteString(w, `{"Id":"st-1"}`)
+			return
+		}
+		io.WriteString(w, `{"Status":"FAILED","Error":"Load into table 'segmentations' failed. Check 'sys_load_error_detail'"}`)
+	})
+	err := r.Load(context.Background(), Batch{ID: "b1", Rows: 1, Body: []byte("{}\n")})
+	if err == nil || !strings.Contains(err.Error(), "sys_load_error_detail") {
+		t.Errorf("Load() error = %v, want the error of the COPY", err)
+	}
+
+	r, _ = newTestRedshift(t, func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"__type":"ValidationException","message":"Workgroup analytics not found"}`)
+	})
+	err = r.Load(context.Background(), Batch{ID: "b1", Rows: 1, Body: []byte("{}\n")})
+	if err == nil || !strings.Contains(err.Error(), "Workgroup analytics not found") {
+		t.Errorf("Load() error = %v, want the error of the Data API", err)
+	}
+}
+
+func TestNewRedshift_Invalid(t *testing.T) {
+	valid := RedshiftConfig{
+		Region: "us-east-1", ClusterID: "c", Database: "dev", Table: "segmentations", IAMRole: "arn",
+		Credentials: sigv4.Credentials{AccessKeyID: "a", SecretAccessKey: "b"},
+	}
+	for name, mutate := range map[string]func(*RedshiftConfig){
+		"cluster and workgroup": func(c *RedshiftConfig) { c.Workgroup = "w" },
+		"no database":           func(c *RedshiftConfig) { c.Database = "" },
+		"injected table":        func(c *RedshiftConfig) { c.Table = "segmentations; DROP TABLE users" },
+		"no credentials":        func(c *RedshiftConfig) { c.Credentials = sigv4.Credentials{} },
+	} {
+		cfg := valid
+		mutate(&cfg)
+		if _, err := NewRedshift(cfg, memorySink{}, nil); err == nil {
+			t.Errorf("%s: NewRedshift() should fail", name)
+		}
+	}
+	if _, err := NewRedshift(valid, memorySink{}, nil); err != nil {
+		t.Errorf("NewRedshift() error = %v", err)
+	}
+}
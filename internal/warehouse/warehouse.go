@@ -0,0 +1,340 @@
+// Package warehouse syncs the changed segmentations to a data warehouse.
+// Each sync reads the rows updated since the watermark of the previous one
+// from the change feed, in (updated_at, id) order, and appends them to the
+// warehouse table with its bulk load: a BigQuery load job or a Redshift
+// COPY. The watermark only advances once a batch is loaded, so a failed
+// sync starts over from the same row. Delivery is therefore at least once:
+// readers keep the row with the latest updated_at of each (user_id,
+// segmentation_type, segmentation_name).
+//
+// The feed holds the current rows only: a row written twice between syncs
+// is loaded once, as last written, and a row deleted before a sync never
+// reaches the warehouse, while a deletion after it is not synced. Users
+// erased on request (LGPD, GDPR) are the exception, with WithErasures:
+// each erasure record is loaded as a tombstone, a row of the user with
+// only erased_at (and created_at and updated_at) set, and readers drop
+// the rows of the user updated up to erased_at. The rows loaded before
+// the erasure stay in the table until the warehouse deletes them, e.g.
+// with a scheduled DELETE of the rows older than the tombstone of their
+// user; the syncer only ever appends.
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// Row is a segmentation as loaded into the warehouse, one JSON object per
+// line. Times are RFC 3339 in UTC; Data is the JSON of the segmentation.
+type Row struct {
+	ID               uint64          `json:"id"`
+	UserID           uint64          `json:"user_id"`
+	SegmentationType string          `json:"segmentation_type"`
+	SegmentationName string          `json:"segmentation_name"`
+	Data             json.RawMessage `json:"data"`
+	CreatedAt        string          `json:"created_at"`
+	UpdatedAt        string          `json:"updated_at"`
+	SyncedAt         string          `json:"synced_at"`
+	// ErasedAt is only set in the tombstone of an erased user
+	ErasedAt string `json:"erased_at,omitempty"`
+}
+
+// Batch is a group of rows loaded at once
+type Batch struct {
+	// ID is unique to the batch and made of letters, digits and _, to name
+	// a load job or a staged file
+	ID   string
+	Rows int
+	// Body is the NDJSON of the rows
+	Body []byte
+}
+
+// Loader appends a batch to the warehouse table, returning once the rows
+// are visible there
+type Loader interface {
+	Load(ctx context.Context, b Batch) error
+}
+
+// LoaderFunc adapts a function to Loader
+type LoaderFunc func(ctx context.Context, b Batch) error
+
+func (f LoaderFunc) Load(ctx context.Context, b Batch) error {
+	return f(ctx, b)
+}
+
+// Syncer loads the changes of the segmentations into a warehouse
+type Syncer struct {
+	name       string
+	changes    repository.ChangeRepository
+	erasures   repository.ErasureRepository
+	watermarks repository.WatermarkRepository
+	loader     Loader
+	batchRows  int
+	interval   time.Duration
+	lag        time.Duration
+	logger     *zap.Logger
+	now        func() time.Time
+}
+
+// Option customizes a Syncer
+type Option func(*Syncer)
+
+// WithBatchRows sets how many rows are loaded at a time (default 50000)
+func WithBatchRows(n int) Option {
+	return func(s *Syncer) {
+		if n > 0 {
+			s.batchRows = n
+		}
+	}
+}
+
+// WithInterval sets how often Run syncs (default 15m)
+func WithInterval(d time.Duration) Option {
+	return func(s *Syncer) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// WithLag leaves the rows updated in the last d for the next sync, so a
+// write committed after a later one is not skipped (default 1m)
+func WithLag(d time.Duration) Option {
+	return func(s *Syncer) {
+		s.lag = max(0, d)
+	}
+}
+
+// WithErasures also loads a tombstone for each erasure record of erasures,
+// under its own watermark, the name of the sync followed by ":erasures"
+func WithErasures(erasures repository.ErasureRepository) Option {
+	return func(s *Syncer) {
+		s.erasures = erasures
+	}
+}
+
+// WithLogger sets the logger of the syncer; by default nothing is logged
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Syncer) {
+		s.logger = logger
+	}
+}
+
+// NewSyncer creates the sync name, e.g. "warehouse:bigquery", of the
+// changes of changes to loader; its watermark is kept in watermarks
+func NewSyncer(
+	name string,
+	changes repository.ChangeRepository,
+	watermarks repository.WatermarkRepository,
+	loader Loader,
+	opts ...Option,
+) *Syncer {
+	s := &Syncer{
+		name:       name,
+		changes:    changes,
+		watermarks: watermarks,
+		loader:     loader,
+		batchRows:  50000,
+		interval:   15 * time.Minute,
+		lag:        time.Minute,
+		logger:     zap.NewNop(),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run syncs right away and then every interval until ctx is done; a
+// failed sync is retried on the next interval
+func (s *Syncer) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		start := s.now()
+		n, err := s.Sync(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			s.logger.Error("warehouse_sync_failed", zap.String("sync", s.name), zap.Int("rows", n), zap.Error(err))
+		case n > 0:
+			s.logger.Info("warehouse_synced", zap.String("sync", s.name), zap.Int("rows", n),
+				zap.Duration("duration", s.now().Sub(start)))
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Sync loads the rows updated since the watermark and up to the lag ago,
+// a batch at a time, then the tombstones of the new erasure records, and
+// returns how many rows it loaded
+func (s *Syncer) Sync(ctx context.Context) (int, error) {
+	total, err := s.syncChanges(ctx)
+	if err != nil || s.erasures == nil {
+		return total, err
+	}
+	n, err := s.syncErasures(ctx)
+	return total + n, err
+}
+
+// syncChanges loads the rows of the change feed
+func (s *Syncer) syncChanges(ctx context.Context) (int, error) {
+	wm, err := s.watermarks.Get(ctx, s.name)
+	if err != nil {
+		return 0, fmt.Errorf("read watermark: %w", err)
+	}
+	if wm == nil {
+		wm = &models.SyncWatermark{Name: s.name}
+	}
+
+	now := s.now()
+	until := strconv.FormatInt(now.Add(-s.lag).Unix(), 10)
+	filter := repository.Filter{{Field: "updated_at", Op: repository.FilterLt, Values: []string{until}}}
+
+	var total int
+	for {
+		after := repository.ChangeCursor{UpdatedAt: wm.LastUpdatedAt, ID: wm.LastID}
+		segs, err := s.changes.Changes(ctx, after, filter, s.batchRows)
+		if err != nil {
+			return total, fmt.Errorf("read changes: %w", err)
+		}
+		if len(segs) == 0 {
+			return total, nil
+		}
+
+		batch, err := encode(segs, now)
+		if err != nil {
+			return total, err
+		}
+		batch.ID = fmt.Sprintf("%s_%d_%d", now.UTC().Format("20060102T150405Z"), after.UpdatedAt, after.ID)
+		if err := s.loader.Load(ctx, batch); err != nil {
+			return total, fmt.Errorf("load batch %s: %w", batch.ID, err)
+		}
+
+		last := segs[len(segs)-1]
+		wm.LastUpdatedAt, wm.LastID = last.UpdatedAt, last.ID
+		wm.SyncedRows += int64(len(segs))
+		wm.SyncedAt = now.Unix()
+		if err := s.watermarks.Save(ctx, wm); err != nil {
+			// loaded but not recorded: the batch is loaded again next time
+			return total, fmt.Errorf("save watermark: %w", err)
+		}
+		total += len(segs)
+		s.logger.Debug("warehouse_batch_loaded", zap.String("sync", s.name), zap.String("batch", batch.ID),
+			zap.Int("rows", len(segs)))
+
+		if len(segs) < s.batchRows {
+			return total, nil
+		}
+	}
+}
+
+// syncErasures loads the tombstones of the erasure records after the
+// erasures watermark, whose LastID is the ID of the last record loaded
+func (s *Syncer) syncErasures(ctx context.Context) (int, error) {
+	name := s.name + ":erasures"
+	wm, err := s.watermarks.Get(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("read erasures watermark: %w", err)
+	}
+	if wm == nil {
+		wm = &models.SyncWatermark{Name: name}
+	}
+
+	now := s.now()
+	var total int
+	for {
+		erasures, err := s.erasures.List(ctx, repository.ErasureFilter{AfterID: wm.LastID, Limit: s.batchRows})
+		if err != nil {
+			return total, fmt.Errorf("read erasures: %w", err)
+		}
+		if len(erasures) == 0 {
+			return total, nil
+		}
+
+		batch, err := encodeTombstones(erasures, now)
+		if err != nil {
+			return total, err
+		}
+		batch.ID = fmt.Sprintf("%s_erasures_%d", now.UTC().Format("20060102T150405Z"), wm.LastID)
+		if err := s.loader.Load(ctx, batch); err != nil {
+			return total, fmt.Errorf("load batch %s: %w", batch.ID, err)
+		}
+
+		last := erasures[len(erasures)-1]
+		wm.LastUpdatedAt, wm.LastID = int64(last.CreatedAt), last.ID
+		wm.SyncedRows += int64(len(erasures))
+		wm.SyncedAt = now.Unix()
+		if err := s.watermarks.Save(ctx, wm); err != nil {
+			// loaded but not recorded: the tombstones are loaded again,
+			// which readers ignore
+			return total, fmt.Errorf("save erasures watermark: %w", err)
+		}
+		total += len(erasures)
+		s.logger.Debug("warehouse_tombstones_loaded", zap.String("sync", s.name), zap.String("batch", batch.ID),
+			zap.Int("rows", len(erasures)))
+
+		if len(erasures) < s.batchRows {
+			return total, nil
+		}
+	}
+}
+
+// encode writes segs as the NDJSON of a batch
+func encode(segs []models.Segmentation, syncedAt time.Time) (Batch, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	synced := syncedAt.UTC().Format(time.RFC3339)
+	for _, seg := range segs {
+		row := Row{
+			ID:               seg.ID,
+			UserID:           seg.UserID,
+			SegmentationType: seg.SegmentationType,
+			SegmentationName: seg.SegmentationName,
+			Data:             json.RawMessage(seg.Data),
+			CreatedAt:        time.Unix(seg.CreatedAt, 0).UTC().Format(time.RFC3339),
+			UpdatedAt:        time.Unix(seg.UpdatedAt, 0).UTC().Format(time.RFC3339),
+			SyncedAt:         synced,
+		}
+		if len(row.Data) == 0 {
+			row.Data = json.RawMessage("null")
+		}
+		if err := enc.Encode(row); err != nil {
+			return Batch{}, fmt.Errorf("encode segmentation %d: %w", seg.ID, err)
+		}
+	}
+	return Batch{Rows: len(segs), Body: buf.Bytes()}, nil
+}
+
+// encodeTombstones writes a tombstone per erasure as the NDJSON of a batch
+func encodeTombstones(erasures []models.Erasure, syncedAt time.Time) (Batch, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	synced := syncedAt.UTC().Format(time.RFC3339)
+	for _, e := range erasures {
+		erased := e.CreatedAt.String()
+		row := Row{
+			UserID:    e.UserID,
+			Data:      json.RawMessage("null"),
+			CreatedAt: erased,
+			UpdatedAt: erased,
+			SyncedAt:  synced,
+			ErasedAt:  erased,
+		}
+		if err := enc.Encode(row); err != nil {
+			return Batch{}, fmt.Errorf("encode erasure %d: %w", e.ID, err)
+		}
+	}
+	return Batch{Rows: len(erasures), Body: buf.Bytes()}, nil
+}
@@ -0,0 +1,193 @@
+package warehouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+// feed is a change feed over rows kept in (updated_at, id) order; it only
+// understands the updated_at filter of the syncer
+type feed []models.Segmentation
+
+func (f feed) Changes(ctx context.Context, after repository.ChangeCursor, filter repository.Filter, limit int) ([]models.Segmentation, error) {
+	until, _ := strconv.ParseInt(filter[0].Values[0], 10, 64)
+	var out []models.Segmentation
+	for _, s := range f {
+		later := s.UpdatedAt > after.UpdatedAt || s.UpdatedAt == after.UpdatedAt && s.ID > after.ID
+		if later && s.UpdatedAt < until && len(out) < limit {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+type watermarks map[string]models.SyncWatermark
+
+func (w watermarks) Get(ctx context.Context, name string) (*models.SyncWatermark, error) {
+	wm, ok := w[name]
+	if !ok {
+		return nil, nil
+	}
+	return &wm, nil
+}
+
+func (w watermarks) Save(ctx context.Context, wm *models.SyncWatermark) error {
+	w[wm.Name] = *wm
+	return nil
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	rows := feed{
+		{ID: 3, UserID: 1, SegmentationType: "drug", SegmentationName: "Aspirina", Data: []byte(`{"dose":"500mg"}`), CreatedAt: 100, UpdatedAt: 100},
+		{ID: 1, UserID: 2, SegmentationType: "drug", SegmentationName: "Dipirona", CreatedAt: 90, UpdatedAt: 200},
+		{ID: 2, UserID: 2, SegmentationType: "specialty", SegmentationName: "Cardiologia", CreatedAt: 90, UpdatedAt: 200},
+		{ID: 4, UserID: 3, SegmentationType: "drug", SegmentationName: "Losartana", CreatedAt: 300, UpdatedAt: 300},
+		// within the lag: left for the next sync
+		{ID: 5, UserID: 4, SegmentationType: "drug", SegmentationName: "Metformina", CreatedAt: now.Unix() - 10, UpdatedAt: now.Unix() - 10},
+	}
+	marks := watermarks{}
+	var batches []Batch
+	loader := LoaderFunc(func(ctx context.Context, b Batch) error {
+		batches = append(batches, b)
+		return nil
+	})
+
+	s := NewSyncer("warehouse:test", rows, marks, loader, WithBatchRows(2), WithLag(time.Minute))
+	s.now = func() time.Time { return now }
+
+	n, err := s.Sync(context.Background())
+	if err != nil || n != 4 {
+		t.Fatalf("Sync() = %d, %v, want 4 rows", n, err)
+	}
+	if len(batches) != 2 || batches[0].Rows != 2 || batches[1].Rows != 2 || batches[0].ID == batches[1].ID {
+		t.Fatalf("unexpected batches: %+v", batches)
+	}
+	if wm := marks["warehouse:test"]; wm.LastUpdatedAt != 300 || wm.LastID != 4 || wm.SyncedRows != 4 || wm.SyncedAt != now.Unix() {
+		t.Errorf("unexpected watermark: %+v", wm)
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(batches[0].Body))
+	sc.Scan()
+	var row Row
+	if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+		t.Fatal(err)
+	}
+	if row.ID != 3 || row.UserID != 1 || string(row.Data) != `{"dose":"500mg"}` ||
+		row.UpdatedAt != "1970-01-01T00:01:40Z" || row.SyncedAt != "2026-10-17T12:00:00Z" {
+		t.Errorf("unexpected row: %+v", row)
+	}
+	sc.Scan()
+	if err := json.Unmarshal(sc.Bytes(), &row); err != nil || string(row.Data) != "null" {
+		t.Errorf("a row without data should load null, got %s (%v)", row.Data, err)
+	}
+
+	// nothing new: nothing loaded
+	batches = nil
+	if n, err := s.Sync(context.Background()); n != 0 || err != nil || len(batches) != 0 {
+		t.Errorf("second Sync() = %d, %v with %d batches", n, err, len(batches))
+	}
+}
+
+func TestSyncer_FailedLoadKeepsWatermark(t *testing.T) {
+	rows := feed{
+		{ID: 1, UpdatedAt: 100},
+		{ID: 2, UpdatedAt: 200},
+		{ID: 3, UpdatedAt: 300},
+	}
+	marks := watermarks{}
+	fail := true
+	var loaded []int
+	loader := LoaderFunc(func(ctx context.Context, b Batch) error {
+		if fail && len(loaded) == 1 {
+			return errors.New("warehouse unavailable")
+		}
+		loaded = append(loaded, b.Rows)
+		return nil
+	})
+	s := NewSyncer("warehouse:test", rows, marks, loader, WithBatchRows(2), WithLag(0))
+
+	if n, err := s.Sync(context.Background()); err == nil || n != 2 {
+		t.Fatalf("Sync() = %d, %v, want the error of the second batch after 2 rows", n, err)
+	}
+	if wm := marks["warehouse:test"]; wm.LastID != 2 {
+		t.Errorf("the watermark should stop at the last loaded row, got %+v", wm)
+	}
+
+	fail = false
+	if n, err := s.Sync(context.Background()); err != nil || n != 1 {
+		t.Fatalf("retried Sync() = %d, %v, want the remaining row", n, err)
+	}
+	if wm := marks["warehouse:test"]; wm.LastID != 3 || wm.SyncedRows != 3 {
+		t.Errorf("unexpected watermark: %+v", wm)
+	}
+}
+
+// erasureLog lists erasure records in ID order
+type erasureLog []models.Erasure
+
+func (l erasureLog) Erase(ctx context.Context, erasure *models.Erasure) ([]models.Segmentation, error) {
+	return nil, errors.New("not supported")
+}
+
+func (l erasureLog) List(ctx context.Context, filter repository.ErasureFilter) ([]models.Erasure, error) {
+	var out []models.Erasure
+	for _, e := range l {
+		if e.ID > filter.AfterID && len(out) < filter.Limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func TestSyncer_SyncsErasureTombstones(t *testing.T) {
+	erasures := erasureLog{
+		{ID: 1, UserID: 7, CreatedAt: 500},
+		{ID: 2, UserID: 8, CreatedAt: 600},
+		{ID: 3, UserID: 9, CreatedAt: 700},
+	}
+	marks := watermarks{}
+	var batches []Batch
+	loader := LoaderFunc(func(ctx context.Context, b Batch) error {
+		batches = append(batches, b)
+		return nil
+	})
+	s := NewSyncer("warehouse:test", feed{{ID: 1, UserID: 1, UpdatedAt: 100}}, marks, loader,
+		WithBatchRows(2), WithLag(0), WithErasures(erasures[:2]))
+
+	if n, err := s.Sync(context.Background()); err != nil || n != 3 {
+		t.Fatalf("Sync() = %d, %v, want 1 row and 2 tombstones", n, err)
+	}
+	if len(batches) != 2 || batches[1].Rows != 2 {
+		t.Fatalf("unexpected batches: %+v", batches)
+	}
+	if wm := marks["warehouse:test:erasures"]; wm.LastID != 2 || wm.SyncedRows != 2 {
+		t.Errorf("unexpected erasures watermark: %+v", wm)
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(batches[1].Body))
+	sc.Scan()
+	var row Row
+	if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+		t.Fatal(err)
+	}
+	if row.UserID != 7 || row.ErasedAt != "1970-01-01T00:08:20Z" || row.UpdatedAt != row.ErasedAt || row.SegmentationName != "" {
+		t.Errorf("unexpected tombstone: %+v", row)
+	}
+
+	// only the records added since are loaded
+	s.erasures = erasures
+	batches = nil
+	if n, err := s.Sync(context.Background()); err != nil || n != 1 || len(batches) != 1 || batches[0].Rows != 1 {
+		t.Errorf("second Sync() = %d, %v with batches %+v", n, err, batches)
+	}
+}
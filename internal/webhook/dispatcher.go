@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+)
+
+const (
+	// retryBase is the wait after the first failed attempt, doubled after
+	// each one up to retryMax
+	retryBase = 10 * time.Second
+	retryMax  = time.Hour
+	// concurrency is how many deliveries are sent at a time
+	concurrency = 8
+	// purgeInterval is how often finished deliveries past the retention
+	// are deleted
+	purgeInterval = time.Hour
+	// maxErrorBytes is how much of a refusal's body is kept as its error
+	maxErrorBytes = 512
+	userAgent     = "segmentation-api-webhooks"
+)
+
+// Dispatcher sends the pending deliveries of the webhooks
+type Dispatcher struct {
+	repo        repository.WebhookRepository
+	client      *http.Client
+	maxAttempts int
+	batchSize   int
+	interval    time.Duration
+	retention   time.Duration
+	logger      *zap.Logger
+	now         func() time.Time
+}
+
+// Option customizes a Dispatcher
+type Option func(*Dispatcher)
+
+// WithTimeout bounds each delivery attempt (default 10s)
+func WithTimeout(d time.Duration) Option {
+	return func(disp *Dispatcher) {
+		if d > 0 {
+			disp.client.Timeout = d
+		}
+	}
+}
+
+// WithMaxAttempts sets after how many failed attempts a delivery is
+// given up as failed (default 10)
+func WithMaxAttempts(n int) Option {
+	return func(disp *Dispatcher) {
+		if n > 0 {
+			disp.maxAttempts = n
+		}
+	}
+}
+
+// WithInterval sets how often the dispatcher looks for due deliveries
+// (default 1s)
+func WithInterval(d time.Duration) Option {
+	return func(disp *Dispatcher) {
+		if d > 0 {
+			disp.interval = d
+		}
+	}
+}
+
+// WithRetention deletes finished deliveries older than d; 0 (the default)
+// keeps them
+func WithRetention(d time.Duration) Option {
+	return func(disp *Dispatcher) {
+		disp.retention = max(0, d)
+	}
+}
+
+// WithLogger sets the logger of the dispatcher; by default nothing is
+// logged
+func WithLogger(logger *zap.Logger) Option {
+	return func(disp *Dispatcher) {
+		disp.logger = logger
+	}
+}
+
+// NewDispatcher creates a dispatcher of the deliveries in repo
+func NewDispatcher(repo repository.WebhookRepository, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		repo:        repo,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 10,
+		batchSize:   100,
+		interval:    time.Second,
+		logger:      zap.NewNop(),
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run sends the due deliveries until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	var lastPurge time.Time
+	for ctx.Err() == nil {
+		wait := d.interval
+		n, err := d.DispatchOnce(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			d.logger.Warn("webhook_dispatch_failed", zap.Error(err))
+		} else if n == d.batchSize {
+			wait = 0
+		}
+
+		if d.retention > 0 && d.now().Sub(lastPurge) >= purgeInterval {
+			lastPurge = d.now()
+			d.purge(ctx)
+		}
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// DispatchOnce attempts one batch of due deliveries and records the
+// outcome of each, returning how many were attempted
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	due, err := d.repo.Due(ctx, d.now().Unix(), d.batchSize)
+	if err != nil || len(due) == 0 {
+		return 0, err
+	}
+	hooks, err := d.repo.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[uint64]models.Webhook, len(hooks))
+	for _, w := range hooks {
+		byID[w.ID] = w
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var saveErr error
+	sem := make(chan struct{}, concurrency)
+	for i := range due {
+		w, ok := byID[due[i].WebhookID]
+		if !ok {
+			continue // deleted since Due
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if !d.attempt(ctx, w, delivery) {
+				return
+			}
+			if err := d.repo.SaveDelivery(ctx, delivery); err != nil {
+				mu.Lock()
+				saveErr = err
+				mu.Unlock()
+			}
+		}(&due[i])
+	}
+	wg.Wait()
+	return len(due), saveErr
+}
+
+// attempt sends a delivery once and updates it with the outcome; false
+// when the dispatcher stopped during the attempt, which then does not count
+func (d *Dispatcher) attempt(ctx context.Context, w models.Webhook, delivery *models.WebhookDelivery) bool {
+	status, err := d.send(ctx, w, delivery)
+	if err != nil && ctx.Err() != nil {
+		return false
+	}
+	now := d.now()
+	delivery.Attempts++
+	delivery.LastStatusCode = status
+	if err == nil {
+		at := now.Unix()
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &at
+		return true
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.maxAttempts {
+		delivery.Status = models.DeliveryFailed
+		d.logger.Warn("webhook_delivery_failed",
+			zap.Uint64("webhook_id", w.ID),
+			zap.Uint64("delivery_id", delivery.ID),
+			zap.Int("attempts", delivery.Attempts),
+			zap.Error(err),
+		)
+		return true
+	}
+	delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts)).Unix()
+	d.logger.Debug("webhook_delivery_retry",
+		zap.Uint64("webhook_id", w.ID),
+		zap.Uint64("delivery_id", delivery.ID),
+		zap.Int("attempts", delivery.Attempts),
+		zap.Error(err),
+	)
+	return true
+}
+
+// send POSTs the delivery in the format of the webhook; a response other
+// than 2xx is an error
+func (d *Dispatcher) send(ctx context.Context, w models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body, contentType, err := encode(w.BodyFormat(), delivery.Payload)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(w.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %d: %s", resp.StatusCode, bytes.TrimSpace(answer))
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay is the wait after the attempts-th failed attempt
+func retryDelay(attempts int) time.Duration {
+	return min(retryBase<<min(attempts-1, 20), retryMax)
+}
+
+// purge deletes the finished deliveries created more than the retention
+// ago
+func (d *Dispatcher) purge(ctx context.Context) {
+	n, err := d.repo.DeleteFinished(ctx, d.now().Add(-d.retention).Unix())
+	if err != nil {
+		d.logger.Error("webhook_purge_error", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		d.logger.Info("webhook_deliveries_purged", zap.Int64("deleted", n))
+	}
+}
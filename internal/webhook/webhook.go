@@ -0,0 +1,152 @@
+// Package webhook delivers the change events of the outbox to the
+// registered webhooks. Fanout is the outbox Publisher: it records one
+// pending delivery per event and subscribed webhook. The Dispatcher POSTs
+// the pending deliveries, signed with the secret of their webhook, and
+// retries the failed ones with exponential backoff until they run out of
+// attempts. Deliveries are stored as JSON and encoded in the format of
+// their webhook when sent.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/outbox"
+	"segmentation-api/internal/repository"
+	pb "segmentation-api/pkg/segmentationpb"
+)
+
+// Headers of a delivery
+const (
+	HeaderEvent     = "X-Segmentation-Event"
+	HeaderDelivery  = "X-Segmentation-Delivery"
+	HeaderTimestamp = "X-Segmentation-Timestamp"
+	// HeaderSignature is "sha256=" and the hex HMAC-SHA256, keyed with the
+	// webhook secret, of the timestamp, a dot and the body
+	HeaderSignature = "X-Segmentation-Signature"
+)
+
+// Payload is the body of a delivery
+type Payload struct {
+	// EventID identifies the event; a retried delivery carries the same one
+	EventID          uint64          `json:"event_id"`
+	EventType        string          `json:"event_type"`
+	UserID           uint64          `json:"user_id"`
+	SegmentationType string          `json:"segmentation_type"`
+	SegmentationName string          `json:"segmentation_name"`
+	Data             json.RawMessage `json:"data,omitempty"`
+	OccurredAt       time.Time       `json:"occurred_at"`
+	// RunID is the processor run that made the change; empty for API writes
+	RunID string `json:"run_id,omitempty"`
+}
+
+// ContentTypeProtobuf is the Content-Type of the protobuf deliveries
+const ContentTypeProtobuf = "application/x-protobuf"
+
+// Proto returns the payload as the segmentation.v1.SegmentationEvent
+// message of the protobuf deliveries
+func (p Payload) Proto() *pb.SegmentationEvent {
+	return &pb.SegmentationEvent{
+		EventId:          p.EventID,
+		EventType:        p.EventType,
+		UserId:           p.UserID,
+		SegmentationType: p.SegmentationType,
+		SegmentationName: p.SegmentationName,
+		Data:             string(p.Data),
+		OccurredAt:       timestamppb.New(p.OccurredAt),
+		RunId:            p.RunID,
+	}
+}
+
+// encode returns the body of a stored JSON payload in format, and its
+// Content-Type
+func encode(format string, payload datatypes.JSON) ([]byte, string, error) {
+	if format != models.WebhookFormatProtobuf {
+		return payload, "application/json", nil
+	}
+	var p Payload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, "", fmt.Errorf("decode payload: %w", err)
+	}
+	body, err := proto.Marshal(p.Proto())
+	if err != nil {
+		return nil, "", err
+	}
+	return body, ContentTypeProtobuf, nil
+}
+
+// Sign returns the signature header of body sent at timestamp (unix)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewFanout returns the outbox Publisher that records a delivery of each
+// event to every active webhook subscribed to its type
+func NewFanout(repo repository.WebhookRepository) outbox.Publisher {
+	return outbox.PublisherFunc(func(ctx context.Context, events []models.OutboxEvent) error {
+		hooks, err := repo.List(ctx)
+		if err != nil {
+			return err
+		}
+		hooks = slices.DeleteFunc(hooks, func(w models.Webhook) bool { return !w.Active })
+		if len(hooks) == 0 {
+			return nil
+		}
+
+		now := time.Now().Unix()
+		var deliveries []models.WebhookDelivery
+		for _, e := range events {
+			var payload datatypes.JSON
+			for _, w := range hooks {
+				if !slices.Contains(w.Subscribed(), e.EventType) {
+					continue
+				}
+				if payload == nil {
+					if payload, err = json.Marshal(PayloadOf(e)); err != nil {
+						return err
+					}
+				}
+				deliveries = append(deliveries, models.WebhookDelivery{
+					WebhookID:     w.ID,
+					EventID:       e.ID,
+					EventType:     e.EventType,
+					Payload:       payload,
+					Status:        models.DeliveryPending,
+					NextAttemptAt: now,
+					CreatedAt:     now,
+				})
+			}
+		}
+		return repo.Enqueue(ctx, deliveries)
+	})
+}
+
+// PayloadOf returns the payload of an event
+func PayloadOf(e models.OutboxEvent) Payload {
+	return Payload{
+		EventID:          e.ID,
+		EventType:        e.EventType,
+		UserID:           e.UserID,
+		SegmentationType: e.SegmentationType,
+		SegmentationName: e.SegmentationName,
+		Data:             json.RawMessage(e.Data),
+		OccurredAt:       time.Unix(e.CreatedAt, 0).UTC(),
+		RunID:            e.RunID,
+	}
+}
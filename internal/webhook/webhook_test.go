@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	pb "segmentation-api/pkg/segmentationpb"
+)
+
+// memoryWebhooks is a WebhookRepository in memory
+type memoryWebhooks struct {
+	mu         sync.Mutex
+	hooks      []models.Webhook
+	deliveries []models.WebhookDelivery
+}
+
+func (m *memoryWebhooks) List(ctx context.Context) ([]models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Webhook{}, m.hooks...), nil
+}
+
+func (m *memoryWebhooks) Find(ctx context.Context, id uint64) (*models.Webhook, error) {
+	return nil, nil
+}
+
+func (m *memoryWebhooks) Create(ctx context.Context, w *models.Webhook) error { return nil }
+
+func (m *memoryWebhooks) Update(ctx context.Context, w *models.Webhook) error { return nil }
+
+func (m *memoryWebhooks) Delete(ctx context.Context, id uint64) (bool, error) { return false, nil }
+
+func (m *memoryWebhooks) Enqueue(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+next:
+	for _, d := range deliveries {
+		for _, e := range m.deliveries {
+			if e.WebhookID == d.WebhookID && e.EventID == d.EventID {
+				continue next
+			}
+		}
+		d.ID = uint64(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, d)
+	}
+	return nil
+}
+
+func (m *memoryWebhooks) Due(ctx context.Context, now int64, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == models.DeliveryPending && d.NextAttemptAt <= now && len(out) < limit {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryWebhooks) Deliveries(ctx context.Context, filter repository.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *memoryWebhooks) FindDelivery(ctx context.Context, webhookID, id uint64) (*models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *memoryWebhooks) SaveDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[d.ID-1] = *d
+	return nil
+}
+
+func (m *memoryWebhooks) DeleteFinished(ctx context.Context, before int64) (int64, error) {
+	return 0, nil
+}
+
+func TestFanout(t *testing.T) {
+	repo := &memoryWebhooks{hooks: []models.Webhook{
+		{ID: 1, Active: true},
+		{ID: 2, Active: true, EventTypes: models.OutboxDeleted},
+		{ID: 3, Active: false},
+	}}
+	events := []models.OutboxEvent{
+		{ID: 10, EventType: models.OutboxUpserted, UserID: 7, SegmentationType: "drug", SegmentationName: "Aspirina", Data: datatypes.JSON(`{"dose":"500mg"}`), CreatedAt: 1_700_000_000, RunID: "run-1"},
+		{ID: 11, EventType: models.OutboxDeleted, UserID: 7, SegmentationType: "drug", SegmentationName: "Dipirona", CreatedAt: 1_700_000_001},
+	}
+
+	fanout := NewFanout(repo)
+	if err := fanout.Publish(context.Background(), events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	// a republished batch is not delivered twice
+	if err := fanout.Publish(context.Background(), events); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	got := map[[2]uint64]bool{}
+	for _, d := range repo.deliveries {
+		got[[2]uint64{d.WebhookID, d.EventID}] = true
+	}
+	if len(repo.deliveries) != 3 || !got[[2]uint64{1, 10}] || !got[[2]uint64{1, 11}] || !got[[2]uint64{2, 11}] {
+		t.Fatalf("unexpected deliveries %+v", repo.deliveries)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(repo.deliveries[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.EventID != 10 || payload.UserID != 7 || string(payload.Data) != `{"dose":"500mg"}` ||
+		!payload.OccurredAt.Equal(time.Unix(1_700_000_000, 0)) || payload.RunID != "run-1" {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get(HeaderSignature) != Sign("s3cr3t-s3cr3t-s3cr3t", ts, body) {
+			t.Error("the delivery should be signed with the webhook secret")
+		}
+		if r.Header.Get(HeaderEvent) != models.OutboxUpserted || r.Header.Get(HeaderDelivery) != "1" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "try later")
+		}
+	}))
+	defer srv.Close()
+
+	repo := &memoryWebhooks{hooks: []models.Webhook{{ID: 1, URL: srv.URL, Secret: "s3cr3t-s3cr3t-s3cr3t", Active: true}}}
+	repo.Enqueue(context.Background(), []models.WebhookDelivery{
+		{WebhookID: 1, EventID: 10, EventType: models.OutboxUpserted, Payload: datatypes.JSON(`{"event_id":10}`), Status: models.DeliveryPending},
+	})
+	now := time.Unix(1_700_000_000, 0)
+	d := NewDispatcher(repo)
+	d.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if n, err := d.DispatchOnce(ctx); n != 1 || err != nil {
+		t.Fatalf("DispatchOnce() = %d, %v", n, err)
+	}
+	got := repo.deliveries[0]
+	if got.Status != models.DeliveryPending || got.Attempts != 1 || got.LastStatusCode != 503 ||
+		got.LastError != "webhook answered 503: try later" || got.NextAttemptAt != now.Add(retryBase).Unix() {
+		t.Fatalf("a refused delivery should be retried later: %+v", got)
+	}
+	if n, _ := d.DispatchOnce(ctx); n != 0 {
+		t.Fatal("a delivery should wait for its next attempt")
+	}
+
+	now = now.Add(retryBase)
+	if n, err := d.DispatchOnce(ctx); n != 1 || err != nil {
+		t.Fatalf("DispatchOnce() = %d, %v", n, err)
+	}
+	got = repo.deliveries[0]
+	if got.Status != models.DeliveryDelivered || got.Attempts != 2 || got.LastError != "" ||
+		got.DeliveredAt == nil || *got.DeliveredAt != now.Unix() {
+		t.Errorf("unexpected delivery %+v", got)
+	}
+}
+
+func TestDispatcher_GivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	repo := &memoryWebhooks{hooks: []models.Webhook{{ID: 1, URL: srv.URL, Active: true}}}
+	repo.Enqueue(context.Background(), []models.WebhookDelivery{{WebhookID: 1, EventID: 10, Status: models.DeliveryPending}})
+	now := time.Unix(1_700_000_000, 0)
+	d := NewDispatcher(repo, WithMaxAttempts(2))
+	d.now = func() time.Time { return now }
+
+	for range 2 {
+		d.DispatchOnce(context.Background())
+		now = now.Add(retryMax)
+	}
+	if got := repo.deliveries[0]; got.Status != models.DeliveryFailed || got.Attempts != 2 || got.LastStatusCode != 410 {
+		t.Errorf("the delivery should fail after its last attempt: %+v", got)
+	}
+}
+
+func TestDispatcher_Protobuf(t *testing.T) {
+	received := make(chan *pb.SegmentationEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get("Content-Type") != ContentTypeProtobuf || r.Header.Get(HeaderSignature) != Sign("s3cr3t-s3cr3t-s3cr3t", ts, body) {
+			t.Errorf("the protobuf body should be typed and signed: %v", r.Header)
+		}
+		var event pb.SegmentationEvent
+		if err := proto.Unmarshal(body, &event); err != nil {
+			t.Error(err)
+		}
+		received <- &event
+	}))
+	defer srv.Close()
+
+	repo := &memoryWebhooks{hooks: []models.Webhook{
+		{ID: 1, URL: srv.URL, Secret: "s3cr3t-s3cr3t-s3cr3t", Format: models.WebhookFormatProtobuf, Active: true},
+	}}
+	event := models.OutboxEvent{ID: 10, EventType: models.OutboxUpserted, UserID: 7, SegmentationType: "drug", SegmentationName: "Aspirina", Data: datatypes.JSON(`{"dose":"500mg"}`), CreatedAt: 1_700_000_000, RunID: "run-1"}
+	if err := NewFanout(repo).Publish(context.Background(), []models.OutboxEvent{event}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := NewDispatcher(repo).DispatchOnce(context.Background()); n != 1 || err != nil {
+		t.Fatalf("DispatchOnce() = %d, %v", n, err)
+	}
+
+	got := <-received
+	if got.EventId != 10 || got.EventType != models.OutboxUpserted || got.UserId != 7 || got.SegmentationName != "Aspirina" ||
+		got.Data != `{"dose":"500mg"}` || got.OccurredAt.GetSeconds() != 1_700_000_000 || got.RunId != "run-1" {
+		t.Errorf("unexpected event %v", got)
+	}
+	if repo.deliveries[0].Status != models.DeliveryDelivered {
+		t.Errorf("unexpected delivery %+v", repo.deliveries[0])
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 4: 80 * time.Second, 12: time.Hour, 100: time.Hour} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
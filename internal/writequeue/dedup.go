@@ -0,0 +1,110 @@
+package writequeue
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// WithDedup skips the items of an entry that repeat, with the same data,
+// an item flushed less than ttl ago for the same user, type and name, so a
+// write delivered twice (a client retrying a POST whose answer it missed,
+// a producer with at-least-once delivery) is written to MySQL once. The
+// fingerprints live in memory: entries replayed after a restart are
+// written again. 0 disables deduplication.
+func WithDedup(ttl time.Duration) Option {
+	return func(q *Queue) {
+		if ttl > 0 {
+			q.dedup = newFingerprints(ttl)
+		}
+	}
+}
+
+// recordKey identifies the row an item writes
+type recordKey struct {
+	userID  uint64
+	segType string
+	name    string
+}
+
+type fingerprint struct {
+	hash    [sha256.Size]byte
+	expires time.Time
+}
+
+// fingerprints remembers the hash of the last item flushed for each row
+// until it expires
+type fingerprints struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	seen      map[recordKey]fingerprint
+	lastSweep time.Time
+}
+
+func newFingerprints(ttl time.Duration) *fingerprints {
+	return &fingerprints{ttl: ttl, now: time.Now, seen: make(map[recordKey]fingerprint)}
+}
+
+// filter returns the items that are not duplicates and their index in
+// items
+func (f *fingerprints) filter(items []item) ([]item, []int) {
+	kept := make([]item, 0, len(items))
+	index := make([]int, 0, len(items))
+	for i, it := range items {
+		if !f.duplicate(it) {
+			kept = append(kept, it)
+			index = append(index, i)
+		}
+	}
+	return kept, index
+}
+
+func (it item) key() recordKey {
+	return recordKey{userID: it.UserID, segType: it.Type, name: it.Name}
+}
+
+func (it item) hash() [sha256.Size]byte {
+	return sha256.Sum256(it.Data)
+}
+
+// duplicate reports whether it repeats the last item flushed for its row
+// within the ttl
+func (f *fingerprints) duplicate(it item) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fp, ok := f.seen[it.key()]
+	return ok && f.now().Before(fp.expires) && fp.hash == it.hash()
+}
+
+// record remembers items as flushed, dropping the expired fingerprints at
+// most once per ttl
+func (f *fingerprints) record(items []item) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	if now.Sub(f.lastSweep) >= f.ttl {
+		for k, fp := range f.seen {
+			if !now.Before(fp.expires) {
+				delete(f.seen, k)
+			}
+		}
+		f.lastSweep = now
+	}
+	for _, it := range items {
+		f.seen[it.key()] = fingerprint{hash: it.hash(), expires: now.Add(f.ttl)}
+	}
+}
+
+// forget drops the fingerprints of userID, whose rows were erased: the
+// same write accepted again must be written
+func (f *fingerprints) forget(userID uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k := range f.seen {
+		if k.userID == userID {
+			delete(f.seen, k)
+		}
+	}
+}
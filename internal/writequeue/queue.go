@@ -0,0 +1,622 @@
+// Package writequeue is a durable local queue of segmentation writes. The
+// API appends accepted writes to a log file on disk and answers right away;
+// a background flusher writes them to MySQL in order, so spikes are
+// absorbed by the disk instead of the database.
+package writequeue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/apperrors"
+	"segmentation-api/internal/models"
+)
+
+const (
+	logFile    = "queue.log"
+	offsetFile = "queue.offset"
+
+	// compactAbove is the size of the flushed part of the log above which
+	// it is rewritten with the pending entries only
+	compactAbove = 64 << 20
+	// keepFinished is how many finished writes keep their status
+	keepFinished = 10000
+
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+var (
+	// ErrFull is returned by Enqueue when MaxPending writes are waiting
+	ErrFull = apperrors.New("write queue is full", apperrors.ErrUnavailable)
+	// ErrClosed is returned by Enqueue after Close
+	ErrClosed = apperrors.New("write queue is closed", apperrors.ErrUnavailable)
+)
+
+// State is the stage of a queued write
+type State string
+
+const (
+	StateQueued  State = "queued"
+	StateWritten State = "written"
+	// StatePurged is a write whose items were all dropped by Purge
+	StatePurged State = "purged"
+)
+
+// ItemError reports an item of a write that MySQL refused; Index counts
+// the queued items only, without the ones rejected by validation
+type ItemError struct {
+	Index            int    `json:"index"`
+	UserID           uint64 `json:"user_id"`
+	SegmentationType string `json:"segmentation_type"`
+	SegmentationName string `json:"segmentation_name"`
+	Error            string `json:"error"`
+}
+
+// Status describes a write accepted by the queue
+type Status struct {
+	ID    string `json:"id"`
+	State State  `json:"state"`
+	Items int    `json:"items"`
+	// Failed and Errors report the items refused once written
+	Failed int         `json:"failed"`
+	Errors []ItemError `json:"errors,omitempty"`
+	// Duplicates counts the items skipped by WithDedup
+	Duplicates int `json:"duplicates,omitempty"`
+	// Purged counts the items dropped by Purge before being written
+	Purged int `json:"purged,omitempty"`
+	// Attempts and LastError report failed flushes, retried with backoff
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error,omitempty"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	WrittenAt  time.Time `json:"written_at,omitzero"`
+}
+
+// FlushFunc writes the items of an entry. Items it cannot write are
+// returned as ItemErrors and the entry is done; an error leaves the entry
+// at the head of the queue to be retried.
+type FlushFunc func(ctx context.Context, items []models.Segmentation) ([]ItemError, error)
+
+// Option customizes a Queue
+type Option func(*Queue)
+
+// WithMaxPending makes Enqueue refuse writes with ErrFull while n writes
+// are waiting to be flushed; 0 means no limit
+func WithMaxPending(n int) Option {
+	return func(q *Queue) {
+		q.maxPending = n
+	}
+}
+
+// WithLogger sets the logger of the flusher; the default discards everything
+func WithLogger(logger *zap.Logger) Option {
+	return func(q *Queue) {
+		q.logger = logger
+	}
+}
+
+// entry is a write in the log
+type entry struct {
+	ID         string    `json:"id"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	Items      []item    `json:"items"`
+}
+
+type item struct {
+	UserID uint64          `json:"user_id"`
+	Type   string          `json:"segmentation_type"`
+	Name   string          `json:"segmentation_name"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// pending is an entry waiting to be flushed and its size in the log
+type pending struct {
+	entry
+	size int64
+}
+
+// Queue is a durable FIFO of writes: an append-only log and the offset of
+// the entries already flushed. Writes are synced to disk before Enqueue
+// returns, and the entries past the offset are replayed by Open after a
+// restart or crash.
+type Queue struct {
+	dir        string
+	maxPending int
+	logger     *zap.Logger
+	dedup      *fingerprints
+	wake       chan struct{}
+	// flushing is held by Run while it writes the head, so Purge waits
+	// for a write already under way
+	flushing sync.Mutex
+
+	mu       sync.Mutex
+	log      *os.File
+	size     int64 // bytes in the log
+	offset   int64 // bytes of the log already flushed
+	pending  []pending
+	statuses map[string]*Status
+	finished []string // IDs of finished writes, oldest first
+	closed   bool
+}
+
+// Open opens or creates the queue in dir and loads the entries not flushed
+// yet. A last entry cut short by a crash was never acknowledged and is
+// dropped.
+func Open(dir string, opts ...Option) (*Queue, error) {
+	q := &Queue{
+		dir:      dir,
+		logger:   zap.NewNop(),
+		wake:     make(chan struct{}, 1),
+		statuses: make(map[string]*Status),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	offset, err := readOffset(filepath.Join(dir, offsetFile))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	q.log = f
+	if err := q.load(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if len(q.pending) > 0 {
+		q.logger.Info("write_queue_replayed", zap.Int("pending", len(q.pending)))
+		q.signal()
+	}
+	return q, nil
+}
+
+// load reads the entries past offset and positions the log for appends
+func (q *Queue) load(offset int64) error {
+	info, err := q.log.Stat()
+	if err != nil {
+		return err
+	}
+	if offset > info.Size() {
+		return fmt.Errorf("write queue offset %d is past the end of the log (%d bytes)", offset, info.Size())
+	}
+	if _, err := q.log.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(q.log)
+	pos := offset
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				q.logger.Warn("write_queue_truncated", zap.Int64("offset", pos), zap.Int("bytes", len(line)))
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("write queue entry at offset %d: %w", pos, err)
+		}
+		q.pending = append(q.pending, pending{entry: e, size: int64(len(line))})
+		q.statuses[e.ID] = &Status{ID: e.ID, State: StateQueued, Items: len(e.Items), AcceptedAt: e.AcceptedAt}
+		pos += int64(len(line))
+	}
+
+	if err := q.log.Truncate(pos); err != nil {
+		return err
+	}
+	if _, err := q.log.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	q.size, q.offset = pos, offset
+	return nil
+}
+
+// Enqueue appends a write to the log and returns once it is on disk
+func (q *Queue) Enqueue(items []models.Segmentation) (Status, error) {
+	e := entry{ID: uuid.NewString(), AcceptedAt: time.Now().UTC(), Items: make([]item, len(items))}
+	for i, s := range items {
+		e.Items[i] = item{UserID: s.UserID, Type: s.SegmentationType, Name: s.SegmentationName, Data: json.RawMessage(s.Data)}
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return Status{}, err
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return Status{}, ErrClosed
+	}
+	if q.maxPending > 0 && len(q.pending) >= q.maxPending {
+		return Status{}, ErrFull
+	}
+	if _, err := q.log.Write(line); err != nil {
+		q.rollback()
+		return Status{}, err
+	}
+	if err := q.log.Sync(); err != nil {
+		q.rollback()
+		return Status{}, err
+	}
+	q.size += int64(len(line))
+
+	q.pending = append(q.pending, pending{entry: e, size: int64(len(line))})
+	status := &Status{ID: e.ID, State: StateQueued, Items: len(items), AcceptedAt: e.AcceptedAt}
+	q.statuses[e.ID] = status
+	q.signal()
+	return *status, nil
+}
+
+// rollback drops a partial append so the next one starts on a line boundary
+func (q *Queue) rollback() {
+	q.log.Truncate(q.size)
+	q.log.Seek(q.size, io.SeekStart)
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the status of a write. Statuses live in memory: after a
+// restart only the writes still pending are known.
+func (q *Queue) Status(id string) (Status, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	status, ok := q.statuses[id]
+	if !ok {
+		return Status{}, false
+	}
+	return *status, true
+}
+
+// Pending is the number of writes waiting to be flushed
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Run flushes the entries in order until ctx is done. A failed flush is
+// retried with exponential backoff, keeping the entry at the head so writes
+// are applied in the order they were accepted.
+func (q *Queue) Run(ctx context.Context, flush FlushFunc) {
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		id, err := q.flushHead(ctx, flush)
+		switch {
+		case id == "":
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+				continue
+			}
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			q.failed(id, err)
+			q.logger.Warn("write_queue_flush_failed",
+				zap.String("id", id),
+				zap.Duration("retry_in", backoff),
+				zap.Error(err),
+			)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+	}
+}
+
+// flushHead writes the entry at the head of the queue and removes it,
+// returning its ID, or "" when nothing is pending. A failed write leaves
+// the entry at the head. It holds flushing, so Purge cannot change the
+// entry while it is being written.
+func (q *Queue) flushHead(ctx context.Context, flush FlushFunc) (string, error) {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+
+	head, ok := q.head()
+	if !ok {
+		return "", nil
+	}
+
+	items, index := head.Items, []int(nil)
+	if q.dedup != nil {
+		items, index = q.dedup.filter(head.Items)
+		if len(items) == 0 {
+			q.logger.Debug("write_queue_deduplicated", zap.String("id", head.ID), zap.Int("items", len(head.Items)))
+			if err := q.done(nil, len(head.Items)); err != nil {
+				q.logger.Error("write_queue_offset_failed", zap.Error(err))
+			}
+			return head.ID, nil
+		}
+	}
+
+	errs, err := flush(ctx, segmentations(items))
+	if err != nil {
+		return head.ID, err
+	}
+
+	if q.dedup != nil {
+		refused := make(map[int]bool, len(errs))
+		for i := range errs {
+			refused[errs[i].Index] = true
+			errs[i].Index = index[errs[i].Index]
+		}
+		written := items[:0:0]
+		for i, it := range items {
+			if !refused[i] {
+				written = append(written, it)
+			}
+		}
+		q.dedup.record(written)
+	}
+
+	if err := q.done(errs, len(head.Items)-len(items)); err != nil {
+		// the entry stays written; it is written again only if the
+		// process restarts before the offset is saved
+		q.logger.Error("write_queue_offset_failed", zap.Error(err))
+	}
+	return head.ID, nil
+}
+
+// Purge drops the pending items of userID, for the erasure of the user,
+// and rewrites the log so no copy of the user's writes is left in it,
+// flushed or not. A write of the user being flushed is waited for, so once
+// Purge returns no write accepted before it can write the user's rows.
+// It returns how many items were dropped.
+func (q *Queue) Purge(userID uint64) (int, error) {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var purged int
+	kept := q.pending[:0]
+	for _, p := range q.pending {
+		items := p.Items[:0:0]
+		for _, it := range p.Items {
+			if it.UserID != userID {
+				items = append(items, it)
+			}
+		}
+		dropped := len(p.Items) - len(items)
+		if dropped == 0 {
+			kept = append(kept, p)
+			continue
+		}
+		purged += dropped
+		status := q.statuses[p.ID]
+		if status != nil {
+			status.Purged = dropped
+		}
+		if len(items) > 0 {
+			p.Items = items
+			kept = append(kept, p)
+			continue
+		}
+		if status != nil {
+			status.State = StatePurged
+		}
+		q.finished = append(q.finished, p.ID)
+	}
+	q.pending = kept
+	if q.dedup != nil {
+		q.dedup.forget(userID)
+	}
+	for len(q.finished) > keepFinished {
+		delete(q.statuses, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+
+	if q.closed {
+		return purged, ErrClosed
+	}
+	// the flushed part of the log may hold the user's writes too
+	return purged, q.compact()
+}
+
+func (q *Queue) head() (pending, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return pending{}, false
+	}
+	return q.pending[0], true
+}
+
+func (q *Queue) failed(id string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if status, ok := q.statuses[id]; ok {
+		status.Attempts++
+		status.LastError = err.Error()
+	}
+}
+
+// done removes the flushed head, of which duplicates items were skipped,
+// and saves the new offset. The log is emptied when nothing is pending and
+// compacted when its flushed part grows past compactAbove.
+func (q *Queue) done(errs []ItemError, duplicates int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	head := q.pending[0]
+	q.pending = q.pending[1:]
+	q.offset += head.size
+
+	if status, ok := q.statuses[head.ID]; ok {
+		status.Attempts++
+		status.State = StateWritten
+		status.Failed = len(errs)
+		status.Errors = errs
+		status.Duplicates = duplicates
+		status.WrittenAt = time.Now().UTC()
+	}
+	q.finished = append(q.finished, head.ID)
+	if len(q.finished) > keepFinished {
+		delete(q.statuses, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+
+	switch {
+	case q.closed:
+		return nil
+	case len(q.pending) == 0:
+		// offset first: a crash in between replays flushed entries, which
+		// are upserts, instead of skipping pending ones
+		if err := writeOffset(q.dir, 0); err != nil {
+			return err
+		}
+		if err := q.log.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := q.log.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		q.size, q.offset = 0, 0
+		return nil
+	case q.offset > compactAbove:
+		return q.compact()
+	default:
+		return writeOffset(q.dir, q.offset)
+	}
+}
+
+// compact rewrites the log with the pending entries only
+func (q *Queue) compact() error {
+	tmp, err := os.CreateTemp(q.dir, logFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	var size int64
+	for i := range q.pending {
+		line, err := json.Marshal(q.pending[i].entry)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		line = append(line, '\n')
+		w.Write(line)
+		q.pending[i].size = int64(len(line))
+		size += int64(len(line))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	// same order as when emptying the log: replay too much, never too little
+	if err := writeOffset(q.dir, 0); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(q.dir, logFile)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(q.dir, logFile), os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	q.log.Close()
+	q.log, q.size, q.offset = f, size, 0
+	return nil
+}
+
+// Close stops accepting writes and closes the log. Pending entries stay on
+// disk for the next Open.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	if err := writeOffset(q.dir, q.offset); err != nil {
+		q.log.Close()
+		return err
+	}
+	return q.log.Close()
+}
+
+func segmentations(items []item) []models.Segmentation {
+	segs := make([]models.Segmentation, len(items))
+	for i, it := range items {
+		segs[i] = models.Segmentation{
+			UserID:           it.UserID,
+			SegmentationType: it.Type,
+			SegmentationName: it.Name,
+			Data:             datatypes.JSON(it.Data),
+		}
+	}
+	return segs
+}
+
+func readOffset(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid write queue offset in %s", path)
+	}
+	return offset, nil
+}
+
+// writeOffset replaces the offset file atomically
+func writeOffset(dir string, offset int64) error {
+	tmp := filepath.Join(dir, offsetFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, offsetFile))
+}
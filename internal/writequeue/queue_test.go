@@ -0,0 +1,344 @@
+package writequeue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+
+	"segmentation-api/internal/models"
+)
+
+func seg(userID uint64, name string) models.Segmentation {
+	return models.Segmentation{UserID: userID, SegmentationType: "drug", SegmentationName: name, Data: datatypes.JSON(`{"quantity":"1"}`)}
+}
+
+// recorder is a FlushFunc that records the items it wrote
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+	fail  int // flushes to fail before succeeding
+}
+
+func (r *recorder) flush(ctx context.Context, items []models.Segmentation) ([]ItemError, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail > 0 {
+		r.fail--
+		return nil, errors.New("database unavailable")
+	}
+	for _, s := range items {
+		r.names = append(r.names, s.SegmentationName)
+	}
+	return nil, nil
+}
+
+func (r *recorder) written() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueue_FlushesInOrder(t *testing.T) {
+	q, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	first, err := q.Enqueue([]models.Segmentation{seg(1, "a"), seg(2, "b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.State != StateQueued || first.Items != 2 || first.ID == "" {
+		t.Errorf("Enqueue() = %+v", first)
+	}
+	if _, err := q.Enqueue([]models.Segmentation{seg(1, "c")}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{fail: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, rec.flush)
+
+	waitFor(t, func() bool { return q.Pending() == 0 })
+	if got := rec.written(); len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("written = %v, want [a b c]", got)
+	}
+
+	status, ok := q.Status(first.ID)
+	if !ok || status.State != StateWritten || status.Attempts != 3 || status.LastError == "" || status.WrittenAt.IsZero() {
+		t.Errorf("Status() = %+v, %v", status, ok)
+	}
+
+	// an empty queue truncates the log
+	info, err := os.Stat(filepath.Join(q.dir, logFile))
+	if err != nil || info.Size() != 0 {
+		t.Errorf("log size = %v, %v; want 0", info.Size(), err)
+	}
+}
+
+func TestQueue_ReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue([]models.Segmentation{seg(1, name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// flush only the first entry, then stop
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, func(ctx context.Context, items []models.Segmentation) ([]ItemError, error) {
+			cancel()
+			return nil, nil
+		})
+	}()
+	<-done
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash in the middle of an append leaves a partial line
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"torn","items":[`)
+	f.Close()
+
+	q, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if q.Pending() != 2 {
+		t.Fatalf("Pending() = %d after reopen, want 2", q.Pending())
+	}
+	if _, err := q.Enqueue([]models.Segmentation{seg(1, "d")}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, rec.flush)
+	waitFor(t, func() bool { return q.Pending() == 0 })
+	if got := rec.written(); len(got) != 3 || got[0] != "b" || got[1] != "c" || got[2] != "d" {
+		t.Errorf("written = %v, want [b c d]", got)
+	}
+}
+
+func TestQueue_MaxPending(t *testing.T) {
+	q, err := Open(t.TempDir(), WithMaxPending(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue([]models.Segmentation{seg(1, "a")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue([]models.Segmentation{seg(1, "b")}); !errors.Is(err, ErrFull) {
+		t.Errorf("Enqueue() error = %v, want ErrFull", err)
+	}
+	q.Close()
+	if _, err := q.Enqueue([]models.Segmentation{seg(1, "c")}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue() after Close error = %v, want ErrClosed", err)
+	}
+}
+
+func TestQueue_ItemErrors(t *testing.T) {
+	q, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	status, err := q.Enqueue([]models.Segmentation{seg(1, "a"), seg(1, "b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, func(ctx context.Context, items []models.Segmentation) ([]ItemError, error) {
+		return []ItemError{{Index: 1, SegmentationName: items[1].SegmentationName, Error: "data too long"}}, nil
+	})
+	waitFor(t, func() bool { return q.Pending() == 0 })
+
+	status, _ = q.Status(status.ID)
+	if status.State != StateWritten || status.Failed != 1 || len(status.Errors) != 1 || status.Errors[0].SegmentationName != "b" {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestQueue_Dedup(t *testing.T) {
+	q, err := Open(t.TempDir(), WithDedup(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	changed := seg(1, "a")
+	changed.Data = datatypes.JSON(`{"quantity":"2"}`)
+	var ids []string
+	for _, items := range [][]models.Segmentation{
+		{seg(1, "a"), seg(2, "a"), seg(1, "bad")},
+		// redelivery of the first write: only the refused item is written
+		{seg(1, "a"), seg(2, "a"), seg(1, "bad")},
+		// the same as already written
+		{seg(2, "a")},
+		// new data for the same row
+		{changed},
+	} {
+		status, err := q.Enqueue(items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, status.ID)
+	}
+
+	var mu sync.Mutex
+	var written []string
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, func(ctx context.Context, items []models.Segmentation) ([]ItemError, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		var errs []ItemError
+		for i, s := range items {
+			if s.SegmentationName == "bad" {
+				errs = append(errs, ItemError{Index: i, SegmentationName: s.SegmentationName, Error: "refused"})
+				continue
+			}
+			written = append(written, fmt.Sprintf("%d/%s/%s", s.UserID, s.SegmentationName, s.Data))
+		}
+		return errs, nil
+	})
+	waitFor(t, func() bool { return q.Pending() == 0 })
+
+	mu.Lock()
+	got := strings.Join(written, " ")
+	mu.Unlock()
+	if want := `1/a/{"quantity":"1"} 2/a/{"quantity":"1"} 1/a/{"quantity":"2"}`; got != want {
+		t.Errorf("written = %s, want %s", got, want)
+	}
+
+	redelivered, _ := q.Status(ids[1])
+	if redelivered.Duplicates != 2 || redelivered.Failed != 1 || redelivered.Errors[0].Index != 2 {
+		t.Errorf("redelivered Status() = %+v", redelivered)
+	}
+	if repeated, _ := q.Status(ids[2]); repeated.State != StateWritten || repeated.Duplicates != 1 {
+		t.Errorf("repeated Status() = %+v", repeated)
+	}
+	if last, _ := q.Status(ids[3]); last.Duplicates != 0 {
+		t.Errorf("changed Status() = %+v", last)
+	}
+}
+
+func TestFingerprints_Expire(t *testing.T) {
+	now := time.Now()
+	f := newFingerprints(time.Minute)
+	f.now = func() time.Time { return now }
+
+	it := item{UserID: 1, Type: "drug", Name: "a", Data: []byte(`{}`)}
+	f.record([]item{it})
+	if !f.duplicate(it) {
+		t.Error("a recorded item should be a duplicate")
+	}
+
+	now = now.Add(time.Minute)
+	if f.duplicate(it) {
+		t.Error("an expired item should not be a duplicate")
+	}
+	f.record(nil)
+	if len(f.seen) != 0 {
+		t.Errorf("expired fingerprints = %d, want 0", len(f.seen))
+	}
+}
+
+func TestQueue_PurgeDropsTheWritesOfAUser(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	// flushed already: only a copy in the log
+	rec := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, rec.flush)
+	}()
+	if _, err := q.Enqueue([]models.Segmentation{seg(1, "a"), seg(2, "keep")}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return q.Pending() == 0 })
+	cancel()
+	<-done
+
+	mixed, err := q.Enqueue([]models.Segmentation{seg(1, "b"), seg(2, "c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	only, err := q.Enqueue([]models.Segmentation{seg(1, "d")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := q.Purge(1)
+	if err != nil || purged != 2 {
+		t.Fatalf("Purge() = %d, %v, want 2", purged, err)
+	}
+	if s, _ := q.Status(mixed.ID); s.State != StateQueued || s.Purged != 1 {
+		t.Errorf("status of the mixed write = %+v", s)
+	}
+	if s, _ := q.Status(only.ID); s.State != StatePurged || s.Purged != 1 {
+		t.Errorf("status of the purged write = %+v", s)
+	}
+	if q.Pending() != 1 {
+		t.Errorf("Pending() = %d, want 1", q.Pending())
+	}
+	log, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(log), `"user_id":1,`) {
+		t.Errorf("the log still holds writes of the user:\n%s", log)
+	}
+
+	rec = &recorder{}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, rec.flush)
+	waitFor(t, func() bool { return q.Pending() == 0 })
+	if got := rec.written(); len(got) != 1 || got[0] != "c" {
+		t.Errorf("written after the purge = %v, want [c]", got)
+	}
+}
@@ -0,0 +1,333 @@
+// Package client is a typed Go client of the segmentation API, so services
+// stop hand-rolling HTTP calls against it.
+//
+//	c, err := client.New("http://segmentation-api:8080", client.WithToken(token))
+//	...
+//	res, err := c.Upsert(ctx, 123, client.Upsert{Type: "drug", Name: "Aspirina"})
+//
+// Every call is retried on connection errors, 429, 502, 503 and 504, with
+// exponential backoff that honors Retry-After, and each attempt has its own
+// timeout. Writes carry an Idempotency-Key kept across the retries of a
+// call, so an API with idempotency enabled applies them once. The package
+// only depends on the standard library.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds each attempt of a call
+	DefaultTimeout = 10 * time.Second
+	// DefaultRetries is how many times a failed attempt is retried
+	DefaultRetries = 3
+	// MaxBulkItems is the largest batch BulkUpsert is accepted with
+	MaxBulkItems = 1000
+
+	defaultBaseBackoff = 200 * time.Millisecond
+	defaultMaxBackoff  = 5 * time.Second
+	userAgent          = "segmentation-api-client"
+)
+
+// Client calls the segmentation API; it is safe for concurrent use
+type Client struct {
+	baseURL     *url.URL
+	http        *http.Client
+	token       string
+	timeout     time.Duration
+	retries     int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+// Option customizes a Client
+type Option func(*Client)
+
+// WithHTTPClient sends the requests through hc instead of
+// http.DefaultClient, e.g. to set a transport with TLS or tracing
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithToken sends token as a bearer Authorization header, for APIs behind
+// an authenticating gateway and for the /admin routes
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithTimeout bounds each attempt of a call; 0 leaves only the deadline of
+// the context of the call
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithRetries sets how many times a failed attempt is retried; 0 disables
+// retries
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = max(0, n)
+	}
+}
+
+// WithBackoff sets the wait before the first retry, doubled on each retry
+// up to maxWait. A longer Retry-After from the API is honored up to maxWait.
+func WithBackoff(base, maxWait time.Duration) Option {
+	return func(c *Client) {
+		c.baseBackoff = base
+		c.maxBackoff = max(base, maxWait)
+	}
+}
+
+// New creates a client of the API at baseURL, e.g.
+// "http://segmentation-api:8080"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: must be http(s)://host[:port]", baseURL)
+	}
+
+	c := &Client{
+		baseURL:     u,
+		http:        http.DefaultClient,
+		timeout:     DefaultTimeout,
+		retries:     DefaultRetries,
+		baseBackoff: defaultBaseBackoff,
+		maxBackoff:  defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// GetUserSegmentations returns the segmentations of a user grouped by type
+// ("drugs", "specialties", ...). A user the API does not know is an error
+// for which IsNotFound is true, when the API checks users; otherwise it
+// gets an empty result.
+func (c *Client) GetUserSegmentations(ctx context.Context, userID uint64) (*UserSegmentations, error) {
+	var out UserSegmentations
+	if _, err := c.do(ctx, http.MethodGet, userPath(userID), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Upsert inserts or updates one segmentation of a user. When the API
+// queues writes the result is ResultQueued with the WriteID to follow.
+func (c *Client) Upsert(ctx context.Context, userID uint64, seg Upsert) (*UpsertResult, error) {
+	var out UpsertResult
+	status, err := c.do(ctx, http.MethodPost, userPath(userID), nil, seg, &out)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusAccepted {
+		out = UpsertResult{UserID: userID, Type: seg.Type, Name: seg.Name, Result: ResultQueued, WriteID: out.WriteID}
+	}
+	return &out, nil
+}
+
+// BulkUpsert writes up to MaxBulkItems segmentations of any users. Items
+// the API refuses are reported in BulkResult.Errors, unless the batch is
+// transactional, when any of them fails the whole call.
+func (c *Client) BulkUpsert(ctx context.Context, req BulkRequest) (*BulkResult, error) {
+	if len(req.Items) > MaxBulkItems {
+		return nil, fmt.Errorf("bulk upsert of %d items: at most %d per call", len(req.Items), MaxBulkItems)
+	}
+	var out BulkResult
+	status, err := c.do(ctx, http.MethodPost, "/segmentations/bulk", nil, req, &out)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusAccepted {
+		out.Queued = true
+	}
+	return &out, nil
+}
+
+// Delete removes one segmentation of a user, by type (singular or group
+// key) and name. Deleting a segmentation the user does not have is an
+// error for which IsNotFound is true, unless an earlier attempt of the same
+// call failed in a way that may have deleted it.
+func (c *Client) Delete(ctx context.Context, userID uint64, segType, name string) error {
+	query := url.Values{"type": {segType}, "name": {name}}
+	_, err := c.do(ctx, http.MethodDelete, userPath(userID), query, nil, nil)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && apiErr.unsure {
+		return nil
+	}
+	return err
+}
+
+func userPath(userID uint64) string {
+	return "/users/" + strconv.FormatUint(userID, 10) + "/segmentations"
+}
+
+// do sends a request, retrying it as configured, and decodes a successful
+// response into out. It returns the status of the last attempt.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) (int, error) {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return 0, fmt.Errorf("encode request: %w", err)
+		}
+	}
+	// one key for every attempt, so the API applies a retried write once
+	var idempotencyKey string
+	if method == http.MethodPost {
+		idempotencyKey = rand.Text()
+	}
+
+	var lastErr error
+	// unsure is set once an attempt failed without telling whether the
+	// API applied it: a lost connection or a proxy timeout
+	var unsure bool
+	for attempt := 0; ; attempt++ {
+		status, retryAfter, err := c.attempt(ctx, method, u.String(), payload, idempotencyKey, out)
+		if err == nil {
+			return status, nil
+		}
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			apiErr.unsure = unsure
+		}
+		unsure = unsure || apiErr == nil ||
+			apiErr.StatusCode == http.StatusBadGateway || apiErr.StatusCode == http.StatusGatewayTimeout
+		lastErr = err
+		if attempt >= c.retries || !retryable(ctx, err) {
+			return status, lastErr
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status, fmt.Errorf("%w (last error: %w)", ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends one request; retryAfter is the Retry-After of the
+// response, if any
+func (c *Client) attempt(
+	ctx context.Context,
+	method, target string,
+	payload []byte,
+	idempotencyKey string,
+	out any,
+) (status int, retryAfter time.Duration, err error) {
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out == nil || resp.StatusCode == http.StatusNoContent {
+			io.Copy(io.Discard, resp.Body)
+			return resp.StatusCode, 0, nil
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, 0, fmt.Errorf("decode %s %s response: %w", method, req.URL.Path, err)
+		}
+		return resp.StatusCode, 0, nil
+	}
+
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(raw, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return resp.StatusCode, retryAfter, apiErr
+}
+
+// retryable reports whether a failed attempt may succeed when repeated:
+// connection errors and timeouts of the attempt itself, and the statuses
+// of an overloaded or unreachable API
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// backoff returns the wait before retry attempt+1: exponential with full
+// jitter, or retryAfter when the API asked for longer, capped at
+// maxBackoff
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	wait := c.baseBackoff << min(attempt, 16)
+	if wait <= 0 || wait > c.maxBackoff {
+		wait = c.maxBackoff
+	}
+	if wait > 0 {
+		wait = wait/2 + mrand.N(wait/2+1)
+	}
+	return min(max(wait, retryAfter), c.maxBackoff)
+}
@@ -0,0 +1,301 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"segmentation-api/internal/api"
+	"segmentation-api/internal/models"
+	"segmentation-api/internal/repository"
+	"segmentation-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, append([]Option{WithBackoff(time.Millisecond, 5*time.Millisecond)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:8080", "ftp://host", "http://"} {
+		if _, err := New(u); err == nil {
+			t.Errorf("New(%q) should fail", u)
+		}
+	}
+}
+
+func TestGetUserSegmentations(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/123/segmentations" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		io.WriteString(w, `{"user_id":123,"segmentations":{"drugs":[{"name":"Aspirina","data":{"dose":"500mg"}}]}}`)
+	}, WithToken("secret"))
+
+	got, err := c.GetUserSegmentations(context.Background(), 123)
+	if err != nil {
+		t.Fatalf("GetUserSegmentations() error = %v", err)
+	}
+	if got.UserID != 123 || len(got.Segmentations["drugs"]) != 1 || got.Segmentations["drugs"][0].Name != "Aspirina" {
+		t.Errorf("unexpected result: %+v", got)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	var keys sync.Map
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		keys.Store(r.Header.Get("Idempotency-Key"), true)
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"error":"database unavailable"}`)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"user_id":1,"segmentation_type":"drug","segmentation_name":"Aspirina","result":"inserted"}`)
+	})
+
+	start := time.Now()
+	got, err := c.Upsert(context.Background(), 1, Upsert{Type: "drug", Name: "Aspirina"})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if got.Result != ResultInserted || calls.Load() != 3 {
+		t.Errorf("result = %q after %d calls, want inserted after 3", got.Result, calls.Load())
+	}
+	// Retry-After is capped at the maximum backoff
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retries took %s", elapsed)
+	}
+	n := 0
+	keys.Range(func(key, _ any) bool {
+		if key == "" {
+			t.Error("writes should carry an Idempotency-Key")
+		}
+		n++
+		return true
+	})
+	if n != 1 {
+		t.Errorf("retries used %d Idempotency-Keys, want 1", n)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":"invalid user_id format","value":"0","reason":"must be greater than zero"}`)
+	})
+
+	_, err := c.GetUserSegmentations(context.Background(), 0)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !IsInvalid(err) || IsNotFound(err) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if apiErr.Reason != "must be greater than zero" || !strings.Contains(err.Error(), "400") {
+		t.Errorf("unexpected error details: %+v", apiErr)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("a 400 should not be retried, got %d calls", calls.Load())
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		io.WriteString(w, `{"user_id":1,"segmentations":{}}`)
+	}, WithTimeout(50*time.Millisecond))
+
+	if _, err := c.GetUserSegmentations(context.Background(), 1); err != nil {
+		t.Fatalf("a timed out attempt should be retried, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestDelete_NotFoundAfterUnsureAttempt(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("type") != "drug" || r.URL.Query().Get("name") != "Ácido fólico" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		switch calls.Add(1) {
+		case 1:
+			// applied, but the proxy gave up waiting
+			w.WriteHeader(http.StatusGatewayTimeout)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":"segmentation not found"}`)
+		}
+	})
+
+	if err := c.Delete(context.Background(), 1, "drug", "Ácido fólico"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"segmentation not found"}`)
+	})
+	if err := c.Delete(context.Background(), 1, "drug", "Aspirina"); !IsNotFound(err) {
+		t.Fatalf("Delete() error = %v, want not found", err)
+	}
+}
+
+func TestBulkUpsert_Queued(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req BulkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Items) != 2 {
+			t.Errorf("unexpected body: %+v, %v", req, err)
+		}
+		w.Header().Set("Location", "/writes/w-1")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{"id":"w-1","state":"queued","items":1,"rejected":1,"errors":[{"index":1,"error":"empty name"}]}`)
+	})
+
+	got, err := c.BulkUpsert(context.Background(), BulkRequest{Items: []BulkItem{
+		{UserID: 1, Upsert: Upsert{Type: "drug", Name: "Aspirina"}},
+		{UserID: 2, Upsert: Upsert{Type: "drug"}},
+	}})
+	if err != nil {
+		t.Fatalf("BulkUpsert() error = %v", err)
+	}
+	if !got.Queued || got.WriteID != "w-1" || got.Items != 1 || got.Rejected != 1 || len(got.Errors) != 1 {
+		t.Errorf("unexpected result: %+v", got)
+	}
+
+	if _, err := c.BulkUpsert(context.Background(), BulkRequest{Items: make([]BulkItem, MaxBulkItems+1)}); err == nil {
+		t.Error("an oversized batch should fail before sending")
+	}
+}
+
+// memoryRepository keeps segmentations in memory for the round trip
+// against the real router
+type memoryRepository struct {
+	mu     sync.Mutex
+	rows   []models.Segmentation
+	nextID uint64
+}
+
+func (m *memoryRepository) FindByUserID(ctx context.Context, userID uint64) ([]models.Segmentation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.Segmentation
+	for _, r := range m.rows {
+		if r.UserID == userID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) EachByUserID(ctx context.Context, userID uint64, fn func(models.Segmentation) error) error {
+	return repository.EachFound(ctx, m.FindByUserID, userID, fn)
+}
+
+func (m *memoryRepository) Upsert(ctx context.Context, s *models.Segmentation) (repository.UpsertResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.rows {
+		if r.UserID == s.UserID && r.SegmentationType == s.SegmentationType && r.UniqueName() == s.UniqueName() {
+			m.rows[i].Data = s.Data
+			return repository.UpsertUpdated, nil
+		}
+	}
+	m.nextID++
+	row := *s
+	row.ID = m.nextID
+	m.rows = append(m.rows, row)
+	return repository.UpsertInserted, nil
+}
+
+func (m *memoryRepository) BulkUpsert(ctx context.Context, items []models.Segmentation) (repository.BulkUpsertResult, error) {
+	return repository.UpsertEach(ctx, m.Upsert, items)
+}
+
+func (m *memoryRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	kept := m.rows[:0]
+	for _, r := range m.rows {
+		drop := false
+		for _, id := range ids {
+			drop = drop || r.ID == id
+		}
+		if drop {
+			n++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	m.rows = kept
+	return n, nil
+}
+
+func (m *memoryRepository) Transaction(ctx context.Context, fn func(tx repository.SegmentationRepository) error) error {
+	return fn(m)
+}
+
+func TestClient_AgainstRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := httptest.NewServer(api.SetupRouter(service.NewSegmentationService(&memoryRepository{})))
+	defer srv.Close()
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+
+	res, err := c.Upsert(ctx, 42, Upsert{Type: "drug", Name: "Aspirina", Data: json.RawMessage(`{"dose":"500mg"}`)})
+	if err != nil || res.Result != ResultInserted {
+		t.Fatalf("Upsert() = %+v, %v", res, err)
+	}
+	bulk, err := c.BulkUpsert(ctx, BulkRequest{Items: []BulkItem{
+		{UserID: 42, Upsert: Upsert{Type: "specialties", Name: "Cardiologia"}},
+		{UserID: 42, Upsert: Upsert{Type: "drug", Name: ""}},
+	}})
+	if err != nil || bulk.Inserted != 1 || bulk.Failed != 1 {
+		t.Fatalf("BulkUpsert() = %+v, %v", bulk, err)
+	}
+	if err := c.Delete(ctx, 42, "drugs", "Aspirina"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	got, err := c.GetUserSegmentations(ctx, 42)
+	if err != nil {
+		t.Fatalf("GetUserSegmentations() error = %v", err)
+	}
+	if len(got.Segmentations["drugs"]) != 0 || len(got.Segmentations["specialties"]) != 1 {
+		t.Errorf("unexpected segmentations: %+v", got.Segmentations)
+	}
+	if err := c.Delete(ctx, 42, "drug", "Aspirina"); !IsNotFound(err) {
+		t.Errorf("second Delete() error = %v, want not found", err)
+	}
+}
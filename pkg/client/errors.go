@@ -0,0 +1,54 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is a response of the API with a non-2xx status, decoded from its
+// {"error": ...} envelope
+type Error struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"error"`
+	// Value and Reason describe a refused user_id or body
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Allowed lists the accepted segmentation types of an unknown type
+	Allowed []string `json:"allowed,omitempty"`
+	// Errors lists the items of a transactional bulk write that was rolled
+	// back
+	Errors []BulkItemError `json:"errors,omitempty"`
+
+	// unsure is set when an earlier attempt of the call may have been
+	// applied by the API
+	unsure bool
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("segmentation api: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Reason != "" {
+		msg += " (" + e.Reason + ")"
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 of the API: an unknown user,
+// segmentation or write
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsInvalid reports whether err is a request the API refused as invalid
+// (400 or 422); repeating it will not help
+func IsInvalid(err error) bool {
+	return hasStatus(err, http.StatusBadRequest) || hasStatus(err, http.StatusUnprocessableEntity)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
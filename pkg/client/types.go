@@ -0,0 +1,101 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Result values of UpsertResult
+const (
+	ResultInserted = "inserted"
+	ResultUpdated  = "updated"
+	// ResultNoop is a write that matched the stored segmentation
+	ResultNoop = "noop"
+	// ResultQueued is a write accepted by the write queue of the API, not
+	// yet applied; follow it at GET /writes/{WriteID}
+	ResultQueued = "queued"
+)
+
+// Segmentation is one segmentation of a user
+type Segmentation struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// UserSegmentations is the segmentation set of a user, grouped by type
+type UserSegmentations struct {
+	UserID        uint64                    `json:"user_id"`
+	Segmentations map[string][]Segmentation `json:"segmentations"`
+	// Labels holds localized group labels, when a locale was negotiated
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Upsert is a single segmentation write. Type may be singular ("drug") or
+// a group key ("drugs"); an empty Data is stored as {}.
+type Upsert struct {
+	Type string          `json:"segmentation_type"`
+	Name string          `json:"segmentation_name"`
+	Data json.RawMessage `json:"data,omitempty"`
+	// CreatedAt keeps the creation time of a segmentation migrated from
+	// another system; it only applies when the write inserts the row
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// Warning is a non-fatal finding of the API validation
+type Warning struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// UpsertResult describes the outcome of a single write
+type UpsertResult struct {
+	UserID   uint64    `json:"user_id"`
+	Type     string    `json:"segmentation_type"`
+	Name     string    `json:"segmentation_name"`
+	Result   string    `json:"result"`
+	Warnings []Warning `json:"warnings,omitempty"`
+	// WriteID identifies a queued write (ResultQueued)
+	WriteID string `json:"id,omitempty"`
+}
+
+// BulkItem is one entry of a bulk write
+type BulkItem struct {
+	UserID uint64 `json:"user_id"`
+	Upsert
+}
+
+// BulkRequest is a batch of at most MaxBulkItems writes. Transactional
+// applies them all or none.
+type BulkRequest struct {
+	Items         []BulkItem `json:"items"`
+	Transactional bool       `json:"transactional,omitempty"`
+}
+
+// BulkItemError reports why the item at Index was not applied
+type BulkItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// BulkItemWarning is a warning raised by the item at Index
+type BulkItemWarning struct {
+	Index int `json:"index"`
+	Warning
+}
+
+// BulkResult summarizes a bulk write. A batch accepted by the write queue
+// is Queued, with its WriteID, the number of Items queued and the items
+// refused before queueing in Rejected and Errors.
+type BulkResult struct {
+	Inserted int               `json:"inserted"`
+	Updated  int               `json:"updated"`
+	Failed   int               `json:"failed"`
+	Errors   []BulkItemError   `json:"errors,omitempty"`
+	Warnings []BulkItemWarning `json:"warnings,omitempty"`
+
+	Queued   bool   `json:"-"`
+	WriteID  string `json:"id,omitempty"`
+	Items    int    `json:"items,omitempty"`
+	Rejected int    `json:"rejected,omitempty"`
+}
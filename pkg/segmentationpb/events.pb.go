@@ -0,0 +1,197 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: segmentation/v1/events.proto
+
+package segmentationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SegmentationEvent is a change of the outbox: the body of a webhook
+// delivery with format "protobuf", the same fields as the JSON one.
+type SegmentationEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// event_id identifies the event; a retried delivery carries the same one
+	EventId uint64 `protobuf:"varint,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// event_type is "segmentation.upserted" or "segmentation.deleted"; new
+	// types may be added, so receivers should skip the ones they do not know
+	EventType        string `protobuf:"bytes,2,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	UserId           uint64 `protobuf:"varint,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	SegmentationType string `protobuf:"bytes,4,opt,name=segmentation_type,json=segmentationType,proto3" json:"segmentation_type,omitempty"`
+	SegmentationName string `protobuf:"bytes,5,opt,name=segmentation_name,json=segmentationName,proto3" json:"segmentation_name,omitempty"`
+	// data is the JSON object of an upserted segmentation; empty for deletes
+	Data       string                 `protobuf:"bytes,6,opt,name=data,proto3" json:"data,omitempty"`
+	OccurredAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	// run_id is the processor run that made the change; empty for API writes
+	RunId         string `protobuf:"bytes,8,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SegmentationEvent) Reset() {
+	*x = SegmentationEvent{}
+	mi := &file_segmentation_v1_events_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SegmentationEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SegmentationEvent) ProtoMessage() {}
+
+func (x *SegmentationEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_segmentation_v1_events_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SegmentationEvent.ProtoReflect.Descriptor instead.
+func (*SegmentationEvent) Descriptor() ([]byte, []int) {
+	return file_segmentation_v1_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SegmentationEvent) GetEventId() uint64 {
+	if x != nil {
+		return x.EventId
+	}
+	return 0
+}
+
+func (x *SegmentationEvent) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *SegmentationEvent) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *SegmentationEvent) GetSegmentationType() string {
+	if x != nil {
+		return x.SegmentationType
+	}
+	return ""
+}
+
+func (x *SegmentationEvent) GetSegmentationName() string {
+	if x != nil {
+		return x.SegmentationName
+	}
+	return ""
+}
+
+func (x *SegmentationEvent) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *SegmentationEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *SegmentationEvent) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+var File_segmentation_v1_events_proto protoreflect.FileDescriptor
+
+const file_segmentation_v1_events_proto_rawDesc = "" +
+	"\n" +
+	"\x1csegmentation/v1/events.proto\x12\x0fsegmentation.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa8\x02\n" +
+	"\x11SegmentationEvent\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\x04R\aeventId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x02 \x01(\tR\teventType\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\x04R\x06userId\x12+\n" +
+	"\x11segmentation_type\x18\x04 \x01(\tR\x10segmentationType\x12+\n" +
+	"\x11segmentation_name\x18\x05 \x01(\tR\x10segmentationName\x12\x12\n" +
+	"\x04data\x18\x06 \x01(\tR\x04data\x12;\n" +
+	"\voccurred_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt\x12\x15\n" +
+	"\x06run_id\x18\b \x01(\tR\x05runIdB4Z2segmentation-api/pkg/segmentationpb;segmentationpbb\x06proto3"
+
+var (
+	file_segmentation_v1_events_proto_rawDescOnce sync.Once
+	file_segmentation_v1_events_proto_rawDescData []byte
+)
+
+func file_segmentation_v1_events_proto_rawDescGZIP() []byte {
+	file_segmentation_v1_events_proto_rawDescOnce.Do(func() {
+		file_segmentation_v1_events_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_segmentation_v1_events_proto_rawDesc), len(file_segmentation_v1_events_proto_rawDesc)))
+	})
+	return file_segmentation_v1_events_proto_rawDescData
+}
+
+var file_segmentation_v1_events_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_segmentation_v1_events_proto_goTypes = []any{
+	(*SegmentationEvent)(nil),     // 0: segmentation.v1.SegmentationEvent
+	(*timestamppb.Timestamp)(nil), // 1: google.protobuf.Timestamp
+}
+var file_segmentation_v1_events_proto_depIdxs = []int32{
+	1, // 0: segmentation.v1.SegmentationEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_segmentation_v1_events_proto_init() }
+func file_segmentation_v1_events_proto_init() {
+	if File_segmentation_v1_events_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_segmentation_v1_events_proto_rawDesc), len(file_segmentation_v1_events_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_segmentation_v1_events_proto_goTypes,
+		DependencyIndexes: file_segmentation_v1_events_proto_depIdxs,
+		MessageInfos:      file_segmentation_v1_events_proto_msgTypes,
+	}.Build()
+	File_segmentation_v1_events_proto = out.File
+	file_segmentation_v1_events_proto_goTypes = nil
+	file_segmentation_v1_events_proto_depIdxs = nil
+}
@@ -0,0 +1,333 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: segmentation/v1/ingest.proto
+
+package segmentationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// UpsertRecord is one segmentation of a user, as in POST /segmentations/bulk.
+type UpsertRecord struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	UserId           uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	SegmentationType string                 `protobuf:"bytes,2,opt,name=segmentation_type,json=segmentationType,proto3" json:"segmentation_type,omitempty"`
+	SegmentationName string                 `protobuf:"bytes,3,opt,name=segmentation_name,json=segmentationName,proto3" json:"segmentation_name,omitempty"`
+	// data is the JSON object of the segmentation; empty for none
+	Data string `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	// created_at keeps the creation time of a segmentation migrated from
+	// another system; it only applies when the write inserts the row
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertRecord) Reset() {
+	*x = UpsertRecord{}
+	mi := &file_segmentation_v1_ingest_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertRecord) ProtoMessage() {}
+
+func (x *UpsertRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_segmentation_v1_ingest_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertRecord.ProtoReflect.Descriptor instead.
+func (*UpsertRecord) Descriptor() ([]byte, []int) {
+	return file_segmentation_v1_ingest_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *UpsertRecord) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *UpsertRecord) GetSegmentationType() string {
+	if x != nil {
+		return x.SegmentationType
+	}
+	return ""
+}
+
+func (x *UpsertRecord) GetSegmentationName() string {
+	if x != nil {
+		return x.SegmentationName
+	}
+	return ""
+}
+
+func (x *UpsertRecord) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *UpsertRecord) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+// StreamUpsertSummary counts the records of a stream.
+type StreamUpsertSummary struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Received uint64                 `protobuf:"varint,1,opt,name=received,proto3" json:"received,omitempty"`
+	Inserted uint64                 `protobuf:"varint,2,opt,name=inserted,proto3" json:"inserted,omitempty"`
+	Updated  uint64                 `protobuf:"varint,3,opt,name=updated,proto3" json:"updated,omitempty"`
+	// unchanged records were already stored as sent
+	Unchanged uint64 `protobuf:"varint,4,opt,name=unchanged,proto3" json:"unchanged,omitempty"`
+	Failed    uint64 `protobuf:"varint,5,opt,name=failed,proto3" json:"failed,omitempty"`
+	Warnings  uint64 `protobuf:"varint,6,opt,name=warnings,proto3" json:"warnings,omitempty"`
+	// errors lists the first failed records
+	Errors        []*RecordError `protobuf:"bytes,7,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamUpsertSummary) Reset() {
+	*x = StreamUpsertSummary{}
+	mi := &file_segmentation_v1_ingest_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamUpsertSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamUpsertSummary) ProtoMessage() {}
+
+func (x *StreamUpsertSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_segmentation_v1_ingest_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamUpsertSummary.ProtoReflect.Descriptor instead.
+func (*StreamUpsertSummary) Descriptor() ([]byte, []int) {
+	return file_segmentation_v1_ingest_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StreamUpsertSummary) GetReceived() uint64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *StreamUpsertSummary) GetInserted() uint64 {
+	if x != nil {
+		return x.Inserted
+	}
+	return 0
+}
+
+func (x *StreamUpsertSummary) GetUpdated() uint64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *StreamUpsertSummary) GetUnchanged() uint64 {
+	if x != nil {
+		return x.Unchanged
+	}
+	return 0
+}
+
+func (x *StreamUpsertSummary) GetFailed() uint64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *StreamUpsertSummary) GetWarnings() uint64 {
+	if x != nil {
+		return x.Warnings
+	}
+	return 0
+}
+
+func (x *StreamUpsertSummary) GetErrors() []*RecordError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+// RecordError is why a record was not written.
+type RecordError struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// index is the position of the record in the stream, from 0
+	Index         uint64 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordError) Reset() {
+	*x = RecordError{}
+	mi := &file_segmentation_v1_ingest_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordError) ProtoMessage() {}
+
+func (x *RecordError) ProtoReflect() protoreflect.Message {
+	mi := &file_segmentation_v1_ingest_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordError.ProtoReflect.Descriptor instead.
+func (*RecordError) Descriptor() ([]byte, []int) {
+	return file_segmentation_v1_ingest_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RecordError) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *RecordError) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_segmentation_v1_ingest_proto protoreflect.FileDescriptor
+
+const file_segmentation_v1_ingest_proto_rawDesc = "" +
+	"\n" +
+	"\x1csegmentation/v1/ingest.proto\x12\x0fsegmentation.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd0\x01\n" +
+	"\fUpsertRecord\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12+\n" +
+	"\x11segmentation_type\x18\x02 \x01(\tR\x10segmentationType\x12+\n" +
+	"\x11segmentation_name\x18\x03 \x01(\tR\x10segmentationName\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\tR\x04data\x129\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xef\x01\n" +
+	"\x13StreamUpsertSummary\x12\x1a\n" +
+	"\breceived\x18\x01 \x01(\x04R\breceived\x12\x1a\n" +
+	"\binserted\x18\x02 \x01(\x04R\binserted\x12\x18\n" +
+	"\aupdated\x18\x03 \x01(\x04R\aupdated\x12\x1c\n" +
+	"\tunchanged\x18\x04 \x01(\x04R\tunchanged\x12\x16\n" +
+	"\x06failed\x18\x05 \x01(\x04R\x06failed\x12\x1a\n" +
+	"\bwarnings\x18\x06 \x01(\x04R\bwarnings\x124\n" +
+	"\x06errors\x18\a \x03(\v2\x1c.segmentation.v1.RecordErrorR\x06errors\"9\n" +
+	"\vRecordError\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x04R\x05index\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error2f\n" +
+	"\rIngestService\x12U\n" +
+	"\fStreamUpsert\x12\x1d.segmentation.v1.UpsertRecord\x1a$.segmentation.v1.StreamUpsertSummary(\x01B4Z2segmentation-api/pkg/segmentationpb;segmentationpbb\x06proto3"
+
+var (
+	file_segmentation_v1_ingest_proto_rawDescOnce sync.Once
+	file_segmentation_v1_ingest_proto_rawDescData []byte
+)
+
+func file_segmentation_v1_ingest_proto_rawDescGZIP() []byte {
+	file_segmentation_v1_ingest_proto_rawDescOnce.Do(func() {
+		file_segmentation_v1_ingest_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_segmentation_v1_ingest_proto_rawDesc), len(file_segmentation_v1_ingest_proto_rawDesc)))
+	})
+	return file_segmentation_v1_ingest_proto_rawDescData
+}
+
+var file_segmentation_v1_ingest_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_segmentation_v1_ingest_proto_goTypes = []any{
+	(*UpsertRecord)(nil),          // 0: segmentation.v1.UpsertRecord
+	(*StreamUpsertSummary)(nil),   // 1: segmentation.v1.StreamUpsertSummary
+	(*RecordError)(nil),           // 2: segmentation.v1.RecordError
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_segmentation_v1_ingest_proto_depIdxs = []int32{
+	3, // 0: segmentation.v1.UpsertRecord.created_at:type_name -> google.protobuf.Timestamp
+	2, // 1: segmentation.v1.StreamUpsertSummary.errors:type_name -> segmentation.v1.RecordError
+	0, // 2: segmentation.v1.IngestService.StreamUpsert:input_type -> segmentation.v1.UpsertRecord
+	1, // 3: segmentation.v1.IngestService.StreamUpsert:output_type -> segmentation.v1.StreamUpsertSummary
+	3, // [3:4] is the sub-list for method output_type
+	2, // [2:3] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_segmentation_v1_ingest_proto_init() }
+func file_segmentation_v1_ingest_proto_init() {
+	if File_segmentation_v1_ingest_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_segmentation_v1_ingest_proto_rawDesc), len(file_segmentation_v1_ingest_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_segmentation_v1_ingest_proto_goTypes,
+		DependencyIndexes: file_segmentation_v1_ingest_proto_depIdxs,
+		MessageInfos:      file_segmentation_v1_ingest_proto_msgTypes,
+	}.Build()
+	File_segmentation_v1_ingest_proto = out.File
+	file_segmentation_v1_ingest_proto_goTypes = nil
+	file_segmentation_v1_ingest_proto_depIdxs = nil
+}
@@ -0,0 +1,132 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: segmentation/v1/ingest.proto
+
+package segmentationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	IngestService_StreamUpsert_FullMethodName = "/segmentation.v1.IngestService/StreamUpsert"
+)
+
+// IngestServiceClient is the client API for IngestService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// IngestService writes segmentations for upstream services, as an
+// alternative to uploading CSV files for the processor.
+type IngestServiceClient interface {
+	// StreamUpsert writes a stream of records, validated one by one and
+	// applied in batches as they arrive. Invalid records are reported in the
+	// summary and do not stop the others. The stream is aborted when the
+	// database cannot take the writes (UNAVAILABLE, DEADLINE_EXCEEDED); the
+	// records applied before stay written, and sending them again is
+	// harmless since every write is an upsert.
+	StreamUpsert(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UpsertRecord, StreamUpsertSummary], error)
+}
+
+type ingestServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIngestServiceClient(cc grpc.ClientConnInterface) IngestServiceClient {
+	return &ingestServiceClient{cc}
+}
+
+func (c *ingestServiceClient) StreamUpsert(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UpsertRecord, StreamUpsertSummary], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IngestService_ServiceDesc.Streams[0], IngestService_StreamUpsert_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UpsertRecord, StreamUpsertSummary]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_StreamUpsertClient = grpc.ClientStreamingClient[UpsertRecord, StreamUpsertSummary]
+
+// IngestServiceServer is the server API for IngestService service.
+// All implementations must embed UnimplementedIngestServiceServer
+// for forward compatibility.
+//
+// IngestService writes segmentations for upstream services, as an
+// alternative to uploading CSV files for the processor.
+type IngestServiceServer interface {
+	// StreamUpsert writes a stream of records, validated one by one and
+	// applied in batches as they arrive. Invalid records are reported in the
+	// summary and do not stop the others. The stream is aborted when the
+	// database cannot take the writes (UNAVAILABLE, DEADLINE_EXCEEDED); the
+	// records applied before stay written, and sending them again is
+	// harmless since every write is an upsert.
+	StreamUpsert(grpc.ClientStreamingServer[UpsertRecord, StreamUpsertSummary]) error
+	mustEmbedUnimplementedIngestServiceServer()
+}
+
+// UnimplementedIngestServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIngestServiceServer struct{}
+
+func (UnimplementedIngestServiceServer) StreamUpsert(grpc.ClientStreamingServer[UpsertRecord, StreamUpsertSummary]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUpsert not implemented")
+}
+func (UnimplementedIngestServiceServer) mustEmbedUnimplementedIngestServiceServer() {}
+func (UnimplementedIngestServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeIngestServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IngestServiceServer will
+// result in compilation errors.
+type UnsafeIngestServiceServer interface {
+	mustEmbedUnimplementedIngestServiceServer()
+}
+
+func RegisterIngestServiceServer(s grpc.ServiceRegistrar, srv IngestServiceServer) {
+	// If the following call pancis, it indicates UnimplementedIngestServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&IngestService_ServiceDesc, srv)
+}
+
+func _IngestService_StreamUpsert_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IngestServiceServer).StreamUpsert(&grpc.GenericServerStream[UpsertRecord, StreamUpsertSummary]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_StreamUpsertServer = grpc.ClientStreamingServer[UpsertRecord, StreamUpsertSummary]
+
+// IngestService_ServiceDesc is the grpc.ServiceDesc for IngestService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IngestService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "segmentation.v1.IngestService",
+	HandlerType: (*IngestServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUpsert",
+			Handler:       _IngestService_StreamUpsert_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "segmentation/v1/ingest.proto",
+}
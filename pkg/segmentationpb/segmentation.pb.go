@@ -0,0 +1,279 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: segmentation/v1/segmentation.proto
+
+package segmentationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Segmentation is one segmentation of a user.
+type Segmentation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// group is the key of the type in the HTTP response, its plural ("drugs"
+	// for "drug")
+	Group            string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	SegmentationName string `protobuf:"bytes,2,opt,name=segmentation_name,json=segmentationName,proto3" json:"segmentation_name,omitempty"`
+	// data is the JSON object of the segmentation, kept as stored; empty for
+	// none
+	Data          string                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Segmentation) Reset() {
+	*x = Segmentation{}
+	mi := &file_segmentation_v1_segmentation_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Segmentation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Segmentation) ProtoMessage() {}
+
+func (x *Segmentation) ProtoReflect() protoreflect.Message {
+	mi := &file_segmentation_v1_segmentation_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Segmentation.ProtoReflect.Descriptor instead.
+func (*Segmentation) Descriptor() ([]byte, []int) {
+	return file_segmentation_v1_segmentation_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Segmentation) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *Segmentation) GetSegmentationName() string {
+	if x != nil {
+		return x.SegmentationName
+	}
+	return ""
+}
+
+func (x *Segmentation) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *Segmentation) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Segmentation) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type GetUserSegmentationsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserSegmentationsRequest) Reset() {
+	*x = GetUserSegmentationsRequest{}
+	mi := &file_segmentation_v1_segmentation_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserSegmentationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserSegmentationsRequest) ProtoMessage() {}
+
+func (x *GetUserSegmentationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_segmentation_v1_segmentation_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserSegmentationsRequest.ProtoReflect.Descriptor instead.
+func (*GetUserSegmentationsRequest) Descriptor() ([]byte, []int) {
+	return file_segmentation_v1_segmentation_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetUserSegmentationsRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+// UserSegmentations lists the segmentations of a user, sorted by group and
+// in the order they are stored within a group.
+type UserSegmentations struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Segmentations []*Segmentation        `protobuf:"bytes,2,rep,name=segmentations,proto3" json:"segmentations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserSegmentations) Reset() {
+	*x = UserSegmentations{}
+	mi := &file_segmentation_v1_segmentation_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserSegmentations) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserSegmentations) ProtoMessage() {}
+
+func (x *UserSegmentations) ProtoReflect() protoreflect.Message {
+	mi := &file_segmentation_v1_segmentation_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserSegmentations.ProtoReflect.Descriptor instead.
+func (*UserSegmentations) Descriptor() ([]byte, []int) {
+	return file_segmentation_v1_segmentation_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UserSegmentations) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *UserSegmentations) GetSegmentations() []*Segmentation {
+	if x != nil {
+		return x.Segmentations
+	}
+	return nil
+}
+
+var File_segmentation_v1_segmentation_proto protoreflect.FileDescriptor
+
+const file_segmentation_v1_segmentation_proto_rawDesc = "" +
+	"\n" +
+	"\"segmentation/v1/segmentation.proto\x12\x0fsegmentation.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xdb\x01\n" +
+	"\fSegmentation\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\x12+\n" +
+	"\x11segmentation_name\x18\x02 \x01(\tR\x10segmentationName\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\tR\x04data\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"6\n" +
+	"\x1bGetUserSegmentationsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\"q\n" +
+	"\x11UserSegmentations\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12C\n" +
+	"\rsegmentations\x18\x02 \x03(\v2\x1d.segmentation.v1.SegmentationR\rsegmentations2\x7f\n" +
+	"\x13SegmentationService\x12h\n" +
+	"\x14GetUserSegmentations\x12,.segmentation.v1.GetUserSegmentationsRequest\x1a\".segmentation.v1.UserSegmentationsB4Z2segmentation-api/pkg/segmentationpb;segmentationpbb\x06proto3"
+
+var (
+	file_segmentation_v1_segmentation_proto_rawDescOnce sync.Once
+	file_segmentation_v1_segmentation_proto_rawDescData []byte
+)
+
+func file_segmentation_v1_segmentation_proto_rawDescGZIP() []byte {
+	file_segmentation_v1_segmentation_proto_rawDescOnce.Do(func() {
+		file_segmentation_v1_segmentation_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_segmentation_v1_segmentation_proto_rawDesc), len(file_segmentation_v1_segmentation_proto_rawDesc)))
+	})
+	return file_segmentation_v1_segmentation_proto_rawDescData
+}
+
+var file_segmentation_v1_segmentation_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_segmentation_v1_segmentation_proto_goTypes = []any{
+	(*Segmentation)(nil),                // 0: segmentation.v1.Segmentation
+	(*GetUserSegmentationsRequest)(nil), // 1: segmentation.v1.GetUserSegmentationsRequest
+	(*UserSegmentations)(nil),           // 2: segmentation.v1.UserSegmentations
+	(*timestamppb.Timestamp)(nil),       // 3: google.protobuf.Timestamp
+}
+var file_segmentation_v1_segmentation_proto_depIdxs = []int32{
+	3, // 0: segmentation.v1.Segmentation.created_at:type_name -> google.protobuf.Timestamp
+	3, // 1: segmentation.v1.Segmentation.updated_at:type_name -> google.protobuf.Timestamp
+	0, // 2: segmentation.v1.UserSegmentations.segmentations:type_name -> segmentation.v1.Segmentation
+	1, // 3: segmentation.v1.SegmentationService.GetUserSegmentations:input_type -> segmentation.v1.GetUserSegmentationsRequest
+	2, // 4: segmentation.v1.SegmentationService.GetUserSegmentations:output_type -> segmentation.v1.UserSegmentations
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_segmentation_v1_segmentation_proto_init() }
+func file_segmentation_v1_segmentation_proto_init() {
+	if File_segmentation_v1_segmentation_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_segmentation_v1_segmentation_proto_rawDesc), len(file_segmentation_v1_segmentation_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_segmentation_v1_segmentation_proto_goTypes,
+		DependencyIndexes: file_segmentation_v1_segmentation_proto_depIdxs,
+		MessageInfos:      file_segmentation_v1_segmentation_proto_msgTypes,
+	}.Build()
+	File_segmentation_v1_segmentation_proto = out.File
+	file_segmentation_v1_segmentation_proto_goTypes = nil
+	file_segmentation_v1_segmentation_proto_depIdxs = nil
+}
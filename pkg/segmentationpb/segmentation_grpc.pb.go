@@ -0,0 +1,133 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: segmentation/v1/segmentation.proto
+
+package segmentationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SegmentationService_GetUserSegmentations_FullMethodName = "/segmentation.v1.SegmentationService/GetUserSegmentations"
+)
+
+// SegmentationServiceClient is the client API for SegmentationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SegmentationService reads the segmentations of a user, as
+// GET /users/{user_id}/segmentations.
+type SegmentationServiceClient interface {
+	// GetUserSegmentations returns every segmentation of a user. A user
+	// without segmentations answers NOT_FOUND when the users table says it
+	// does not exist, as the HTTP endpoint does.
+	GetUserSegmentations(ctx context.Context, in *GetUserSegmentationsRequest, opts ...grpc.CallOption) (*UserSegmentations, error)
+}
+
+type segmentationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSegmentationServiceClient(cc grpc.ClientConnInterface) SegmentationServiceClient {
+	return &segmentationServiceClient{cc}
+}
+
+func (c *segmentationServiceClient) GetUserSegmentations(ctx context.Context, in *GetUserSegmentationsRequest, opts ...grpc.CallOption) (*UserSegmentations, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserSegmentations)
+	err := c.cc.Invoke(ctx, SegmentationService_GetUserSegmentations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SegmentationServiceServer is the server API for SegmentationService service.
+// All implementations must embed UnimplementedSegmentationServiceServer
+// for forward compatibility.
+//
+// SegmentationService reads the segmentations of a user, as
+// GET /users/{user_id}/segmentations.
+type SegmentationServiceServer interface {
+	// GetUserSegmentations returns every segmentation of a user. A user
+	// without segmentations answers NOT_FOUND when the users table says it
+	// does not exist, as the HTTP endpoint does.
+	GetUserSegmentations(context.Context, *GetUserSegmentationsRequest) (*UserSegmentations, error)
+	mustEmbedUnimplementedSegmentationServiceServer()
+}
+
+// UnimplementedSegmentationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSegmentationServiceServer struct{}
+
+func (UnimplementedSegmentationServiceServer) GetUserSegmentations(context.Context, *GetUserSegmentationsRequest) (*UserSegmentations, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserSegmentations not implemented")
+}
+func (UnimplementedSegmentationServiceServer) mustEmbedUnimplementedSegmentationServiceServer() {}
+func (UnimplementedSegmentationServiceServer) testEmbeddedByValue()                             {}
+
+// UnsafeSegmentationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SegmentationServiceServer will
+// result in compilation errors.
+type UnsafeSegmentationServiceServer interface {
+	mustEmbedUnimplementedSegmentationServiceServer()
+}
+
+func RegisterSegmentationServiceServer(s grpc.ServiceRegistrar, srv SegmentationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSegmentationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SegmentationService_ServiceDesc, srv)
+}
+
+func _SegmentationService_GetUserSegmentations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserSegmentationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServiceServer).GetUserSegmentations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SegmentationService_GetUserSegmentations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServiceServer).GetUserSegmentations(ctx, req.(*GetUserSegmentationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SegmentationService_ServiceDesc is the grpc.ServiceDesc for SegmentationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SegmentationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "segmentation.v1.SegmentationService",
+	HandlerType: (*SegmentationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetUserSegmentations",
+			Handler:    _SegmentationService_GetUserSegmentations_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "segmentation/v1/segmentation.proto",
+}